@@ -0,0 +1,276 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/grnsv/shortener/pkg/client"
+)
+
+// command runs a subcommand with its arguments.
+type command func(ctx context.Context, env *environment, args []string) error
+
+// environment is what commands run with.
+type environment struct {
+	opts   options
+	client client.Client
+	stdin  io.Reader
+	stderr io.Writer
+	out    printer
+}
+
+var commands = map[string]command{
+	"shorten": runShorten,
+	"batch":   runBatch,
+	"list":    runList,
+	"delete":  runDelete,
+	"expand":  runExpand,
+	"stats":   runStats,
+}
+
+// run parses the global flags, connects to the server and runs the command named by the first argument.
+func run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	opts, args, err := parseOptions(args, stderr)
+	if err != nil {
+		return err
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		return fmt.Errorf("unknown command %q, see client -h", args[0])
+	}
+
+	c, err := newClient(opts)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = c.Close() }()
+
+	ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+	env := &environment{opts: opts, client: c, stdin: stdin, stderr: stderr, out: printer{w: stdout, format: opts.Output}}
+	return cmd(ctx, env, args[1:])
+}
+
+// newClient connects to the server with the transport chosen by opts. The token the server issues is kept
+// in the token file, unless an API key is given.
+func newClient(opts options) (client.Client, error) {
+	clientOpts := []client.Option{client.WithTokenStore(client.FileTokenStore{Path: opts.TokenFile})}
+	if opts.APIKey != "" {
+		clientOpts = append(clientOpts, client.WithAPIKey(opts.APIKey))
+	}
+	if opts.Workspace != "" {
+		clientOpts = append(clientOpts, client.WithWorkspace(opts.Workspace))
+	}
+	if opts.Transport == transportGRPC {
+		return client.NewGRPC(opts.GRPC, clientOpts...)
+	}
+	return client.NewHTTP(opts.Server, clientOpts...)
+}
+
+// newFlagSet returns a flag set of a command, which writes its usage to the error output of env.
+func newFlagSet(env *environment, name, arguments string) *flag.FlagSet {
+	set := flag.NewFlagSet(name, flag.ContinueOnError)
+	set.SetOutput(env.stderr)
+	set.Usage = func() {
+		_, _ = fmt.Fprintf(env.stderr, "Usage: client %s [flags] %s\n", name, arguments)
+		set.PrintDefaults()
+	}
+	return set
+}
+
+// stringsFlag collects the values of a repeated flag.
+type stringsFlag []string
+
+func (s *stringsFlag) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringsFlag) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
+
+func runShorten(ctx context.Context, env *environment, args []string) error {
+	var req client.ShortenRequest
+	var tags stringsFlag
+	set := newFlagSet(env, "shorten", "URL")
+	set.StringVar(&req.Password, "password", "", "Passphrase required to follow the link")
+	set.BoolVar(&req.Interstitial, "interstitial", false, "Show a preview page instead of redirecting")
+	set.IntVar(&req.RedirectCode, "redirect-code", 0, "HTTP status of the redirect: 301, 302, 307 or 308")
+	set.StringVar(&req.Metadata.Title, "title", "", "Title of the link")
+	set.StringVar(&req.Metadata.Description, "description", "", "Description of the link")
+	set.StringVar(&req.Metadata.Notes, "notes", "", "Notes on the link")
+	set.Var(&tags, "tag", "Tag of the link, repeatable")
+	set.StringVar(&req.Domain, "domain", "", "Verified custom domain to shorten the link on, with the HTTP API only")
+	if err := set.Parse(args); err != nil {
+		return err
+	}
+	if set.NArg() != 1 {
+		set.Usage()
+		return errors.New("shorten takes exactly one URL")
+	}
+	req.URL = set.Arg(0)
+	req.Metadata.Tags = tags
+
+	link, alreadyExists, err := env.client.Shorten(ctx, req)
+	if err != nil {
+		return err
+	}
+	status := client.BatchCreated
+	if alreadyExists {
+		status = client.BatchExists
+	}
+	return env.out.Print(link,
+		[]string{"ID", "SHORT URL", "ORIGINAL URL", "STATUS"},
+		[][]string{{link.ID, link.ShortURL, link.OriginalURL, status}},
+	)
+}
+
+func runBatch(ctx context.Context, env *environment, args []string) error {
+	var file string
+	set := newFlagSet(env, "batch", "[URL...]")
+	set.StringVar(&file, "f", "", "File with a URL per line, - for standard input; used if no URL is given")
+	if err := set.Parse(args); err != nil {
+		return err
+	}
+
+	urls := set.Args()
+	if len(urls) == 0 {
+		var err error
+		if urls, err = readLines(env.stdin, file); err != nil {
+			return err
+		}
+	}
+	if len(urls) == 0 {
+		return errors.New("no URL given")
+	}
+
+	items := make([]client.BatchItem, len(urls))
+	for i, u := range urls {
+		items[i] = client.BatchItem{CorrelationID: strconv.Itoa(i + 1), URL: u}
+	}
+	results, err := env.client.ShortenBatch(ctx, items)
+	if err != nil {
+		return err
+	}
+
+	rows := make([][]string, len(results))
+	for i, result := range results {
+		rows[i] = []string{result.CorrelationID, result.Status, orDash(result.ShortURL), orDash(result.Error)}
+	}
+	return env.out.Print(results, []string{"#", "STATUS", "SHORT URL", "ERROR"}, rows)
+}
+
+// readLines reads the non-blank lines of the file, or of stdin if the file is - or empty.
+func readLines(stdin io.Reader, file string) ([]string, error) {
+	r := stdin
+	if file != "" && file != "-" {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, err
+		}
+		defer func() { _ = f.Close() }()
+		r = f
+	}
+
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, scanner.Err()
+}
+
+func runList(ctx context.Context, env *environment, args []string) error {
+	var tags stringsFlag
+	set := newFlagSet(env, "list", "")
+	set.Var(&tags, "tag", "Only links having the tag, repeatable")
+	if err := set.Parse(args); err != nil {
+		return err
+	}
+
+	links, err := env.client.List(ctx, tags...)
+	if err != nil {
+		return err
+	}
+
+	rows := make([][]string, len(links))
+	for i, link := range links {
+		rows[i] = []string{
+			link.ID,
+			link.OriginalURL,
+			orDash(link.Metadata.Title),
+			orDash(strings.Join(link.Metadata.Tags, ",")),
+			formatTime(link.CreatedAt),
+		}
+	}
+	return env.out.Print(links,
+		[]string{"ID", "ORIGINAL URL", "TITLE", "TAGS", "CREATED"}, rows)
+}
+
+func runDelete(ctx context.Context, env *environment, args []string) error {
+	set := newFlagSet(env, "delete", "ID...")
+	if err := set.Parse(args); err != nil {
+		return err
+	}
+	if set.NArg() == 0 {
+		set.Usage()
+		return errors.New("no ID given")
+	}
+
+	if err := env.client.Delete(ctx, set.Args()...); err != nil {
+		return err
+	}
+	return env.out.Print(set.Args(), []string{"DELETING"}, [][]string{{strings.Join(set.Args(), " ")}})
+}
+
+func runExpand(ctx context.Context, env *environment, args []string) error {
+	var password string
+	set := newFlagSet(env, "expand", "ID")
+	set.StringVar(&password, "password", "", "Passphrase of a protected link")
+	if err := set.Parse(args); err != nil {
+		return err
+	}
+	if set.NArg() != 1 {
+		set.Usage()
+		return errors.New("expand takes exactly one ID")
+	}
+
+	exp, err := env.client.Expand(ctx, set.Arg(0), password)
+	if err != nil {
+		return err
+	}
+	code := "default"
+	if exp.RedirectCode != 0 {
+		code = strconv.Itoa(exp.RedirectCode)
+	}
+	return env.out.Print(exp,
+		[]string{"URL", "REDIRECT", "INTERSTITIAL"},
+		[][]string{{exp.URL, code, strconv.FormatBool(exp.Interstitial)}},
+	)
+}
+
+func runStats(ctx context.Context, env *environment, args []string) error {
+	set := newFlagSet(env, "stats", "")
+	if err := set.Parse(args); err != nil {
+		return err
+	}
+
+	stats, err := env.client.Stats(ctx)
+	if err != nil {
+		return err
+	}
+	return env.out.Print(stats,
+		[]string{"URLS", "USERS"},
+		[][]string{{strconv.Itoa(stats.URLs), strconv.Itoa(stats.Users)}},
+	)
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/caarlos0/env/v11"
+)
+
+// Transports and output formats of the client.
+const (
+	transportHTTP = "http"
+	transportGRPC = "grpc"
+
+	outputTable = "table"
+	outputJSON  = "json"
+)
+
+// options configures the client. Flags take precedence over environment variables.
+type options struct {
+	Server    string        `env:"SHORTENER_SERVER"`       // Base URL of the HTTP API
+	GRPC      string        `env:"SHORTENER_GRPC_ADDRESS"` // Address of the gRPC API
+	Transport string        `env:"SHORTENER_TRANSPORT"`    // http or grpc
+	Output    string        `env:"SHORTENER_OUTPUT"`       // table or json
+	APIKey    string        `env:"SHORTENER_API_KEY"`      // API key used instead of the stored token
+	Workspace string        `env:"SHORTENER_WORKSPACE"`    // ID of the workspace whose links commands act on
+	TokenFile string        `env:"SHORTENER_TOKEN_FILE"`   // File storing the authentication token
+	Timeout   time.Duration `env:"SHORTENER_TIMEOUT"`      // Timeout of every call
+}
+
+// defaultOptions returns the options used unless set by environment variables or flags.
+// The token is stored in the user configuration directory, or the working directory if there is none.
+func defaultOptions() options {
+	tokenFile := "shortener-token"
+	if dir, err := os.UserConfigDir(); err == nil {
+		tokenFile = filepath.Join(dir, "shortener", "token")
+	}
+	return options{
+		Server:    "http://localhost:8080",
+		GRPC:      "localhost:3200",
+		Transport: transportHTTP,
+		Output:    outputTable,
+		TokenFile: tokenFile,
+		Timeout:   10 * time.Second,
+	}
+}
+
+// parseOptions parses the global flags of args on top of the environment and the defaults.
+// It returns the options and the remaining arguments, which start with the command.
+func parseOptions(args []string, stderr io.Writer) (options, []string, error) {
+	opts := defaultOptions()
+	if err := env.Parse(&opts); err != nil {
+		return opts, nil, fmt.Errorf("failed to parse env: %w", err)
+	}
+
+	set := flag.NewFlagSet("client", flag.ContinueOnError)
+	set.SetOutput(stderr)
+	set.StringVar(&opts.Server, "server", opts.Server, "Base URL of the HTTP API (SHORTENER_SERVER)")
+	set.StringVar(&opts.GRPC, "grpc", opts.GRPC, "Address of the gRPC API (SHORTENER_GRPC_ADDRESS)")
+	set.StringVar(&opts.Transport, "transport", opts.Transport, "Transport: http or grpc (SHORTENER_TRANSPORT)")
+	set.StringVar(&opts.Output, "o", opts.Output, "Output format: table or json (SHORTENER_OUTPUT)")
+	set.StringVar(&opts.APIKey, "api-key", opts.APIKey, "API key used instead of the stored token (SHORTENER_API_KEY)")
+	set.StringVar(&opts.Workspace, "workspace", opts.Workspace, "ID of the workspace whose links commands act on (SHORTENER_WORKSPACE)")
+	set.StringVar(&opts.TokenFile, "token-file", opts.TokenFile, "File storing the authentication token (SHORTENER_TOKEN_FILE)")
+	set.DurationVar(&opts.Timeout, "timeout", opts.Timeout, "Timeout of every call (SHORTENER_TIMEOUT)")
+	set.Usage = func() {
+		_, _ = fmt.Fprint(stderr, usage)
+		set.PrintDefaults()
+	}
+	if err := set.Parse(args); err != nil {
+		return opts, nil, err
+	}
+
+	if err := opts.validate(); err != nil {
+		return opts, nil, err
+	}
+	if set.NArg() == 0 {
+		set.Usage()
+		return opts, nil, errors.New("no command given")
+	}
+	return opts, set.Args(), nil
+}
+
+const usage = `Usage: client [flags] <command> [command flags] [arguments]
+
+Commands:
+  shorten  shorten a URL
+  batch    shorten URLs given as arguments or read one per line from a file or standard input
+  list     list your links
+  delete   delete links by their IDs
+  expand   show where a short link leads
+  stats    show statistics of the service
+
+Flags:
+`
+
+func (o options) validate() error {
+	if o.Transport != transportHTTP && o.Transport != transportGRPC {
+		return fmt.Errorf("unsupported transport %q, want %s or %s", o.Transport, transportHTTP, transportGRPC)
+	}
+	if o.Output != outputTable && o.Output != outputJSON {
+		return fmt.Errorf("unsupported output format %q, want %s or %s", o.Output, outputTable, outputJSON)
+	}
+	if o.Timeout <= 0 {
+		return errors.New("timeout must be positive")
+	}
+	return nil
+}
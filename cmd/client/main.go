@@ -1,60 +1,41 @@
+// Command client is a command-line client of the URL shortener.
+//
+// Usage:
+//
+//	client [flags] <command> [command flags] [arguments]
+//
+// Commands:
+//
+//	shorten  shorten a URL
+//	batch    shorten URLs given as arguments or read one per line from a file or standard input
+//	list     list your links
+//	delete   delete links by their IDs
+//	expand   show where a short link leads
+//	stats    show statistics of the service (HTTP requires a trusted subnet)
+//
+// The client talks to the server over HTTP or gRPC. It remembers the authentication token the server issues
+// in a file, so later calls act on behalf of the same user, unless an API key is given.
+// Every flag can also be set with an environment variable, see the output of client -h.
 package main
 
 import (
-	"bufio"
-	"fmt"
-	"io"
-	"net/http"
-	"net/url"
+	"context"
+	"errors"
+	"flag"
+	"log"
 	"os"
-	"strings"
+	"os/signal"
+	"syscall"
 )
 
 func main() {
-	endpoint := "http://localhost:8080/"
-	// контейнер данных для запроса
-	data := url.Values{}
-	// приглашение в консоли
-	fmt.Println("Введите длинный URL")
-	// открываем потоковое чтение из консоли
-	reader := bufio.NewReader(os.Stdin)
-	// читаем строку из консоли
-	long, err := reader.ReadString('\n')
-	if err != nil {
-		panic(err)
-	}
-	long = strings.TrimSuffix(long, "\n")
-	// заполняем контейнер данными
-	data.Set("url", long)
-	// добавляем HTTP-клиент
-	client := &http.Client{}
-	// пишем запрос
-	// запрос методом POST должен, помимо заголовков, содержать тело
-	// тело должно быть источником потокового чтения io.Reader
-	request, err := http.NewRequest(http.MethodPost, endpoint, strings.NewReader(data.Encode()))
-	if err != nil {
-		panic(err)
-	}
-	// в заголовках запроса указываем кодировку
-	request.Header.Add("Content-Type", "text/plain")
-	// отправляем запрос и получаем ответ
-	response, err := client.Do(request)
-	if err != nil {
-		panic(err)
-	}
-	// выводим код ответа
-	fmt.Println("Статус-код ", response.Status)
-	defer func() {
-		err = response.Body.Close()
-		if err != nil {
-			panic(err)
-		}
-	}()
-	// читаем поток из тела ответа
-	body, err := io.ReadAll(response.Body)
-	if err != nil {
-		panic(err)
+	log.SetFlags(0)
+	log.SetPrefix("client: ")
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	err := run(ctx, os.Args[1:], os.Stdin, os.Stdout, os.Stderr)
+	stop()
+	if err != nil && !errors.Is(err, flag.ErrHelp) {
+		log.Fatal(err)
 	}
-	// и печатаем его
-	fmt.Println(string(body))
 }
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/grnsv/shortener/internal/api"
+	"github.com/grnsv/shortener/internal/api/middleware"
+	"github.com/grnsv/shortener/internal/api/pb"
+	"github.com/grnsv/shortener/internal/config"
+	"github.com/grnsv/shortener/internal/logger"
+	"github.com/grnsv/shortener/internal/mocks"
+	"github.com/grnsv/shortener/internal/models"
+	"github.com/grnsv/shortener/internal/service"
+	"github.com/grnsv/shortener/pkg/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+var signer = middleware.NewHMACSigner("secret")
+
+// servers starts the HTTP and gRPC APIs over the mock and returns the flags pointing the client to them.
+func servers(t *testing.T, mock *mocks.MockShortener) []string {
+	t.Helper()
+	log, err := logger.New("testing")
+	require.NoError(t, err)
+
+	cfg := config.New()
+	ts := httptest.NewServer(api.NewRouter(api.NewURLHandler(mock, cfg, log), cfg, signer, log))
+	t.Cleanup(ts.Close)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	server := grpc.NewServer(grpc.UnaryInterceptor(middleware.GRPCAuthenticateInterceptor(signer, mock, log)))
+	pb.RegisterShortenerServer(server, pb.NewGRPCShortenerServer(mock, log))
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(server.Stop)
+
+	return []string{"-server", ts.URL, "-grpc", listener.Addr().String(), "-token-file", filepath.Join(t.TempDir(), "token")}
+}
+
+func runClient(t *testing.T, stdin string, args ...string) (string, error) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	err := run(context.Background(), args, strings.NewReader(stdin), &stdout, &stderr)
+	return stdout.String(), err
+}
+
+func TestTransports(t *testing.T) {
+	baseURL := config.New().BaseURL.String()
+
+	for _, transport := range []string{transportHTTP, transportGRPC} {
+		t.Run(transport, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			mock := mocks.NewMockShortener(ctrl)
+			flags := append(servers(t, mock), "-transport", transport, "-o", outputJSON)
+
+			var userID string
+			mock.EXPECT().ShortenURL(gomock.Any(), "http://example.com", gomock.Any(), gomock.Any()).
+				DoAndReturn(func(_ context.Context, _ string, id string, _ ...service.ShortenOption) (string, bool, error) {
+					userID = id
+					return baseURL + "/abc", false, nil
+				}).AnyTimes()
+			mock.EXPECT().Shorten(gomock.Any(), "http://example.com", gomock.Any(), gomock.Any()).
+				DoAndReturn(func(_ context.Context, url string, id string, _ ...service.ShortenOption) (*models.URL, bool, error) {
+					userID = id
+					return &models.URL{ShortURL: baseURL + "/abc", OriginalURL: url}, false, nil
+				}).AnyTimes()
+
+			out, err := runClient(t, "", append(flags, "shorten", "-tag", "work", "http://example.com")...)
+			require.NoError(t, err)
+			var link client.Link
+			require.NoError(t, json.Unmarshal([]byte(out), &link))
+			assert.Equal(t, "abc", link.ID)
+			assert.Equal(t, baseURL+"/abc", link.ShortURL)
+			require.NotEmpty(t, userID)
+
+			// The token issued on the first call authenticates the next ones as the same user.
+			mock.EXPECT().GetAll(gomock.Any(), userID, models.URLFilter{Tags: []string{"work"}}).
+				Return([]models.URL{{ShortURL: baseURL + "/abc", OriginalURL: "http://example.com"}}, nil)
+			out, err = runClient(t, "", append(flags, "list", "-tag", "work")...)
+			require.NoError(t, err)
+			var links []client.Link
+			require.NoError(t, json.Unmarshal([]byte(out), &links))
+			require.Len(t, links, 1)
+			assert.Equal(t, "abc", links[0].ID)
+
+			mock.EXPECT().ShortenBatch(gomock.Any(), models.BatchRequest{
+				{CorrelationID: "1", OriginalURL: "http://example.com/1"},
+				{CorrelationID: "2", OriginalURL: "http://example.com/2"},
+			}, userID, gomock.Not(""), gomock.Any()).Return(models.BatchResponse{
+				{CorrelationID: "1", ShortURL: baseURL + "/one", Status: models.BatchCreated},
+				{CorrelationID: "2", ShortURL: baseURL + "/two", Status: models.BatchExists},
+			}, nil)
+			out, err = runClient(t, "http://example.com/1\n\nhttp://example.com/2\n", append(flags, "batch", "-f", "-")...)
+			require.NoError(t, err)
+			var items []client.BatchResult
+			require.NoError(t, json.Unmarshal([]byte(out), &items))
+			require.Len(t, items, 2)
+			assert.Equal(t, "two", items[1].ID)
+
+			mock.EXPECT().ExpandURL(gomock.Any(), "abc").Return("http://example.com", http.StatusMovedPermanently, nil)
+			out, err = runClient(t, "", append(flags, "expand", "abc")...)
+			require.NoError(t, err)
+			var exp client.Expansion
+			require.NoError(t, json.Unmarshal([]byte(out), &exp))
+			assert.Equal(t, client.Expansion{URL: "http://example.com", RedirectCode: http.StatusMovedPermanently}, exp)
+		})
+	}
+}
+
+func TestErrors(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mock := mocks.NewMockShortener(ctrl)
+	flags := servers(t, mock)
+
+	_, err := runClient(t, "", append(flags, "stats")...)
+	var apiErr *client.Error
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusForbidden, apiErr.StatusCode)
+
+	_, err = runClient(t, "", append(flags, "shorten")...)
+	assert.ErrorContains(t, err, "exactly one URL")
+
+	_, err = runClient(t, "", append(flags, "rename")...)
+	assert.ErrorContains(t, err, "unknown command")
+}
+
+func TestTableOutput(t *testing.T) {
+	var buf bytes.Buffer
+	p := printer{w: &buf, format: outputTable}
+	require.NoError(t, p.Print(nil, []string{"ID", "URL"}, [][]string{{"abc", "http://example.com"}, {"de", orDash("")}}))
+	assert.Equal(t, "ID   URL\nabc  http://example.com\nde   -\n", buf.String())
+}
+
+func TestParseOptions(t *testing.T) {
+	t.Setenv("SHORTENER_TRANSPORT", transportGRPC)
+	t.Setenv("SHORTENER_OUTPUT", outputJSON)
+
+	opts, args, err := parseOptions([]string{"-o", outputTable, "list", "-tag", "a"}, os.Stderr)
+	require.NoError(t, err)
+	assert.Equal(t, transportGRPC, opts.Transport, "environment overrides defaults")
+	assert.Equal(t, outputTable, opts.Output, "flags override the environment")
+	assert.Equal(t, []string{"list", "-tag", "a"}, args)
+
+	_, _, err = parseOptions([]string{"-transport", "carrier-pigeon", "list"}, &bytes.Buffer{})
+	assert.ErrorContains(t, err, "unsupported transport")
+
+	_, _, err = parseOptions(nil, &bytes.Buffer{})
+	assert.ErrorContains(t, err, "no command")
+}
@@ -47,8 +47,9 @@ func BenchmarkApi(b *testing.B) {
 
 	shortener := service.NewShortener(storage, storage, storage, storage, cfg.BaseURL.String())
 	handler := NewURLHandler(shortener, cfg, log)
-	router := NewRouter(handler, cfg, log)
-	cookie, err := middleware.BuildAuthCookie(secret, userID)
+	signer := middleware.NewHMACSigner(secret)
+	router := NewRouter(handler, cfg, signer, log)
+	cookie, err := middleware.BuildAuthCookie(signer, userID)
 	handleError(b, err)
 
 	ts := httptest.NewServer(router)
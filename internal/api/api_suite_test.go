@@ -2,11 +2,16 @@ package api_test
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/golang/mock/gomock"
@@ -15,10 +20,15 @@ import (
 
 	"github.com/grnsv/shortener/internal/api"
 	"github.com/grnsv/shortener/internal/api/middleware"
+	"github.com/grnsv/shortener/internal/api/openapi"
+	"github.com/grnsv/shortener/internal/api/problem"
 	"github.com/grnsv/shortener/internal/config"
 	"github.com/grnsv/shortener/internal/logger"
 	"github.com/grnsv/shortener/internal/mocks"
 	"github.com/grnsv/shortener/internal/models"
+	"github.com/grnsv/shortener/internal/qr"
+	"github.com/grnsv/shortener/internal/service"
+	"github.com/grnsv/shortener/internal/storage"
 )
 
 func TestApi(t *testing.T) {
@@ -26,6 +36,8 @@ func TestApi(t *testing.T) {
 	RunSpecs(t, "Api Suite")
 }
 
+var signer = middleware.NewHMACSigner("secret")
+
 func must(fn func() error) {
 	handleError(fn())
 }
@@ -48,10 +60,10 @@ var _ = Describe("PingDB Handler", func() {
 	BeforeEach(func() {
 		ctrl = gomock.NewController(GinkgoT())
 		mockShortener = mocks.NewMockShortener(ctrl)
-		cfg = config.New()
+		cfg = config.New(config.WithValidateResponses(true))
 		log, _ = logger.New("testing")
 		handler = api.NewURLHandler(mockShortener, cfg, log)
-		router = api.NewRouter(handler, cfg, log)
+		router = api.NewRouter(handler, cfg, signer, log)
 		ts = httptest.NewServer(router)
 	})
 
@@ -99,10 +111,10 @@ var _ = Describe("Authenticate", func() {
 	BeforeEach(func() {
 		ctrl = gomock.NewController(GinkgoT())
 		mockShortener = mocks.NewMockShortener(ctrl)
-		cfg = config.New(config.WithJWTSecret("secret"))
+		cfg = config.New(config.WithValidateResponses(true), config.WithJWTSecret("secret"))
 		log, _ = logger.New("testing")
 		handler = api.NewURLHandler(mockShortener, cfg, log)
-		router = api.NewRouter(handler, cfg, log)
+		router = api.NewRouter(handler, cfg, signer, log)
 		ts = httptest.NewServer(router)
 	})
 
@@ -187,7 +199,7 @@ var _ = Describe("Authenticate", func() {
 		It("returns status 401 Unauthorized", func() {
 			req, err := http.NewRequest("GET", ts.URL+"/ping", nil)
 			handleError(err)
-			cookie, err := middleware.BuildAuthCookie("secret", "")
+			cookie, err := middleware.BuildAuthCookie(signer, "")
 			handleError(err)
 			req.AddCookie(cookie)
 			resp, err := http.DefaultClient.Do(req)
@@ -214,10 +226,10 @@ var _ = Describe("GetURLs", func() {
 	BeforeEach(func() {
 		ctrl = gomock.NewController(GinkgoT())
 		mockShortener = mocks.NewMockShortener(ctrl)
-		cfg = config.New(config.WithJWTSecret("secret"))
+		cfg = config.New(config.WithValidateResponses(true), config.WithJWTSecret("secret"))
 		log, _ = logger.New("testing")
 		handler = api.NewURLHandler(mockShortener, cfg, log)
-		router = api.NewRouter(handler, cfg, log)
+		router = api.NewRouter(handler, cfg, signer, log)
 		ts = httptest.NewServer(router)
 	})
 
@@ -228,11 +240,11 @@ var _ = Describe("GetURLs", func() {
 
 	Context("when storage does not have stored urls", func() {
 		It("returns status 204 StatusNoContent", func() {
-			mockShortener.EXPECT().GetAll(gomock.Any(), userID).Return(nil, nil)
+			mockShortener.EXPECT().GetAll(gomock.Any(), userID, models.URLFilter{}).Return(nil, nil)
 
 			req, err := http.NewRequest("GET", ts.URL+"/api/user/urls", nil)
 			handleError(err)
-			cookie, err := middleware.BuildAuthCookie("secret", userID)
+			cookie, err := middleware.BuildAuthCookie(signer, userID)
 			handleError(err)
 			req.AddCookie(cookie)
 			resp, err := http.DefaultClient.Do(req)
@@ -257,11 +269,11 @@ var _ = Describe("GetURLs", func() {
 					OriginalURL: "http://example.com/2",
 				},
 			}
-			mockShortener.EXPECT().GetAll(gomock.Any(), userID).Return(urls, nil)
+			mockShortener.EXPECT().GetAll(gomock.Any(), userID, models.URLFilter{}).Return(urls, nil)
 
 			req, err := http.NewRequest("GET", ts.URL+"/api/user/urls", nil)
 			handleError(err)
-			cookie, err := middleware.BuildAuthCookie("secret", userID)
+			cookie, err := middleware.BuildAuthCookie(signer, userID)
 			handleError(err)
 			req.AddCookie(cookie)
 			resp, err := http.DefaultClient.Do(req)
@@ -277,6 +289,134 @@ var _ = Describe("GetURLs", func() {
 			Expect(len(urls)).To(Equal(len(responseURLs)))
 		})
 	})
+
+	Context("when filtering by tags", func() {
+		It("passes the tags to the service and rejects invalid ones", func() {
+			mockShortener.EXPECT().GetAll(gomock.Any(), userID, models.URLFilter{Tags: []string{"work", "docs"}}).Return([]models.URL{
+				{ShortURL: "00000001", OriginalURL: "http://example.com/1", URLMetadata: models.URLMetadata{Tags: models.Tags{"docs", "work"}}},
+			}, nil)
+			mockShortener.EXPECT().GetAll(gomock.Any(), userID, models.URLFilter{Tags: []string{"a,b"}}).Return(nil, service.ErrInvalidMetadata)
+
+			cookie, err := middleware.BuildAuthCookie(signer, userID)
+			handleError(err)
+
+			req, err := http.NewRequest("GET", ts.URL+"/api/user/urls?tag=work&tag=docs", nil)
+			handleError(err)
+			req.AddCookie(cookie)
+			resp, err := http.DefaultClient.Do(req)
+			handleError(err)
+			defer must(resp.Body.Close)
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			var responseURLs []models.URL
+			handleError(json.NewDecoder(resp.Body).Decode(&responseURLs))
+			Expect(responseURLs).To(HaveLen(1))
+			Expect(responseURLs[0].Tags).To(Equal(models.Tags{"docs", "work"}))
+
+			req, err = http.NewRequest("GET", ts.URL+"/api/user/urls?tag=a,b", nil)
+			handleError(err)
+			req.AddCookie(cookie)
+			invalid, err := http.DefaultClient.Do(req)
+			handleError(err)
+			defer must(invalid.Body.Close)
+			Expect(invalid.StatusCode).To(Equal(http.StatusBadRequest))
+		})
+	})
+
+	Context("when searching", func() {
+		It("returns the matches, 204 without any and 400 for invalid queries", func() {
+			mockShortener.EXPECT().SearchURLs(gomock.Any(), userID, "q3 report", 5).Return([]models.URL{
+				{ShortURL: "http://localhost:8080/00000001", OriginalURL: "http://example.com/q3-report"},
+			}, nil)
+			mockShortener.EXPECT().SearchURLs(gomock.Any(), userID, "nothing", 0).Return(nil, nil)
+
+			cookie, err := middleware.BuildAuthCookie(signer, userID)
+			handleError(err)
+			search := func(query string) *http.Response {
+				req, reqErr := http.NewRequest("GET", ts.URL+"/api/user/urls/search?"+query, nil)
+				handleError(reqErr)
+				req.AddCookie(cookie)
+				resp, doErr := http.DefaultClient.Do(req)
+				handleError(doErr)
+				DeferCleanup(resp.Body.Close)
+				return resp
+			}
+
+			resp := search("q=q3+report&limit=5")
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			var responseURLs []models.URL
+			handleError(json.NewDecoder(resp.Body).Decode(&responseURLs))
+			Expect(responseURLs).To(HaveLen(1))
+			Expect(responseURLs[0].OriginalURL).To(Equal("http://example.com/q3-report"))
+
+			Expect(search("q=nothing").StatusCode).To(Equal(http.StatusNoContent))
+			Expect(search("q=").StatusCode).To(Equal(http.StatusBadRequest))
+			Expect(search("").StatusCode).To(Equal(http.StatusBadRequest))
+			Expect(search("q=report&limit=many").StatusCode).To(Equal(http.StatusBadRequest))
+		})
+	})
+
+	Context("when importing and exporting", func() {
+		It("reads the file in the requested format and reports every record", func() {
+			mockShortener.EXPECT().ImportURLs(gomock.Any(), userID, gomock.Any()).DoAndReturn(
+				func(_ context.Context, _ string, records service.LinkSeq) (*models.ImportReport, error) {
+					report := &models.ImportReport{}
+					for record, err := range records {
+						Expect(err).To(BeNil())
+						Expect(record.OriginalURL).To(Equal("http://example.com/1"))
+						Expect(record.Alias).To(Equal("one"))
+						report.Add(models.ImportResult{Row: record.Row, Status: models.ImportCreated})
+					}
+					return report, nil
+				})
+
+			cookie, err := middleware.BuildAuthCookie(signer, userID)
+			handleError(err)
+			req, err := http.NewRequest("POST", ts.URL+"/api/user/urls/import", strings.NewReader("original_url,alias\nhttp://example.com/1,one\n"))
+			handleError(err)
+			req.Header.Set("Content-Type", "text/csv")
+			req.AddCookie(cookie)
+			resp, err := http.DefaultClient.Do(req)
+			handleError(err)
+			defer must(resp.Body.Close)
+
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			var report models.ImportReport
+			handleError(json.NewDecoder(resp.Body).Decode(&report))
+			Expect(report.Created).To(Equal(1))
+			Expect(report.Results).To(HaveLen(1))
+
+			req, err = http.NewRequest("POST", ts.URL+"/api/user/urls/import", strings.NewReader("{}"))
+			handleError(err)
+			req.Header.Set("Content-Type", "application/json")
+			req.AddCookie(cookie)
+			unsupported, err := http.DefaultClient.Do(req)
+			handleError(err)
+			defer must(unsupported.Body.Close)
+			Expect(unsupported.StatusCode).To(Equal(http.StatusBadRequest))
+		})
+
+		It("streams the links as an attachment", func() {
+			mockShortener.EXPECT().ExportURLs(gomock.Any(), userID).Return(service.LinkSeq(func(yield func(models.LinkRecord, error) bool) {
+				yield(models.LinkRecord{OriginalURL: "http://example.com/1", Alias: "one", ShortURL: "http://localhost/one"}, nil)
+			}))
+
+			cookie, err := middleware.BuildAuthCookie(signer, userID)
+			handleError(err)
+			req, err := http.NewRequest("GET", ts.URL+"/api/user/urls/export?format=jsonl", nil)
+			handleError(err)
+			req.AddCookie(cookie)
+			resp, err := http.DefaultClient.Do(req)
+			handleError(err)
+			defer must(resp.Body.Close)
+
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			Expect(resp.Header.Get("Content-Type")).To(Equal("application/x-ndjson"))
+			Expect(resp.Header.Get("Content-Disposition")).To(ContainSubstring("links.jsonl"))
+			body, err := io.ReadAll(resp.Body)
+			handleError(err)
+			Expect(string(body)).To(Equal(`{"original_url":"http://example.com/1","alias":"one","short_url":"http://localhost/one"}` + "\n"))
+		})
+	})
 })
 
 var _ = Describe("ShortenBatch", func() {
@@ -294,10 +434,10 @@ var _ = Describe("ShortenBatch", func() {
 	BeforeEach(func() {
 		ctrl = gomock.NewController(GinkgoT())
 		mockShortener = mocks.NewMockShortener(ctrl)
-		cfg = config.New(config.WithJWTSecret("secret"))
+		cfg = config.New(config.WithValidateResponses(true), config.WithJWTSecret("secret"))
 		log, _ = logger.New("testing")
 		handler = api.NewURLHandler(mockShortener, cfg, log)
-		router = api.NewRouter(handler, cfg, log)
+		router = api.NewRouter(handler, cfg, signer, log)
 		ts = httptest.NewServer(router)
 	})
 
@@ -313,16 +453,16 @@ var _ = Describe("ShortenBatch", func() {
 				{CorrelationID: "2", OriginalURL: "http://example.com/2"},
 			}
 			batchResp := models.BatchResponse{
-				{CorrelationID: "1", ShortURL: "http://localhost:8080/short1"},
-				{CorrelationID: "2", ShortURL: "http://localhost:8080/short2"},
+				{CorrelationID: "1", ShortURL: "http://localhost:8080/short1", Status: models.BatchCreated},
+				{CorrelationID: "2", ShortURL: "http://localhost:8080/short2", Status: models.BatchExists},
 			}
-			mockShortener.EXPECT().ShortenBatch(gomock.Any(), batchReq, userID).Return(batchResp, nil)
+			mockShortener.EXPECT().ShortenBatch(gomock.Any(), batchReq, userID, "").Return(batchResp, nil)
 
 			body, err := json.Marshal(batchReq)
 			handleError(err)
 			req, err := http.NewRequest("POST", ts.URL+"/api/shorten/batch", bytes.NewReader(body))
 			handleError(err)
-			cookie, err := middleware.BuildAuthCookie("secret", userID)
+			cookie, err := middleware.BuildAuthCookie(signer, userID)
 			handleError(err)
 			req.AddCookie(cookie)
 			req.Header.Set("Content-Type", "application/json")
@@ -338,12 +478,57 @@ var _ = Describe("ShortenBatch", func() {
 		})
 	})
 
+	Context("when no link is created", func() {
+		It("returns status 200 OK and passes the idempotency key", func() {
+			batchReq := models.BatchRequest{{CorrelationID: "1", OriginalURL: "not a url"}}
+			batchResp := models.BatchResponse{{CorrelationID: "1", Status: models.BatchInvalid, Error: "invalid URL"}}
+			mockShortener.EXPECT().ShortenBatch(gomock.Any(), batchReq, userID, "retry-1").Return(batchResp, nil)
+
+			body, err := json.Marshal(batchReq)
+			handleError(err)
+			req, err := http.NewRequest("POST", ts.URL+"/api/shorten/batch", bytes.NewReader(body))
+			handleError(err)
+			cookie, err := middleware.BuildAuthCookie(signer, userID)
+			handleError(err)
+			req.AddCookie(cookie)
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Idempotency-Key", "retry-1")
+			resp, err := http.DefaultClient.Do(req)
+			handleError(err)
+			defer must(resp.Body.Close)
+
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			var got models.BatchResponse
+			handleError(json.NewDecoder(resp.Body).Decode(&got))
+			Expect(got).To(Equal(batchResp))
+		})
+	})
+
+	Context("when the idempotency key was used for another batch", func() {
+		It("returns status 422 Unprocessable Entity", func() {
+			mockShortener.EXPECT().ShortenBatch(gomock.Any(), gomock.Any(), userID, "retry-1").Return(nil, service.ErrIdempotencyKeyReused)
+
+			req, err := http.NewRequest("POST", ts.URL+"/api/shorten/batch", strings.NewReader(`[{"correlation_id":"1","original_url":"http://example.com"}]`))
+			handleError(err)
+			cookie, err := middleware.BuildAuthCookie(signer, userID)
+			handleError(err)
+			req.AddCookie(cookie)
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Idempotency-Key", "retry-1")
+			resp, err := http.DefaultClient.Do(req)
+			handleError(err)
+			defer must(resp.Body.Close)
+
+			Expect(resp.StatusCode).To(Equal(http.StatusUnprocessableEntity))
+		})
+	})
+
 	Context("when batch request is invalid", func() {
 		It("returns status 400 BadRequest", func() {
 			body := []byte(`invalid json`)
 			req, err := http.NewRequest("POST", ts.URL+"/api/shorten/batch", bytes.NewReader(body))
 			handleError(err)
-			cookie, err := middleware.BuildAuthCookie("secret", userID)
+			cookie, err := middleware.BuildAuthCookie(signer, userID)
 			handleError(err)
 			req.AddCookie(cookie)
 			req.Header.Set("Content-Type", "application/json")
@@ -354,6 +539,45 @@ var _ = Describe("ShortenBatch", func() {
 			Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
 		})
 	})
+
+	Context("when the batch is too long", func() {
+		It("returns status 413 Payload Too Large", func() {
+			batchReq := make(models.BatchRequest, config.DefaultMaxBatchSize+1)
+			for i := range batchReq {
+				batchReq[i] = models.BatchRequestItem{CorrelationID: strconv.Itoa(i), OriginalURL: "http://example.com"}
+			}
+			body, err := json.Marshal(batchReq)
+			handleError(err)
+			req, err := http.NewRequest("POST", ts.URL+"/api/shorten/batch", bytes.NewReader(body))
+			handleError(err)
+			cookie, err := middleware.BuildAuthCookie(signer, userID)
+			handleError(err)
+			req.AddCookie(cookie)
+			req.Header.Set("Content-Type", "application/json")
+			resp, err := http.DefaultClient.Do(req)
+			handleError(err)
+			defer must(resp.Body.Close)
+
+			Expect(resp.StatusCode).To(Equal(http.StatusRequestEntityTooLarge))
+		})
+	})
+
+	Context("when the body is too large", func() {
+		It("returns status 413 Payload Too Large", func() {
+			body := `[{"correlation_id":"1","original_url":"http://example.com/` + strings.Repeat("a", config.DefaultMaxBodySize) + `"}]`
+			req, err := http.NewRequest("POST", ts.URL+"/api/shorten/batch", strings.NewReader(body))
+			handleError(err)
+			cookie, err := middleware.BuildAuthCookie(signer, userID)
+			handleError(err)
+			req.AddCookie(cookie)
+			req.Header.Set("Content-Type", "application/json")
+			resp, err := http.DefaultClient.Do(req)
+			handleError(err)
+			defer must(resp.Body.Close)
+
+			Expect(resp.StatusCode).To(Equal(http.StatusRequestEntityTooLarge))
+		})
+	})
 })
 
 var _ = Describe("DeleteURLs", func() {
@@ -371,10 +595,10 @@ var _ = Describe("DeleteURLs", func() {
 	BeforeEach(func() {
 		ctrl = gomock.NewController(GinkgoT())
 		mockShortener = mocks.NewMockShortener(ctrl)
-		cfg = config.New(config.WithJWTSecret("secret"))
+		cfg = config.New(config.WithValidateResponses(true), config.WithJWTSecret("secret"))
 		log, _ = logger.New("testing")
 		handler = api.NewURLHandler(mockShortener, cfg, log)
-		router = api.NewRouter(handler, cfg, log)
+		router = api.NewRouter(handler, cfg, signer, log)
 		ts = httptest.NewServer(router)
 	})
 
@@ -386,12 +610,13 @@ var _ = Describe("DeleteURLs", func() {
 	Context("when delete request is valid", func() {
 		It("returns status 202 Accepted", func() {
 			shortURLs := []string{"short1", "short2"}
+			mockShortener.EXPECT().LinkOwner(gomock.Any(), userID, models.ScopeDelete).Return(userID, nil)
 			mockShortener.EXPECT().DeleteMany(gomock.Any(), userID, shortURLs).Return(nil)
 
 			body, _ := json.Marshal(shortURLs)
 			req, err := http.NewRequest("DELETE", ts.URL+"/api/user/urls", bytes.NewReader(body))
 			handleError(err)
-			cookie, err := middleware.BuildAuthCookie("secret", userID)
+			cookie, err := middleware.BuildAuthCookie(signer, userID)
 			handleError(err)
 			req.AddCookie(cookie)
 			req.Header.Set("Content-Type", "application/json")
@@ -408,7 +633,7 @@ var _ = Describe("DeleteURLs", func() {
 			body := []byte(`invalid json`)
 			req, err := http.NewRequest("DELETE", ts.URL+"/api/user/urls", bytes.NewReader(body))
 			handleError(err)
-			cookie, err := middleware.BuildAuthCookie("secret", userID)
+			cookie, err := middleware.BuildAuthCookie(signer, userID)
 			handleError(err)
 			req.AddCookie(cookie)
 			req.Header.Set("Content-Type", "application/json")
@@ -420,3 +645,876 @@ var _ = Describe("DeleteURLs", func() {
 		})
 	})
 })
+
+var _ = Describe("APIKeys", func() {
+	const userID = "ffffffff-ffff-ffff-ffff-ffffffffffff"
+	var (
+		ctrl          *gomock.Controller
+		mockShortener *mocks.MockShortener
+		cfg           *config.Config
+		log           logger.Logger
+		handler       *api.URLHandler
+		router        chi.Router
+		ts            *httptest.Server
+		cookie        *http.Cookie
+	)
+
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		mockShortener = mocks.NewMockShortener(ctrl)
+		cfg = config.New(config.WithValidateResponses(true), config.WithJWTSecret("secret"))
+		log, _ = logger.New("testing")
+		handler = api.NewURLHandler(mockShortener, cfg, log)
+		router = api.NewRouter(handler, cfg, signer, log)
+		ts = httptest.NewServer(router)
+		var err error
+		cookie, err = middleware.BuildAuthCookie(signer, userID)
+		handleError(err)
+	})
+
+	AfterEach(func() {
+		ts.Close()
+		ctrl.Finish()
+	})
+
+	Context("when creating a key", func() {
+		It("returns status 201 Created and the key", func() {
+			mockShortener.EXPECT().CreateAPIKey(gomock.Any(), userID, "ci", []string{models.ScopeShorten}).
+				Return(&models.CreateAPIKeyResponse{APIKey: models.APIKey{ID: "id", Name: "ci"}, Key: "sk_new"}, nil)
+
+			req, err := http.NewRequest("POST", ts.URL+"/api/user/keys", bytes.NewBufferString(`{"name":"ci","scopes":["shorten"]}`))
+			handleError(err)
+			req.AddCookie(cookie)
+			resp, err := http.DefaultClient.Do(req)
+			handleError(err)
+			defer must(resp.Body.Close)
+
+			Expect(resp.StatusCode).To(Equal(http.StatusCreated))
+			var got models.CreateAPIKeyResponse
+			handleError(json.NewDecoder(resp.Body).Decode(&got))
+			Expect(got.Key).To(Equal("sk_new"))
+		})
+	})
+
+	Context("when revoking an unknown key", func() {
+		It("returns status 404 Not Found", func() {
+			mockShortener.EXPECT().RevokeAPIKey(gomock.Any(), userID, "missing").Return(storage.ErrNotFound)
+
+			req, err := http.NewRequest("DELETE", ts.URL+"/api/user/keys/missing", nil)
+			handleError(err)
+			req.AddCookie(cookie)
+			resp, err := http.DefaultClient.Do(req)
+			handleError(err)
+			defer must(resp.Body.Close)
+
+			Expect(resp.StatusCode).To(Equal(http.StatusNotFound))
+		})
+	})
+
+	Context("when a key without the keys scope manages keys", func() {
+		It("returns status 403 Forbidden", func() {
+			mockShortener.EXPECT().VerifyAPIKey(gomock.Any(), "sk_read").
+				Return(&models.APIKey{UserID: userID, Scopes: models.Scopes{models.ScopeRead}}, nil)
+
+			req, err := http.NewRequest("GET", ts.URL+"/api/user/keys", nil)
+			handleError(err)
+			req.Header.Set("Authorization", "Bearer sk_read")
+			resp, err := http.DefaultClient.Do(req)
+			handleError(err)
+			defer must(resp.Body.Close)
+
+			Expect(resp.StatusCode).To(Equal(http.StatusForbidden))
+		})
+	})
+})
+
+var _ = Describe("Webhooks", func() {
+	const userID = "ffffffff-ffff-ffff-ffff-ffffffffffff"
+	var (
+		ctrl          *gomock.Controller
+		mockShortener *mocks.MockShortener
+		ts            *httptest.Server
+		cookie        *http.Cookie
+	)
+
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		mockShortener = mocks.NewMockShortener(ctrl)
+		cfg := config.New(config.WithValidateResponses(true), config.WithJWTSecret("secret"))
+		log, _ := logger.New("testing")
+		ts = httptest.NewServer(api.NewRouter(api.NewURLHandler(mockShortener, cfg, log), cfg, signer, log))
+		var err error
+		cookie, err = middleware.BuildAuthCookie(signer, userID)
+		handleError(err)
+	})
+
+	AfterEach(func() {
+		ts.Close()
+		ctrl.Finish()
+	})
+
+	Context("when creating a webhook", func() {
+		It("returns status 201 Created and the webhook with its secret", func() {
+			mockShortener.EXPECT().CreateWebhook(gomock.Any(), userID, "https://crm.example.com/hook", []string{models.EventLinkCreated}).
+				Return(&models.Webhook{
+					ID:        "9b2f0c36-6a5e-4d6b-9a57-3f1c2d4e5f60",
+					URL:       "https://crm.example.com/hook",
+					Secret:    "whsec_new",
+					Events:    models.Events{models.EventLinkCreated},
+					CreatedAt: time.Now(),
+				}, nil)
+
+			req, err := http.NewRequest("POST", ts.URL+"/api/user/webhooks",
+				bytes.NewBufferString(`{"url":"https://crm.example.com/hook","events":["link.created"]}`))
+			handleError(err)
+			req.AddCookie(cookie)
+			resp, err := http.DefaultClient.Do(req)
+			handleError(err)
+			defer must(resp.Body.Close)
+
+			Expect(resp.StatusCode).To(Equal(http.StatusCreated))
+			var got models.Webhook
+			handleError(json.NewDecoder(resp.Body).Decode(&got))
+			Expect(got.Secret).To(Equal("whsec_new"))
+		})
+	})
+
+	Context("when subscribing to an unknown event", func() {
+		It("returns status 400 Bad Request", func() {
+			mockShortener.EXPECT().CreateWebhook(gomock.Any(), userID, "https://crm.example.com/hook", []string{"link.renamed"}).
+				Return(nil, service.ErrInvalidEvent)
+
+			req, err := http.NewRequest("POST", ts.URL+"/api/user/webhooks",
+				bytes.NewBufferString(`{"url":"https://crm.example.com/hook","events":["link.renamed"]}`))
+			handleError(err)
+			req.AddCookie(cookie)
+			resp, err := http.DefaultClient.Do(req)
+			handleError(err)
+			defer must(resp.Body.Close)
+
+			Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+		})
+	})
+
+	Context("when getting the deliveries of a webhook without any", func() {
+		It("returns status 200 OK and an empty array", func() {
+			mockShortener.EXPECT().GetWebhookDeliveries(gomock.Any(), userID, "id").Return(nil, nil)
+
+			req, err := http.NewRequest("GET", ts.URL+"/api/user/webhooks/id/deliveries", nil)
+			handleError(err)
+			req.AddCookie(cookie)
+			resp, err := http.DefaultClient.Do(req)
+			handleError(err)
+			defer must(resp.Body.Close)
+
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			body, err := io.ReadAll(resp.Body)
+			handleError(err)
+			Expect(body).To(MatchJSON(`[]`))
+		})
+	})
+
+	Context("when a key without the webhooks scope manages webhooks", func() {
+		It("returns status 403 Forbidden", func() {
+			mockShortener.EXPECT().VerifyAPIKey(gomock.Any(), "sk_read").
+				Return(&models.APIKey{UserID: userID, Scopes: models.Scopes{models.ScopeRead}}, nil)
+
+			req, err := http.NewRequest("GET", ts.URL+"/api/user/webhooks", nil)
+			handleError(err)
+			req.Header.Set("Authorization", "Bearer sk_read")
+			resp, err := http.DefaultClient.Do(req)
+			handleError(err)
+			defer must(resp.Body.Close)
+
+			Expect(resp.StatusCode).To(Equal(http.StatusForbidden))
+		})
+	})
+})
+
+var _ = Describe("UpdateURL", func() {
+	const userID = "ffffffff-ffff-ffff-ffff-ffffffffffff"
+	var (
+		ctrl          *gomock.Controller
+		mockShortener *mocks.MockShortener
+		cfg           *config.Config
+		log           logger.Logger
+		handler       *api.URLHandler
+		router        chi.Router
+		ts            *httptest.Server
+		cookie        *http.Cookie
+	)
+
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		mockShortener = mocks.NewMockShortener(ctrl)
+		cfg = config.New(config.WithValidateResponses(true), config.WithJWTSecret("secret"))
+		log, _ = logger.New("testing")
+		handler = api.NewURLHandler(mockShortener, cfg, log)
+		router = api.NewRouter(handler, cfg, signer, log)
+		ts = httptest.NewServer(router)
+		var err error
+		cookie, err = middleware.BuildAuthCookie(signer, userID)
+		handleError(err)
+	})
+
+	AfterEach(func() {
+		ts.Close()
+		ctrl.Finish()
+	})
+
+	patch := func(id string, body string) *http.Response {
+		req, err := http.NewRequest(http.MethodPatch, ts.URL+"/api/user/urls/"+id, bytes.NewBufferString(body))
+		handleError(err)
+		req.AddCookie(cookie)
+		resp, err := http.DefaultClient.Do(req)
+		handleError(err)
+		return resp
+	}
+
+	Context("when the user owns the URL", func() {
+		It("returns status 200 OK and the updated URL", func() {
+			mockShortener.EXPECT().UpdateURL(gomock.Any(), userID, "short1", models.UpdateURLRequest{URL: "http://example.com/fixed"}).
+				Return(&models.URL{ShortURL: "http://localhost/short1", OriginalURL: "http://example.com/fixed"}, nil)
+
+			resp := patch("short1", `{"url":"http://example.com/fixed"}`)
+			defer must(resp.Body.Close)
+
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			var got models.URL
+			handleError(json.NewDecoder(resp.Body).Decode(&got))
+			Expect(got.OriginalURL).To(Equal("http://example.com/fixed"))
+		})
+	})
+
+	Context("when the URL is invalid", func() {
+		It("returns status 400 Bad Request", func() {
+			mockShortener.EXPECT().UpdateURL(gomock.Any(), userID, "short1", models.UpdateURLRequest{URL: "not a url"}).Return(nil, service.ErrInvalidURL)
+
+			resp := patch("short1", `{"url":"not a url"}`)
+			defer must(resp.Body.Close)
+
+			Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+		})
+	})
+
+	Context("when the user does not own the URL", func() {
+		It("returns status 404 Not Found", func() {
+			mockShortener.EXPECT().UpdateURL(gomock.Any(), userID, "foreign", gomock.Any()).Return(nil, storage.ErrNotFound)
+
+			resp := patch("foreign", `{"url":"http://example.com/"}`)
+			defer must(resp.Body.Close)
+
+			Expect(resp.StatusCode).To(Equal(http.StatusNotFound))
+		})
+	})
+
+	Context("when restoring a deleted URL", func() {
+		It("returns status 204 No Content", func() {
+			mockShortener.EXPECT().RestoreURL(gomock.Any(), userID, "short1").Return(nil)
+
+			req, err := http.NewRequest(http.MethodPost, ts.URL+"/api/user/urls/short1/restore", nil)
+			handleError(err)
+			req.AddCookie(cookie)
+			resp, err := http.DefaultClient.Do(req)
+			handleError(err)
+			defer must(resp.Body.Close)
+
+			Expect(resp.StatusCode).To(Equal(http.StatusNoContent))
+		})
+	})
+
+	Context("when the retention window has passed", func() {
+		It("returns status 404 Not Found", func() {
+			mockShortener.EXPECT().RestoreURL(gomock.Any(), userID, "short1").Return(storage.ErrNotFound)
+
+			req, err := http.NewRequest(http.MethodPost, ts.URL+"/api/user/urls/short1/restore", nil)
+			handleError(err)
+			req.AddCookie(cookie)
+			resp, err := http.DefaultClient.Do(req)
+			handleError(err)
+			defer must(resp.Body.Close)
+
+			Expect(resp.StatusCode).To(Equal(http.StatusNotFound))
+		})
+	})
+
+	Context("when listing the history", func() {
+		It("returns status 200 OK and previous destinations", func() {
+			mockShortener.EXPECT().GetURLHistory(gomock.Any(), userID, "short1").
+				Return([]models.URLHistory{{ShortURL: "http://localhost/short1", OriginalURL: "http://example.com/typo"}}, nil)
+
+			req, err := http.NewRequest(http.MethodGet, ts.URL+"/api/user/urls/short1/history", nil)
+			handleError(err)
+			req.AddCookie(cookie)
+			resp, err := http.DefaultClient.Do(req)
+			handleError(err)
+			defer must(resp.Body.Close)
+
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			var got []models.URLHistory
+			handleError(json.NewDecoder(resp.Body).Decode(&got))
+			Expect(got).To(HaveLen(1))
+		})
+	})
+})
+
+var _ = Describe("GetQRCode", func() {
+	var (
+		ctrl          *gomock.Controller
+		mockShortener *mocks.MockShortener
+		cfg           *config.Config
+		log           logger.Logger
+		handler       *api.URLHandler
+		router        chi.Router
+		ts            *httptest.Server
+	)
+
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		mockShortener = mocks.NewMockShortener(ctrl)
+		cfg = config.New(config.WithValidateResponses(true))
+		log, _ = logger.New("testing")
+		handler = api.NewURLHandler(mockShortener, cfg, log)
+		router = api.NewRouter(handler, cfg, signer, log)
+		ts = httptest.NewServer(router)
+	})
+
+	AfterEach(func() {
+		ts.Close()
+		ctrl.Finish()
+	})
+
+	Context("when options are given in the query", func() {
+		It("returns the rendered image with caching headers", func() {
+			mockShortener.EXPECT().GetQRCode(gomock.Any(), "short1", qr.Options{Format: "svg", Size: 512, Level: "H", Margin: 0}).
+				Return(&qr.Image{Data: []byte("<svg/>"), ContentType: "image/svg+xml"}, nil)
+
+			resp, err := http.Get(ts.URL + "/short1/qr?format=svg&size=512&level=H&margin=0")
+			handleError(err)
+			defer must(resp.Body.Close)
+
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			Expect(resp.Header.Get("Content-Type")).To(Equal("image/svg+xml"))
+			Expect(resp.Header.Get("Cache-Control")).To(ContainSubstring("max-age"))
+			body, err := io.ReadAll(resp.Body)
+			handleError(err)
+			Expect(string(body)).To(Equal("<svg/>"))
+		})
+	})
+
+	Context("when options are omitted", func() {
+		It("uses the default margin", func() {
+			mockShortener.EXPECT().GetQRCode(gomock.Any(), "short1", qr.Options{Margin: qr.DefaultMargin}).
+				Return(&qr.Image{Data: []byte("png"), ContentType: "image/png"}, nil)
+
+			resp, err := http.Get(ts.URL + "/short1/qr")
+			handleError(err)
+			defer must(resp.Body.Close)
+
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			Expect(resp.Header.Get("Content-Type")).To(Equal("image/png"))
+		})
+	})
+
+	Context("when options are invalid", func() {
+		It("returns status 400 Bad Request", func() {
+			resp, err := http.Get(ts.URL + "/short1/qr?size=big")
+			handleError(err)
+			defer must(resp.Body.Close)
+			Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+
+			mockShortener.EXPECT().GetQRCode(gomock.Any(), "short1", gomock.Any()).Return(nil, qr.ErrInvalidOptions)
+			resp2, err := http.Get(ts.URL + "/short1/qr?format=gif")
+			handleError(err)
+			defer must(resp2.Body.Close)
+			Expect(resp2.StatusCode).To(Equal(http.StatusBadRequest))
+		})
+	})
+
+	Context("when the URL does not exist", func() {
+		It("returns status 404 Not Found", func() {
+			mockShortener.EXPECT().GetQRCode(gomock.Any(), "missing", gomock.Any()).Return(nil, storage.ErrNotFound)
+
+			resp, err := http.Get(ts.URL + "/missing/qr")
+			handleError(err)
+			defer must(resp.Body.Close)
+
+			Expect(resp.StatusCode).To(Equal(http.StatusNotFound))
+		})
+	})
+})
+
+var _ = Describe("Problem details", func() {
+	const userID = "ffffffff-ffff-ffff-ffff-ffffffffffff"
+	var (
+		ctrl          *gomock.Controller
+		mockShortener *mocks.MockShortener
+		ts            *httptest.Server
+	)
+
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		mockShortener = mocks.NewMockShortener(ctrl)
+		cfg := config.New(config.WithValidateResponses(true), config.WithJWTSecret("secret"))
+		log, _ := logger.New("testing")
+		ts = httptest.NewServer(api.NewRouter(api.NewURLHandler(mockShortener, cfg, log), cfg, signer, log))
+	})
+
+	AfterEach(func() {
+		ts.Close()
+		ctrl.Finish()
+	})
+
+	do := func(method, path, body string) *problem.Problem {
+		req, err := http.NewRequest(method, ts.URL+path, strings.NewReader(body))
+		handleError(err)
+		cookie, err := middleware.BuildAuthCookie(signer, userID)
+		handleError(err)
+		req.AddCookie(cookie)
+		client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+		resp, err := client.Do(req)
+		handleError(err)
+		defer must(resp.Body.Close)
+
+		Expect(resp.Header.Get("Content-Type")).To(Equal(problem.ContentType))
+		var p problem.Problem
+		handleError(json.NewDecoder(resp.Body).Decode(&p))
+		Expect(p.Status).To(Equal(resp.StatusCode))
+		return &p
+	}
+
+	DescribeTable("maps failures to statuses and stable codes",
+		func(setup func(), method, path, body string, status int, code problem.Code) {
+			if setup != nil {
+				setup()
+			}
+			p := do(method, path, body)
+			Expect(p.Status).To(Equal(status))
+			Expect(p.Code).To(Equal(code))
+			Expect(p.Type).To(HaveSuffix(string(code)))
+			Expect(p.Title).To(Equal(http.StatusText(status)))
+			Expect(p.Instance).To(Equal(strings.SplitN(path, "?", 2)[0]))
+		},
+		Entry("unknown short URL", func() {
+			mockShortener.EXPECT().ExpandURL(gomock.Any(), "missing").Return("", 0, storage.ErrNotFound)
+		}, http.MethodGet, "/missing", "", http.StatusNotFound, problem.CodeNotFound),
+		Entry("deleted short URL", func() {
+			mockShortener.EXPECT().ExpandURL(gomock.Any(), "deleted").Return("", 0, storage.ErrDeleted)
+		}, http.MethodGet, "/deleted", "", http.StatusGone, problem.CodeGone),
+		Entry("unknown route", nil, http.MethodGet, "/api/unknown", "", http.StatusNotFound, problem.CodeNotFound),
+		Entry("method not allowed", nil, http.MethodPut, "/api/shorten", "", http.StatusBadRequest, problem.CodeMethodNotAllowed),
+		Entry("malformed JSON", nil, http.MethodPost, "/api/shorten", "{", http.StatusBadRequest, problem.CodeInvalidJSON),
+		Entry("empty URL", nil, http.MethodPost, "/api/shorten", `{"url":""}`, http.StatusBadRequest, problem.CodeEmptyURL),
+		Entry("invalid URL", func() {
+			mockShortener.EXPECT().ShortenURL(gomock.Any(), "ftp://example.com", userID, gomock.Any()).
+				Return("", false, service.ErrInvalidURL)
+		}, http.MethodPost, "/api/shorten", `{"url":"ftp://example.com"}`, http.StatusBadRequest, problem.CodeInvalidURL),
+		Entry("empty batch", nil, http.MethodPost, "/api/shorten/batch", "[]", http.StatusBadRequest, problem.CodeEmptyBatch),
+		Entry("reused idempotency key", func() {
+			mockShortener.EXPECT().ShortenBatch(gomock.Any(), gomock.Any(), userID, "").Return(nil, service.ErrIdempotencyKeyReused)
+		}, http.MethodPost, "/api/shorten/batch", `[{"correlation_id":"1","original_url":"http://example.com"}]`,
+			http.StatusUnprocessableEntity, problem.CodeIdempotencyKeyReused),
+		Entry("invalid redirect code", func() {
+			mockShortener.EXPECT().UpdateURL(gomock.Any(), userID, "short1", gomock.Any()).Return(nil, service.ErrInvalidRedirectCode)
+		}, http.MethodPatch, "/api/user/urls/short1", `{"redirect_code":300}`, http.StatusBadRequest, problem.CodeValidationFailed),
+		Entry("unknown link of the user", func() {
+			mockShortener.EXPECT().GetURLHistory(gomock.Any(), userID, "short1").Return(nil, storage.ErrNotFound)
+		}, http.MethodGet, "/api/user/urls/short1/history", "", http.StatusNotFound, problem.CodeNotFound),
+		Entry("invalid search limit", nil, http.MethodGet, "/api/user/urls/search?q=go&limit=many", "",
+			http.StatusBadRequest, problem.CodeValidationFailed),
+		Entry("storage failure", func() {
+			mockShortener.EXPECT().GetAll(gomock.Any(), userID, gomock.Any()).Return(nil, errors.New("connection refused"))
+		}, http.MethodGet, "/api/user/urls", "", http.StatusInternalServerError, problem.CodeInternal),
+	)
+
+	It("does not disclose internal errors", func() {
+		mockShortener.EXPECT().GetAll(gomock.Any(), userID, gomock.Any()).Return(nil, errors.New("connection refused"))
+
+		p := do(http.MethodGet, "/api/user/urls", "")
+		Expect(p.Detail).To(BeEmpty())
+	})
+})
+
+var _ = Describe("OpenAPI specification", func() {
+	var (
+		ctrl          *gomock.Controller
+		mockShortener *mocks.MockShortener
+		log           logger.Logger
+		router        chi.Router
+	)
+
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		mockShortener = mocks.NewMockShortener(ctrl)
+		cfg := config.New(config.WithValidateResponses(true), config.WithJWTSecret("secret"))
+		log, _ = logger.New("testing")
+		router = api.NewRouter(api.NewURLHandler(mockShortener, cfg, log), cfg, signer, log)
+	})
+
+	AfterEach(func() {
+		ctrl.Finish()
+	})
+
+	It("describes every route of the router and nothing else", func() {
+		routes := make(map[string]bool)
+		handleError(chi.Walk(router, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+			if len(route) > 1 {
+				route = strings.TrimSuffix(route, "/")
+			}
+			routes[method+" "+route] = true
+			return nil
+		}))
+
+		described := make(map[string]bool)
+		for path, item := range openapi.Spec().Paths.Map() {
+			for method := range item.Operations() {
+				described[method+" "+path] = true
+			}
+		}
+		for route := range routes {
+			Expect(described).To(HaveKey(route), "route %s is missing from the OpenAPI specification", route)
+		}
+		for operation := range described {
+			Expect(routes).To(HaveKey(operation), "operation %s is not routed", operation)
+		}
+	})
+
+	It("serves the specification and Swagger UI", func() {
+		ts := httptest.NewServer(router)
+		defer ts.Close()
+
+		resp, err := http.Get(ts.URL + openapi.SpecPath)
+		handleError(err)
+		defer must(resp.Body.Close)
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		Expect(resp.Header.Get("Content-Type")).To(Equal("application/json"))
+
+		docs, err := http.Get(ts.URL + openapi.DocsPath)
+		handleError(err)
+		defer must(docs.Body.Close)
+		Expect(docs.StatusCode).To(Equal(http.StatusOK))
+		Expect(docs.Header.Get("Content-Type")).To(HavePrefix("text/html"))
+	})
+
+	It("rejects requests not matching the specification", func() {
+		ts := httptest.NewServer(router)
+		defer ts.Close()
+
+		resp, err := http.Post(ts.URL+"/api/shorten/batch", "application/json",
+			strings.NewReader(`[{"correlation_id":1,"original_url":"http://example.com"}]`))
+		handleError(err)
+		defer must(resp.Body.Close)
+
+		Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+		var p problem.Problem
+		handleError(json.NewDecoder(resp.Body).Decode(&p))
+		Expect(p.Code).To(Equal(problem.CodeValidationFailed))
+		Expect(p.Detail).To(ContainSubstring("correlation_id"))
+	})
+
+	It("checks responses only if the config asks for it", func() {
+		const userID = "ffffffff-ffff-ffff-ffff-ffffffffffff"
+		mockShortener.EXPECT().GetWorkspaces(gomock.Any(), userID).
+			Return([]models.Workspace{{ID: "team", Name: "Team", Role: "bogus"}}, nil).Times(2)
+		cookie, err := middleware.BuildAuthCookie(signer, userID)
+		handleError(err)
+
+		// getWorkspaces lists the workspaces of the user through the router and returns the status of the response.
+		getWorkspaces := func(router http.Handler) int {
+			req := httptest.NewRequest(http.MethodGet, "/api/user/workspaces", nil)
+			req.AddCookie(cookie)
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+			return rec.Code
+		}
+
+		Expect(getWorkspaces(router)).To(Equal(http.StatusInternalServerError))
+
+		cfg := config.New(config.WithValidateResponses(false))
+		defer config.New(config.WithValidateResponses(true))
+		Expect(getWorkspaces(api.NewRouter(api.NewURLHandler(mockShortener, cfg, log), cfg, signer, log))).To(Equal(http.StatusOK))
+	})
+})
+
+var _ = Describe("Links API v2", func() {
+	const userID = "ffffffff-ffff-ffff-ffff-ffffffffffff"
+	var (
+		ctrl          *gomock.Controller
+		mockShortener *mocks.MockShortener
+		cfg           *config.Config
+		log           logger.Logger
+		handler       *api.URLHandler
+		router        chi.Router
+		ts            *httptest.Server
+		cookie        *http.Cookie
+		baseURL       string
+	)
+
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		mockShortener = mocks.NewMockShortener(ctrl)
+		cfg = config.New(config.WithValidateResponses(true), config.WithJWTSecret("secret"))
+		log, _ = logger.New("testing")
+		handler = api.NewURLHandler(mockShortener, cfg, log)
+		router = api.NewRouter(handler, cfg, signer, log)
+		ts = httptest.NewServer(router)
+		baseURL = cfg.BaseURL.String()
+		var err error
+		cookie, err = middleware.BuildAuthCookie(signer, userID)
+		handleError(err)
+	})
+
+	AfterEach(func() {
+		ts.Close()
+		ctrl.Finish()
+	})
+
+	do := func(method, path, body string) *http.Response {
+		var reader io.Reader
+		if body != "" {
+			reader = strings.NewReader(body)
+		}
+		req, err := http.NewRequest(method, ts.URL+path, reader)
+		handleError(err)
+		if body != "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		req.AddCookie(cookie)
+		resp, err := http.DefaultClient.Do(req)
+		handleError(err)
+		return resp
+	}
+
+	Context("when creating a link", func() {
+		It("returns status 201 Created and the link", func() {
+			createdAt := time.Date(2026, time.October, 18, 12, 0, 0, 0, time.UTC)
+			mockShortener.EXPECT().Shorten(gomock.Any(), "http://example.com", userID, gomock.Any()).
+				Return(&models.URL{
+					ShortURL:    baseURL + "/short1",
+					OriginalURL: "http://example.com",
+					CreatedAt:   createdAt,
+					URLMetadata: models.URLMetadata{Title: "Example"},
+				}, false, nil)
+
+			resp := do(http.MethodPost, "/api/v2/links", `{"url":"http://example.com","metadata":{"title":"Example"}}`)
+			defer must(resp.Body.Close)
+
+			Expect(resp.StatusCode).To(Equal(http.StatusCreated))
+			Expect(resp.Header.Get("Deprecation")).To(BeEmpty())
+			var got models.Link
+			handleError(json.NewDecoder(resp.Body).Decode(&got))
+			Expect(got.ID).To(Equal("short1"))
+			Expect(got.ShortURL).To(Equal(baseURL + "/short1"))
+			Expect(got.Metadata.Title).To(Equal("Example"))
+			Expect(got.Metadata.Tags).To(BeEmpty())
+			Expect(got.CreatedAt).To(Equal(createdAt))
+		})
+
+		It("returns status 200 OK for an already shortened URL", func() {
+			mockShortener.EXPECT().Shorten(gomock.Any(), "http://example.com", userID, gomock.Any()).
+				Return(&models.URL{ShortURL: baseURL + "/short1", OriginalURL: "http://example.com"}, true, nil)
+
+			resp := do(http.MethodPost, "/api/v2/links", `{"url":"http://example.com"}`)
+			defer must(resp.Body.Close)
+
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		})
+
+		It("returns status 400 Bad Request for an empty URL", func() {
+			resp := do(http.MethodPost, "/api/v2/links", `{"url":""}`)
+			defer must(resp.Body.Close)
+
+			Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+			var p problem.Problem
+			handleError(json.NewDecoder(resp.Body).Decode(&p))
+			Expect(p.Code).To(Equal(problem.CodeEmptyURL))
+		})
+	})
+
+	Context("when shortening a batch", func() {
+		It("returns the items with the IDs of the links", func() {
+			batchReq := models.BatchRequest{
+				{CorrelationID: "1", OriginalURL: "http://example.com/1"},
+				{CorrelationID: "2", OriginalURL: "not a url"},
+			}
+			mockShortener.EXPECT().ShortenBatch(gomock.Any(), batchReq, userID, "").Return(models.BatchResponse{
+				{CorrelationID: "1", ShortURL: baseURL + "/short1", Status: models.BatchCreated},
+				{CorrelationID: "2", Status: models.BatchInvalid, Error: "invalid URL"},
+			}, nil)
+
+			resp := do(http.MethodPost, "/api/v2/links/batch",
+				`[{"correlation_id":"1","original_url":"http://example.com/1"},{"correlation_id":"2","original_url":"not a url"}]`)
+			defer must(resp.Body.Close)
+
+			Expect(resp.StatusCode).To(Equal(http.StatusCreated))
+			var got models.LinkBatchResponse
+			handleError(json.NewDecoder(resp.Body).Decode(&got))
+			Expect(got.Items).To(Equal([]models.LinkBatchItem{
+				{CorrelationID: "1", ID: "short1", ShortURL: baseURL + "/short1", Status: models.BatchCreated},
+				{CorrelationID: "2", Status: models.BatchInvalid, Error: "invalid URL"},
+			}))
+		})
+	})
+
+	Context("when listing and searching links", func() {
+		It("returns empty lists rather than 204 No Content", func() {
+			mockShortener.EXPECT().GetAll(gomock.Any(), userID, models.URLFilter{Tags: []string{"work"}}).Return(nil, nil)
+			mockShortener.EXPECT().SearchURLs(gomock.Any(), userID, "exa", 0).
+				Return([]models.URL{{ShortURL: baseURL + "/short1", OriginalURL: "http://example.com"}}, nil)
+
+			resp := do(http.MethodGet, "/api/v2/links?tag=work", "")
+			defer must(resp.Body.Close)
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			body, err := io.ReadAll(resp.Body)
+			handleError(err)
+			Expect(body).To(MatchJSON(`{"links":[],"count":0}`))
+
+			found := do(http.MethodGet, "/api/v2/links/search?q=exa", "")
+			defer must(found.Body.Close)
+			Expect(found.StatusCode).To(Equal(http.StatusOK))
+			var got models.LinkList
+			handleError(json.NewDecoder(found.Body).Decode(&got))
+			Expect(got.Count).To(Equal(1))
+			Expect(got.Links[0].ID).To(Equal("short1"))
+		})
+	})
+
+	Context("when deleting links", func() {
+		It("returns status 202 Accepted", func() {
+			done := make(chan struct{})
+			mockShortener.EXPECT().LinkOwner(gomock.Any(), userID, models.ScopeDelete).Return(userID, nil)
+			mockShortener.EXPECT().DeleteMany(gomock.Any(), userID, []string{"short1", "short2"}).
+				DoAndReturn(func(context.Context, string, []string) error {
+					close(done)
+					return nil
+				})
+
+			resp := do(http.MethodDelete, "/api/v2/links", `{"ids":["short1","short2"]}`)
+			defer must(resp.Body.Close)
+
+			Expect(resp.StatusCode).To(Equal(http.StatusAccepted))
+			Eventually(done).Should(BeClosed())
+		})
+	})
+
+	Context("when updating a link", func() {
+		It("returns the updated link and its history", func() {
+			mockShortener.EXPECT().UpdateURL(gomock.Any(), userID, "short1", models.UpdateURLRequest{URL: "http://example.com/fixed"}).
+				Return(&models.URL{ShortURL: baseURL + "/short1", OriginalURL: "http://example.com/fixed"}, nil)
+			mockShortener.EXPECT().GetURLHistory(gomock.Any(), userID, "short1").Return(nil, nil)
+
+			resp := do(http.MethodPatch, "/api/v2/links/short1", `{"url":"http://example.com/fixed"}`)
+			defer must(resp.Body.Close)
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			var got models.Link
+			handleError(json.NewDecoder(resp.Body).Decode(&got))
+			Expect(got.ID).To(Equal("short1"))
+			Expect(got.OriginalURL).To(Equal("http://example.com/fixed"))
+
+			history := do(http.MethodGet, "/api/v2/links/short1/history", "")
+			defer must(history.Body.Close)
+			Expect(history.StatusCode).To(Equal(http.StatusOK))
+			body, err := io.ReadAll(history.Body)
+			handleError(err)
+			Expect(body).To(MatchJSON(`{"history":[]}`))
+		})
+	})
+
+	Context("when calling version 1", func() {
+		It("marks the responses as deprecated", func() {
+			mockShortener.EXPECT().ShortenURL(gomock.Any(), "http://example.com", userID, gomock.Any()).
+				Return(baseURL+"/short1", false, nil)
+			mockShortener.EXPECT().GetAll(gomock.Any(), userID, gomock.Any()).Return(nil, nil)
+
+			for _, resp := range []*http.Response{
+				do(http.MethodPost, "/api/shorten", `{"url":"http://example.com"}`),
+				do(http.MethodGet, "/api/user/urls", ""),
+			} {
+				must(resp.Body.Close)
+				Expect(resp.Header.Get("Deprecation")).To(HavePrefix("@"))
+				Expect(resp.Header.Get("Sunset")).To(HaveSuffix("GMT"))
+				Expect(resp.Header.Get("Link")).To(Equal(`</api/v2/links>; rel="successor-version"`))
+			}
+		})
+	})
+})
+
+var _ = Describe("Domains", func() {
+	const userID = "ffffffff-ffff-ffff-ffff-ffffffffffff"
+	var (
+		ctrl          *gomock.Controller
+		mockShortener *mocks.MockShortener
+		ts            *httptest.Server
+		cookie        *http.Cookie
+	)
+
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		mockShortener = mocks.NewMockShortener(ctrl)
+		cfg := config.New(config.WithValidateResponses(true), config.WithJWTSecret("secret"))
+		log, _ := logger.New("testing")
+		ts = httptest.NewServer(api.NewRouter(api.NewURLHandler(mockShortener, cfg, log), cfg, signer, log))
+		var err error
+		cookie, err = middleware.BuildAuthCookie(signer, userID)
+		handleError(err)
+	})
+
+	AfterEach(func() {
+		ts.Close()
+		ctrl.Finish()
+	})
+
+	Context("when adding a domain", func() {
+		It("returns status 201 Created and the domain with its verification token", func() {
+			mockShortener.EXPECT().AddDomain(gomock.Any(), userID, "go.brand.com").
+				Return(&models.Domain{Name: "go.brand.com", VerificationToken: "token", CreatedAt: time.Now()}, nil)
+
+			req, err := http.NewRequest("POST", ts.URL+"/api/user/domains", bytes.NewBufferString(`{"name":"go.brand.com"}`))
+			handleError(err)
+			req.AddCookie(cookie)
+			resp, err := http.DefaultClient.Do(req)
+			handleError(err)
+			defer must(resp.Body.Close)
+
+			Expect(resp.StatusCode).To(Equal(http.StatusCreated))
+			var got models.Domain
+			handleError(json.NewDecoder(resp.Body).Decode(&got))
+			Expect(got.VerificationToken).To(Equal("token"))
+			Expect(got.Verified()).To(BeFalse())
+		})
+	})
+
+	Context("when the verification record of a domain is missing", func() {
+		It("returns status 409 Conflict", func() {
+			mockShortener.EXPECT().VerifyDomain(gomock.Any(), userID, "go.brand.com").
+				Return(nil, service.ErrDomainVerificationFailed)
+
+			req, err := http.NewRequest("POST", ts.URL+"/api/user/domains/go.brand.com/verify", nil)
+			handleError(err)
+			req.AddCookie(cookie)
+			resp, err := http.DefaultClient.Do(req)
+			handleError(err)
+			defer must(resp.Body.Close)
+
+			Expect(resp.StatusCode).To(Equal(http.StatusConflict))
+		})
+	})
+
+	Context("when listing the domains of a user without any", func() {
+		It("returns status 200 OK and an empty array", func() {
+			mockShortener.EXPECT().GetDomains(gomock.Any(), userID).Return(nil, nil)
+
+			req, err := http.NewRequest("GET", ts.URL+"/api/user/domains", nil)
+			handleError(err)
+			req.AddCookie(cookie)
+			resp, err := http.DefaultClient.Do(req)
+			handleError(err)
+			defer must(resp.Body.Close)
+
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			body, err := io.ReadAll(resp.Body)
+			handleError(err)
+			Expect(body).To(MatchJSON(`[]`))
+		})
+	})
+})
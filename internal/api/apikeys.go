@@ -0,0 +1,85 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/grnsv/shortener/internal/api/middleware"
+	"github.com/grnsv/shortener/internal/api/problem"
+	"github.com/grnsv/shortener/internal/models"
+)
+
+// CreateAPIKey handles requests to issue a new API key for a user.
+// It expects a JSON body with a name and optional scopes and returns the key,
+// which is shown only once, with 201 Created.
+func (h *URLHandler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDContextKey).(string)
+	if !ok {
+		h.writeError(w, r, errUserIDNotFound)
+		return
+	}
+
+	var req models.CreateAPIKeyRequest
+	defer h.closeBody(r)
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeBodyError(w, r, err, problem.CodeInvalidJSON)
+		return
+	}
+
+	resp, err := h.shortener.CreateAPIKey(r.Context(), userID, req.Name, req.Scopes)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err = json.NewEncoder(w).Encode(resp); err != nil {
+		h.logger.Error(err)
+	}
+}
+
+// GetAPIKeys handles requests to list the API keys of a user.
+// It returns a JSON array of keys or 204 No Content if none exist.
+func (h *URLHandler) GetAPIKeys(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDContextKey).(string)
+	if !ok {
+		h.writeError(w, r, errUserIDNotFound)
+		return
+	}
+
+	keys, err := h.shortener.GetAPIKeys(r.Context(), userID)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	if len(keys) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err = json.NewEncoder(w).Encode(keys); err != nil {
+		h.logger.Error(err)
+	}
+}
+
+// RevokeAPIKey handles requests to revoke an API key of a user.
+// It returns 204 No Content on success or 404 Not Found for an unknown key.
+func (h *URLHandler) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDContextKey).(string)
+	if !ok {
+		h.writeError(w, r, errUserIDNotFound)
+		return
+	}
+
+	err := h.shortener.RevokeAPIKey(r.Context(), userID, chi.URLParam(r, "id"))
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
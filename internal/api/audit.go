@@ -0,0 +1,60 @@
+package api
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/grnsv/shortener/internal/api/problem"
+	"github.com/grnsv/shortener/internal/models"
+)
+
+// GetAuditLog handles requests from the trusted subnet for the audit log. The optional query parameters
+// actor, action and target select entries by their fields, since and until by the time they were made
+// in RFC 3339 format, and limit caps their number. It returns a JSON array of the entries, newest first,
+// or 400 Bad Request for an invalid query.
+func (h *URLHandler) GetAuditLog(w http.ResponseWriter, r *http.Request) {
+	filter, p := parseAuditFilter(r.URL.Query())
+	if p != nil {
+		h.writeProblem(w, r, p)
+		return
+	}
+
+	entries, err := h.shortener.GetAuditLog(r.Context(), filter)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+	if entries == nil {
+		entries = []models.AuditEntry{}
+	}
+
+	h.writeJSON(w, http.StatusOK, entries)
+}
+
+// parseAuditFilter reads an audit filter from the query parameters of a request.
+func parseAuditFilter(query url.Values) (models.AuditFilter, *problem.Problem) {
+	filter := models.AuditFilter{
+		Actor:  query.Get("actor"),
+		Action: query.Get("action"),
+		Target: query.Get("target"),
+	}
+	var err error
+	if raw := query.Get("since"); raw != "" {
+		if filter.Since, err = time.Parse(time.RFC3339, raw); err != nil {
+			return filter, badRequest(problem.CodeValidationFailed, "since must be an RFC 3339 time")
+		}
+	}
+	if raw := query.Get("until"); raw != "" {
+		if filter.Until, err = time.Parse(time.RFC3339, raw); err != nil {
+			return filter, badRequest(problem.CodeValidationFailed, "until must be an RFC 3339 time")
+		}
+	}
+	if raw := query.Get("limit"); raw != "" {
+		if filter.Limit, err = strconv.Atoi(raw); err != nil {
+			return filter, badRequest(problem.CodeValidationFailed, "limit must be an integer")
+		}
+	}
+	return filter, nil
+}
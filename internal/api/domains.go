@@ -0,0 +1,77 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/grnsv/shortener/internal/api/middleware"
+	"github.com/grnsv/shortener/internal/api/problem"
+	"github.com/grnsv/shortener/internal/models"
+)
+
+// AddDomain handles requests to add a custom domain to the user.
+// It expects a JSON body with the name of the domain and returns the unverified domain with the token
+// its verification record must contain with 201 Created, or 409 Conflict if the user already added it.
+func (h *URLHandler) AddDomain(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDContextKey).(string)
+	if !ok {
+		h.writeError(w, r, errUserIDNotFound)
+		return
+	}
+
+	var req models.CreateDomainRequest
+	defer h.closeBody(r)
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeBodyError(w, r, err, problem.CodeInvalidJSON)
+		return
+	}
+
+	domain, err := h.shortener.AddDomain(r.Context(), userID, req.Name)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	h.writeJSON(w, http.StatusCreated, domain)
+}
+
+// GetDomains handles requests to list the custom domains of a user.
+// It returns a JSON array of domains, which is empty if there are none.
+func (h *URLHandler) GetDomains(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDContextKey).(string)
+	if !ok {
+		h.writeError(w, r, errUserIDNotFound)
+		return
+	}
+
+	domains, err := h.shortener.GetDomains(r.Context(), userID)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+	if domains == nil {
+		domains = []models.Domain{}
+	}
+
+	h.writeJSON(w, http.StatusOK, domains)
+}
+
+// VerifyDomain handles requests to verify a custom domain of the user by its DNS TXT record.
+// It returns the verified domain, 404 Not Found for a domain the user has not added,
+// or 409 Conflict if the record is missing or another user verified the domain.
+func (h *URLHandler) VerifyDomain(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDContextKey).(string)
+	if !ok {
+		h.writeError(w, r, errUserIDNotFound)
+		return
+	}
+
+	domain, err := h.shortener.VerifyDomain(r.Context(), userID, chi.URLParam(r, "name"))
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, domain)
+}
@@ -0,0 +1,126 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/grnsv/shortener/internal/api/problem"
+	"github.com/grnsv/shortener/internal/qr"
+	"github.com/grnsv/shortener/internal/service"
+	"github.com/grnsv/shortener/internal/storage"
+	"github.com/grnsv/shortener/internal/transfer"
+)
+
+// validationErrors are the errors of the service caused by invalid input, answered with 400 Bad Request.
+var validationErrors = []error{
+	service.ErrInvalidRedirectCode,
+	service.ErrInvalidMetadata,
+	service.ErrInvalidQuery,
+	service.ErrInvalidScope,
+	service.ErrInvalidEvent,
+	service.ErrInvalidAlias,
+	service.ErrInvalidExpiry,
+	service.ErrInvalidIdempotencyKey,
+	service.ErrInvalidDomain,
+	service.ErrDomainNotVerified,
+	service.ErrInvalidWorkspace,
+	service.ErrInvalidWorkspaceName,
+	service.ErrInvalidMember,
+	service.ErrInvalidRole,
+	service.ErrInvalidUser,
+	service.ErrUnknownPlan,
+	service.ErrInvalidAuditFilter,
+	qr.ErrInvalidOptions,
+	transfer.ErrUnknownFormat,
+}
+
+// problemFor maps an error returned by the service or while reading a request body to a problem.
+// Errors it does not know are internal errors, whose detail is not disclosed to clients.
+func problemFor(err error) *problem.Problem {
+	var p *problem.Problem
+	if errors.As(err, &p) {
+		return p
+	}
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return problem.New(http.StatusRequestEntityTooLarge, problem.CodePayloadTooLarge,
+			"request body exceeds "+strconv.FormatInt(maxBytesErr.Limit, 10)+" bytes")
+	}
+
+	switch {
+	case errors.Is(err, service.ErrMemberNotFound):
+		return problem.New(http.StatusNotFound, problem.CodeNotFound, err.Error())
+	case errors.Is(err, storage.ErrNotFound):
+		return problem.New(http.StatusNotFound, problem.CodeNotFound, "short URL not found")
+	case errors.Is(err, storage.ErrDeleted):
+		return problem.New(http.StatusGone, problem.CodeGone, "short URL was deleted")
+	case errors.Is(err, storage.ErrAlreadyExist), errors.Is(err, storage.ErrLastOwner):
+		return problem.New(http.StatusConflict, problem.CodeConflict, err.Error())
+	case errors.Is(err, service.ErrInvalidURL):
+		return problem.New(http.StatusBadRequest, problem.CodeInvalidURL, err.Error())
+	case errors.Is(err, service.ErrTooManyWebhooks), errors.Is(err, service.ErrTooManyDomains),
+		errors.Is(err, service.ErrDomainVerificationFailed):
+		return problem.New(http.StatusConflict, problem.CodeConflict, err.Error())
+	case errors.Is(err, service.ErrIdempotencyKeyReused):
+		return problem.New(http.StatusUnprocessableEntity, problem.CodeIdempotencyKeyReused, err.Error())
+	case errors.Is(err, service.ErrNotMember), errors.Is(err, service.ErrInsufficientRole):
+		return problem.New(http.StatusForbidden, problem.CodeForbidden, err.Error())
+	case errors.Is(err, service.ErrQuotaExceeded):
+		return problem.New(http.StatusForbidden, problem.CodeQuotaExceeded, err.Error())
+	case errors.Is(err, service.ErrInvalidPassword):
+		return problem.New(http.StatusForbidden, problem.CodeInvalidPassword, err.Error())
+	case errors.Is(err, service.ErrTooManyAttempts):
+		return problem.New(http.StatusTooManyRequests, problem.CodeTooManyRequests, err.Error())
+	case errors.Is(err, service.ErrUnsupported):
+		return problem.New(http.StatusNotImplemented, problem.CodeNotImplemented, err.Error())
+	}
+	for _, target := range validationErrors {
+		if errors.Is(err, target) {
+			return problem.New(http.StatusBadRequest, problem.CodeValidationFailed, err.Error())
+		}
+	}
+	return problem.New(http.StatusInternalServerError, problem.CodeInternal, "")
+}
+
+// writeError responds with the problem for err. Internal errors are logged.
+func (h *URLHandler) writeError(w http.ResponseWriter, r *http.Request, err error) {
+	p := problemFor(err)
+	if p.Status >= http.StatusInternalServerError {
+		h.logger.Error(err)
+	}
+	h.writeProblem(w, r, p)
+}
+
+// writeBodyError responds to a request whose body could not be read or decoded
+// with 413 Payload Too Large if it exceeded its limit and with 400 Bad Request and code otherwise.
+func (h *URLHandler) writeBodyError(w http.ResponseWriter, r *http.Request, err error, code problem.Code) {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		h.writeError(w, r, err)
+		return
+	}
+	h.writeProblem(w, r, problem.New(http.StatusBadRequest, code, err.Error()))
+}
+
+// writeProblem sends p as the response to r.
+func (h *URLHandler) writeProblem(w http.ResponseWriter, r *http.Request, p *problem.Problem) {
+	p.Instance = r.URL.Path
+	if err := problem.Write(w, p); err != nil {
+		h.logger.Error(err)
+	}
+}
+
+// badRequest creates a problem for an invalid request with the given code and detail.
+func badRequest(code problem.Code, detail string) *problem.Problem {
+	return problem.New(http.StatusBadRequest, code, detail)
+}
+
+// errUserIDNotFound is an internal error of a request without the user ID set by the authentication middleware.
+var errUserIDNotFound = errors.New("user ID not found in context")
+
+// batchTooLarge creates a problem for a batch of more than limit items.
+func batchTooLarge(limit int) *problem.Problem {
+	return problem.New(http.StatusRequestEntityTooLarge, problem.CodeBatchTooLarge,
+		"batch must have at most "+strconv.Itoa(limit)+" items")
+}
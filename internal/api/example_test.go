@@ -71,7 +71,7 @@ func Example_expandURL() {
 	rec := httptest.NewRecorder()
 	handler.ExpandURL(rec, req)
 	fmt.Print(rec.Code)
-	// Output: 400
+	// Output: 404
 }
 
 // Example of getting all user URLs
@@ -9,10 +9,12 @@ import (
 	"errors"
 	"io"
 	"net/http"
+	"slices"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/grnsv/shortener/internal/api/middleware"
+	"github.com/grnsv/shortener/internal/api/problem"
 	"github.com/grnsv/shortener/internal/config"
 	"github.com/grnsv/shortener/internal/logger"
 	"github.com/grnsv/shortener/internal/models"
@@ -47,31 +49,29 @@ func (h *URLHandler) closeBody(r *http.Request) {
 func (h *URLHandler) ShortenURL(w http.ResponseWriter, r *http.Request) {
 	userID, ok := r.Context().Value(middleware.UserIDContextKey).(string)
 	if !ok {
-		h.logger.Error("user ID not found in context")
-		w.WriteHeader(http.StatusInternalServerError)
+		h.writeError(w, r, errUserIDNotFound)
 		return
 	}
 
 	defer h.closeBody(r)
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		writeError(w)
+		h.writeBodyError(w, r, err, problem.CodeBadRequest)
 		return
 	}
 
 	if len(body) == 0 {
-		writeError(w)
+		h.writeProblem(w, r, badRequest(problem.CodeEmptyURL, "request body must contain a URL"))
 		return
 	}
 
-	w.Header().Set("Content-Type", "text/plain")
-
 	shortURL, alreadyExists, err := h.shortener.ShortenURL(r.Context(), string(body), userID)
 	if err != nil {
-		writeError(w)
+		h.writeError(w, r, err)
 		return
 	}
 
+	w.Header().Set("Content-Type", "text/plain")
 	if alreadyExists {
 		w.WriteHeader(http.StatusConflict)
 	} else {
@@ -80,7 +80,7 @@ func (h *URLHandler) ShortenURL(w http.ResponseWriter, r *http.Request) {
 
 	_, err = w.Write([]byte(shortURL))
 	if err != nil {
-		writeError(w)
+		h.logger.Error(err)
 	}
 }
 
@@ -89,8 +89,7 @@ func (h *URLHandler) ShortenURL(w http.ResponseWriter, r *http.Request) {
 func (h *URLHandler) ShortenURLJSON(w http.ResponseWriter, r *http.Request) {
 	userID, ok := r.Context().Value(middleware.UserIDContextKey).(string)
 	if !ok {
-		h.logger.Error("user ID not found in context")
-		w.WriteHeader(http.StatusInternalServerError)
+		h.writeError(w, r, errUserIDNotFound)
 		return
 	}
 
@@ -98,23 +97,28 @@ func (h *URLHandler) ShortenURLJSON(w http.ResponseWriter, r *http.Request) {
 	defer h.closeBody(r)
 	err := json.NewDecoder(r.Body).Decode(&req)
 	if err != nil {
-		writeError(w)
+		h.writeBodyError(w, r, err, problem.CodeInvalidJSON)
 		return
 	}
 
 	if len(req.URL) == 0 {
-		writeError(w)
+		h.writeProblem(w, r, badRequest(problem.CodeEmptyURL, "url must not be empty"))
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-
-	shortURL, alreadyExists, err := h.shortener.ShortenURL(r.Context(), req.URL, userID)
+	shortURL, alreadyExists, err := h.shortener.ShortenURL(r.Context(), req.URL, userID,
+		service.WithPassword(req.Password),
+		service.WithInterstitial(req.Interstitial),
+		service.WithRedirectCode(req.RedirectCode),
+		service.WithMetadata(req.URLMetadata),
+		service.WithDomain(req.Domain),
+	)
 	if err != nil {
-		writeError(w)
+		h.writeError(w, r, err)
 		return
 	}
 
+	w.Header().Set("Content-Type", "application/json")
 	if alreadyExists {
 		w.WriteHeader(http.StatusConflict)
 	} else {
@@ -125,17 +129,18 @@ func (h *URLHandler) ShortenURLJSON(w http.ResponseWriter, r *http.Request) {
 		Result: shortURL,
 	})
 	if err != nil {
-		writeError(w)
+		h.logger.Error(err)
 	}
 }
 
 // ShortenBatch handles batch URL shortening requests.
-// It expects a JSON array of URLs and returns a JSON array of shortened URLs.
+// It expects a JSON array of URLs and returns a JSON array of shortened URLs with the status of every item,
+// with 201 Created if any link was created and 200 OK otherwise. A batch retried with the same
+// Idempotency-Key header gets the original response; reusing the key for another batch yields 422.
 func (h *URLHandler) ShortenBatch(w http.ResponseWriter, r *http.Request) {
 	userID, ok := r.Context().Value(middleware.UserIDContextKey).(string)
 	if !ok {
-		h.logger.Error("user ID not found in context")
-		w.WriteHeader(http.StatusInternalServerError)
+		h.writeError(w, r, errUserIDNotFound)
 		return
 	}
 
@@ -143,51 +148,76 @@ func (h *URLHandler) ShortenBatch(w http.ResponseWriter, r *http.Request) {
 	defer h.closeBody(r)
 	err := json.NewDecoder(r.Body).Decode(&req)
 	if err != nil {
-		writeError(w)
+		h.writeBodyError(w, r, err, problem.CodeInvalidJSON)
 		return
 	}
 
 	if len(req) == 0 {
-		writeError(w)
+		h.writeProblem(w, r, badRequest(problem.CodeEmptyBatch, "batch must not be empty"))
+		return
+	}
+	if len(req) > h.config.MaxBatchSize {
+		h.writeProblem(w, r, batchTooLarge(h.config.MaxBatchSize))
 		return
 	}
 
-	resp, err := h.shortener.ShortenBatch(r.Context(), req, userID)
+	resp, err := h.shortener.ShortenBatch(r.Context(), req, userID, r.Header.Get("Idempotency-Key"))
 	if err != nil {
-		writeError(w)
+		h.writeError(w, r, err)
 		return
 	}
 
+	code := http.StatusOK
+	if slices.ContainsFunc(resp, func(item models.BatchResponseItem) bool {
+		return item.Status == models.BatchCreated
+	}) {
+		code = http.StatusCreated
+	}
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
+	w.WriteHeader(code)
 	err = json.NewEncoder(w).Encode(resp)
 	if err != nil {
-		writeError(w)
+		h.logger.Error(err)
 	}
 }
 
 // ExpandURL handles GET requests to expand a shortened URL.
-// It redirects the client to the original URL if found.
+// It redirects the client to the original URL if found, with the link's redirect status code
+// or the configured default.
+// For password-protected links it serves an unlock form instead, unless the client has already unlocked the link.
+// For links with an interstitial page it serves the preview page instead.
 func (h *URLHandler) ExpandURL(w http.ResponseWriter, r *http.Request) {
 	shortURL := chi.URLParam(r, "id")
 	if shortURL == "" {
-		writeError(w)
+		h.writeError(w, r, storage.ErrNotFound)
 		return
 	}
 
-	url, err := h.shortener.ExpandURL(r.Context(), shortURL)
+	url, code, err := h.shortener.ExpandURL(r.Context(), shortURL)
 	if err != nil {
-		if errors.Is(err, storage.ErrDeleted) {
-			w.WriteHeader(http.StatusGone)
-			return
+		switch {
+		case errors.Is(err, service.ErrPasswordRequired):
+			h.expandProtectedURL(w, r, shortURL)
+		case errors.Is(err, service.ErrInterstitial):
+			h.writePreview(w, r, shortURL)
+		default:
+			h.writeError(w, r, err)
 		}
-
-		h.logger.Error(err)
-		writeError(w)
 		return
 	}
 
-	http.Redirect(w, r, url, http.StatusTemporaryRedirect)
+	h.redirect(w, r, url, code)
+}
+
+// redirect redirects the client to url with the link's status code, or the configured default if it is zero.
+func (h *URLHandler) redirect(w http.ResponseWriter, r *http.Request, url string, code int) {
+	if code == 0 {
+		code = h.config.RedirectCode
+	}
+	if !models.ValidRedirectCode(code) {
+		code = http.StatusTemporaryRedirect
+	}
+	http.Redirect(w, r, url, code)
 }
 
 // PingDB handles health check requests for the storage backend.
@@ -195,7 +225,7 @@ func (h *URLHandler) ExpandURL(w http.ResponseWriter, r *http.Request) {
 func (h *URLHandler) PingDB(w http.ResponseWriter, r *http.Request) {
 	if err := h.shortener.PingStorage(r.Context()); err != nil {
 		h.logger.Error(err)
-		w.WriteHeader(http.StatusInternalServerError)
+		h.writeProblem(w, r, problem.New(http.StatusInternalServerError, problem.CodeUnavailable, "storage is unreachable"))
 		return
 	}
 
@@ -203,19 +233,18 @@ func (h *URLHandler) PingDB(w http.ResponseWriter, r *http.Request) {
 }
 
 // GetURLs handles requests to retrieve all URLs for a user.
+// Repeated tag query parameters select only URLs having all of the tags.
 // It returns a JSON array of URLs or 204 No Content if none exist.
 func (h *URLHandler) GetURLs(w http.ResponseWriter, r *http.Request) {
 	userID, ok := r.Context().Value(middleware.UserIDContextKey).(string)
 	if !ok {
-		h.logger.Error("user ID not found in context")
-		w.WriteHeader(http.StatusInternalServerError)
+		h.writeError(w, r, errUserIDNotFound)
 		return
 	}
 
-	urls, err := h.shortener.GetAll(r.Context(), userID)
+	urls, err := h.shortener.GetAll(r.Context(), userID, models.URLFilter{Tags: r.URL.Query()["tag"]})
 	if err != nil {
-		h.logger.Error(err)
-		w.WriteHeader(http.StatusInternalServerError)
+		h.writeError(w, r, err)
 		return
 	}
 
@@ -228,17 +257,16 @@ func (h *URLHandler) GetURLs(w http.ResponseWriter, r *http.Request) {
 	err = json.NewEncoder(w).Encode(urls)
 	if err != nil {
 		h.logger.Error(err)
-		w.WriteHeader(http.StatusInternalServerError)
 	}
 }
 
 // DeleteURLs handles requests to delete multiple shortened URLs for a user.
 // It accepts a JSON array of short URL IDs and processes deletion asynchronously.
+// Access to the active workspace is checked before the deletion is accepted.
 func (h *URLHandler) DeleteURLs(w http.ResponseWriter, r *http.Request) {
 	userID, ok := r.Context().Value(middleware.UserIDContextKey).(string)
 	if !ok {
-		h.logger.Error("user ID not found in context")
-		w.WriteHeader(http.StatusInternalServerError)
+		h.writeError(w, r, errUserIDNotFound)
 		return
 	}
 
@@ -246,14 +274,25 @@ func (h *URLHandler) DeleteURLs(w http.ResponseWriter, r *http.Request) {
 	defer h.closeBody(r)
 	err := json.NewDecoder(r.Body).Decode(&shortURLs)
 	if err != nil {
-		writeError(w)
+		h.writeBodyError(w, r, err, problem.CodeInvalidJSON)
+		return
+	}
+	if len(shortURLs) > h.config.MaxBatchSize {
+		h.writeProblem(w, r, batchTooLarge(h.config.MaxBatchSize))
+		return
+	}
+	owner, err := h.shortener.LinkOwner(r.Context(), userID, models.ScopeDelete)
+	if err != nil {
+		h.writeError(w, r, err)
 		return
 	}
 
+	// Deletions are recorded in the audit log with the request they are made on.
+	info := service.RequestInfoFrom(r.Context())
 	go func() {
-		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		ctx, cancel := context.WithTimeout(service.WithRequestInfo(context.Background(), info), time.Minute)
 		defer cancel()
-		err := h.shortener.DeleteMany(ctx, userID, shortURLs)
+		err := h.shortener.DeleteMany(ctx, owner, shortURLs)
 		if err != nil {
 			h.logger.Error(err)
 		}
@@ -267,8 +306,7 @@ func (h *URLHandler) DeleteURLs(w http.ResponseWriter, r *http.Request) {
 func (h *URLHandler) GetStats(w http.ResponseWriter, r *http.Request) {
 	stats, err := h.shortener.GetStats(r.Context())
 	if err != nil {
-		h.logger.Error(err)
-		w.WriteHeader(http.StatusInternalServerError)
+		h.writeError(w, r, err)
 		return
 	}
 
@@ -276,10 +314,5 @@ func (h *URLHandler) GetStats(w http.ResponseWriter, r *http.Request) {
 	err = json.NewEncoder(w).Encode(stats)
 	if err != nil {
 		h.logger.Error(err)
-		w.WriteHeader(http.StatusInternalServerError)
 	}
 }
-
-func writeError(w http.ResponseWriter) {
-	w.WriteHeader(http.StatusBadRequest)
-}
@@ -3,15 +3,22 @@ package api
 import (
 	"bytes"
 	"context"
+	"database/sql"
+	"database/sql/driver"
 	"encoding/json"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/golang/mock/gomock"
+	"github.com/grnsv/shortener/internal/api/middleware"
+	"github.com/grnsv/shortener/internal/api/problem"
 	"github.com/grnsv/shortener/internal/config"
 	"github.com/grnsv/shortener/internal/logger"
+	"github.com/grnsv/shortener/internal/mocks"
 	"github.com/grnsv/shortener/internal/models"
 	"github.com/grnsv/shortener/internal/service"
 	"github.com/grnsv/shortener/internal/storage"
@@ -32,6 +39,7 @@ func TestHandleShortenURL(t *testing.T) {
 	defer requireNoError(t, storage.Close)
 	require.NoError(t, err)
 	cfg := config.New(
+		config.WithValidateResponses(true),
 		config.WithAppEnv("testing"),
 		config.WithServerAddress(config.NetAddress{Host: "localhost", Port: 8080}),
 		config.WithBaseURL(config.BaseURL{Scheme: "http://", Address: config.NetAddress{Host: "localhost", Port: 8080}}),
@@ -40,7 +48,7 @@ func TestHandleShortenURL(t *testing.T) {
 	log, err := logger.New("testing")
 	require.NoError(t, err)
 	handler := NewURLHandler(shortener, cfg, log)
-	ts := httptest.NewServer(NewRouter(handler, cfg, log))
+	ts := httptest.NewServer(NewRouter(handler, cfg, middleware.NewHMACSigner(cfg.JWTSecret), log))
 	defer ts.Close()
 
 	type req struct {
@@ -79,7 +87,8 @@ func TestHandleShortenURL(t *testing.T) {
 				contentType: "text/plain",
 			},
 			want: want{
-				statusCode: http.StatusBadRequest,
+				statusCode:  http.StatusBadRequest,
+				contentType: problem.ContentType,
 			},
 		},
 		{
@@ -90,7 +99,9 @@ func TestHandleShortenURL(t *testing.T) {
 				contentType: "text/plain",
 			},
 			want: want{
-				statusCode: http.StatusBadRequest,
+				statusCode:  http.StatusBadRequest,
+				body:        `"code":"empty_url"`,
+				contentType: problem.ContentType,
 			},
 		},
 	}
@@ -121,6 +132,7 @@ func TestHandleExpandURL(t *testing.T) {
 	defer requireNoError(t, storage.Close)
 	require.NoError(t, err)
 	cfg := config.New(
+		config.WithValidateResponses(true),
 		config.WithAppEnv("testing"),
 		config.WithServerAddress(config.NetAddress{Host: "localhost", Port: 8080}),
 		config.WithBaseURL(config.BaseURL{Scheme: "http://", Address: config.NetAddress{Host: "localhost", Port: 8080}}),
@@ -129,7 +141,7 @@ func TestHandleExpandURL(t *testing.T) {
 	log, err := logger.New("testing")
 	require.NoError(t, err)
 	handler := NewURLHandler(shortener, cfg, log)
-	ts := httptest.NewServer(NewRouter(handler, cfg, log))
+	ts := httptest.NewServer(NewRouter(handler, cfg, middleware.NewHMACSigner(cfg.JWTSecret), log))
 	defer ts.Close()
 
 	client := ts.Client()
@@ -195,13 +207,13 @@ func TestHandleExpandURL(t *testing.T) {
 			},
 		},
 		{
-			name: "invalid path",
+			name: "unknown path",
 			req: req{
 				method: http.MethodGet,
 				target: "/practicum.yandex.ru",
 			},
 			want: want{
-				statusCode: http.StatusBadRequest,
+				statusCode: http.StatusNotFound,
 			},
 		},
 	}
@@ -218,11 +230,40 @@ func TestHandleExpandURL(t *testing.T) {
 	}
 }
 
+func TestHandleUnknownURLOnDB(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	db := mocks.NewMockDB(ctrl)
+	stmt := mocks.NewMockStmt(ctrl)
+	db.EXPECT().ExecContext(gomock.Any(), gomock.Any()).Return(driver.RowsAffected(0), nil).Times(2)
+	db.EXPECT().PreparexContext(gomock.Any(), gomock.Any()).Return(stmt, nil).AnyTimes()
+	storage, err := storage.NewDBStorage(context.Background(), db)
+	require.NoError(t, err)
+	cfg := config.New(
+		config.WithValidateResponses(true),
+		config.WithAppEnv("testing"),
+		config.WithServerAddress(config.NetAddress{Host: "localhost", Port: 8080}),
+		config.WithBaseURL(config.BaseURL{Scheme: "http://", Address: config.NetAddress{Host: "localhost", Port: 8080}}),
+	)
+	shortener := service.NewShortener(storage, storage, storage, storage, cfg.BaseURL.String())
+	log, err := logger.New("testing")
+	require.NoError(t, err)
+	handler := NewURLHandler(shortener, cfg, log)
+	ts := httptest.NewServer(NewRouter(handler, cfg, middleware.NewHMACSigner(cfg.JWTSecret), log))
+	defer ts.Close()
+
+	stmt.EXPECT().GetContext(gomock.Any(), gomock.Any(), "unknown").Return(sql.ErrNoRows)
+	res, err := ts.Client().Get(ts.URL + "/unknown")
+	require.NoError(t, err)
+	defer closeBody(t, res)
+	assert.Equal(t, http.StatusNotFound, res.StatusCode)
+}
+
 func TestHandleShortenURLJSON(t *testing.T) {
 	storage, err := storage.NewMemoryStorage(context.Background())
 	defer requireNoError(t, storage.Close)
 	require.NoError(t, err)
 	cfg := config.New(
+		config.WithValidateResponses(true),
 		config.WithAppEnv("testing"),
 		config.WithServerAddress(config.NetAddress{Host: "localhost", Port: 8080}),
 		config.WithBaseURL(config.BaseURL{Scheme: "http://", Address: config.NetAddress{Host: "localhost", Port: 8080}}),
@@ -231,7 +272,7 @@ func TestHandleShortenURLJSON(t *testing.T) {
 	log, err := logger.New("testing")
 	require.NoError(t, err)
 	handler := NewURLHandler(shortener, cfg, log)
-	ts := httptest.NewServer(NewRouter(handler, cfg, log))
+	ts := httptest.NewServer(NewRouter(handler, cfg, middleware.NewHMACSigner(cfg.JWTSecret), log))
 	defer ts.Close()
 
 	type req struct {
@@ -273,7 +314,7 @@ func TestHandleShortenURLJSON(t *testing.T) {
 			},
 			want: want{
 				statusCode:  http.StatusBadRequest,
-				contentType: "application/x-gzip",
+				contentType: problem.ContentType,
 			},
 		},
 		{
@@ -285,7 +326,7 @@ func TestHandleShortenURLJSON(t *testing.T) {
 			},
 			want: want{
 				statusCode:  http.StatusBadRequest,
-				contentType: "application/x-gzip",
+				contentType: problem.ContentType,
 			},
 		},
 	}
@@ -314,3 +355,491 @@ func TestHandleShortenURLJSON(t *testing.T) {
 		assert.Equal(t, tt.want.contentType, res.Header.Get("Content-Type"), tt.name)
 	}
 }
+
+func TestHandleProtectedURL(t *testing.T) {
+	storage, err := storage.NewMemoryStorage(context.Background())
+	defer requireNoError(t, storage.Close)
+	require.NoError(t, err)
+	cfg := config.New(
+		config.WithValidateResponses(true),
+		config.WithAppEnv("testing"),
+		config.WithServerAddress(config.NetAddress{Host: "localhost", Port: 8080}),
+		config.WithBaseURL(config.BaseURL{Scheme: "http://", Address: config.NetAddress{Host: "localhost", Port: 8080}}),
+	)
+	shortener := service.NewShortener(storage, storage, storage, storage, cfg.BaseURL.String())
+	log, err := logger.New("testing")
+	require.NoError(t, err)
+	handler := NewURLHandler(shortener, cfg, log)
+	ts := httptest.NewServer(NewRouter(handler, cfg, middleware.NewHMACSigner(cfg.JWTSecret), log))
+	defer ts.Close()
+
+	client := ts.Client()
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	body, err := json.Marshal(models.ShortenRequest{URL: "https://practicum.yandex.ru/", Password: "open sesame"})
+	require.NoError(t, err)
+	res, err := client.Post(ts.URL+"/api/shorten", "application/json", bytes.NewReader(body))
+	require.NoError(t, err)
+	defer closeBody(t, res)
+	require.Equal(t, http.StatusCreated, res.StatusCode)
+	var created models.ShortenResponse
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&created))
+	target := strings.Split(created.Result, cfg.BaseURL.String())[1]
+
+	unlock := func(password string) (int, http.Header) {
+		r, postErr := client.PostForm(ts.URL+target, map[string][]string{"password": {password}})
+		require.NoError(t, postErr)
+		defer closeBody(t, r)
+		return r.StatusCode, r.Header
+	}
+
+	res, err = client.Get(ts.URL + target)
+	require.NoError(t, err)
+	defer closeBody(t, res)
+	page, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, res.StatusCode, "unlock form")
+	assert.Equal(t, "text/html; charset=utf-8", res.Header.Get("Content-Type"), "unlock form")
+	assert.Contains(t, string(page), `name="password"`, "unlock form")
+	assert.Empty(t, res.Header.Get("Location"), "unlock form")
+
+	code, _ := unlock("wrong")
+	assert.Equal(t, http.StatusForbidden, code, "wrong password")
+
+	code, header := unlock("open sesame")
+	assert.Equal(t, http.StatusSeeOther, code, "right password")
+	assert.Equal(t, "https://practicum.yandex.ru/", header.Get("Location"), "right password")
+	var cookie *http.Cookie
+	for _, line := range header.Values("Set-Cookie") {
+		if c, parseErr := http.ParseSetCookie(line); parseErr == nil && c.Name == unlockCookieName {
+			cookie = c
+		}
+	}
+	require.NotNil(t, cookie, "unlock cookie")
+	assert.True(t, cookie.HttpOnly, "unlock cookie")
+	assert.Equal(t, target, cookie.Path, "unlock cookie")
+
+	request, err := http.NewRequest(http.MethodGet, ts.URL+target, nil)
+	require.NoError(t, err)
+	request.AddCookie(&http.Cookie{Name: cookie.Name, Value: cookie.Value})
+	res, err = client.Do(request)
+	require.NoError(t, err)
+	defer closeBody(t, res)
+	assert.Equal(t, http.StatusTemporaryRedirect, res.StatusCode, "unlocked")
+	assert.Equal(t, "https://practicum.yandex.ru/", res.Header.Get("Location"), "unlocked")
+
+	for range service.MaxUnlockAttempts {
+		unlock("wrong")
+	}
+	code, header = unlock("open sesame")
+	assert.Equal(t, http.StatusTooManyRequests, code, "throttled")
+	assert.NotEmpty(t, header.Get("Retry-After"), "throttled")
+
+	spoofed, err := http.NewRequest(http.MethodPost, ts.URL+target, strings.NewReader("password=open+sesame"))
+	require.NoError(t, err)
+	spoofed.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	spoofed.Header.Set("X-Real-IP", "203.0.113.1")
+	res, err = client.Do(spoofed)
+	require.NoError(t, err)
+	defer closeBody(t, res)
+	assert.Equal(t, http.StatusTooManyRequests, res.StatusCode, "throttled despite X-Real-IP from an untrusted peer")
+}
+
+func TestHandlePreview(t *testing.T) {
+	storage, err := storage.NewMemoryStorage(context.Background())
+	defer requireNoError(t, storage.Close)
+	require.NoError(t, err)
+	cfg := config.New(
+		config.WithValidateResponses(true),
+		config.WithAppEnv("testing"),
+		config.WithServerAddress(config.NetAddress{Host: "localhost", Port: 8080}),
+		config.WithBaseURL(config.BaseURL{Scheme: "http://", Address: config.NetAddress{Host: "localhost", Port: 8080}}),
+	)
+	shortener := service.NewShortener(storage, storage, storage, storage, cfg.BaseURL.String())
+	log, err := logger.New("testing")
+	require.NoError(t, err)
+	handler := NewURLHandler(shortener, cfg, log)
+	ts := httptest.NewServer(NewRouter(handler, cfg, middleware.NewHMACSigner(cfg.JWTSecret), log))
+	defer ts.Close()
+
+	client := ts.Client()
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	shorten := func(req models.ShortenRequest) string {
+		body, marshalErr := json.Marshal(req)
+		require.NoError(t, marshalErr)
+		r, postErr := client.Post(ts.URL+"/api/shorten", "application/json", bytes.NewReader(body))
+		require.NoError(t, postErr)
+		defer closeBody(t, r)
+		require.Equal(t, http.StatusCreated, r.StatusCode)
+		var created models.ShortenResponse
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&created))
+		return strings.TrimPrefix(created.Result, cfg.BaseURL.String())
+	}
+	get := func(path string) (int, http.Header, string) {
+		r, getErr := client.Get(ts.URL + path)
+		require.NoError(t, getErr)
+		defer closeBody(t, r)
+		page, readErr := io.ReadAll(r.Body)
+		require.NoError(t, readErr)
+		return r.StatusCode, r.Header, string(page)
+	}
+
+	plain := shorten(models.ShortenRequest{URL: "https://example.com/?q=<script>"})
+	code, header, _ := get(plain)
+	assert.Equal(t, http.StatusTemporaryRedirect, code, "plain link")
+	assert.Equal(t, "https://example.com/?q=<script>", header.Get("Location"), "plain link")
+
+	code, header, page := get(plain + "+")
+	assert.Equal(t, http.StatusOK, code, "preview")
+	assert.Equal(t, "text/html; charset=utf-8", header.Get("Content-Type"), "preview")
+	assert.Contains(t, page, "<title>example.com</title>", "preview")
+	assert.Contains(t, page, "https://example.com/?q=&lt;script&gt;", "preview escapes the destination")
+	assert.NotContains(t, page, "<script>", "preview escapes the destination")
+
+	require.NoError(t, storage.Save(context.Background(), models.URL{
+		ShortURL: "titled", OriginalURL: "https://example.com/docs", URLMetadata: models.URLMetadata{Title: "Docs & <guides>"},
+	}))
+	code, _, page = get("/titled+")
+	assert.Equal(t, http.StatusOK, code, "titled preview")
+	assert.Contains(t, page, "<title>Docs &amp; &lt;guides&gt;</title>", "preview shows the title of the link")
+	assert.NotContains(t, page, "<title>example.com</title>", "preview shows the title of the link")
+
+	interstitial := shorten(models.ShortenRequest{URL: "https://example.com/?q=<script>", Interstitial: true})
+	assert.NotEqual(t, plain, interstitial, "interstitial links do not reuse plain ones")
+	code, header, page = get(interstitial)
+	assert.Equal(t, http.StatusOK, code, "interstitial")
+	assert.Empty(t, header.Get("Location"), "interstitial")
+	assert.Contains(t, page, "Continue", "interstitial")
+
+	protected := shorten(models.ShortenRequest{URL: "https://example.com/secret", Password: "open sesame"})
+	code, _, page = get(protected + "+")
+	assert.Equal(t, http.StatusOK, code, "protected preview")
+	assert.Contains(t, page, `name="password"`, "protected preview")
+	assert.NotContains(t, page, "https://example.com/secret", "protected preview")
+
+	code, _, _ = get("/unknown+")
+	assert.Equal(t, http.StatusNotFound, code, "unknown")
+}
+
+func TestHandleRedirectCode(t *testing.T) {
+	storage, err := storage.NewMemoryStorage(context.Background())
+	defer requireNoError(t, storage.Close)
+	require.NoError(t, err)
+	cfg := config.New(
+		config.WithValidateResponses(true),
+		config.WithAppEnv("testing"),
+		config.WithServerAddress(config.NetAddress{Host: "localhost", Port: 8080}),
+		config.WithBaseURL(config.BaseURL{Scheme: "http://", Address: config.NetAddress{Host: "localhost", Port: 8080}}),
+		config.WithRedirectCode(http.StatusFound),
+	)
+	defer config.New(config.WithRedirectCode(http.StatusTemporaryRedirect))
+	shortener := service.NewShortener(storage, storage, storage, storage, cfg.BaseURL.String(), service.WithUpdater(storage))
+	log, err := logger.New("testing")
+	require.NoError(t, err)
+	handler := NewURLHandler(shortener, cfg, log)
+	signer := middleware.NewHMACSigner(cfg.JWTSecret)
+	ts := httptest.NewServer(NewRouter(handler, cfg, signer, log))
+	defer ts.Close()
+	cookie, err := middleware.BuildAuthCookie(signer, "ffffffff-ffff-ffff-ffff-ffffffffffff")
+	require.NoError(t, err)
+
+	client := ts.Client()
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	do := func(method string, path string, body any) (int, []byte) {
+		data, marshalErr := json.Marshal(body)
+		require.NoError(t, marshalErr)
+		req, reqErr := http.NewRequest(method, ts.URL+path, bytes.NewReader(data))
+		require.NoError(t, reqErr)
+		req.AddCookie(cookie)
+		r, doErr := client.Do(req)
+		require.NoError(t, doErr)
+		defer closeBody(t, r)
+		respBody, readErr := io.ReadAll(r.Body)
+		require.NoError(t, readErr)
+		return r.StatusCode, respBody
+	}
+	shorten := func(req models.ShortenRequest) (int, string) {
+		code, body := do(http.MethodPost, "/api/shorten", req)
+		var created models.ShortenResponse
+		if code == http.StatusCreated {
+			require.NoError(t, json.Unmarshal(body, &created))
+		}
+		return code, strings.TrimPrefix(created.Result, cfg.BaseURL.String())
+	}
+	redirect := func(path string) (int, string) {
+		r, getErr := client.Get(ts.URL + path)
+		require.NoError(t, getErr)
+		defer closeBody(t, r)
+		return r.StatusCode, r.Header.Get("Location")
+	}
+
+	_, plain := shorten(models.ShortenRequest{URL: "https://example.com/"})
+	code, location := redirect(plain)
+	assert.Equal(t, http.StatusFound, code, "configured default")
+	assert.Equal(t, "https://example.com/", location, "configured default")
+
+	_, permanent := shorten(models.ShortenRequest{URL: "https://example.com/", RedirectCode: http.StatusMovedPermanently})
+	assert.NotEqual(t, plain, permanent, "custom codes do not reuse plain links")
+	code, _ = redirect(permanent)
+	assert.Equal(t, http.StatusMovedPermanently, code, "per-link code")
+
+	code, _ = shorten(models.ShortenRequest{URL: "https://example.com/", RedirectCode: http.StatusOK})
+	assert.Equal(t, http.StatusBadRequest, code, "unsupported code")
+
+	code, _ = do(http.MethodPatch, "/api/user/urls"+permanent, models.UpdateURLRequest{RedirectCode: http.StatusPermanentRedirect})
+	assert.Equal(t, http.StatusOK, code, "update")
+	code, location = redirect(permanent)
+	assert.Equal(t, http.StatusPermanentRedirect, code, "updated code")
+	assert.Equal(t, "https://example.com/", location, "updated code keeps destination")
+}
+
+func TestHandleCustomDomain(t *testing.T) {
+	const userID = "ffffffff-ffff-ffff-ffff-ffffffffffff"
+	ctx := context.Background()
+	storage, err := storage.NewMemoryStorage(ctx)
+	defer requireNoError(t, storage.Close)
+	require.NoError(t, err)
+	require.NoError(t, storage.SaveDomain(ctx, models.Domain{Name: "go.brand.com", UserID: userID, VerificationToken: "token"}))
+	require.NoError(t, storage.VerifyDomain(ctx, userID, "go.brand.com", time.Now()))
+	cfg := config.New(
+		config.WithValidateResponses(true),
+		config.WithAppEnv("testing"),
+		config.WithBaseURL(config.BaseURL{Scheme: "http://", Address: config.NetAddress{Host: "localhost", Port: 8080}}),
+	)
+	shortener := service.NewShortener(storage, storage, storage, storage, cfg.BaseURL.String(),
+		service.WithDomains(storage, nil))
+	log, err := logger.New("testing")
+	require.NoError(t, err)
+	signer := middleware.NewHMACSigner(cfg.JWTSecret)
+	ts := httptest.NewServer(NewRouter(NewURLHandler(shortener, cfg, log), cfg, signer, log))
+	defer ts.Close()
+	cookie, err := middleware.BuildAuthCookie(signer, userID)
+	require.NoError(t, err)
+
+	client := ts.Client()
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	request, err := http.NewRequest(http.MethodPost, ts.URL+"/api/v2/links",
+		strings.NewReader(`{"url":"https://brand.com/launch","domain":"go.brand.com"}`))
+	require.NoError(t, err)
+	request.AddCookie(cookie)
+	res, err := client.Do(request)
+	require.NoError(t, err)
+	defer closeBody(t, res)
+	require.Equal(t, http.StatusCreated, res.StatusCode)
+	var link models.Link
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&link))
+	assert.Equal(t, "go.brand.com", link.Domain)
+	domain, code := models.SplitLinkKey(link.ID)
+	assert.Equal(t, "go.brand.com", domain)
+	assert.Equal(t, "http://go.brand.com/"+code, link.ShortURL)
+
+	request, err = http.NewRequest(http.MethodPost, ts.URL+"/api/v2/links",
+		strings.NewReader(`{"url":"https://brand.com/launch","domain":"brand.link"}`))
+	require.NoError(t, err)
+	request.AddCookie(cookie)
+	res, err = client.Do(request)
+	require.NoError(t, err)
+	defer closeBody(t, res)
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode, "unverified domain")
+
+	tests := []struct {
+		name       string
+		host       string
+		target     string
+		statusCode int
+	}{
+		{name: "custom domain", host: "go.brand.com", target: "/" + code, statusCode: http.StatusTemporaryRedirect},
+		{name: "default domain", host: "localhost:8080", target: "/" + code, statusCode: http.StatusNotFound},
+		{name: "key on the default domain", host: "localhost:8080", target: "/" + link.ID, statusCode: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		request, err := http.NewRequest(http.MethodGet, ts.URL+tt.target, nil)
+		require.NoError(t, err, tt.name)
+		request.Host = tt.host
+
+		res, err := client.Do(request)
+		require.NoError(t, err, tt.name)
+		defer closeBody(t, res, tt.name)
+		assert.Equal(t, tt.statusCode, res.StatusCode, tt.name)
+	}
+}
+
+func TestHandleWorkspace(t *testing.T) {
+	const (
+		ownerID  = "11111111-1111-1111-1111-111111111111"
+		viewerID = "22222222-2222-2222-2222-222222222222"
+	)
+	ctx := context.Background()
+	storage, err := storage.NewMemoryStorage(ctx)
+	defer requireNoError(t, storage.Close)
+	require.NoError(t, err)
+	cfg := config.New(config.WithValidateResponses(true), config.WithAppEnv("testing"))
+	shortener := service.NewShortener(storage, storage, storage, storage, cfg.BaseURL.String(),
+		service.WithWorkspaces(storage))
+	log, err := logger.New("testing")
+	require.NoError(t, err)
+	signer := middleware.NewHMACSigner(cfg.JWTSecret)
+	ts := httptest.NewServer(NewRouter(NewURLHandler(shortener, cfg, log), cfg, signer, log))
+	defer ts.Close()
+
+	// do sends a request of the user in the workspace, if any, decodes the response into out, if any,
+	// and returns the status of the response.
+	do := func(userID string, method string, target string, workspaceID string, body string, out any) int {
+		request, err := http.NewRequest(method, ts.URL+target, strings.NewReader(body))
+		require.NoError(t, err)
+		cookie, err := middleware.BuildAuthCookie(signer, userID)
+		require.NoError(t, err)
+		request.AddCookie(cookie)
+		if workspaceID != "" {
+			request.Header.Set(WorkspaceHeader, workspaceID)
+		}
+		res, err := ts.Client().Do(request)
+		require.NoError(t, err)
+		defer closeBody(t, res)
+		if out != nil {
+			require.NoError(t, json.NewDecoder(res.Body).Decode(out))
+		}
+		return res.StatusCode
+	}
+
+	var workspace models.Workspace
+	require.Equal(t, http.StatusCreated, do(ownerID, http.MethodPost, "/api/user/workspaces", "", `{"name":"Campaigns"}`, &workspace))
+	assert.Equal(t, models.RoleOwner, workspace.Role)
+	members := "/api/user/workspaces/" + workspace.ID + "/members/"
+	require.Equal(t, http.StatusOK, do(ownerID, http.MethodPut, members+viewerID, "", `{"role":"viewer"}`, nil))
+
+	var link models.Link
+	status := do(ownerID, http.MethodPost, "/api/v2/links", workspace.ID, `{"url":"https://example.com/campaign"}`, &link)
+	require.Equal(t, http.StatusCreated, status)
+	var list models.LinkList
+	require.Equal(t, http.StatusOK, do(viewerID, http.MethodGet, "/api/v2/links", workspace.ID, "", &list))
+	assert.Equal(t, 1, list.Count)
+
+	status = do(viewerID, http.MethodDelete, "/api/v2/links", workspace.ID, `{"ids":["`+link.ID+`"]}`, nil)
+	assert.Equal(t, http.StatusForbidden, status, "viewers cannot delete")
+	require.Equal(t, http.StatusOK, do(viewerID, http.MethodGet, "/api/v2/links", "", "", &list))
+	assert.Zero(t, list.Count, "the links of the workspace are not the viewer's own")
+	assert.Equal(t, http.StatusConflict, do(ownerID, http.MethodDelete, members+ownerID, "", "", nil), "last owner")
+}
+
+func TestHandleQuota(t *testing.T) {
+	const userID = "11111111-1111-1111-1111-111111111111"
+	ctx := context.Background()
+	storage, err := storage.NewMemoryStorage(ctx)
+	defer requireNoError(t, storage.Close)
+	require.NoError(t, err)
+	cfg := *config.New(config.WithValidateResponses(true), config.WithAppEnv("testing"))
+	cfg.TrustedSubnet = "192.168.0.0/24"
+	shortener := service.NewShortener(storage, storage, storage, storage, cfg.BaseURL.String(),
+		service.WithQuotas(storage, []models.Plan{{Name: "free", MaxActiveLinks: 1}, {Name: "pro"}}, "free"))
+	log, err := logger.New("testing")
+	require.NoError(t, err)
+	signer := middleware.NewHMACSigner(cfg.JWTSecret)
+	ts := httptest.NewServer(NewRouter(NewURLHandler(shortener, &cfg, log), &cfg, signer, log))
+	defer ts.Close()
+
+	// do sends a request of the user from the trusted subnet, decodes the response into out
+	// and returns the status of the response.
+	do := func(method string, target string, body string, out any) int {
+		request, err := http.NewRequest(method, ts.URL+target, strings.NewReader(body))
+		require.NoError(t, err)
+		cookie, err := middleware.BuildAuthCookie(signer, userID)
+		require.NoError(t, err)
+		request.AddCookie(cookie)
+		request.Header.Set("X-Real-IP", "192.168.0.1")
+		res, err := ts.Client().Do(request)
+		require.NoError(t, err)
+		defer closeBody(t, res)
+		require.NoError(t, json.NewDecoder(res.Body).Decode(out))
+		return res.StatusCode
+	}
+
+	var quota models.Quota
+	require.Equal(t, http.StatusOK, do(http.MethodGet, "/api/user/quota", "", &quota))
+	assert.Equal(t, "free", quota.Plan)
+	assert.Equal(t, 1, quota.MaxActiveLinks)
+
+	var link models.Link
+	require.Equal(t, http.StatusCreated, do(http.MethodPost, "/api/v2/links", `{"url":"https://example.com/1"}`, &link))
+	var p problem.Problem
+	require.Equal(t, http.StatusForbidden, do(http.MethodPost, "/api/v2/links", `{"url":"https://example.com/2"}`, &p))
+	assert.Equal(t, problem.CodeQuotaExceeded, p.Code)
+
+	require.Equal(t, http.StatusBadRequest, do(http.MethodPut, "/api/internal/users/"+userID+"/plan", `{"plan":"gold"}`, &p))
+	require.Equal(t, http.StatusOK, do(http.MethodPut, "/api/internal/users/"+userID+"/plan", `{"plan":"pro"}`, &quota))
+	assert.Equal(t, "pro", quota.Plan)
+	assert.Equal(t, 1, quota.ActiveLinks)
+	require.Equal(t, http.StatusCreated, do(http.MethodPost, "/api/v2/links", `{"url":"https://example.com/2"}`, &link))
+}
+
+func TestHandleAudit(t *testing.T) {
+	const userID = "11111111-1111-1111-1111-111111111111"
+	ctx := context.Background()
+	storage, err := storage.NewMemoryStorage(ctx)
+	defer requireNoError(t, storage.Close)
+	require.NoError(t, err)
+	cfg := *config.New(config.WithValidateResponses(true), config.WithAppEnv("testing"))
+	cfg.TrustedSubnet = "192.168.0.0/24"
+	cfg.TrustedProxies = []string{"127.0.0.1/32"}
+	shortener := service.NewShortener(storage, storage, storage, storage, cfg.BaseURL.String(),
+		service.WithUpdater(storage), service.WithAuditLog(storage, time.Hour))
+	log, err := logger.New("testing")
+	require.NoError(t, err)
+	signer := middleware.NewHMACSigner(cfg.JWTSecret)
+	ts := httptest.NewServer(NewRouter(NewURLHandler(shortener, &cfg, log), &cfg, signer, log))
+	defer ts.Close()
+
+	// do sends a request of the user from the trusted subnet, decodes the response into out
+	// and returns the status of the response.
+	do := func(method string, target string, body string, out any) int {
+		request, err := http.NewRequest(method, ts.URL+target, strings.NewReader(body))
+		require.NoError(t, err)
+		cookie, err := middleware.BuildAuthCookie(signer, userID)
+		require.NoError(t, err)
+		request.AddCookie(cookie)
+		request.Header.Set("X-Real-IP", "192.168.0.1")
+		request.Header.Set("User-Agent", "audit-test")
+		res, err := ts.Client().Do(request)
+		require.NoError(t, err)
+		defer closeBody(t, res)
+		require.NoError(t, json.NewDecoder(res.Body).Decode(out))
+		return res.StatusCode
+	}
+
+	var entries []models.AuditEntry
+	require.Equal(t, http.StatusOK, do(http.MethodGet, "/api/internal/audit", "", &entries))
+	assert.Empty(t, entries)
+
+	var link models.Link
+	require.Equal(t, http.StatusCreated, do(http.MethodPost, "/api/v2/links", `{"url":"https://example.com/1"}`, &link))
+	require.Equal(t, http.StatusOK, do(http.MethodPatch, "/api/v2/links/"+link.ID, `{"url":"https://example.com/2"}`, &link))
+
+	require.Equal(t, http.StatusOK, do(http.MethodGet, "/api/internal/audit?target="+link.ID, "", &entries))
+	require.Len(t, entries, 2)
+	assert.Equal(t, models.AuditLinkUpdated, entries[0].Action)
+	assert.Equal(t, models.AuditLinkCreated, entries[1].Action)
+	assert.Equal(t, userID, entries[0].Actor)
+	assert.Equal(t, "192.168.0.1", entries[0].IP)
+	assert.Equal(t, "audit-test", entries[0].UserAgent)
+	assert.Equal(t, models.TransportHTTP, entries[0].Transport)
+	var before models.Link
+	require.NoError(t, json.Unmarshal(entries[0].Before, &before))
+	assert.Equal(t, "https://example.com/1", before.OriginalURL)
+
+	require.Equal(t, http.StatusOK, do(http.MethodGet, "/api/internal/audit?action=link.created&limit=1", "", &entries))
+	assert.Len(t, entries, 1)
+
+	var p problem.Problem
+	require.Equal(t, http.StatusBadRequest, do(http.MethodGet, "/api/internal/audit?since=yesterday", "", &p))
+}
@@ -3,62 +3,82 @@ package middleware
 import (
 	"context"
 	"errors"
-	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
-	"github.com/golang-jwt/jwt/v4"
 	"github.com/google/uuid"
+	"github.com/grnsv/shortener/internal/api/problem"
 	"github.com/grnsv/shortener/internal/logger"
+	"github.com/grnsv/shortener/internal/models"
+	"github.com/grnsv/shortener/internal/service"
 )
 
 const cookieName = "token"
 
-var signingMethod = jwt.SigningMethodHS256
-
-// Claims represents the JWT claims used for authentication.
-type Claims struct {
-	jwt.RegisteredClaims
-}
-
 type contextKey string
 
-// UserIDContextKey is the context key for storing the user ID.
-const UserIDContextKey contextKey = "userID"
+// Context keys for storing authentication data.
+const (
+	UserIDContextKey contextKey = "userID" // user ID
+	ScopesContextKey contextKey = "scopes" // models.Scopes of the API key, absent for cookie sessions
+)
 
-// Authenticate is a middleware that authenticates users using JWT cookies.
-// It sets a user ID in the request context, generating a new one if needed.
+// Authenticate is a middleware that authenticates users using JWT cookies or API keys.
+// A request with an "Authorization: Bearer" header is authenticated by the API key only,
+// and its scopes are stored in the request context.
+// Otherwise it sets a user ID from the cookie in the request context, generating a new one if needed,
+// and reissues the cookie when the token is about to expire or was signed with a rotated-out key.
 // If authentication fails, it returns an appropriate HTTP error.
-func Authenticate(key string, logger logger.Logger) func(http.Handler) http.Handler {
+func Authenticate(signer *Signer, verifier service.APIKeyVerifier, logger logger.Logger) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if apiKey, ok := bearerToken(r.Header.Get("Authorization")); ok {
+				model, code := verifyAPIKey(r.Context(), verifier, apiKey, logger)
+				if model == nil {
+					if code == http.StatusUnauthorized {
+						w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+						problem.Error(w, r, code, problem.CodeUnauthorized, "invalid API key")
+					} else {
+						problem.Error(w, r, code, problem.CodeInternal, "")
+					}
+					return
+				}
+				ctx := context.WithValue(r.Context(), UserIDContextKey, model.UserID)
+				ctx = context.WithValue(ctx, ScopesContextKey, model.Scopes)
+				next.ServeHTTP(w, r.WithContext(ctx))
+				return
+			}
+
 			var userID string
-			claims, err := getClaims(r, key)
+			claims, err := getClaims(r, signer)
 			if err != nil {
 				logger.Debug(err)
 				userID, err = generateUserID()
 				if err != nil {
 					logger.Error(err)
-					w.WriteHeader(http.StatusInternalServerError)
+					problem.Error(w, r, http.StatusInternalServerError, problem.CodeInternal, "")
 					return
 				}
-				err = refreshCookie(w, key, userID)
+				err = refreshCookie(w, signer, userID)
 				if err != nil {
 					logger.Error(err)
-					w.WriteHeader(http.StatusInternalServerError)
+					problem.Error(w, r, http.StatusInternalServerError, problem.CodeInternal, "")
 					return
 				}
 			} else {
 				userID = claims.Subject
 				if userID == "" {
-					w.WriteHeader(http.StatusUnauthorized)
+					problem.Error(w, r, http.StatusUnauthorized, problem.CodeUnauthorized, "token has no user ID")
 					return
 				}
-				err = refreshCookie(w, key, userID)
-				if err != nil {
-					logger.Error(err)
-					w.WriteHeader(http.StatusInternalServerError)
-					return
+				if signer.NeedsRefresh(claims) {
+					err = refreshCookie(w, signer, userID)
+					if err != nil {
+						logger.Error(err)
+						problem.Error(w, r, http.StatusInternalServerError, problem.CodeInternal, "")
+						return
+					}
 				}
 			}
 
@@ -68,40 +88,65 @@ func Authenticate(key string, logger logger.Logger) func(http.Handler) http.Hand
 	}
 }
 
-func getClaims(r *http.Request, key string) (*Claims, error) {
-	cookie, err := r.Cookie(cookieName)
-	if err != nil {
-		return nil, err
+// RequireScope returns a middleware that rejects requests authenticated by an API key
+// lacking the given scope with 403 Forbidden.
+func RequireScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !HasScope(r.Context(), scope) {
+				problem.Error(w, r, http.StatusForbidden, problem.CodeInsufficientScope, "API key lacks the "+scope+" scope")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
 	}
-	if err = cookie.Valid(); err != nil {
-		return nil, err
+}
+
+// HasScope reports whether the request context grants the given scope.
+// Requests authenticated by cookie have every scope.
+func HasScope(ctx context.Context, scope string) bool {
+	scopes, ok := ctx.Value(ScopesContextKey).(models.Scopes)
+	return !ok || scopes.Allows(scope)
+}
+
+func bearerToken(header string) (string, bool) {
+	const prefix = "Bearer "
+	if len(header) < len(prefix) || !strings.EqualFold(header[:len(prefix)], prefix) {
+		return "", false
 	}
+	return strings.TrimSpace(header[len(prefix):]), true
+}
 
-	return parseClaims(cookie.Value, key)
+func verifyAPIKey(ctx context.Context, verifier service.APIKeyVerifier, apiKey string, logger logger.Logger) (*models.APIKey, int) {
+	if verifier == nil || apiKey == "" {
+		return nil, http.StatusUnauthorized
+	}
+	model, err := verifier.VerifyAPIKey(ctx, apiKey)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidAPIKey) {
+			logger.Debug(err)
+			return nil, http.StatusUnauthorized
+		}
+		logger.Error(err)
+		return nil, http.StatusInternalServerError
+	}
+	return model, http.StatusOK
 }
 
-func parseClaims(tokenStr string, key string) (*Claims, error) {
-	claims := &Claims{}
-	token, err := jwt.ParseWithClaims(tokenStr, claims,
-		func(t *jwt.Token) (interface{}, error) {
-			if t.Method == nil || t.Method.Alg() != signingMethod.Alg() {
-				return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
-			}
-			return []byte(key), nil
-		})
+func getClaims(r *http.Request, signer *Signer) (*Claims, error) {
+	cookie, err := r.Cookie(cookieName)
 	if err != nil {
 		return nil, err
 	}
-
-	if !token.Valid {
-		return nil, errors.New("token is not valid")
+	if err = cookie.Valid(); err != nil {
+		return nil, err
 	}
 
-	return claims, nil
+	return signer.Parse(cookie.Value)
 }
 
-func refreshCookie(w http.ResponseWriter, key string, userID string) error {
-	cookie, err := BuildAuthCookie(key, userID)
+func refreshCookie(w http.ResponseWriter, signer *Signer, userID string) error {
+	cookie, err := BuildAuthCookie(signer, userID)
 	if err != nil {
 		return err
 	}
@@ -111,8 +156,9 @@ func refreshCookie(w http.ResponseWriter, key string, userID string) error {
 }
 
 // BuildAuthCookie builds a new authentication cookie for the given user ID.
-func BuildAuthCookie(key string, userID string) (*http.Cookie, error) {
-	tokenString, err := BuildJWTString(key, userID)
+// The cookie expires together with the token it holds.
+func BuildAuthCookie(signer *Signer, userID string) (*http.Cookie, error) {
+	tokenString, err := BuildJWTString(signer, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -120,7 +166,7 @@ func BuildAuthCookie(key string, userID string) (*http.Cookie, error) {
 		Name:     cookieName,
 		Value:    tokenString,
 		Path:     "/",
-		Expires:  time.Now().Add(10 * 365 * 24 * time.Hour),
+		Expires:  time.Now().Add(signer.TTL()),
 		HttpOnly: true,
 		SameSite: http.SameSiteLaxMode,
 	}
@@ -128,16 +174,10 @@ func BuildAuthCookie(key string, userID string) (*http.Cookie, error) {
 	return cookie, nil
 }
 
-// BuildJWTString creates a signed JWT string for the given user ID using the provided key.
+// BuildJWTString creates a signed JWT string for the given user ID using the provided signer.
 // It returns the signed JWT as a string.
-func BuildJWTString(key string, userID string) (string, error) {
-	token := jwt.NewWithClaims(signingMethod, Claims{
-		RegisteredClaims: jwt.RegisteredClaims{
-			Subject: userID,
-		},
-	})
-
-	return token.SignedString([]byte(key))
+func BuildJWTString(signer *Signer, userID string) (string, error) {
+	return signer.Sign(userID)
 }
 
 func generateUserID() (string, error) {
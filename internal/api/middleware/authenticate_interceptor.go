@@ -2,17 +2,48 @@ package middleware
 
 import (
 	"context"
+	"net/http"
 
 	"github.com/grnsv/shortener/internal/logger"
+	"github.com/grnsv/shortener/internal/models"
+	"github.com/grnsv/shortener/internal/service"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 )
 
-// GRPCAuthenticateInterceptor returns a gRPC unary interceptor that authenticates users using JWT from metadata.
+// Scopes of gRPC methods that are not API key scopes.
+const (
+	scopePublic = "*" // available to any API key
+	scopeAll    = ""  // available only to API keys without scopes, which grant everything
+)
+
+// grpcMethodScopes maps gRPC methods to the API key scope they require.
+// Methods not listed here are denied to API keys.
+var grpcMethodScopes = map[string]string{
+	"/shortener.Shortener/ExpandURL":     scopePublic,
+	"/shortener.Shortener/PingDB":        scopePublic,
+	"/shortener.Shortener/ShortenURL":    models.ScopeShorten,
+	"/shortener.Shortener/ShortenBatch":  models.ScopeShorten,
+	"/shortener.Shortener/GetURLs":       models.ScopeRead,
+	"/shortener.Shortener/SearchURLs":    models.ScopeRead,
+	"/shortener.Shortener/DeleteURLs":    models.ScopeDelete,
+	"/shortener.Shortener/RestoreURL":    models.ScopeDelete,
+	"/shortener.Shortener/UpdateURL":     models.ScopeUpdate,
+	"/shortener.Shortener/GetURLHistory": models.ScopeRead,
+	"/shortener.Shortener/GetQRCode":     models.ScopeRead,
+	"/shortener.Shortener/GetStats":      scopeAll,
+	"/shortener.Shortener/CreateAPIKey":  models.ScopeKeys,
+	"/shortener.Shortener/GetAPIKeys":    models.ScopeKeys,
+	"/shortener.Shortener/RevokeAPIKey":  models.ScopeKeys,
+}
+
+// GRPCAuthenticateInterceptor returns a gRPC unary interceptor that authenticates users using JWT or API key from metadata.
+// An "authorization: Bearer" entry is checked as an API key and must be valid and have the scope the method requires.
 // If token is missing or invalid, generates a new userID and continues (like HTTP middleware).
-func GRPCAuthenticateInterceptor(key string, logger logger.Logger) grpc.UnaryServerInterceptor {
+// A valid token is reissued when it is about to expire or was signed with a rotated-out key.
+func GRPCAuthenticateInterceptor(signer *Signer, verifier service.APIKeyVerifier, logger logger.Logger) grpc.UnaryServerInterceptor {
 	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
 		var token string
 		md, ok := metadata.FromIncomingContext(ctx)
@@ -21,10 +52,15 @@ func GRPCAuthenticateInterceptor(key string, logger logger.Logger) grpc.UnarySer
 			if len(values) > 0 {
 				token = values[0]
 			}
+			if values = md.Get("authorization"); len(values) > 0 {
+				if apiKey, isBearer := bearerToken(values[0]); isBearer {
+					return handleAPIKey(ctx, verifier, apiKey, logger, req, info, handler)
+				}
+			}
 		}
 
 		var userID string
-		claims, err := parseClaims(token, key)
+		claims, err := signer.Parse(token)
 		if err != nil {
 			logger.Debug(err)
 			userID, err = generateUserID()
@@ -32,7 +68,7 @@ func GRPCAuthenticateInterceptor(key string, logger logger.Logger) grpc.UnarySer
 				logger.Error(err)
 				return nil, status.Error(codes.Internal, "Failed to generate userID")
 			}
-			token, err = BuildJWTString(key, userID)
+			token, err = BuildJWTString(signer, userID)
 			if err != nil {
 				logger.Error(err)
 				return nil, status.Error(codes.Internal, "Failed to build token")
@@ -42,6 +78,13 @@ func GRPCAuthenticateInterceptor(key string, logger logger.Logger) grpc.UnarySer
 			if userID == "" {
 				return nil, status.Error(codes.Unauthenticated, "Empty userID")
 			}
+			if signer.NeedsRefresh(claims) {
+				token, err = BuildJWTString(signer, userID)
+				if err != nil {
+					logger.Error(err)
+					return nil, status.Error(codes.Internal, "Failed to build token")
+				}
+			}
 		}
 
 		ctx = context.WithValue(ctx, UserIDContextKey, userID)
@@ -59,3 +102,35 @@ func GRPCAuthenticateInterceptor(key string, logger logger.Logger) grpc.UnarySer
 		return resp, nil
 	}
 }
+
+func handleAPIKey(
+	ctx context.Context,
+	verifier service.APIKeyVerifier,
+	apiKey string,
+	logger logger.Logger,
+	req any,
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (any, error) {
+	model, code := verifyAPIKey(ctx, verifier, apiKey, logger)
+	if model == nil {
+		if code == http.StatusUnauthorized {
+			return nil, status.Error(codes.Unauthenticated, "Invalid API key")
+		}
+		return nil, status.Error(codes.Internal, "Failed to verify API key")
+	}
+
+	scope, ok := grpcMethodScopes[info.FullMethod]
+	switch {
+	case !ok:
+		return nil, status.Error(codes.PermissionDenied, "Method is not available to API keys")
+	case scope == scopeAll && !model.Scopes.Allows(scope):
+		return nil, status.Error(codes.PermissionDenied, "Method requires an API key without scopes")
+	case scope != scopePublic && !model.Scopes.Allows(scope):
+		return nil, status.Error(codes.PermissionDenied, "API key lacks scope "+scope)
+	}
+
+	ctx = context.WithValue(ctx, UserIDContextKey, model.UserID)
+	ctx = context.WithValue(ctx, ScopesContextKey, model.Scopes)
+	return handler(ctx, req)
+}
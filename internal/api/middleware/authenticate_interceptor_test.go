@@ -0,0 +1,18 @@
+package middleware_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/grnsv/shortener/internal/api/middleware"
+	"github.com/grnsv/shortener/internal/api/pb"
+)
+
+func TestGRPCMethodScopes(t *testing.T) {
+	for _, method := range pb.Shortener_ServiceDesc.Methods {
+		fullMethod := "/" + pb.Shortener_ServiceDesc.ServiceName + "/" + method.MethodName
+		_, ok := middleware.GRPCMethodScopes[fullMethod]
+		assert.True(t, ok, "%s has no API key scope", fullMethod)
+	}
+}
@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"context"
+	"io"
+	"net/http"
+)
+
+// bodyLimitContextKey holds the *int64 limit shared by the readers of a request body.
+const bodyLimitContextKey contextKey = "bodyLimit"
+
+// limitedBody reads at most *limit bytes of a body and then fails with *http.MaxBytesError,
+// which handlers answer with 413 Payload Too Large. The limit is read on every call,
+// so LimitBody can change it until the body is read.
+type limitedBody struct {
+	io.ReadCloser
+	limit *int64
+	read  int64
+	err   error
+}
+
+// Read reads from the body until the limit is exceeded.
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.err != nil {
+		return 0, b.err
+	}
+	remaining := max(*b.limit-b.read, 0)
+	if int64(len(p)) > remaining+1 {
+		p = p[:remaining+1]
+	}
+	n, err := b.ReadCloser.Read(p)
+	if int64(n) > remaining {
+		b.read += remaining
+		b.err = &http.MaxBytesError{Limit: *b.limit}
+		return int(remaining), b.err
+	}
+	b.read += int64(n)
+	return n, err
+}
+
+// limitBody wraps the body of r with a limitedBody sharing the limit stored in the context of r.
+func limitBody(r *http.Request, body io.ReadCloser) io.ReadCloser {
+	limit, ok := r.Context().Value(bodyLimitContextKey).(*int64)
+	if !ok {
+		return body
+	}
+	return &limitedBody{ReadCloser: body, limit: limit}
+}
+
+// withBodyLimit stores the limit of request bodies in the context of r.
+func withBodyLimit(r *http.Request, limit int64) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), bodyLimitContextKey, &limit))
+}
+
+// LimitBody is a middleware that changes the maximum size of request bodies set by WithCompressing
+// for the routes it is applied to, e.g. to accept larger uploads.
+func LimitBody(limit int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if current, ok := r.Context().Value(bodyLimitContextKey).(*int64); ok {
+				*current = limit
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"context"
+	"net"
+	"net/http"
+)
+
+// clientIPContextKey holds the IP address of the client resolved by ClientIP.
+const clientIPContextKey contextKey = "clientIP"
+
+// ClientIP returns a middleware resolving the IP address of the client once for the handlers, see ClientIPFrom.
+// The address is taken from the X-Real-IP header only for connections from one of the trusted proxies,
+// subnets in CIDR notation, which set it; otherwise it is the address of the connection, so clients
+// cannot choose it by sending the header themselves.
+func ClientIP(trustedProxies []string) func(http.Handler) http.Handler {
+	proxies := make([]*net.IPNet, 0, len(trustedProxies))
+	for _, proxy := range trustedProxies {
+		if _, subnet, err := net.ParseCIDR(proxy); err == nil {
+			proxies = append(proxies, subnet)
+		}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip := remoteIP(r)
+			if realIP := net.ParseIP(r.Header.Get("X-Real-IP")); realIP != nil && trusted(proxies, net.ParseIP(ip)) {
+				ip = realIP.String()
+			}
+
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), clientIPContextKey, ip)))
+		})
+	}
+}
+
+// ClientIPFrom returns the IP address of the client resolved by ClientIP.
+func ClientIPFrom(ctx context.Context) string {
+	ip, _ := ctx.Value(clientIPContextKey).(string)
+	return ip
+}
+
+// remoteIP returns the IP address of the connection of the request.
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// trusted reports whether ip belongs to one of the proxies.
+func trusted(proxies []*net.IPNet, ip net.IP) bool {
+	for _, proxy := range proxies {
+		if proxy.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
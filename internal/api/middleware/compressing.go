@@ -2,126 +2,389 @@ package middleware
 
 import (
 	"compress/gzip"
+	"errors"
 	"io"
+	"mime"
 	"net/http"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
 
+	"github.com/andybalholm/brotli"
+	"github.com/grnsv/shortener/internal/api/problem"
+	"github.com/grnsv/shortener/internal/config"
 	"github.com/grnsv/shortener/internal/logger"
+	"github.com/klauspost/compress/zstd"
 )
 
-var supportedContentTypes = [2]string{"application/json", "text/html"}
+// DefaultCompressMinSize is the size below which responses are sent uncompressed.
+const DefaultCompressMinSize = 1024
 
-func isSupportedContentType(contentType string) bool {
-	for _, v := range supportedContentTypes {
-		if contentType == v {
-			return true
+// zstdMaxWindow bounds the memory a zstd request body can make the decoder allocate.
+const zstdMaxWindow = 8 << 20
+
+// brotliLevel trades compression ratio for speed, as responses are compressed on the fly.
+const brotliLevel = 4
+
+// Content codings in order of preference when a client accepts several of them equally.
+var supportedEncodings = []string{"br", "zstd", "gzip"}
+
+// compressibleContentTypes are the media types compressed besides text/* and */*+json.
+var compressibleContentTypes = []string{"application/json", "application/x-ndjson", "application/javascript", "image/svg+xml"}
+
+// errUnsupportedEncoding is returned for request bodies in a coding other than the supported ones.
+var errUnsupportedEncoding = errors.New("unsupported content encoding")
+
+func isCompressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return strings.HasPrefix(mediaType, "text/") || strings.HasSuffix(mediaType, "+json") ||
+		slices.Contains(compressibleContentTypes, mediaType)
+}
+
+// encoder is a pooled compressor writing to a reusable destination.
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// decoder is a pooled decompressor reading from a reusable source.
+type decoder interface {
+	io.Reader
+	Reset(r io.Reader) error
+}
+
+var encoders = map[string]*sync.Pool{
+	"br": {New: func() any {
+		return brotli.NewWriterLevel(io.Discard, brotliLevel)
+	}},
+	"zstd": {New: func() any {
+		// The options are valid, so NewWriter does not fail.
+		w, _ := zstd.NewWriter(io.Discard, zstd.WithEncoderConcurrency(1))
+		return w
+	}},
+	"gzip": {New: func() any {
+		return gzip.NewWriter(io.Discard)
+	}},
+}
+
+var decoders = map[string]*sync.Pool{
+	"br": {New: func() any {
+		return brotli.NewReader(nil)
+	}},
+	"zstd": {New: func() any {
+		// The options are valid, so NewReader does not fail.
+		r, _ := zstd.NewReader(nil, zstd.WithDecoderConcurrency(1), zstd.WithDecoderMaxWindow(zstdMaxWindow))
+		return r
+	}},
+	"gzip": {New: func() any {
+		return new(gzip.Reader)
+	}},
+}
+
+// negotiateEncoding picks the supported coding with the highest quality value in an Accept-Encoding header,
+// or an empty string if the client prefers identity or accepts none of them.
+func negotiateEncoding(header string) string {
+	qualities := make(map[string]float64)
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(part, ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding == "" {
+			continue
+		}
+		if coding == "x-gzip" {
+			coding = "gzip"
+		}
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			name, value, _ := strings.Cut(param, "=")
+			if strings.EqualFold(strings.TrimSpace(name), "q") {
+				parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+				if err != nil || parsed < 0 || parsed > 1 {
+					parsed = 0
+				}
+				q = parsed
+			}
 		}
+		qualities[coding] = q
 	}
 
-	return false
+	best, bestQ := "", 0.0
+	for _, coding := range supportedEncodings {
+		q, ok := qualities[coding]
+		if !ok {
+			q = qualities["*"]
+		}
+		if q > bestQ {
+			best, bestQ = coding, q
+		}
+	}
+	if q, ok := qualities["identity"]; ok && q > bestQ {
+		return ""
+	}
+	return best
 }
 
-// compressWriter wraps http.ResponseWriter and gzip.Writer to provide gzip compression for responses.
+// compressWriter compresses responses of compressible content types with the negotiated coding.
+// It buffers the start of the response until it is at least minSize bytes, so short responses
+// are sent as is, and adds Vary: Accept-Encoding to all responses that could be compressed.
 type compressWriter struct {
-	w  http.ResponseWriter
-	zw *gzip.Writer
+	http.ResponseWriter
+	encoding string
+	minSize  int
+	status   int
+	buf      []byte
+	started  bool
+	enc      encoder
 }
 
-// newCompressWriter creates a new compressWriter for the given http.ResponseWriter.
-func newCompressWriter(w http.ResponseWriter) *compressWriter {
-	return &compressWriter{
-		w:  w,
-		zw: gzip.NewWriter(w),
-	}
+func newCompressWriter(w http.ResponseWriter, encoding string, minSize int) *compressWriter {
+	return &compressWriter{ResponseWriter: w, encoding: encoding, minSize: minSize}
 }
 
-// Header returns the header map that will be sent by WriteHeader.
-func (c *compressWriter) Header() http.Header {
-	return c.w.Header()
+// WriteHeader records the status code; it is sent with the first part of the body.
+// Responses without a body, and all responses when no coding was negotiated, are sent at once.
+func (c *compressWriter) WriteHeader(statusCode int) {
+	if c.started || c.status != 0 {
+		return
+	}
+	if statusCode < http.StatusOK {
+		c.ResponseWriter.WriteHeader(statusCode)
+		return
+	}
+	c.status = statusCode
+	if c.encoding == "" || statusCode == http.StatusNoContent || statusCode == http.StatusNotModified {
+		_ = c.start(false)
+	}
 }
 
-// Write writes compressed data to the underlying gzip.Writer.
+// Write buffers the body until the compression decision can be made and writes it afterwards.
 func (c *compressWriter) Write(p []byte) (int, error) {
-	return c.zw.Write(p)
+	if c.started {
+		if c.enc != nil {
+			return c.enc.Write(p)
+		}
+		return c.ResponseWriter.Write(p)
+	}
+	if c.encoding == "" {
+		if err := c.start(false); err != nil {
+			return 0, err
+		}
+		return c.Write(p)
+	}
+
+	c.buf = append(c.buf, p...)
+	if len(c.buf) >= c.minSize {
+		if err := c.start(false); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
 }
 
-// WriteHeader sends an HTTP response header with the provided status code and sets Content-Encoding if appropriate.
-func (c *compressWriter) WriteHeader(statusCode int) {
-	if statusCode < 300 {
-		c.w.Header().Set("Content-Encoding", "gzip")
+// start sends the header, compressing the body if it is compressible and, for a complete body,
+// at least minSize bytes, and writes the buffered part of the body.
+func (c *compressWriter) start(complete bool) error {
+	c.started = true
+	if c.status == 0 {
+		c.status = http.StatusOK
+	}
+
+	h := c.Header()
+	if h.Get("Content-Type") == "" && len(c.buf) > 0 {
+		h.Set("Content-Type", http.DetectContentType(c.buf))
+	}
+	compressible := isCompressible(h.Get("Content-Type"))
+	if compressible {
+		h.Add("Vary", "Accept-Encoding")
 	}
-	c.w.WriteHeader(statusCode)
+	if compressible && c.encoding != "" && h.Get("Content-Encoding") == "" &&
+		c.status != http.StatusNoContent && c.status != http.StatusNotModified &&
+		(!complete || len(c.buf) >= c.minSize) {
+		c.enc = encoders[c.encoding].Get().(encoder)
+		c.enc.Reset(c.ResponseWriter)
+		h.Set("Content-Encoding", c.encoding)
+		h.Del("Content-Length")
+		if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			h.Set("ETag", "W/"+etag)
+		}
+	}
+	c.ResponseWriter.WriteHeader(c.status)
+
+	buf := c.buf
+	c.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	_, err := c.Write(buf)
+	return err
+}
+
+// FlushError starts the response if it has not started yet and flushes the compressed data to the client.
+func (c *compressWriter) FlushError() error {
+	if !c.started {
+		if err := c.start(false); err != nil {
+			return err
+		}
+	}
+	if c.enc != nil {
+		if err := c.enc.Flush(); err != nil {
+			return err
+		}
+	}
+	return http.NewResponseController(c.ResponseWriter).Flush()
 }
 
-// Close closes the underlying gzip.Writer.
+// Flush implements http.Flusher.
+func (c *compressWriter) Flush() {
+	_ = c.FlushError()
+}
+
+// Unwrap returns the underlying http.ResponseWriter for http.ResponseController.
+func (c *compressWriter) Unwrap() http.ResponseWriter {
+	return c.ResponseWriter
+}
+
+// Close sends the rest of the response and returns the encoder to its pool.
 func (c *compressWriter) Close() error {
-	return c.zw.Close()
+	if !c.started {
+		if c.status == 0 && len(c.buf) == 0 {
+			return nil
+		}
+		if err := c.start(true); err != nil {
+			return err
+		}
+	}
+	if c.enc == nil {
+		return nil
+	}
+
+	err := c.enc.Close()
+	c.enc.Reset(io.Discard)
+	encoders[c.encoding].Put(c.enc)
+	c.enc = nil
+	return err
 }
 
-// compressReader wraps an io.ReadCloser and gzip.Reader to provide gzip decompression for requests.
-type compressReader struct {
-	r  io.ReadCloser
-	zr *gzip.Reader
+// decompressReader decompresses a request body with a pooled decoder.
+type decompressReader struct {
+	body     io.ReadCloser
+	dec      decoder
+	encoding string
 }
 
-// newCompressReader creates a new compressReader for the given io.ReadCloser.
-func newCompressReader(r io.ReadCloser) (*compressReader, error) {
-	zr, err := gzip.NewReader(r)
-	if err != nil {
+// newDecompressReader creates a decompressReader for a body in the given coding.
+func newDecompressReader(body io.ReadCloser, encoding string) (*decompressReader, error) {
+	encoding = strings.ToLower(strings.TrimSpace(encoding))
+	if encoding == "x-gzip" {
+		encoding = "gzip"
+	}
+	pool, ok := decoders[encoding]
+	if !ok {
+		return nil, errUnsupportedEncoding
+	}
+
+	dec := pool.Get().(decoder)
+	if err := dec.Reset(body); err != nil {
+		pool.Put(dec)
 		return nil, err
 	}
+	return &decompressReader{body: body, dec: dec, encoding: encoding}, nil
+}
+
+// Read reads decompressed data.
+func (d *decompressReader) Read(p []byte) (int, error) {
+	return d.dec.Read(p)
+}
+
+// Close closes the body and returns the decoder to its pool.
+func (d *decompressReader) Close() error {
+	if d.dec != nil {
+		decoders[d.encoding].Put(d.dec)
+		d.dec = nil
+	}
+	return d.body.Close()
+}
+
+// CompressOption configures WithCompressing.
+type CompressOption func(*compressConfig)
 
-	return &compressReader{
-		r:  r,
-		zr: zr,
-	}, nil
+type compressConfig struct {
+	minSize     int
+	maxBodySize int64
 }
 
-// Read reads decompressed data from the underlying gzip.Reader.
-func (c compressReader) Read(p []byte) (n int, err error) {
-	return c.zr.Read(p)
+// WithCompressMinSize sets the size below which responses are sent uncompressed.
+func WithCompressMinSize(size int) CompressOption {
+	return func(c *compressConfig) {
+		c.minSize = size
+	}
 }
 
-// Close closes the underlying gzip.Reader and io.ReadCloser.
-func (c *compressReader) Close() error {
-	if err := c.r.Close(); err != nil {
-		return err
+// WithMaxBodySize sets the maximum size of request bodies before and after decompression.
+func WithMaxBodySize(size int64) CompressOption {
+	return func(c *compressConfig) {
+		c.maxBodySize = size
 	}
-	return c.zr.Close()
 }
 
-// WithCompressing is a middleware that handles gzip compression and decompression for supported requests and responses.
-func WithCompressing(logger logger.Logger) func(http.Handler) http.Handler {
+// WithCompressing is a middleware that negotiates response compression with Accept-Encoding, preferring br,
+// then zstd, then gzip among equal quality values, and decompresses request bodies in any of these codings.
+// Only compressible content types of at least DefaultCompressMinSize bytes are compressed.
+// Request bodies in other codings are rejected with 415 Unsupported Media Type.
+// Request bodies are limited to config.DefaultMaxBodySize bytes both as sent and decompressed, so small
+// compressed bodies cannot inflate without bounds; reading past the limit fails with *http.MaxBytesError.
+// LimitBody changes the limit for some routes.
+func WithCompressing(logger logger.Logger, opts ...CompressOption) func(http.Handler) http.Handler {
+	cfg := compressConfig{minSize: DefaultCompressMinSize, maxBodySize: config.DefaultMaxBodySize}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r = withBodyLimit(r, cfg.maxBodySize)
+			r.Body = limitBody(r, r.Body)
 
-			if strings.Contains(r.Header.Get("Content-Encoding"), "gzip") {
-				cr, err := newCompressReader(r.Body)
-				if err != nil {
-					logger.Errorf("failed to create gzip reader for request: %v", err)
-					w.WriteHeader(http.StatusInternalServerError)
+			if encoding := r.Header.Get("Content-Encoding"); encoding != "" && !strings.EqualFold(encoding, "identity") {
+				dr, err := newDecompressReader(r.Body, encoding)
+				var maxBytesErr *http.MaxBytesError
+				switch {
+				case errors.Is(err, errUnsupportedEncoding):
+					w.Header().Set("Accept-Encoding", strings.Join(supportedEncodings, ", "))
+					problem.Error(w, r, http.StatusUnsupportedMediaType, problem.CodeUnsupportedEncoding,
+						"request body must be encoded with "+strings.Join(supportedEncodings, ", ")+" or identity")
+					return
+				case errors.As(err, &maxBytesErr):
+					problem.Error(w, r, http.StatusRequestEntityTooLarge, problem.CodePayloadTooLarge,
+						"request body exceeds "+strconv.FormatInt(maxBytesErr.Limit, 10)+" bytes")
+					return
+				case err != nil:
+					problem.Error(w, r, http.StatusBadRequest, problem.CodeInvalidEncoding, err.Error())
 					return
 				}
-				r.Body = cr
+				r.Body = limitBody(r, dr)
+				r.Header.Del("Content-Encoding")
+				r.ContentLength = -1
 				defer func() {
-					if err := cr.Close(); err != nil {
-						logger.Errorf("failed to close gzip reader for request: %v", err)
+					if err := dr.Close(); err != nil {
+						logger.Errorf("failed to close %s reader for request: %v", dr.encoding, err)
 					}
 				}()
 			}
 
-			ow := w
-			if strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") && isSupportedContentType(r.Header.Get("Content-Type")) {
-				cw := newCompressWriter(w)
-				ow = cw
-				defer func() {
-					if err := cw.Close(); err != nil {
-						logger.Errorf("failed to close gzip writer for response: %v", err)
-					}
-				}()
-			}
+			cw := newCompressWriter(w, negotiateEncoding(r.Header.Get("Accept-Encoding")), cfg.minSize)
+			defer func() {
+				if err := cw.Close(); err != nil {
+					logger.Errorf("failed to close %s writer for response: %v", cw.encoding, err)
+				}
+			}()
 
-			next.ServeHTTP(ow, r)
+			next.ServeHTTP(cw, r)
 		})
 	}
 }
@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Deprecated is a middleware that marks responses of deprecated routes as described by RFC 9745 and RFC 8594:
+// the Deprecation header holds the time the routes were deprecated, the Sunset header the time they may stop
+// responding, and a Link header points to the documentation of the successor, if any.
+func Deprecated(deprecation, sunset time.Time, successor string) func(http.Handler) http.Handler {
+	deprecationValue := "@" + strconv.FormatInt(deprecation.Unix(), 10)
+	sunsetValue := sunset.UTC().Format(http.TimeFormat)
+	var linkValue string
+	if successor != "" {
+		linkValue = "<" + successor + `>; rel="successor-version"`
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Deprecation", deprecationValue)
+			w.Header().Set("Sunset", sunsetValue)
+			if linkValue != "" {
+				w.Header().Add("Link", linkValue)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
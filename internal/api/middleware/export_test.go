@@ -0,0 +1,4 @@
+package middleware
+
+// GRPCMethodScopes exposes the scopes of gRPC methods to the external tests.
+var GRPCMethodScopes = grpcMethodScopes
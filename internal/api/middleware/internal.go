@@ -3,6 +3,8 @@ package middleware
 import (
 	"net"
 	"net/http"
+
+	"github.com/grnsv/shortener/internal/api/problem"
 )
 
 // Internal returns a middleware that allows access only from the specified trusted subnet.
@@ -11,13 +13,8 @@ func Internal(trustedSubnet string) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			_, subnet, err := net.ParseCIDR(trustedSubnet)
-			if err != nil {
-				w.WriteHeader(http.StatusForbidden)
-				return
-			}
-
-			if !subnet.Contains(net.ParseIP(r.Header.Get("X-Real-IP"))) {
-				w.WriteHeader(http.StatusForbidden)
+			if err != nil || !subnet.Contains(net.ParseIP(r.Header.Get("X-Real-IP"))) {
+				problem.Error(w, r, http.StatusForbidden, problem.CodeForbidden, "access is allowed only from the trusted subnet")
 				return
 			}
 
@@ -0,0 +1,223 @@
+package middleware
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/grnsv/shortener/internal/config"
+)
+
+const defaultTokenTTL = 30 * 24 * time.Hour
+
+// Claims represents the JWT claims used for authentication.
+type Claims struct {
+	jwt.RegisteredClaims
+	keyID string // "kid" header of the parsed token
+}
+
+type verificationKey struct {
+	method jwt.SigningMethod
+	key    any
+}
+
+// Signer issues and verifies expiring JWTs.
+// Every token carries a "kid" header naming the key it was signed with,
+// so tokens signed with rotated-out keys stay valid until they expire.
+type Signer struct {
+	method     jwt.SigningMethod
+	kid        string
+	signingKey any
+	keys       map[string]verificationKey
+	ttl        time.Duration
+	now        func() time.Time
+}
+
+// NewSigner creates a Signer from the JWT settings of the configuration.
+// For HS256 it signs with JWTSecret and also accepts JWTPreviousSecrets;
+// for RS256 and EdDSA it signs with JWTPrivateKeyFile and also accepts JWTPublicKeyFiles.
+func NewSigner(cfg *config.Config) (*Signer, error) {
+	ttl := time.Duration(cfg.JWTTTL)
+	switch cfg.JWTAlgorithm {
+	case "", config.JWTAlgorithmHS256:
+		signer := NewHMACSigner(cfg.JWTSecret, cfg.JWTPreviousSecrets...)
+		if ttl > 0 {
+			signer.ttl = ttl
+		}
+		return signer, nil
+	case config.JWTAlgorithmRS256:
+		return newAsymmetricSigner(jwt.SigningMethodRS256, cfg.JWTPrivateKeyFile, cfg.JWTPublicKeyFiles, ttl,
+			func(data []byte) (crypto.Signer, error) { return jwt.ParseRSAPrivateKeyFromPEM(data) },
+			func(data []byte) (crypto.PublicKey, error) { return jwt.ParseRSAPublicKeyFromPEM(data) },
+		)
+	case config.JWTAlgorithmEdDSA:
+		return newAsymmetricSigner(jwt.SigningMethodEdDSA, cfg.JWTPrivateKeyFile, cfg.JWTPublicKeyFiles, ttl,
+			func(data []byte) (crypto.Signer, error) {
+				key, err := jwt.ParseEdPrivateKeyFromPEM(data)
+				if err != nil {
+					return nil, err
+				}
+				edKey, ok := key.(ed25519.PrivateKey)
+				if !ok {
+					return nil, errors.New("key is not a valid Ed25519 private key")
+				}
+				return edKey, nil
+			},
+			jwt.ParseEdPublicKeyFromPEM,
+		)
+	default:
+		return nil, fmt.Errorf("unsupported JWT algorithm %q", cfg.JWTAlgorithm)
+	}
+}
+
+// NewHMACSigner creates an HS256 Signer that signs with secret and also accepts tokens signed with previous secrets.
+func NewHMACSigner(secret string, previous ...string) *Signer {
+	signer := &Signer{
+		method:     jwt.SigningMethodHS256,
+		kid:        keyID([]byte(secret)),
+		signingKey: []byte(secret),
+		keys:       make(map[string]verificationKey, len(previous)+1),
+		ttl:        defaultTokenTTL,
+		now:        time.Now,
+	}
+	for _, s := range append([]string{secret}, previous...) {
+		signer.keys[keyID([]byte(s))] = verificationKey{method: jwt.SigningMethodHS256, key: []byte(s)}
+	}
+	return signer
+}
+
+func newAsymmetricSigner(
+	method jwt.SigningMethod,
+	privateKeyFile string,
+	publicKeyFiles []string,
+	ttl time.Duration,
+	parsePrivate func([]byte) (crypto.Signer, error),
+	parsePublic func([]byte) (crypto.PublicKey, error),
+) (*Signer, error) {
+	data, err := os.ReadFile(privateKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read JWT private key: %w", err)
+	}
+	privateKey, err := parsePrivate(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse JWT private key: %w", err)
+	}
+	kid, err := publicKeyID(privateKey.Public())
+	if err != nil {
+		return nil, err
+	}
+
+	signer := &Signer{
+		method:     method,
+		kid:        kid,
+		signingKey: privateKey,
+		keys:       map[string]verificationKey{kid: {method: method, key: privateKey.Public()}},
+		ttl:        defaultTokenTTL,
+		now:        time.Now,
+	}
+	if ttl > 0 {
+		signer.ttl = ttl
+	}
+
+	for _, file := range publicKeyFiles {
+		var publicKey crypto.PublicKey
+		if data, err = os.ReadFile(file); err != nil {
+			return nil, fmt.Errorf("failed to read JWT public key: %w", err)
+		}
+		if publicKey, err = parsePublic(data); err != nil {
+			return nil, fmt.Errorf("failed to parse JWT public key %s: %w", file, err)
+		}
+		if kid, err = publicKeyID(publicKey); err != nil {
+			return nil, err
+		}
+		signer.keys[kid] = verificationKey{method: method, key: publicKey}
+	}
+
+	return signer, nil
+}
+
+// TTL returns the lifetime of issued tokens.
+func (s *Signer) TTL() time.Duration {
+	return s.ttl
+}
+
+// Sign issues a token for the given user ID that expires after the signer's TTL.
+func (s *Signer) Sign(userID string) (string, error) {
+	now := s.now()
+	token := jwt.NewWithClaims(s.method, Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   userID,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(s.ttl)),
+		},
+	})
+	token.Header["kid"] = s.kid
+
+	return token.SignedString(s.signingKey)
+}
+
+// Parse verifies the token with the key named by its "kid" header and returns its claims.
+// Tokens without an expiration time are rejected.
+func (s *Signer) Parse(tokenStr string) (*Claims, error) {
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenStr, claims,
+		func(t *jwt.Token) (interface{}, error) {
+			claims.keyID, _ = t.Header["kid"].(string)
+			key, ok := s.keys[claims.keyID]
+			if !ok {
+				return nil, fmt.Errorf("unknown key id: %q", claims.keyID)
+			}
+			if t.Method == nil || t.Method.Alg() != key.method.Alg() {
+				return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
+			}
+			return key.key, nil
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	if !token.Valid {
+		return nil, errors.New("token is not valid")
+	}
+	if claims.ExpiresAt == nil {
+		return nil, errors.New("token has no expiration time")
+	}
+
+	return claims, nil
+}
+
+// NeedsRefresh reports whether a token with the given parsed claims should be reissued:
+// when less than half of its lifetime remains or it was signed with a rotated-out key.
+func (s *Signer) NeedsRefresh(claims *Claims) bool {
+	if claims.keyID != s.kid || claims.ExpiresAt == nil {
+		return true
+	}
+	return claims.ExpiresAt.Sub(s.now()) < s.ttl/2
+}
+
+// keyID derives a key identifier that does not reveal the key material.
+func keyID(key []byte) string {
+	sum := sha256.Sum256(append([]byte("kid:"), key...))
+	return hex.EncodeToString(sum[:8])
+}
+
+func publicKeyID(key crypto.PublicKey) (string, error) {
+	switch key.(type) {
+	case *rsa.PublicKey, ed25519.PublicKey:
+	default:
+		return "", fmt.Errorf("unsupported public key type %T", key)
+	}
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		return "", err
+	}
+	return keyID(der), nil
+}
@@ -30,6 +30,11 @@ func (r *loggingResponseWriter) WriteHeader(statusCode int) {
 	r.status = statusCode
 }
 
+// Unwrap returns the underlying http.ResponseWriter, so http.ResponseController can flush it.
+func (r *loggingResponseWriter) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
 // WithLogging is a middleware that logs HTTP requests and responses using the provided logger.
 func WithLogging(logger logger.Logger) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
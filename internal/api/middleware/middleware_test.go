@@ -3,14 +3,30 @@ package middleware
 import (
 	"bytes"
 	"compress/gzip"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/andybalholm/brotli"
+	"github.com/golang-jwt/jwt/v4"
 	"github.com/golang/mock/gomock"
+	"github.com/grnsv/shortener/internal/config"
 	"github.com/grnsv/shortener/internal/mocks"
+	"github.com/grnsv/shortener/internal/models"
+	"github.com/grnsv/shortener/internal/service"
+	"github.com/klauspost/compress/zstd"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestWithLogging(t *testing.T) {
@@ -41,78 +57,280 @@ func TestWithLogging(t *testing.T) {
 	assert.Equal(t, "OK", rec.Body.String())
 }
 
+func TestNegotiateEncoding(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{header: "", want: ""},
+		{header: "gzip", want: "gzip"},
+		{header: "gzip, deflate, br, zstd", want: "br"},
+		{header: "gzip;q=1.0, br;q=0.5, zstd;q=0.8", want: "gzip"},
+		{header: "br;q=0, zstd", want: "zstd"},
+		{header: "*", want: "br"},
+		{header: "*;q=0.5, gzip", want: "gzip"},
+		{header: "x-gzip", want: "gzip"},
+		{header: "deflate", want: ""},
+		{header: "gzip;q=0.5, identity", want: ""},
+		{header: "gzip;q=bogus", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			assert.Equal(t, tt.want, negotiateEncoding(tt.header))
+		})
+	}
+}
+
+func decompress(t *testing.T, encoding string, body []byte) string {
+	t.Helper()
+	var r io.Reader
+	switch encoding {
+	case "gzip":
+		gz, err := gzip.NewReader(bytes.NewReader(body))
+		require.NoError(t, err)
+		r = gz
+	case "br":
+		r = brotli.NewReader(bytes.NewReader(body))
+	case "zstd":
+		zr, err := zstd.NewReader(bytes.NewReader(body))
+		require.NoError(t, err)
+		defer zr.Close()
+		r = zr
+	default:
+		return string(body)
+	}
+	decompressed, err := io.ReadAll(r)
+	require.NoError(t, err)
+	return string(decompressed)
+}
+
 func TestWithCompressing(t *testing.T) {
-	t.Run("gzip supported and content-type supported", func(t *testing.T) {
+	long := strings.Repeat(`{"short_url":"http://localhost:8080/abcdefgh"}`, 50)
+	tests := []struct {
+		name           string
+		acceptEncoding string
+		contentType    string
+		body           string
+		wantEncoding   string
+		wantVary       bool
+	}{
+		{name: "gzip", acceptEncoding: "gzip", contentType: "application/json", body: long, wantEncoding: "gzip", wantVary: true},
+		{name: "brotli", acceptEncoding: "gzip, br", contentType: "text/html; charset=utf-8", body: long, wantEncoding: "br", wantVary: true},
+		{name: "zstd", acceptEncoding: "zstd, gzip;q=0.9", contentType: "application/problem+json", body: long, wantEncoding: "zstd", wantVary: true},
+		{name: "sniffed content type", acceptEncoding: "gzip", body: strings.Repeat("plain text ", 200), wantEncoding: "gzip", wantVary: true},
+		{name: "below minimum size", acceptEncoding: "gzip", contentType: "application/json", body: "OK", wantVary: true},
+		{name: "content type not compressible", acceptEncoding: "gzip", contentType: "image/png", body: long},
+		{name: "no accepted encoding", contentType: "application/json", body: long, wantVary: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockLogger := mocks.NewMockLogger(ctrl)
+			handler := WithCompressing(mockLogger)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.contentType != "" {
+					w.Header().Set("Content-Type", tt.contentType)
+				}
+				w.WriteHeader(http.StatusOK)
+				_, err := w.Write([]byte(tt.body))
+				assert.NoError(t, err)
+			}))
+			req := httptest.NewRequest(http.MethodGet, "/test", nil)
+			req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, tt.wantEncoding, rec.Header().Get("Content-Encoding"))
+			assert.Equal(t, tt.wantVary, rec.Header().Get("Vary") == "Accept-Encoding")
+			assert.Equal(t, tt.body, decompress(t, tt.wantEncoding, rec.Body.Bytes()))
+		})
+	}
+
+	t.Run("streamed responses are flushed compressed", func(t *testing.T) {
 		ctrl := gomock.NewController(t)
 		defer ctrl.Finish()
 
 		mockLogger := mocks.NewMockLogger(ctrl)
 		handler := WithCompressing(mockLogger)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			w.WriteHeader(http.StatusOK)
-			_, err := w.Write([]byte("OK"))
+			w.Header().Set("Content-Type", "text/csv")
+			_, err := w.Write([]byte("a,b\n"))
+			assert.NoError(t, err)
+			assert.NoError(t, http.NewResponseController(w).Flush())
+			_, err = w.Write([]byte("c,d\n"))
 			assert.NoError(t, err)
 		}))
 		req := httptest.NewRequest(http.MethodGet, "/test", nil)
 		req.Header.Set("Accept-Encoding", "gzip")
-		req.Header.Set("Content-Type", "text/html")
 		rec := httptest.NewRecorder()
 		handler.ServeHTTP(rec, req)
-		assert.Equal(t, http.StatusOK, rec.Code)
+
+		assert.True(t, rec.Flushed)
 		assert.Equal(t, "gzip", rec.Header().Get("Content-Encoding"))
-		gz, err := gzip.NewReader(bytes.NewReader(rec.Body.Bytes()))
-		if assert.NoError(t, err) {
-			decompressed, err := io.ReadAll(gz)
-			assert.NoError(t, err)
-			assert.Equal(t, "OK", string(decompressed))
-			assert.NoError(t, gz.Close())
-		}
+		assert.Equal(t, "a,b\nc,d\n", decompress(t, "gzip", rec.Body.Bytes()))
 	})
 
-	t.Run("gzip supported but content-type not supported", func(t *testing.T) {
+	t.Run("responses without a body are not compressed", func(t *testing.T) {
 		ctrl := gomock.NewController(t)
 		defer ctrl.Finish()
 
 		mockLogger := mocks.NewMockLogger(ctrl)
 		handler := WithCompressing(mockLogger)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			w.WriteHeader(http.StatusOK)
-			_, err := w.Write([]byte("OK"))
-			assert.NoError(t, err)
+			w.WriteHeader(http.StatusNoContent)
 		}))
 		req := httptest.NewRequest(http.MethodGet, "/test", nil)
 		req.Header.Set("Accept-Encoding", "gzip")
-		req.Header.Set("Content-Type", "application/xml")
 		rec := httptest.NewRecorder()
 		handler.ServeHTTP(rec, req)
-		assert.Equal(t, http.StatusOK, rec.Code)
-		assert.NotEqual(t, "gzip", rec.Header().Get("Content-Encoding"))
-		assert.Equal(t, "OK", rec.Body.String())
+
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+		assert.Empty(t, rec.Header().Get("Content-Encoding"))
+		assert.Empty(t, rec.Body.Bytes())
 	})
+}
+
+func TestWithCompressingRequests(t *testing.T) {
+	const body = `{"url":"https://practicum.yandex.ru/"}`
+	compress := map[string]func(io.Writer) io.WriteCloser{
+		"gzip": func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) },
+		"br":   func(w io.Writer) io.WriteCloser { return brotli.NewWriter(w) },
+		"zstd": func(w io.Writer) io.WriteCloser {
+			zw, err := zstd.NewWriter(w)
+			require.NoError(t, err)
+			return zw
+		},
+	}
+	for encoding, newWriter := range compress {
+		t.Run(encoding, func(t *testing.T) {
+			var buf bytes.Buffer
+			zw := newWriter(&buf)
+			_, err := zw.Write([]byte(body))
+			require.NoError(t, err)
+			require.NoError(t, zw.Close())
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
 
-	t.Run("gzip not supported", func(t *testing.T) {
+			mockLogger := mocks.NewMockLogger(ctrl)
+			handler := WithCompressing(mockLogger)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got, readErr := io.ReadAll(r.Body)
+				assert.NoError(t, readErr)
+				assert.Equal(t, body, string(got))
+				assert.Empty(t, r.Header.Get("Content-Encoding"))
+				w.WriteHeader(http.StatusOK)
+			}))
+			req := httptest.NewRequest(http.MethodPost, "/test", &buf)
+			req.Header.Set("Content-Encoding", encoding)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			assert.Equal(t, http.StatusOK, rec.Code)
+		})
+	}
+
+	t.Run("unsupported encoding", func(t *testing.T) {
 		ctrl := gomock.NewController(t)
 		defer ctrl.Finish()
 
 		mockLogger := mocks.NewMockLogger(ctrl)
 		handler := WithCompressing(mockLogger)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			w.WriteHeader(http.StatusOK)
-			_, err := w.Write([]byte("OK"))
-			assert.NoError(t, err)
+			t.Fatal("handler must not be called")
 		}))
-		req := httptest.NewRequest(http.MethodGet, "/test", nil)
-		req.Header.Set("Content-Type", "text/html")
+		req := httptest.NewRequest(http.MethodPost, "/test", strings.NewReader(body))
+		req.Header.Set("Content-Encoding", "deflate")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusUnsupportedMediaType, rec.Code)
+		assert.Equal(t, "br, zstd, gzip", rec.Header().Get("Accept-Encoding"))
+	})
+
+	t.Run("malformed gzip body", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockLogger := mocks.NewMockLogger(ctrl)
+		handler := WithCompressing(mockLogger)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			t.Fatal("handler must not be called")
+		}))
+		req := httptest.NewRequest(http.MethodPost, "/test", strings.NewReader(body))
+		req.Header.Set("Content-Encoding", "gzip")
 		rec := httptest.NewRecorder()
 		handler.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
+
+func TestWithCompressingBodyLimits(t *testing.T) {
+	const limit = 4 << 10
+	readBody := func(t *testing.T, opts ...func(http.Handler) http.Handler) (*httptest.ResponseRecorder, func(*http.Request)) {
+		t.Helper()
+		ctrl := gomock.NewController(t)
+		t.Cleanup(ctrl.Finish)
+
+		var handler http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, err := io.ReadAll(r.Body)
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				w.WriteHeader(http.StatusRequestEntityTooLarge)
+				return
+			}
+			assert.NoError(t, err)
+			w.WriteHeader(http.StatusOK)
+		})
+		for _, opt := range opts {
+			handler = opt(handler)
+		}
+		handler = WithCompressing(mocks.NewMockLogger(ctrl), WithMaxBodySize(limit))(handler)
+		rec := httptest.NewRecorder()
+		return rec, func(r *http.Request) { handler.ServeHTTP(rec, r) }
+	}
+	gzipped := func(t *testing.T, size int) io.Reader {
+		t.Helper()
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		_, err := zw.Write(make([]byte, size))
+		require.NoError(t, err)
+		require.NoError(t, zw.Close())
+		require.Less(t, buf.Len(), limit)
+		return &buf
+	}
+
+	t.Run("body within the limit", func(t *testing.T) {
+		rec, serve := readBody(t)
+		serve(httptest.NewRequest(http.MethodPost, "/test", strings.NewReader(strings.Repeat("a", limit))))
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("body over the limit", func(t *testing.T) {
+		rec, serve := readBody(t)
+		serve(httptest.NewRequest(http.MethodPost, "/test", strings.NewReader(strings.Repeat("a", limit+1))))
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+	})
+
+	t.Run("compressed body inflating over the limit", func(t *testing.T) {
+		rec, serve := readBody(t)
+		req := httptest.NewRequest(http.MethodPost, "/test", gzipped(t, 1<<20))
+		req.Header.Set("Content-Encoding", "gzip")
+		serve(req)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+	})
+
+	t.Run("route with a larger limit", func(t *testing.T) {
+		rec, serve := readBody(t, LimitBody(2<<20))
+		req := httptest.NewRequest(http.MethodPost, "/test", gzipped(t, 1<<20))
+		req.Header.Set("Content-Encoding", "gzip")
+		serve(req)
 		assert.Equal(t, http.StatusOK, rec.Code)
-		assert.NotEqual(t, "gzip", rec.Header().Get("Content-Encoding"))
-		assert.Equal(t, "OK", rec.Body.String())
 	})
 }
 
 func TestAuthenticate(t *testing.T) {
 	const secret = "test-secret"
+	signer := NewHMACSigner(secret)
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 	mockLogger := mocks.NewMockLogger(ctrl)
+	mockShortener := mocks.NewMockShortener(ctrl)
 
 	// Helper handler to check userID in context
 	checkUserIDHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -126,7 +344,7 @@ func TestAuthenticate(t *testing.T) {
 		assert.NoError(t, err)
 	})
 
-	middleware := Authenticate(secret, mockLogger)
+	middleware := Authenticate(signer, mockShortener, mockLogger)
 
 	t.Run("no token: should set new token and userID", func(t *testing.T) {
 		rec := httptest.NewRecorder()
@@ -150,9 +368,26 @@ func TestAuthenticate(t *testing.T) {
 		assert.True(t, found, "should set token cookie")
 	})
 
-	t.Run("valid token: should refresh token and keep userID", func(t *testing.T) {
+	t.Run("fresh token: should keep userID without refreshing token", func(t *testing.T) {
+		userID := "test-user-id"
+		cookie, err := BuildAuthCookie(signer, userID)
+		assert.NoError(t, err)
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.AddCookie(cookie)
+		mockLogger.EXPECT().Debug(gomock.Any()).AnyTimes()
+		wrapped := middleware(checkUserIDHandler)
+		wrapped.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), userID)
+		assert.Empty(t, rec.Header().Get("Set-Cookie"), "should not refresh fresh token")
+	})
+
+	t.Run("aging token: should refresh token and keep userID", func(t *testing.T) {
 		userID := "test-user-id"
-		cookie, err := BuildAuthCookie(secret, userID)
+		old := NewHMACSigner(secret)
+		old.now = func() time.Time { return time.Now().Add(-old.TTL() * 3 / 4) }
+		cookie, err := BuildAuthCookie(old, userID)
 		assert.NoError(t, err)
 		rec := httptest.NewRecorder()
 		req := httptest.NewRequest(http.MethodGet, "/", nil)
@@ -170,13 +405,50 @@ func TestAuthenticate(t *testing.T) {
 		// Should refresh token
 		updated := false
 		for _, c := range resp.Cookies() {
-			if c.Name == "token" && c.Value != "" {
+			if c.Name == "token" && c.Value != "" && c.Value != cookie.Value {
 				updated = true
 			}
 		}
 		assert.True(t, updated, "should refresh token cookie")
 	})
 
+	t.Run("token signed with previous secret: should keep userID and refresh token", func(t *testing.T) {
+		userID := "test-user-id"
+		rotated := NewHMACSigner("new-secret", secret)
+		cookie, err := BuildAuthCookie(signer, userID)
+		assert.NoError(t, err)
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.AddCookie(cookie)
+		Authenticate(rotated, mockShortener, mockLogger)(checkUserIDHandler).ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, userID, rec.Body.String())
+		resp := rec.Result()
+		defer func() {
+			assert.NoError(t, resp.Body.Close())
+		}()
+
+		if cookies := resp.Cookies(); assert.Len(t, cookies, 1) {
+			claims, err := rotated.Parse(cookies[0].Value)
+			assert.NoError(t, err)
+			assert.False(t, rotated.NeedsRefresh(claims), "should sign with the current secret")
+		}
+	})
+
+	t.Run("expired token: should set new token and userID", func(t *testing.T) {
+		expired := NewHMACSigner(secret)
+		expired.now = func() time.Time { return time.Now().Add(-2 * expired.TTL()) }
+		cookie, err := BuildAuthCookie(expired, "test-user-id")
+		assert.NoError(t, err)
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.AddCookie(&http.Cookie{Name: cookie.Name, Value: cookie.Value})
+		mockLogger.EXPECT().Debug(gomock.Any()).AnyTimes()
+		middleware(checkUserIDHandler).ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.NotEqual(t, "test-user-id", rec.Body.String())
+	})
+
 	t.Run("invalid token: should set new token and userID", func(t *testing.T) {
 		rec := httptest.NewRecorder()
 		req := httptest.NewRequest(http.MethodGet, "/", nil)
@@ -201,7 +473,7 @@ func TestAuthenticate(t *testing.T) {
 	})
 
 	t.Run("token with empty userID: should return 401", func(t *testing.T) {
-		cookie, err := BuildAuthCookie(secret, "")
+		cookie, err := BuildAuthCookie(signer, "")
 		assert.NoError(t, err)
 		rec := httptest.NewRecorder()
 		req := httptest.NewRequest(http.MethodGet, "/", nil)
@@ -212,3 +484,183 @@ func TestAuthenticate(t *testing.T) {
 		assert.Equal(t, http.StatusUnauthorized, rec.Code)
 	})
 }
+
+func TestAuthenticateAPIKey(t *testing.T) {
+	const (
+		secret = "test-secret"
+		userID = "test-user-id"
+	)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockLogger := mocks.NewMockLogger(ctrl)
+	mockShortener := mocks.NewMockShortener(ctrl)
+
+	handler := Authenticate(NewHMACSigner(secret), mockShortener, mockLogger)(
+		RequireScope(models.ScopeRead)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userID, _ := r.Context().Value(UserIDContextKey).(string)
+			_, err := w.Write([]byte(userID))
+			assert.NoError(t, err)
+		})),
+	)
+
+	t.Run("valid key: should set userID without cookie", func(t *testing.T) {
+		mockShortener.EXPECT().VerifyAPIKey(gomock.Any(), "sk_valid").Return(&models.APIKey{UserID: userID}, nil)
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Authorization", "Bearer sk_valid")
+		handler.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, userID, rec.Body.String())
+		assert.Empty(t, rec.Header().Get("Set-Cookie"))
+	})
+
+	t.Run("invalid key: should return 401", func(t *testing.T) {
+		mockShortener.EXPECT().VerifyAPIKey(gomock.Any(), "sk_invalid").Return(nil, service.ErrInvalidAPIKey)
+		mockLogger.EXPECT().Debug(gomock.Any())
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Authorization", "Bearer sk_invalid")
+		handler.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+
+	t.Run("key without scope: should return 403", func(t *testing.T) {
+		mockShortener.EXPECT().VerifyAPIKey(gomock.Any(), "sk_shorten").
+			Return(&models.APIKey{UserID: userID, Scopes: models.Scopes{models.ScopeShorten}}, nil)
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Authorization", "Bearer sk_shorten")
+		handler.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	})
+}
+
+func TestSigner(t *testing.T) {
+	t.Run("token without kid: should be rejected", func(t *testing.T) {
+		signer := NewHMACSigner("secret")
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{
+			RegisteredClaims: jwt.RegisteredClaims{
+				Subject:   "user",
+				ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+			},
+		}).SignedString([]byte("secret"))
+		require.NoError(t, err)
+		_, err = signer.Parse(token)
+		assert.Error(t, err)
+	})
+
+	t.Run("token without exp: should be rejected", func(t *testing.T) {
+		signer := NewHMACSigner("secret")
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{RegisteredClaims: jwt.RegisteredClaims{Subject: "user"}})
+		token.Header["kid"] = signer.kid
+		tokenString, err := token.SignedString([]byte("secret"))
+		require.NoError(t, err)
+		_, err = signer.Parse(tokenString)
+		assert.Error(t, err)
+	})
+
+	t.Run("token signed with removed secret: should be rejected", func(t *testing.T) {
+		token, err := NewHMACSigner("old").Sign("user")
+		require.NoError(t, err)
+		_, err = NewHMACSigner("new").Parse(token)
+		assert.Error(t, err)
+	})
+
+	t.Run("EdDSA: should sign and verify with rotated keys", func(t *testing.T) {
+		dir := t.TempDir()
+		writeKey := func(name string) (string, string) {
+			public, private, err := ed25519.GenerateKey(rand.Reader)
+			require.NoError(t, err)
+			privateDER, err := x509.MarshalPKCS8PrivateKey(private)
+			require.NoError(t, err)
+			publicDER, err := x509.MarshalPKIXPublicKey(public)
+			require.NoError(t, err)
+			privatePath := filepath.Join(dir, name+".pem")
+			publicPath := filepath.Join(dir, name+".pub.pem")
+			require.NoError(t, os.WriteFile(privatePath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDER}), 0600))
+			require.NoError(t, os.WriteFile(publicPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER}), 0600))
+			return privatePath, publicPath
+		}
+		oldPrivate, oldPublic := writeKey("old")
+		newPrivate, _ := writeKey("new")
+
+		old, err := NewSigner(&config.Config{JWTAlgorithm: config.JWTAlgorithmEdDSA, JWTPrivateKeyFile: oldPrivate})
+		require.NoError(t, err)
+		token, err := old.Sign("user")
+		require.NoError(t, err)
+
+		rotated, err := NewSigner(&config.Config{
+			JWTAlgorithm:      config.JWTAlgorithmEdDSA,
+			JWTPrivateKeyFile: newPrivate,
+			JWTPublicKeyFiles: []string{oldPublic},
+		})
+		require.NoError(t, err)
+		claims, err := rotated.Parse(token)
+		require.NoError(t, err)
+		assert.Equal(t, "user", claims.Subject)
+		assert.True(t, rotated.NeedsRefresh(claims))
+
+		_, err = NewHMACSigner("secret").Parse(token)
+		assert.Error(t, err)
+	})
+}
+
+func TestDeprecated(t *testing.T) {
+	deprecation := time.Date(2026, time.October, 18, 0, 0, 0, 0, time.UTC)
+	sunset := time.Date(2027, time.April, 30, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		successor string
+		link      string
+	}{
+		{name: "with successor", successor: "/api/v2/links", link: `</api/v2/links>; rel="successor-version"`},
+		{name: "without successor"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := Deprecated(deprecation, sunset, tt.successor)(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusCreated)
+			}))
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/shorten", nil))
+
+			assert.Equal(t, http.StatusCreated, rec.Code)
+			assert.Equal(t, "@1792281600", rec.Header().Get("Deprecation"))
+			assert.Equal(t, "Fri, 30 Apr 2027 00:00:00 GMT", rec.Header().Get("Sunset"))
+			assert.Equal(t, tt.link, rec.Header().Get("Link"))
+		})
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		name       string
+		remoteAddr string
+		realIP     string
+		want       string
+	}{
+		{name: "trusted proxy", remoteAddr: "10.0.0.2:4321", realIP: "203.0.113.1", want: "203.0.113.1"},
+		{name: "trusted proxy without the header", remoteAddr: "10.0.0.2:4321", want: "10.0.0.2"},
+		{name: "trusted proxy with an invalid header", remoteAddr: "10.0.0.2:4321", realIP: "unknown", want: "10.0.0.2"},
+		{name: "untrusted peer", remoteAddr: "198.51.100.7:4321", realIP: "203.0.113.1", want: "198.51.100.7"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			handler := ClientIP([]string{"10.0.0.0/8"})(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+				got = ClientIPFrom(r.Context())
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.realIP != "" {
+				req.Header.Set("X-Real-IP", tt.realIP)
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"context"
+	"net"
+
+	"github.com/grnsv/shortener/internal/models"
+	"github.com/grnsv/shortener/internal/service"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// GRPCRequestInfoInterceptor returns a gRPC unary interceptor passing the authenticated user, the address
+// of the peer and its user agent to the service for the audit log, see service.WithRequestInfo.
+// It must follow GRPCAuthenticateInterceptor.
+func GRPCRequestInfoInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		request := models.RequestInfo{Transport: models.TransportGRPC}
+		request.Actor, _ = ctx.Value(UserIDContextKey).(string)
+		if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+			request.IP = p.Addr.String()
+			if host, _, err := net.SplitHostPort(request.IP); err == nil {
+				request.IP = host
+			}
+		}
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			if values := md.Get("user-agent"); len(values) > 0 {
+				request.UserAgent = values[0]
+			}
+		}
+		return handler(service.WithRequestInfo(ctx, request), req)
+	}
+}
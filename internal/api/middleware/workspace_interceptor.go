@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"context"
+
+	"github.com/grnsv/shortener/internal/service"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// WorkspaceMetadataKey is the gRPC metadata key naming the active workspace of a call, see service.WithWorkspace.
+const WorkspaceMetadataKey = "workspace-id"
+
+// GRPCWorkspaceInterceptor returns a gRPC unary interceptor making the workspace named by the WorkspaceMetadataKey
+// of a call, if any, active for it.
+func GRPCWorkspaceInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			if values := md.Get(WorkspaceMetadataKey); len(values) > 0 && values[0] != "" {
+				ctx = service.WithWorkspace(ctx, values[0])
+			}
+		}
+		return handler(ctx, req)
+	}
+}
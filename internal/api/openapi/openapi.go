@@ -0,0 +1,73 @@
+// Package openapi provides the OpenAPI 3 specification of the HTTP API, the handlers serving it
+// with Swagger UI, and a middleware validating requests and responses against it.
+package openapi
+
+import (
+	"context"
+	"embed"
+	"html/template"
+	"net/http"
+	"sync"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/grnsv/shortener/internal/api/problem"
+)
+
+// SpecPath and DocsPath are where NewRouter serves the specification and Swagger UI.
+const (
+	SpecPath = "/api/openapi.json"
+	DocsPath = "/api/docs"
+)
+
+//go:embed openapi.yaml swagger.html
+var files embed.FS
+
+// docsPage is the Swagger UI page, which loads its assets from a CDN and the specification from SpecPath.
+var docsPage = template.Must(template.ParseFS(files, "swagger.html"))
+
+// Spec returns the parsed and validated specification embedded in the binary.
+// It panics if the specification is invalid, which the tests of the package catch.
+var Spec = sync.OnceValue(func() *openapi3.T {
+	doc, err := load()
+	if err != nil {
+		panic(err)
+	}
+	return doc
+})
+
+func load() (*openapi3.T, error) {
+	data, err := files.ReadFile("openapi.yaml")
+	if err != nil {
+		return nil, err
+	}
+	doc, err := openapi3.NewLoader().LoadFromData(data)
+	if err != nil {
+		return nil, err
+	}
+	if err = doc.Validate(context.Background()); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// specJSON is the specification encoded for SpecHandler.
+var specJSON = sync.OnceValues(func() ([]byte, error) {
+	return Spec().MarshalJSON()
+})
+
+// SpecHandler serves the specification as JSON.
+func SpecHandler(w http.ResponseWriter, r *http.Request) {
+	data, err := specJSON()
+	if err != nil {
+		problem.Error(w, r, http.StatusInternalServerError, problem.CodeInternal, "")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(data)
+}
+
+// DocsHandler serves Swagger UI for the specification.
+func DocsHandler(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_ = docsPage.Execute(w, SpecPath)
+}
@@ -0,0 +1,201 @@
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/grnsv/shortener/internal/api/problem"
+	"github.com/grnsv/shortener/internal/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpec(t *testing.T) {
+	doc := Spec()
+
+	for path, item := range doc.Paths.Map() {
+		for method, op := range item.Operations() {
+			assert.NotEmpty(t, op.OperationID, "%s %s", method, path)
+			assert.NotEmpty(t, op.Summary, "%s %s", method, path)
+		}
+	}
+}
+
+func TestSpecHandler(t *testing.T) {
+	rec := httptest.NewRecorder()
+	SpecHandler(rec, httptest.NewRequest(http.MethodGet, SpecPath, nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	var got map[string]any
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+	assert.Equal(t, "3.0.3", got["openapi"])
+	assert.Contains(t, got["paths"], "/api/shorten")
+}
+
+func TestDocsHandler(t *testing.T) {
+	rec := httptest.NewRecorder()
+	DocsHandler(rec, httptest.NewRequest(http.MethodGet, DocsPath, nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Header().Get("Content-Type"), "text/html")
+	assert.Contains(t, rec.Body.String(), "swagger-ui")
+	assert.Contains(t, rec.Body.String(), `\/api\/openapi.json`)
+}
+
+func TestValidate(t *testing.T) {
+	log, err := logger.New("testing")
+	require.NoError(t, err)
+
+	respond := func(contentType, body string, status int) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", contentType)
+			w.WriteHeader(status)
+			_, _ = w.Write([]byte(body))
+		})
+	}
+	shortened := respond("application/json", `{"result":"http://localhost:8080/abc"}`, http.StatusCreated)
+
+	tests := []struct {
+		name        string
+		handler     http.Handler
+		opts        []ValidateOption
+		method      string
+		target      string
+		contentType string
+		body        string
+		status      int
+		code        problem.Code
+	}{
+		{
+			name:        "valid request",
+			handler:     shortened,
+			method:      http.MethodPost,
+			target:      "/api/shorten",
+			contentType: "application/json",
+			body:        `{"url":"https://example.com"}`,
+			status:      http.StatusCreated,
+		},
+		{
+			name:        "body of a wrong type",
+			handler:     shortened,
+			method:      http.MethodPost,
+			target:      "/api/shorten",
+			contentType: "application/json",
+			body:        `{"url":42}`,
+			status:      http.StatusBadRequest,
+			code:        problem.CodeValidationFailed,
+		},
+		{
+			name:        "missing required property",
+			handler:     shortened,
+			method:      http.MethodPost,
+			target:      "/api/shorten/batch",
+			contentType: "application/json; charset=utf-8",
+			body:        `[{"correlation_id":"1"}]`,
+			status:      http.StatusBadRequest,
+			code:        problem.CodeValidationFailed,
+		},
+		{
+			name:    "query parameter of a wrong type",
+			handler: respond("image/png", "png", http.StatusOK),
+			method:  http.MethodGet,
+			target:  "/abc/qr?size=big",
+			status:  http.StatusBadRequest,
+			code:    problem.CodeValidationFailed,
+		},
+		{
+			name:        "bodies other than JSON are passed on",
+			handler:     respond("text/plain", "http://localhost:8080/abc", http.StatusCreated),
+			method:      http.MethodPost,
+			target:      "/api/user/urls/import?format=csv",
+			contentType: "text/csv",
+			body:        "not,validated",
+			status:      http.StatusCreated,
+		},
+		{
+			name:    "unknown path is passed on",
+			handler: respond("text/plain", "", http.StatusTeapot),
+			method:  http.MethodGet,
+			target:  "/api/unknown/path",
+			status:  http.StatusTeapot,
+		},
+		{
+			name:        "invalid response is not checked by default",
+			handler:     respond("application/json", `{"result":1}`, http.StatusCreated),
+			method:      http.MethodPost,
+			target:      "/api/shorten",
+			contentType: "application/json",
+			body:        `{"url":"https://example.com"}`,
+			status:      http.StatusCreated,
+		},
+		{
+			name:        "invalid response",
+			handler:     respond("application/json", `{"result":1}`, http.StatusCreated),
+			opts:        []ValidateOption{WithResponseValidation()},
+			method:      http.MethodPost,
+			target:      "/api/shorten",
+			contentType: "application/json",
+			body:        `{"url":"https://example.com"}`,
+			status:      http.StatusInternalServerError,
+			code:        problem.CodeInternal,
+		},
+		{
+			name:        "valid response",
+			handler:     shortened,
+			opts:        []ValidateOption{WithResponseValidation()},
+			method:      http.MethodPost,
+			target:      "/api/shorten",
+			contentType: "application/json",
+			body:        `{"url":"https://example.com"}`,
+			status:      http.StatusCreated,
+		},
+		{
+			name:    "invalid problem",
+			handler: respond(problem.ContentType, `{"status":"404"}`, http.StatusNotFound),
+			opts:    []ValidateOption{WithResponseValidation()},
+			method:  http.MethodGet,
+			target:  "/api/user/urls/abc/history",
+			status:  http.StatusInternalServerError,
+			code:    problem.CodeInternal,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			rec := httptest.NewRecorder()
+			Validate(log, tt.opts...)(tt.handler).ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.status, rec.Code)
+			if tt.code == "" {
+				return
+			}
+			assert.Equal(t, problem.ContentType, rec.Header().Get("Content-Type"))
+			var p problem.Problem
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &p))
+			assert.Equal(t, tt.code, p.Code)
+		})
+	}
+}
+
+func TestValidateBodyLimit(t *testing.T) {
+	log, err := logger.New("testing")
+	require.NoError(t, err)
+	handler := Validate(log)(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/api/shorten", strings.NewReader(`{"url":"https://example.com"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Body = http.MaxBytesReader(rec, req.Body, 8)
+	handler.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+}
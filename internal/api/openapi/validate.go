@@ -0,0 +1,182 @@
+package openapi
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+	"github.com/grnsv/shortener/internal/api/problem"
+	"github.com/grnsv/shortener/internal/logger"
+)
+
+// ValidateOption configures Validate.
+type ValidateOption func(*validateConfig)
+
+type validateConfig struct {
+	responses bool
+}
+
+// WithResponseValidation makes Validate check JSON responses too and replace those not matching the specification
+// with 500 Internal Server Error. JSON responses are buffered to be checked, so it is meant for development and tests.
+func WithResponseValidation() ValidateOption {
+	return func(c *validateConfig) {
+		c.responses = true
+	}
+}
+
+// Validate is a middleware that rejects requests not matching the specification with 400 Bad Request
+// and the validation_failed problem code, or 413 Payload Too Large if the body exceeds its limit.
+// Requests for paths and methods the specification does not describe are passed on, for the router to answer.
+// Only JSON bodies of operations accepting JSON are validated, so uploads and forms are streamed to the handlers.
+// Authentication is left to the authentication middleware.
+func Validate(logger logger.Logger, opts ...ValidateOption) func(http.Handler) http.Handler {
+	var cfg validateConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	router, err := gorillamux.NewRouter(Spec())
+	if err != nil {
+		// The specification has no servers, so the routes always compile.
+		panic(err)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			route, params, err := router.FindRoute(r)
+			if err != nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			options := &openapi3filter.Options{
+				ExcludeRequestBody: !acceptsJSON(route, r.Header.Get("Content-Type")),
+				AuthenticationFunc: openapi3filter.NoopAuthenticationFunc,
+			}
+			options.WithCustomSchemaErrorFunc(schemaErrorMessage)
+			input := &openapi3filter.RequestValidationInput{Request: r, PathParams: params, Route: route, Options: options}
+			if err = openapi3filter.ValidateRequest(r.Context(), input); err != nil {
+				writeRequestError(w, r, err)
+				return
+			}
+
+			if !cfg.responses {
+				next.ServeHTTP(w, r)
+				return
+			}
+			vw := &validatingWriter{ResponseWriter: w}
+			next.ServeHTTP(vw, r)
+			if !vw.buffering {
+				return
+			}
+			err = openapi3filter.ValidateResponse(r.Context(), &openapi3filter.ResponseValidationInput{
+				RequestValidationInput: input,
+				Status:                 vw.status,
+				Header:                 w.Header(),
+				Body:                   io.NopCloser(bytes.NewReader(vw.buf.Bytes())),
+				Options:                options,
+			})
+			if err != nil {
+				logger.Errorf("response to %s %s does not match the OpenAPI specification: %v", r.Method, r.URL.Path, err)
+				problem.Error(w, r, http.StatusInternalServerError, problem.CodeInternal, "")
+				return
+			}
+			w.WriteHeader(vw.status)
+			if _, err = w.Write(vw.buf.Bytes()); err != nil {
+				logger.Error(err)
+			}
+		})
+	}
+}
+
+// writeRequestError responds to a request rejected by the validation.
+func writeRequestError(w http.ResponseWriter, r *http.Request, err error) {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		problem.Error(w, r, http.StatusRequestEntityTooLarge, problem.CodePayloadTooLarge,
+			"request body exceeds "+strconv.FormatInt(maxBytesErr.Limit, 10)+" bytes")
+		return
+	}
+	problem.Error(w, r, http.StatusBadRequest, problem.CodeValidationFailed, err.Error())
+}
+
+// schemaErrorMessage describes a value not matching its schema by its location and the reason only,
+// without the schema and the value.
+func schemaErrorMessage(err *openapi3.SchemaError) string {
+	if pointer := err.JSONPointer(); len(pointer) > 0 {
+		return strings.Join(pointer, ".") + ": " + err.Reason
+	}
+	return err.Reason
+}
+
+// acceptsJSON reports whether contentType is a JSON media type the operation of route accepts.
+func acceptsJSON(route *routers.Route, contentType string) bool {
+	if !isJSON(contentType) || route.Operation == nil || route.Operation.RequestBody == nil {
+		return false
+	}
+	body := route.Operation.RequestBody.Value
+	return body != nil && body.Content.Get(contentType) != nil
+}
+
+func isJSON(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && (mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"))
+}
+
+// validatingWriter buffers JSON responses to validate them before they are sent.
+// Other responses are sent as they are written.
+type validatingWriter struct {
+	http.ResponseWriter
+	status    int
+	started   bool
+	buffering bool
+	buf       bytes.Buffer
+}
+
+// WriteHeader records the status code of JSON responses and sends it for others.
+func (v *validatingWriter) WriteHeader(statusCode int) {
+	if v.started {
+		return
+	}
+	if statusCode < http.StatusOK {
+		v.ResponseWriter.WriteHeader(statusCode)
+		return
+	}
+	v.started = true
+	v.status = statusCode
+	v.buffering = isJSON(v.Header().Get("Content-Type"))
+	if !v.buffering {
+		v.ResponseWriter.WriteHeader(statusCode)
+	}
+}
+
+// Write buffers the body of JSON responses and sends the body of others.
+func (v *validatingWriter) Write(p []byte) (int, error) {
+	if !v.started {
+		v.WriteHeader(http.StatusOK)
+	}
+	if v.buffering {
+		return v.buf.Write(p)
+	}
+	return v.ResponseWriter.Write(p)
+}
+
+// FlushError flushes responses that are not buffered.
+func (v *validatingWriter) FlushError() error {
+	if v.buffering {
+		return nil
+	}
+	return http.NewResponseController(v.ResponseWriter).Flush()
+}
+
+// Unwrap returns the underlying http.ResponseWriter for http.ResponseController.
+func (v *validatingWriter) Unwrap() http.ResponseWriter {
+	return v.ResponseWriter
+}
@@ -2,7 +2,11 @@ package pb_test
 
 import (
 	"context"
+	"database/sql"
+	"database/sql/driver"
 	"net"
+	"net/http"
+	"strconv"
 	"testing"
 
 	. "github.com/onsi/ginkgo/v2"
@@ -18,12 +22,17 @@ import (
 	"github.com/golang/mock/gomock"
 	"github.com/grnsv/shortener/internal/api/middleware"
 	"github.com/grnsv/shortener/internal/api/pb"
+	"github.com/grnsv/shortener/internal/config"
 	"github.com/grnsv/shortener/internal/logger"
 	"github.com/grnsv/shortener/internal/mocks"
 	"github.com/grnsv/shortener/internal/models"
+	"github.com/grnsv/shortener/internal/qr"
+	"github.com/grnsv/shortener/internal/service"
 	"github.com/grnsv/shortener/internal/storage"
 )
 
+var signer = middleware.NewHMACSigner("secret")
+
 func TestPb(t *testing.T) {
 	RegisterFailHandler(Fail)
 	RunSpecs(t, "Pb Suite")
@@ -48,7 +57,11 @@ var _ = Describe("GRPCShortenerServer", func() {
 		Expect(err).To(BeNil())
 		listener, err = net.Listen("tcp", ":0")
 		Expect(err).To(BeNil())
-		server = grpc.NewServer(grpc.UnaryInterceptor(middleware.GRPCAuthenticateInterceptor("secret", log)))
+		server = grpc.NewServer(grpc.ChainUnaryInterceptor(
+			middleware.GRPCAuthenticateInterceptor(signer, mockShortener, log),
+			middleware.GRPCRequestInfoInterceptor(),
+			middleware.GRPCWorkspaceInterceptor(),
+		))
 		pb.RegisterShortenerServer(server, pb.NewGRPCShortenerServer(mockShortener, log))
 		go func() {
 			serverErr := server.Serve(listener)
@@ -124,7 +137,7 @@ var _ = Describe("GRPCShortenerServer", func() {
 		})
 		When("request has empty user ID", func() {
 			It("returns status Unauthenticated", func() {
-				jwtString, err := middleware.BuildJWTString("secret", "")
+				jwtString, err := middleware.BuildJWTString(signer, "")
 				Expect(err).To(BeNil())
 				ctx := metadata.NewOutgoingContext(context.Background(), metadata.Pairs("token", jwtString))
 				var header metadata.MD
@@ -141,24 +154,58 @@ var _ = Describe("GRPCShortenerServer", func() {
 		const userID = "ffffffff-ffff-ffff-ffff-ffffffffffff"
 		var ctx context.Context
 		BeforeEach(func() {
-			jwtString, err := middleware.BuildJWTString("secret", userID)
+			jwtString, err := middleware.BuildJWTString(signer, userID)
 			Expect(err).To(BeNil())
 			ctx = metadata.NewOutgoingContext(context.Background(), metadata.Pairs("token", jwtString))
 		})
 		It("returns short url", func() {
-			mockShortener.EXPECT().ShortenURL(gomock.Any(), "http://example.com", userID).Return("short", false, nil)
+			mockShortener.EXPECT().ShortenURL(gomock.Any(), "http://example.com", userID, gomock.Any()).Return("short", false, nil)
 			resp, err := client.ShortenURL(ctx, &pb.ShortenRequest{Url: "http://example.com"})
 			Expect(err).To(BeNil())
 			Expect(resp.Result).To(Equal("short"))
 		})
 		When("url exists", func() {
 			It("returns AlreadyExists", func() {
-				mockShortener.EXPECT().ShortenURL(gomock.Any(), "http://example.com", userID).Return("short", true, nil)
+				mockShortener.EXPECT().ShortenURL(gomock.Any(), "http://example.com", userID, gomock.Any()).Return("short", true, nil)
 				_, err := client.ShortenURL(ctx, &pb.ShortenRequest{Url: "http://example.com"})
 				Expect(err).To(HaveOccurred())
 				Expect(status.Code(err)).To(Equal(codes.AlreadyExists))
 			})
 		})
+		When("the quota is exceeded", func() {
+			It("returns ResourceExhausted", func() {
+				mockShortener.EXPECT().ShortenURL(gomock.Any(), "http://example.com", userID, gomock.Any()).Return("", false, service.ErrQuotaExceeded)
+				_, err := client.ShortenURL(ctx, &pb.ShortenRequest{Url: "http://example.com"})
+				Expect(status.Code(err)).To(Equal(codes.ResourceExhausted))
+			})
+		})
+		When("a custom domain is given", func() {
+			It("shortens the url on the domain", func() {
+				var opts []service.ShortenOption
+				mockShortener.EXPECT().ShortenURL(gomock.Any(), "http://example.com", userID, gomock.Any()).DoAndReturn(
+					func(_ context.Context, _ string, _ string, options ...service.ShortenOption) (string, bool, error) {
+						opts = options
+						return "https://go.brand.com/short", false, nil
+					})
+				resp, err := client.ShortenURL(metadata.AppendToOutgoingContext(ctx, pb.DomainMetadataKey, "go.brand.com"),
+					&pb.ShortenRequest{Url: "http://example.com"})
+				Expect(err).To(BeNil())
+				Expect(resp.Result).To(Equal("https://go.brand.com/short"))
+
+				url := models.URL{OriginalURL: "http://example.com"}
+				for _, opt := range opts {
+					Expect(opt(&url)).To(Succeed())
+				}
+				domain, _ := models.SplitLinkKey(url.ShortURL)
+				Expect(domain).To(Equal("go.brand.com"))
+			})
+			It("returns FailedPrecondition for domains the user has not verified", func() {
+				mockShortener.EXPECT().ShortenURL(gomock.Any(), "http://example.com", userID, gomock.Any()).Return("", false, service.ErrDomainNotVerified)
+				_, err := client.ShortenURL(metadata.AppendToOutgoingContext(ctx, pb.DomainMetadataKey, "brand.link"),
+					&pb.ShortenRequest{Url: "http://example.com"})
+				Expect(status.Code(err)).To(Equal(codes.FailedPrecondition))
+			})
+		})
 		When("url is empty", func() {
 			It("returns error", func() {
 				_, err := client.ShortenURL(ctx, &pb.ShortenRequest{Url: ""})
@@ -166,20 +213,27 @@ var _ = Describe("GRPCShortenerServer", func() {
 				Expect(status.Code(err)).To(Equal(codes.InvalidArgument))
 			})
 		})
+		When("url is not an absolute http URL", func() {
+			It("returns error", func() {
+				mockShortener.EXPECT().ShortenURL(gomock.Any(), "example.com", userID, gomock.Any()).Return("", false, service.ErrInvalidURL)
+				_, err := client.ShortenURL(ctx, &pb.ShortenRequest{Url: "example.com"})
+				Expect(status.Code(err)).To(Equal(codes.InvalidArgument))
+			})
+		})
 	})
 
 	Context("GetURLs", func() {
 		const userID = "ffffffff-ffff-ffff-ffff-ffffffffffff"
 		var ctx context.Context
 		BeforeEach(func() {
-			jwtString, err := middleware.BuildJWTString("secret", userID)
+			jwtString, err := middleware.BuildJWTString(signer, userID)
 			Expect(err).To(BeNil())
 			ctx = metadata.NewOutgoingContext(context.Background(), metadata.Pairs("token", jwtString))
 		})
 		When("storage does not have stored urls", func() {
 			It("returns empty list", func() {
-				mockShortener.EXPECT().GetAll(gomock.Any(), userID).Return(nil, nil)
-				resp, err := client.GetURLs(ctx, &pb.Empty{})
+				mockShortener.EXPECT().GetAll(gomock.Any(), userID, models.URLFilter{}).Return(nil, nil)
+				resp, err := client.GetURLs(ctx, &pb.GetURLsRequest{})
 				Expect(err).To(BeNil())
 				Expect(resp.Urls).To(BeEmpty())
 			})
@@ -198,19 +252,49 @@ var _ = Describe("GRPCShortenerServer", func() {
 						OriginalURL: "http://example.com/2",
 					},
 				}
-				mockShortener.EXPECT().GetAll(gomock.Any(), userID).Return(urls, nil)
-				resp, err := client.GetURLs(ctx, &pb.Empty{})
+				mockShortener.EXPECT().GetAll(gomock.Any(), userID, models.URLFilter{}).Return(urls, nil)
+				resp, err := client.GetURLs(ctx, &pb.GetURLsRequest{})
 				Expect(err).To(BeNil())
 				Expect(len(urls)).To(Equal(len(resp.Urls)))
 			})
 		})
+		When("filtering by tags", func() {
+			It("returns the metadata of matching urls", func() {
+				urls := []models.URL{{
+					ShortURL:    "00000001",
+					OriginalURL: "http://example.com/1",
+					URLMetadata: models.URLMetadata{Title: "Example", Tags: models.Tags{"docs", "work"}},
+				}}
+				mockShortener.EXPECT().GetAll(gomock.Any(), userID, models.URLFilter{Tags: []string{"work"}}).Return(urls, nil)
+				resp, err := client.GetURLs(ctx, &pb.GetURLsRequest{Tags: []string{"work"}})
+				Expect(err).To(BeNil())
+				Expect(resp.Urls).To(HaveLen(1))
+				Expect(resp.Urls[0].Title).To(Equal("Example"))
+				Expect(resp.Urls[0].Tags).To(Equal([]string{"docs", "work"}))
+			})
+		})
+		When("searching", func() {
+			It("returns the matching urls", func() {
+				urls := []models.URL{{ShortURL: "00000001", OriginalURL: "http://example.com/q3-report"}}
+				mockShortener.EXPECT().SearchURLs(gomock.Any(), userID, "q3", 10).Return(urls, nil)
+				resp, err := client.SearchURLs(ctx, &pb.SearchURLsRequest{Query: "q3", Limit: 10})
+				Expect(err).To(BeNil())
+				Expect(resp.Urls).To(HaveLen(1))
+				Expect(resp.Urls[0].OriginalUrl).To(Equal("http://example.com/q3-report"))
+			})
+			It("rejects invalid queries", func() {
+				mockShortener.EXPECT().SearchURLs(gomock.Any(), userID, "", 0).Return(nil, service.ErrInvalidQuery)
+				_, err := client.SearchURLs(ctx, &pb.SearchURLsRequest{})
+				Expect(status.Code(err)).To(Equal(codes.InvalidArgument))
+			})
+		})
 	})
 
 	Context("ShortenBatch", func() {
 		const userID = "ffffffff-ffff-ffff-ffff-ffffffffffff"
 		var ctx context.Context
 		BeforeEach(func() {
-			jwtString, err := middleware.BuildJWTString("secret", userID)
+			jwtString, err := middleware.BuildJWTString(signer, userID)
 			Expect(err).To(BeNil())
 			ctx = metadata.NewOutgoingContext(context.Background(), metadata.Pairs("token", jwtString))
 		})
@@ -221,12 +305,12 @@ var _ = Describe("GRPCShortenerServer", func() {
 					{CorrelationID: "2", OriginalURL: "http://example.com/2"},
 				}
 				modelsResp := models.BatchResponse{
-					{CorrelationID: "1", ShortURL: "http://localhost:8080/short1"},
-					{CorrelationID: "2", ShortURL: "http://localhost:8080/short2"},
+					{CorrelationID: "1", ShortURL: "http://localhost:8080/short1", Status: models.BatchCreated},
+					{CorrelationID: "2", Status: models.BatchInvalid, Error: "invalid URL"},
 				}
-				mockShortener.EXPECT().ShortenBatch(gomock.Any(), modelsReq, userID).Return(modelsResp, nil)
+				mockShortener.EXPECT().ShortenBatch(gomock.Any(), modelsReq, userID, "retry-1", gomock.Any()).Return(modelsResp, nil)
 
-				resp, err := client.ShortenBatch(ctx, &pb.BatchRequest{Items: []*pb.BatchRequestItem{
+				resp, err := client.ShortenBatch(metadata.AppendToOutgoingContext(ctx, "idempotency-key", "retry-1"), &pb.BatchRequest{Items: []*pb.BatchRequestItem{
 					{CorrelationId: modelsReq[0].CorrelationID, OriginalUrl: modelsReq[0].OriginalURL},
 					{CorrelationId: modelsReq[1].CorrelationID, OriginalUrl: modelsReq[1].OriginalURL},
 				}})
@@ -234,8 +318,56 @@ var _ = Describe("GRPCShortenerServer", func() {
 				Expect(resp.Items).To(HaveLen(2))
 				Expect(resp.Items[0].CorrelationId).To(Equal(modelsResp[0].CorrelationID))
 				Expect(resp.Items[0].ShortUrl).To(Equal(modelsResp[0].ShortURL))
+				Expect(resp.Items[0].Status).To(Equal(models.BatchCreated))
 				Expect(resp.Items[1].CorrelationId).To(Equal(modelsResp[1].CorrelationID))
-				Expect(resp.Items[1].ShortUrl).To(Equal(modelsResp[1].ShortURL))
+				Expect(resp.Items[1].ShortUrl).To(BeEmpty())
+				Expect(resp.Items[1].Status).To(Equal(models.BatchInvalid))
+				Expect(resp.Items[1].Error).To(Equal("invalid URL"))
+			})
+		})
+		When("the idempotency key was used for another batch", func() {
+			It("returns FailedPrecondition", func() {
+				mockShortener.EXPECT().ShortenBatch(gomock.Any(), gomock.Any(), userID, "retry-1", gomock.Any()).Return(nil, service.ErrIdempotencyKeyReused)
+
+				_, err := client.ShortenBatch(metadata.AppendToOutgoingContext(ctx, "idempotency-key", "retry-1"), &pb.BatchRequest{Items: []*pb.BatchRequestItem{
+					{CorrelationId: "1", OriginalUrl: "http://example.com/1"},
+				}})
+				Expect(status.Code(err)).To(Equal(codes.FailedPrecondition))
+			})
+		})
+		When("the quota is exceeded", func() {
+			It("returns ResourceExhausted", func() {
+				mockShortener.EXPECT().ShortenBatch(gomock.Any(), gomock.Any(), userID, "", gomock.Any()).Return(nil, service.ErrQuotaExceeded)
+
+				_, err := client.ShortenBatch(ctx, &pb.BatchRequest{Items: []*pb.BatchRequestItem{
+					{CorrelationId: "1", OriginalUrl: "http://example.com/1"},
+				}})
+				Expect(status.Code(err)).To(Equal(codes.ResourceExhausted))
+			})
+		})
+		When("a custom domain is given", func() {
+			It("shortens the urls on the domain", func() {
+				var opt service.ShortenOption
+				mockShortener.EXPECT().ShortenBatch(gomock.Any(), gomock.Any(), userID, "", gomock.Any()).DoAndReturn(
+					func(_ context.Context, _ models.BatchRequest, _ string, _ string, option service.ShortenOption) (models.BatchResponse, error) {
+						opt = option
+						return models.BatchResponse{{CorrelationID: "1", ShortURL: "https://go.brand.com/one", Status: models.BatchCreated}}, nil
+					})
+				resp, err := client.ShortenBatch(metadata.AppendToOutgoingContext(ctx, pb.DomainMetadataKey, "go.brand.com"),
+					&pb.BatchRequest{Items: []*pb.BatchRequestItem{{CorrelationId: "1", OriginalUrl: "http://example.com/1"}}})
+				Expect(err).To(BeNil())
+				Expect(resp.Items[0].ShortUrl).To(Equal("https://go.brand.com/one"))
+
+				url := models.URL{OriginalURL: "http://example.com/1"}
+				Expect(opt(&url)).To(Succeed())
+				domain, _ := models.SplitLinkKey(url.ShortURL)
+				Expect(domain).To(Equal("go.brand.com"))
+			})
+			It("returns FailedPrecondition for domains the user has not verified", func() {
+				mockShortener.EXPECT().ShortenBatch(gomock.Any(), gomock.Any(), userID, "", gomock.Any()).Return(nil, service.ErrDomainNotVerified)
+				_, err := client.ShortenBatch(metadata.AppendToOutgoingContext(ctx, pb.DomainMetadataKey, "brand.link"),
+					&pb.BatchRequest{Items: []*pb.BatchRequestItem{{CorrelationId: "1", OriginalUrl: "http://example.com/1"}}})
+				Expect(status.Code(err)).To(Equal(codes.FailedPrecondition))
 			})
 		})
 		When("batch request is empty", func() {
@@ -245,13 +377,23 @@ var _ = Describe("GRPCShortenerServer", func() {
 				Expect(status.Code(err)).To(Equal(codes.InvalidArgument))
 			})
 		})
+		When("batch request is too long", func() {
+			It("returns ResourceExhausted", func() {
+				items := make([]*pb.BatchRequestItem, config.DefaultMaxBatchSize+1)
+				for i := range items {
+					items[i] = &pb.BatchRequestItem{CorrelationId: strconv.Itoa(i), OriginalUrl: "http://example.com"}
+				}
+				_, err := client.ShortenBatch(ctx, &pb.BatchRequest{Items: items})
+				Expect(status.Code(err)).To(Equal(codes.ResourceExhausted))
+			})
+		})
 	})
 
 	Context("DeleteURLs", func() {
 		const userID = "ffffffff-ffff-ffff-ffff-ffffffffffff"
 		var ctx context.Context
 		BeforeEach(func() {
-			jwtString, err := middleware.BuildJWTString("secret", userID)
+			jwtString, err := middleware.BuildJWTString(signer, userID)
 			Expect(err).To(BeNil())
 			ctx = metadata.NewOutgoingContext(context.Background(), metadata.Pairs("token", jwtString))
 		})
@@ -281,11 +423,127 @@ var _ = Describe("GRPCShortenerServer", func() {
 		})
 	})
 
+	Context("RestoreURL", func() {
+		const userID = "ffffffff-ffff-ffff-ffff-ffffffffffff"
+		var ctx context.Context
+		BeforeEach(func() {
+			jwtString, err := middleware.BuildJWTString(signer, userID)
+			Expect(err).To(BeNil())
+			ctx = metadata.NewOutgoingContext(context.Background(), metadata.Pairs("token", jwtString))
+		})
+		When("URL was deleted within the retention window", func() {
+			It("returns OK", func() {
+				mockShortener.EXPECT().RestoreURL(gomock.Any(), userID, "short1").Return(nil)
+				_, err := client.RestoreURL(ctx, &pb.RestoreURLRequest{Id: "short1"})
+				Expect(err).To(BeNil())
+			})
+		})
+		When("URL cannot be restored", func() {
+			It("returns NotFound", func() {
+				mockShortener.EXPECT().RestoreURL(gomock.Any(), userID, "short1").Return(storage.ErrNotFound)
+				_, err := client.RestoreURL(ctx, &pb.RestoreURLRequest{Id: "short1"})
+				Expect(status.Code(err)).To(Equal(codes.NotFound))
+			})
+		})
+	})
+
+	Context("Workspaces", func() {
+		const userID = "ffffffff-ffff-ffff-ffff-ffffffffffff"
+		It("passes the workspace of the metadata to the service and denies users who are not members", func() {
+			store, err := storage.NewMemoryStorage(context.Background())
+			Expect(err).To(BeNil())
+			workspaces := service.NewShortener(store, store, store, store, "http://short", service.WithWorkspaces(store))
+			mockShortener.EXPECT().DeleteMany(gomock.Any(), userID, []string{"short1"}).
+				DoAndReturn(func(ctx context.Context, userID string, _ []string) error {
+					_, ownerErr := workspaces.LinkOwner(ctx, userID, models.ScopeDelete)
+					return ownerErr
+				})
+
+			jwtString, err := middleware.BuildJWTString(signer, userID)
+			Expect(err).To(BeNil())
+			ctx := metadata.NewOutgoingContext(context.Background(), metadata.Pairs(
+				"token", jwtString,
+				middleware.WorkspaceMetadataKey, "11111111-1111-1111-1111-111111111111",
+			))
+			_, err = client.DeleteURLs(ctx, &pb.DeleteURLsRequest{ShortUrls: []string{"short1"}})
+			Expect(status.Code(err)).To(Equal(codes.PermissionDenied))
+		})
+	})
+
+	Context("GetQRCode", func() {
+		When("URL exists", func() {
+			It("returns the rendered image", func() {
+				mockShortener.EXPECT().GetQRCode(gomock.Any(), "short1", qr.Options{Format: "svg", Size: 128, Level: "Q", Margin: 0}).
+					Return(&qr.Image{Data: []byte("<svg/>"), ContentType: "image/svg+xml"}, nil)
+				margin := int32(0)
+				resp, err := client.GetQRCode(context.Background(), &pb.GetQRCodeRequest{Id: "short1", Format: "svg", Size: 128, Level: "Q", Margin: &margin})
+				Expect(err).To(BeNil())
+				Expect(resp.ContentType).To(Equal("image/svg+xml"))
+				Expect(resp.Image).To(Equal([]byte("<svg/>")))
+			})
+		})
+		When("margin is unset", func() {
+			It("uses the default margin", func() {
+				mockShortener.EXPECT().GetQRCode(gomock.Any(), "short1", qr.Options{Margin: qr.DefaultMargin}).
+					Return(&qr.Image{Data: []byte("png"), ContentType: "image/png"}, nil)
+				_, err := client.GetQRCode(context.Background(), &pb.GetQRCodeRequest{Id: "short1"})
+				Expect(err).To(BeNil())
+			})
+		})
+		When("options are invalid", func() {
+			It("returns InvalidArgument", func() {
+				mockShortener.EXPECT().GetQRCode(gomock.Any(), "short1", gomock.Any()).Return(nil, qr.ErrInvalidOptions)
+				_, err := client.GetQRCode(context.Background(), &pb.GetQRCodeRequest{Id: "short1", Format: "gif"})
+				Expect(status.Code(err)).To(Equal(codes.InvalidArgument))
+			})
+		})
+		When("URL does not exist", func() {
+			It("returns NotFound", func() {
+				mockShortener.EXPECT().GetQRCode(gomock.Any(), "missing", gomock.Any()).Return(nil, storage.ErrNotFound)
+				_, err := client.GetQRCode(context.Background(), &pb.GetQRCodeRequest{Id: "missing"})
+				Expect(status.Code(err)).To(Equal(codes.NotFound))
+			})
+		})
+	})
+
+	Context("UpdateURL", func() {
+		const userID = "ffffffff-ffff-ffff-ffff-ffffffffffff"
+		var ctx context.Context
+		BeforeEach(func() {
+			jwtString, err := middleware.BuildJWTString(signer, userID)
+			Expect(err).To(BeNil())
+			ctx = metadata.NewOutgoingContext(context.Background(), metadata.Pairs("token", jwtString))
+		})
+		When("update request is valid", func() {
+			It("returns the updated URL", func() {
+				mockShortener.EXPECT().UpdateURL(gomock.Any(), userID, "short1", models.UpdateURLRequest{URL: "http://example.com/fixed"}).
+					Return(&models.URL{UserID: userID, ShortURL: "short1", OriginalURL: "http://example.com/fixed"}, nil)
+				resp, err := client.UpdateURL(ctx, &pb.UpdateURLRequest{Id: "short1", Url: "http://example.com/fixed"})
+				Expect(err).To(BeNil())
+				Expect(resp.OriginalUrl).To(Equal("http://example.com/fixed"))
+			})
+		})
+		When("URL is invalid", func() {
+			It("returns InvalidArgument", func() {
+				mockShortener.EXPECT().UpdateURL(gomock.Any(), userID, "short1", models.UpdateURLRequest{URL: "bad"}).Return(nil, service.ErrInvalidURL)
+				_, err := client.UpdateURL(ctx, &pb.UpdateURLRequest{Id: "short1", Url: "bad"})
+				Expect(status.Code(err)).To(Equal(codes.InvalidArgument))
+			})
+		})
+		When("URL belongs to another user", func() {
+			It("returns NotFound", func() {
+				mockShortener.EXPECT().UpdateURL(gomock.Any(), userID, "foreign", gomock.Any()).Return(nil, storage.ErrNotFound)
+				_, err := client.UpdateURL(ctx, &pb.UpdateURLRequest{Id: "foreign", Url: "http://example.com/"})
+				Expect(status.Code(err)).To(Equal(codes.NotFound))
+			})
+		})
+	})
+
 	Context("GetStats", func() {
 		const userID = "ffffffff-ffff-ffff-ffff-ffffffffffff"
 		var ctx context.Context
 		BeforeEach(func() {
-			jwtString, err := middleware.BuildJWTString("secret", userID)
+			jwtString, err := middleware.BuildJWTString(signer, userID)
 			Expect(err).To(BeNil())
 			ctx = metadata.NewOutgoingContext(context.Background(), metadata.Pairs("token", jwtString))
 		})
@@ -313,26 +571,58 @@ var _ = Describe("GRPCShortenerServer", func() {
 		const userID = "ffffffff-ffff-ffff-ffff-ffffffffffff"
 		var ctx context.Context
 		BeforeEach(func() {
-			jwtString, err := middleware.BuildJWTString("secret", userID)
+			jwtString, err := middleware.BuildJWTString(signer, userID)
 			Expect(err).To(BeNil())
 			ctx = metadata.NewOutgoingContext(context.Background(), metadata.Pairs("token", jwtString))
 		})
 		When("short URL exists", func() {
 			It("returns the original URL", func() {
-				mockShortener.EXPECT().ExpandURL(gomock.Any(), "short1").Return("http://example.com/1", nil)
+				mockShortener.EXPECT().ExpandURL(gomock.Any(), "short1").Return("http://example.com/1", http.StatusMovedPermanently, nil)
 				resp, err := client.ExpandURL(ctx, &pb.ExpandRequest{Id: "short1"})
 				Expect(err).To(BeNil())
 				Expect(resp.Url).To(Equal("http://example.com/1"))
+				Expect(resp.RedirectCode).To(Equal(int32(http.StatusMovedPermanently)))
 			})
 		})
 		When("short URL does not exist", func() {
 			It("returns NotFound", func() {
-				mockShortener.EXPECT().ExpandURL(gomock.Any(), "deleted").Return("", storage.ErrDeleted)
+				mockShortener.EXPECT().ExpandURL(gomock.Any(), "deleted").Return("", 0, storage.ErrDeleted)
 				_, err := client.ExpandURL(ctx, &pb.ExpandRequest{Id: "deleted"})
 				Expect(err).To(HaveOccurred())
 				Expect(status.Code(err)).To(Equal(codes.NotFound))
 			})
 		})
+		When("short URL has an interstitial page", func() {
+			It("returns the original URL flagged as interstitial", func() {
+				mockShortener.EXPECT().ExpandURL(gomock.Any(), "preview").Return("", 0, service.ErrInterstitial)
+				mockShortener.EXPECT().PreviewURL(gomock.Any(), "preview").
+					Return(&models.Preview{ShortURL: "http://localhost/preview", OriginalURL: "http://example.com/1"}, nil)
+				resp, err := client.ExpandURL(ctx, &pb.ExpandRequest{Id: "preview"})
+				Expect(err).To(BeNil())
+				Expect(resp.Url).To(Equal("http://example.com/1"))
+				Expect(resp.Interstitial).To(BeTrue())
+			})
+		})
+		When("short URL is password-protected", func() {
+			It("returns PermissionDenied without the password", func() {
+				mockShortener.EXPECT().ExpandURL(gomock.Any(), "locked").Return("", 0, service.ErrPasswordRequired)
+				_, err := client.ExpandURL(ctx, &pb.ExpandRequest{Id: "locked"})
+				Expect(status.Code(err)).To(Equal(codes.PermissionDenied))
+			})
+			It("returns the original URL and redirect code with the password", func() {
+				mockShortener.EXPECT().UnlockURL(gomock.Any(), "locked", "secret", gomock.Any()).Return("http://example.com/1", "token", nil)
+				mockShortener.EXPECT().ExpandUnlockedURL(gomock.Any(), "locked", "token").Return("http://example.com/1", http.StatusPermanentRedirect, nil)
+				resp, err := client.ExpandURL(ctx, &pb.ExpandRequest{Id: "locked", Password: "secret"})
+				Expect(err).To(BeNil())
+				Expect(resp.Url).To(Equal("http://example.com/1"))
+				Expect(resp.RedirectCode).To(Equal(int32(http.StatusPermanentRedirect)))
+			})
+			It("returns ResourceExhausted when throttled", func() {
+				mockShortener.EXPECT().UnlockURL(gomock.Any(), "locked", "wrong", gomock.Any()).Return("", "", service.ErrTooManyAttempts)
+				_, err := client.ExpandURL(ctx, &pb.ExpandRequest{Id: "locked", Password: "wrong"})
+				Expect(status.Code(err)).To(Equal(codes.ResourceExhausted))
+			})
+		})
 		When("short URL is empty", func() {
 			It("returns InvalidArgument", func() {
 				_, err := client.ExpandURL(ctx, &pb.ExpandRequest{Id: ""})
@@ -341,4 +631,103 @@ var _ = Describe("GRPCShortenerServer", func() {
 			})
 		})
 	})
+
+	Context("APIKey", func() {
+		const userID = "ffffffff-ffff-ffff-ffff-ffffffffffff"
+		bearer := func(key string) context.Context {
+			return metadata.NewOutgoingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+key))
+		}
+		When("key is valid", func() {
+			It("authenticates the request", func() {
+				mockShortener.EXPECT().VerifyAPIKey(gomock.Any(), "sk_valid").Return(&models.APIKey{UserID: userID}, nil)
+				mockShortener.EXPECT().GetAll(gomock.Any(), userID, models.URLFilter{}).Return(nil, nil)
+				_, err := client.GetURLs(bearer("sk_valid"), &pb.GetURLsRequest{})
+				Expect(err).To(BeNil())
+			})
+		})
+		When("key is invalid", func() {
+			It("returns Unauthenticated", func() {
+				mockShortener.EXPECT().VerifyAPIKey(gomock.Any(), "sk_invalid").Return(nil, service.ErrInvalidAPIKey)
+				_, err := client.GetURLs(bearer("sk_invalid"), &pb.GetURLsRequest{})
+				Expect(status.Code(err)).To(Equal(codes.Unauthenticated))
+			})
+		})
+		When("key lacks the scope", func() {
+			It("returns PermissionDenied", func() {
+				mockShortener.EXPECT().VerifyAPIKey(gomock.Any(), "sk_read").
+					Return(&models.APIKey{UserID: userID, Scopes: models.Scopes{models.ScopeRead}}, nil)
+				_, err := client.ShortenURL(bearer("sk_read"), &pb.ShortenRequest{Url: "http://example.com"})
+				Expect(status.Code(err)).To(Equal(codes.PermissionDenied))
+			})
+		})
+		When("the method is not available to scoped keys", func() {
+			It("returns PermissionDenied", func() {
+				mockShortener.EXPECT().VerifyAPIKey(gomock.Any(), "sk_read").
+					Return(&models.APIKey{UserID: userID, Scopes: models.Scopes{models.ScopeRead}}, nil)
+				_, err := client.GetStats(bearer("sk_read"), &pb.Empty{})
+				Expect(status.Code(err)).To(Equal(codes.PermissionDenied))
+			})
+		})
+		When("creating a key", func() {
+			It("returns the key", func() {
+				jwtString, err := middleware.BuildJWTString(signer, userID)
+				Expect(err).To(BeNil())
+				ctx := metadata.NewOutgoingContext(context.Background(), metadata.Pairs("token", jwtString))
+				mockShortener.EXPECT().CreateAPIKey(gomock.Any(), userID, "ci", []string{models.ScopeShorten}).
+					Return(&models.CreateAPIKeyResponse{APIKey: models.APIKey{ID: "id", Name: "ci"}, Key: "sk_new"}, nil)
+				resp, err := client.CreateAPIKey(ctx, &pb.CreateAPIKeyRequest{Name: "ci", Scopes: []string{models.ScopeShorten}})
+				Expect(err).To(BeNil())
+				Expect(resp.Key).To(Equal("sk_new"))
+				Expect(resp.ApiKey.Name).To(Equal("ci"))
+			})
+		})
+	})
+})
+
+var _ = Describe("GRPCShortenerServer on a database", func() {
+	var (
+		ctrl   *gomock.Controller
+		stmt   *mocks.MockStmt
+		server *grpc.Server
+		client pb.ShortenerClient
+		conn   *grpc.ClientConn
+	)
+
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		db := mocks.NewMockDB(ctrl)
+		stmt = mocks.NewMockStmt(ctrl)
+		db.EXPECT().ExecContext(gomock.Any(), gomock.Any()).Return(driver.RowsAffected(0), nil).Times(2)
+		db.EXPECT().PreparexContext(gomock.Any(), gomock.Any()).Return(stmt, nil).AnyTimes()
+		store, err := storage.NewDBStorage(context.Background(), db)
+		Expect(err).To(BeNil())
+		shortener := service.NewShortener(store, store, store, store, "http://localhost:8080")
+		log, err := logger.New("testing")
+		Expect(err).To(BeNil())
+		listener, err := net.Listen("tcp", ":0")
+		Expect(err).To(BeNil())
+		server = grpc.NewServer(grpc.ChainUnaryInterceptor(
+			middleware.GRPCAuthenticateInterceptor(signer, shortener, log),
+		))
+		pb.RegisterShortenerServer(server, pb.NewGRPCShortenerServer(shortener, log))
+		go func() {
+			defer GinkgoRecover()
+			Expect(server.Serve(listener)).To(Succeed())
+		}()
+		conn, err = grpc.NewClient(listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+		Expect(err).ToNot(HaveOccurred())
+		client = pb.NewShortenerClient(conn)
+	})
+
+	AfterEach(func() {
+		server.Stop()
+		Expect(conn.Close()).To(Succeed())
+		ctrl.Finish()
+	})
+
+	It("returns NotFound for unknown short URLs", func() {
+		stmt.EXPECT().GetContext(gomock.Any(), gomock.Any(), "unknown").Return(sql.ErrNoRows)
+		_, err := client.ExpandURL(context.Background(), &pb.ExpandRequest{Id: "unknown"})
+		Expect(status.Code(err)).To(Equal(codes.NotFound))
+	})
 })
@@ -4,30 +4,57 @@ package pb
 import (
 	"context"
 	"errors"
+	"net"
 
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
 
 	"github.com/grnsv/shortener/internal/api/middleware"
+	"github.com/grnsv/shortener/internal/config"
 	"github.com/grnsv/shortener/internal/logger"
 	"github.com/grnsv/shortener/internal/models"
+	"github.com/grnsv/shortener/internal/qr"
 	"github.com/grnsv/shortener/internal/service"
 	"github.com/grnsv/shortener/internal/storage"
 )
 
+// DomainMetadataKey is the gRPC metadata key naming the custom domain to shorten links on, see service.WithDomain.
+const DomainMetadataKey = "domain"
+
 // GRPCShortenerServer implements the gRPC Shortener service.
 type GRPCShortenerServer struct {
 	UnimplementedShortenerServer
-	shortener service.Shortener // Service for URL shortening logic
-	logger    logger.Logger     // Logger for error and info messages
+	shortener    service.Shortener // Service for URL shortening logic
+	logger       logger.Logger     // Logger for error and info messages
+	maxBatchSize int               // Maximum number of URLs shortened or deleted in a batch
+}
+
+// ServerOption configures a GRPCShortenerServer.
+type ServerOption func(*GRPCShortenerServer)
+
+// WithMaxBatchSize sets the maximum number of URLs shortened or deleted in a batch.
+func WithMaxBatchSize(size int) ServerOption {
+	return func(s *GRPCShortenerServer) {
+		s.maxBatchSize = size
+	}
 }
 
 // NewGRPCShortenerServer creates a new instance of GRPCShortenerServer.
-func NewGRPCShortenerServer(shortener service.Shortener, logger logger.Logger) *GRPCShortenerServer {
-	return &GRPCShortenerServer{shortener: shortener, logger: logger}
+// Batches are limited to config.DefaultMaxBatchSize URLs unless WithMaxBatchSize is given.
+func NewGRPCShortenerServer(shortener service.Shortener, logger logger.Logger, opts ...ServerOption) *GRPCShortenerServer {
+	s := &GRPCShortenerServer{shortener: shortener, logger: logger, maxBatchSize: config.DefaultMaxBatchSize}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
-// ShortenURL shortens a given URL for the authenticated user.
+// ShortenURL shortens a given URL for the authenticated user, on the custom domain named by
+// the DomainMetadataKey metadata if any. A domain the user has not verified is answered with FailedPrecondition.
+// A URL past the quota of the user's plan is answered with ResourceExhausted.
 func (s *GRPCShortenerServer) ShortenURL(ctx context.Context, in *ShortenRequest) (*ShortenResponse, error) {
 	userID, ok := ctx.Value(middleware.UserIDContextKey).(string)
 	if !ok {
@@ -39,8 +66,31 @@ func (s *GRPCShortenerServer) ShortenURL(ctx context.Context, in *ShortenRequest
 		return nil, status.Error(codes.InvalidArgument, "Empty url")
 	}
 
-	shortURL, alreadyExists, err := s.shortener.ShortenURL(ctx, in.Url, userID)
+	shortURL, alreadyExists, err := s.shortener.ShortenURL(ctx, in.Url, userID, service.WithPassword(in.Password),
+		service.WithInterstitial(in.Interstitial),
+		service.WithRedirectCode(int(in.RedirectCode)),
+		service.WithMetadata(models.URLMetadata{
+			Title:       in.Title,
+			Description: in.Description,
+			Notes:       in.Notes,
+			Tags:        in.Tags,
+		}),
+		service.WithDomain(incomingMetadata(ctx, DomainMetadataKey)),
+	)
 	if err != nil {
+		if st := workspaceStatus(err); st != nil {
+			return nil, st
+		}
+		if st := domainStatus(err); st != nil {
+			return nil, st
+		}
+		if errors.Is(err, service.ErrInvalidURL) || errors.Is(err, service.ErrInvalidRedirectCode) ||
+			errors.Is(err, service.ErrInvalidMetadata) {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		if errors.Is(err, service.ErrQuotaExceeded) {
+			return nil, status.Error(codes.ResourceExhausted, err.Error())
+		}
 		s.logger.Error(err)
 		return nil, status.Error(codes.Internal, err.Error())
 	}
@@ -52,7 +102,10 @@ func (s *GRPCShortenerServer) ShortenURL(ctx context.Context, in *ShortenRequest
 	return &ShortenResponse{Result: shortURL}, nil
 }
 
-// ShortenBatch shortens multiple URLs in a batch for the authenticated user.
+// ShortenBatch shortens multiple URLs in a batch for the authenticated user and reports the status of every item.
+// A batch retried with the same idempotency-key metadata gets the original response.
+// Like in ShortenURL, the links are shortened on the custom domain named by the DomainMetadataKey metadata if any.
+// A batch past the quota of the user's plan is answered with ResourceExhausted.
 func (s *GRPCShortenerServer) ShortenBatch(ctx context.Context, in *BatchRequest) (*BatchResponse, error) {
 	userID, ok := ctx.Value(middleware.UserIDContextKey).(string)
 	if !ok {
@@ -63,6 +116,9 @@ func (s *GRPCShortenerServer) ShortenBatch(ctx context.Context, in *BatchRequest
 	if in == nil || len(in.Items) == 0 {
 		return nil, status.Error(codes.InvalidArgument, "Empty batch request")
 	}
+	if len(in.Items) > s.maxBatchSize {
+		return nil, status.Errorf(codes.ResourceExhausted, "Batch of %d URLs exceeds the limit of %d", len(in.Items), s.maxBatchSize)
+	}
 
 	req := make([]models.BatchRequestItem, len(in.Items))
 	for i, item := range in.Items {
@@ -72,8 +128,22 @@ func (s *GRPCShortenerServer) ShortenBatch(ctx context.Context, in *BatchRequest
 		}
 	}
 
-	resp, err := s.shortener.ShortenBatch(ctx, req, userID)
-	if err != nil {
+	resp, err := s.shortener.ShortenBatch(ctx, req, userID, incomingMetadata(ctx, "idempotency-key"),
+		service.WithDomain(incomingMetadata(ctx, DomainMetadataKey)))
+	if st := workspaceStatus(err); st != nil {
+		return nil, st
+	}
+	if st := domainStatus(err); st != nil {
+		return nil, st
+	}
+	switch {
+	case errors.Is(err, service.ErrInvalidIdempotencyKey):
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, service.ErrIdempotencyKeyReused):
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, service.ErrQuotaExceeded):
+		return nil, status.Error(codes.ResourceExhausted, err.Error())
+	case err != nil:
 		s.logger.Error(err)
 		return nil, status.Error(codes.Internal, err.Error())
 	}
@@ -85,6 +155,8 @@ func (s *GRPCShortenerServer) ShortenBatch(ctx context.Context, in *BatchRequest
 		out.Items[i] = &BatchResponseItem{
 			CorrelationId: item.CorrelationID,
 			ShortUrl:      item.ShortURL,
+			Status:        item.Status,
+			Error:         item.Error,
 		}
 	}
 
@@ -92,14 +164,74 @@ func (s *GRPCShortenerServer) ShortenBatch(ctx context.Context, in *BatchRequest
 }
 
 // ExpandURL expands a shortened URL ID to its original URL.
+// Links with an interstitial page are expanded too and flagged in the response.
+// Password-protected links are expanded only with the correct password, which counts as a click
+// like following the link over HTTP; failed attempts are throttled.
 func (s *GRPCShortenerServer) ExpandURL(ctx context.Context, in *ExpandRequest) (*ExpandResponse, error) {
 	if in == nil || in.Id == "" {
 		return nil, status.Error(codes.InvalidArgument, "Empty id")
 	}
 
-	url, err := s.shortener.ExpandURL(ctx, in.Id)
+	var url string
+	var code int
+	var err error
+	if in.Password != "" {
+		var token string
+		if _, token, err = s.shortener.UnlockURL(ctx, in.Id, in.Password, peerAddr(ctx)); err == nil {
+			url, code, err = s.shortener.ExpandUnlockedURL(ctx, in.Id, token)
+		}
+	} else {
+		url, code, err = s.shortener.ExpandURL(ctx, in.Id)
+	}
+	if errors.Is(err, service.ErrInterstitial) {
+		var preview *models.Preview
+		if preview, err = s.shortener.PreviewURL(ctx, in.Id); err == nil {
+			return &ExpandResponse{Url: preview.OriginalURL, Interstitial: true}, nil
+		}
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrNotFound):
+			return nil, status.Error(codes.NotFound, "URL not found")
+		case errors.Is(err, storage.ErrDeleted):
+			return nil, status.Error(codes.NotFound, "URL deleted")
+		case errors.Is(err, service.ErrPasswordRequired), errors.Is(err, service.ErrInvalidPassword):
+			return nil, status.Error(codes.PermissionDenied, err.Error())
+		case errors.Is(err, service.ErrTooManyAttempts):
+			return nil, status.Error(codes.ResourceExhausted, err.Error())
+		}
+
+		s.logger.Error(err)
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &ExpandResponse{Url: url, RedirectCode: int32(code)}, nil
+}
+
+// GetQRCode renders a QR code of a shortened URL as a PNG or SVG image.
+func (s *GRPCShortenerServer) GetQRCode(ctx context.Context, in *GetQRCodeRequest) (*QRCode, error) {
+	if in == nil || in.Id == "" {
+		return nil, status.Error(codes.InvalidArgument, "Empty id")
+	}
+
+	opts := qr.Options{
+		Format: in.Format,
+		Size:   int(in.Size),
+		Level:  in.Level,
+		Margin: qr.DefaultMargin,
+	}
+	if in.Margin != nil {
+		opts.Margin = int(in.GetMargin())
+	}
+
+	img, err := s.shortener.GetQRCode(ctx, in.Id, opts)
 	if err != nil {
-		if errors.Is(err, storage.ErrDeleted) {
+		switch {
+		case errors.Is(err, qr.ErrInvalidOptions):
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		case errors.Is(err, storage.ErrNotFound):
+			return nil, status.Error(codes.NotFound, "URL not found")
+		case errors.Is(err, storage.ErrDeleted):
 			return nil, status.Error(codes.NotFound, "URL deleted")
 		}
 
@@ -107,7 +239,7 @@ func (s *GRPCShortenerServer) ExpandURL(ctx context.Context, in *ExpandRequest)
 		return nil, status.Error(codes.Internal, err.Error())
 	}
 
-	return &ExpandResponse{Url: url}, nil
+	return &QRCode{ContentType: img.ContentType, Image: img.Data}, nil
 }
 
 // PingDB checks the health of the database/storage.
@@ -120,27 +252,57 @@ func (s *GRPCShortenerServer) PingDB(ctx context.Context, in *Empty) (*Empty, er
 	return &Empty{}, nil
 }
 
-// GetURLs retrieves all shortened URLs for the authenticated user.
-func (s *GRPCShortenerServer) GetURLs(ctx context.Context, in *Empty) (*GetURLsResponse, error) {
+// GetURLs retrieves the shortened URLs of the authenticated user, optionally only those having all given tags.
+func (s *GRPCShortenerServer) GetURLs(ctx context.Context, in *GetURLsRequest) (*GetURLsResponse, error) {
 	userID, ok := ctx.Value(middleware.UserIDContextKey).(string)
 	if !ok {
 		s.logger.Error("user ID not found in context")
 		return nil, status.Error(codes.Unauthenticated, "Empty userID")
 	}
 
-	urls, err := s.shortener.GetAll(ctx, userID)
+	urls, err := s.shortener.GetAll(ctx, userID, models.URLFilter{Tags: in.GetTags()})
 	if err != nil {
+		if st := workspaceStatus(err); st != nil {
+			return nil, st
+		}
+		if errors.Is(err, service.ErrInvalidMetadata) {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
 		s.logger.Error(err)
 		return nil, status.Error(codes.Internal, err.Error())
 	}
 
 	resp := make([]*URLItem, len(urls))
 	for i, u := range urls {
-		resp[i] = &URLItem{
-			UserId:      u.UserID,
-			ShortUrl:    u.ShortURL,
-			OriginalUrl: u.OriginalURL,
+		resp[i] = urlItem(&u)
+	}
+
+	return &GetURLsResponse{Urls: resp}, nil
+}
+
+// SearchURLs searches the shortened URLs of the authenticated user by short code, destination, title and tags.
+func (s *GRPCShortenerServer) SearchURLs(ctx context.Context, in *SearchURLsRequest) (*GetURLsResponse, error) {
+	userID, ok := ctx.Value(middleware.UserIDContextKey).(string)
+	if !ok {
+		s.logger.Error("user ID not found in context")
+		return nil, status.Error(codes.Unauthenticated, "Empty userID")
+	}
+
+	urls, err := s.shortener.SearchURLs(ctx, userID, in.GetQuery(), int(in.GetLimit()))
+	if err != nil {
+		if st := workspaceStatus(err); st != nil {
+			return nil, st
+		}
+		if errors.Is(err, service.ErrInvalidQuery) {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
 		}
+		s.logger.Error(err)
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	resp := make([]*URLItem, len(urls))
+	for i, u := range urls {
+		resp[i] = urlItem(&u)
 	}
 
 	return &GetURLsResponse{Urls: resp}, nil
@@ -157,9 +319,41 @@ func (s *GRPCShortenerServer) DeleteURLs(ctx context.Context, in *DeleteURLsRequ
 	if in == nil || len(in.ShortUrls) == 0 {
 		return nil, status.Error(codes.InvalidArgument, "Empty short_urls")
 	}
+	if len(in.ShortUrls) > s.maxBatchSize {
+		return nil, status.Errorf(codes.ResourceExhausted, "Batch of %d URLs exceeds the limit of %d", len(in.ShortUrls), s.maxBatchSize)
+	}
 
 	err := s.shortener.DeleteMany(ctx, userID, in.ShortUrls)
 	if err != nil {
+		if st := workspaceStatus(err); st != nil {
+			return nil, st
+		}
+		s.logger.Error(err)
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &Empty{}, nil
+}
+
+// RestoreURL restores a short URL deleted by the authenticated user within the retention window.
+func (s *GRPCShortenerServer) RestoreURL(ctx context.Context, in *RestoreURLRequest) (*Empty, error) {
+	userID, ok := ctx.Value(middleware.UserIDContextKey).(string)
+	if !ok {
+		s.logger.Error("user ID not found in context")
+		return nil, status.Error(codes.Unauthenticated, "Empty userID")
+	}
+
+	if in == nil || in.Id == "" {
+		return nil, status.Error(codes.InvalidArgument, "Empty id")
+	}
+
+	if err := s.shortener.RestoreURL(ctx, userID, in.Id); err != nil {
+		if st := workspaceStatus(err); st != nil {
+			return nil, st
+		}
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, status.Error(codes.NotFound, "Deleted URL not found")
+		}
 		s.logger.Error(err)
 		return nil, status.Error(codes.Internal, err.Error())
 	}
@@ -167,6 +361,133 @@ func (s *GRPCShortenerServer) DeleteURLs(ctx context.Context, in *DeleteURLsRequ
 	return &Empty{}, nil
 }
 
+// UpdateURL changes the destination, redirect status code or metadata of a short URL owned by the authenticated user.
+func (s *GRPCShortenerServer) UpdateURL(ctx context.Context, in *UpdateURLRequest) (*URLItem, error) {
+	userID, ok := ctx.Value(middleware.UserIDContextKey).(string)
+	if !ok {
+		s.logger.Error("user ID not found in context")
+		return nil, status.Error(codes.Unauthenticated, "Empty userID")
+	}
+
+	if in == nil || in.Id == "" {
+		return nil, status.Error(codes.InvalidArgument, "Empty id")
+	}
+
+	req := models.UpdateURLRequest{
+		URL:          in.Url,
+		RedirectCode: int(in.RedirectCode),
+		Title:        in.Title,
+		Description:  in.Description,
+		Notes:        in.Notes,
+	}
+	if in.Tags != nil {
+		tags := models.Tags(in.Tags.GetTags())
+		req.Tags = &tags
+	}
+
+	url, err := s.shortener.UpdateURL(ctx, userID, in.Id, req)
+	if err != nil {
+		if st := workspaceStatus(err); st != nil {
+			return nil, st
+		}
+		switch {
+		case errors.Is(err, service.ErrInvalidURL), errors.Is(err, service.ErrInvalidRedirectCode),
+			errors.Is(err, service.ErrInvalidMetadata):
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		case errors.Is(err, storage.ErrNotFound):
+			return nil, status.Error(codes.NotFound, "URL not found")
+		}
+		s.logger.Error(err)
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return urlItem(url), nil
+}
+
+// workspaceStatus returns the status of errors of the service rejecting the active workspace, nil for other errors.
+func workspaceStatus(err error) error {
+	switch {
+	case errors.Is(err, service.ErrNotMember), errors.Is(err, service.ErrInsufficientRole):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, service.ErrInvalidWorkspace):
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	return nil
+}
+
+// domainStatus returns the status of errors of the service rejecting the custom domain of links,
+// including servers without custom domains, nil for other errors.
+func domainStatus(err error) error {
+	switch {
+	case errors.Is(err, service.ErrInvalidDomain):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, service.ErrDomainNotVerified):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, service.ErrUnsupported):
+		return status.Error(codes.Unimplemented, err.Error())
+	}
+	return nil
+}
+
+// incomingMetadata returns the first value of the metadata key of the call, empty if there is none.
+func incomingMetadata(ctx context.Context, key string) string {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(key); len(values) > 0 {
+			return values[0]
+		}
+	}
+	return ""
+}
+
+func urlItem(url *models.URL) *URLItem {
+	return &URLItem{
+		UserId:       url.UserID,
+		ShortUrl:     url.ShortURL,
+		OriginalUrl:  url.OriginalURL,
+		RedirectCode: int32(url.RedirectCode),
+		Title:        url.Title,
+		Description:  url.Description,
+		Notes:        url.Notes,
+		Tags:         url.Tags,
+	}
+}
+
+// GetURLHistory lists the previous destinations of a short URL owned by the authenticated user.
+func (s *GRPCShortenerServer) GetURLHistory(ctx context.Context, in *GetURLHistoryRequest) (*GetURLHistoryResponse, error) {
+	userID, ok := ctx.Value(middleware.UserIDContextKey).(string)
+	if !ok {
+		s.logger.Error("user ID not found in context")
+		return nil, status.Error(codes.Unauthenticated, "Empty userID")
+	}
+
+	if in == nil || in.Id == "" {
+		return nil, status.Error(codes.InvalidArgument, "Empty id")
+	}
+
+	history, err := s.shortener.GetURLHistory(ctx, userID, in.Id)
+	if err != nil {
+		if st := workspaceStatus(err); st != nil {
+			return nil, st
+		}
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, status.Error(codes.NotFound, "URL not found")
+		}
+		s.logger.Error(err)
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	resp := make([]*URLHistoryItem, len(history))
+	for i, entry := range history {
+		resp[i] = &URLHistoryItem{
+			ShortUrl:    entry.ShortURL,
+			OriginalUrl: entry.OriginalURL,
+			ChangedAt:   timestamppb.New(entry.ChangedAt),
+		}
+	}
+
+	return &GetURLHistoryResponse{Items: resp}, nil
+}
+
 // GetStats returns statistics about the number of URLs and users.
 func (s *GRPCShortenerServer) GetStats(ctx context.Context, in *Empty) (*StatsResponse, error) {
 	stats, err := s.shortener.GetStats(ctx)
@@ -177,3 +498,98 @@ func (s *GRPCShortenerServer) GetStats(ctx context.Context, in *Empty) (*StatsRe
 
 	return &StatsResponse{Urls: int32(stats.URLsCount), Users: int32(stats.UsersCount)}, nil
 }
+
+// CreateAPIKey issues a new API key for the authenticated user.
+func (s *GRPCShortenerServer) CreateAPIKey(ctx context.Context, in *CreateAPIKeyRequest) (*CreateAPIKeyResponse, error) {
+	userID, ok := ctx.Value(middleware.UserIDContextKey).(string)
+	if !ok {
+		s.logger.Error("user ID not found in context")
+		return nil, status.Error(codes.Unauthenticated, "Empty userID")
+	}
+
+	resp, err := s.shortener.CreateAPIKey(ctx, userID, in.GetName(), in.GetScopes())
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidScope) {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		s.logger.Error(err)
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &CreateAPIKeyResponse{ApiKey: toAPIKey(resp.APIKey), Key: resp.Key}, nil
+}
+
+// GetAPIKeys lists the API keys of the authenticated user.
+func (s *GRPCShortenerServer) GetAPIKeys(ctx context.Context, in *Empty) (*GetAPIKeysResponse, error) {
+	userID, ok := ctx.Value(middleware.UserIDContextKey).(string)
+	if !ok {
+		s.logger.Error("user ID not found in context")
+		return nil, status.Error(codes.Unauthenticated, "Empty userID")
+	}
+
+	keys, err := s.shortener.GetAPIKeys(ctx, userID)
+	if err != nil {
+		s.logger.Error(err)
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	resp := make([]*APIKey, len(keys))
+	for i, key := range keys {
+		resp[i] = toAPIKey(key)
+	}
+
+	return &GetAPIKeysResponse{ApiKeys: resp}, nil
+}
+
+// RevokeAPIKey revokes an API key of the authenticated user.
+func (s *GRPCShortenerServer) RevokeAPIKey(ctx context.Context, in *RevokeAPIKeyRequest) (*Empty, error) {
+	userID, ok := ctx.Value(middleware.UserIDContextKey).(string)
+	if !ok {
+		s.logger.Error("user ID not found in context")
+		return nil, status.Error(codes.Unauthenticated, "Empty userID")
+	}
+
+	if in == nil || in.Id == "" {
+		return nil, status.Error(codes.InvalidArgument, "Empty id")
+	}
+
+	if err := s.shortener.RevokeAPIKey(ctx, userID, in.Id); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, status.Error(codes.NotFound, "API key not found")
+		}
+		s.logger.Error(err)
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &Empty{}, nil
+}
+
+// peerAddr returns the IP address of the calling client used to throttle unlock attempts.
+func peerAddr(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
+}
+
+func toAPIKey(key models.APIKey) *APIKey {
+	out := &APIKey{
+		Id:        key.ID,
+		Name:      key.Name,
+		Prefix:    key.Prefix,
+		Scopes:    key.Scopes,
+		CreatedAt: timestamppb.New(key.CreatedAt),
+	}
+	if key.LastUsedAt != nil {
+		out.LastUsedAt = timestamppb.New(*key.LastUsedAt)
+	}
+	if key.RevokedAt != nil {
+		out.RevokedAt = timestamppb.New(*key.RevokedAt)
+	}
+	return out
+}
@@ -9,6 +9,7 @@ package pb
 import (
 	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
 	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
 	reflect "reflect"
 	sync "sync"
 	unsafe "unsafe"
@@ -24,6 +25,13 @@ const (
 type ShortenRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Url           string                 `protobuf:"bytes,1,opt,name=url,proto3" json:"url,omitempty"`
+	Password      string                 `protobuf:"bytes,2,opt,name=password,proto3" json:"password,omitempty"`                              // optional passphrase required to expand the link
+	Interstitial  bool                   `protobuf:"varint,3,opt,name=interstitial,proto3" json:"interstitial,omitempty"`                     // show a preview page instead of redirecting browsers
+	RedirectCode  int32                  `protobuf:"varint,4,opt,name=redirect_code,json=redirectCode,proto3" json:"redirect_code,omitempty"` // 301, 302, 307 or 308; the server default if unset
+	Title         string                 `protobuf:"bytes,5,opt,name=title,proto3" json:"title,omitempty"`
+	Description   string                 `protobuf:"bytes,6,opt,name=description,proto3" json:"description,omitempty"`
+	Notes         string                 `protobuf:"bytes,7,opt,name=notes,proto3" json:"notes,omitempty"`
+	Tags          []string               `protobuf:"bytes,8,rep,name=tags,proto3" json:"tags,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -65,6 +73,55 @@ func (x *ShortenRequest) GetUrl() string {
 	return ""
 }
 
+func (x *ShortenRequest) GetPassword() string {
+	if x != nil {
+		return x.Password
+	}
+	return ""
+}
+
+func (x *ShortenRequest) GetInterstitial() bool {
+	if x != nil {
+		return x.Interstitial
+	}
+	return false
+}
+
+func (x *ShortenRequest) GetRedirectCode() int32 {
+	if x != nil {
+		return x.RedirectCode
+	}
+	return 0
+}
+
+func (x *ShortenRequest) GetTitle() string {
+	if x != nil {
+		return x.Title
+	}
+	return ""
+}
+
+func (x *ShortenRequest) GetDescription() string {
+	if x != nil {
+		return x.Description
+	}
+	return ""
+}
+
+func (x *ShortenRequest) GetNotes() string {
+	if x != nil {
+		return x.Notes
+	}
+	return ""
+}
+
+func (x *ShortenRequest) GetTags() []string {
+	if x != nil {
+		return x.Tags
+	}
+	return nil
+}
+
 type ShortenResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Result        string                 `protobuf:"bytes,1,opt,name=result,proto3" json:"result,omitempty"`
@@ -112,6 +169,7 @@ func (x *ShortenResponse) GetResult() string {
 type ExpandRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Password      string                 `protobuf:"bytes,2,opt,name=password,proto3" json:"password,omitempty"` // required for password-protected links
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -153,9 +211,18 @@ func (x *ExpandRequest) GetId() string {
 	return ""
 }
 
+func (x *ExpandRequest) GetPassword() string {
+	if x != nil {
+		return x.Password
+	}
+	return ""
+}
+
 type ExpandResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Url           string                 `protobuf:"bytes,1,opt,name=url,proto3" json:"url,omitempty"`
+	Interstitial  bool                   `protobuf:"varint,2,opt,name=interstitial,proto3" json:"interstitial,omitempty"`                     // browsers are shown a preview page before the URL
+	RedirectCode  int32                  `protobuf:"varint,3,opt,name=redirect_code,json=redirectCode,proto3" json:"redirect_code,omitempty"` // HTTP status browsers are redirected with, 0 for the server default
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -197,6 +264,20 @@ func (x *ExpandResponse) GetUrl() string {
 	return ""
 }
 
+func (x *ExpandResponse) GetInterstitial() bool {
+	if x != nil {
+		return x.Interstitial
+	}
+	return false
+}
+
+func (x *ExpandResponse) GetRedirectCode() int32 {
+	if x != nil {
+		return x.RedirectCode
+	}
+	return 0
+}
+
 type Empty struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	unknownFields protoimpl.UnknownFields
@@ -333,6 +414,8 @@ type BatchResponseItem struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	CorrelationId string                 `protobuf:"bytes,1,opt,name=correlation_id,json=correlationId,proto3" json:"correlation_id,omitempty"`
 	ShortUrl      string                 `protobuf:"bytes,2,opt,name=short_url,json=shortUrl,proto3" json:"short_url,omitempty"`
+	Status        string                 `protobuf:"bytes,3,opt,name=status,proto3" json:"status,omitempty"` // created, exists or invalid
+	Error         string                 `protobuf:"bytes,4,opt,name=error,proto3" json:"error,omitempty"`   // why the item is invalid
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -381,6 +464,20 @@ func (x *BatchResponseItem) GetShortUrl() string {
 	return ""
 }
 
+func (x *BatchResponseItem) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *BatchResponseItem) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
 type BatchResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Items         []*BatchResponseItem   `protobuf:"bytes,1,rep,name=items,proto3" json:"items,omitempty"`
@@ -430,6 +527,11 @@ type URLItem struct {
 	UserId        string                 `protobuf:"bytes,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
 	ShortUrl      string                 `protobuf:"bytes,2,opt,name=short_url,json=shortUrl,proto3" json:"short_url,omitempty"`
 	OriginalUrl   string                 `protobuf:"bytes,3,opt,name=original_url,json=originalUrl,proto3" json:"original_url,omitempty"`
+	RedirectCode  int32                  `protobuf:"varint,4,opt,name=redirect_code,json=redirectCode,proto3" json:"redirect_code,omitempty"`
+	Title         string                 `protobuf:"bytes,5,opt,name=title,proto3" json:"title,omitempty"`
+	Description   string                 `protobuf:"bytes,6,opt,name=description,proto3" json:"description,omitempty"`
+	Notes         string                 `protobuf:"bytes,7,opt,name=notes,proto3" json:"notes,omitempty"`
+	Tags          []string               `protobuf:"bytes,8,rep,name=tags,proto3" json:"tags,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -485,27 +587,62 @@ func (x *URLItem) GetOriginalUrl() string {
 	return ""
 }
 
-type GetURLsResponse struct {
+func (x *URLItem) GetRedirectCode() int32 {
+	if x != nil {
+		return x.RedirectCode
+	}
+	return 0
+}
+
+func (x *URLItem) GetTitle() string {
+	if x != nil {
+		return x.Title
+	}
+	return ""
+}
+
+func (x *URLItem) GetDescription() string {
+	if x != nil {
+		return x.Description
+	}
+	return ""
+}
+
+func (x *URLItem) GetNotes() string {
+	if x != nil {
+		return x.Notes
+	}
+	return ""
+}
+
+func (x *URLItem) GetTags() []string {
+	if x != nil {
+		return x.Tags
+	}
+	return nil
+}
+
+type GetURLsRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Urls          []*URLItem             `protobuf:"bytes,1,rep,name=urls,proto3" json:"urls,omitempty"`
+	Tags          []string               `protobuf:"bytes,1,rep,name=tags,proto3" json:"tags,omitempty"` // only URLs having all of these tags
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *GetURLsResponse) Reset() {
-	*x = GetURLsResponse{}
+func (x *GetURLsRequest) Reset() {
+	*x = GetURLsRequest{}
 	mi := &file_internal_api_pb_shortener_proto_msgTypes[10]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *GetURLsResponse) String() string {
+func (x *GetURLsRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*GetURLsResponse) ProtoMessage() {}
+func (*GetURLsRequest) ProtoMessage() {}
 
-func (x *GetURLsResponse) ProtoReflect() protoreflect.Message {
+func (x *GetURLsRequest) ProtoReflect() protoreflect.Message {
 	mi := &file_internal_api_pb_shortener_proto_msgTypes[10]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -517,39 +654,39 @@ func (x *GetURLsResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use GetURLsResponse.ProtoReflect.Descriptor instead.
-func (*GetURLsResponse) Descriptor() ([]byte, []int) {
+// Deprecated: Use GetURLsRequest.ProtoReflect.Descriptor instead.
+func (*GetURLsRequest) Descriptor() ([]byte, []int) {
 	return file_internal_api_pb_shortener_proto_rawDescGZIP(), []int{10}
 }
 
-func (x *GetURLsResponse) GetUrls() []*URLItem {
+func (x *GetURLsRequest) GetTags() []string {
 	if x != nil {
-		return x.Urls
+		return x.Tags
 	}
 	return nil
 }
 
-type DeleteURLsRequest struct {
+type GetURLsResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	ShortUrls     []string               `protobuf:"bytes,1,rep,name=short_urls,json=shortUrls,proto3" json:"short_urls,omitempty"`
+	Urls          []*URLItem             `protobuf:"bytes,1,rep,name=urls,proto3" json:"urls,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *DeleteURLsRequest) Reset() {
-	*x = DeleteURLsRequest{}
+func (x *GetURLsResponse) Reset() {
+	*x = GetURLsResponse{}
 	mi := &file_internal_api_pb_shortener_proto_msgTypes[11]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *DeleteURLsRequest) String() string {
+func (x *GetURLsResponse) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*DeleteURLsRequest) ProtoMessage() {}
+func (*GetURLsResponse) ProtoMessage() {}
 
-func (x *DeleteURLsRequest) ProtoReflect() protoreflect.Message {
+func (x *GetURLsResponse) ProtoReflect() protoreflect.Message {
 	mi := &file_internal_api_pb_shortener_proto_msgTypes[11]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -561,40 +698,40 @@ func (x *DeleteURLsRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use DeleteURLsRequest.ProtoReflect.Descriptor instead.
-func (*DeleteURLsRequest) Descriptor() ([]byte, []int) {
+// Deprecated: Use GetURLsResponse.ProtoReflect.Descriptor instead.
+func (*GetURLsResponse) Descriptor() ([]byte, []int) {
 	return file_internal_api_pb_shortener_proto_rawDescGZIP(), []int{11}
 }
 
-func (x *DeleteURLsRequest) GetShortUrls() []string {
+func (x *GetURLsResponse) GetUrls() []*URLItem {
 	if x != nil {
-		return x.ShortUrls
+		return x.Urls
 	}
 	return nil
 }
 
-type StatsResponse struct {
+type SearchURLsRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Urls          int32                  `protobuf:"varint,1,opt,name=urls,proto3" json:"urls,omitempty"`
-	Users         int32                  `protobuf:"varint,2,opt,name=users,proto3" json:"users,omitempty"`
+	Query         string                 `protobuf:"bytes,1,opt,name=query,proto3" json:"query,omitempty"`
+	Limit         int32                  `protobuf:"varint,2,opt,name=limit,proto3" json:"limit,omitempty"` // maximum number of results, the default if 0
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *StatsResponse) Reset() {
-	*x = StatsResponse{}
+func (x *SearchURLsRequest) Reset() {
+	*x = SearchURLsRequest{}
 	mi := &file_internal_api_pb_shortener_proto_msgTypes[12]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *StatsResponse) String() string {
+func (x *SearchURLsRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*StatsResponse) ProtoMessage() {}
+func (*SearchURLsRequest) ProtoMessage() {}
 
-func (x *StatsResponse) ProtoReflect() protoreflect.Message {
+func (x *SearchURLsRequest) ProtoReflect() protoreflect.Message {
 	mi := &file_internal_api_pb_shortener_proto_msgTypes[12]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -606,71 +743,995 @@ func (x *StatsResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use StatsResponse.ProtoReflect.Descriptor instead.
-func (*StatsResponse) Descriptor() ([]byte, []int) {
+// Deprecated: Use SearchURLsRequest.ProtoReflect.Descriptor instead.
+func (*SearchURLsRequest) Descriptor() ([]byte, []int) {
 	return file_internal_api_pb_shortener_proto_rawDescGZIP(), []int{12}
 }
 
-func (x *StatsResponse) GetUrls() int32 {
+func (x *SearchURLsRequest) GetQuery() string {
 	if x != nil {
-		return x.Urls
+		return x.Query
+	}
+	return ""
+}
+
+func (x *SearchURLsRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
 	}
 	return 0
 }
 
-func (x *StatsResponse) GetUsers() int32 {
+type UpdateURLRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Url           string                 `protobuf:"bytes,2,opt,name=url,proto3" json:"url,omitempty"`                                        // new destination, unchanged if empty
+	RedirectCode  int32                  `protobuf:"varint,3,opt,name=redirect_code,json=redirectCode,proto3" json:"redirect_code,omitempty"` // new redirect status code, unchanged if 0
+	Title         *string                `protobuf:"bytes,4,opt,name=title,proto3,oneof" json:"title,omitempty"`
+	Description   *string                `protobuf:"bytes,5,opt,name=description,proto3,oneof" json:"description,omitempty"`
+	Notes         *string                `protobuf:"bytes,6,opt,name=notes,proto3,oneof" json:"notes,omitempty"`
+	Tags          *TagSet                `protobuf:"bytes,7,opt,name=tags,proto3" json:"tags,omitempty"` // replaces the tags if set
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UpdateURLRequest) Reset() {
+	*x = UpdateURLRequest{}
+	mi := &file_internal_api_pb_shortener_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpdateURLRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdateURLRequest) ProtoMessage() {}
+
+func (x *UpdateURLRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_api_pb_shortener_proto_msgTypes[13]
 	if x != nil {
-		return x.Users
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdateURLRequest.ProtoReflect.Descriptor instead.
+func (*UpdateURLRequest) Descriptor() ([]byte, []int) {
+	return file_internal_api_pb_shortener_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *UpdateURLRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *UpdateURLRequest) GetUrl() string {
+	if x != nil {
+		return x.Url
+	}
+	return ""
+}
+
+func (x *UpdateURLRequest) GetRedirectCode() int32 {
+	if x != nil {
+		return x.RedirectCode
 	}
 	return 0
 }
 
-var File_internal_api_pb_shortener_proto protoreflect.FileDescriptor
+func (x *UpdateURLRequest) GetTitle() string {
+	if x != nil && x.Title != nil {
+		return *x.Title
+	}
+	return ""
+}
 
-const file_internal_api_pb_shortener_proto_rawDesc = "" +
-	"\n" +
-	"\x1finternal/api/pb/shortener.proto\x12\tshortener\"\"\n" +
-	"\x0eShortenRequest\x12\x10\n" +
-	"\x03url\x18\x01 \x01(\tR\x03url\")\n" +
-	"\x0fShortenResponse\x12\x16\n" +
-	"\x06result\x18\x01 \x01(\tR\x06result\"\x1f\n" +
-	"\rExpandRequest\x12\x0e\n" +
-	"\x02id\x18\x01 \x01(\tR\x02id\"\"\n" +
-	"\x0eExpandResponse\x12\x10\n" +
-	"\x03url\x18\x01 \x01(\tR\x03url\"\a\n" +
-	"\x05Empty\"\\\n" +
-	"\x10BatchRequestItem\x12%\n" +
-	"\x0ecorrelation_id\x18\x01 \x01(\tR\rcorrelationId\x12!\n" +
-	"\foriginal_url\x18\x02 \x01(\tR\voriginalUrl\"A\n" +
-	"\fBatchRequest\x121\n" +
-	"\x05items\x18\x01 \x03(\v2\x1b.shortener.BatchRequestItemR\x05items\"W\n" +
-	"\x11BatchResponseItem\x12%\n" +
-	"\x0ecorrelation_id\x18\x01 \x01(\tR\rcorrelationId\x12\x1b\n" +
-	"\tshort_url\x18\x02 \x01(\tR\bshortUrl\"C\n" +
-	"\rBatchResponse\x122\n" +
-	"\x05items\x18\x01 \x03(\v2\x1c.shortener.BatchResponseItemR\x05items\"b\n" +
-	"\aURLItem\x12\x17\n" +
-	"\auser_id\x18\x01 \x01(\tR\x06userId\x12\x1b\n" +
-	"\tshort_url\x18\x02 \x01(\tR\bshortUrl\x12!\n" +
-	"\foriginal_url\x18\x03 \x01(\tR\voriginalUrl\"9\n" +
-	"\x0fGetURLsResponse\x12&\n" +
-	"\x04urls\x18\x01 \x03(\v2\x12.shortener.URLItemR\x04urls\"2\n" +
-	"\x11DeleteURLsRequest\x12\x1d\n" +
-	"\n" +
-	"short_urls\x18\x01 \x03(\tR\tshortUrls\"9\n" +
-	"\rStatsResponse\x12\x12\n" +
-	"\x04urls\x18\x01 \x01(\x05R\x04urls\x12\x14\n" +
-	"\x05users\x18\x02 \x01(\x05R\x05users2\xb2\x03\n" +
-	"\tShortener\x12C\n" +
-	"\n" +
-	"ShortenURL\x12\x19.shortener.ShortenRequest\x1a\x1a.shortener.ShortenResponse\x12A\n" +
-	"\fShortenBatch\x12\x17.shortener.BatchRequest\x1a\x18.shortener.BatchResponse\x12@\n" +
-	"\tExpandURL\x12\x18.shortener.ExpandRequest\x1a\x19.shortener.ExpandResponse\x12,\n" +
-	"\x06PingDB\x12\x10.shortener.Empty\x1a\x10.shortener.Empty\x127\n" +
-	"\aGetURLs\x12\x10.shortener.Empty\x1a\x1a.shortener.GetURLsResponse\x12<\n" +
+func (x *UpdateURLRequest) GetDescription() string {
+	if x != nil && x.Description != nil {
+		return *x.Description
+	}
+	return ""
+}
+
+func (x *UpdateURLRequest) GetNotes() string {
+	if x != nil && x.Notes != nil {
+		return *x.Notes
+	}
+	return ""
+}
+
+func (x *UpdateURLRequest) GetTags() *TagSet {
+	if x != nil {
+		return x.Tags
+	}
+	return nil
+}
+
+type TagSet struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Tags          []string               `protobuf:"bytes,1,rep,name=tags,proto3" json:"tags,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TagSet) Reset() {
+	*x = TagSet{}
+	mi := &file_internal_api_pb_shortener_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TagSet) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TagSet) ProtoMessage() {}
+
+func (x *TagSet) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_api_pb_shortener_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TagSet.ProtoReflect.Descriptor instead.
+func (*TagSet) Descriptor() ([]byte, []int) {
+	return file_internal_api_pb_shortener_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *TagSet) GetTags() []string {
+	if x != nil {
+		return x.Tags
+	}
+	return nil
+}
+
+type GetURLHistoryRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetURLHistoryRequest) Reset() {
+	*x = GetURLHistoryRequest{}
+	mi := &file_internal_api_pb_shortener_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetURLHistoryRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetURLHistoryRequest) ProtoMessage() {}
+
+func (x *GetURLHistoryRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_api_pb_shortener_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetURLHistoryRequest.ProtoReflect.Descriptor instead.
+func (*GetURLHistoryRequest) Descriptor() ([]byte, []int) {
+	return file_internal_api_pb_shortener_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *GetURLHistoryRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type URLHistoryItem struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ShortUrl      string                 `protobuf:"bytes,1,opt,name=short_url,json=shortUrl,proto3" json:"short_url,omitempty"`
+	OriginalUrl   string                 `protobuf:"bytes,2,opt,name=original_url,json=originalUrl,proto3" json:"original_url,omitempty"`
+	ChangedAt     *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=changed_at,json=changedAt,proto3" json:"changed_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *URLHistoryItem) Reset() {
+	*x = URLHistoryItem{}
+	mi := &file_internal_api_pb_shortener_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *URLHistoryItem) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*URLHistoryItem) ProtoMessage() {}
+
+func (x *URLHistoryItem) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_api_pb_shortener_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use URLHistoryItem.ProtoReflect.Descriptor instead.
+func (*URLHistoryItem) Descriptor() ([]byte, []int) {
+	return file_internal_api_pb_shortener_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *URLHistoryItem) GetShortUrl() string {
+	if x != nil {
+		return x.ShortUrl
+	}
+	return ""
+}
+
+func (x *URLHistoryItem) GetOriginalUrl() string {
+	if x != nil {
+		return x.OriginalUrl
+	}
+	return ""
+}
+
+func (x *URLHistoryItem) GetChangedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ChangedAt
+	}
+	return nil
+}
+
+type GetURLHistoryResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Items         []*URLHistoryItem      `protobuf:"bytes,1,rep,name=items,proto3" json:"items,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetURLHistoryResponse) Reset() {
+	*x = GetURLHistoryResponse{}
+	mi := &file_internal_api_pb_shortener_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetURLHistoryResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetURLHistoryResponse) ProtoMessage() {}
+
+func (x *GetURLHistoryResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_api_pb_shortener_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetURLHistoryResponse.ProtoReflect.Descriptor instead.
+func (*GetURLHistoryResponse) Descriptor() ([]byte, []int) {
+	return file_internal_api_pb_shortener_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *GetURLHistoryResponse) GetItems() []*URLHistoryItem {
+	if x != nil {
+		return x.Items
+	}
+	return nil
+}
+
+type DeleteURLsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ShortUrls     []string               `protobuf:"bytes,1,rep,name=short_urls,json=shortUrls,proto3" json:"short_urls,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteURLsRequest) Reset() {
+	*x = DeleteURLsRequest{}
+	mi := &file_internal_api_pb_shortener_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteURLsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteURLsRequest) ProtoMessage() {}
+
+func (x *DeleteURLsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_api_pb_shortener_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteURLsRequest.ProtoReflect.Descriptor instead.
+func (*DeleteURLsRequest) Descriptor() ([]byte, []int) {
+	return file_internal_api_pb_shortener_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *DeleteURLsRequest) GetShortUrls() []string {
+	if x != nil {
+		return x.ShortUrls
+	}
+	return nil
+}
+
+type RestoreURLRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RestoreURLRequest) Reset() {
+	*x = RestoreURLRequest{}
+	mi := &file_internal_api_pb_shortener_proto_msgTypes[19]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RestoreURLRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RestoreURLRequest) ProtoMessage() {}
+
+func (x *RestoreURLRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_api_pb_shortener_proto_msgTypes[19]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RestoreURLRequest.ProtoReflect.Descriptor instead.
+func (*RestoreURLRequest) Descriptor() ([]byte, []int) {
+	return file_internal_api_pb_shortener_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *RestoreURLRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type GetQRCodeRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Format        string                 `protobuf:"bytes,2,opt,name=format,proto3" json:"format,omitempty"`        // png (default) or svg
+	Size          int32                  `protobuf:"varint,3,opt,name=size,proto3" json:"size,omitempty"`           // width and height in pixels
+	Level         string                 `protobuf:"bytes,4,opt,name=level,proto3" json:"level,omitempty"`          // error-correction level: L, M (default), Q or H
+	Margin        *int32                 `protobuf:"varint,5,opt,name=margin,proto3,oneof" json:"margin,omitempty"` // quiet zone in modules, 4 when unset
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetQRCodeRequest) Reset() {
+	*x = GetQRCodeRequest{}
+	mi := &file_internal_api_pb_shortener_proto_msgTypes[20]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetQRCodeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetQRCodeRequest) ProtoMessage() {}
+
+func (x *GetQRCodeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_api_pb_shortener_proto_msgTypes[20]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetQRCodeRequest.ProtoReflect.Descriptor instead.
+func (*GetQRCodeRequest) Descriptor() ([]byte, []int) {
+	return file_internal_api_pb_shortener_proto_rawDescGZIP(), []int{20}
+}
+
+func (x *GetQRCodeRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *GetQRCodeRequest) GetFormat() string {
+	if x != nil {
+		return x.Format
+	}
+	return ""
+}
+
+func (x *GetQRCodeRequest) GetSize() int32 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+func (x *GetQRCodeRequest) GetLevel() string {
+	if x != nil {
+		return x.Level
+	}
+	return ""
+}
+
+func (x *GetQRCodeRequest) GetMargin() int32 {
+	if x != nil && x.Margin != nil {
+		return *x.Margin
+	}
+	return 0
+}
+
+type QRCode struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ContentType   string                 `protobuf:"bytes,1,opt,name=content_type,json=contentType,proto3" json:"content_type,omitempty"`
+	Image         []byte                 `protobuf:"bytes,2,opt,name=image,proto3" json:"image,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *QRCode) Reset() {
+	*x = QRCode{}
+	mi := &file_internal_api_pb_shortener_proto_msgTypes[21]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *QRCode) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*QRCode) ProtoMessage() {}
+
+func (x *QRCode) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_api_pb_shortener_proto_msgTypes[21]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use QRCode.ProtoReflect.Descriptor instead.
+func (*QRCode) Descriptor() ([]byte, []int) {
+	return file_internal_api_pb_shortener_proto_rawDescGZIP(), []int{21}
+}
+
+func (x *QRCode) GetContentType() string {
+	if x != nil {
+		return x.ContentType
+	}
+	return ""
+}
+
+func (x *QRCode) GetImage() []byte {
+	if x != nil {
+		return x.Image
+	}
+	return nil
+}
+
+type StatsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Urls          int32                  `protobuf:"varint,1,opt,name=urls,proto3" json:"urls,omitempty"`
+	Users         int32                  `protobuf:"varint,2,opt,name=users,proto3" json:"users,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StatsResponse) Reset() {
+	*x = StatsResponse{}
+	mi := &file_internal_api_pb_shortener_proto_msgTypes[22]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StatsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StatsResponse) ProtoMessage() {}
+
+func (x *StatsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_api_pb_shortener_proto_msgTypes[22]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StatsResponse.ProtoReflect.Descriptor instead.
+func (*StatsResponse) Descriptor() ([]byte, []int) {
+	return file_internal_api_pb_shortener_proto_rawDescGZIP(), []int{22}
+}
+
+func (x *StatsResponse) GetUrls() int32 {
+	if x != nil {
+		return x.Urls
+	}
+	return 0
+}
+
+func (x *StatsResponse) GetUsers() int32 {
+	if x != nil {
+		return x.Users
+	}
+	return 0
+}
+
+type APIKey struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Name          string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Prefix        string                 `protobuf:"bytes,3,opt,name=prefix,proto3" json:"prefix,omitempty"`
+	Scopes        []string               `protobuf:"bytes,4,rep,name=scopes,proto3" json:"scopes,omitempty"`
+	CreatedAt     *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	LastUsedAt    *timestamppb.Timestamp `protobuf:"bytes,6,opt,name=last_used_at,json=lastUsedAt,proto3" json:"last_used_at,omitempty"`
+	RevokedAt     *timestamppb.Timestamp `protobuf:"bytes,7,opt,name=revoked_at,json=revokedAt,proto3" json:"revoked_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *APIKey) Reset() {
+	*x = APIKey{}
+	mi := &file_internal_api_pb_shortener_proto_msgTypes[23]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *APIKey) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*APIKey) ProtoMessage() {}
+
+func (x *APIKey) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_api_pb_shortener_proto_msgTypes[23]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use APIKey.ProtoReflect.Descriptor instead.
+func (*APIKey) Descriptor() ([]byte, []int) {
+	return file_internal_api_pb_shortener_proto_rawDescGZIP(), []int{23}
+}
+
+func (x *APIKey) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *APIKey) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *APIKey) GetPrefix() string {
+	if x != nil {
+		return x.Prefix
+	}
+	return ""
+}
+
+func (x *APIKey) GetScopes() []string {
+	if x != nil {
+		return x.Scopes
+	}
+	return nil
+}
+
+func (x *APIKey) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+func (x *APIKey) GetLastUsedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.LastUsedAt
+	}
+	return nil
+}
+
+func (x *APIKey) GetRevokedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.RevokedAt
+	}
+	return nil
+}
+
+type CreateAPIKeyRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Scopes        []string               `protobuf:"bytes,2,rep,name=scopes,proto3" json:"scopes,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateAPIKeyRequest) Reset() {
+	*x = CreateAPIKeyRequest{}
+	mi := &file_internal_api_pb_shortener_proto_msgTypes[24]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateAPIKeyRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateAPIKeyRequest) ProtoMessage() {}
+
+func (x *CreateAPIKeyRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_api_pb_shortener_proto_msgTypes[24]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateAPIKeyRequest.ProtoReflect.Descriptor instead.
+func (*CreateAPIKeyRequest) Descriptor() ([]byte, []int) {
+	return file_internal_api_pb_shortener_proto_rawDescGZIP(), []int{24}
+}
+
+func (x *CreateAPIKeyRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *CreateAPIKeyRequest) GetScopes() []string {
+	if x != nil {
+		return x.Scopes
+	}
+	return nil
+}
+
+type CreateAPIKeyResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ApiKey        *APIKey                `protobuf:"bytes,1,opt,name=api_key,json=apiKey,proto3" json:"api_key,omitempty"`
+	Key           string                 `protobuf:"bytes,2,opt,name=key,proto3" json:"key,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateAPIKeyResponse) Reset() {
+	*x = CreateAPIKeyResponse{}
+	mi := &file_internal_api_pb_shortener_proto_msgTypes[25]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateAPIKeyResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateAPIKeyResponse) ProtoMessage() {}
+
+func (x *CreateAPIKeyResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_api_pb_shortener_proto_msgTypes[25]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateAPIKeyResponse.ProtoReflect.Descriptor instead.
+func (*CreateAPIKeyResponse) Descriptor() ([]byte, []int) {
+	return file_internal_api_pb_shortener_proto_rawDescGZIP(), []int{25}
+}
+
+func (x *CreateAPIKeyResponse) GetApiKey() *APIKey {
+	if x != nil {
+		return x.ApiKey
+	}
+	return nil
+}
+
+func (x *CreateAPIKeyResponse) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+type GetAPIKeysResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ApiKeys       []*APIKey              `protobuf:"bytes,1,rep,name=api_keys,json=apiKeys,proto3" json:"api_keys,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetAPIKeysResponse) Reset() {
+	*x = GetAPIKeysResponse{}
+	mi := &file_internal_api_pb_shortener_proto_msgTypes[26]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetAPIKeysResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetAPIKeysResponse) ProtoMessage() {}
+
+func (x *GetAPIKeysResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_api_pb_shortener_proto_msgTypes[26]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetAPIKeysResponse.ProtoReflect.Descriptor instead.
+func (*GetAPIKeysResponse) Descriptor() ([]byte, []int) {
+	return file_internal_api_pb_shortener_proto_rawDescGZIP(), []int{26}
+}
+
+func (x *GetAPIKeysResponse) GetApiKeys() []*APIKey {
+	if x != nil {
+		return x.ApiKeys
+	}
+	return nil
+}
+
+type RevokeAPIKeyRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RevokeAPIKeyRequest) Reset() {
+	*x = RevokeAPIKeyRequest{}
+	mi := &file_internal_api_pb_shortener_proto_msgTypes[27]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RevokeAPIKeyRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RevokeAPIKeyRequest) ProtoMessage() {}
+
+func (x *RevokeAPIKeyRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_api_pb_shortener_proto_msgTypes[27]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RevokeAPIKeyRequest.ProtoReflect.Descriptor instead.
+func (*RevokeAPIKeyRequest) Descriptor() ([]byte, []int) {
+	return file_internal_api_pb_shortener_proto_rawDescGZIP(), []int{27}
+}
+
+func (x *RevokeAPIKeyRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+var File_internal_api_pb_shortener_proto protoreflect.FileDescriptor
+
+const file_internal_api_pb_shortener_proto_rawDesc = "" +
+	"\n" +
+	"\x1finternal/api/pb/shortener.proto\x12\tshortener\x1a\x1fgoogle/protobuf/timestamp.proto\"\xe9\x01\n" +
+	"\x0eShortenRequest\x12\x10\n" +
+	"\x03url\x18\x01 \x01(\tR\x03url\x12\x1a\n" +
+	"\bpassword\x18\x02 \x01(\tR\bpassword\x12\"\n" +
+	"\finterstitial\x18\x03 \x01(\bR\finterstitial\x12#\n" +
+	"\rredirect_code\x18\x04 \x01(\x05R\fredirectCode\x12\x14\n" +
+	"\x05title\x18\x05 \x01(\tR\x05title\x12 \n" +
+	"\vdescription\x18\x06 \x01(\tR\vdescription\x12\x14\n" +
+	"\x05notes\x18\a \x01(\tR\x05notes\x12\x12\n" +
+	"\x04tags\x18\b \x03(\tR\x04tags\")\n" +
+	"\x0fShortenResponse\x12\x16\n" +
+	"\x06result\x18\x01 \x01(\tR\x06result\";\n" +
+	"\rExpandRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x1a\n" +
+	"\bpassword\x18\x02 \x01(\tR\bpassword\"k\n" +
+	"\x0eExpandResponse\x12\x10\n" +
+	"\x03url\x18\x01 \x01(\tR\x03url\x12\"\n" +
+	"\finterstitial\x18\x02 \x01(\bR\finterstitial\x12#\n" +
+	"\rredirect_code\x18\x03 \x01(\x05R\fredirectCode\"\a\n" +
+	"\x05Empty\"\\\n" +
+	"\x10BatchRequestItem\x12%\n" +
+	"\x0ecorrelation_id\x18\x01 \x01(\tR\rcorrelationId\x12!\n" +
+	"\foriginal_url\x18\x02 \x01(\tR\voriginalUrl\"A\n" +
+	"\fBatchRequest\x121\n" +
+	"\x05items\x18\x01 \x03(\v2\x1b.shortener.BatchRequestItemR\x05items\"\x85\x01\n" +
+	"\x11BatchResponseItem\x12%\n" +
+	"\x0ecorrelation_id\x18\x01 \x01(\tR\rcorrelationId\x12\x1b\n" +
+	"\tshort_url\x18\x02 \x01(\tR\bshortUrl\x12\x16\n" +
+	"\x06status\x18\x03 \x01(\tR\x06status\x12\x14\n" +
+	"\x05error\x18\x04 \x01(\tR\x05error\"C\n" +
+	"\rBatchResponse\x122\n" +
+	"\x05items\x18\x01 \x03(\v2\x1c.shortener.BatchResponseItemR\x05items\"\xe9\x01\n" +
+	"\aURLItem\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\tR\x06userId\x12\x1b\n" +
+	"\tshort_url\x18\x02 \x01(\tR\bshortUrl\x12!\n" +
+	"\foriginal_url\x18\x03 \x01(\tR\voriginalUrl\x12#\n" +
+	"\rredirect_code\x18\x04 \x01(\x05R\fredirectCode\x12\x14\n" +
+	"\x05title\x18\x05 \x01(\tR\x05title\x12 \n" +
+	"\vdescription\x18\x06 \x01(\tR\vdescription\x12\x14\n" +
+	"\x05notes\x18\a \x01(\tR\x05notes\x12\x12\n" +
+	"\x04tags\x18\b \x03(\tR\x04tags\"$\n" +
+	"\x0eGetURLsRequest\x12\x12\n" +
+	"\x04tags\x18\x01 \x03(\tR\x04tags\"9\n" +
+	"\x0fGetURLsResponse\x12&\n" +
+	"\x04urls\x18\x01 \x03(\v2\x12.shortener.URLItemR\x04urls\"?\n" +
+	"\x11SearchURLsRequest\x12\x14\n" +
+	"\x05query\x18\x01 \x01(\tR\x05query\x12\x14\n" +
+	"\x05limit\x18\x02 \x01(\x05R\x05limit\"\x81\x02\n" +
+	"\x10UpdateURLRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x10\n" +
+	"\x03url\x18\x02 \x01(\tR\x03url\x12#\n" +
+	"\rredirect_code\x18\x03 \x01(\x05R\fredirectCode\x12\x19\n" +
+	"\x05title\x18\x04 \x01(\tH\x00R\x05title\x88\x01\x01\x12%\n" +
+	"\vdescription\x18\x05 \x01(\tH\x01R\vdescription\x88\x01\x01\x12\x19\n" +
+	"\x05notes\x18\x06 \x01(\tH\x02R\x05notes\x88\x01\x01\x12%\n" +
+	"\x04tags\x18\a \x01(\v2\x11.shortener.TagSetR\x04tagsB\b\n" +
+	"\x06_titleB\x0e\n" +
+	"\f_descriptionB\b\n" +
+	"\x06_notes\"\x1c\n" +
+	"\x06TagSet\x12\x12\n" +
+	"\x04tags\x18\x01 \x03(\tR\x04tags\"&\n" +
+	"\x14GetURLHistoryRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\"\x8b\x01\n" +
+	"\x0eURLHistoryItem\x12\x1b\n" +
+	"\tshort_url\x18\x01 \x01(\tR\bshortUrl\x12!\n" +
+	"\foriginal_url\x18\x02 \x01(\tR\voriginalUrl\x129\n" +
+	"\n" +
+	"changed_at\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\tchangedAt\"H\n" +
+	"\x15GetURLHistoryResponse\x12/\n" +
+	"\x05items\x18\x01 \x03(\v2\x19.shortener.URLHistoryItemR\x05items\"2\n" +
+	"\x11DeleteURLsRequest\x12\x1d\n" +
+	"\n" +
+	"short_urls\x18\x01 \x03(\tR\tshortUrls\"#\n" +
+	"\x11RestoreURLRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\"\x8c\x01\n" +
+	"\x10GetQRCodeRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x16\n" +
+	"\x06format\x18\x02 \x01(\tR\x06format\x12\x12\n" +
+	"\x04size\x18\x03 \x01(\x05R\x04size\x12\x14\n" +
+	"\x05level\x18\x04 \x01(\tR\x05level\x12\x1b\n" +
+	"\x06margin\x18\x05 \x01(\x05H\x00R\x06margin\x88\x01\x01B\t\n" +
+	"\a_margin\"A\n" +
+	"\x06QRCode\x12!\n" +
+	"\fcontent_type\x18\x01 \x01(\tR\vcontentType\x12\x14\n" +
+	"\x05image\x18\x02 \x01(\fR\x05image\"9\n" +
+	"\rStatsResponse\x12\x12\n" +
+	"\x04urls\x18\x01 \x01(\x05R\x04urls\x12\x14\n" +
+	"\x05users\x18\x02 \x01(\x05R\x05users\"\x90\x02\n" +
+	"\x06APIKey\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12\x16\n" +
+	"\x06prefix\x18\x03 \x01(\tR\x06prefix\x12\x16\n" +
+	"\x06scopes\x18\x04 \x03(\tR\x06scopes\x129\n" +
+	"\n" +
+	"created_at\x18\x05 \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\x12<\n" +
+	"\flast_used_at\x18\x06 \x01(\v2\x1a.google.protobuf.TimestampR\n" +
+	"lastUsedAt\x129\n" +
+	"\n" +
+	"revoked_at\x18\a \x01(\v2\x1a.google.protobuf.TimestampR\trevokedAt\"A\n" +
+	"\x13CreateAPIKeyRequest\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x16\n" +
+	"\x06scopes\x18\x02 \x03(\tR\x06scopes\"T\n" +
+	"\x14CreateAPIKeyResponse\x12*\n" +
+	"\aapi_key\x18\x01 \x01(\v2\x11.shortener.APIKeyR\x06apiKey\x12\x10\n" +
+	"\x03key\x18\x02 \x01(\tR\x03key\"B\n" +
+	"\x12GetAPIKeysResponse\x12,\n" +
+	"\bapi_keys\x18\x01 \x03(\v2\x11.shortener.APIKeyR\aapiKeys\"%\n" +
+	"\x13RevokeAPIKeyRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id2\xe2\a\n" +
+	"\tShortener\x12C\n" +
+	"\n" +
+	"ShortenURL\x12\x19.shortener.ShortenRequest\x1a\x1a.shortener.ShortenResponse\x12A\n" +
+	"\fShortenBatch\x12\x17.shortener.BatchRequest\x1a\x18.shortener.BatchResponse\x12@\n" +
+	"\tExpandURL\x12\x18.shortener.ExpandRequest\x1a\x19.shortener.ExpandResponse\x12,\n" +
+	"\x06PingDB\x12\x10.shortener.Empty\x1a\x10.shortener.Empty\x12@\n" +
+	"\aGetURLs\x12\x19.shortener.GetURLsRequest\x1a\x1a.shortener.GetURLsResponse\x12F\n" +
+	"\n" +
+	"SearchURLs\x12\x1c.shortener.SearchURLsRequest\x1a\x1a.shortener.GetURLsResponse\x12<\n" +
+	"\n" +
+	"DeleteURLs\x12\x1c.shortener.DeleteURLsRequest\x1a\x10.shortener.Empty\x12<\n" +
+	"\n" +
+	"RestoreURL\x12\x1c.shortener.RestoreURLRequest\x1a\x10.shortener.Empty\x12<\n" +
+	"\tUpdateURL\x12\x1b.shortener.UpdateURLRequest\x1a\x12.shortener.URLItem\x12R\n" +
+	"\rGetURLHistory\x12\x1f.shortener.GetURLHistoryRequest\x1a .shortener.GetURLHistoryResponse\x12;\n" +
+	"\tGetQRCode\x12\x1b.shortener.GetQRCodeRequest\x1a\x11.shortener.QRCode\x126\n" +
+	"\bGetStats\x12\x10.shortener.Empty\x1a\x18.shortener.StatsResponse\x12O\n" +
+	"\fCreateAPIKey\x12\x1e.shortener.CreateAPIKeyRequest\x1a\x1f.shortener.CreateAPIKeyResponse\x12=\n" +
 	"\n" +
-	"DeleteURLs\x12\x1c.shortener.DeleteURLsRequest\x1a\x10.shortener.Empty\x126\n" +
-	"\bGetStats\x12\x10.shortener.Empty\x1a\x18.shortener.StatsResponseB/Z-github.com/grnsv/shortener/internal/api/pb;pbb\x06proto3"
+	"GetAPIKeys\x12\x10.shortener.Empty\x1a\x1d.shortener.GetAPIKeysResponse\x12@\n" +
+	"\fRevokeAPIKey\x12\x1e.shortener.RevokeAPIKeyRequest\x1a\x10.shortener.EmptyB/Z-github.com/grnsv/shortener/internal/api/pb;pbb\x06proto3"
 
 var (
 	file_internal_api_pb_shortener_proto_rawDescOnce sync.Once
@@ -684,45 +1745,85 @@ func file_internal_api_pb_shortener_proto_rawDescGZIP() []byte {
 	return file_internal_api_pb_shortener_proto_rawDescData
 }
 
-var file_internal_api_pb_shortener_proto_msgTypes = make([]protoimpl.MessageInfo, 13)
+var file_internal_api_pb_shortener_proto_msgTypes = make([]protoimpl.MessageInfo, 28)
 var file_internal_api_pb_shortener_proto_goTypes = []any{
-	(*ShortenRequest)(nil),    // 0: shortener.ShortenRequest
-	(*ShortenResponse)(nil),   // 1: shortener.ShortenResponse
-	(*ExpandRequest)(nil),     // 2: shortener.ExpandRequest
-	(*ExpandResponse)(nil),    // 3: shortener.ExpandResponse
-	(*Empty)(nil),             // 4: shortener.Empty
-	(*BatchRequestItem)(nil),  // 5: shortener.BatchRequestItem
-	(*BatchRequest)(nil),      // 6: shortener.BatchRequest
-	(*BatchResponseItem)(nil), // 7: shortener.BatchResponseItem
-	(*BatchResponse)(nil),     // 8: shortener.BatchResponse
-	(*URLItem)(nil),           // 9: shortener.URLItem
-	(*GetURLsResponse)(nil),   // 10: shortener.GetURLsResponse
-	(*DeleteURLsRequest)(nil), // 11: shortener.DeleteURLsRequest
-	(*StatsResponse)(nil),     // 12: shortener.StatsResponse
+	(*ShortenRequest)(nil),        // 0: shortener.ShortenRequest
+	(*ShortenResponse)(nil),       // 1: shortener.ShortenResponse
+	(*ExpandRequest)(nil),         // 2: shortener.ExpandRequest
+	(*ExpandResponse)(nil),        // 3: shortener.ExpandResponse
+	(*Empty)(nil),                 // 4: shortener.Empty
+	(*BatchRequestItem)(nil),      // 5: shortener.BatchRequestItem
+	(*BatchRequest)(nil),          // 6: shortener.BatchRequest
+	(*BatchResponseItem)(nil),     // 7: shortener.BatchResponseItem
+	(*BatchResponse)(nil),         // 8: shortener.BatchResponse
+	(*URLItem)(nil),               // 9: shortener.URLItem
+	(*GetURLsRequest)(nil),        // 10: shortener.GetURLsRequest
+	(*GetURLsResponse)(nil),       // 11: shortener.GetURLsResponse
+	(*SearchURLsRequest)(nil),     // 12: shortener.SearchURLsRequest
+	(*UpdateURLRequest)(nil),      // 13: shortener.UpdateURLRequest
+	(*TagSet)(nil),                // 14: shortener.TagSet
+	(*GetURLHistoryRequest)(nil),  // 15: shortener.GetURLHistoryRequest
+	(*URLHistoryItem)(nil),        // 16: shortener.URLHistoryItem
+	(*GetURLHistoryResponse)(nil), // 17: shortener.GetURLHistoryResponse
+	(*DeleteURLsRequest)(nil),     // 18: shortener.DeleteURLsRequest
+	(*RestoreURLRequest)(nil),     // 19: shortener.RestoreURLRequest
+	(*GetQRCodeRequest)(nil),      // 20: shortener.GetQRCodeRequest
+	(*QRCode)(nil),                // 21: shortener.QRCode
+	(*StatsResponse)(nil),         // 22: shortener.StatsResponse
+	(*APIKey)(nil),                // 23: shortener.APIKey
+	(*CreateAPIKeyRequest)(nil),   // 24: shortener.CreateAPIKeyRequest
+	(*CreateAPIKeyResponse)(nil),  // 25: shortener.CreateAPIKeyResponse
+	(*GetAPIKeysResponse)(nil),    // 26: shortener.GetAPIKeysResponse
+	(*RevokeAPIKeyRequest)(nil),   // 27: shortener.RevokeAPIKeyRequest
+	(*timestamppb.Timestamp)(nil), // 28: google.protobuf.Timestamp
 }
 var file_internal_api_pb_shortener_proto_depIdxs = []int32{
 	5,  // 0: shortener.BatchRequest.items:type_name -> shortener.BatchRequestItem
 	7,  // 1: shortener.BatchResponse.items:type_name -> shortener.BatchResponseItem
 	9,  // 2: shortener.GetURLsResponse.urls:type_name -> shortener.URLItem
-	0,  // 3: shortener.Shortener.ShortenURL:input_type -> shortener.ShortenRequest
-	6,  // 4: shortener.Shortener.ShortenBatch:input_type -> shortener.BatchRequest
-	2,  // 5: shortener.Shortener.ExpandURL:input_type -> shortener.ExpandRequest
-	4,  // 6: shortener.Shortener.PingDB:input_type -> shortener.Empty
-	4,  // 7: shortener.Shortener.GetURLs:input_type -> shortener.Empty
-	11, // 8: shortener.Shortener.DeleteURLs:input_type -> shortener.DeleteURLsRequest
-	4,  // 9: shortener.Shortener.GetStats:input_type -> shortener.Empty
-	1,  // 10: shortener.Shortener.ShortenURL:output_type -> shortener.ShortenResponse
-	8,  // 11: shortener.Shortener.ShortenBatch:output_type -> shortener.BatchResponse
-	3,  // 12: shortener.Shortener.ExpandURL:output_type -> shortener.ExpandResponse
-	4,  // 13: shortener.Shortener.PingDB:output_type -> shortener.Empty
-	10, // 14: shortener.Shortener.GetURLs:output_type -> shortener.GetURLsResponse
-	4,  // 15: shortener.Shortener.DeleteURLs:output_type -> shortener.Empty
-	12, // 16: shortener.Shortener.GetStats:output_type -> shortener.StatsResponse
-	10, // [10:17] is the sub-list for method output_type
-	3,  // [3:10] is the sub-list for method input_type
-	3,  // [3:3] is the sub-list for extension type_name
-	3,  // [3:3] is the sub-list for extension extendee
-	0,  // [0:3] is the sub-list for field type_name
+	14, // 3: shortener.UpdateURLRequest.tags:type_name -> shortener.TagSet
+	28, // 4: shortener.URLHistoryItem.changed_at:type_name -> google.protobuf.Timestamp
+	16, // 5: shortener.GetURLHistoryResponse.items:type_name -> shortener.URLHistoryItem
+	28, // 6: shortener.APIKey.created_at:type_name -> google.protobuf.Timestamp
+	28, // 7: shortener.APIKey.last_used_at:type_name -> google.protobuf.Timestamp
+	28, // 8: shortener.APIKey.revoked_at:type_name -> google.protobuf.Timestamp
+	23, // 9: shortener.CreateAPIKeyResponse.api_key:type_name -> shortener.APIKey
+	23, // 10: shortener.GetAPIKeysResponse.api_keys:type_name -> shortener.APIKey
+	0,  // 11: shortener.Shortener.ShortenURL:input_type -> shortener.ShortenRequest
+	6,  // 12: shortener.Shortener.ShortenBatch:input_type -> shortener.BatchRequest
+	2,  // 13: shortener.Shortener.ExpandURL:input_type -> shortener.ExpandRequest
+	4,  // 14: shortener.Shortener.PingDB:input_type -> shortener.Empty
+	10, // 15: shortener.Shortener.GetURLs:input_type -> shortener.GetURLsRequest
+	12, // 16: shortener.Shortener.SearchURLs:input_type -> shortener.SearchURLsRequest
+	18, // 17: shortener.Shortener.DeleteURLs:input_type -> shortener.DeleteURLsRequest
+	19, // 18: shortener.Shortener.RestoreURL:input_type -> shortener.RestoreURLRequest
+	13, // 19: shortener.Shortener.UpdateURL:input_type -> shortener.UpdateURLRequest
+	15, // 20: shortener.Shortener.GetURLHistory:input_type -> shortener.GetURLHistoryRequest
+	20, // 21: shortener.Shortener.GetQRCode:input_type -> shortener.GetQRCodeRequest
+	4,  // 22: shortener.Shortener.GetStats:input_type -> shortener.Empty
+	24, // 23: shortener.Shortener.CreateAPIKey:input_type -> shortener.CreateAPIKeyRequest
+	4,  // 24: shortener.Shortener.GetAPIKeys:input_type -> shortener.Empty
+	27, // 25: shortener.Shortener.RevokeAPIKey:input_type -> shortener.RevokeAPIKeyRequest
+	1,  // 26: shortener.Shortener.ShortenURL:output_type -> shortener.ShortenResponse
+	8,  // 27: shortener.Shortener.ShortenBatch:output_type -> shortener.BatchResponse
+	3,  // 28: shortener.Shortener.ExpandURL:output_type -> shortener.ExpandResponse
+	4,  // 29: shortener.Shortener.PingDB:output_type -> shortener.Empty
+	11, // 30: shortener.Shortener.GetURLs:output_type -> shortener.GetURLsResponse
+	11, // 31: shortener.Shortener.SearchURLs:output_type -> shortener.GetURLsResponse
+	4,  // 32: shortener.Shortener.DeleteURLs:output_type -> shortener.Empty
+	4,  // 33: shortener.Shortener.RestoreURL:output_type -> shortener.Empty
+	9,  // 34: shortener.Shortener.UpdateURL:output_type -> shortener.URLItem
+	17, // 35: shortener.Shortener.GetURLHistory:output_type -> shortener.GetURLHistoryResponse
+	21, // 36: shortener.Shortener.GetQRCode:output_type -> shortener.QRCode
+	22, // 37: shortener.Shortener.GetStats:output_type -> shortener.StatsResponse
+	25, // 38: shortener.Shortener.CreateAPIKey:output_type -> shortener.CreateAPIKeyResponse
+	26, // 39: shortener.Shortener.GetAPIKeys:output_type -> shortener.GetAPIKeysResponse
+	4,  // 40: shortener.Shortener.RevokeAPIKey:output_type -> shortener.Empty
+	26, // [26:41] is the sub-list for method output_type
+	11, // [11:26] is the sub-list for method input_type
+	11, // [11:11] is the sub-list for extension type_name
+	11, // [11:11] is the sub-list for extension extendee
+	0,  // [0:11] is the sub-list for field type_name
 }
 
 func init() { file_internal_api_pb_shortener_proto_init() }
@@ -730,13 +1831,15 @@ func file_internal_api_pb_shortener_proto_init() {
 	if File_internal_api_pb_shortener_proto != nil {
 		return
 	}
+	file_internal_api_pb_shortener_proto_msgTypes[13].OneofWrappers = []any{}
+	file_internal_api_pb_shortener_proto_msgTypes[20].OneofWrappers = []any{}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_internal_api_pb_shortener_proto_rawDesc), len(file_internal_api_pb_shortener_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   13,
+			NumMessages:   28,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
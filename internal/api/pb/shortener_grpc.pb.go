@@ -19,13 +19,21 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	Shortener_ShortenURL_FullMethodName   = "/shortener.Shortener/ShortenURL"
-	Shortener_ShortenBatch_FullMethodName = "/shortener.Shortener/ShortenBatch"
-	Shortener_ExpandURL_FullMethodName    = "/shortener.Shortener/ExpandURL"
-	Shortener_PingDB_FullMethodName       = "/shortener.Shortener/PingDB"
-	Shortener_GetURLs_FullMethodName      = "/shortener.Shortener/GetURLs"
-	Shortener_DeleteURLs_FullMethodName   = "/shortener.Shortener/DeleteURLs"
-	Shortener_GetStats_FullMethodName     = "/shortener.Shortener/GetStats"
+	Shortener_ShortenURL_FullMethodName    = "/shortener.Shortener/ShortenURL"
+	Shortener_ShortenBatch_FullMethodName  = "/shortener.Shortener/ShortenBatch"
+	Shortener_ExpandURL_FullMethodName     = "/shortener.Shortener/ExpandURL"
+	Shortener_PingDB_FullMethodName        = "/shortener.Shortener/PingDB"
+	Shortener_GetURLs_FullMethodName       = "/shortener.Shortener/GetURLs"
+	Shortener_SearchURLs_FullMethodName    = "/shortener.Shortener/SearchURLs"
+	Shortener_DeleteURLs_FullMethodName    = "/shortener.Shortener/DeleteURLs"
+	Shortener_RestoreURL_FullMethodName    = "/shortener.Shortener/RestoreURL"
+	Shortener_UpdateURL_FullMethodName     = "/shortener.Shortener/UpdateURL"
+	Shortener_GetURLHistory_FullMethodName = "/shortener.Shortener/GetURLHistory"
+	Shortener_GetQRCode_FullMethodName     = "/shortener.Shortener/GetQRCode"
+	Shortener_GetStats_FullMethodName      = "/shortener.Shortener/GetStats"
+	Shortener_CreateAPIKey_FullMethodName  = "/shortener.Shortener/CreateAPIKey"
+	Shortener_GetAPIKeys_FullMethodName    = "/shortener.Shortener/GetAPIKeys"
+	Shortener_RevokeAPIKey_FullMethodName  = "/shortener.Shortener/RevokeAPIKey"
 )
 
 // ShortenerClient is the client API for Shortener service.
@@ -36,9 +44,17 @@ type ShortenerClient interface {
 	ShortenBatch(ctx context.Context, in *BatchRequest, opts ...grpc.CallOption) (*BatchResponse, error)
 	ExpandURL(ctx context.Context, in *ExpandRequest, opts ...grpc.CallOption) (*ExpandResponse, error)
 	PingDB(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Empty, error)
-	GetURLs(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*GetURLsResponse, error)
+	GetURLs(ctx context.Context, in *GetURLsRequest, opts ...grpc.CallOption) (*GetURLsResponse, error)
+	SearchURLs(ctx context.Context, in *SearchURLsRequest, opts ...grpc.CallOption) (*GetURLsResponse, error)
 	DeleteURLs(ctx context.Context, in *DeleteURLsRequest, opts ...grpc.CallOption) (*Empty, error)
+	RestoreURL(ctx context.Context, in *RestoreURLRequest, opts ...grpc.CallOption) (*Empty, error)
+	UpdateURL(ctx context.Context, in *UpdateURLRequest, opts ...grpc.CallOption) (*URLItem, error)
+	GetURLHistory(ctx context.Context, in *GetURLHistoryRequest, opts ...grpc.CallOption) (*GetURLHistoryResponse, error)
+	GetQRCode(ctx context.Context, in *GetQRCodeRequest, opts ...grpc.CallOption) (*QRCode, error)
 	GetStats(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*StatsResponse, error)
+	CreateAPIKey(ctx context.Context, in *CreateAPIKeyRequest, opts ...grpc.CallOption) (*CreateAPIKeyResponse, error)
+	GetAPIKeys(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*GetAPIKeysResponse, error)
+	RevokeAPIKey(ctx context.Context, in *RevokeAPIKeyRequest, opts ...grpc.CallOption) (*Empty, error)
 }
 
 type shortenerClient struct {
@@ -89,7 +105,7 @@ func (c *shortenerClient) PingDB(ctx context.Context, in *Empty, opts ...grpc.Ca
 	return out, nil
 }
 
-func (c *shortenerClient) GetURLs(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*GetURLsResponse, error) {
+func (c *shortenerClient) GetURLs(ctx context.Context, in *GetURLsRequest, opts ...grpc.CallOption) (*GetURLsResponse, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(GetURLsResponse)
 	err := c.cc.Invoke(ctx, Shortener_GetURLs_FullMethodName, in, out, cOpts...)
@@ -99,6 +115,16 @@ func (c *shortenerClient) GetURLs(ctx context.Context, in *Empty, opts ...grpc.C
 	return out, nil
 }
 
+func (c *shortenerClient) SearchURLs(ctx context.Context, in *SearchURLsRequest, opts ...grpc.CallOption) (*GetURLsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetURLsResponse)
+	err := c.cc.Invoke(ctx, Shortener_SearchURLs_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *shortenerClient) DeleteURLs(ctx context.Context, in *DeleteURLsRequest, opts ...grpc.CallOption) (*Empty, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(Empty)
@@ -109,6 +135,46 @@ func (c *shortenerClient) DeleteURLs(ctx context.Context, in *DeleteURLsRequest,
 	return out, nil
 }
 
+func (c *shortenerClient) RestoreURL(ctx context.Context, in *RestoreURLRequest, opts ...grpc.CallOption) (*Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, Shortener_RestoreURL_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *shortenerClient) UpdateURL(ctx context.Context, in *UpdateURLRequest, opts ...grpc.CallOption) (*URLItem, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(URLItem)
+	err := c.cc.Invoke(ctx, Shortener_UpdateURL_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *shortenerClient) GetURLHistory(ctx context.Context, in *GetURLHistoryRequest, opts ...grpc.CallOption) (*GetURLHistoryResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetURLHistoryResponse)
+	err := c.cc.Invoke(ctx, Shortener_GetURLHistory_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *shortenerClient) GetQRCode(ctx context.Context, in *GetQRCodeRequest, opts ...grpc.CallOption) (*QRCode, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(QRCode)
+	err := c.cc.Invoke(ctx, Shortener_GetQRCode_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *shortenerClient) GetStats(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*StatsResponse, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(StatsResponse)
@@ -119,6 +185,36 @@ func (c *shortenerClient) GetStats(ctx context.Context, in *Empty, opts ...grpc.
 	return out, nil
 }
 
+func (c *shortenerClient) CreateAPIKey(ctx context.Context, in *CreateAPIKeyRequest, opts ...grpc.CallOption) (*CreateAPIKeyResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CreateAPIKeyResponse)
+	err := c.cc.Invoke(ctx, Shortener_CreateAPIKey_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *shortenerClient) GetAPIKeys(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*GetAPIKeysResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetAPIKeysResponse)
+	err := c.cc.Invoke(ctx, Shortener_GetAPIKeys_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *shortenerClient) RevokeAPIKey(ctx context.Context, in *RevokeAPIKeyRequest, opts ...grpc.CallOption) (*Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, Shortener_RevokeAPIKey_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // ShortenerServer is the server API for Shortener service.
 // All implementations must embed UnimplementedShortenerServer
 // for forward compatibility.
@@ -127,9 +223,17 @@ type ShortenerServer interface {
 	ShortenBatch(context.Context, *BatchRequest) (*BatchResponse, error)
 	ExpandURL(context.Context, *ExpandRequest) (*ExpandResponse, error)
 	PingDB(context.Context, *Empty) (*Empty, error)
-	GetURLs(context.Context, *Empty) (*GetURLsResponse, error)
+	GetURLs(context.Context, *GetURLsRequest) (*GetURLsResponse, error)
+	SearchURLs(context.Context, *SearchURLsRequest) (*GetURLsResponse, error)
 	DeleteURLs(context.Context, *DeleteURLsRequest) (*Empty, error)
+	RestoreURL(context.Context, *RestoreURLRequest) (*Empty, error)
+	UpdateURL(context.Context, *UpdateURLRequest) (*URLItem, error)
+	GetURLHistory(context.Context, *GetURLHistoryRequest) (*GetURLHistoryResponse, error)
+	GetQRCode(context.Context, *GetQRCodeRequest) (*QRCode, error)
 	GetStats(context.Context, *Empty) (*StatsResponse, error)
+	CreateAPIKey(context.Context, *CreateAPIKeyRequest) (*CreateAPIKeyResponse, error)
+	GetAPIKeys(context.Context, *Empty) (*GetAPIKeysResponse, error)
+	RevokeAPIKey(context.Context, *RevokeAPIKeyRequest) (*Empty, error)
 	mustEmbedUnimplementedShortenerServer()
 }
 
@@ -152,15 +256,39 @@ func (UnimplementedShortenerServer) ExpandURL(context.Context, *ExpandRequest) (
 func (UnimplementedShortenerServer) PingDB(context.Context, *Empty) (*Empty, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method PingDB not implemented")
 }
-func (UnimplementedShortenerServer) GetURLs(context.Context, *Empty) (*GetURLsResponse, error) {
+func (UnimplementedShortenerServer) GetURLs(context.Context, *GetURLsRequest) (*GetURLsResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetURLs not implemented")
 }
+func (UnimplementedShortenerServer) SearchURLs(context.Context, *SearchURLsRequest) (*GetURLsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SearchURLs not implemented")
+}
 func (UnimplementedShortenerServer) DeleteURLs(context.Context, *DeleteURLsRequest) (*Empty, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method DeleteURLs not implemented")
 }
+func (UnimplementedShortenerServer) RestoreURL(context.Context, *RestoreURLRequest) (*Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RestoreURL not implemented")
+}
+func (UnimplementedShortenerServer) UpdateURL(context.Context, *UpdateURLRequest) (*URLItem, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateURL not implemented")
+}
+func (UnimplementedShortenerServer) GetURLHistory(context.Context, *GetURLHistoryRequest) (*GetURLHistoryResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetURLHistory not implemented")
+}
+func (UnimplementedShortenerServer) GetQRCode(context.Context, *GetQRCodeRequest) (*QRCode, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetQRCode not implemented")
+}
 func (UnimplementedShortenerServer) GetStats(context.Context, *Empty) (*StatsResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetStats not implemented")
 }
+func (UnimplementedShortenerServer) CreateAPIKey(context.Context, *CreateAPIKeyRequest) (*CreateAPIKeyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateAPIKey not implemented")
+}
+func (UnimplementedShortenerServer) GetAPIKeys(context.Context, *Empty) (*GetAPIKeysResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetAPIKeys not implemented")
+}
+func (UnimplementedShortenerServer) RevokeAPIKey(context.Context, *RevokeAPIKeyRequest) (*Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RevokeAPIKey not implemented")
+}
 func (UnimplementedShortenerServer) mustEmbedUnimplementedShortenerServer() {}
 func (UnimplementedShortenerServer) testEmbeddedByValue()                   {}
 
@@ -255,7 +383,7 @@ func _Shortener_PingDB_Handler(srv interface{}, ctx context.Context, dec func(in
 }
 
 func _Shortener_GetURLs_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(Empty)
+	in := new(GetURLsRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
@@ -267,7 +395,25 @@ func _Shortener_GetURLs_Handler(srv interface{}, ctx context.Context, dec func(i
 		FullMethod: Shortener_GetURLs_FullMethodName,
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(ShortenerServer).GetURLs(ctx, req.(*Empty))
+		return srv.(ShortenerServer).GetURLs(ctx, req.(*GetURLsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Shortener_SearchURLs_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SearchURLsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShortenerServer).SearchURLs(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Shortener_SearchURLs_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShortenerServer).SearchURLs(ctx, req.(*SearchURLsRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
@@ -290,6 +436,78 @@ func _Shortener_DeleteURLs_Handler(srv interface{}, ctx context.Context, dec fun
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Shortener_RestoreURL_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RestoreURLRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShortenerServer).RestoreURL(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Shortener_RestoreURL_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShortenerServer).RestoreURL(ctx, req.(*RestoreURLRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Shortener_UpdateURL_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateURLRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShortenerServer).UpdateURL(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Shortener_UpdateURL_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShortenerServer).UpdateURL(ctx, req.(*UpdateURLRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Shortener_GetURLHistory_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetURLHistoryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShortenerServer).GetURLHistory(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Shortener_GetURLHistory_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShortenerServer).GetURLHistory(ctx, req.(*GetURLHistoryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Shortener_GetQRCode_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetQRCodeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShortenerServer).GetQRCode(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Shortener_GetQRCode_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShortenerServer).GetQRCode(ctx, req.(*GetQRCodeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _Shortener_GetStats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(Empty)
 	if err := dec(in); err != nil {
@@ -308,6 +526,60 @@ func _Shortener_GetStats_Handler(srv interface{}, ctx context.Context, dec func(
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Shortener_CreateAPIKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateAPIKeyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShortenerServer).CreateAPIKey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Shortener_CreateAPIKey_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShortenerServer).CreateAPIKey(ctx, req.(*CreateAPIKeyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Shortener_GetAPIKeys_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShortenerServer).GetAPIKeys(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Shortener_GetAPIKeys_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShortenerServer).GetAPIKeys(ctx, req.(*Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Shortener_RevokeAPIKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RevokeAPIKeyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShortenerServer).RevokeAPIKey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Shortener_RevokeAPIKey_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShortenerServer).RevokeAPIKey(ctx, req.(*RevokeAPIKeyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // Shortener_ServiceDesc is the grpc.ServiceDesc for Shortener service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -335,14 +607,46 @@ var Shortener_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetURLs",
 			Handler:    _Shortener_GetURLs_Handler,
 		},
+		{
+			MethodName: "SearchURLs",
+			Handler:    _Shortener_SearchURLs_Handler,
+		},
 		{
 			MethodName: "DeleteURLs",
 			Handler:    _Shortener_DeleteURLs_Handler,
 		},
+		{
+			MethodName: "RestoreURL",
+			Handler:    _Shortener_RestoreURL_Handler,
+		},
+		{
+			MethodName: "UpdateURL",
+			Handler:    _Shortener_UpdateURL_Handler,
+		},
+		{
+			MethodName: "GetURLHistory",
+			Handler:    _Shortener_GetURLHistory_Handler,
+		},
+		{
+			MethodName: "GetQRCode",
+			Handler:    _Shortener_GetQRCode_Handler,
+		},
 		{
 			MethodName: "GetStats",
 			Handler:    _Shortener_GetStats_Handler,
 		},
+		{
+			MethodName: "CreateAPIKey",
+			Handler:    _Shortener_CreateAPIKey_Handler,
+		},
+		{
+			MethodName: "GetAPIKeys",
+			Handler:    _Shortener_GetAPIKeys_Handler,
+		},
+		{
+			MethodName: "RevokeAPIKey",
+			Handler:    _Shortener_RevokeAPIKey_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "internal/api/pb/shortener.proto",
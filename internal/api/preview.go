@@ -0,0 +1,38 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/grnsv/shortener/internal/service"
+	"github.com/grnsv/shortener/internal/storage"
+)
+
+// PreviewURL handles GET requests to /{id}+ and serves a page showing where the short URL leads
+// with a link to continue, instead of redirecting.
+func (h *URLHandler) PreviewURL(w http.ResponseWriter, r *http.Request) {
+	shortURL := chi.URLParam(r, "id")
+	if shortURL == "" {
+		h.writeError(w, r, storage.ErrNotFound)
+		return
+	}
+
+	h.writePreview(w, r, shortURL)
+}
+
+// writePreview serves the preview page of a short URL.
+// Password-protected links are served as usual, so their destination is not disclosed.
+func (h *URLHandler) writePreview(w http.ResponseWriter, r *http.Request, shortURL string) {
+	preview, err := h.shortener.PreviewURL(r.Context(), shortURL)
+	if err != nil {
+		if errors.Is(err, service.ErrPasswordRequired) {
+			h.expandProtectedURL(w, r, shortURL)
+			return
+		}
+		h.writeError(w, r, err)
+		return
+	}
+
+	h.writePage(w, "preview.html", http.StatusOK, preview)
+}
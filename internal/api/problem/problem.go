@@ -0,0 +1,93 @@
+// Package problem provides the error responses of the HTTP API: RFC 7807 problem details
+// with a stable machine-readable code, so clients can tell failures apart without parsing messages.
+package problem
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// ContentType is the media type of problem details.
+const ContentType = "application/problem+json"
+
+// typePrefix prefixes the code of a problem to form its type URI.
+const typePrefix = "urn:shortener:problem:"
+
+// Code identifies the kind of a problem. Codes are part of the API and never change.
+type Code string
+
+// Problem codes.
+const (
+	CodeBadRequest           Code = "bad_request"
+	CodeInvalidJSON          Code = "invalid_json"
+	CodeEmptyURL             Code = "empty_url"
+	CodeInvalidURL           Code = "invalid_url"
+	CodeValidationFailed     Code = "validation_failed"
+	CodeEmptyBatch           Code = "empty_batch"
+	CodeBatchTooLarge        Code = "batch_too_large"
+	CodePayloadTooLarge      Code = "payload_too_large"
+	CodeUnsupportedEncoding  Code = "unsupported_encoding"
+	CodeInvalidEncoding      Code = "invalid_encoding"
+	CodeUnauthorized         Code = "unauthorized"
+	CodeForbidden            Code = "forbidden"
+	CodeInsufficientScope    Code = "insufficient_scope"
+	CodeInvalidPassword      Code = "invalid_password"
+	CodeNotFound             Code = "not_found"
+	CodeMethodNotAllowed     Code = "method_not_allowed"
+	CodeGone                 Code = "gone"
+	CodeConflict             Code = "conflict"
+	CodeIdempotencyKeyReused Code = "idempotency_key_reused"
+	CodeTooManyRequests      Code = "too_many_requests"
+	CodeQuotaExceeded        Code = "quota_exceeded"
+	CodeInternal             Code = "internal_error"
+	CodeNotImplemented       Code = "not_implemented"
+	CodeUnavailable          Code = "unavailable"
+)
+
+// Problem is an RFC 7807 problem details object extended with a code.
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	Code     Code   `json:"code"`
+}
+
+// New creates a problem with the given status, code and human-readable detail.
+// Its title is the status text, so it is the same for all occurrences of the problem.
+func New(status int, code Code, detail string) *Problem {
+	return &Problem{
+		Type:   typePrefix + string(code),
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+		Code:   code,
+	}
+}
+
+// Error returns the detail of the problem, or its title if there is none.
+func (p *Problem) Error() string {
+	if p.Detail != "" {
+		return p.Detail
+	}
+	return p.Title
+}
+
+// Write sends p as the response, replacing any Content-Type and Content-Length set before.
+func Write(w http.ResponseWriter, p *Problem) error {
+	h := w.Header()
+	h.Set("Content-Type", ContentType)
+	h.Set("X-Content-Type-Options", "nosniff")
+	h.Del("Content-Length")
+	w.WriteHeader(p.Status)
+	return json.NewEncoder(w).Encode(p)
+}
+
+// Error writes a problem with the given status, code and detail for the request r.
+// Write errors are ignored, as the client has gone away if the response cannot be sent.
+func Error(w http.ResponseWriter, r *http.Request, status int, code Code, detail string) {
+	p := New(status, code, detail)
+	p.Instance = r.URL.Path
+	_ = Write(w, p)
+}
@@ -0,0 +1,45 @@
+package problem
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	p := New(http.StatusNotFound, CodeNotFound, "short URL not found")
+
+	assert.Equal(t, "urn:shortener:problem:not_found", p.Type)
+	assert.Equal(t, "Not Found", p.Title)
+	assert.Equal(t, http.StatusNotFound, p.Status)
+	assert.Equal(t, "short URL not found", p.Error())
+	assert.Equal(t, "Internal Server Error", New(http.StatusInternalServerError, CodeInternal, "").Error())
+}
+
+func TestError(t *testing.T) {
+	rec := httptest.NewRecorder()
+	rec.Header().Set("Content-Type", "text/plain")
+	rec.Header().Set("Content-Length", "2")
+	req := httptest.NewRequest(http.MethodPost, "/api/shorten?x=1", nil)
+
+	Error(rec, req, http.StatusBadRequest, CodeEmptyURL, "url must not be empty")
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, ContentType, rec.Header().Get("Content-Type"))
+	assert.Empty(t, rec.Header().Get("Content-Length"))
+
+	var got map[string]any
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+	assert.Equal(t, map[string]any{
+		"type":     "urn:shortener:problem:empty_url",
+		"title":    "Bad Request",
+		"status":   float64(http.StatusBadRequest),
+		"detail":   "url must not be empty",
+		"instance": "/api/shorten",
+		"code":     "empty_url",
+	}, got)
+}
@@ -0,0 +1,60 @@
+package api
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/grnsv/shortener/internal/api/problem"
+	"github.com/grnsv/shortener/internal/qr"
+)
+
+// qrCacheControl lets clients and proxies cache QR codes, as the encoded short URL never changes.
+const qrCacheControl = "public, max-age=86400"
+
+// GetQRCode handles GET requests for a QR code of a short URL.
+// The query parameters format (png or svg), size (pixels), level (L, M, Q or H) and margin (modules)
+// are optional. It returns 400 Bad Request for invalid parameters and 404 Not Found for unknown links.
+func (h *URLHandler) GetQRCode(w http.ResponseWriter, r *http.Request) {
+	opts, err := parseQROptions(r.URL.Query())
+	if err != nil {
+		h.writeProblem(w, r, badRequest(problem.CodeValidationFailed, "size and margin must be integers"))
+		return
+	}
+
+	img, err := h.shortener.GetQRCode(r.Context(), chi.URLParam(r, "id"), opts)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", img.ContentType)
+	w.Header().Set("Cache-Control", qrCacheControl)
+	if _, err = w.Write(img.Data); err != nil {
+		h.logger.Error(err)
+	}
+}
+
+func parseQROptions(query url.Values) (qr.Options, error) {
+	opts := qr.Options{
+		Format: query.Get("format"),
+		Level:  query.Get("level"),
+	}
+
+	var err error
+	if size := query.Get("size"); size != "" {
+		if opts.Size, err = strconv.Atoi(size); err != nil {
+			return opts, err
+		}
+	}
+	if margin := query.Get("margin"); margin != "" {
+		if opts.Margin, err = strconv.Atoi(margin); err != nil {
+			return opts, err
+		}
+	} else {
+		opts.Margin = qr.DefaultMargin
+	}
+
+	return opts, nil
+}
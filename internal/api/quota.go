@@ -0,0 +1,54 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/grnsv/shortener/internal/api/middleware"
+	"github.com/grnsv/shortener/internal/api/problem"
+	"github.com/grnsv/shortener/internal/models"
+)
+
+// GetQuota handles requests for the quota of the user, or of the active workspace.
+// It returns the plan with its limits and the usage against them as JSON.
+func (h *URLHandler) GetQuota(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDContextKey).(string)
+	if !ok {
+		h.writeError(w, r, errUserIDNotFound)
+		return
+	}
+
+	quota, err := h.shortener.GetQuota(r.Context(), userID)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, quota)
+}
+
+// SetUserPlan handles requests from the trusted subnet to put a user, or a workspace, on a plan.
+// It expects a JSON body with the name of the plan and returns the resulting quota.
+func (h *URLHandler) SetUserPlan(w http.ResponseWriter, r *http.Request) {
+	userID := chi.URLParam(r, "userID")
+
+	var req models.SetPlanRequest
+	defer h.closeBody(r)
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeBodyError(w, r, err, problem.CodeInvalidJSON)
+		return
+	}
+
+	if err := h.shortener.SetPlan(r.Context(), userID, req.Plan); err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+	quota, err := h.shortener.GetQuota(r.Context(), userID)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, quota)
+}
@@ -2,55 +2,163 @@ package api
 
 import (
 	"net/http"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/grnsv/shortener/internal/api/middleware"
+	"github.com/grnsv/shortener/internal/api/openapi"
+	"github.com/grnsv/shortener/internal/api/problem"
 	"github.com/grnsv/shortener/internal/config"
 	"github.com/grnsv/shortener/internal/logger"
+	"github.com/grnsv/shortener/internal/models"
+	"github.com/grnsv/shortener/internal/service"
+)
+
+// Version 1 of the JSON API, /api/shorten and /api/user/urls, is deprecated in favour of /api/v2
+// and may be removed after its sunset.
+var (
+	v1Deprecation = time.Date(2026, time.October, 18, 0, 0, 0, 0, time.UTC)
+	v1Sunset      = time.Date(2027, time.April, 30, 0, 0, 0, 0, time.UTC)
 )
 
 // NewRouter creates and configures a new chi.Router for the URL shortener API.
 //
-// It registers all API endpoints, applies middleware for logging, compression, and authentication,
-// and sets up handlers for URL shortening, expansion, health checks, and user-specific operations.
+// It registers all API endpoints, applies middleware for logging, compression, authentication,
+// and validation against the OpenAPI specification, and sets up handlers for URL shortening, expansion,
+// health checks, user-specific operations, and the specification itself with Swagger UI.
+// With ValidateResponses in the config, responses are validated as well. Responses of version 1 of the JSON API
+// carry the Deprecation and Sunset headers.
 //
 // Parameters:
 //
 //	h      - pointer to URLHandler containing all endpoint handler methods
-//	config - pointer to Config struct with application configuration (e.g., JWT secret)
+//	config - pointer to Config struct with application configuration (e.g., trusted subnet)
+//	signer - pointer to Signer issuing and verifying authentication tokens
 //	logger - Logger interface for request logging
 //
 // Returns:
 //
 //	chi.Router - a fully configured router ready to be used by an HTTP server
-func NewRouter(h *URLHandler, config *config.Config, logger logger.Logger) chi.Router {
+func NewRouter(h *URLHandler, config *config.Config, signer *middleware.Signer, logger logger.Logger) chi.Router {
 	r := chi.NewRouter()
 
 	r.Use(
+		middleware.ClientIP(config.TrustedProxies),
 		middleware.WithLogging(logger),
-		middleware.WithCompressing(logger),
-		middleware.Authenticate(config.JWTSecret, logger),
+		middleware.WithCompressing(logger, middleware.WithMaxBodySize(config.MaxBodySize)),
+		middleware.Authenticate(signer, h.shortener, logger),
+		withRequestInfo,
 	)
+	if config.ValidateResponses {
+		r.Use(openapi.Validate(logger, openapi.WithResponseValidation()))
+	} else {
+		r.Use(openapi.Validate(logger))
+	}
 
-	r.Post("/", h.ShortenURL)
-	r.Get("/{id}", h.ExpandURL)
+	r.With(middleware.RequireScope(models.ScopeShorten), withWorkspace).Post("/", h.ShortenURL)
+	r.Group(func(r chi.Router) {
+		r.Use(withRequestHost)
+		r.Get("/{id}", h.ExpandURL)
+		r.Get("/{id}+", h.PreviewURL)
+		r.Get("/{id}/qr", h.GetQRCode)
+		r.Post("/{id}", h.UnlockURL)
+	})
 	r.Get("/ping", h.PingDB)
+	r.Get(openapi.SpecPath, openapi.SpecHandler)
+	r.Get(openapi.DocsPath, openapi.DocsHandler)
 	r.Route("/api", func(r chi.Router) {
 		r.Route("/shorten", func(r chi.Router) {
+			r.Use(middleware.Deprecated(v1Deprecation, v1Sunset, "/api/v2/links"))
+			r.Use(middleware.RequireScope(models.ScopeShorten), withWorkspace)
 			r.Post("/", h.ShortenURLJSON)
 			r.Post("/batch", h.ShortenBatch)
 		})
 		r.Route("/user/urls", func(r chi.Router) {
-			r.Get("/", h.GetURLs)
-			r.Delete("/", h.DeleteURLs)
+			r.Use(middleware.Deprecated(v1Deprecation, v1Sunset, "/api/v2/links"), withWorkspace)
+			r.With(middleware.RequireScope(models.ScopeRead)).Get("/", h.GetURLs)
+			r.With(middleware.RequireScope(models.ScopeRead)).Get("/search", h.SearchURLs)
+			r.With(middleware.RequireScope(models.ScopeRead)).Get("/export", h.ExportURLs)
+			r.With(middleware.RequireScope(models.ScopeShorten), middleware.LimitBody(config.MaxImportSize)).Post("/import", h.ImportURLs)
+			r.With(middleware.RequireScope(models.ScopeDelete)).Delete("/", h.DeleteURLs)
+			r.With(middleware.RequireScope(models.ScopeUpdate)).Patch("/{id}", h.UpdateURL)
+			r.With(middleware.RequireScope(models.ScopeRead)).Get("/{id}/history", h.GetURLHistory)
+			r.With(middleware.RequireScope(models.ScopeDelete)).Post("/{id}/restore", h.RestoreURL)
+		})
+		r.Route("/v2/links", func(r chi.Router) {
+			r.Use(withWorkspace)
+			r.With(middleware.RequireScope(models.ScopeShorten)).Post("/", h.CreateLink)
+			r.With(middleware.RequireScope(models.ScopeShorten)).Post("/batch", h.CreateLinks)
+			r.With(middleware.RequireScope(models.ScopeRead)).Get("/", h.GetLinks)
+			r.With(middleware.RequireScope(models.ScopeRead)).Get("/search", h.SearchLinks)
+			r.With(middleware.RequireScope(models.ScopeRead)).Get("/export", h.ExportURLs)
+			r.With(middleware.RequireScope(models.ScopeShorten), middleware.LimitBody(config.MaxImportSize)).Post("/import", h.ImportURLs)
+			r.With(middleware.RequireScope(models.ScopeDelete)).Delete("/", h.DeleteLinks)
+			r.With(middleware.RequireScope(models.ScopeUpdate)).Patch("/{id}", h.UpdateLink)
+			r.With(middleware.RequireScope(models.ScopeRead)).Get("/{id}/history", h.GetLinkHistory)
+			r.With(middleware.RequireScope(models.ScopeDelete)).Post("/{id}/restore", h.RestoreURL)
+		})
+		r.Route("/user/keys", func(r chi.Router) {
+			r.Use(middleware.RequireScope(models.ScopeKeys))
+			r.Post("/", h.CreateAPIKey)
+			r.Get("/", h.GetAPIKeys)
+			r.Delete("/{id}", h.RevokeAPIKey)
+		})
+		r.Route("/user/webhooks", func(r chi.Router) {
+			r.Use(middleware.RequireScope(models.ScopeWebhooks))
+			r.Post("/", h.CreateWebhook)
+			r.Get("/", h.GetWebhooks)
+			r.Delete("/{id}", h.DeleteWebhook)
+			r.Get("/{id}/deliveries", h.GetWebhookDeliveries)
+		})
+		r.Route("/user/domains", func(r chi.Router) {
+			r.Use(middleware.RequireScope(models.ScopeDomains))
+			r.Post("/", h.AddDomain)
+			r.Get("/", h.GetDomains)
+			r.Post("/{name}/verify", h.VerifyDomain)
+		})
+		r.Route("/user/workspaces", func(r chi.Router) {
+			r.Use(middleware.RequireScope(models.ScopeWorkspaces))
+			r.Post("/", h.CreateWorkspace)
+			r.Get("/", h.GetWorkspaces)
+			r.Get("/{id}/members", h.GetWorkspaceMembers)
+			r.Put("/{id}/members/{userID}", h.SetWorkspaceMember)
+			r.Delete("/{id}/members/{userID}", h.RemoveWorkspaceMember)
 		})
+		r.With(middleware.RequireScope(models.ScopeRead), withWorkspace).Get("/user/quota", h.GetQuota)
 		r.With(middleware.Internal(config.TrustedSubnet)).Route("/internal", func(r chi.Router) {
 			r.Get("/stats", h.GetStats)
+			r.Put("/users/{userID}/plan", h.SetUserPlan)
+			r.Get("/audit", h.GetAuditLog)
 		})
 	})
+	r.NotFound(func(w http.ResponseWriter, r *http.Request) {
+		problem.Error(w, r, http.StatusNotFound, problem.CodeNotFound, "no such resource")
+	})
 	r.MethodNotAllowed(func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusBadRequest)
+		problem.Error(w, r, http.StatusBadRequest, problem.CodeMethodNotAllowed, r.Method+" is not allowed for "+r.URL.Path)
 	})
 
 	return r
 }
+
+// withRequestInfo passes the authenticated user, the address of the client and its user agent to the service
+// for the audit log. The address is the one resolved by middleware.ClientIP, trusting only configured proxies.
+func withRequestInfo(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		info := models.RequestInfo{
+			IP:        middleware.ClientIPFrom(r.Context()),
+			UserAgent: r.UserAgent(),
+			Transport: models.TransportHTTP,
+		}
+		info.Actor, _ = r.Context().Value(middleware.UserIDContextKey).(string)
+		next.ServeHTTP(w, r.WithContext(service.WithRequestInfo(r.Context(), info)))
+	})
+}
+
+// withRequestHost passes the Host header of requests for short links to the service,
+// which looks short codes up on the custom domain they are requested on.
+func withRequestHost(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(service.WithHost(r.Context(), r.Host)))
+	})
+}
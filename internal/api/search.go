@@ -0,0 +1,46 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/grnsv/shortener/internal/api/middleware"
+	"github.com/grnsv/shortener/internal/api/problem"
+)
+
+// SearchURLs handles requests to search the URLs of a user by short code, destination, title and tags.
+// The query parameter q is required and limit is optional. It returns a JSON array of the best matches first,
+// 204 No Content if nothing matches or 400 Bad Request for an invalid query.
+func (h *URLHandler) SearchURLs(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDContextKey).(string)
+	if !ok {
+		h.writeError(w, r, errUserIDNotFound)
+		return
+	}
+
+	var limit int
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		var err error
+		if limit, err = strconv.Atoi(raw); err != nil {
+			h.writeProblem(w, r, badRequest(problem.CodeValidationFailed, "limit must be an integer"))
+			return
+		}
+	}
+
+	urls, err := h.shortener.SearchURLs(r.Context(), userID, r.URL.Query().Get("q"), limit)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	if len(urls) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err = json.NewEncoder(w).Encode(urls); err != nil {
+		h.logger.Error(err)
+	}
+}
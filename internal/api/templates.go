@@ -0,0 +1,23 @@
+package api
+
+import (
+	"embed"
+	"html/template"
+	"net/http"
+)
+
+//go:embed templates/*.html
+var templateFS embed.FS
+
+// pages holds the HTML pages served to browsers, parsed from templates embedded in the binary.
+var pages = template.Must(template.ParseFS(templateFS, "templates/*.html"))
+
+// writePage renders the named page with data. Pages are never cached, as they may depend on cookies.
+func (h *URLHandler) writePage(w http.ResponseWriter, name string, status int, data any) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	if err := pages.ExecuteTemplate(w, name, data); err != nil {
+		h.logger.Error(err)
+	}
+}
@@ -0,0 +1,126 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/grnsv/shortener/internal/api/middleware"
+	"github.com/grnsv/shortener/internal/service"
+	"github.com/grnsv/shortener/internal/transfer"
+)
+
+// exportFlushInterval is how many exported records are buffered before they are sent to the client.
+const exportFlushInterval = 100
+
+// ImportURLs handles requests to import links of a user from a CSV or JSON-lines file.
+// The format is taken from the format query parameter or the Content-Type header.
+// It returns a JSON report with the result of every record, or 400 Bad Request for an unknown format.
+// A report with an error means the import stopped before the end of the file; if the file exceeds
+// the maximum import size, the report of the records read so far comes with 413 Payload Too Large.
+func (h *URLHandler) ImportURLs(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDContextKey).(string)
+	if !ok {
+		h.writeError(w, r, errUserIDNotFound)
+		return
+	}
+
+	format, err := requestFormat(r, r.Header.Get("Content-Type"))
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	defer h.closeBody(r)
+	reader := transfer.NewReader(r.Body, format)
+	report, err := h.shortener.ImportURLs(r.Context(), userID, service.LinkSeq(reader.Records()))
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+	status := http.StatusOK
+	if readErr := reader.Err(); readErr != nil {
+		report.Error = readErr.Error()
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(readErr, &maxBytesErr) {
+			status = http.StatusRequestEntityTooLarge
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err = json.NewEncoder(w).Encode(report); err != nil {
+		h.logger.Error(err)
+	}
+}
+
+// ExportURLs handles requests to download the links of a user as a CSV file, or JSON lines
+// if the format query parameter is jsonl. The file is streamed and can be imported again.
+func (h *URLHandler) ExportURLs(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDContextKey).(string)
+	if !ok {
+		h.writeError(w, r, errUserIDNotFound)
+		return
+	}
+
+	format, err := requestFormat(r, string(transfer.FormatCSV))
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	writer := transfer.NewWriter(w, format)
+	started := false
+	start := func() {
+		started = true
+		w.Header().Set("Content-Type", format.ContentType())
+		w.Header().Set("Content-Disposition", `attachment; filename="links.`+string(format)+`"`)
+		w.WriteHeader(http.StatusOK)
+	}
+
+	var count int
+	for record, exportErr := range h.shortener.ExportURLs(r.Context(), userID) {
+		if exportErr != nil {
+			if !started {
+				h.writeError(w, r, exportErr)
+			} else {
+				h.logger.Error(exportErr)
+			}
+			return
+		}
+		if !started {
+			start()
+		}
+		if err = writer.Write(record); err != nil {
+			h.logger.Error(err)
+			return
+		}
+		if count++; count%exportFlushInterval == 0 {
+			h.flush(w, writer)
+		}
+	}
+
+	if !started {
+		start()
+	}
+	h.flush(w, writer)
+}
+
+// flush sends the records buffered by writer to the client.
+func (h *URLHandler) flush(w http.ResponseWriter, writer *transfer.Writer) {
+	if err := writer.Flush(); err != nil {
+		h.logger.Error(err)
+		return
+	}
+	if err := http.NewResponseController(w).Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		h.logger.Error(err)
+	}
+}
+
+// requestFormat returns the file format named by the format query parameter, or by fallback if it is absent.
+func requestFormat(r *http.Request, fallback string) (transfer.Format, error) {
+	if format := r.URL.Query().Get("format"); format != "" {
+		return transfer.ParseFormat(format)
+	}
+	return transfer.ParseFormat(fallback)
+}
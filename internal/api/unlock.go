@@ -0,0 +1,81 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/grnsv/shortener/internal/api/middleware"
+	"github.com/grnsv/shortener/internal/api/problem"
+	"github.com/grnsv/shortener/internal/service"
+	"github.com/grnsv/shortener/internal/storage"
+)
+
+// unlockCookieName is the cookie holding the unlock token of a protected link.
+// It is scoped to the link's path, so every link has its own cookie.
+const unlockCookieName = "unlock"
+
+// UnlockURL handles form POST requests with the password of a protected link.
+// On success it sets a short-lived unlock cookie and redirects to the original URL.
+func (h *URLHandler) UnlockURL(w http.ResponseWriter, r *http.Request) {
+	shortURL := chi.URLParam(r, "id")
+	if shortURL == "" {
+		h.writeError(w, r, storage.ErrNotFound)
+		return
+	}
+
+	defer h.closeBody(r)
+	if err := r.ParseForm(); err != nil {
+		h.writeBodyError(w, r, err, problem.CodeBadRequest)
+		return
+	}
+
+	url, token, err := h.shortener.UnlockURL(r.Context(), shortURL, r.PostFormValue("password"), middleware.ClientIPFrom(r.Context()))
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidPassword):
+			h.writeUnlockForm(w, shortURL, http.StatusForbidden, "Wrong password.")
+		case errors.Is(err, service.ErrTooManyAttempts):
+			w.Header().Set("Retry-After", strconv.Itoa(int(service.UnlockWindow.Seconds())))
+			h.writeUnlockForm(w, shortURL, http.StatusTooManyRequests, "Too many attempts. Try again later.")
+		default:
+			h.writeError(w, r, err)
+		}
+		return
+	}
+
+	if token != "" {
+		http.SetCookie(w, &http.Cookie{
+			Name:     unlockCookieName,
+			Value:    token,
+			Path:     "/" + shortURL,
+			MaxAge:   int(service.UnlockTTL.Seconds()),
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+		})
+	}
+	http.Redirect(w, r, url, http.StatusSeeOther)
+}
+
+// expandProtectedURL serves a protected link: it redirects if the request carries
+// a valid unlock cookie and serves the unlock form otherwise.
+func (h *URLHandler) expandProtectedURL(w http.ResponseWriter, r *http.Request, shortURL string) {
+	if cookie, cookieErr := r.Cookie(unlockCookieName); cookieErr == nil {
+		url, code, err := h.shortener.ExpandUnlockedURL(r.Context(), shortURL, cookie.Value)
+		if err == nil {
+			h.redirect(w, r, url, code)
+			return
+		}
+		if !errors.Is(err, service.ErrPasswordRequired) {
+			h.writeError(w, r, err)
+			return
+		}
+	}
+
+	h.writeUnlockForm(w, shortURL, http.StatusOK, "")
+}
+
+func (h *URLHandler) writeUnlockForm(w http.ResponseWriter, shortURL string, status int, message string) {
+	h.writePage(w, "unlock.html", status, struct{ ID, Error string }{ID: shortURL, Error: message})
+}
@@ -0,0 +1,83 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/grnsv/shortener/internal/api/middleware"
+	"github.com/grnsv/shortener/internal/api/problem"
+	"github.com/grnsv/shortener/internal/models"
+)
+
+// UpdateURL handles PATCH requests to change the destination, redirect status code or metadata of a user's short URL.
+// It expects a JSON body with the fields to change and returns the updated URL as JSON.
+func (h *URLHandler) UpdateURL(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDContextKey).(string)
+	if !ok {
+		h.writeError(w, r, errUserIDNotFound)
+		return
+	}
+
+	var req models.UpdateURLRequest
+	defer h.closeBody(r)
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeBodyError(w, r, err, problem.CodeInvalidJSON)
+		return
+	}
+
+	url, err := h.shortener.UpdateURL(r.Context(), userID, chi.URLParam(r, "id"), req)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err = json.NewEncoder(w).Encode(url); err != nil {
+		h.logger.Error(err)
+	}
+}
+
+// GetURLHistory handles requests to list the previous destinations of a user's short URL.
+// It returns a JSON array, oldest first, or 204 No Content if the destination never changed.
+func (h *URLHandler) GetURLHistory(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDContextKey).(string)
+	if !ok {
+		h.writeError(w, r, errUserIDNotFound)
+		return
+	}
+
+	history, err := h.shortener.GetURLHistory(r.Context(), userID, chi.URLParam(r, "id"))
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	if len(history) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err = json.NewEncoder(w).Encode(history); err != nil {
+		h.logger.Error(err)
+	}
+}
+
+// RestoreURL handles requests to restore a user's deleted short URL.
+// It returns 204 No Content on success and 404 Not Found if the URL was not deleted
+// by the user within the retention window.
+func (h *URLHandler) RestoreURL(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDContextKey).(string)
+	if !ok {
+		h.writeError(w, r, errUserIDNotFound)
+		return
+	}
+
+	if err := h.shortener.RestoreURL(r.Context(), userID, chi.URLParam(r, "id")); err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,234 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/grnsv/shortener/internal/api/middleware"
+	"github.com/grnsv/shortener/internal/api/problem"
+	"github.com/grnsv/shortener/internal/models"
+	"github.com/grnsv/shortener/internal/service"
+)
+
+// CreateLink handles requests to shorten a URL with version 2 of the API.
+// It returns the link with 201 Created, or the existing link with only its ID, short and original URLs
+// and 200 OK if the URL was already shortened.
+func (h *URLHandler) CreateLink(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDContextKey).(string)
+	if !ok {
+		h.writeError(w, r, errUserIDNotFound)
+		return
+	}
+
+	var req models.CreateLinkRequest
+	defer h.closeBody(r)
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeBodyError(w, r, err, problem.CodeInvalidJSON)
+		return
+	}
+
+	if len(req.URL) == 0 {
+		h.writeProblem(w, r, badRequest(problem.CodeEmptyURL, "url must not be empty"))
+		return
+	}
+
+	link, alreadyExists, err := h.shortener.Shorten(r.Context(), req.URL, userID,
+		service.WithPassword(req.Password),
+		service.WithInterstitial(req.Interstitial),
+		service.WithRedirectCode(req.RedirectCode),
+		service.WithMetadata(req.Metadata),
+		service.WithDomain(req.Domain),
+	)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	code := http.StatusCreated
+	if alreadyExists {
+		code = http.StatusOK
+	}
+	h.writeJSON(w, code, models.NewLink(*link, h.config.BaseURL.String()))
+}
+
+// CreateLinks handles batch shortening requests with version 2 of the API.
+// It behaves like ShortenBatch and wraps the items, which also carry the IDs of the links, in an object.
+func (h *URLHandler) CreateLinks(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDContextKey).(string)
+	if !ok {
+		h.writeError(w, r, errUserIDNotFound)
+		return
+	}
+
+	var req models.BatchRequest
+	defer h.closeBody(r)
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeBodyError(w, r, err, problem.CodeInvalidJSON)
+		return
+	}
+
+	if len(req) == 0 {
+		h.writeProblem(w, r, badRequest(problem.CodeEmptyBatch, "batch must not be empty"))
+		return
+	}
+	if len(req) > h.config.MaxBatchSize {
+		h.writeProblem(w, r, batchTooLarge(h.config.MaxBatchSize))
+		return
+	}
+
+	resp, err := h.shortener.ShortenBatch(r.Context(), req, userID, r.Header.Get("Idempotency-Key"))
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	code := http.StatusOK
+	if slices.ContainsFunc(resp, func(item models.BatchResponseItem) bool {
+		return item.Status == models.BatchCreated
+	}) {
+		code = http.StatusCreated
+	}
+	h.writeJSON(w, code, models.NewLinkBatchResponse(resp, h.config.BaseURL.String()))
+}
+
+// GetLinks handles requests to list the links of a user with version 2 of the API.
+// Repeated tag query parameters select only links having all of the tags.
+// It returns a list of links, which is empty rather than 204 No Content if the user has none.
+func (h *URLHandler) GetLinks(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDContextKey).(string)
+	if !ok {
+		h.writeError(w, r, errUserIDNotFound)
+		return
+	}
+
+	urls, err := h.shortener.GetAll(r.Context(), userID, models.URLFilter{Tags: r.URL.Query()["tag"]})
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, models.NewLinkList(urls, h.config.BaseURL.String()))
+}
+
+// SearchLinks handles requests to search the links of a user with version 2 of the API.
+// It takes the same query parameters as SearchURLs and returns a list of links, the best matches first.
+func (h *URLHandler) SearchLinks(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDContextKey).(string)
+	if !ok {
+		h.writeError(w, r, errUserIDNotFound)
+		return
+	}
+
+	var limit int
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		var err error
+		if limit, err = strconv.Atoi(raw); err != nil {
+			h.writeProblem(w, r, badRequest(problem.CodeValidationFailed, "limit must be an integer"))
+			return
+		}
+	}
+
+	urls, err := h.shortener.SearchURLs(r.Context(), userID, r.URL.Query().Get("q"), limit)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, models.NewLinkList(urls, h.config.BaseURL.String()))
+}
+
+// DeleteLinks handles requests to delete links of a user by their IDs with version 2 of the API.
+// Like DeleteURLs, it checks access to the active workspace, processes deletion asynchronously
+// and returns 202 Accepted.
+func (h *URLHandler) DeleteLinks(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDContextKey).(string)
+	if !ok {
+		h.writeError(w, r, errUserIDNotFound)
+		return
+	}
+
+	var req models.DeleteLinksRequest
+	defer h.closeBody(r)
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeBodyError(w, r, err, problem.CodeInvalidJSON)
+		return
+	}
+	if len(req.IDs) > h.config.MaxBatchSize {
+		h.writeProblem(w, r, batchTooLarge(h.config.MaxBatchSize))
+		return
+	}
+	owner, err := h.shortener.LinkOwner(r.Context(), userID, models.ScopeDelete)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	// Deletions are recorded in the audit log with the request they are made on.
+	info := service.RequestInfoFrom(r.Context())
+	go func() {
+		ctx, cancel := context.WithTimeout(service.WithRequestInfo(context.Background(), info), time.Minute)
+		defer cancel()
+		if err := h.shortener.DeleteMany(ctx, owner, req.IDs); err != nil {
+			h.logger.Error(err)
+		}
+	}()
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// UpdateLink handles PATCH requests to change a link of a user with version 2 of the API.
+// It expects the same body as UpdateURL and returns the updated link.
+func (h *URLHandler) UpdateLink(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDContextKey).(string)
+	if !ok {
+		h.writeError(w, r, errUserIDNotFound)
+		return
+	}
+
+	var req models.UpdateURLRequest
+	defer h.closeBody(r)
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeBodyError(w, r, err, problem.CodeInvalidJSON)
+		return
+	}
+
+	url, err := h.shortener.UpdateURL(r.Context(), userID, chi.URLParam(r, "id"), req)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, models.NewLink(*url, h.config.BaseURL.String()))
+}
+
+// GetLinkHistory handles requests to list the previous destinations of a link of a user with version 2 of the API.
+// The history is oldest first and empty rather than 204 No Content if the destination never changed.
+func (h *URLHandler) GetLinkHistory(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDContextKey).(string)
+	if !ok {
+		h.writeError(w, r, errUserIDNotFound)
+		return
+	}
+
+	history, err := h.shortener.GetURLHistory(r.Context(), userID, chi.URLParam(r, "id"))
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, models.NewLinkHistory(history))
+}
+
+// writeJSON writes v as a JSON response with the status code.
+func (h *URLHandler) writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		h.logger.Error(err)
+	}
+}
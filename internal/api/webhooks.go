@@ -0,0 +1,98 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/grnsv/shortener/internal/api/middleware"
+	"github.com/grnsv/shortener/internal/api/problem"
+	"github.com/grnsv/shortener/internal/models"
+)
+
+// CreateWebhook handles requests to subscribe a URL to events of the user's links.
+// It expects a JSON body with the URL and optional events and returns the webhook with the secret
+// signing its requests, which is shown only once, with 201 Created.
+func (h *URLHandler) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDContextKey).(string)
+	if !ok {
+		h.writeError(w, r, errUserIDNotFound)
+		return
+	}
+
+	var req models.CreateWebhookRequest
+	defer h.closeBody(r)
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeBodyError(w, r, err, problem.CodeInvalidJSON)
+		return
+	}
+
+	hook, err := h.shortener.CreateWebhook(r.Context(), userID, req.URL, req.Events)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	h.writeJSON(w, http.StatusCreated, hook)
+}
+
+// GetWebhooks handles requests to list the webhooks of a user.
+// It returns a JSON array of webhooks without their secrets or 204 No Content if none exist.
+func (h *URLHandler) GetWebhooks(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDContextKey).(string)
+	if !ok {
+		h.writeError(w, r, errUserIDNotFound)
+		return
+	}
+
+	hooks, err := h.shortener.GetWebhooks(r.Context(), userID)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	if len(hooks) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, hooks)
+}
+
+// DeleteWebhook handles requests to delete a webhook of a user, which cancels its pending deliveries.
+// It returns 204 No Content on success or 404 Not Found for an unknown webhook.
+func (h *URLHandler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDContextKey).(string)
+	if !ok {
+		h.writeError(w, r, errUserIDNotFound)
+		return
+	}
+
+	if err := h.shortener.DeleteWebhook(r.Context(), userID, chi.URLParam(r, "id")); err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetWebhookDeliveries handles requests for the delivery history of a webhook of a user.
+// It returns a JSON array of the latest deliveries, newest first, which is empty if there are none.
+func (h *URLHandler) GetWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDContextKey).(string)
+	if !ok {
+		h.writeError(w, r, errUserIDNotFound)
+		return
+	}
+
+	deliveries, err := h.shortener.GetWebhookDeliveries(r.Context(), userID, chi.URLParam(r, "id"))
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+	if deliveries == nil {
+		deliveries = []models.WebhookDelivery{}
+	}
+
+	h.writeJSON(w, http.StatusOK, deliveries)
+}
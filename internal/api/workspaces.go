@@ -0,0 +1,135 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/grnsv/shortener/internal/api/middleware"
+	"github.com/grnsv/shortener/internal/api/problem"
+	"github.com/grnsv/shortener/internal/models"
+	"github.com/grnsv/shortener/internal/service"
+)
+
+// WorkspaceHeader is the request header naming the active workspace of requests for links, see service.WithWorkspace.
+const WorkspaceHeader = "X-Workspace-ID"
+
+// withWorkspace makes the workspace named by the WorkspaceHeader of a request, if any, active for it.
+func withWorkspace(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if id := r.Header.Get(WorkspaceHeader); id != "" {
+			r = r.WithContext(service.WithWorkspace(r.Context(), id))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// CreateWorkspace handles requests to create a workspace owned by the user.
+// It expects a JSON body with the name of the workspace and returns the workspace with 201 Created.
+func (h *URLHandler) CreateWorkspace(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDContextKey).(string)
+	if !ok {
+		h.writeError(w, r, errUserIDNotFound)
+		return
+	}
+
+	var req models.CreateWorkspaceRequest
+	defer h.closeBody(r)
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeBodyError(w, r, err, problem.CodeInvalidJSON)
+		return
+	}
+
+	workspace, err := h.shortener.CreateWorkspace(r.Context(), userID, req.Name)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	h.writeJSON(w, http.StatusCreated, workspace)
+}
+
+// GetWorkspaces handles requests to list the workspaces the user is a member of.
+// It returns a JSON array of workspaces with the role of the user, which is empty if there are none.
+func (h *URLHandler) GetWorkspaces(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDContextKey).(string)
+	if !ok {
+		h.writeError(w, r, errUserIDNotFound)
+		return
+	}
+
+	workspaces, err := h.shortener.GetWorkspaces(r.Context(), userID)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+	if workspaces == nil {
+		workspaces = []models.Workspace{}
+	}
+
+	h.writeJSON(w, http.StatusOK, workspaces)
+}
+
+// GetWorkspaceMembers handles requests to list the members of a workspace of the user.
+// It returns a JSON array of members, or 403 Forbidden if the user is not a member.
+func (h *URLHandler) GetWorkspaceMembers(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDContextKey).(string)
+	if !ok {
+		h.writeError(w, r, errUserIDNotFound)
+		return
+	}
+
+	members, err := h.shortener.GetMembers(r.Context(), userID, chi.URLParam(r, "id"))
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+	if members == nil {
+		members = []models.WorkspaceMember{}
+	}
+
+	h.writeJSON(w, http.StatusOK, members)
+}
+
+// SetWorkspaceMember handles PUT requests adding a user to a workspace or changing the role of a member.
+// It expects a JSON body with the role and returns the member, 403 Forbidden unless the user owns the workspace,
+// or 409 Conflict if the last owner would lose the role.
+func (h *URLHandler) SetWorkspaceMember(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDContextKey).(string)
+	if !ok {
+		h.writeError(w, r, errUserIDNotFound)
+		return
+	}
+
+	var req models.SetMemberRequest
+	defer h.closeBody(r)
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeBodyError(w, r, err, problem.CodeInvalidJSON)
+		return
+	}
+
+	member, err := h.shortener.SetMember(r.Context(), userID, chi.URLParam(r, "id"), chi.URLParam(r, "userID"), req.Role)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, member)
+}
+
+// RemoveWorkspaceMember handles requests to remove a member from a workspace, or the user from one they leave.
+// It returns 204 No Content, 404 Not Found for a user who is not a member, or 409 Conflict for the last owner.
+func (h *URLHandler) RemoveWorkspaceMember(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDContextKey).(string)
+	if !ok {
+		h.writeError(w, r, errUserIDNotFound)
+		return
+	}
+
+	if err := h.shortener.RemoveMember(r.Context(), userID, chi.URLParam(r, "id"), chi.URLParam(r, "userID")); err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
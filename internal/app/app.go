@@ -5,10 +5,12 @@ package app
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/grnsv/shortener/internal/api"
@@ -16,19 +18,28 @@ import (
 	"github.com/grnsv/shortener/internal/api/pb"
 	"github.com/grnsv/shortener/internal/config"
 	"github.com/grnsv/shortener/internal/logger"
+	"github.com/grnsv/shortener/internal/metadata"
+	"github.com/grnsv/shortener/internal/outbox"
 	"github.com/grnsv/shortener/internal/service"
 	"github.com/grnsv/shortener/internal/storage"
+	"github.com/grnsv/shortener/internal/webhook"
 	"google.golang.org/grpc"
 )
 
 // Application encapsulates the main components and servers of the URL shortener application.
 type Application struct {
 	Config     *config.Config
+	Signer     *middleware.Signer
 	Logger     logger.Logger
 	Storage    storage.Storage
 	Shortener  service.Shortener
 	HTTPServer *http.Server
 	GRPCServer *grpc.Server
+	Dispatcher *webhook.Dispatcher
+	Relay      *outbox.Relay // publishes the change stream of links, nil unless an event sink is configured
+	sink       outbox.Sink
+	stopJobs   context.CancelFunc // stops the background jobs started by Run
+	jobs       sync.WaitGroup     // tracks the background jobs until they stop
 }
 
 // NewApplication creates and initializes a new Application instance.
@@ -43,16 +54,61 @@ func NewApplication(ctx context.Context) (*Application, error) {
 	if app.Logger, err = logger.New(app.Config.AppEnv); err != nil {
 		return nil, fmt.Errorf("failed to create logger: %w", err)
 	}
+	if app.Signer, err = middleware.NewSigner(app.Config); err != nil {
+		return nil, fmt.Errorf("failed to create JWT signer: %w", err)
+	}
 	if app.Storage, err = storage.New(ctx, app.Config); err != nil {
 		return nil, fmt.Errorf("failed to create storage: %w", err)
 	}
 
-	app.Shortener = service.NewShortener(app.Storage, app.Storage, app.Storage, app.Storage, app.Config.BaseURL.String())
+	opts := []service.Option{
+		service.WithRestorer(app.Storage, time.Duration(app.Config.DeletedRetention)),
+		service.WithUpdater(app.Storage),
+		service.WithSearcher(app.Storage),
+		service.WithStreamer(app.Storage),
+		service.WithKeyStorage(app.Storage),
+		service.WithWebhooks(app.Storage, app.Storage),
+		service.WithDomains(app.Storage, net.DefaultResolver),
+		service.WithWorkspaces(app.Storage),
+		service.WithAuditLog(app.Storage, time.Duration(app.Config.AuditRetention)),
+		service.WithLogger(app.Logger),
+	}
+	if app.Config.FetchMetadata {
+		opts = append(opts, service.WithMetadataFetcher(metadata.NewFetcher(nil)))
+	}
+	if len(app.Config.QuotaPlans) > 0 {
+		opts = append(opts, service.WithQuotas(app.Storage, app.Config.QuotaPlans, app.Config.DefaultPlan))
+	}
+	app.Shortener = service.NewShortener(
+		app.Storage, app.Storage, app.Storage, app.Storage, app.Config.BaseURL.String(), opts...,
+	)
+	app.Dispatcher = webhook.NewDispatcher(app.Storage, app.Logger)
+	if err = app.initRelay(); err != nil {
+		return nil, err
+	}
 	app.initServers()
 
 	return &app, nil
 }
 
+// initRelay creates the relay publishing the change stream of links if an event sink is configured.
+func (app *Application) initRelay() error {
+	if app.Config.EventSink == "" {
+		return nil
+	}
+	store, ok := app.Storage.(storage.Outbox)
+	if !ok {
+		return errors.New("event sink requires the database storage")
+	}
+	sink, err := outbox.Open(app.Config.EventSink)
+	if err != nil {
+		return fmt.Errorf("failed to open event sink: %w", err)
+	}
+	app.sink = sink
+	app.Relay = outbox.NewRelay(store, sink, app.Config.BaseURL.String(), app.Logger)
+	return nil
+}
+
 func (app *Application) initServers() {
 	app.initHTTP()
 	app.initGRPC()
@@ -60,7 +116,7 @@ func (app *Application) initServers() {
 
 func (app *Application) initHTTP() {
 	handler := api.NewURLHandler(app.Shortener, app.Config, app.Logger)
-	router := api.NewRouter(handler, app.Config, app.Logger)
+	router := api.NewRouter(handler, app.Config, app.Signer, app.Logger)
 	app.HTTPServer = &http.Server{
 		Addr:         app.Config.ServerAddress.String(),
 		Handler:      router,
@@ -71,15 +127,37 @@ func (app *Application) initHTTP() {
 }
 
 func (app *Application) initGRPC() {
-	authenticate := middleware.GRPCAuthenticateInterceptor(app.Config.JWTSecret, app.Logger)
-	app.GRPCServer = grpc.NewServer(grpc.UnaryInterceptor(authenticate))
-	pb.RegisterShortenerServer(app.GRPCServer, pb.NewGRPCShortenerServer(app.Shortener, app.Logger))
+	authenticate := middleware.GRPCAuthenticateInterceptor(app.Signer, app.Shortener, app.Logger)
+	// The limit of received messages also applies to them after decompression.
+	app.GRPCServer = grpc.NewServer(
+		grpc.ChainUnaryInterceptor(authenticate, middleware.GRPCRequestInfoInterceptor(), middleware.GRPCWorkspaceInterceptor()),
+		grpc.MaxRecvMsgSize(int(app.Config.MaxBodySize)),
+	)
+	server := pb.NewGRPCShortenerServer(app.Shortener, app.Logger, pb.WithMaxBatchSize(app.Config.MaxBatchSize))
+	pb.RegisterShortenerServer(app.GRPCServer, server)
 }
 
-// Run starts the HTTP and gRPC servers of the application.
+// Run starts the HTTP and gRPC servers of the application, the job purging deleted URLs,
+// the webhook dispatcher and the relay of the change stream, if any.
 func (app *Application) Run() {
 	go app.runHTTP()
 	go app.runGRPC()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	app.stopJobs = cancel
+	app.jobs.Add(2)
+	go app.runPurge(ctx)
+	go func() {
+		defer app.jobs.Done()
+		app.Dispatcher.Run(ctx)
+	}()
+	if app.Relay != nil {
+		app.jobs.Add(1)
+		go func() {
+			defer app.jobs.Done()
+			app.Relay.Run(ctx)
+		}()
+	}
 }
 
 func (app *Application) runHTTP() {
@@ -105,12 +183,50 @@ func (app *Application) runGRPC() {
 	}
 }
 
-// Shutdown gracefully shuts down the application's servers, storage, and logger.
+// runPurge periodically removes URLs deleted longer than the retention window ago
+// and audit entries older than theirs until ctx is done.
+func (app *Application) runPurge(ctx context.Context) {
+	defer app.jobs.Done()
+
+	ticker := time.NewTicker(time.Duration(app.Config.PurgeInterval))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			purged, err := app.Shortener.PurgeDeleted(ctx)
+			if err != nil {
+				app.Logger.Errorf("Failed to purge deleted URLs: %v", err)
+			} else if purged > 0 {
+				app.Logger.Infof("Purged %d deleted URLs", purged)
+			}
+			purged, err = app.Shortener.PurgeAuditLog(ctx)
+			if err != nil {
+				app.Logger.Errorf("Failed to purge the audit log: %v", err)
+			} else if purged > 0 {
+				app.Logger.Infof("Purged %d audit entries", purged)
+			}
+		}
+	}
+}
+
+// Shutdown gracefully shuts down the application's servers, background jobs, storage, and logger.
 func (app *Application) Shutdown(ctx context.Context) error {
 	app.GRPCServer.GracefulStop()
 	if err := app.HTTPServer.Shutdown(ctx); err != nil {
 		return fmt.Errorf("failed to shutdown HTTP server: %w", err)
 	}
+	if app.stopJobs != nil {
+		app.stopJobs()
+		app.jobs.Wait()
+	}
+	if app.sink != nil {
+		if err := app.sink.Close(); err != nil {
+			return fmt.Errorf("failed to close event sink: %w", err)
+		}
+	}
 	if err := app.Storage.Close(); err != nil {
 		return fmt.Errorf("failed to close storage: %w", err)
 	}
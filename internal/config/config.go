@@ -8,26 +8,92 @@ import (
 	"flag"
 	"fmt"
 	"net"
+	"net/http"
 	"os"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/caarlos0/env/v11"
+	"github.com/grnsv/shortener/internal/models"
 )
 
 // Config holds the application configuration loaded from environment variables and flags.
 type Config struct {
-	AppEnv          string     `env:"APP_ENV" json:"app_env"`                     // Application environment (e.g., local, production)
-	JWTSecret       string     `env:"JWT_SECRET" json:"jwt_secret"`               // Secret key for JWT authentication
-	ServerAddress   NetAddress `env:"SERVER_ADDRESS" json:"server_address"`       // Address for the HTTP server
-	BaseURL         BaseURL    `env:"BASE_URL" json:"base_url"`                   // Base URL for shortened links
-	FileStoragePath string     `env:"FILE_STORAGE_PATH" json:"file_storage_path"` // Path to file storage
-	DatabaseDSN     string     `env:"DATABASE_DSN" json:"database_dsn"`           // Database connection string
-	EnableHTTPS     bool       `env:"ENABLE_HTTPS" json:"enable_https"`           // Enable HTTPS flag
-	CertFile        string     `env:"CERT_FILE" json:"cert_file"`                 // Cert file
-	KeyFile         string     `env:"KEY_FILE" json:"key_file"`                   // Key file
-	Config          string     `env:"CONFIG"`                                     // Config file
-	TrustedSubnet   string     `env:"TRUSTED_SUBNET" json:"trusted_subnet"`       // Trusted subnet
+	AppEnv             string     `env:"APP_ENV" json:"app_env"`                                            // Application environment (e.g., local, production)
+	JWTSecret          string     `env:"JWT_SECRET" json:"jwt_secret"`                                      // Secret key for JWT authentication
+	JWTPreviousSecrets []string   `env:"JWT_PREVIOUS_SECRETS" envSeparator:"," json:"jwt_previous_secrets"` // Rotated-out secrets still accepted for verification
+	JWTAlgorithm       string     `env:"JWT_ALGORITHM" json:"jwt_algorithm"`                                // JWT signing algorithm: HS256, RS256 or EdDSA
+	JWTPrivateKeyFile  string     `env:"JWT_PRIVATE_KEY_FILE" json:"jwt_private_key_file"`                  // PEM private key for RS256 and EdDSA
+	JWTPublicKeyFiles  []string   `env:"JWT_PUBLIC_KEY_FILES" envSeparator:"," json:"jwt_public_key_files"` // Rotated-out PEM public keys still accepted for verification
+	JWTTTL             Duration   `env:"JWT_TTL" json:"jwt_ttl"`                                            // Lifetime of issued tokens
+	ServerAddress      NetAddress `env:"SERVER_ADDRESS" json:"server_address"`                              // Address for the HTTP server
+	BaseURL            BaseURL    `env:"BASE_URL" json:"base_url"`                                          // Base URL for shortened links
+	FileStoragePath    string     `env:"FILE_STORAGE_PATH" json:"file_storage_path"`                        // Path to file storage
+	DatabaseDSN        string     `env:"DATABASE_DSN" json:"database_dsn"`                                  // Database connection string
+	EnableHTTPS        bool       `env:"ENABLE_HTTPS" json:"enable_https"`                                  // Enable HTTPS flag
+	CertFile           string     `env:"CERT_FILE" json:"cert_file"`                                        // Cert file
+	KeyFile            string     `env:"KEY_FILE" json:"key_file"`                                          // Key file
+	Config             string     `env:"CONFIG"`                                                            // Config file
+	TrustedSubnet      string     `env:"TRUSTED_SUBNET" json:"trusted_subnet"`                              // Trusted subnet
+	TrustedProxies     []string   `env:"TRUSTED_PROXIES" envSeparator:"," json:"trusted_proxies"`           // Subnets of reverse proxies whose X-Real-IP header is trusted as the client address
+	DeletedRetention   Duration   `env:"DELETED_RETENTION" json:"deleted_retention"`                        // How long deleted URLs can be restored before they are purged
+	PurgeInterval      Duration   `env:"PURGE_INTERVAL" json:"purge_interval"`                              // How often deleted URLs past the retention window are purged
+	RedirectCode       int        `env:"REDIRECT_CODE" json:"redirect_code"`                                // HTTP status of redirects for links without their own: 301, 302, 307 or 308
+	FetchMetadata      bool       `env:"FETCH_METADATA" json:"fetch_metadata"`                              // Fetch titles and descriptions of destination pages for new links
+	MaxBodySize        int64      `env:"MAX_BODY_SIZE" json:"max_body_size"`                                // Maximum size of request bodies and gRPC messages in bytes, before and after decompression
+	MaxImportSize      int64      `env:"MAX_IMPORT_SIZE" json:"max_import_size"`                            // Maximum size of imported files in bytes, before and after decompression
+	MaxBatchSize       int        `env:"MAX_BATCH_SIZE" json:"max_batch_size"`                              // Maximum number of URLs shortened or deleted in a batch
+	EventSink          string     `env:"EVENT_SINK" json:"event_sink"`                                      // Where the change stream of links is published: stdout or file:<path>; disabled if empty
+	QuotaPlans         Plans      `env:"QUOTA_PLANS" json:"quota_plans"`                                    // Plan tiers limiting the links of users; quotas are disabled if empty
+	DefaultPlan        string     `env:"DEFAULT_PLAN" json:"default_plan"`                                  // Plan of users without one of their own
+	AuditRetention     Duration   `env:"AUDIT_RETENTION" json:"audit_retention"`                            // How long entries of the audit log are kept before they are purged
+	ValidateResponses  bool       `env:"VALIDATE_RESPONSES" json:"validate_responses"`                      // Check JSON responses against the OpenAPI specification, for development and tests
+}
+
+// Default limits of requests.
+const (
+	DefaultMaxBodySize   = 1 << 20
+	DefaultMaxImportSize = 32 << 20
+	DefaultMaxBatchSize  = 1000
+)
+
+// JWT signing algorithms supported by the application.
+const (
+	JWTAlgorithmHS256 = "HS256"
+	JWTAlgorithmRS256 = "RS256"
+	JWTAlgorithmEdDSA = "EdDSA"
+)
+
+// defaultJWTSecret is the development secret that must not be used in production.
+const defaultJWTSecret = "secret"
+
+// Duration is a time.Duration that can be parsed from strings like "720h".
+type Duration time.Duration
+
+// String returns the Duration formatted like time.Duration.
+// It implements the flag.Value interface, allowing it to be used as a command-line flag.
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+// Set parses and sets the Duration from a string like "1h30m".
+// It implements the flag.Value interface, allowing it to be used as a command-line flag.
+func (d *Duration) Set(s string) error {
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// UnmarshalText parses the Duration from text.
+// It implements the encoding.TextUnmarshaler interface, allowing it to be used as an environment variable
+// and as a string in the config file.
+func (d *Duration) UnmarshalText(text []byte) error {
+	return d.Set(string(text))
 }
 
 // NetAddress represents a network address with a host and port.
@@ -109,6 +175,117 @@ func (b *BaseURL) UnmarshalText(text []byte) error {
 	return b.Set(string(text))
 }
 
+// Plans is a list of plan tiers that can be parsed from strings like "free:100:500,pro:0:10000":
+// comma-separated names with their maximum numbers of active links and of links created a month, zero for no limit.
+type Plans []models.Plan
+
+// String returns the Plans formatted like "free:100:500,pro:0:10000".
+// It implements the flag.Value interface, allowing it to be used as a command-line flag.
+func (p Plans) String() string {
+	tiers := make([]string, len(p))
+	for i, plan := range p {
+		tiers[i] = plan.Name + ":" + strconv.Itoa(plan.MaxActiveLinks) + ":" + strconv.Itoa(plan.MaxMonthlyLinks)
+	}
+	return strings.Join(tiers, ",")
+}
+
+// Set parses and sets the Plans from a string like "free:100:500,pro:0:10000".
+// It implements the flag.Value interface, allowing it to be used as a command-line flag.
+func (p *Plans) Set(s string) error {
+	var plans Plans
+	for tier := range strings.SplitSeq(s, ",") {
+		parts := strings.Split(strings.TrimSpace(tier), ":")
+		if len(parts) != 3 {
+			return fmt.Errorf("plan %q must be in a form name:max_active_links:max_monthly_links", tier)
+		}
+		active, err := strconv.Atoi(parts[1])
+		if err != nil {
+			return fmt.Errorf("invalid maximum of active links of plan %q: %w", parts[0], err)
+		}
+		monthly, err := strconv.Atoi(parts[2])
+		if err != nil {
+			return fmt.Errorf("invalid maximum of monthly links of plan %q: %w", parts[0], err)
+		}
+		plans = append(plans, models.Plan{Name: parts[0], MaxActiveLinks: active, MaxMonthlyLinks: monthly})
+	}
+	*p = plans
+	return nil
+}
+
+// UnmarshalText parses the Plans from text.
+// It implements the encoding.TextUnmarshaler interface, allowing it to be used as an environment variable
+// and as a string in the config file.
+func (p *Plans) UnmarshalText(text []byte) error {
+	return p.Set(string(text))
+}
+
+// Validate checks that the configuration is consistent and safe for the application environment.
+func (c *Config) Validate() error {
+	switch c.JWTAlgorithm {
+	case JWTAlgorithmHS256:
+		if c.JWTSecret == "" {
+			return errors.New("JWT secret must not be empty")
+		}
+		if c.AppEnv == "production" && (c.JWTSecret == defaultJWTSecret || slices.Contains(c.JWTPreviousSecrets, defaultJWTSecret)) {
+			return errors.New("default JWT secret must not be used in production")
+		}
+	case JWTAlgorithmRS256, JWTAlgorithmEdDSA:
+		if c.JWTPrivateKeyFile == "" {
+			return fmt.Errorf("JWT private key file is required for %s", c.JWTAlgorithm)
+		}
+	default:
+		return fmt.Errorf("unsupported JWT algorithm %q", c.JWTAlgorithm)
+	}
+	if c.JWTTTL <= 0 {
+		return errors.New("JWT TTL must be positive")
+	}
+	if c.DeletedRetention <= 0 {
+		return errors.New("deleted URLs retention must be positive")
+	}
+	if c.AuditRetention <= 0 {
+		return errors.New("audit log retention must be positive")
+	}
+	if c.PurgeInterval <= 0 {
+		return errors.New("purge interval must be positive")
+	}
+	if !models.ValidRedirectCode(c.RedirectCode) {
+		return fmt.Errorf("unsupported redirect code %d", c.RedirectCode)
+	}
+	if c.MaxBodySize <= 0 || c.MaxImportSize <= 0 || c.MaxBatchSize <= 0 {
+		return errors.New("request size limits must be positive")
+	}
+	if c.EventSink != "" && c.DatabaseDSN == "" {
+		return errors.New("event sink requires the database storage")
+	}
+	for _, proxy := range c.TrustedProxies {
+		if _, _, err := net.ParseCIDR(proxy); err != nil {
+			return fmt.Errorf("trusted proxy %q is not a subnet: %w", proxy, err)
+		}
+	}
+	return c.validatePlans()
+}
+
+// validatePlans checks that plan names are unique, limits are not negative and the default plan is one of them.
+func (c *Config) validatePlans() error {
+	if len(c.QuotaPlans) == 0 {
+		return nil
+	}
+	names := make(map[string]bool, len(c.QuotaPlans))
+	for _, plan := range c.QuotaPlans {
+		if plan.Name == "" || names[plan.Name] {
+			return fmt.Errorf("plan names must be unique and not empty, %q given", plan.Name)
+		}
+		if plan.MaxActiveLinks < 0 || plan.MaxMonthlyLinks < 0 {
+			return fmt.Errorf("limits of plan %q must not be negative", plan.Name)
+		}
+		names[plan.Name] = true
+	}
+	if !names[c.DefaultPlan] {
+		return fmt.Errorf("default plan %q is not one of the quota plans", c.DefaultPlan)
+	}
+	return nil
+}
+
 // Option is a function that applies a configuration option to Config.
 type Option func(*Config)
 
@@ -126,6 +303,56 @@ func WithJWTSecret(key string) Option {
 	}
 }
 
+// WithJWTTTL sets the lifetime of issued JWTs in the Config.
+func WithJWTTTL(ttl time.Duration) Option {
+	return func(c *Config) {
+		c.JWTTTL = Duration(ttl)
+	}
+}
+
+// WithDeletedRetention sets how long deleted URLs can be restored in the Config.
+func WithDeletedRetention(retention time.Duration) Option {
+	return func(c *Config) {
+		c.DeletedRetention = Duration(retention)
+	}
+}
+
+// WithAuditRetention sets how long entries of the audit log are kept in the Config.
+func WithAuditRetention(retention time.Duration) Option {
+	return func(c *Config) {
+		c.AuditRetention = Duration(retention)
+	}
+}
+
+// WithRedirectCode sets the default HTTP status of redirects in the Config.
+func WithRedirectCode(code int) Option {
+	return func(c *Config) {
+		c.RedirectCode = code
+	}
+}
+
+// WithMaxBodySize sets the maximum size of request bodies in the Config.
+func WithMaxBodySize(size int64) Option {
+	return func(c *Config) {
+		c.MaxBodySize = size
+	}
+}
+
+// WithMaxBatchSize sets the maximum number of URLs in a batch in the Config.
+func WithMaxBatchSize(size int) Option {
+	return func(c *Config) {
+		c.MaxBatchSize = size
+	}
+}
+
+// WithQuotaPlans sets the plan tiers and the plan of users without one of their own in the Config.
+func WithQuotaPlans(plans Plans, defaultPlan string) Option {
+	return func(c *Config) {
+		c.QuotaPlans = plans
+		c.DefaultPlan = defaultPlan
+	}
+}
+
 // WithServerAddress sets the server address in the Config.
 func WithServerAddress(addr NetAddress) Option {
 	return func(c *Config) {
@@ -140,6 +367,13 @@ func WithBaseURL(url BaseURL) Option {
 	}
 }
 
+// WithValidateResponses sets whether JSON responses are checked against the OpenAPI specification in the Config.
+func WithValidateResponses(validate bool) Option {
+	return func(c *Config) {
+		c.ValidateResponses = validate
+	}
+}
+
 // WithDatabaseDSN sets the database DSN in the Config.
 func WithDatabaseDSN(dsn string) Option {
 	return func(c *Config) {
@@ -156,14 +390,24 @@ func New(opts ...Option) *Config {
 }
 
 var config = &Config{
-	AppEnv:          "local",
-	JWTSecret:       "secret",
-	ServerAddress:   NetAddress{"localhost", 8080},
-	BaseURL:         BaseURL{"http://", NetAddress{"localhost", 8080}},
-	FileStoragePath: "",
-	DatabaseDSN:     "",
-	CertFile:        "../../certs/cert.pem",
-	KeyFile:         "../../certs/key.pem",
+	AppEnv:           "local",
+	JWTSecret:        defaultJWTSecret,
+	JWTAlgorithm:     JWTAlgorithmHS256,
+	JWTTTL:           Duration(30 * 24 * time.Hour),
+	DeletedRetention: Duration(30 * 24 * time.Hour),
+	AuditRetention:   Duration(365 * 24 * time.Hour),
+	PurgeInterval:    Duration(time.Hour),
+	RedirectCode:     http.StatusTemporaryRedirect,
+	MaxBodySize:      DefaultMaxBodySize,
+	MaxImportSize:    DefaultMaxImportSize,
+	MaxBatchSize:     DefaultMaxBatchSize,
+	DefaultPlan:      "free",
+	ServerAddress:    NetAddress{"localhost", 8080},
+	BaseURL:          BaseURL{"http://", NetAddress{"localhost", 8080}},
+	FileStoragePath:  "",
+	DatabaseDSN:      "",
+	CertFile:         "../../certs/cert.pem",
+	KeyFile:          "../../certs/key.pem",
 }
 
 func parseFlags() error {
@@ -176,9 +420,36 @@ func parseFlags() error {
 	set.StringVar(&config.Config, "c", config.Config, "Config file")
 	set.StringVar(&config.Config, "config", config.Config, "Config file")
 	set.StringVar(&config.TrustedSubnet, "t", config.TrustedSubnet, "Trusted subnet")
+	set.Func("trusted-proxies", "Subnets of reverse proxies whose X-Real-IP header is trusted (10.0.0.0/8,127.0.0.1/32)", listFlag(&config.TrustedProxies))
+	set.StringVar(&config.JWTSecret, "jwt-secret", config.JWTSecret, "Secret key for HS256 JWTs")
+	set.Func("jwt-previous-secrets", "Rotated-out JWT secrets still accepted for verification (old1,old2)", listFlag(&config.JWTPreviousSecrets))
+	set.StringVar(&config.JWTAlgorithm, "jwt-algorithm", config.JWTAlgorithm, "JWT signing algorithm (HS256, RS256 or EdDSA)")
+	set.StringVar(&config.JWTPrivateKeyFile, "jwt-private-key-file", config.JWTPrivateKeyFile, "PEM private key for RS256 and EdDSA JWTs")
+	set.Func("jwt-public-key-files", "Rotated-out PEM public keys still accepted for verification (old1.pem,old2.pem)", listFlag(&config.JWTPublicKeyFiles))
+	set.Var(&config.JWTTTL, "jwt-ttl", "Lifetime of issued JWTs (720h)")
+	set.Var(&config.PurgeInterval, "purge-interval", "How often deleted URLs past the retention window are purged (1h)")
+	set.Var(&config.DeletedRetention, "deleted-retention", "How long deleted URLs can be restored (720h)")
+	set.Var(&config.AuditRetention, "audit-retention", "How long entries of the audit log are kept (8760h)")
+	set.IntVar(&config.RedirectCode, "redirect-code", config.RedirectCode, "Default HTTP status of redirects (301, 302, 307 or 308)")
+	set.BoolVar(&config.FetchMetadata, "fetch-metadata", config.FetchMetadata, "Fetch titles of destination pages for new links")
+	set.BoolVar(&config.ValidateResponses, "validate-responses", config.ValidateResponses, "Check JSON responses against the OpenAPI specification")
+	set.Int64Var(&config.MaxBodySize, "max-body-size", config.MaxBodySize, "Maximum size of request bodies in bytes")
+	set.Int64Var(&config.MaxImportSize, "max-import-size", config.MaxImportSize, "Maximum size of imported files in bytes")
+	set.IntVar(&config.MaxBatchSize, "max-batch-size", config.MaxBatchSize, "Maximum number of URLs in a batch")
+	set.Var(&config.QuotaPlans, "quota-plans", "Plan tiers limiting the links of users (free:100:500,pro:0:10000)")
+	set.StringVar(&config.DefaultPlan, "default-plan", config.DefaultPlan, "Plan of users without one of their own")
+	set.StringVar(&config.EventSink, "event-sink", config.EventSink, "Where to publish the change stream of links (stdout, file:/data/events.jsonl)")
 	return set.Parse(os.Args[1:])
 }
 
+// listFlag returns a flag setter of a comma-separated list, split like the list environment variables.
+func listFlag(list *[]string) func(string) error {
+	return func(value string) error {
+		*list = strings.Split(value, ",")
+		return nil
+	}
+}
+
 // Parse loads configuration from flags and environment variables and returns a Config pointer.
 func Parse() (*Config, error) {
 	err := loadConfigFile()
@@ -193,6 +464,10 @@ func Parse() (*Config, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse env: %w", err)
 	}
+	err = config.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
 	return config, nil
 }
 
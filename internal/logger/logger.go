@@ -23,7 +23,7 @@ type Logger interface {
 	// Fatal(args ...interface{})
 
 	// Debugf(template string, args ...interface{})
-	// Infof(template string, args ...interface{})
+	Infof(template string, args ...interface{})
 	// Warnf(template string, args ...interface{})
 	Errorf(template string, args ...interface{})
 	// DPanicf(template string, args ...interface{})
@@ -0,0 +1,151 @@
+// Package metadata fetches the title and description of web pages that short URLs lead to.
+package metadata
+
+import (
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/grnsv/shortener/internal/models"
+	"golang.org/x/net/html"
+)
+
+const (
+	// DefaultTimeout limits how long fetching a page may take with the default client.
+	DefaultTimeout = 5 * time.Second
+	// maxBodySize is how much of a page is read looking for its metadata.
+	maxBodySize = 1 << 20
+)
+
+// Fetch errors.
+var (
+	ErrNotHTML          = errors.New("not an HTML page")
+	ErrForbiddenAddress = errors.New("address is not publicly routable")
+)
+
+// Fetcher retrieves metadata of web pages over HTTP.
+type Fetcher struct {
+	client *http.Client
+}
+
+// NewFetcher creates a Fetcher using client. A nil client selects NewHTTPClient.
+func NewFetcher(client *http.Client) *Fetcher {
+	if client == nil {
+		client = NewHTTPClient()
+	}
+	return &Fetcher{client: client}
+}
+
+// NewHTTPClient returns a client suitable for fetching user-supplied URLs:
+// it times out after DefaultTimeout and refuses to connect to loopback, private and other
+// non-public addresses, so short URLs cannot be used to probe the internal network.
+func NewHTTPClient() *http.Client {
+	dialer := &net.Dialer{Timeout: DefaultTimeout, Control: denyNonPublic}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Timeout: DefaultTimeout, Transport: transport}
+}
+
+func denyNonPublic(_ string, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !ip.IsGlobalUnicast() || ip.IsPrivate() {
+		return ErrForbiddenAddress
+	}
+	return nil
+}
+
+// Fetch downloads the page at url and returns its title and description.
+// Only the title and description of the result are set.
+func (f *Fetcher) Fetch(ctx context.Context, url string) (meta models.URLMetadata, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return meta, err
+	}
+	req.Header.Set("Accept", "text/html")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return meta, err
+	}
+	defer func() {
+		err = errors.Join(err, resp.Body.Close())
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return meta, errors.New("unexpected status " + resp.Status)
+	}
+	if mediaType, _, parseErr := mime.ParseMediaType(resp.Header.Get("Content-Type")); parseErr != nil || mediaType != "text/html" {
+		return meta, ErrNotHTML
+	}
+
+	return parse(io.LimitReader(resp.Body, maxBodySize)), nil
+}
+
+// parse extracts the title and description from the head of an HTML document.
+func parse(r io.Reader) models.URLMetadata {
+	var meta models.URLMetadata
+	tokenizer := html.NewTokenizer(r)
+	inTitle := false
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			return meta
+		case html.TextToken:
+			if inTitle && meta.Title == "" {
+				meta.Title = strings.Join(strings.Fields(string(tokenizer.Text())), " ")
+			}
+		case html.EndTagToken:
+			name, _ := tokenizer.TagName()
+			switch string(name) {
+			case "title":
+				inTitle = false
+			case "head":
+				return meta
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := tokenizer.TagName()
+			switch string(name) {
+			case "title":
+				inTitle = true
+			case "meta":
+				if meta.Description == "" && hasAttr {
+					meta.Description = description(tokenizer)
+				}
+			case "body":
+				return meta
+			}
+		}
+	}
+}
+
+// description returns the content of a description meta tag, or an empty string for other meta tags.
+func description(tokenizer *html.Tokenizer) string {
+	var name, content string
+	for {
+		key, value, more := tokenizer.TagAttr()
+		switch strings.ToLower(string(key)) {
+		case "name", "property":
+			name = strings.ToLower(string(value))
+		case "content":
+			content = string(value)
+		}
+		if !more {
+			break
+		}
+	}
+	if name != "description" && name != "og:description" {
+		return ""
+	}
+	return strings.Join(strings.Fields(content), " ")
+}
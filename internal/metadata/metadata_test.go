@@ -0,0 +1,85 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFetch(t *testing.T) {
+	tests := []struct {
+		name            string
+		contentType     string
+		body            string
+		wantTitle       string
+		wantDescription string
+		wantErr         error
+	}{
+		{
+			name:            "title and description",
+			contentType:     "text/html; charset=utf-8",
+			body:            `<html><head><title> Example &amp; Co </title><meta name="description" content="An example page"></head></html>`,
+			wantTitle:       "Example & Co",
+			wantDescription: "An example page",
+		},
+		{
+			name:            "open graph description",
+			contentType:     "text/html",
+			body:            `<head><meta property="og:description" content="From Open Graph"><title>OG</title></head>`,
+			wantTitle:       "OG",
+			wantDescription: "From Open Graph",
+		},
+		{
+			name:        "no metadata",
+			contentType: "text/html",
+			body:        `<p>hello</p>`,
+		},
+		{
+			name:        "not HTML",
+			contentType: "application/json",
+			body:        `{"title":"nope"}`,
+			wantErr:     ErrNotHTML,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tt.contentType)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+
+			meta, err := NewFetcher(srv.Client()).Fetch(context.Background(), srv.URL)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantTitle, meta.Title)
+			assert.Equal(t, tt.wantDescription, meta.Description)
+		})
+	}
+}
+
+func TestFetchStatus(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+
+	_, err := NewFetcher(srv.Client()).Fetch(context.Background(), srv.URL)
+	assert.Error(t, err)
+}
+
+func TestDefaultClientRefusesLoopback(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+	}))
+	defer srv.Close()
+
+	_, err := NewFetcher(nil).Fetch(context.Background(), srv.URL)
+	assert.True(t, errors.Is(err, ErrForbiddenAddress), "got %v", err)
+}
@@ -10,6 +10,8 @@ import (
 
 	gomock "github.com/golang/mock/gomock"
 	models "github.com/grnsv/shortener/internal/models"
+	qr "github.com/grnsv/shortener/internal/qr"
+	service "github.com/grnsv/shortener/internal/service"
 )
 
 // MockShortener is a mock of Shortener interface.
@@ -35,6 +37,66 @@ func (m *MockShortener) EXPECT() *MockShortenerMockRecorder {
 	return m.recorder
 }
 
+// AddDomain mocks base method.
+func (m *MockShortener) AddDomain(arg0 context.Context, arg1, arg2 string) (*models.Domain, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddDomain", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*models.Domain)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddDomain indicates an expected call of AddDomain.
+func (mr *MockShortenerMockRecorder) AddDomain(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddDomain", reflect.TypeOf((*MockShortener)(nil).AddDomain), arg0, arg1, arg2)
+}
+
+// CreateAPIKey mocks base method.
+func (m *MockShortener) CreateAPIKey(arg0 context.Context, arg1, arg2 string, arg3 []string) (*models.CreateAPIKeyResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateAPIKey", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(*models.CreateAPIKeyResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateAPIKey indicates an expected call of CreateAPIKey.
+func (mr *MockShortenerMockRecorder) CreateAPIKey(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateAPIKey", reflect.TypeOf((*MockShortener)(nil).CreateAPIKey), arg0, arg1, arg2, arg3)
+}
+
+// CreateWebhook mocks base method.
+func (m *MockShortener) CreateWebhook(arg0 context.Context, arg1, arg2 string, arg3 []string) (*models.Webhook, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateWebhook", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(*models.Webhook)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateWebhook indicates an expected call of CreateWebhook.
+func (mr *MockShortenerMockRecorder) CreateWebhook(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateWebhook", reflect.TypeOf((*MockShortener)(nil).CreateWebhook), arg0, arg1, arg2, arg3)
+}
+
+// CreateWorkspace mocks base method.
+func (m *MockShortener) CreateWorkspace(arg0 context.Context, arg1, arg2 string) (*models.Workspace, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateWorkspace", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*models.Workspace)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateWorkspace indicates an expected call of CreateWorkspace.
+func (mr *MockShortenerMockRecorder) CreateWorkspace(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateWorkspace", reflect.TypeOf((*MockShortener)(nil).CreateWorkspace), arg0, arg1, arg2)
+}
+
 // DeleteMany mocks base method.
 func (m *MockShortener) DeleteMany(arg0 context.Context, arg1 string, arg2 []string) error {
 	m.ctrl.T.Helper()
@@ -49,13 +111,28 @@ func (mr *MockShortenerMockRecorder) DeleteMany(arg0, arg1, arg2 interface{}) *g
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteMany", reflect.TypeOf((*MockShortener)(nil).DeleteMany), arg0, arg1, arg2)
 }
 
+// DeleteWebhook mocks base method.
+func (m *MockShortener) DeleteWebhook(arg0 context.Context, arg1, arg2 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteWebhook", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteWebhook indicates an expected call of DeleteWebhook.
+func (mr *MockShortenerMockRecorder) DeleteWebhook(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteWebhook", reflect.TypeOf((*MockShortener)(nil).DeleteWebhook), arg0, arg1, arg2)
+}
+
 // ExpandURL mocks base method.
-func (m *MockShortener) ExpandURL(arg0 context.Context, arg1 string) (string, error) {
+func (m *MockShortener) ExpandURL(arg0 context.Context, arg1 string) (string, int, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "ExpandURL", arg0, arg1)
 	ret0, _ := ret[0].(string)
-	ret1, _ := ret[1].(error)
-	return ret0, ret1
+	ret1, _ := ret[1].(int)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
 }
 
 // ExpandURL indicates an expected call of ExpandURL.
@@ -64,19 +141,139 @@ func (mr *MockShortenerMockRecorder) ExpandURL(arg0, arg1 interface{}) *gomock.C
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExpandURL", reflect.TypeOf((*MockShortener)(nil).ExpandURL), arg0, arg1)
 }
 
+// ExpandUnlockedURL mocks base method.
+func (m *MockShortener) ExpandUnlockedURL(arg0 context.Context, arg1, arg2 string) (string, int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExpandUnlockedURL", arg0, arg1, arg2)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(int)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// ExpandUnlockedURL indicates an expected call of ExpandUnlockedURL.
+func (mr *MockShortenerMockRecorder) ExpandUnlockedURL(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExpandUnlockedURL", reflect.TypeOf((*MockShortener)(nil).ExpandUnlockedURL), arg0, arg1, arg2)
+}
+
+// ExportURLs mocks base method.
+func (m *MockShortener) ExportURLs(arg0 context.Context, arg1 string) service.LinkSeq {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExportURLs", arg0, arg1)
+	ret0, _ := ret[0].(service.LinkSeq)
+	return ret0
+}
+
+// ExportURLs indicates an expected call of ExportURLs.
+func (mr *MockShortenerMockRecorder) ExportURLs(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExportURLs", reflect.TypeOf((*MockShortener)(nil).ExportURLs), arg0, arg1)
+}
+
+// GetAPIKeys mocks base method.
+func (m *MockShortener) GetAPIKeys(arg0 context.Context, arg1 string) ([]models.APIKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAPIKeys", arg0, arg1)
+	ret0, _ := ret[0].([]models.APIKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAPIKeys indicates an expected call of GetAPIKeys.
+func (mr *MockShortenerMockRecorder) GetAPIKeys(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAPIKeys", reflect.TypeOf((*MockShortener)(nil).GetAPIKeys), arg0, arg1)
+}
+
 // GetAll mocks base method.
-func (m *MockShortener) GetAll(arg0 context.Context, arg1 string) ([]models.URL, error) {
+func (m *MockShortener) GetAll(arg0 context.Context, arg1 string, arg2 models.URLFilter) ([]models.URL, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetAll", arg0, arg1)
+	ret := m.ctrl.Call(m, "GetAll", arg0, arg1, arg2)
 	ret0, _ := ret[0].([]models.URL)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetAll indicates an expected call of GetAll.
-func (mr *MockShortenerMockRecorder) GetAll(arg0, arg1 interface{}) *gomock.Call {
+func (mr *MockShortenerMockRecorder) GetAll(arg0, arg1, arg2 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAll", reflect.TypeOf((*MockShortener)(nil).GetAll), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAll", reflect.TypeOf((*MockShortener)(nil).GetAll), arg0, arg1, arg2)
+}
+
+// GetAuditLog mocks base method.
+func (m *MockShortener) GetAuditLog(arg0 context.Context, arg1 models.AuditFilter) ([]models.AuditEntry, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAuditLog", arg0, arg1)
+	ret0, _ := ret[0].([]models.AuditEntry)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAuditLog indicates an expected call of GetAuditLog.
+func (mr *MockShortenerMockRecorder) GetAuditLog(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAuditLog", reflect.TypeOf((*MockShortener)(nil).GetAuditLog), arg0, arg1)
+}
+
+// GetDomains mocks base method.
+func (m *MockShortener) GetDomains(arg0 context.Context, arg1 string) ([]models.Domain, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDomains", arg0, arg1)
+	ret0, _ := ret[0].([]models.Domain)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDomains indicates an expected call of GetDomains.
+func (mr *MockShortenerMockRecorder) GetDomains(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDomains", reflect.TypeOf((*MockShortener)(nil).GetDomains), arg0, arg1)
+}
+
+// GetMembers mocks base method.
+func (m *MockShortener) GetMembers(arg0 context.Context, arg1, arg2 string) ([]models.WorkspaceMember, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetMembers", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]models.WorkspaceMember)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetMembers indicates an expected call of GetMembers.
+func (mr *MockShortenerMockRecorder) GetMembers(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMembers", reflect.TypeOf((*MockShortener)(nil).GetMembers), arg0, arg1, arg2)
+}
+
+// GetQRCode mocks base method.
+func (m *MockShortener) GetQRCode(arg0 context.Context, arg1 string, arg2 qr.Options) (*qr.Image, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetQRCode", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*qr.Image)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetQRCode indicates an expected call of GetQRCode.
+func (mr *MockShortenerMockRecorder) GetQRCode(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetQRCode", reflect.TypeOf((*MockShortener)(nil).GetQRCode), arg0, arg1, arg2)
+}
+
+// GetQuota mocks base method.
+func (m *MockShortener) GetQuota(arg0 context.Context, arg1 string) (*models.Quota, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetQuota", arg0, arg1)
+	ret0, _ := ret[0].(*models.Quota)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetQuota indicates an expected call of GetQuota.
+func (mr *MockShortenerMockRecorder) GetQuota(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetQuota", reflect.TypeOf((*MockShortener)(nil).GetQuota), arg0, arg1)
 }
 
 // GetStats mocks base method.
@@ -94,6 +291,96 @@ func (mr *MockShortenerMockRecorder) GetStats(arg0 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetStats", reflect.TypeOf((*MockShortener)(nil).GetStats), arg0)
 }
 
+// GetURLHistory mocks base method.
+func (m *MockShortener) GetURLHistory(arg0 context.Context, arg1, arg2 string) ([]models.URLHistory, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetURLHistory", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]models.URLHistory)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetURLHistory indicates an expected call of GetURLHistory.
+func (mr *MockShortenerMockRecorder) GetURLHistory(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetURLHistory", reflect.TypeOf((*MockShortener)(nil).GetURLHistory), arg0, arg1, arg2)
+}
+
+// GetWebhookDeliveries mocks base method.
+func (m *MockShortener) GetWebhookDeliveries(arg0 context.Context, arg1, arg2 string) ([]models.WebhookDelivery, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWebhookDeliveries", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]models.WebhookDelivery)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWebhookDeliveries indicates an expected call of GetWebhookDeliveries.
+func (mr *MockShortenerMockRecorder) GetWebhookDeliveries(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWebhookDeliveries", reflect.TypeOf((*MockShortener)(nil).GetWebhookDeliveries), arg0, arg1, arg2)
+}
+
+// GetWebhooks mocks base method.
+func (m *MockShortener) GetWebhooks(arg0 context.Context, arg1 string) ([]models.Webhook, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWebhooks", arg0, arg1)
+	ret0, _ := ret[0].([]models.Webhook)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWebhooks indicates an expected call of GetWebhooks.
+func (mr *MockShortenerMockRecorder) GetWebhooks(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWebhooks", reflect.TypeOf((*MockShortener)(nil).GetWebhooks), arg0, arg1)
+}
+
+// GetWorkspaces mocks base method.
+func (m *MockShortener) GetWorkspaces(arg0 context.Context, arg1 string) ([]models.Workspace, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWorkspaces", arg0, arg1)
+	ret0, _ := ret[0].([]models.Workspace)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWorkspaces indicates an expected call of GetWorkspaces.
+func (mr *MockShortenerMockRecorder) GetWorkspaces(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWorkspaces", reflect.TypeOf((*MockShortener)(nil).GetWorkspaces), arg0, arg1)
+}
+
+// ImportURLs mocks base method.
+func (m *MockShortener) ImportURLs(arg0 context.Context, arg1 string, arg2 service.LinkSeq) (*models.ImportReport, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ImportURLs", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*models.ImportReport)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ImportURLs indicates an expected call of ImportURLs.
+func (mr *MockShortenerMockRecorder) ImportURLs(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ImportURLs", reflect.TypeOf((*MockShortener)(nil).ImportURLs), arg0, arg1, arg2)
+}
+
+// LinkOwner mocks base method.
+func (m *MockShortener) LinkOwner(arg0 context.Context, arg1, arg2 string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LinkOwner", arg0, arg1, arg2)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LinkOwner indicates an expected call of LinkOwner.
+func (mr *MockShortenerMockRecorder) LinkOwner(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LinkOwner", reflect.TypeOf((*MockShortener)(nil).LinkOwner), arg0, arg1, arg2)
+}
+
 // PingStorage mocks base method.
 func (m *MockShortener) PingStorage(arg0 context.Context) error {
 	m.ctrl.T.Helper()
@@ -108,25 +395,186 @@ func (mr *MockShortenerMockRecorder) PingStorage(arg0 interface{}) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PingStorage", reflect.TypeOf((*MockShortener)(nil).PingStorage), arg0)
 }
 
+// PreviewURL mocks base method.
+func (m *MockShortener) PreviewURL(arg0 context.Context, arg1 string) (*models.Preview, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PreviewURL", arg0, arg1)
+	ret0, _ := ret[0].(*models.Preview)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PreviewURL indicates an expected call of PreviewURL.
+func (mr *MockShortenerMockRecorder) PreviewURL(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PreviewURL", reflect.TypeOf((*MockShortener)(nil).PreviewURL), arg0, arg1)
+}
+
+// PurgeAuditLog mocks base method.
+func (m *MockShortener) PurgeAuditLog(arg0 context.Context) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PurgeAuditLog", arg0)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PurgeAuditLog indicates an expected call of PurgeAuditLog.
+func (mr *MockShortenerMockRecorder) PurgeAuditLog(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PurgeAuditLog", reflect.TypeOf((*MockShortener)(nil).PurgeAuditLog), arg0)
+}
+
+// PurgeDeleted mocks base method.
+func (m *MockShortener) PurgeDeleted(arg0 context.Context) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PurgeDeleted", arg0)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PurgeDeleted indicates an expected call of PurgeDeleted.
+func (mr *MockShortenerMockRecorder) PurgeDeleted(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PurgeDeleted", reflect.TypeOf((*MockShortener)(nil).PurgeDeleted), arg0)
+}
+
+// RemoveMember mocks base method.
+func (m *MockShortener) RemoveMember(arg0 context.Context, arg1, arg2, arg3 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemoveMember", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RemoveMember indicates an expected call of RemoveMember.
+func (mr *MockShortenerMockRecorder) RemoveMember(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveMember", reflect.TypeOf((*MockShortener)(nil).RemoveMember), arg0, arg1, arg2, arg3)
+}
+
+// RestoreURL mocks base method.
+func (m *MockShortener) RestoreURL(arg0 context.Context, arg1, arg2 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RestoreURL", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RestoreURL indicates an expected call of RestoreURL.
+func (mr *MockShortenerMockRecorder) RestoreURL(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RestoreURL", reflect.TypeOf((*MockShortener)(nil).RestoreURL), arg0, arg1, arg2)
+}
+
+// RevokeAPIKey mocks base method.
+func (m *MockShortener) RevokeAPIKey(arg0 context.Context, arg1, arg2 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeAPIKey", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeAPIKey indicates an expected call of RevokeAPIKey.
+func (mr *MockShortenerMockRecorder) RevokeAPIKey(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeAPIKey", reflect.TypeOf((*MockShortener)(nil).RevokeAPIKey), arg0, arg1, arg2)
+}
+
+// SearchURLs mocks base method.
+func (m *MockShortener) SearchURLs(arg0 context.Context, arg1, arg2 string, arg3 int) ([]models.URL, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SearchURLs", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].([]models.URL)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SearchURLs indicates an expected call of SearchURLs.
+func (mr *MockShortenerMockRecorder) SearchURLs(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SearchURLs", reflect.TypeOf((*MockShortener)(nil).SearchURLs), arg0, arg1, arg2, arg3)
+}
+
+// SetMember mocks base method.
+func (m *MockShortener) SetMember(arg0 context.Context, arg1, arg2, arg3, arg4 string) (*models.WorkspaceMember, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetMember", arg0, arg1, arg2, arg3, arg4)
+	ret0, _ := ret[0].(*models.WorkspaceMember)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SetMember indicates an expected call of SetMember.
+func (mr *MockShortenerMockRecorder) SetMember(arg0, arg1, arg2, arg3, arg4 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetMember", reflect.TypeOf((*MockShortener)(nil).SetMember), arg0, arg1, arg2, arg3, arg4)
+}
+
+// SetPlan mocks base method.
+func (m *MockShortener) SetPlan(arg0 context.Context, arg1, arg2 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetPlan", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetPlan indicates an expected call of SetPlan.
+func (mr *MockShortenerMockRecorder) SetPlan(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetPlan", reflect.TypeOf((*MockShortener)(nil).SetPlan), arg0, arg1, arg2)
+}
+
+// Shorten mocks base method.
+func (m *MockShortener) Shorten(arg0 context.Context, arg1, arg2 string, arg3 ...service.ShortenOption) (*models.URL, bool, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{arg0, arg1, arg2}
+	for _, a := range arg3 {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "Shorten", varargs...)
+	ret0, _ := ret[0].(*models.URL)
+	ret1, _ := ret[1].(bool)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// Shorten indicates an expected call of Shorten.
+func (mr *MockShortenerMockRecorder) Shorten(arg0, arg1, arg2 interface{}, arg3 ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{arg0, arg1, arg2}, arg3...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Shorten", reflect.TypeOf((*MockShortener)(nil).Shorten), varargs...)
+}
+
 // ShortenBatch mocks base method.
-func (m *MockShortener) ShortenBatch(arg0 context.Context, arg1 models.BatchRequest, arg2 string) (models.BatchResponse, error) {
+func (m *MockShortener) ShortenBatch(arg0 context.Context, arg1 models.BatchRequest, arg2, arg3 string, arg4 ...service.ShortenOption) (models.BatchResponse, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ShortenBatch", arg0, arg1, arg2)
+	varargs := []interface{}{arg0, arg1, arg2, arg3}
+	for _, a := range arg4 {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "ShortenBatch", varargs...)
 	ret0, _ := ret[0].(models.BatchResponse)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // ShortenBatch indicates an expected call of ShortenBatch.
-func (mr *MockShortenerMockRecorder) ShortenBatch(arg0, arg1, arg2 interface{}) *gomock.Call {
+func (mr *MockShortenerMockRecorder) ShortenBatch(arg0, arg1, arg2, arg3 interface{}, arg4 ...interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ShortenBatch", reflect.TypeOf((*MockShortener)(nil).ShortenBatch), arg0, arg1, arg2)
+	varargs := append([]interface{}{arg0, arg1, arg2, arg3}, arg4...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ShortenBatch", reflect.TypeOf((*MockShortener)(nil).ShortenBatch), varargs...)
 }
 
 // ShortenURL mocks base method.
-func (m *MockShortener) ShortenURL(arg0 context.Context, arg1, arg2 string) (string, bool, error) {
+func (m *MockShortener) ShortenURL(arg0 context.Context, arg1, arg2 string, arg3 ...service.ShortenOption) (string, bool, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ShortenURL", arg0, arg1, arg2)
+	varargs := []interface{}{arg0, arg1, arg2}
+	for _, a := range arg3 {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "ShortenURL", varargs...)
 	ret0, _ := ret[0].(string)
 	ret1, _ := ret[1].(bool)
 	ret2, _ := ret[2].(error)
@@ -134,7 +582,69 @@ func (m *MockShortener) ShortenURL(arg0 context.Context, arg1, arg2 string) (str
 }
 
 // ShortenURL indicates an expected call of ShortenURL.
-func (mr *MockShortenerMockRecorder) ShortenURL(arg0, arg1, arg2 interface{}) *gomock.Call {
+func (mr *MockShortenerMockRecorder) ShortenURL(arg0, arg1, arg2 interface{}, arg3 ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{arg0, arg1, arg2}, arg3...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ShortenURL", reflect.TypeOf((*MockShortener)(nil).ShortenURL), varargs...)
+}
+
+// UnlockURL mocks base method.
+func (m *MockShortener) UnlockURL(arg0 context.Context, arg1, arg2, arg3 string) (string, string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UnlockURL", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(string)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// UnlockURL indicates an expected call of UnlockURL.
+func (mr *MockShortenerMockRecorder) UnlockURL(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UnlockURL", reflect.TypeOf((*MockShortener)(nil).UnlockURL), arg0, arg1, arg2, arg3)
+}
+
+// UpdateURL mocks base method.
+func (m *MockShortener) UpdateURL(arg0 context.Context, arg1, arg2 string, arg3 models.UpdateURLRequest) (*models.URL, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateURL", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(*models.URL)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateURL indicates an expected call of UpdateURL.
+func (mr *MockShortenerMockRecorder) UpdateURL(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateURL", reflect.TypeOf((*MockShortener)(nil).UpdateURL), arg0, arg1, arg2, arg3)
+}
+
+// VerifyAPIKey mocks base method.
+func (m *MockShortener) VerifyAPIKey(arg0 context.Context, arg1 string) (*models.APIKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "VerifyAPIKey", arg0, arg1)
+	ret0, _ := ret[0].(*models.APIKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// VerifyAPIKey indicates an expected call of VerifyAPIKey.
+func (mr *MockShortenerMockRecorder) VerifyAPIKey(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VerifyAPIKey", reflect.TypeOf((*MockShortener)(nil).VerifyAPIKey), arg0, arg1)
+}
+
+// VerifyDomain mocks base method.
+func (m *MockShortener) VerifyDomain(arg0 context.Context, arg1, arg2 string) (*models.Domain, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "VerifyDomain", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*models.Domain)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// VerifyDomain indicates an expected call of VerifyDomain.
+func (mr *MockShortenerMockRecorder) VerifyDomain(arg0, arg1, arg2 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ShortenURL", reflect.TypeOf((*MockShortener)(nil).ShortenURL), arg0, arg1, arg2)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VerifyDomain", reflect.TypeOf((*MockShortener)(nil).VerifyDomain), arg0, arg1, arg2)
 }
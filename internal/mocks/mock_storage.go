@@ -8,6 +8,7 @@ import (
 	context "context"
 	sql "database/sql"
 	reflect "reflect"
+	time "time"
 
 	gomock "github.com/golang/mock/gomock"
 	models "github.com/grnsv/shortener/internal/models"
@@ -38,6 +39,35 @@ func (m *MockStorage) EXPECT() *MockStorageMockRecorder {
 	return m.recorder
 }
 
+// AddUsage mocks base method.
+func (m *MockStorage) AddUsage(arg0 context.Context, arg1, arg2 string, arg3 int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddUsage", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddUsage indicates an expected call of AddUsage.
+func (mr *MockStorageMockRecorder) AddUsage(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddUsage", reflect.TypeOf((*MockStorage)(nil).AddUsage), arg0, arg1, arg2, arg3)
+}
+
+// ClaimDeliveries mocks base method.
+func (m *MockStorage) ClaimDeliveries(arg0 context.Context, arg1, arg2 time.Time, arg3 int) ([]models.WebhookDelivery, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ClaimDeliveries", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].([]models.WebhookDelivery)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ClaimDeliveries indicates an expected call of ClaimDeliveries.
+func (mr *MockStorageMockRecorder) ClaimDeliveries(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClaimDeliveries", reflect.TypeOf((*MockStorage)(nil).ClaimDeliveries), arg0, arg1, arg2, arg3)
+}
+
 // Close mocks base method.
 func (m *MockStorage) Close() error {
 	m.ctrl.T.Helper()
@@ -52,6 +82,35 @@ func (mr *MockStorageMockRecorder) Close() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Close", reflect.TypeOf((*MockStorage)(nil).Close))
 }
 
+// CountActiveURLs mocks base method.
+func (m *MockStorage) CountActiveURLs(arg0 context.Context, arg1 string) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CountActiveURLs", arg0, arg1)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CountActiveURLs indicates an expected call of CountActiveURLs.
+func (mr *MockStorageMockRecorder) CountActiveURLs(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountActiveURLs", reflect.TypeOf((*MockStorage)(nil).CountActiveURLs), arg0, arg1)
+}
+
+// CreateWorkspace mocks base method.
+func (m *MockStorage) CreateWorkspace(arg0 context.Context, arg1 models.Workspace, arg2 models.WorkspaceMember) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateWorkspace", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateWorkspace indicates an expected call of CreateWorkspace.
+func (mr *MockStorageMockRecorder) CreateWorkspace(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateWorkspace", reflect.TypeOf((*MockStorage)(nil).CreateWorkspace), arg0, arg1, arg2)
+}
+
 // DeleteMany mocks base method.
 func (m *MockStorage) DeleteMany(arg0 context.Context, arg1 string, arg2 []string) error {
 	m.ctrl.T.Helper()
@@ -66,11 +125,39 @@ func (mr *MockStorageMockRecorder) DeleteMany(arg0, arg1, arg2 interface{}) *gom
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteMany", reflect.TypeOf((*MockStorage)(nil).DeleteMany), arg0, arg1, arg2)
 }
 
+// DeleteMember mocks base method.
+func (m *MockStorage) DeleteMember(arg0 context.Context, arg1, arg2 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteMember", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteMember indicates an expected call of DeleteMember.
+func (mr *MockStorageMockRecorder) DeleteMember(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteMember", reflect.TypeOf((*MockStorage)(nil).DeleteMember), arg0, arg1, arg2)
+}
+
+// DeleteWebhook mocks base method.
+func (m *MockStorage) DeleteWebhook(arg0 context.Context, arg1, arg2 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteWebhook", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteWebhook indicates an expected call of DeleteWebhook.
+func (mr *MockStorageMockRecorder) DeleteWebhook(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteWebhook", reflect.TypeOf((*MockStorage)(nil).DeleteWebhook), arg0, arg1, arg2)
+}
+
 // Get mocks base method.
-func (m *MockStorage) Get(arg0 context.Context, arg1 string) (string, error) {
+func (m *MockStorage) Get(arg0 context.Context, arg1 string) (models.URL, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "Get", arg0, arg1)
-	ret0, _ := ret[0].(string)
+	ret0, _ := ret[0].(models.URL)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
@@ -81,19 +168,139 @@ func (mr *MockStorageMockRecorder) Get(arg0, arg1 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockStorage)(nil).Get), arg0, arg1)
 }
 
+// GetAPIKeyByHash mocks base method.
+func (m *MockStorage) GetAPIKeyByHash(arg0 context.Context, arg1 string) (models.APIKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAPIKeyByHash", arg0, arg1)
+	ret0, _ := ret[0].(models.APIKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAPIKeyByHash indicates an expected call of GetAPIKeyByHash.
+func (mr *MockStorageMockRecorder) GetAPIKeyByHash(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAPIKeyByHash", reflect.TypeOf((*MockStorage)(nil).GetAPIKeyByHash), arg0, arg1)
+}
+
+// GetAPIKeys mocks base method.
+func (m *MockStorage) GetAPIKeys(arg0 context.Context, arg1 string) ([]models.APIKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAPIKeys", arg0, arg1)
+	ret0, _ := ret[0].([]models.APIKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAPIKeys indicates an expected call of GetAPIKeys.
+func (mr *MockStorageMockRecorder) GetAPIKeys(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAPIKeys", reflect.TypeOf((*MockStorage)(nil).GetAPIKeys), arg0, arg1)
+}
+
 // GetAll mocks base method.
-func (m *MockStorage) GetAll(arg0 context.Context, arg1 string) ([]models.URL, error) {
+func (m *MockStorage) GetAll(arg0 context.Context, arg1 string, arg2 models.URLFilter) ([]models.URL, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetAll", arg0, arg1)
+	ret := m.ctrl.Call(m, "GetAll", arg0, arg1, arg2)
 	ret0, _ := ret[0].([]models.URL)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetAll indicates an expected call of GetAll.
-func (mr *MockStorageMockRecorder) GetAll(arg0, arg1 interface{}) *gomock.Call {
+func (mr *MockStorageMockRecorder) GetAll(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAll", reflect.TypeOf((*MockStorage)(nil).GetAll), arg0, arg1, arg2)
+}
+
+// GetAuditEntries mocks base method.
+func (m *MockStorage) GetAuditEntries(arg0 context.Context, arg1 models.AuditFilter) ([]models.AuditEntry, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAuditEntries", arg0, arg1)
+	ret0, _ := ret[0].([]models.AuditEntry)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAuditEntries indicates an expected call of GetAuditEntries.
+func (mr *MockStorageMockRecorder) GetAuditEntries(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAuditEntries", reflect.TypeOf((*MockStorage)(nil).GetAuditEntries), arg0, arg1)
+}
+
+// GetDeliveries mocks base method.
+func (m *MockStorage) GetDeliveries(arg0 context.Context, arg1 string, arg2 int) ([]models.WebhookDelivery, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDeliveries", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]models.WebhookDelivery)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDeliveries indicates an expected call of GetDeliveries.
+func (mr *MockStorageMockRecorder) GetDeliveries(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDeliveries", reflect.TypeOf((*MockStorage)(nil).GetDeliveries), arg0, arg1, arg2)
+}
+
+// GetDomains mocks base method.
+func (m *MockStorage) GetDomains(arg0 context.Context, arg1 string) ([]models.Domain, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDomains", arg0, arg1)
+	ret0, _ := ret[0].([]models.Domain)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDomains indicates an expected call of GetDomains.
+func (mr *MockStorageMockRecorder) GetDomains(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAll", reflect.TypeOf((*MockStorage)(nil).GetAll), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDomains", reflect.TypeOf((*MockStorage)(nil).GetDomains), arg0, arg1)
+}
+
+// GetMember mocks base method.
+func (m *MockStorage) GetMember(arg0 context.Context, arg1, arg2 string) (models.WorkspaceMember, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetMember", arg0, arg1, arg2)
+	ret0, _ := ret[0].(models.WorkspaceMember)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetMember indicates an expected call of GetMember.
+func (mr *MockStorageMockRecorder) GetMember(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMember", reflect.TypeOf((*MockStorage)(nil).GetMember), arg0, arg1, arg2)
+}
+
+// GetMembers mocks base method.
+func (m *MockStorage) GetMembers(arg0 context.Context, arg1 string) ([]models.WorkspaceMember, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetMembers", arg0, arg1)
+	ret0, _ := ret[0].([]models.WorkspaceMember)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetMembers indicates an expected call of GetMembers.
+func (mr *MockStorageMockRecorder) GetMembers(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMembers", reflect.TypeOf((*MockStorage)(nil).GetMembers), arg0, arg1)
+}
+
+// GetPlan mocks base method.
+func (m *MockStorage) GetPlan(arg0 context.Context, arg1 string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPlan", arg0, arg1)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPlan indicates an expected call of GetPlan.
+func (mr *MockStorageMockRecorder) GetPlan(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPlan", reflect.TypeOf((*MockStorage)(nil).GetPlan), arg0, arg1)
 }
 
 // GetStats mocks base method.
@@ -110,6 +317,124 @@ func (mr *MockStorageMockRecorder) GetStats(arg0, arg1 interface{}) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetStats", reflect.TypeOf((*MockStorage)(nil).GetStats), arg0, arg1)
 }
 
+// GetURLHistory mocks base method.
+func (m *MockStorage) GetURLHistory(arg0 context.Context, arg1 string) ([]models.URLHistory, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetURLHistory", arg0, arg1)
+	ret0, _ := ret[0].([]models.URLHistory)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetURLHistory indicates an expected call of GetURLHistory.
+func (mr *MockStorageMockRecorder) GetURLHistory(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetURLHistory", reflect.TypeOf((*MockStorage)(nil).GetURLHistory), arg0, arg1)
+}
+
+// GetUsage mocks base method.
+func (m *MockStorage) GetUsage(arg0 context.Context, arg1, arg2 string) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUsage", arg0, arg1, arg2)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUsage indicates an expected call of GetUsage.
+func (mr *MockStorageMockRecorder) GetUsage(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUsage", reflect.TypeOf((*MockStorage)(nil).GetUsage), arg0, arg1, arg2)
+}
+
+// GetVerifiedDomain mocks base method.
+func (m *MockStorage) GetVerifiedDomain(arg0 context.Context, arg1 string) (models.Domain, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetVerifiedDomain", arg0, arg1)
+	ret0, _ := ret[0].(models.Domain)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetVerifiedDomain indicates an expected call of GetVerifiedDomain.
+func (mr *MockStorageMockRecorder) GetVerifiedDomain(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetVerifiedDomain", reflect.TypeOf((*MockStorage)(nil).GetVerifiedDomain), arg0, arg1)
+}
+
+// GetWebhook mocks base method.
+func (m *MockStorage) GetWebhook(arg0 context.Context, arg1 string) (models.Webhook, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWebhook", arg0, arg1)
+	ret0, _ := ret[0].(models.Webhook)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWebhook indicates an expected call of GetWebhook.
+func (mr *MockStorageMockRecorder) GetWebhook(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWebhook", reflect.TypeOf((*MockStorage)(nil).GetWebhook), arg0, arg1)
+}
+
+// GetWebhooks mocks base method.
+func (m *MockStorage) GetWebhooks(arg0 context.Context, arg1 string) ([]models.Webhook, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWebhooks", arg0, arg1)
+	ret0, _ := ret[0].([]models.Webhook)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWebhooks indicates an expected call of GetWebhooks.
+func (mr *MockStorageMockRecorder) GetWebhooks(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWebhooks", reflect.TypeOf((*MockStorage)(nil).GetWebhooks), arg0, arg1)
+}
+
+// GetWorkspaces mocks base method.
+func (m *MockStorage) GetWorkspaces(arg0 context.Context, arg1 string) ([]models.Workspace, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWorkspaces", arg0, arg1)
+	ret0, _ := ret[0].([]models.Workspace)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWorkspaces indicates an expected call of GetWorkspaces.
+func (mr *MockStorageMockRecorder) GetWorkspaces(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWorkspaces", reflect.TypeOf((*MockStorage)(nil).GetWorkspaces), arg0, arg1)
+}
+
+// IterateAll mocks base method.
+func (m *MockStorage) IterateAll(arg0 context.Context, arg1 string) storage.URLSeq {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IterateAll", arg0, arg1)
+	ret0, _ := ret[0].(storage.URLSeq)
+	return ret0
+}
+
+// IterateAll indicates an expected call of IterateAll.
+func (mr *MockStorageMockRecorder) IterateAll(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IterateAll", reflect.TypeOf((*MockStorage)(nil).IterateAll), arg0, arg1)
+}
+
+// PatchURL mocks base method.
+func (m *MockStorage) PatchURL(arg0 context.Context, arg1, arg2 string, arg3 models.URLPatch, arg4 time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PatchURL", arg0, arg1, arg2, arg3, arg4)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PatchURL indicates an expected call of PatchURL.
+func (mr *MockStorageMockRecorder) PatchURL(arg0, arg1, arg2, arg3, arg4 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PatchURL", reflect.TypeOf((*MockStorage)(nil).PatchURL), arg0, arg1, arg2, arg3, arg4)
+}
+
 // Ping mocks base method.
 func (m *MockStorage) Ping(arg0 context.Context) error {
 	m.ctrl.T.Helper()
@@ -124,6 +449,79 @@ func (mr *MockStorageMockRecorder) Ping(arg0 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Ping", reflect.TypeOf((*MockStorage)(nil).Ping), arg0)
 }
 
+// Purge mocks base method.
+func (m *MockStorage) Purge(arg0 context.Context, arg1 time.Time) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Purge", arg0, arg1)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Purge indicates an expected call of Purge.
+func (mr *MockStorageMockRecorder) Purge(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Purge", reflect.TypeOf((*MockStorage)(nil).Purge), arg0, arg1)
+}
+
+// PurgeAuditEntries mocks base method.
+func (m *MockStorage) PurgeAuditEntries(arg0 context.Context, arg1 time.Time) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PurgeAuditEntries", arg0, arg1)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PurgeAuditEntries indicates an expected call of PurgeAuditEntries.
+func (mr *MockStorageMockRecorder) PurgeAuditEntries(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PurgeAuditEntries", reflect.TypeOf((*MockStorage)(nil).PurgeAuditEntries), arg0, arg1)
+}
+
+// RecordFirstClick mocks base method.
+func (m *MockStorage) RecordFirstClick(arg0 context.Context, arg1 string, arg2 time.Time) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RecordFirstClick", arg0, arg1, arg2)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RecordFirstClick indicates an expected call of RecordFirstClick.
+func (mr *MockStorageMockRecorder) RecordFirstClick(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecordFirstClick", reflect.TypeOf((*MockStorage)(nil).RecordFirstClick), arg0, arg1, arg2)
+}
+
+// Restore mocks base method.
+func (m *MockStorage) Restore(arg0 context.Context, arg1, arg2 string, arg3 time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Restore", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Restore indicates an expected call of Restore.
+func (mr *MockStorageMockRecorder) Restore(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Restore", reflect.TypeOf((*MockStorage)(nil).Restore), arg0, arg1, arg2, arg3)
+}
+
+// RevokeAPIKey mocks base method.
+func (m *MockStorage) RevokeAPIKey(arg0 context.Context, arg1, arg2 string, arg3 time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeAPIKey", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeAPIKey indicates an expected call of RevokeAPIKey.
+func (mr *MockStorageMockRecorder) RevokeAPIKey(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeAPIKey", reflect.TypeOf((*MockStorage)(nil).RevokeAPIKey), arg0, arg1, arg2, arg3)
+}
+
 // Save mocks base method.
 func (m *MockStorage) Save(arg0 context.Context, arg1 models.URL) error {
 	m.ctrl.T.Helper()
@@ -138,20 +536,190 @@ func (mr *MockStorageMockRecorder) Save(arg0, arg1 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Save", reflect.TypeOf((*MockStorage)(nil).Save), arg0, arg1)
 }
 
-// SaveMany mocks base method.
-func (m *MockStorage) SaveMany(arg0 context.Context, arg1 []models.URL) error {
+// SaveAPIKey mocks base method.
+func (m *MockStorage) SaveAPIKey(arg0 context.Context, arg1 models.APIKey) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "SaveMany", arg0, arg1)
+	ret := m.ctrl.Call(m, "SaveAPIKey", arg0, arg1)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
+// SaveAPIKey indicates an expected call of SaveAPIKey.
+func (mr *MockStorageMockRecorder) SaveAPIKey(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveAPIKey", reflect.TypeOf((*MockStorage)(nil).SaveAPIKey), arg0, arg1)
+}
+
+// SaveAuditEntries mocks base method.
+func (m *MockStorage) SaveAuditEntries(arg0 context.Context, arg1 []models.AuditEntry) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveAuditEntries", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveAuditEntries indicates an expected call of SaveAuditEntries.
+func (mr *MockStorageMockRecorder) SaveAuditEntries(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveAuditEntries", reflect.TypeOf((*MockStorage)(nil).SaveAuditEntries), arg0, arg1)
+}
+
+// SaveDeliveries mocks base method.
+func (m *MockStorage) SaveDeliveries(arg0 context.Context, arg1 []models.WebhookDelivery) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveDeliveries", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveDeliveries indicates an expected call of SaveDeliveries.
+func (mr *MockStorageMockRecorder) SaveDeliveries(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveDeliveries", reflect.TypeOf((*MockStorage)(nil).SaveDeliveries), arg0, arg1)
+}
+
+// SaveDomain mocks base method.
+func (m *MockStorage) SaveDomain(arg0 context.Context, arg1 models.Domain) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveDomain", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveDomain indicates an expected call of SaveDomain.
+func (mr *MockStorageMockRecorder) SaveDomain(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveDomain", reflect.TypeOf((*MockStorage)(nil).SaveDomain), arg0, arg1)
+}
+
+// SaveMany mocks base method.
+func (m *MockStorage) SaveMany(arg0 context.Context, arg1 []models.URL) ([]error, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveMany", arg0, arg1)
+	ret0, _ := ret[0].([]error)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
 // SaveMany indicates an expected call of SaveMany.
 func (mr *MockStorageMockRecorder) SaveMany(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveMany", reflect.TypeOf((*MockStorage)(nil).SaveMany), arg0, arg1)
 }
 
+// SaveMember mocks base method.
+func (m *MockStorage) SaveMember(arg0 context.Context, arg1 models.WorkspaceMember) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveMember", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveMember indicates an expected call of SaveMember.
+func (mr *MockStorageMockRecorder) SaveMember(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveMember", reflect.TypeOf((*MockStorage)(nil).SaveMember), arg0, arg1)
+}
+
+// SaveWebhook mocks base method.
+func (m *MockStorage) SaveWebhook(arg0 context.Context, arg1 models.Webhook) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveWebhook", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveWebhook indicates an expected call of SaveWebhook.
+func (mr *MockStorageMockRecorder) SaveWebhook(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveWebhook", reflect.TypeOf((*MockStorage)(nil).SaveWebhook), arg0, arg1)
+}
+
+// Search mocks base method.
+func (m *MockStorage) Search(arg0 context.Context, arg1, arg2 string, arg3 int) ([]models.URL, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Search", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].([]models.URL)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Search indicates an expected call of Search.
+func (mr *MockStorageMockRecorder) Search(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Search", reflect.TypeOf((*MockStorage)(nil).Search), arg0, arg1, arg2, arg3)
+}
+
+// SetMetadata mocks base method.
+func (m *MockStorage) SetMetadata(arg0 context.Context, arg1, arg2 string, arg3 models.URLMetadata) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetMetadata", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetMetadata indicates an expected call of SetMetadata.
+func (mr *MockStorageMockRecorder) SetMetadata(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetMetadata", reflect.TypeOf((*MockStorage)(nil).SetMetadata), arg0, arg1, arg2, arg3)
+}
+
+// SetPlan mocks base method.
+func (m *MockStorage) SetPlan(arg0 context.Context, arg1, arg2 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetPlan", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetPlan indicates an expected call of SetPlan.
+func (mr *MockStorageMockRecorder) SetPlan(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetPlan", reflect.TypeOf((*MockStorage)(nil).SetPlan), arg0, arg1, arg2)
+}
+
+// TouchAPIKey mocks base method.
+func (m *MockStorage) TouchAPIKey(arg0 context.Context, arg1 string, arg2 time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TouchAPIKey", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// TouchAPIKey indicates an expected call of TouchAPIKey.
+func (mr *MockStorageMockRecorder) TouchAPIKey(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TouchAPIKey", reflect.TypeOf((*MockStorage)(nil).TouchAPIKey), arg0, arg1, arg2)
+}
+
+// UpdateDelivery mocks base method.
+func (m *MockStorage) UpdateDelivery(arg0 context.Context, arg1 models.WebhookDelivery) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateDelivery", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateDelivery indicates an expected call of UpdateDelivery.
+func (mr *MockStorageMockRecorder) UpdateDelivery(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateDelivery", reflect.TypeOf((*MockStorage)(nil).UpdateDelivery), arg0, arg1)
+}
+
+// VerifyDomain mocks base method.
+func (m *MockStorage) VerifyDomain(arg0 context.Context, arg1, arg2 string, arg3 time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "VerifyDomain", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// VerifyDomain indicates an expected call of VerifyDomain.
+func (mr *MockStorageMockRecorder) VerifyDomain(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VerifyDomain", reflect.TypeOf((*MockStorage)(nil).VerifyDomain), arg0, arg1, arg2, arg3)
+}
+
 // MockDB is a mock of DB interface.
 type MockDB struct {
 	ctrl     *gomock.Controller
@@ -254,6 +822,21 @@ func (mr *MockDBMockRecorder) NamedExecContext(arg0, arg1, arg2 interface{}) *go
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NamedExecContext", reflect.TypeOf((*MockDB)(nil).NamedExecContext), arg0, arg1, arg2)
 }
 
+// NamedQueryContext mocks base method.
+func (m *MockDB) NamedQueryContext(arg0 context.Context, arg1 string, arg2 interface{}) (*sqlx.Rows, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "NamedQueryContext", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*sqlx.Rows)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// NamedQueryContext indicates an expected call of NamedQueryContext.
+func (mr *MockDBMockRecorder) NamedQueryContext(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NamedQueryContext", reflect.TypeOf((*MockDB)(nil).NamedQueryContext), arg0, arg1, arg2)
+}
+
 // PingContext mocks base method.
 func (m *MockDB) PingContext(arg0 context.Context) error {
 	m.ctrl.T.Helper()
@@ -0,0 +1,85 @@
+package models
+
+import (
+	"database/sql/driver"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+)
+
+// API key scopes. A key without scopes has full access.
+const (
+	ScopeRead       = "read"       // listing the user's URLs
+	ScopeShorten    = "shorten"    // shortening URLs
+	ScopeDelete     = "delete"     // deleting the user's URLs
+	ScopeUpdate     = "update"     // changing destinations of the user's URLs
+	ScopeKeys       = "keys"       // managing API keys
+	ScopeWebhooks   = "webhooks"   // managing webhooks and reading their deliveries
+	ScopeDomains    = "domains"    // managing custom domains
+	ScopeWorkspaces = "workspaces" // managing workspaces and their members
+)
+
+// AllScopes lists every scope that can be granted to an API key.
+var AllScopes = Scopes{ScopeRead, ScopeShorten, ScopeDelete, ScopeUpdate, ScopeKeys, ScopeWebhooks, ScopeDomains, ScopeWorkspaces}
+
+// Scopes is a set of API key scopes.
+// It is stored in the database as a comma-separated string.
+type Scopes []string
+
+// Allows reports whether the scopes grant the given scope.
+// Empty scopes grant everything.
+func (s Scopes) Allows(scope string) bool {
+	return len(s) == 0 || slices.Contains(s, scope)
+}
+
+// Value implements the driver.Valuer interface.
+func (s Scopes) Value() (driver.Value, error) {
+	return strings.Join(s, ","), nil
+}
+
+// Scan implements the sql.Scanner interface.
+func (s *Scopes) Scan(src any) error {
+	var str string
+	switch v := src.(type) {
+	case nil:
+	case string:
+		str = v
+	case []byte:
+		str = string(v)
+	default:
+		return errors.New("unsupported type for scopes")
+	}
+	if str == "" {
+		*s = nil
+		return nil
+	}
+	*s = strings.Split(str, ",")
+	return nil
+}
+
+// APIKey represents an API key issued to a user for programmatic access.
+// Only a hash of the key is stored; the key itself is shown once on creation.
+type APIKey struct {
+	ID         string     `db:"id" json:"id"`
+	UserID     string     `db:"user_id" json:"user_id,omitempty"`
+	Name       string     `db:"name" json:"name"`
+	Prefix     string     `db:"prefix" json:"prefix"`
+	KeyHash    string     `db:"key_hash" json:"key_hash,omitempty"`
+	Scopes     Scopes     `db:"scopes" json:"scopes"`
+	CreatedAt  time.Time  `db:"created_at" json:"created_at"`
+	LastUsedAt *time.Time `db:"last_used_at" json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `db:"revoked_at" json:"revoked_at,omitempty"`
+}
+
+// CreateAPIKeyRequest represents a request to create an API key.
+type CreateAPIKeyRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+}
+
+// CreateAPIKeyResponse represents a newly created API key together with its plain-text value.
+type CreateAPIKeyResponse struct {
+	APIKey
+	Key string `json:"key"`
+}
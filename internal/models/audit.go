@@ -0,0 +1,63 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Actions recorded in the audit log.
+const (
+	AuditLinkCreated      = "link.created"
+	AuditLinkUpdated      = "link.updated" // the destination, redirect code or metadata changed
+	AuditLinkDeleted      = "link.deleted"
+	AuditLinkRestored     = "link.restored"
+	AuditKeyCreated       = "api_key.created"
+	AuditKeyRevoked       = "api_key.revoked"
+	AuditWebhookCreated   = "webhook.created"
+	AuditWebhookDeleted   = "webhook.deleted"
+	AuditDomainAdded      = "domain.added"
+	AuditDomainVerified   = "domain.verified"
+	AuditWorkspaceCreated = "workspace.created"
+	AuditMemberSet        = "workspace.member_set"     // a member was added or their role changed
+	AuditMemberRemoved    = "workspace.member_removed" // the target is "<workspace>/<user>"
+	AuditPlanSet          = "plan.set"
+)
+
+// Transports requests are made with.
+const (
+	TransportHTTP = "http"
+	TransportGRPC = "grpc"
+)
+
+// AuditEntry records a change a user made: who made it, from where, what changed and how.
+// Entries are never changed, only removed after the retention window.
+type AuditEntry struct {
+	ID        string          `db:"id" json:"id"`
+	Actor     string          `db:"actor" json:"actor"`             // the user who made the change
+	Action    string          `db:"action" json:"action"`           // one of the Audit* actions
+	Target    string          `db:"target" json:"target"`           // the ID of the link, a short code on the default domain, or of another resource
+	Before    json.RawMessage `db:"before" json:"before,omitempty"` // the resource before the change, a Link for links
+	After     json.RawMessage `db:"after" json:"after,omitempty"`   // the resource after the change, a Link for links
+	IP        string          `db:"ip" json:"ip"`
+	UserAgent string          `db:"user_agent" json:"user_agent"`
+	Transport string          `db:"transport" json:"transport"` // TransportHTTP or TransportGRPC, empty for changes made by the service itself
+	CreatedAt time.Time       `db:"created_at" json:"created_at"`
+}
+
+// AuditFilter selects audit entries. Empty fields match every entry.
+type AuditFilter struct {
+	Actor  string
+	Action string
+	Target string
+	Since  time.Time // entries made at or after it
+	Until  time.Time // entries made before it
+	Limit  int       // maximum number of entries, all if zero
+}
+
+// RequestInfo describes where a request changing resources comes from, for the audit log.
+type RequestInfo struct {
+	Actor     string // the authenticated user
+	IP        string
+	UserAgent string
+	Transport string // TransportHTTP or TransportGRPC
+}
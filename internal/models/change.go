@@ -0,0 +1,31 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// EventLinkUpdated is the change event of links whose destination, redirect code or metadata changed,
+// or that were restored. Links are created and deleted with EventLinkCreated and EventLinkDeleted.
+const EventLinkUpdated = "link.updated"
+
+// Change is a change of a link recorded in the outbox together with the change itself.
+type Change struct {
+	Sequence  int64           `db:"id"`         // increases with every change, in the order changes of a short code were made
+	Event     string          `db:"event"`      // EventLinkCreated, EventLinkUpdated or EventLinkDeleted
+	ShortURL  string          `db:"short_url"`  // the short code
+	UserID    string          `db:"user_id"`    // the owner of the link
+	Payload   json.RawMessage `db:"payload"`    // the link after the change, a URL without the password hash
+	CreatedAt time.Time       `db:"created_at"` // when the change was made
+}
+
+// ChangeEvent is a message of the change stream of links.
+// Consumers can drop messages with a Sequence they have already seen for the link,
+// since messages are delivered at least once.
+type ChangeEvent struct {
+	Sequence   int64     `json:"sequence"`
+	Event      string    `json:"event"`
+	UserID     string    `json:"user_id"`
+	OccurredAt time.Time `json:"occurred_at"`
+	Link       Link      `json:"link"`
+}
@@ -0,0 +1,85 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// DomainVerificationPrefix is prepended to the name of a domain to get the name of the DNS TXT record
+// proving its ownership, which must contain the verification token of the domain.
+const DomainVerificationPrefix = "_shortener."
+
+// Domain is a custom domain serving the short links of a user, such as go.brand-a.com.
+// Users may add any name, but only the one who proves ownership of the domain can verify it and shorten links on it.
+type Domain struct {
+	Name              string     `db:"name" json:"name"` // lowercase host name
+	UserID            string     `db:"user_id" json:"user_id,omitempty"`
+	VerificationToken string     `db:"verification_token" json:"verification_token"`
+	VerifiedAt        *time.Time `db:"verified_at" json:"verified_at,omitempty"` // nil until verified
+	CreatedAt         time.Time  `db:"created_at" json:"created_at"`
+}
+
+// Verified reports whether the ownership of the domain has been proven.
+func (d Domain) Verified() bool {
+	return d.VerifiedAt != nil
+}
+
+// VerificationRecord returns the name of the DNS TXT record proving the ownership of the domain.
+func (d Domain) VerificationRecord() string {
+	return DomainVerificationPrefix + d.Name
+}
+
+// CreateDomainRequest represents a request to add a custom domain.
+type CreateDomainRequest struct {
+	Name string `json:"name"`
+}
+
+// DomainSeparator separates the domain from the short code in the keys of links on custom domains.
+// Short codes never contain it.
+const DomainSeparator = ":"
+
+// LinkKey returns the key a link with the short code on the domain is stored under: the code itself on the default
+// domain, which has an empty name, and "<domain>:<code>" on custom ones. Keys make short codes unique per domain.
+// Keys are also the IDs of links in the API.
+func LinkKey(domain string, code string) string {
+	if domain == "" {
+		return code
+	}
+	return domain + DomainSeparator + code
+}
+
+// SplitLinkKey splits the key of a link into its domain, empty for the default one, and its short code.
+func SplitLinkKey(key string) (domain string, code string) {
+	if domain, code, ok := strings.Cut(key, DomainSeparator); ok {
+		return domain, code
+	}
+	return "", key
+}
+
+// FullShortURL returns the short URL of the link stored under key: under baseURL on the default domain,
+// and on the custom domain with the scheme of baseURL otherwise.
+func FullShortURL(baseURL string, key string) string {
+	domain, code := SplitLinkKey(key)
+	if domain == "" {
+		return baseURL + "/" + code
+	}
+	scheme, _, _ := strings.Cut(baseURL, "://")
+	return scheme + "://" + domain + "/" + code
+}
+
+// keyOf returns the key of the link with the full short URL made by FullShortURL with baseURL.
+// Anything else is returned unchanged.
+func keyOf(shortURL string, baseURL string) string {
+	if code, ok := strings.CutPrefix(shortURL, baseURL+"/"); ok {
+		return code
+	}
+	_, rest, ok := strings.Cut(shortURL, "://")
+	if !ok {
+		return shortURL
+	}
+	domain, code, ok := strings.Cut(rest, "/")
+	if !ok {
+		return shortURL
+	}
+	return LinkKey(domain, code)
+}
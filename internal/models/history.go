@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// URLHistory records a previous destination of a short URL, replaced at ChangedAt.
+type URLHistory struct {
+	ShortURL    string    `db:"short_url" json:"short_url"`
+	OriginalURL string    `db:"original_url" json:"original_url"`
+	ChangedAt   time.Time `db:"changed_at" json:"changed_at"`
+}
+
+// UpdateURLRequest represents a request to change the destination, redirect status code or metadata
+// of a short URL. Empty and missing fields are left unchanged.
+type UpdateURLRequest struct {
+	URL          string  `json:"url,omitempty"`
+	RedirectCode int     `json:"redirect_code,omitempty"`
+	Title        *string `json:"title,omitempty"`
+	Description  *string `json:"description,omitempty"`
+	Notes        *string `json:"notes,omitempty"`
+	Tags         *Tags   `json:"tags,omitempty"`
+}
+
+// URLPatch is a change of a short URL applied at once. Zero fields are left unchanged.
+type URLPatch struct {
+	OriginalURL  string       // the new destination; the previous one is recorded in the history of the URL
+	RedirectCode int          // the new redirect status code
+	Metadata     *URLMetadata // replaces the title, description, notes and tags
+}
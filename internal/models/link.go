@@ -0,0 +1,139 @@
+package models
+
+import "time"
+
+// Link is a short URL as returned by version 2 of the HTTP API. Unlike URL, it has a stable ID,
+// timestamps and its metadata grouped in one object, and it never exposes the owner or the password hash.
+type Link struct {
+	ID           string       `json:"id"` // the short code, prefixed with the domain and ':' on custom domains
+	ShortURL     string       `json:"short_url"`
+	Domain       string       `json:"domain,omitempty"` // the custom domain of the link, empty on the default one
+	OriginalURL  string       `json:"original_url"`
+	RedirectCode int          `json:"redirect_code,omitempty"` // the configured default if zero
+	Interstitial bool         `json:"interstitial"`
+	Metadata     LinkMetadata `json:"metadata"`
+	CreatedAt    time.Time    `json:"created_at,omitzero"`
+	ExpiresAt    *time.Time   `json:"expires_at,omitempty"`
+}
+
+// LinkMetadata is the metadata of a Link. Unlike URLMetadata, all fields are always present.
+type LinkMetadata struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Notes       string `json:"notes"`
+	Tags        Tags   `json:"tags"` // empty, never null
+}
+
+// NewLink converts a URL with a full short URL made by FullShortURL with baseURL to a Link.
+func NewLink(u URL, baseURL string) Link {
+	tags := u.Tags
+	if tags == nil {
+		tags = Tags{}
+	}
+	key := keyOf(u.ShortURL, baseURL)
+	domain, _ := SplitLinkKey(key)
+	return Link{
+		ID:           key,
+		ShortURL:     u.ShortURL,
+		Domain:       domain,
+		OriginalURL:  u.OriginalURL,
+		RedirectCode: u.RedirectCode,
+		Interstitial: u.Interstitial,
+		Metadata: LinkMetadata{
+			Title:       u.Title,
+			Description: u.Description,
+			Notes:       u.Notes,
+			Tags:        tags,
+		},
+		CreatedAt: u.CreatedAt,
+		ExpiresAt: u.ExpiresAt,
+	}
+}
+
+// NewLinks converts URLs with full short URLs made by FullShortURL with baseURL to links.
+func NewLinks(urls []URL, baseURL string) []Link {
+	links := make([]Link, len(urls))
+	for i, u := range urls {
+		links[i] = NewLink(u, baseURL)
+	}
+	return links
+}
+
+// LinkList is a list of links. Empty lists are returned as such rather than as 204 No Content.
+type LinkList struct {
+	Links []Link `json:"links"`
+	Count int    `json:"count"`
+}
+
+// NewLinkList converts URLs with full short URLs made by FullShortURL with baseURL to a LinkList.
+func NewLinkList(urls []URL, baseURL string) LinkList {
+	return LinkList{Links: NewLinks(urls, baseURL), Count: len(urls)}
+}
+
+// CreateLinkRequest represents a request to shorten a URL in version 2 of the HTTP API.
+type CreateLinkRequest struct {
+	URL          string      `json:"url"`
+	Password     string      `json:"password,omitempty"`      // optional passphrase required to follow the link
+	Interstitial bool        `json:"interstitial,omitempty"`  // show a preview page instead of redirecting
+	RedirectCode int         `json:"redirect_code,omitempty"` // 301, 302, 307 or 308; the configured default if zero
+	Metadata     URLMetadata `json:"metadata,omitzero"`
+	Domain       string      `json:"domain,omitempty"` // a verified custom domain of the user; the default one if empty
+}
+
+// LinkBatchResponse reports the status of every item of a batch shortened with version 2 of the HTTP API.
+type LinkBatchResponse struct {
+	Items []LinkBatchItem `json:"items"`
+}
+
+// LinkBatchItem is a BatchResponseItem with the ID of the link.
+type LinkBatchItem struct {
+	CorrelationID string `json:"correlation_id"`
+	ID            string `json:"id,omitempty"`
+	ShortURL      string `json:"short_url,omitempty"`
+	Status        string `json:"status"`
+	Error         string `json:"error,omitempty"`
+}
+
+// NewLinkBatchResponse converts a batch response with full short URLs made by FullShortURL with baseURL
+// to a LinkBatchResponse.
+func NewLinkBatchResponse(resp BatchResponse, baseURL string) LinkBatchResponse {
+	items := make([]LinkBatchItem, len(resp))
+	for i, item := range resp {
+		items[i] = LinkBatchItem{
+			CorrelationID: item.CorrelationID,
+			ShortURL:      item.ShortURL,
+			Status:        item.Status,
+			Error:         item.Error,
+		}
+		if item.ShortURL != "" {
+			items[i].ID = keyOf(item.ShortURL, baseURL)
+		}
+	}
+	return LinkBatchResponse{Items: items}
+}
+
+// DeleteLinksRequest represents a request to delete links by their IDs.
+type DeleteLinksRequest struct {
+	IDs []string `json:"ids"`
+}
+
+// LinkHistory lists the previous destinations of a link, oldest first.
+// Empty histories are returned as such rather than as 204 No Content.
+type LinkHistory struct {
+	History []LinkChange `json:"history"`
+}
+
+// LinkChange is a previous destination of a link, replaced at ChangedAt.
+type LinkChange struct {
+	OriginalURL string    `json:"original_url"`
+	ChangedAt   time.Time `json:"changed_at"`
+}
+
+// NewLinkHistory converts the history of a URL to a LinkHistory.
+func NewLinkHistory(history []URLHistory) LinkHistory {
+	changes := make([]LinkChange, len(history))
+	for i, h := range history {
+		changes[i] = LinkChange{OriginalURL: h.OriginalURL, ChangedAt: h.ChangedAt}
+	}
+	return LinkHistory{History: changes}
+}
@@ -0,0 +1,65 @@
+package models
+
+import (
+	"database/sql/driver"
+	"errors"
+	"slices"
+	"strings"
+)
+
+// URLMetadata helps users tell their short URLs apart.
+type URLMetadata struct {
+	Title       string `db:"title" json:"title,omitempty"`
+	Description string `db:"description" json:"description,omitempty"`
+	Notes       string `db:"notes" json:"notes,omitempty"` // free-form notes of the owner
+	Tags        Tags   `db:"tags" json:"tags,omitempty"`
+}
+
+// IsZero reports whether no metadata is set.
+func (m URLMetadata) IsZero() bool {
+	return m.Title == "" && m.Description == "" && m.Notes == "" && len(m.Tags) == 0
+}
+
+// URLFilter selects URLs when listing them.
+type URLFilter struct {
+	Tags []string // URLs must have all of these tags
+}
+
+// Tags is a set of tags of a short URL. Tags are lowercase and never contain commas.
+// It is stored in the database as a comma-separated string.
+type Tags []string
+
+// ContainsAll reports whether the set includes every given tag.
+func (t Tags) ContainsAll(tags []string) bool {
+	for _, tag := range tags {
+		if !slices.Contains(t, tag) {
+			return false
+		}
+	}
+	return true
+}
+
+// Value implements the driver.Valuer interface.
+func (t Tags) Value() (driver.Value, error) {
+	return strings.Join(t, ","), nil
+}
+
+// Scan implements the sql.Scanner interface.
+func (t *Tags) Scan(src any) error {
+	var str string
+	switch v := src.(type) {
+	case nil:
+	case string:
+		str = v
+	case []byte:
+		str = string(v)
+	default:
+		return errors.New("unsupported type for tags")
+	}
+	if str == "" {
+		*t = nil
+		return nil
+	}
+	*t = strings.Split(str, ",")
+	return nil
+}
@@ -3,9 +3,19 @@
 // of URLs and batch operations.
 package models
 
+import (
+	"net/http"
+	"time"
+)
+
 // ShortenRequest represents a request to shorten a URL.
 type ShortenRequest struct {
-	URL string `json:"url"`
+	URL          string `json:"url"`
+	Password     string `json:"password,omitempty"`      // optional passphrase required to follow the link
+	Interstitial bool   `json:"interstitial,omitempty"`  // show a preview page instead of redirecting
+	RedirectCode int    `json:"redirect_code,omitempty"` // 301, 302, 307 or 308; the configured default if zero
+	Domain       string `json:"domain,omitempty"`        // a verified custom domain of the user; the default one if empty
+	URLMetadata
 }
 
 // ShortenResponse represents a response containing the shortened URL.
@@ -25,19 +35,59 @@ type BatchRequestItem struct {
 // BatchResponse is a slice of BatchResponseItem for batch shortening responses.
 type BatchResponse []BatchResponseItem
 
+// Statuses of batch shorten response items.
+const (
+	BatchCreated = "created" // the link was saved
+	BatchExists  = "exists"  // the URL was already shortened
+	BatchInvalid = "invalid" // the URL is not a valid HTTP(S) URL
+)
+
 // BatchResponseItem represents a single item in a batch shorten response.
+// Invalid items have no short URL and an error explaining why.
 type BatchResponseItem struct {
 	CorrelationID string `json:"correlation_id"`
-	ShortURL      string `json:"short_url"`
+	ShortURL      string `json:"short_url,omitempty"`
+	Status        string `json:"status"`
+	Error         string `json:"error,omitempty"`
 }
 
 // URL represents a shortened URL mapping with metadata.
 type URL struct {
-	UUID        string `db:"id" json:"-"`
-	UserID      string `db:"user_id" json:"user_id"`
-	ShortURL    string `db:"short_url" json:"short_url"`
-	OriginalURL string `db:"original_url" json:"original_url"`
-	IsDeleted   bool   `db:"is_deleted" json:"-"`
+	UUID         string     `db:"id" json:"-"`
+	UserID       string     `db:"user_id" json:"user_id"`
+	ShortURL     string     `db:"short_url" json:"short_url"`
+	OriginalURL  string     `db:"original_url" json:"original_url"`
+	PasswordHash string     `db:"password_hash" json:"password_hash,omitempty"` // bcrypt hash of the passphrase, empty for public links
+	Interstitial bool       `db:"interstitial" json:"interstitial,omitempty"`   // show a preview page instead of redirecting
+	RedirectCode int        `db:"redirect_code" json:"redirect_code,omitempty"` // HTTP status of the redirect, the configured default if zero
+	IsDeleted    bool       `db:"is_deleted" json:"is_deleted,omitempty"`
+	DeletedAt    *time.Time `db:"deleted_at" json:"deleted_at,omitempty"` // when the URL was soft-deleted
+	ExpiresAt    *time.Time `db:"expires_at" json:"expires_at,omitempty"` // when the URL stops redirecting, never if nil
+	CreatedAt    time.Time  `db:"created_at" json:"created_at,omitzero"`  // when the URL was shortened, zero if unknown
+	ClickedAt    *time.Time `db:"clicked_at" json:"clicked_at,omitempty"` // when the URL was first followed, never if nil
+	URLMetadata
+}
+
+// Expired reports whether the URL has an expiry time that is not after now.
+func (u *URL) Expired(now time.Time) bool {
+	return u.ExpiresAt != nil && !u.ExpiresAt.After(now)
+}
+
+// ValidRedirectCode reports whether code is an HTTP status a short URL can redirect with:
+// 301, 302, 307 or 308.
+func ValidRedirectCode(code int) bool {
+	switch code {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	}
+	return false
+}
+
+// Preview describes where a short URL leads, for pages shown before following it.
+type Preview struct {
+	ShortURL    string `json:"short_url"`
+	OriginalURL string `json:"original_url"`
+	Title       string `json:"title"`
 }
 
 // Stats represents service statistics including the total number of shortened URLs and users.
@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// Plan is a tier of users limiting how many links they can have and create. A zero limit means no limit.
+type Plan struct {
+	Name            string `json:"name"`
+	MaxActiveLinks  int    `json:"max_active_links"`  // links that are not deleted
+	MaxMonthlyLinks int    `json:"max_monthly_links"` // links created in a calendar month, UTC
+}
+
+// Quota is the usage of a user, or of a workspace, against the limits of their plan.
+type Quota struct {
+	Plan            string    `json:"plan"`
+	ActiveLinks     int       `json:"active_links"`
+	MaxActiveLinks  int       `json:"max_active_links"` // zero means no limit
+	MonthlyLinks    int       `json:"monthly_links"`
+	MaxMonthlyLinks int       `json:"max_monthly_links"` // zero means no limit
+	ResetsAt        time.Time `json:"resets_at"`         // when the count of monthly links starts over
+}
+
+// SetPlanRequest represents a request to change the plan of a user.
+type SetPlanRequest struct {
+	Plan string `json:"plan"`
+}
+
+// UsagePeriod returns the period the links created at t are counted in: its calendar month in UTC, such as "2026-10".
+func UsagePeriod(t time.Time) string {
+	return t.UTC().Format("2006-01")
+}
@@ -0,0 +1,54 @@
+package models
+
+import "time"
+
+// Statuses of imported links.
+const (
+	ImportCreated  = "created"  // the link was saved
+	ImportExists   = "exists"   // the user already has the link
+	ImportConflict = "conflict" // the alias is taken by another link
+	ImportInvalid  = "invalid"  // the record is malformed or fails validation
+	ImportFailed   = "failed"   // the storage failed to save the link
+)
+
+// LinkRecord is a link in an import or export file.
+// Imports read the original URL, alias, tags and expiry; exports also write the short URL and title.
+type LinkRecord struct {
+	Row         int        `json:"-"` // position of the record in the imported file, starting at 1
+	OriginalURL string     `json:"original_url"`
+	Alias       string     `json:"alias,omitempty"` // short code to use instead of a generated one
+	Tags        Tags       `json:"tags,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	ShortURL    string     `json:"short_url,omitempty"`
+	Title       string     `json:"title,omitempty"`
+}
+
+// ImportResult reports what happened to a single imported record.
+type ImportResult struct {
+	Row      int    `json:"row"`
+	Status   string `json:"status"`
+	ShortURL string `json:"short_url,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// ImportReport summarizes an import and lists the result of every record.
+type ImportReport struct {
+	Created int            `json:"created"`
+	Skipped int            `json:"skipped"`         // existing links
+	Failed  int            `json:"failed"`          // conflicting, invalid and failed records
+	Error   string         `json:"error,omitempty"` // why the import stopped before the end of the file
+	Results []ImportResult `json:"results"`
+}
+
+// Add records the result of a record and updates the counters.
+func (r *ImportReport) Add(result ImportResult) {
+	switch result.Status {
+	case ImportCreated:
+		r.Created++
+	case ImportExists:
+		r.Skipped++
+	default:
+		r.Failed++
+	}
+	r.Results = append(r.Results, result)
+}
@@ -0,0 +1,85 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"slices"
+	"time"
+)
+
+// Webhook events.
+const (
+	EventLinkCreated      = "link.created"       // a link was shortened
+	EventLinkDeleted      = "link.deleted"       // a link was deleted
+	EventLinkFirstClicked = "link.first_clicked" // a link was followed for the first time
+)
+
+// AllEvents lists every event a webhook can subscribe to.
+var AllEvents = Events{EventLinkCreated, EventLinkDeleted, EventLinkFirstClicked}
+
+// Events is a set of webhook events.
+// It is stored in the database as a comma-separated string.
+type Events []string
+
+// Has reports whether the set contains the event.
+func (e Events) Has(event string) bool {
+	return slices.Contains(e, event)
+}
+
+// Value implements the driver.Valuer interface.
+func (e Events) Value() (driver.Value, error) {
+	return Scopes(e).Value()
+}
+
+// Scan implements the sql.Scanner interface.
+func (e *Events) Scan(src any) error {
+	return (*Scopes)(e).Scan(src)
+}
+
+// Webhook is a subscription of a user to events of their links, delivered as signed POST requests to its URL.
+// The secret signing the requests is shown once on creation.
+type Webhook struct {
+	ID        string    `db:"id" json:"id"`
+	UserID    string    `db:"user_id" json:"user_id,omitempty"`
+	URL       string    `db:"url" json:"url"`
+	Secret    string    `db:"secret" json:"secret,omitempty"`
+	Events    Events    `db:"events" json:"events"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
+
+// CreateWebhookRequest represents a request to create a webhook.
+type CreateWebhookRequest struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+}
+
+// Statuses of webhook deliveries.
+const (
+	DeliveryPending   = "pending"   // waiting for its first or next attempt
+	DeliveryDelivered = "delivered" // the endpoint answered with a 2xx status
+	DeliveryFailed    = "failed"    // all attempts failed
+)
+
+// WebhookDelivery is an event queued for, or sent to, a webhook, with the outcome of its last attempt.
+type WebhookDelivery struct {
+	ID             string          `db:"id" json:"id"`
+	WebhookID      string          `db:"webhook_id" json:"webhook_id"`
+	Event          string          `db:"event" json:"event"`
+	Payload        json.RawMessage `db:"payload" json:"payload"` // the WebhookEvent sent as the request body
+	Status         string          `db:"status" json:"status"`
+	Attempts       int             `db:"attempts" json:"attempts"`
+	ResponseStatus int             `db:"response_status" json:"response_status,omitempty"` // HTTP status of the last attempt, zero if it got no response
+	LastError      string          `db:"last_error" json:"last_error,omitempty"`
+	CreatedAt      time.Time       `db:"created_at" json:"created_at"`
+	NextAttemptAt  *time.Time      `db:"next_attempt_at" json:"next_attempt_at,omitempty"` // nil once delivered or failed
+	DeliveredAt    *time.Time      `db:"delivered_at" json:"delivered_at,omitempty"`
+}
+
+// WebhookEvent is the body of a webhook request. Its ID is the ID of the delivery,
+// so receivers can tell retries of a delivery apart from new events.
+type WebhookEvent struct {
+	ID        string    `json:"id"`
+	Event     string    `json:"event"`
+	CreatedAt time.Time `json:"created_at"`
+	Link      Link      `json:"link"`
+}
@@ -0,0 +1,57 @@
+package models
+
+import "time"
+
+// Roles of workspace members.
+const (
+	RoleOwner  = "owner"  // manages the links and the members of the workspace
+	RoleEditor = "editor" // manages the links of the workspace
+	RoleViewer = "viewer" // reads the links of the workspace
+)
+
+// roleScopes maps every role to the API key scopes of the operations on links it allows.
+var roleScopes = map[string]Scopes{
+	RoleOwner:  {ScopeRead, ScopeShorten, ScopeDelete, ScopeUpdate},
+	RoleEditor: {ScopeRead, ScopeShorten, ScopeDelete, ScopeUpdate},
+	RoleViewer: {ScopeRead},
+}
+
+// ValidRole reports whether role is a role of workspace members.
+func ValidRole(role string) bool {
+	_, ok := roleScopes[role]
+	return ok
+}
+
+// RoleAllows reports whether members with the role may perform the operations on links
+// that API keys need the scope for.
+func RoleAllows(role string, scope string) bool {
+	scopes, ok := roleScopes[role]
+	return ok && scopes.Allows(scope)
+}
+
+// Workspace is a team sharing links. The ID of a workspace is the owner of its links in place of a user ID,
+// so links belong to exactly one user or workspace.
+type Workspace struct {
+	ID        string    `db:"id" json:"id"`
+	Name      string    `db:"name" json:"name"`
+	Role      string    `db:"role" json:"role,omitempty"` // role of the user the workspace is listed for
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
+
+// WorkspaceMember is a user's membership in a workspace.
+type WorkspaceMember struct {
+	WorkspaceID string    `db:"workspace_id" json:"workspace_id"`
+	UserID      string    `db:"user_id" json:"user_id"`
+	Role        string    `db:"role" json:"role"`
+	AddedAt     time.Time `db:"added_at" json:"added_at"`
+}
+
+// CreateWorkspaceRequest represents a request to create a workspace.
+type CreateWorkspaceRequest struct {
+	Name string `json:"name"`
+}
+
+// SetMemberRequest represents a request to add a member to a workspace or change their role.
+type SetMemberRequest struct {
+	Role string `json:"role"`
+}
@@ -0,0 +1,164 @@
+// Package outbox publishes the change stream of links. DBStorage records every creation, update and deletion
+// of a link in an outbox table with the same statement as the change, so a change is recorded if and only if
+// it is committed. A Relay reads the outbox in order and publishes the changes to a Sink.
+//
+// Changes of a short code are published in the order they were made: only the relay holding the lease of
+// the outbox publishes, batch by batch, and a batch is removed from the outbox only once the sink accepted it.
+// Messages are delivered at least once, so consumers should drop those with a sequence number they have
+// already seen for the link.
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/grnsv/shortener/internal/logger"
+	"github.com/grnsv/shortener/internal/models"
+	"github.com/grnsv/shortener/internal/storage"
+)
+
+// Defaults of the Relay.
+const (
+	DefaultBatchSize    = 100
+	DefaultPollInterval = time.Second
+)
+
+// lease is how long a relay stays the only publisher of the outbox after every batch.
+// Publishing a batch must take less, or another relay may publish the next batches meanwhile.
+const lease = 30 * time.Second
+
+// Relay publishes the changes recorded in an outbox to a sink.
+type Relay struct {
+	store        storage.Outbox
+	sink         Sink
+	log          logger.Logger
+	baseURL      string
+	holder       string
+	batchSize    int
+	pollInterval time.Duration
+	now          func() time.Time
+}
+
+// Option is a function that configures a Relay.
+type Option func(*Relay)
+
+// WithBatchSize sets the maximum number of changes published at a time.
+func WithBatchSize(size int) Option {
+	return func(r *Relay) {
+		if size > 0 {
+			r.batchSize = size
+		}
+	}
+}
+
+// WithPollInterval sets how often the outbox is checked for changes.
+func WithPollInterval(interval time.Duration) Option {
+	return func(r *Relay) {
+		if interval > 0 {
+			r.pollInterval = interval
+		}
+	}
+}
+
+// NewRelay creates a Relay publishing the changes recorded in store to sink,
+// with the short URLs of links under baseURL.
+func NewRelay(store storage.Outbox, sink Sink, baseURL string, log logger.Logger, opts ...Option) *Relay {
+	r := &Relay{
+		store:        store,
+		sink:         sink,
+		log:          log,
+		baseURL:      baseURL,
+		holder:       uuid.NewString(),
+		batchSize:    DefaultBatchSize,
+		pollInterval: DefaultPollInterval,
+		now:          time.Now,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Run publishes recorded changes every poll interval until ctx is done.
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := r.PublishPending(ctx); err != nil && ctx.Err() == nil {
+			r.log.Errorf("Failed to publish changes: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// PublishPending publishes the recorded changes in batches, oldest first, and returns their number.
+// It publishes nothing while another relay holds the lease of the outbox. A batch the sink fails
+// to accept stays in the outbox and is published first next time.
+func (r *Relay) PublishPending(ctx context.Context) (int, error) {
+	var published int
+	for ctx.Err() == nil {
+		now := r.now()
+		leased, err := r.store.LeaseOutbox(ctx, r.holder, now, now.Add(lease))
+		if err != nil || !leased {
+			return published, err
+		}
+
+		changes, err := r.store.GetChanges(ctx, r.batchSize)
+		if err != nil {
+			return published, err
+		}
+		if len(changes) == 0 {
+			break
+		}
+
+		messages := make([]Message, len(changes))
+		sequences := make([]int64, len(changes))
+		for i, change := range changes {
+			if messages[i], err = r.encode(change); err != nil {
+				return published, fmt.Errorf("encode change %d: %w", change.Sequence, err)
+			}
+			sequences[i] = change.Sequence
+		}
+		if err = r.sink.Publish(ctx, messages); err != nil {
+			return published, err
+		}
+		if err = r.store.DeleteChanges(ctx, sequences); err != nil {
+			return published, err
+		}
+
+		published += len(changes)
+		if len(changes) < r.batchSize {
+			break
+		}
+	}
+	return published, nil
+}
+
+// encode converts a change to a message carrying a models.ChangeEvent.
+func (r *Relay) encode(change models.Change) (Message, error) {
+	var url models.URL
+	if err := json.Unmarshal(change.Payload, &url); err != nil {
+		return Message{}, err
+	}
+	url.ShortURL = models.FullShortURL(r.baseURL, url.ShortURL)
+
+	data, err := json.Marshal(models.ChangeEvent{
+		Sequence:   change.Sequence,
+		Event:      change.Event,
+		UserID:     change.UserID,
+		OccurredAt: change.CreatedAt,
+		Link:       models.NewLink(url, r.baseURL),
+	})
+	if err != nil {
+		return Message{}, err
+	}
+	return Message{Key: change.ShortURL, Event: change.Event, Data: data}, nil
+}
@@ -0,0 +1,241 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/grnsv/shortener/internal/logger"
+	"github.com/grnsv/shortener/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const baseURL = "http://localhost:8080"
+
+// fakeOutbox is an in-memory storage.Outbox.
+type fakeOutbox struct {
+	mu          sync.Mutex
+	changes     []models.Change
+	holder      string
+	leasedUntil time.Time
+}
+
+func (o *fakeOutbox) record(event string, short string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	payload, _ := json.Marshal(models.URL{UserID: "user", ShortURL: short, OriginalURL: "http://example.com/" + short})
+	o.changes = append(o.changes, models.Change{
+		Sequence:  int64(len(o.changes) + 1),
+		Event:     event,
+		ShortURL:  short,
+		UserID:    "user",
+		Payload:   payload,
+		CreatedAt: time.Now(),
+	})
+}
+
+func (o *fakeOutbox) LeaseOutbox(_ context.Context, holder string, now time.Time, leaseUntil time.Time) (bool, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.holder != holder && o.leasedUntil.After(now) {
+		return false, nil
+	}
+	o.holder, o.leasedUntil = holder, leaseUntil
+	return true, nil
+}
+
+func (o *fakeOutbox) GetChanges(_ context.Context, limit int) ([]models.Change, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return slices.Clone(o.changes[:min(limit, len(o.changes))]), nil
+}
+
+func (o *fakeOutbox) DeleteChanges(_ context.Context, sequences []int64) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.changes = slices.DeleteFunc(o.changes, func(c models.Change) bool {
+		return slices.Contains(sequences, c.Sequence)
+	})
+	return nil
+}
+
+func (o *fakeOutbox) len() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return len(o.changes)
+}
+
+func newRelay(t *testing.T, store *fakeOutbox, sink Sink, opts ...Option) *Relay {
+	t.Helper()
+	log, err := logger.New("testing")
+	require.NoError(t, err)
+	return NewRelay(store, sink, baseURL, log, opts...)
+}
+
+func decode(t *testing.T, m Message) models.ChangeEvent {
+	t.Helper()
+	var event models.ChangeEvent
+	require.NoError(t, json.Unmarshal(m.Data, &event))
+	return event
+}
+
+func TestPublishPendingInOrder(t *testing.T) {
+	store := &fakeOutbox{}
+	store.record(models.EventLinkCreated, "a")
+	store.record(models.EventLinkCreated, "b")
+	store.record(models.EventLinkUpdated, "a")
+	store.record(models.EventLinkDeleted, "a")
+	store.record(models.EventLinkDeleted, "b")
+	sink := NewMemorySink()
+
+	published, err := newRelay(t, store, sink, WithBatchSize(2)).PublishPending(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 5, published)
+	assert.Zero(t, store.len(), "published changes are removed from the outbox")
+
+	messages := sink.Messages()
+	require.Len(t, messages, 5)
+	for i, m := range messages {
+		event := decode(t, m)
+		assert.Equal(t, int64(i+1), event.Sequence)
+		assert.Equal(t, m.Event, event.Event)
+		assert.Equal(t, m.Key, event.Link.ID)
+		assert.Equal(t, baseURL+"/"+m.Key, event.Link.ShortURL)
+		assert.Equal(t, "user", event.UserID)
+	}
+	var keyA []string
+	for _, m := range messages {
+		if m.Key == "a" {
+			keyA = append(keyA, m.Event)
+		}
+	}
+	assert.Equal(t, []string{models.EventLinkCreated, models.EventLinkUpdated, models.EventLinkDeleted}, keyA)
+}
+
+func TestPublishPendingCustomDomain(t *testing.T) {
+	store := &fakeOutbox{}
+	store.record(models.EventLinkCreated, models.LinkKey("go.brand.com", "a"))
+	sink := NewMemorySink()
+
+	_, err := newRelay(t, store, sink).PublishPending(context.Background())
+	require.NoError(t, err)
+	messages := sink.Messages()
+	require.Len(t, messages, 1)
+	event := decode(t, messages[0])
+	assert.Equal(t, "go.brand.com:a", event.Link.ID)
+	assert.Equal(t, "go.brand.com", event.Link.Domain)
+	assert.Equal(t, "http://go.brand.com/a", event.Link.ShortURL)
+}
+
+// flakySink fails to publish the first time.
+type flakySink struct {
+	MemorySink
+	failed bool
+}
+
+func (s *flakySink) Publish(ctx context.Context, messages []Message) error {
+	if !s.failed {
+		s.failed = true
+		return errors.New("broker unavailable")
+	}
+	return s.MemorySink.Publish(ctx, messages)
+}
+
+func TestPublishPendingKeepsFailedBatches(t *testing.T) {
+	store := &fakeOutbox{}
+	store.record(models.EventLinkCreated, "a")
+	store.record(models.EventLinkUpdated, "a")
+	sink := &flakySink{}
+	relay := newRelay(t, store, sink)
+
+	_, err := relay.PublishPending(context.Background())
+	require.ErrorContains(t, err, "broker unavailable")
+	assert.Equal(t, 2, store.len())
+
+	store.record(models.EventLinkDeleted, "a")
+	published, err := relay.PublishPending(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 3, published)
+	var events []string
+	for _, m := range sink.Messages() {
+		events = append(events, m.Event)
+	}
+	assert.Equal(t, []string{models.EventLinkCreated, models.EventLinkUpdated, models.EventLinkDeleted}, events)
+}
+
+func TestPublishPendingRequiresLease(t *testing.T) {
+	store := &fakeOutbox{}
+	now := time.Now()
+	first := newRelay(t, store, NewMemorySink())
+	first.now = func() time.Time { return now }
+	_, err := first.PublishPending(context.Background())
+	require.NoError(t, err)
+
+	store.record(models.EventLinkCreated, "a")
+	sink := NewMemorySink()
+	second := newRelay(t, store, sink)
+	second.now = func() time.Time { return now }
+	published, err := second.PublishPending(context.Background())
+	require.NoError(t, err)
+	assert.Zero(t, published, "another relay holds the lease")
+
+	now = now.Add(lease)
+	published, err = second.PublishPending(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, published, "the lease expired")
+	assert.Len(t, sink.Messages(), 1)
+}
+
+// recordingPublisher records the keys of published messages.
+type recordingPublisher struct {
+	topics, keys []string
+	closed       bool
+}
+
+func (p *recordingPublisher) Publish(_ context.Context, topic string, key string, _ []byte) error {
+	p.topics = append(p.topics, topic)
+	p.keys = append(p.keys, key)
+	return nil
+}
+
+func (p *recordingPublisher) Close() error {
+	p.closed = true
+	return nil
+}
+
+func TestBrokerSink(t *testing.T) {
+	publisher := &recordingPublisher{}
+	sink := NewBrokerSink(publisher, "links")
+	require.NoError(t, sink.Publish(context.Background(), []Message{{Key: "a"}, {Key: "b"}}))
+	assert.Equal(t, []string{"links", "links"}, publisher.topics)
+	assert.Equal(t, []string{"a", "b"}, publisher.keys)
+	require.NoError(t, sink.Close())
+	assert.True(t, publisher.closed)
+}
+
+func TestOpen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	sink, err := Open(SinkFile + path)
+	require.NoError(t, err)
+	require.NoError(t, sink.Publish(context.Background(), []Message{{Data: []byte(`{"sequence":1}`)}, {Data: []byte(`{"sequence":2}`)}}))
+	require.NoError(t, sink.Close())
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "{\"sequence\":1}\n{\"sequence\":2}\n", string(data))
+
+	sink, err = Open(SinkStdout)
+	require.NoError(t, err)
+	assert.IsType(t, &WriterSink{}, sink)
+
+	_, err = Open("kafka://localhost:9092")
+	assert.ErrorContains(t, err, "unsupported event sink")
+	_, err = Open(SinkFile)
+	assert.Error(t, err)
+}
@@ -0,0 +1,186 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Message is a change event encoded for a sink.
+type Message struct {
+	Key   string // the ID of the changed link, see models.LinkKey; messages with the same key must stay in order
+	Event string // the name of the event, such as link.created
+	Data  []byte // the JSON-encoded models.ChangeEvent
+}
+
+// Sink receives the messages published by a Relay, oldest first.
+type Sink interface {
+	// Publish must return only once the messages are stored or acknowledged, since the relay
+	// then forgets them. If it fails, the same messages are published again.
+	Publish(ctx context.Context, messages []Message) error
+	// Close releases the resources of the sink.
+	Close() error
+}
+
+// Kinds of sinks Open accepts.
+const (
+	SinkStdout = "stdout"
+	SinkFile   = "file:"
+)
+
+// Open returns the sink described by spec: "stdout" writes messages to the standard output
+// and "file:" followed by a path appends them to the file, one JSON object per line.
+func Open(spec string) (Sink, error) {
+	switch {
+	case spec == SinkStdout:
+		return NewWriterSink(os.Stdout), nil
+	case strings.HasPrefix(spec, SinkFile) && len(spec) > len(SinkFile):
+		return OpenFileSink(strings.TrimPrefix(spec, SinkFile))
+	}
+	return nil, fmt.Errorf("unsupported event sink %q", spec)
+}
+
+// WriterSink writes messages to an io.Writer, one JSON object per line.
+type WriterSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewWriterSink creates a WriterSink writing to w, which it does not close.
+func NewWriterSink(w io.Writer) *WriterSink {
+	return &WriterSink{w: w}
+}
+
+// Publish writes the messages.
+func (s *WriterSink) Publish(_ context.Context, messages []Message) error {
+	var buf bytes.Buffer
+	for _, m := range messages {
+		buf.Write(m.Data)
+		buf.WriteByte('\n')
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := s.w.Write(buf.Bytes())
+	return err
+}
+
+// Close does nothing.
+func (s *WriterSink) Close() error {
+	return nil
+}
+
+// FileSink appends messages to a file, one JSON object per line, and syncs it after every batch.
+type FileSink struct {
+	WriterSink
+	file *os.File
+}
+
+// OpenFileSink opens or creates the file at path for a FileSink.
+func OpenFileSink(path string) (*FileSink, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &FileSink{WriterSink: WriterSink{w: file}, file: file}, nil
+}
+
+// Publish appends the messages to the file and syncs it.
+func (s *FileSink) Publish(ctx context.Context, messages []Message) error {
+	if err := s.WriterSink.Publish(ctx, messages); err != nil {
+		return err
+	}
+	return s.file.Sync()
+}
+
+// Close closes the file.
+func (s *FileSink) Close() error {
+	return s.file.Close()
+}
+
+// Publisher is the client of a message broker, such as NATS or Kafka, a BrokerSink publishes with.
+// Publish returns once the broker acknowledged the message. Messages with the same key must be delivered
+// in order: Kafka clients can use the key to choose the partition, and NATS clients can append it to the subject.
+type Publisher interface {
+	Publish(ctx context.Context, topic string, key string, data []byte) error
+}
+
+// BrokerSink publishes messages to a topic of a message broker.
+type BrokerSink struct {
+	publisher Publisher
+	topic     string
+	closer    func() error
+}
+
+// NewBrokerSink creates a BrokerSink publishing to the topic with publisher.
+// If the publisher is an io.Closer, closing the sink closes it.
+func NewBrokerSink(publisher Publisher, topic string) *BrokerSink {
+	s := &BrokerSink{publisher: publisher, topic: topic, closer: func() error { return nil }}
+	if c, ok := publisher.(io.Closer); ok {
+		s.closer = c.Close
+	}
+	return s
+}
+
+// Publish publishes the messages one by one, stopping at the first failure.
+func (s *BrokerSink) Publish(ctx context.Context, messages []Message) error {
+	for _, m := range messages {
+		if err := s.publisher.Publish(ctx, s.topic, m.Key, m.Data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close closes the publisher if it is an io.Closer.
+func (s *BrokerSink) Close() error {
+	return s.closer()
+}
+
+// ErrClosed is returned by MemorySink after it is closed.
+var ErrClosed = errors.New("sink closed")
+
+// MemorySink keeps published messages in memory. It is meant for tests.
+type MemorySink struct {
+	mu       sync.Mutex
+	messages []Message
+	closed   bool
+}
+
+// NewMemorySink creates an empty MemorySink.
+func NewMemorySink() *MemorySink {
+	return &MemorySink{}
+}
+
+// Publish appends the messages to the published ones.
+func (s *MemorySink) Publish(_ context.Context, messages []Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return ErrClosed
+	}
+	s.messages = append(s.messages, messages...)
+	return nil
+}
+
+// Messages returns the published messages in the order they were published.
+func (s *MemorySink) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.messages)
+}
+
+// Close makes further publishing fail.
+func (s *MemorySink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	return nil
+}
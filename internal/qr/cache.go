@@ -0,0 +1,82 @@
+package qr
+
+import (
+	"container/list"
+	"sync"
+)
+
+// Cache keeps the most recently rendered QR codes in memory.
+// It is safe for concurrent use.
+type Cache struct {
+	mu       sync.Mutex
+	capacity int
+	order    *list.List // most recently used first
+	items    map[string]*list.Element
+}
+
+type cacheEntry struct {
+	key   string
+	image *Image
+}
+
+// NewCache creates a Cache holding at most capacity images.
+func NewCache(capacity int) *Cache {
+	return &Cache{
+		capacity: capacity,
+		order:    list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+// Render returns the cached rendering of content with the options or renders and caches it.
+// The options must be normalized.
+func (c *Cache) Render(content string, o Options) (*Image, error) {
+	key := o.key(content)
+	if img, ok := c.get(key); ok {
+		return img, nil
+	}
+
+	img, err := Render(content, o)
+	if err != nil {
+		return nil, err
+	}
+	c.add(key, img)
+
+	return img, nil
+}
+
+func (c *Cache) get(key string) (*Image, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(elem)
+	return elem.Value.(*cacheEntry).image, true
+}
+
+func (c *Cache) add(key string, img *Image) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.items[key]; ok {
+		c.order.MoveToFront(elem)
+		return
+	}
+	c.items[key] = c.order.PushFront(&cacheEntry{key: key, image: img})
+	if c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+// Len returns the number of cached images.
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.order.Len()
+}
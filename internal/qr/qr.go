@@ -0,0 +1,165 @@
+// Package qr renders QR codes of short links as PNG or SVG images
+// using a pure-Go encoder, and caches rendered images.
+package qr
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"strconv"
+	"strings"
+
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// Supported image formats.
+const (
+	FormatPNG = "png"
+	FormatSVG = "svg"
+)
+
+// Limits and defaults of rendering options.
+const (
+	DefaultSize   = 256
+	MinSize       = 64
+	MaxSize       = 2048
+	DefaultMargin = 4
+	MaxMargin     = 16
+	DefaultLevel  = "M"
+)
+
+// ErrInvalidOptions is returned when rendering options are out of range.
+var ErrInvalidOptions = errors.New("invalid QR code options")
+
+var levels = map[string]qrcode.RecoveryLevel{
+	"L": qrcode.Low,
+	"M": qrcode.Medium,
+	"Q": qrcode.High,
+	"H": qrcode.Highest,
+}
+
+// Options control how a QR code is rendered. Zero Format, Size and Level select the defaults;
+// a zero Margin renders no quiet zone, so callers should start from DefaultMargin.
+type Options struct {
+	Format string // "png" or "svg"
+	Size   int    // width and height of the image in pixels
+	Level  string // error-correction level: L, M, Q or H
+	Margin int    // quiet zone around the code in modules
+}
+
+// Image is a rendered QR code.
+type Image struct {
+	Data        []byte
+	ContentType string
+}
+
+// Normalize fills in defaults and validates the options.
+func (o Options) Normalize() (Options, error) {
+	o.Format = strings.ToLower(o.Format)
+	if o.Format == "" {
+		o.Format = FormatPNG
+	}
+	if o.Size == 0 {
+		o.Size = DefaultSize
+	}
+	o.Level = strings.ToUpper(o.Level)
+	if o.Level == "" {
+		o.Level = DefaultLevel
+	}
+
+	if o.Format != FormatPNG && o.Format != FormatSVG {
+		return o, fmt.Errorf("%w: unsupported format %q", ErrInvalidOptions, o.Format)
+	}
+	if o.Size < MinSize || o.Size > MaxSize {
+		return o, fmt.Errorf("%w: size must be between %d and %d", ErrInvalidOptions, MinSize, MaxSize)
+	}
+	if _, ok := levels[o.Level]; !ok {
+		return o, fmt.Errorf("%w: unsupported error-correction level %q", ErrInvalidOptions, o.Level)
+	}
+	if o.Margin < 0 || o.Margin > MaxMargin {
+		return o, fmt.Errorf("%w: margin must be between 0 and %d", ErrInvalidOptions, MaxMargin)
+	}
+
+	return o, nil
+}
+
+// key identifies the rendering of content with normalized options.
+func (o Options) key(content string) string {
+	return strings.Join([]string{o.Format, strconv.Itoa(o.Size), o.Level, strconv.Itoa(o.Margin), content}, "|")
+}
+
+// Render encodes content as a QR code image. The options must be normalized.
+func Render(content string, o Options) (*Image, error) {
+	code, err := qrcode.New(content, levels[o.Level])
+	if err != nil {
+		return nil, err
+	}
+	code.DisableBorder = true
+	modules := withMargin(code.Bitmap(), o.Margin)
+
+	switch o.Format {
+	case FormatSVG:
+		return &Image{Data: renderSVG(modules, o.Size), ContentType: "image/svg+xml"}, nil
+	default:
+		data, err := renderPNG(modules, o.Size)
+		if err != nil {
+			return nil, err
+		}
+		return &Image{Data: data, ContentType: "image/png"}, nil
+	}
+}
+
+// withMargin surrounds the module matrix with a light quiet zone.
+func withMargin(bitmap [][]bool, margin int) [][]bool {
+	n := len(bitmap) + 2*margin
+	modules := make([][]bool, n)
+	for y := range modules {
+		modules[y] = make([]bool, n)
+		if y >= margin && y < n-margin {
+			copy(modules[y][margin:], bitmap[y-margin])
+		}
+	}
+	return modules
+}
+
+// renderPNG draws the modules onto a size×size image, scaling with nearest-neighbor sampling.
+// The image grows to one pixel per module if size is too small to fit the code.
+func renderPNG(modules [][]bool, size int) ([]byte, error) {
+	n := len(modules)
+	size = max(size, n)
+	img := image.NewPaletted(image.Rect(0, 0, size, size), color.Palette{color.White, color.Black})
+	for y := range size {
+		row := modules[y*n/size]
+		for x := range size {
+			if row[x*n/size] {
+				img.SetColorIndex(x, y, 1)
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// renderSVG draws the modules as a single path in a size×size SVG document.
+func renderSVG(modules [][]bool, size int) []byte {
+	n := len(modules)
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`, size, size, n, n)
+	fmt.Fprintf(&buf, `<rect width="%d" height="%d" fill="#fff"/><path fill="#000" d="`, n, n)
+	for y, row := range modules {
+		for x, dark := range row {
+			if dark {
+				fmt.Fprintf(&buf, "M%d %dh1v1h-1z", x, y)
+			}
+		}
+	}
+	buf.WriteString(`"/></svg>`)
+	return buf.Bytes()
+}
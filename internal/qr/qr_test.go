@@ -0,0 +1,111 @@
+package qr
+
+import (
+	"bytes"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const content = "http://localhost:8080/EwHXdJfB"
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    Options
+		want    Options
+		wantErr bool
+	}{
+		{
+			name: "defaults",
+			opts: Options{Margin: DefaultMargin},
+			want: Options{Format: FormatPNG, Size: DefaultSize, Level: DefaultLevel, Margin: DefaultMargin},
+		},
+		{
+			name: "case insensitive",
+			opts: Options{Format: "SVG", Size: 512, Level: "h"},
+			want: Options{Format: FormatSVG, Size: 512, Level: "H"},
+		},
+		{name: "unknown format", opts: Options{Format: "gif"}, wantErr: true},
+		{name: "too small", opts: Options{Size: MinSize - 1}, wantErr: true},
+		{name: "too large", opts: Options{Size: MaxSize + 1}, wantErr: true},
+		{name: "unknown level", opts: Options{Level: "X"}, wantErr: true},
+		{name: "negative margin", opts: Options{Margin: -1}, wantErr: true},
+		{name: "too wide margin", opts: Options{Margin: MaxMargin + 1}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.opts.Normalize()
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidOptions)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestRenderPNG(t *testing.T) {
+	opts, err := Options{Size: 300, Margin: 2}.Normalize()
+	require.NoError(t, err)
+
+	img, err := Render(content, opts)
+	require.NoError(t, err)
+	assert.Equal(t, "image/png", img.ContentType)
+
+	decoded, err := png.Decode(bytes.NewReader(img.Data))
+	require.NoError(t, err)
+	assert.Equal(t, 300, decoded.Bounds().Dx())
+	assert.Equal(t, 300, decoded.Bounds().Dy())
+
+	r, g, b, _ := decoded.At(0, 0).RGBA()
+	assert.Equal(t, uint32(0xffff), r&g&b, "quiet zone must be light")
+}
+
+func TestRenderSVG(t *testing.T) {
+	opts, err := Options{Format: FormatSVG, Margin: 0}.Normalize()
+	require.NoError(t, err)
+
+	img, err := Render(content, opts)
+	require.NoError(t, err)
+	assert.Equal(t, "image/svg+xml", img.ContentType)
+
+	svg := string(img.Data)
+	assert.True(t, strings.HasPrefix(svg, "<svg"))
+	assert.Contains(t, svg, `width="256" height="256"`)
+	assert.Contains(t, svg, "M0 0h1v1h-1z", "finder pattern must start at the corner without margin")
+}
+
+func TestRenderLevelsDiffer(t *testing.T) {
+	low, err := Render(content, Options{Format: FormatSVG, Size: DefaultSize, Level: "L"})
+	require.NoError(t, err)
+	high, err := Render(content, Options{Format: FormatSVG, Size: DefaultSize, Level: "H"})
+	require.NoError(t, err)
+	assert.NotEqual(t, low.Data, high.Data)
+}
+
+func TestCache(t *testing.T) {
+	cache := NewCache(2)
+	opts, err := Options{Margin: DefaultMargin}.Normalize()
+	require.NoError(t, err)
+
+	first, err := cache.Render(content+"1", opts)
+	require.NoError(t, err)
+	again, err := cache.Render(content+"1", opts)
+	require.NoError(t, err)
+	assert.Same(t, first, again)
+
+	_, err = cache.Render(content+"2", opts)
+	require.NoError(t, err)
+	_, err = cache.Render(content+"3", opts)
+	require.NoError(t, err)
+	assert.Equal(t, 2, cache.Len())
+
+	evicted, err := cache.Render(content+"1", opts)
+	require.NoError(t, err)
+	assert.NotSame(t, first, evicted)
+}
@@ -0,0 +1,151 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/grnsv/shortener/internal/models"
+	"github.com/grnsv/shortener/internal/storage"
+)
+
+const (
+	apiKeyPrefix       = "sk_"
+	apiKeyBytes        = 32
+	apiKeyPrefixLength = len(apiKeyPrefix) + 8
+)
+
+// API key error variables.
+var (
+	ErrUnsupported   = errors.New("operation is not supported by the storage")
+	ErrInvalidScope  = errors.New("invalid scope")
+	ErrInvalidAPIKey = errors.New("invalid API key")
+)
+
+// APIKeyManager provides methods for managing API keys of a user.
+type APIKeyManager interface {
+	CreateAPIKey(ctx context.Context, userID string, name string, scopes []string) (*models.CreateAPIKeyResponse, error)
+	GetAPIKeys(ctx context.Context, userID string) ([]models.APIKey, error)
+	RevokeAPIKey(ctx context.Context, userID string, id string) error
+}
+
+// APIKeyVerifier provides a method to authenticate a request by its API key.
+type APIKeyVerifier interface {
+	VerifyAPIKey(ctx context.Context, key string) (*models.APIKey, error)
+}
+
+// CreateAPIKey issues a new API key for the user. The plain-text key is only returned here.
+func (s *Service) CreateAPIKey(ctx context.Context, userID string, name string, scopes []string) (*models.CreateAPIKeyResponse, error) {
+	if s.keys == nil {
+		return nil, ErrUnsupported
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(models.AllScopes, scope) {
+			return nil, ErrInvalidScope
+		}
+	}
+
+	raw := make([]byte, apiKeyBytes)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, err
+	}
+	key := apiKeyPrefix + base64.RawURLEncoding.EncodeToString(raw)
+
+	model := models.APIKey{
+		ID:        uuid.NewString(),
+		UserID:    userID,
+		Name:      name,
+		Prefix:    key[:apiKeyPrefixLength],
+		KeyHash:   hashAPIKey(key),
+		Scopes:    slices.Compact(slices.Sorted(slices.Values(scopes))),
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := s.keys.SaveAPIKey(ctx, model); err != nil {
+		return nil, err
+	}
+	entry := models.AuditEntry{Action: models.AuditKeyCreated, Target: model.ID, After: auditValue(publicAPIKey(model))}
+	s.logError(s.record(ctx, userID, entry))
+
+	return &models.CreateAPIKeyResponse{APIKey: publicAPIKey(model), Key: key}, nil
+}
+
+// GetAPIKeys returns all API keys of the user without their hashes.
+func (s *Service) GetAPIKeys(ctx context.Context, userID string) ([]models.APIKey, error) {
+	if s.keys == nil {
+		return nil, ErrUnsupported
+	}
+	keys, err := s.keys.GetAPIKeys(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range keys {
+		keys[i] = publicAPIKey(keys[i])
+	}
+
+	return keys, nil
+}
+
+// RevokeAPIKey revokes an API key of the user.
+func (s *Service) RevokeAPIKey(ctx context.Context, userID string, id string) error {
+	if s.keys == nil {
+		return ErrUnsupported
+	}
+	if _, err := uuid.Parse(id); err != nil {
+		return storage.ErrNotFound
+	}
+	if err := s.keys.RevokeAPIKey(ctx, userID, id, time.Now().UTC()); err != nil {
+		return err
+	}
+	s.logError(s.record(ctx, userID, models.AuditEntry{Action: models.AuditKeyRevoked, Target: id}))
+	return nil
+}
+
+// VerifyAPIKey looks up an active API key by its plain-text value and records its usage.
+func (s *Service) VerifyAPIKey(ctx context.Context, key string) (*models.APIKey, error) {
+	if s.keys == nil {
+		return nil, ErrUnsupported
+	}
+	if !strings.HasPrefix(key, apiKeyPrefix) {
+		return nil, ErrInvalidAPIKey
+	}
+
+	model, err := s.keys.GetAPIKeyByHash(ctx, hashAPIKey(key))
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, ErrInvalidAPIKey
+		}
+		return nil, err
+	}
+	if model.RevokedAt != nil {
+		return nil, ErrInvalidAPIKey
+	}
+
+	now := time.Now().UTC()
+	if err = s.keys.TouchAPIKey(ctx, model.ID, now); err != nil {
+		return nil, err
+	}
+	model.LastUsedAt = &now
+
+	return &model, nil
+}
+
+// hashAPIKey returns the hex-encoded SHA-256 of the key.
+// API keys are random and long enough that a fast hash is sufficient.
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func publicAPIKey(key models.APIKey) models.APIKey {
+	key.UserID = ""
+	key.KeyHash = ""
+	return key
+}
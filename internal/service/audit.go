@@ -0,0 +1,126 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/grnsv/shortener/internal/models"
+	"github.com/grnsv/shortener/internal/storage"
+)
+
+const (
+	// DefaultAuditLimit is the number of audit entries returned if the filter sets no limit.
+	DefaultAuditLimit = 100
+	// MaxAuditLimit is the maximum number of audit entries returned at once.
+	MaxAuditLimit = 1000
+
+	defaultAuditRetention = 365 * 24 * time.Hour
+)
+
+// ErrInvalidAuditFilter is returned for audit filters with a limit out of range or a time range ending before it starts.
+var ErrInvalidAuditFilter = errors.New("invalid audit filter")
+
+// AuditLogger provides methods for the log of changes users make.
+type AuditLogger interface {
+	// GetAuditLog returns the audit entries matching the filter, newest first.
+	GetAuditLog(ctx context.Context, filter models.AuditFilter) ([]models.AuditEntry, error)
+	// PurgeAuditLog removes the entries older than the retention window and returns their number.
+	PurgeAuditLog(ctx context.Context) (int64, error)
+}
+
+// WithAuditLog enables the audit log: every change of links and other resources of users is recorded
+// in the storage, which keeps entries for the retention window.
+func WithAuditLog(audit storage.AuditStorage, retention time.Duration) Option {
+	return func(s *Service) {
+		s.audit = audit
+		s.auditRetention = defaultAuditRetention
+		if retention > 0 {
+			s.auditRetention = retention
+		}
+	}
+}
+
+// requestInfoContextKey is the context key of the RequestInfo of a request.
+type requestInfoContextKey struct{}
+
+// WithRequestInfo returns a copy of ctx telling where the request comes from. Changes made with it are recorded
+// in the audit log with the actor of info, if any, rather than the user passed to the service.
+func WithRequestInfo(ctx context.Context, info models.RequestInfo) context.Context {
+	return context.WithValue(ctx, requestInfoContextKey{}, info)
+}
+
+// RequestInfoFrom returns the RequestInfo set by WithRequestInfo, zero if there is none.
+func RequestInfoFrom(ctx context.Context) models.RequestInfo {
+	info, _ := ctx.Value(requestInfoContextKey{}).(models.RequestInfo)
+	return info
+}
+
+// record saves audit entries of changes made by the user, completed with the RequestInfo of ctx.
+func (s *Service) record(ctx context.Context, userID string, entries ...models.AuditEntry) error {
+	if s.audit == nil || len(entries) == 0 {
+		return nil
+	}
+	info := RequestInfoFrom(ctx)
+	if info.Actor != "" {
+		userID = info.Actor
+	}
+	now := time.Now().UTC()
+	for i := range entries {
+		entries[i].ID = uuid.NewString()
+		entries[i].Actor = userID
+		entries[i].IP = info.IP
+		entries[i].UserAgent = info.UserAgent
+		entries[i].Transport = info.Transport
+		entries[i].CreatedAt = now
+	}
+	return s.audit.SaveAuditEntries(ctx, entries)
+}
+
+// linkEntry returns an audit entry of a change of a link with the URLs before and after it, if any,
+// which have full short URLs.
+func (s *Service) linkEntry(action string, before *models.URL, after *models.URL) models.AuditEntry {
+	entry := models.AuditEntry{Action: action}
+	if before != nil {
+		link := models.NewLink(*before, s.BaseURL)
+		entry.Target, entry.Before = link.ID, auditValue(link)
+	}
+	if after != nil {
+		link := models.NewLink(*after, s.BaseURL)
+		entry.Target, entry.After = link.ID, auditValue(link)
+	}
+	return entry
+}
+
+// auditValue encodes a resource for an audit entry. Models always encode, so errors are not expected.
+func auditValue(v any) json.RawMessage {
+	value, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	return value
+}
+
+// GetAuditLog returns up to filter.Limit audit entries matching the filter, DefaultAuditLimit if it is zero.
+func (s *Service) GetAuditLog(ctx context.Context, filter models.AuditFilter) ([]models.AuditEntry, error) {
+	if s.audit == nil {
+		return nil, ErrUnsupported
+	}
+	if filter.Limit < 0 || filter.Limit > MaxAuditLimit || !filter.Until.IsZero() && filter.Until.Before(filter.Since) {
+		return nil, ErrInvalidAuditFilter
+	}
+	if filter.Limit == 0 {
+		filter.Limit = DefaultAuditLimit
+	}
+	return s.audit.GetAuditEntries(ctx, filter)
+}
+
+// PurgeAuditLog permanently removes audit entries older than the retention window and returns their number.
+func (s *Service) PurgeAuditLog(ctx context.Context) (int64, error) {
+	if s.audit == nil {
+		return 0, ErrUnsupported
+	}
+	return s.audit.PurgeAuditEntries(ctx, time.Now().Add(-s.auditRetention))
+}
@@ -0,0 +1,269 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/grnsv/shortener/internal/models"
+	"github.com/grnsv/shortener/internal/storage"
+)
+
+const (
+	// MaxDomains is the number of custom domains a user can add.
+	MaxDomains = 10
+
+	domainTokenBytes = 24
+	maxDomainLength  = 253
+	maxLabelLength   = 63
+)
+
+// Custom domain error variables.
+var (
+	ErrInvalidDomain            = errors.New("domain must be a host name with at least two labels")
+	ErrDomainNotVerified        = errors.New("domain is not a verified domain of the user")
+	ErrDomainVerificationFailed = errors.New("domain verification record not found")
+	ErrTooManyDomains           = errors.New("too many domains")
+)
+
+// DomainManager provides methods for managing the custom domains of a user.
+type DomainManager interface {
+	// AddDomain adds a domain to the user. The returned domain has the token its verification record must contain.
+	AddDomain(ctx context.Context, userID string, name string) (*models.Domain, error)
+	GetDomains(ctx context.Context, userID string) ([]models.Domain, error)
+	// VerifyDomain looks up the verification record of a domain of the user and marks the domain as verified
+	// if the record contains its token. Links can be shortened on verified domains only.
+	VerifyDomain(ctx context.Context, userID string, name string) (*models.Domain, error)
+}
+
+// TXTResolver looks up DNS TXT records. net.Resolver implements it.
+type TXTResolver interface {
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+}
+
+// WithDomains enables custom domains: the storage keeps them and the resolver looks up their verification records.
+func WithDomains(domains storage.DomainStorage, resolver TXTResolver) Option {
+	return func(s *Service) {
+		s.domains = domains
+		s.resolver = resolver
+	}
+}
+
+// WithDomain shortens the link on a custom domain of the user, which must be verified.
+// An empty domain leaves the link on the default one.
+func WithDomain(domain string) ShortenOption {
+	return func(url *models.URL) error {
+		if domain == "" {
+			return nil
+		}
+		name, err := normalizeDomain(domain)
+		if err != nil {
+			return err
+		}
+		// The ID is derived from the domain too, so the same URL gets a link on every domain.
+		id := uuid.NewSHA1(uuid.NameSpaceURL, []byte(name+"/"+url.OriginalURL))
+		url.UUID = id.String()
+		url.ShortURL = models.LinkKey(name, shortCode(id))
+		return nil
+	}
+}
+
+// hostContextKey is the context key of the host a short link is requested on.
+type hostContextKey struct{}
+
+// WithHost returns a copy of ctx telling that short links are requested on host, the Host header of the request.
+// Short codes are then looked up on the custom domain with that name, or on the default domain if there is none.
+// Without a host, as with the gRPC API, short URLs are the keys links are stored under, see models.LinkKey.
+func WithHost(ctx context.Context, host string) context.Context {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return context.WithValue(ctx, hostContextKey{}, strings.TrimSuffix(strings.ToLower(host), "."))
+}
+
+// requestHost returns the host set by WithHost and whether there is one.
+func requestHost(ctx context.Context) (string, bool) {
+	host, ok := ctx.Value(hostContextKey{}).(string)
+	return host, ok
+}
+
+// resolveKey returns the key of the link with the short code requested on the host set by WithHost.
+func (s *Service) resolveKey(ctx context.Context, code string) (string, error) {
+	host, ok := requestHost(ctx)
+	if !ok {
+		return code, nil
+	}
+	if strings.Contains(code, models.DomainSeparator) {
+		return "", storage.ErrNotFound
+	}
+	if s.domains == nil || host == s.baseHost() {
+		return code, nil
+	}
+	if _, err := s.domains.GetVerifiedDomain(ctx, host); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return code, nil
+		}
+		return "", err
+	}
+	return models.LinkKey(host, code), nil
+}
+
+// baseHost returns the host name of the base URL.
+func (s *Service) baseHost() string {
+	u, err := url.Parse(s.BaseURL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
+
+// shortURL returns the full short URL of the link stored under key.
+func (s *Service) shortURL(key string) string {
+	return models.FullShortURL(s.BaseURL, key)
+}
+
+// checkDomain checks that the domain of the link stored under key, if any, is a verified domain of the user.
+func (s *Service) checkDomain(ctx context.Context, userID string, key string) error {
+	name, _ := models.SplitLinkKey(key)
+	if name == "" {
+		return nil
+	}
+	if s.domains == nil {
+		return ErrUnsupported
+	}
+	domain, err := s.domains.GetVerifiedDomain(ctx, name)
+	if errors.Is(err, storage.ErrNotFound) || err == nil && domain.UserID != userID {
+		return ErrDomainNotVerified
+	}
+	return err
+}
+
+// AddDomain adds an unverified domain to the user with a random verification token.
+func (s *Service) AddDomain(ctx context.Context, userID string, name string) (*models.Domain, error) {
+	if s.domains == nil {
+		return nil, ErrUnsupported
+	}
+	name, err := normalizeDomain(name)
+	if err != nil {
+		return nil, err
+	}
+	if name == s.baseHost() {
+		return nil, ErrInvalidDomain
+	}
+
+	domains, err := s.domains.GetDomains(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(domains) >= MaxDomains {
+		return nil, ErrTooManyDomains
+	}
+
+	raw := make([]byte, domainTokenBytes)
+	if _, err = rand.Read(raw); err != nil {
+		return nil, err
+	}
+	domain := models.Domain{
+		Name:              name,
+		UserID:            userID,
+		VerificationToken: base64.RawURLEncoding.EncodeToString(raw),
+		CreatedAt:         time.Now().UTC(),
+	}
+	if err = s.domains.SaveDomain(ctx, domain); err != nil {
+		return nil, err
+	}
+
+	domain.UserID = ""
+	entry := models.AuditEntry{Action: models.AuditDomainAdded, Target: name, After: auditValue(domain)}
+	s.logError(s.record(ctx, userID, entry))
+	return &domain, nil
+}
+
+// GetDomains returns the domains added by the user, oldest first.
+func (s *Service) GetDomains(ctx context.Context, userID string) ([]models.Domain, error) {
+	if s.domains == nil {
+		return nil, ErrUnsupported
+	}
+	domains, err := s.domains.GetDomains(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range domains {
+		domains[i].UserID = ""
+	}
+	return domains, nil
+}
+
+// VerifyDomain verifies a domain of the user by its DNS TXT record. Verifying a verified domain does nothing.
+func (s *Service) VerifyDomain(ctx context.Context, userID string, name string) (*models.Domain, error) {
+	if s.domains == nil {
+		return nil, ErrUnsupported
+	}
+	name, err := normalizeDomain(name)
+	if err != nil {
+		return nil, err
+	}
+	domains, err := s.domains.GetDomains(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	i := slices.IndexFunc(domains, func(d models.Domain) bool { return d.Name == name })
+	if i < 0 {
+		return nil, storage.ErrNotFound
+	}
+	domain := domains[i]
+	domain.UserID = ""
+	if domain.Verified() {
+		return &domain, nil
+	}
+
+	records, err := s.resolver.LookupTXT(ctx, domain.VerificationRecord())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDomainVerificationFailed, err)
+	}
+	if !slices.Contains(records, domain.VerificationToken) {
+		return nil, ErrDomainVerificationFailed
+	}
+
+	now := time.Now().UTC()
+	if err = s.domains.VerifyDomain(ctx, userID, name, now); err != nil {
+		return nil, err
+	}
+	domain.VerifiedAt = &now
+	entry := models.AuditEntry{Action: models.AuditDomainVerified, Target: name, After: auditValue(domain)}
+	s.logError(s.record(ctx, userID, entry))
+	return &domain, nil
+}
+
+// normalizeDomain lowercases a host name and checks that it has at least two labels
+// of letters, digits and inner hyphens and is not an IP address.
+func normalizeDomain(name string) (string, error) {
+	name = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(name)), ".")
+	if len(name) > maxDomainLength || net.ParseIP(name) != nil {
+		return "", ErrInvalidDomain
+	}
+	labels := strings.Split(name, ".")
+	if len(labels) < 2 {
+		return "", ErrInvalidDomain
+	}
+	for _, label := range labels {
+		if label == "" || len(label) > maxLabelLength || label[0] == '-' || label[len(label)-1] == '-' {
+			return "", ErrInvalidDomain
+		}
+		for _, r := range label {
+			if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '-' {
+				return "", ErrInvalidDomain
+			}
+		}
+	}
+	return name, nil
+}
@@ -0,0 +1,31 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/grnsv/shortener/internal/models"
+	"github.com/grnsv/shortener/internal/storage"
+)
+
+// ErrExpired is returned when a short URL is followed after its expiry time.
+// It wraps storage.ErrDeleted, as an expired link is gone just like a deleted one.
+var ErrExpired = fmt.Errorf("%w: link expired", storage.ErrDeleted)
+
+// getLiveURL retrieves a short URL to be followed on the host set by WithHost, rejecting expired ones with ErrExpired.
+func (s *Service) getLiveURL(ctx context.Context, shortURL string) (models.URL, error) {
+	key, err := s.resolveKey(ctx, shortURL)
+	if err != nil {
+		return models.URL{}, err
+	}
+	url, err := s.retriever.Get(ctx, key)
+	if err != nil {
+		return models.URL{}, err
+	}
+	if url.Expired(time.Now()) {
+		return models.URL{}, ErrExpired
+	}
+
+	return url, nil
+}
@@ -0,0 +1,124 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/grnsv/shortener/internal/models"
+)
+
+const (
+	// IdempotencyKeyTTL is how long the response of a batch is returned for retries with the same key.
+	IdempotencyKeyTTL = 24 * time.Hour
+	// MaxIdempotencyKeyLength is the maximum length of an idempotency key.
+	MaxIdempotencyKeyLength = 255
+)
+
+// maxIdempotencyKeys is how many responses are kept. Batches with new keys are not cached once it is reached.
+const maxIdempotencyKeys = 10_000
+
+// Idempotency error variables.
+var (
+	ErrInvalidIdempotencyKey = errors.New("idempotency key must be up to 255 characters")
+	ErrIdempotencyKeyReused  = errors.New("idempotency key was used for a different request")
+)
+
+// idempotencyCache remembers the responses of batches by key, so retried batches get the original response
+// instead of being shortened again. Failed batches are forgotten, so they can be retried. A batch retried
+// while the first one is still running waits for its response.
+type idempotencyCache struct {
+	mu      sync.Mutex
+	entries map[string]*idempotentBatch
+	ttl     time.Duration
+	limit   int
+	now     func() time.Time
+}
+
+// idempotentBatch is a batch and its response once done is closed.
+type idempotentBatch struct {
+	fingerprint [sha256.Size]byte
+	done        chan struct{}
+	resp        models.BatchResponse
+	err         error
+	expiresAt   time.Time
+}
+
+func newIdempotencyCache(ttl time.Duration, limit int) *idempotencyCache {
+	return &idempotencyCache{
+		entries: make(map[string]*idempotentBatch),
+		ttl:     ttl,
+		limit:   limit,
+		now:     time.Now,
+	}
+}
+
+// do returns the response of the batch with the key, calling shorten if there is none.
+// Reusing a key for a different request yields ErrIdempotencyKeyReused.
+func (c *idempotencyCache) do(ctx context.Context, key string, req models.BatchRequest, shorten func() (models.BatchResponse, error)) (models.BatchResponse, error) {
+	fingerprint := fingerprintBatch(req)
+
+	c.mu.Lock()
+	now := c.now()
+	for k, b := range c.entries {
+		if isDone(b) && !now.Before(b.expiresAt) {
+			delete(c.entries, k)
+		}
+	}
+	if b, ok := c.entries[key]; ok {
+		c.mu.Unlock()
+		if b.fingerprint != fingerprint {
+			return nil, ErrIdempotencyKeyReused
+		}
+		select {
+		case <-b.done:
+			return b.resp, b.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	if len(c.entries) >= c.limit {
+		c.mu.Unlock()
+		return shorten()
+	}
+	b := &idempotentBatch{fingerprint: fingerprint, done: make(chan struct{})}
+	c.entries[key] = b
+	c.mu.Unlock()
+
+	b.resp, b.err = shorten()
+
+	c.mu.Lock()
+	b.expiresAt = c.now().Add(c.ttl)
+	if b.err != nil {
+		delete(c.entries, key)
+	}
+	close(b.done)
+	c.mu.Unlock()
+
+	return b.resp, b.err
+}
+
+func isDone(b *idempotentBatch) bool {
+	select {
+	case <-b.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// fingerprintBatch hashes the items of a batch to tell whether a key is reused for another batch.
+func fingerprintBatch(req models.BatchRequest) [sha256.Size]byte {
+	h := sha256.New()
+	for _, item := range req {
+		h.Write([]byte(item.CorrelationID))
+		h.Write([]byte{0})
+		h.Write([]byte(item.OriginalURL))
+		h.Write([]byte{0})
+	}
+	var sum [sha256.Size]byte
+	h.Sum(sum[:0])
+	return sum
+}
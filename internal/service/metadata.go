@@ -0,0 +1,159 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/grnsv/shortener/internal/models"
+)
+
+// Limits of URL metadata.
+const (
+	MaxTitleLength       = 512
+	MaxDescriptionLength = 2048
+	MaxNotesLength       = 4096
+	MaxTags              = 20
+	MaxTagLength         = 64
+)
+
+// fetchTimeout limits how long fetching the metadata of a destination page may take.
+const fetchTimeout = 10 * time.Second
+
+// ErrInvalidMetadata is returned when a title, description, notes or tags exceed their limits
+// or a tag contains a comma.
+var ErrInvalidMetadata = errors.New("invalid metadata")
+
+// MetadataFetcher retrieves the title and description of the page at a URL.
+type MetadataFetcher interface {
+	Fetch(ctx context.Context, url string) (models.URLMetadata, error)
+}
+
+// WithMetadataFetcher enables filling in the title and description of new short URLs
+// from their destination pages in the background. It requires an updater to store them.
+func WithMetadataFetcher(fetcher MetadataFetcher) Option {
+	return func(s *Service) {
+		s.fetcher = fetcher
+	}
+}
+
+// WithMetadata sets the title, description, notes and tags of the link.
+func WithMetadata(meta models.URLMetadata) ShortenOption {
+	return func(url *models.URL) error {
+		if err := normalizeMetadata(&meta); err != nil {
+			return err
+		}
+		url.URLMetadata = meta
+		return nil
+	}
+}
+
+// normalizeMetadata trims the metadata, normalizes its tags and checks the limits.
+func normalizeMetadata(meta *models.URLMetadata) error {
+	meta.Title = strings.TrimSpace(meta.Title)
+	meta.Description = strings.TrimSpace(meta.Description)
+	meta.Notes = strings.TrimSpace(meta.Notes)
+	if utf8.RuneCountInString(meta.Title) > MaxTitleLength ||
+		utf8.RuneCountInString(meta.Description) > MaxDescriptionLength ||
+		utf8.RuneCountInString(meta.Notes) > MaxNotesLength {
+		return ErrInvalidMetadata
+	}
+
+	tags, err := normalizeTags(meta.Tags)
+	if err != nil {
+		return err
+	}
+	meta.Tags = tags
+	return nil
+}
+
+// normalizeTags lowercases and trims tags, drops empty and duplicate ones and sorts them.
+func normalizeTags(tags []string) (models.Tags, error) {
+	var normalized models.Tags
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" {
+			continue
+		}
+		if strings.Contains(tag, ",") || utf8.RuneCountInString(tag) > MaxTagLength {
+			return nil, ErrInvalidMetadata
+		}
+		normalized = append(normalized, tag)
+	}
+	slices.Sort(normalized)
+	normalized = slices.Compact(normalized)
+	if len(normalized) > MaxTags {
+		return nil, ErrInvalidMetadata
+	}
+	return normalized, nil
+}
+
+// updateMetadata applies the metadata fields present in req to meta.
+// It reports whether req changes any metadata.
+func updateMetadata(meta *models.URLMetadata, req models.UpdateURLRequest) (bool, error) {
+	if req.Title == nil && req.Description == nil && req.Notes == nil && req.Tags == nil {
+		return false, nil
+	}
+
+	updated := *meta
+	if req.Title != nil {
+		updated.Title = *req.Title
+	}
+	if req.Description != nil {
+		updated.Description = *req.Description
+	}
+	if req.Notes != nil {
+		updated.Notes = *req.Notes
+	}
+	if req.Tags != nil {
+		updated.Tags = *req.Tags
+	}
+	if err := normalizeMetadata(&updated); err != nil {
+		return false, err
+	}
+
+	changed := updated.Title != meta.Title || updated.Description != meta.Description ||
+		updated.Notes != meta.Notes || !slices.Equal(updated.Tags, meta.Tags)
+	*meta = updated
+	return changed, nil
+}
+
+// fetchMetadata fills in the missing title and description of a new short URL from its destination page.
+// It is best-effort: the URL keeps its metadata if the page cannot be fetched or the URL has changed meanwhile.
+func (s *Service) fetchMetadata(userID string, shortURL string, url string) {
+	ctx, cancel := context.WithTimeout(context.Background(), fetchTimeout)
+	defer cancel()
+
+	page, err := s.fetcher.Fetch(ctx, url)
+	if err != nil {
+		return
+	}
+
+	model, err := s.getOwnURL(ctx, userID, shortURL)
+	if err != nil || model.OriginalURL != url {
+		return
+	}
+	meta := model.URLMetadata
+	if meta.Title == "" {
+		meta.Title = truncate(page.Title, MaxTitleLength)
+	}
+	if meta.Description == "" {
+		meta.Description = truncate(page.Description, MaxDescriptionLength)
+	}
+	if meta.Title == model.Title && meta.Description == model.Description {
+		return
+	}
+
+	_ = s.updater.SetMetadata(ctx, userID, shortURL, meta)
+}
+
+// truncate shortens s to at most n runes.
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
@@ -0,0 +1,185 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grnsv/shortener/internal/models"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// UnlockTTL is how long an unlock token stays valid after a successful unlock.
+	UnlockTTL = 10 * time.Minute
+	// UnlockWindow is the period in which failed unlock attempts are counted.
+	UnlockWindow = 15 * time.Minute
+	// MaxUnlockAttempts is the number of failed unlock attempts allowed per link and client within UnlockWindow.
+	MaxUnlockAttempts = 5
+)
+
+// Password protection error variables.
+var (
+	ErrPasswordRequired = errors.New("password required")
+	ErrInvalidPassword  = errors.New("invalid password")
+	ErrTooManyAttempts  = errors.New("too many unlock attempts")
+)
+
+// URLUnlocker provides methods to follow password-protected links.
+type URLUnlocker interface {
+	// UnlockURL checks the password of a protected link and returns its original URL
+	// together with a short-lived token that unlocks the link without the password.
+	// Failed attempts are throttled per host, link and client.
+	UnlockURL(ctx context.Context, shortURL string, password string, client string) (url string, token string, err error)
+	// ExpandUnlockedURL expands a protected link using a token issued by UnlockURL
+	// and returns its original URL and redirect status code, like ExpandURL.
+	ExpandUnlockedURL(ctx context.Context, shortURL string, token string) (url string, redirectCode int, err error)
+}
+
+// ShortenOption is a function that sets an optional property of a link being shortened.
+type ShortenOption func(*models.URL) error
+
+// WithPassword protects the link with the given password. An empty password leaves the link public.
+func WithPassword(password string) ShortenOption {
+	return func(url *models.URL) error {
+		if password == "" {
+			return nil
+		}
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+		if err != nil {
+			return err
+		}
+		url.PasswordHash = string(hash)
+		return nil
+	}
+}
+
+// UnlockURL checks the password of a protected link and returns its original URL and an unlock token.
+func (s *Service) UnlockURL(ctx context.Context, shortURL string, password string, client string) (string, string, error) {
+	host, _ := requestHost(ctx)
+	key := host + "/" + shortURL + "\x00" + client
+	if !s.attempts.allow(key) {
+		return "", "", ErrTooManyAttempts
+	}
+
+	url, err := s.getLiveURL(ctx, shortURL)
+	if err != nil {
+		return "", "", err
+	}
+	if url.PasswordHash == "" {
+		return url.OriginalURL, "", nil
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(url.PasswordHash), []byte(password)) != nil {
+		s.attempts.fail(key)
+		return "", "", ErrInvalidPassword
+	}
+	s.attempts.reset(key)
+
+	return url.OriginalURL, signUnlockToken(url, time.Now().Add(UnlockTTL)), nil
+}
+
+// ExpandUnlockedURL expands a protected link if the token is valid and not expired.
+func (s *Service) ExpandUnlockedURL(ctx context.Context, shortURL string, token string) (string, int, error) {
+	url, err := s.getLiveURL(ctx, shortURL)
+	if err != nil {
+		return "", 0, err
+	}
+	if url.PasswordHash != "" && !verifyUnlockToken(url, token, time.Now()) {
+		return "", 0, ErrPasswordRequired
+	}
+	s.recordClick(ctx, url)
+
+	return url.OriginalURL, url.RedirectCode, nil
+}
+
+// signUnlockToken returns "<expiry>.<mac>", where mac is an HMAC of the short URL and expiry
+// keyed by the password hash, so changing the password invalidates outstanding tokens.
+func signUnlockToken(url models.URL, expiresAt time.Time) string {
+	expiry := strconv.FormatInt(expiresAt.Unix(), 10)
+	return expiry + "." + unlockMAC(url, expiry)
+}
+
+func verifyUnlockToken(url models.URL, token string, now time.Time) bool {
+	expiry, mac, ok := strings.Cut(token, ".")
+	if !ok {
+		return false
+	}
+	unix, err := strconv.ParseInt(expiry, 10, 64)
+	if err != nil || now.Unix() >= unix {
+		return false
+	}
+	return hmac.Equal([]byte(mac), []byte(unlockMAC(url, expiry)))
+}
+
+func unlockMAC(url models.URL, expiry string) string {
+	mac := hmac.New(sha256.New, []byte(url.PasswordHash))
+	mac.Write([]byte(url.ShortURL + "." + expiry))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// attemptLimiter counts failed attempts per key in fixed windows.
+type attemptLimiter struct {
+	mu       sync.Mutex
+	attempts map[string]attemptWindow
+	limit    int
+	window   time.Duration
+	now      func() time.Time
+}
+
+type attemptWindow struct {
+	count   int
+	resetAt time.Time
+}
+
+func newAttemptLimiter(limit int, window time.Duration) *attemptLimiter {
+	return &attemptLimiter{
+		attempts: make(map[string]attemptWindow),
+		limit:    limit,
+		window:   window,
+		now:      time.Now,
+	}
+}
+
+// allow reports whether another attempt is permitted for the key.
+func (l *attemptLimiter) allow(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	a, ok := l.attempts[key]
+	return !ok || !l.now().Before(a.resetAt) || a.count < l.limit
+}
+
+// fail records a failed attempt for the key and drops expired windows.
+func (l *attemptLimiter) fail(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	for k, a := range l.attempts {
+		if !now.Before(a.resetAt) {
+			delete(l.attempts, k)
+		}
+	}
+
+	a, ok := l.attempts[key]
+	if !ok {
+		a.resetAt = now.Add(l.window)
+	}
+	a.count++
+	l.attempts[key] = a
+}
+
+// reset forgets the failed attempts for the key.
+func (l *attemptLimiter) reset(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.attempts, key)
+}
@@ -0,0 +1,56 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/url"
+
+	"github.com/grnsv/shortener/internal/models"
+)
+
+// ErrInterstitial is returned by ExpandURL for links that show a preview page instead of redirecting.
+var ErrInterstitial = errors.New("interstitial page required")
+
+// URLPreviewer provides a method to describe where a short URL leads without following it.
+type URLPreviewer interface {
+	// PreviewURL returns the destination of a short URL.
+	// Password-protected links are not previewed and yield ErrPasswordRequired.
+	PreviewURL(ctx context.Context, shortURL string) (*models.Preview, error)
+}
+
+// WithInterstitial makes the link show a preview page with its destination instead of redirecting.
+func WithInterstitial(interstitial bool) ShortenOption {
+	return func(url *models.URL) error {
+		url.Interstitial = interstitial
+		return nil
+	}
+}
+
+// PreviewURL returns the destination of a short URL and a title to show for it.
+func (s *Service) PreviewURL(ctx context.Context, shortURL string) (*models.Preview, error) {
+	model, err := s.getLiveURL(ctx, shortURL)
+	if err != nil {
+		return nil, err
+	}
+	if model.PasswordHash != "" {
+		return nil, ErrPasswordRequired
+	}
+
+	return &models.Preview{
+		ShortURL:    s.shortURL(model.ShortURL),
+		OriginalURL: model.OriginalURL,
+		Title:       previewTitle(model),
+	}, nil
+}
+
+// previewTitle names a link by its title, falling back to the host of its destination
+// and then to the destination itself.
+func previewTitle(model models.URL) string {
+	if model.Title != "" {
+		return model.Title
+	}
+	if u, err := url.Parse(model.OriginalURL); err == nil && u.Host != "" {
+		return u.Host
+	}
+	return model.OriginalURL
+}
@@ -0,0 +1,31 @@
+package service
+
+import (
+	"context"
+
+	"github.com/grnsv/shortener/internal/qr"
+)
+
+// qrCacheSize is how many rendered QR codes are kept in memory.
+const qrCacheSize = 1024
+
+// QRCodeGenerator provides a method to render a QR code of a short URL.
+type QRCodeGenerator interface {
+	GetQRCode(ctx context.Context, shortURL string, opts qr.Options) (*qr.Image, error)
+}
+
+// GetQRCode renders a QR code encoding the full short URL, including the base URL.
+// Rendered images are cached, as the encoded short URL never changes.
+func (s *Service) GetQRCode(ctx context.Context, shortURL string, opts qr.Options) (*qr.Image, error) {
+	opts, err := opts.Normalize()
+	if err != nil {
+		return nil, err
+	}
+
+	url, err := s.getLiveURL(ctx, shortURL)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.qrCodes.Render(s.shortURL(url.ShortURL), opts)
+}
@@ -0,0 +1,213 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/grnsv/shortener/internal/models"
+	"github.com/grnsv/shortener/internal/storage"
+)
+
+// Quota error variables.
+var (
+	ErrQuotaExceeded = errors.New("quota exceeded")
+	ErrUnknownPlan   = errors.New("unknown plan")
+	ErrInvalidUser   = errors.New("user ID must be a UUID")
+)
+
+// QuotaManager provides methods for the plans of users and their usage against them.
+type QuotaManager interface {
+	// GetQuota returns the usage of the user, or of the active workspace, against the limits of their plan.
+	GetQuota(ctx context.Context, userID string) (*models.Quota, error)
+	// SetPlan changes the plan of a user or a workspace.
+	SetPlan(ctx context.Context, userID string, plan string) error
+}
+
+// WithQuotas enables quotas: the storage keeps the plans of users and their usage, and users without a plan
+// of their own are on the default plan, which must be one of plans. The links of workspaces without a plan
+// of their own count against the plan of their first owner.
+func WithQuotas(quotas storage.QuotaStorage, plans []models.Plan, defaultPlan string) Option {
+	return func(s *Service) {
+		s.quotas = quotas
+		s.plans = make(map[string]models.Plan, len(plans))
+		for _, plan := range plans {
+			s.plans[plan.Name] = plan
+		}
+		s.defaultPlan = defaultPlan
+	}
+}
+
+// account returns whose plan and usage the links of the owner count against: the owner itself if it is a user
+// or a workspace with a plan of its own, and otherwise the first owner of the workspace, normally the user who
+// created it. Workspaces thus share the quota of their owner rather than each getting the default plan.
+func (s *Service) account(ctx context.Context, owner string) (string, error) {
+	_, err := s.quotas.GetPlan(ctx, owner)
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		return "", err
+	}
+	if err == nil || s.workspaces == nil {
+		return owner, nil
+	}
+	members, err := s.workspaces.GetMembers(ctx, owner)
+	if err != nil {
+		return "", err
+	}
+	for _, member := range members {
+		if member.Role == models.RoleOwner {
+			return member.UserID, nil
+		}
+	}
+	return owner, nil
+}
+
+// countActive returns the number of links that are not deleted of the account and of the workspaces billed to it.
+func (s *Service) countActive(ctx context.Context, account string) (int, error) {
+	active, err := s.quotas.CountActiveURLs(ctx, account)
+	if err != nil || s.workspaces == nil {
+		return active, err
+	}
+	workspaces, err := s.workspaces.GetWorkspaces(ctx, account)
+	if err != nil {
+		return 0, err
+	}
+	for _, workspace := range workspaces {
+		if workspace.Role != models.RoleOwner {
+			continue
+		}
+		billed, err := s.account(ctx, workspace.ID)
+		if err != nil {
+			return 0, err
+		}
+		if billed != account {
+			continue
+		}
+		links, err := s.quotas.CountActiveURLs(ctx, workspace.ID)
+		if err != nil {
+			return 0, err
+		}
+		active += links
+	}
+	return active, nil
+}
+
+// plan returns the plan of the owner of links. Owners on a plan that was removed are on the default plan.
+func (s *Service) plan(ctx context.Context, owner string) (models.Plan, error) {
+	name, err := s.quotas.GetPlan(ctx, owner)
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		return models.Plan{}, err
+	}
+	plan, ok := s.plans[name]
+	if !ok {
+		plan = s.plans[s.defaultPlan]
+	}
+	return plan, nil
+}
+
+// checkQuota returns ErrQuotaExceeded if creating n links would take the owner past a limit of their plan.
+// Links are counted by countUsage once created, so concurrent requests may exceed the limits by the links they create.
+func (s *Service) checkQuota(ctx context.Context, owner string, n int) error {
+	if s.quotas == nil || n == 0 {
+		return nil
+	}
+	account, err := s.account(ctx, owner)
+	if err != nil {
+		return err
+	}
+	plan, err := s.plan(ctx, account)
+	if err != nil {
+		return err
+	}
+
+	var active, monthly int
+	if plan.MaxActiveLinks > 0 {
+		if active, err = s.countActive(ctx, account); err != nil {
+			return err
+		}
+		if active+n > plan.MaxActiveLinks {
+			return fmt.Errorf("%w: the %s plan allows %d active links", ErrQuotaExceeded, plan.Name, plan.MaxActiveLinks)
+		}
+	}
+	if plan.MaxMonthlyLinks > 0 {
+		if monthly, err = s.quotas.GetUsage(ctx, account, models.UsagePeriod(time.Now())); err != nil {
+			return err
+		}
+		if monthly+n > plan.MaxMonthlyLinks {
+			return fmt.Errorf("%w: the %s plan allows %d new links a month", ErrQuotaExceeded, plan.Name, plan.MaxMonthlyLinks)
+		}
+	}
+	return nil
+}
+
+// countUsage adds n links created by the owner to the usage of their account in the current month.
+func (s *Service) countUsage(ctx context.Context, owner string, n int) error {
+	if s.quotas == nil || n == 0 {
+		return nil
+	}
+	account, err := s.account(ctx, owner)
+	if err != nil {
+		return err
+	}
+	return s.quotas.AddUsage(ctx, account, models.UsagePeriod(time.Now()), n)
+}
+
+// GetQuota returns the plan of the user, or of the active workspace, with its limits, the number of links
+// that are not deleted and the number of links created this month. A workspace without a plan of its own
+// reports the quota of its first owner, see account.
+func (s *Service) GetQuota(ctx context.Context, userID string) (*models.Quota, error) {
+	if s.quotas == nil {
+		return nil, ErrUnsupported
+	}
+	owner, err := s.LinkOwner(ctx, userID, models.ScopeRead)
+	if err != nil {
+		return nil, err
+	}
+	account, err := s.account(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+	plan, err := s.plan(ctx, account)
+	if err != nil {
+		return nil, err
+	}
+	active, err := s.countActive(ctx, account)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	monthly, err := s.quotas.GetUsage(ctx, account, models.UsagePeriod(now))
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.Quota{
+		Plan:            plan.Name,
+		ActiveLinks:     active,
+		MaxActiveLinks:  plan.MaxActiveLinks,
+		MonthlyLinks:    monthly,
+		MaxMonthlyLinks: plan.MaxMonthlyLinks,
+		ResetsAt:        time.Date(now.Year(), now.Month()+1, 1, 0, 0, 0, 0, time.UTC),
+	}, nil
+}
+
+// SetPlan puts a user, or a workspace by its ID, on one of the configured plans.
+func (s *Service) SetPlan(ctx context.Context, userID string, plan string) error {
+	if s.quotas == nil {
+		return ErrUnsupported
+	}
+	if uuid.Validate(userID) != nil {
+		return ErrInvalidUser
+	}
+	if _, ok := s.plans[plan]; !ok {
+		return ErrUnknownPlan
+	}
+	if err := s.quotas.SetPlan(ctx, userID, plan); err != nil {
+		return err
+	}
+	// Plans are set by administrators, so the actor is only known from the RequestInfo of ctx.
+	entry := models.AuditEntry{Action: models.AuditPlanSet, Target: userID, After: auditValue(models.SetPlanRequest{Plan: plan})}
+	s.logError(s.record(ctx, "", entry))
+	return nil
+}
@@ -0,0 +1,22 @@
+package service
+
+import (
+	"errors"
+
+	"github.com/grnsv/shortener/internal/models"
+)
+
+// ErrInvalidRedirectCode is returned for redirect status codes other than 301, 302, 307 and 308.
+var ErrInvalidRedirectCode = errors.New("invalid redirect code")
+
+// WithRedirectCode sets the HTTP status used to redirect to the link.
+// Zero leaves the choice to the configured default.
+func WithRedirectCode(code int) ShortenOption {
+	return func(url *models.URL) error {
+		if code != 0 && !models.ValidRedirectCode(code) {
+			return ErrInvalidRedirectCode
+		}
+		url.RedirectCode = code
+		return nil
+	}
+}
@@ -0,0 +1,48 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/grnsv/shortener/internal/models"
+)
+
+// defaultRetention is how long deleted URLs can be restored unless configured otherwise.
+const defaultRetention = 30 * 24 * time.Hour
+
+// URLRestorer provides methods to restore soft-deleted URLs and purge them after the retention window.
+type URLRestorer interface {
+	RestoreURL(ctx context.Context, userID string, shortURL string) error
+	PurgeDeleted(ctx context.Context) (int64, error)
+}
+
+// RestoreURL undeletes a short URL of the user, or of the active workspace, deleted within the retention window.
+func (s *Service) RestoreURL(ctx context.Context, userID string, shortURL string) error {
+	if s.restorer == nil {
+		return ErrUnsupported
+	}
+	owner, err := s.LinkOwner(ctx, userID, models.ScopeDelete)
+	if err != nil {
+		return err
+	}
+	if err = s.restorer.Restore(ctx, owner, shortURL, time.Now().Add(-s.retention)); err != nil || s.audit == nil {
+		return err
+	}
+	url, err := s.retriever.Get(ctx, shortURL)
+	if err != nil {
+		s.logError(err)
+		return nil
+	}
+	url.ShortURL = s.shortURL(url.ShortURL)
+	s.logError(s.record(ctx, userID, s.linkEntry(models.AuditLinkRestored, nil, &url)))
+	return nil
+}
+
+// PurgeDeleted permanently removes URLs deleted longer than the retention window ago
+// and returns their number.
+func (s *Service) PurgeDeleted(ctx context.Context) (int64, error) {
+	if s.restorer == nil {
+		return 0, ErrUnsupported
+	}
+	return s.restorer.Purge(ctx, time.Now().Add(-s.retention))
+}
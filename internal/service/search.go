@@ -0,0 +1,63 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/grnsv/shortener/internal/models"
+	"github.com/grnsv/shortener/internal/storage"
+)
+
+// Limits of URL searches.
+const (
+	DefaultSearchLimit   = 20
+	MaxSearchLimit       = 100
+	MaxSearchQueryLength = 256
+)
+
+// ErrInvalidQuery is returned when a search query is empty or too long, or the limit is out of range.
+var ErrInvalidQuery = errors.New("invalid search query")
+
+// URLSearcher provides a method to search the URLs of a user.
+type URLSearcher interface {
+	SearchURLs(ctx context.Context, userID string, query string, limit int) ([]models.URL, error)
+}
+
+// WithSearcher sets the storage used to search URLs.
+func WithSearcher(searcher storage.Searcher) Option {
+	return func(s *Service) {
+		s.searcher = searcher
+	}
+}
+
+// SearchURLs returns up to limit URLs of the user, DefaultSearchLimit if zero, whose short code,
+// destination, title or tags match the query, best matches first.
+func (s *Service) SearchURLs(ctx context.Context, userID string, query string, limit int) ([]models.URL, error) {
+	if s.searcher == nil {
+		return nil, ErrUnsupported
+	}
+	query = strings.TrimSpace(query)
+	if query == "" || utf8.RuneCountInString(query) > MaxSearchQueryLength || limit < 0 || limit > MaxSearchLimit {
+		return nil, ErrInvalidQuery
+	}
+	if limit == 0 {
+		limit = DefaultSearchLimit
+	}
+	owner, err := s.LinkOwner(ctx, userID, models.ScopeRead)
+	if err != nil {
+		return nil, err
+	}
+
+	urls, err := s.searcher.Search(ctx, owner, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	for i := range urls {
+		urls[i].ShortURL = s.shortURL(urls[i].ShortURL)
+		urls[i].PasswordHash = ""
+	}
+
+	return urls, nil
+}
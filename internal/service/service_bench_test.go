@@ -41,7 +41,7 @@ func BenchmarkService(b *testing.B) {
 
 	b.Run("ExpandURL", func(b *testing.B) {
 		for b.Loop() {
-			_, err := shortener.ExpandURL(context.Background(), "kv430TPx")
+			_, _, err := shortener.ExpandURL(context.Background(), "kv430TPx")
 			if err != nil {
 				b.Fatal(err)
 			}
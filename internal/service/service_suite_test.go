@@ -2,12 +2,17 @@ package service_test
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"net/http"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/golang/mock/gomock"
 	"github.com/grnsv/shortener/internal/mocks"
 	"github.com/grnsv/shortener/internal/models"
+	"github.com/grnsv/shortener/internal/qr"
 	"github.com/grnsv/shortener/internal/service"
 	"github.com/grnsv/shortener/internal/storage"
 	. "github.com/onsi/ginkgo/v2"
@@ -37,43 +42,106 @@ var _ = Describe("ShortenBatch", func() {
 		ctrl.Finish()
 	})
 
-	It("should shorten a batch of URLs", func() {
-		batchRequest := models.BatchRequest{
-			models.BatchRequestItem{
-				CorrelationID: "00000000-0000-0000-0000-000000000001",
-				OriginalURL:   "http://example.com/1",
-			},
-			models.BatchRequestItem{
-				CorrelationID: "00000000-0000-0000-0000-000000000002",
-				OriginalURL:   "http://example.com/2",
-			},
-		}
+	batchRequest := models.BatchRequest{
+		models.BatchRequestItem{
+			CorrelationID: "00000000-0000-0000-0000-000000000001",
+			OriginalURL:   "http://example.com/1",
+		},
+		models.BatchRequestItem{
+			CorrelationID: "00000000-0000-0000-0000-000000000002",
+			OriginalURL:   "http://example.com/2",
+		},
+	}
 
-		store.EXPECT().SaveMany(gomock.Any(), gomock.Len(2)).Return(nil)
+	It("should shorten a batch of URLs", func() {
+		store.EXPECT().SaveMany(gomock.Any(), gomock.Len(2)).Return([]error{nil, nil}, nil)
 
-		batchResponse, err := shortener.ShortenBatch(context.Background(), batchRequest, userID)
+		batchResponse, err := shortener.ShortenBatch(context.Background(), batchRequest, userID, "")
 		Expect(err).To(BeNil())
 		Expect(batchResponse).To(HaveLen(2))
 		Expect(batchResponse[0].CorrelationID).To(Equal("00000000-0000-0000-0000-000000000001"))
 		Expect(batchResponse[1].CorrelationID).To(Equal("00000000-0000-0000-0000-000000000002"))
+		Expect(batchResponse[0].Status).To(Equal(models.BatchCreated))
+		Expect(batchResponse[1].Status).To(Equal(models.BatchCreated))
+	})
+
+	It("should report invalid and existing URLs", func() {
+		request := append(models.BatchRequest{{CorrelationID: "bad", OriginalURL: "not a url"}}, batchRequest...)
+		var saved []models.URL
+		store.EXPECT().SaveMany(gomock.Any(), gomock.Len(2)).DoAndReturn(func(_ context.Context, urls []models.URL) ([]error, error) {
+			saved = urls
+			return []error{storage.ErrAlreadyExist, nil}, nil
+		})
+		store.EXPECT().Get(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, short string) (models.URL, error) {
+			Expect(short).To(Equal(saved[0].ShortURL))
+			return saved[0], nil
+		})
+
+		batchResponse, err := shortener.ShortenBatch(context.Background(), request, userID, "")
+		Expect(err).To(BeNil())
+		Expect(batchResponse).To(HaveLen(3))
+		Expect(batchResponse[0]).To(Equal(models.BatchResponseItem{CorrelationID: "bad", Status: models.BatchInvalid, Error: service.ErrInvalidURL.Error()}))
+		Expect(batchResponse[1].Status).To(Equal(models.BatchExists))
+		Expect(batchResponse[1].ShortURL).To(Equal("/" + saved[0].ShortURL))
+		Expect(batchResponse[2].Status).To(Equal(models.BatchCreated))
+	})
+
+	It("should use a random short URL if the deterministic one points elsewhere", func() {
+		var first string
+		store.EXPECT().SaveMany(gomock.Any(), gomock.Len(2)).DoAndReturn(func(_ context.Context, urls []models.URL) ([]error, error) {
+			first = urls[0].ShortURL
+			return []error{storage.ErrAlreadyExist, nil}, nil
+		})
+		store.EXPECT().Get(gomock.Any(), gomock.Any()).Return(models.URL{OriginalURL: "http://edited.com"}, nil)
+		store.EXPECT().SaveMany(gomock.Any(), gomock.Len(1)).Return([]error{nil}, nil)
+
+		batchResponse, err := shortener.ShortenBatch(context.Background(), batchRequest, userID, "")
+		Expect(err).To(BeNil())
+		Expect(batchResponse[0].Status).To(Equal(models.BatchCreated))
+		Expect(batchResponse[0].ShortURL).NotTo(Equal("/" + first))
+	})
+
+	It("should not save a batch of invalid URLs", func() {
+		batchResponse, err := shortener.ShortenBatch(context.Background(), models.BatchRequest{{OriginalURL: "ftp://example.com"}}, userID, "")
+		Expect(err).To(BeNil())
+		Expect(batchResponse[0].Status).To(Equal(models.BatchInvalid))
+	})
+
+	It("should return the original response to a batch retried with the same idempotency key", func() {
+		store.EXPECT().SaveMany(gomock.Any(), gomock.Len(2)).Return([]error{nil, nil}, nil)
+
+		first, err := shortener.ShortenBatch(context.Background(), batchRequest, userID, "key")
+		Expect(err).To(BeNil())
+		retried, err := shortener.ShortenBatch(context.Background(), batchRequest, userID, "key")
+		Expect(err).To(BeNil())
+		Expect(retried).To(Equal(first))
+
+		_, err = shortener.ShortenBatch(context.Background(), batchRequest[:1], userID, "key")
+		Expect(err).To(MatchError(service.ErrIdempotencyKeyReused))
+	})
+
+	It("should keep idempotency keys apart per user and forget failed batches", func() {
+		store.EXPECT().SaveMany(gomock.Any(), gomock.Len(2)).Return(nil, errors.New("storage error"))
+		store.EXPECT().SaveMany(gomock.Any(), gomock.Len(2)).Return([]error{nil, nil}, nil).Times(2)
+
+		_, err := shortener.ShortenBatch(context.Background(), batchRequest, userID, "key")
+		Expect(err).To(HaveOccurred())
+		_, err = shortener.ShortenBatch(context.Background(), batchRequest, userID, "key")
+		Expect(err).To(BeNil())
+		_, err = shortener.ShortenBatch(context.Background(), batchRequest, "other", "key")
+		Expect(err).To(BeNil())
+	})
+
+	It("should reject long idempotency keys", func() {
+		_, err := shortener.ShortenBatch(context.Background(), batchRequest, userID, strings.Repeat("k", service.MaxIdempotencyKeyLength+1))
+		Expect(err).To(MatchError(service.ErrInvalidIdempotencyKey))
 	})
 
 	When("storage fails", func() {
 		It("returns an error", func() {
-			batchRequest := models.BatchRequest{
-				models.BatchRequestItem{
-					CorrelationID: "00000000-0000-0000-0000-000000000001",
-					OriginalURL:   "http://example.com/1",
-				},
-				models.BatchRequestItem{
-					CorrelationID: "00000000-0000-0000-0000-000000000002",
-					OriginalURL:   "http://example.com/2",
-				},
-			}
+			store.EXPECT().SaveMany(gomock.Any(), gomock.Len(2)).Return(nil, errors.New("storage error"))
 
-			store.EXPECT().SaveMany(gomock.Any(), gomock.Len(2)).Return(errors.New("storage error"))
-
-			batchResponse, err := shortener.ShortenBatch(context.Background(), batchRequest, userID)
+			batchResponse, err := shortener.ShortenBatch(context.Background(), batchRequest, userID, "")
 			Expect(err).To(HaveOccurred())
 			Expect(batchResponse).To(BeNil())
 		})
@@ -108,11 +176,126 @@ var _ = Describe("ShortenURL", func() {
 
 	It("should return existing short URL if already exists", func() {
 		store.EXPECT().Save(gomock.Any(), gomock.Any()).Return(storage.ErrAlreadyExist)
+		store.EXPECT().Get(gomock.Any(), gomock.Any()).Return(models.URL{OriginalURL: "http://example.com/1"}, nil)
 		shortURL, alreadyExists, err := shortener.ShortenURL(context.Background(), "http://example.com/1", userID)
 		Expect(err).To(BeNil())
 		Expect(alreadyExists).To(BeTrue())
 		Expect(shortURL).To(HavePrefix("http://short/"))
 	})
+
+	It("should reject URLs other than absolute http and https ones without saving them", func() {
+		for _, url := range []string{"", "example.com", "/relative", "ftp://example.com", "javascript:alert(1)", "http://"} {
+			_, _, err := shortener.ShortenURL(context.Background(), url, userID)
+			Expect(err).To(MatchError(service.ErrInvalidURL), url)
+		}
+	})
+
+	It("should return the new link without the password hash", func() {
+		var saved models.URL
+		store.EXPECT().Save(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, model models.URL) error {
+			saved = model
+			return nil
+		})
+		link, alreadyExists, err := shortener.Shorten(context.Background(), "http://example.com/1", userID, service.WithPassword("secret"))
+		Expect(err).To(BeNil())
+		Expect(alreadyExists).To(BeFalse())
+		Expect(saved.PasswordHash).NotTo(BeEmpty())
+		Expect(saved.CreatedAt).NotTo(BeZero())
+		Expect(link.ShortURL).To(Equal("http://short/" + saved.ShortURL))
+		Expect(link.PasswordHash).To(BeEmpty())
+		Expect(link.CreatedAt).To(Equal(saved.CreatedAt))
+	})
+})
+
+var _ = Describe("UpdateURL", func() {
+	const userID = "ffffffff-ffff-ffff-ffff-ffffffffffff"
+	var (
+		ctrl      *gomock.Controller
+		store     *mocks.MockStorage
+		shortener service.Shortener
+	)
+
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		store = mocks.NewMockStorage(ctrl)
+		shortener = service.NewShortener(store, store, store, store, "http://short", service.WithUpdater(store))
+	})
+
+	AfterEach(func() {
+		ctrl.Finish()
+	})
+
+	It("should change the destination of an own URL", func() {
+		store.EXPECT().Get(gomock.Any(), "short1").Return(models.URL{UserID: userID, ShortURL: "short1", OriginalURL: "http://example.com/typo"}, nil)
+		store.EXPECT().PatchURL(gomock.Any(), userID, "short1", models.URLPatch{OriginalURL: "http://example.com/fixed"}, gomock.Any()).Return(nil)
+		url, err := shortener.UpdateURL(context.Background(), userID, "short1", models.UpdateURLRequest{URL: "http://example.com/fixed"})
+		Expect(err).To(BeNil())
+		Expect(url.ShortURL).To(Equal("http://short/short1"))
+		Expect(url.OriginalURL).To(Equal("http://example.com/fixed"))
+	})
+
+	It("should apply every change of a request at once or fail without changing anything", func() {
+		title := "Fixed"
+		store.EXPECT().Get(gomock.Any(), "short1").Return(models.URL{UserID: userID, ShortURL: "short1", OriginalURL: "http://example.com/typo"}, nil).Times(2)
+		patch := models.URLPatch{
+			OriginalURL:  "http://example.com/fixed",
+			RedirectCode: http.StatusMovedPermanently,
+			Metadata:     &models.URLMetadata{Title: title},
+		}
+		req := models.UpdateURLRequest{URL: "http://example.com/fixed", RedirectCode: http.StatusMovedPermanently, Title: &title}
+		store.EXPECT().PatchURL(gomock.Any(), userID, "short1", patch, gomock.Any()).Return(errors.New("connection lost"))
+		_, err := shortener.UpdateURL(context.Background(), userID, "short1", req)
+		Expect(err).To(MatchError("connection lost"))
+
+		store.EXPECT().PatchURL(gomock.Any(), userID, "short1", patch, gomock.Any()).Return(nil)
+		url, err := shortener.UpdateURL(context.Background(), userID, "short1", req)
+		Expect(err).To(BeNil())
+		Expect(url.OriginalURL).To(Equal("http://example.com/fixed"))
+		Expect(url.RedirectCode).To(Equal(http.StatusMovedPermanently))
+		Expect(url.Title).To(Equal(title))
+	})
+
+	It("should reject an invalid destination", func() {
+		_, err := shortener.UpdateURL(context.Background(), userID, "short1", models.UpdateURLRequest{URL: "example.com/no-scheme"})
+		Expect(err).To(MatchError(service.ErrInvalidURL))
+	})
+
+	It("should change only the redirect code", func() {
+		store.EXPECT().Get(gomock.Any(), "short1").Return(models.URL{UserID: userID, ShortURL: "short1", OriginalURL: "http://example.com/"}, nil)
+		store.EXPECT().PatchURL(gomock.Any(), userID, "short1", models.URLPatch{RedirectCode: http.StatusMovedPermanently}, gomock.Any()).Return(nil)
+		url, err := shortener.UpdateURL(context.Background(), userID, "short1", models.UpdateURLRequest{RedirectCode: http.StatusMovedPermanently})
+		Expect(err).To(BeNil())
+		Expect(url.OriginalURL).To(Equal("http://example.com/"))
+		Expect(url.RedirectCode).To(Equal(http.StatusMovedPermanently))
+	})
+
+	It("should reject unsupported redirect codes", func() {
+		_, err := shortener.UpdateURL(context.Background(), userID, "short1", models.UpdateURLRequest{RedirectCode: http.StatusOK})
+		Expect(err).To(MatchError(service.ErrInvalidRedirectCode))
+		_, _, err = shortener.ShortenURL(context.Background(), "http://example.com/", userID, service.WithRedirectCode(http.StatusNotModified))
+		Expect(err).To(MatchError(service.ErrInvalidRedirectCode))
+	})
+
+	It("should reject an empty update", func() {
+		_, err := shortener.UpdateURL(context.Background(), userID, "short1", models.UpdateURLRequest{})
+		Expect(err).To(MatchError(service.ErrInvalidURL))
+	})
+
+	It("should not change a URL of another user", func() {
+		store.EXPECT().Get(gomock.Any(), "short1").Return(models.URL{UserID: "someone-else", ShortURL: "short1"}, nil)
+		_, err := shortener.UpdateURL(context.Background(), userID, "short1", models.UpdateURLRequest{URL: "http://example.com/fixed"})
+		Expect(err).To(MatchError(storage.ErrNotFound))
+	})
+
+	It("should give a fresh short URL when the deterministic one was edited", func() {
+		store.EXPECT().Save(gomock.Any(), gomock.Any()).Return(storage.ErrAlreadyExist)
+		store.EXPECT().Get(gomock.Any(), gomock.Any()).Return(models.URL{OriginalURL: "http://example.com/fixed"}, nil)
+		store.EXPECT().Save(gomock.Any(), gomock.Any()).Return(nil)
+		shortURL, alreadyExists, err := shortener.ShortenURL(context.Background(), "http://example.com/typo", userID)
+		Expect(err).To(BeNil())
+		Expect(alreadyExists).To(BeFalse())
+		Expect(shortURL).To(HavePrefix("http://short/"))
+	})
 })
 
 var _ = Describe("ExpandURL", func() {
@@ -133,18 +316,53 @@ var _ = Describe("ExpandURL", func() {
 	})
 
 	It("should expand a short URL", func() {
-		store.EXPECT().Get(gomock.Any(), "short123").Return("http://example.com/1", nil)
-		orig, err := shortener.ExpandURL(context.Background(), "short123")
+		store.EXPECT().Get(gomock.Any(), "short123").Return(models.URL{ShortURL: "short123", OriginalURL: "http://example.com/1"}, nil)
+		orig, _, err := shortener.ExpandURL(context.Background(), "short123")
 		Expect(err).To(BeNil())
 		Expect(orig).To(Equal("http://example.com/1"))
 	})
 
 	It("should return error if not found", func() {
-		store.EXPECT().Get(gomock.Any(), "short404").Return("", errors.New("not found"))
-		orig, err := shortener.ExpandURL(context.Background(), "short404")
+		store.EXPECT().Get(gomock.Any(), "short404").Return(models.URL{}, errors.New("not found"))
+		orig, _, err := shortener.ExpandURL(context.Background(), "short404")
 		Expect(err).To(HaveOccurred())
 		Expect(orig).To(BeEmpty())
 	})
+
+	It("should require an interstitial page for flagged links", func() {
+		store.EXPECT().Get(gomock.Any(), "short123").Return(models.URL{ShortURL: "short123", OriginalURL: "http://example.com/1", Interstitial: true}, nil)
+		orig, _, err := shortener.ExpandURL(context.Background(), "short123")
+		Expect(err).To(MatchError(service.ErrInterstitial))
+		Expect(orig).To(BeEmpty())
+	})
+
+	It("should preview a short URL", func() {
+		store.EXPECT().Get(gomock.Any(), "short123").Return(models.URL{ShortURL: "short123", OriginalURL: "http://example.com/1", Interstitial: true}, nil)
+		preview, err := shortener.PreviewURL(context.Background(), "short123")
+		Expect(err).To(BeNil())
+		Expect(*preview).To(Equal(models.Preview{ShortURL: "http://short/short123", OriginalURL: "http://example.com/1", Title: "example.com"}))
+	})
+
+	It("should preview a short URL with its title", func() {
+		store.EXPECT().Get(gomock.Any(), "short123").Return(models.URL{
+			ShortURL: "short123", OriginalURL: "http://example.com/1", URLMetadata: models.URLMetadata{Title: "Example"},
+		}, nil)
+		preview, err := shortener.PreviewURL(context.Background(), "short123")
+		Expect(err).To(BeNil())
+		Expect(preview.Title).To(Equal("Example"))
+	})
+
+	It("should not preview password-protected links", func() {
+		store.EXPECT().Get(gomock.Any(), "locked").Return(models.URL{ShortURL: "locked", OriginalURL: "http://example.com/1", PasswordHash: "hash"}, nil)
+		_, err := shortener.PreviewURL(context.Background(), "locked")
+		Expect(err).To(MatchError(service.ErrPasswordRequired))
+	})
+
+	It("should return ErrNotFound when previewing unknown links", func() {
+		store.EXPECT().Get(gomock.Any(), "short404").Return(models.URL{}, storage.ErrNotFound)
+		_, err := shortener.PreviewURL(context.Background(), "short404")
+		Expect(err).To(MatchError(storage.ErrNotFound))
+	})
 })
 
 var _ = Describe("GetAll", func() {
@@ -170,8 +388,8 @@ var _ = Describe("GetAll", func() {
 			{ShortURL: "abc123", OriginalURL: "http://example.com/1"},
 			{ShortURL: "def456", OriginalURL: "http://example.com/2"},
 		}
-		store.EXPECT().GetAll(gomock.Any(), userID).Return(urls, nil)
-		result, err := shortener.GetAll(context.Background(), userID)
+		store.EXPECT().GetAll(gomock.Any(), userID, models.URLFilter{}).Return(urls, nil)
+		result, err := shortener.GetAll(context.Background(), userID, models.URLFilter{})
 		Expect(err).To(BeNil())
 		Expect(result).To(HaveLen(2))
 		Expect(result[0].ShortURL).To(Equal("http://short/abc123"))
@@ -179,13 +397,256 @@ var _ = Describe("GetAll", func() {
 	})
 
 	It("should return error if storage fails", func() {
-		store.EXPECT().GetAll(gomock.Any(), userID).Return(nil, errors.New("fail"))
-		result, err := shortener.GetAll(context.Background(), userID)
+		store.EXPECT().GetAll(gomock.Any(), userID, models.URLFilter{}).Return(nil, errors.New("fail"))
+		result, err := shortener.GetAll(context.Background(), userID, models.URLFilter{})
 		Expect(err).To(HaveOccurred())
 		Expect(result).To(BeNil())
 	})
 })
 
+var _ = Describe("Metadata", func() {
+	const userID = "ffffffff-ffff-ffff-ffff-ffffffffffff"
+	var (
+		ctrl      *gomock.Controller
+		store     *mocks.MockStorage
+		fetcher   *fakeFetcher
+		shortener service.Shortener
+	)
+
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		store = mocks.NewMockStorage(ctrl)
+		fetcher = &fakeFetcher{meta: models.URLMetadata{Title: "Example Domain", Description: "An example"}}
+		shortener = service.NewShortener(store, store, store, store, "http://short",
+			service.WithUpdater(store), service.WithMetadataFetcher(fetcher))
+	})
+
+	AfterEach(func() {
+		ctrl.Finish()
+	})
+
+	It("should normalize the metadata of a new link", func() {
+		var saved models.URL
+		store.EXPECT().Save(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, model models.URL) error {
+			saved = model
+			return nil
+		})
+		_, _, err := shortener.ShortenURL(context.Background(), "http://example.com/", userID, service.WithMetadata(models.URLMetadata{
+			Title: " Docs ",
+			Tags:  models.Tags{"Work", " docs", "work", ""},
+		}))
+		Expect(err).To(BeNil())
+		Expect(saved.Title).To(Equal("Docs"))
+		Expect(saved.Tags).To(Equal(models.Tags{"docs", "work"}))
+	})
+
+	It("should reject invalid tags", func() {
+		_, _, err := shortener.ShortenURL(context.Background(), "http://example.com/", userID, service.WithMetadata(models.URLMetadata{
+			Tags: models.Tags{"a,b"},
+		}))
+		Expect(err).To(MatchError(service.ErrInvalidMetadata))
+	})
+
+	It("should fetch the title of an untitled link in the background", func() {
+		store.EXPECT().Save(gomock.Any(), gomock.Any()).Return(nil)
+		store.EXPECT().Get(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, short string) (models.URL, error) {
+			return models.URL{UserID: userID, ShortURL: short, OriginalURL: "http://example.com/"}, nil
+		})
+		done := make(chan models.URLMetadata, 1)
+		store.EXPECT().SetMetadata(gomock.Any(), userID, gomock.Any(), gomock.Any()).DoAndReturn(
+			func(_ context.Context, _ string, _ string, meta models.URLMetadata) error {
+				done <- meta
+				return nil
+			})
+		_, _, err := shortener.ShortenURL(context.Background(), "http://example.com/", userID)
+		Expect(err).To(BeNil())
+		var meta models.URLMetadata
+		Eventually(done).Should(Receive(&meta))
+		Expect(meta.Title).To(Equal("Example Domain"))
+		Expect(meta.Description).To(Equal("An example"))
+	})
+
+	It("should update only the given metadata fields", func() {
+		notes := "shared with the team"
+		tags := models.Tags{"Team"}
+		store.EXPECT().Get(gomock.Any(), "short1").Return(models.URL{
+			UserID: userID, ShortURL: "short1", OriginalURL: "http://example.com/",
+			URLMetadata: models.URLMetadata{Title: "Example"},
+		}, nil)
+		store.EXPECT().PatchURL(gomock.Any(), userID, "short1", models.URLPatch{Metadata: &models.URLMetadata{
+			Title: "Example", Notes: notes, Tags: models.Tags{"team"},
+		}}, gomock.Any()).Return(nil)
+		url, err := shortener.UpdateURL(context.Background(), userID, "short1", models.UpdateURLRequest{Notes: &notes, Tags: &tags})
+		Expect(err).To(BeNil())
+		Expect(url.Title).To(Equal("Example"))
+		Expect(url.Tags).To(Equal(models.Tags{"team"}))
+	})
+
+	It("should filter URLs by normalized tags", func() {
+		store.EXPECT().GetAll(gomock.Any(), userID, models.URLFilter{Tags: []string{"docs", "work"}}).Return(nil, nil)
+		_, err := shortener.GetAll(context.Background(), userID, models.URLFilter{Tags: []string{"Work", "docs"}})
+		Expect(err).To(BeNil())
+	})
+})
+
+type fakeFetcher struct {
+	meta models.URLMetadata
+}
+
+func (f *fakeFetcher) Fetch(ctx context.Context, url string) (models.URLMetadata, error) {
+	return f.meta, nil
+}
+
+var _ = Describe("SearchURLs", func() {
+	const userID = "ffffffff-ffff-ffff-ffff-ffffffffffff"
+	var (
+		ctrl      *gomock.Controller
+		store     *mocks.MockStorage
+		shortener service.Shortener
+	)
+
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		store = mocks.NewMockStorage(ctrl)
+		shortener = service.NewShortener(store, store, store, store, "http://short", service.WithSearcher(store))
+	})
+
+	AfterEach(func() {
+		ctrl.Finish()
+	})
+
+	It("should search with the default limit and return full short URLs", func() {
+		store.EXPECT().Search(gomock.Any(), userID, "q3 report", service.DefaultSearchLimit).Return([]models.URL{
+			{ShortURL: "abc123", OriginalURL: "http://example.com/q3-report", PasswordHash: "hash"},
+		}, nil)
+		urls, err := shortener.SearchURLs(context.Background(), userID, "  q3 report ", 0)
+		Expect(err).To(BeNil())
+		Expect(urls).To(HaveLen(1))
+		Expect(urls[0].ShortURL).To(Equal("http://short/abc123"))
+		Expect(urls[0].PasswordHash).To(BeEmpty())
+	})
+
+	It("should reject empty queries and limits out of range", func() {
+		_, err := shortener.SearchURLs(context.Background(), userID, " ", 0)
+		Expect(err).To(MatchError(service.ErrInvalidQuery))
+		_, err = shortener.SearchURLs(context.Background(), userID, "report", service.MaxSearchLimit+1)
+		Expect(err).To(MatchError(service.ErrInvalidQuery))
+		_, err = shortener.SearchURLs(context.Background(), userID, "report", -1)
+		Expect(err).To(MatchError(service.ErrInvalidQuery))
+	})
+})
+
+var _ = Describe("Import and export", func() {
+	const userID = "ffffffff-ffff-ffff-ffff-ffffffffffff"
+	var (
+		ctx       context.Context
+		store     *storage.MemoryStorage
+		shortener service.Shortener
+	)
+
+	records := func(records ...models.LinkRecord) service.LinkSeq {
+		return func(yield func(models.LinkRecord, error) bool) {
+			for i, record := range records {
+				record.Row = i + 1
+				var err error
+				if record.OriginalURL == "" {
+					err = errors.New("malformed")
+				}
+				if !yield(record, err) {
+					return
+				}
+			}
+		}
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		var err error
+		store, err = storage.NewMemoryStorage(ctx)
+		Expect(err).To(BeNil())
+		shortener = service.NewShortener(store, store, store, store, "http://short", service.WithStreamer(store))
+	})
+
+	It("should report the result of every record", func() {
+		Expect(store.Save(ctx, models.URL{UserID: "someone-else", ShortURL: "taken", OriginalURL: "http://example.com/other"})).To(Succeed())
+		past := time.Now().Add(-time.Hour)
+		future := time.Now().Add(time.Hour)
+
+		report, err := shortener.ImportURLs(ctx, userID, records(
+			models.LinkRecord{OriginalURL: "http://example.com/1", Tags: models.Tags{"Work"}},
+			models.LinkRecord{OriginalURL: "http://example.com/2", Alias: "docs"},
+			models.LinkRecord{OriginalURL: "http://example.com/3", Alias: "taken"},
+			models.LinkRecord{OriginalURL: "example.com/no-scheme"},
+			models.LinkRecord{OriginalURL: "http://example.com/4", Alias: "api"},
+			models.LinkRecord{OriginalURL: "http://example.com/5", ExpiresAt: &past},
+			models.LinkRecord{OriginalURL: "http://example.com/6", ExpiresAt: &future},
+			models.LinkRecord{},
+			models.LinkRecord{OriginalURL: "http://example.com/1"},
+		))
+		Expect(err).To(BeNil())
+		Expect(report.Created).To(Equal(3))
+		Expect(report.Skipped).To(Equal(1))
+		Expect(report.Failed).To(Equal(5))
+
+		var statuses []string
+		for i, result := range report.Results {
+			Expect(result.Row).To(Equal(i + 1))
+			statuses = append(statuses, result.Status)
+		}
+		Expect(statuses).To(Equal([]string{
+			models.ImportCreated, models.ImportCreated, models.ImportConflict, models.ImportInvalid, models.ImportInvalid,
+			models.ImportInvalid, models.ImportCreated, models.ImportInvalid, models.ImportExists,
+		}))
+		Expect(report.Results[1].ShortURL).To(Equal("http://short/docs"))
+		Expect(report.Results[8].ShortURL).To(Equal(report.Results[0].ShortURL))
+
+		url, err := store.Get(ctx, "docs")
+		Expect(err).To(BeNil())
+		Expect(url.UserID).To(Equal(userID))
+	})
+
+	It("should skip links imported before", func() {
+		file := records(models.LinkRecord{OriginalURL: "http://example.com/1"}, models.LinkRecord{OriginalURL: "http://example.com/2", Alias: "two"})
+		_, err := shortener.ImportURLs(ctx, userID, file)
+		Expect(err).To(BeNil())
+
+		report, err := shortener.ImportURLs(ctx, userID, file)
+		Expect(err).To(BeNil())
+		Expect(report.Created).To(BeZero())
+		Expect(report.Skipped).To(Equal(2))
+	})
+
+	It("should export links so that they can be imported again", func() {
+		future := time.Now().Add(time.Hour).UTC()
+		_, err := shortener.ImportURLs(ctx, userID, records(
+			models.LinkRecord{OriginalURL: "http://example.com/1", Alias: "one", Tags: models.Tags{"work"}, ExpiresAt: &future},
+		))
+		Expect(err).To(BeNil())
+
+		var exported []models.LinkRecord
+		for record, exportErr := range shortener.ExportURLs(ctx, userID) {
+			Expect(exportErr).To(BeNil())
+			exported = append(exported, record)
+		}
+		Expect(exported).To(HaveLen(1))
+		Expect(exported[0].Alias).To(Equal("one"))
+		Expect(exported[0].ShortURL).To(Equal("http://short/one"))
+		Expect(exported[0].Tags).To(Equal(models.Tags{"work"}))
+
+		report, err := shortener.ImportURLs(ctx, userID, shortener.ExportURLs(ctx, userID))
+		Expect(err).To(BeNil())
+		Expect(report.Skipped).To(Equal(1))
+	})
+
+	It("should not follow expired links", func() {
+		past := time.Now().Add(-time.Minute)
+		Expect(store.Save(ctx, models.URL{UserID: userID, ShortURL: "expired", OriginalURL: "http://example.com/", ExpiresAt: &past})).To(Succeed())
+		_, _, err := shortener.ExpandURL(ctx, "expired")
+		Expect(err).To(MatchError(service.ErrExpired))
+		Expect(errors.Is(err, storage.ErrDeleted)).To(BeTrue())
+	})
+})
+
 var _ = Describe("DeleteMany", func() {
 	const userID = "ffffffff-ffff-ffff-ffff-ffffffffffff"
 	var (
@@ -218,3 +679,893 @@ var _ = Describe("DeleteMany", func() {
 		Expect(err).To(HaveOccurred())
 	})
 })
+
+var _ = Describe("RestoreURL", func() {
+	const userID = "ffffffff-ffff-ffff-ffff-ffffffffffff"
+	var (
+		ctrl      *gomock.Controller
+		store     *mocks.MockStorage
+		shortener service.Shortener
+	)
+
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		store = mocks.NewMockStorage(ctrl)
+		shortener = service.NewShortener(store, store, store, store, "http://short", service.WithRestorer(store, time.Hour))
+	})
+
+	AfterEach(func() {
+		ctrl.Finish()
+	})
+
+	It("should restore URLs deleted within the retention window", func() {
+		store.EXPECT().Restore(gomock.Any(), userID, "abc123", gomock.Any()).DoAndReturn(
+			func(_ context.Context, _ string, _ string, deletedAfter time.Time) error {
+				Expect(deletedAfter).To(BeTemporally("~", time.Now().Add(-time.Hour), time.Second))
+				return nil
+			})
+		Expect(shortener.RestoreURL(context.Background(), userID, "abc123")).To(Succeed())
+	})
+
+	It("should purge URLs deleted before the retention window", func() {
+		store.EXPECT().Purge(gomock.Any(), gomock.Any()).DoAndReturn(
+			func(_ context.Context, deletedBefore time.Time) (int64, error) {
+				Expect(deletedBefore).To(BeTemporally("~", time.Now().Add(-time.Hour), time.Second))
+				return 2, nil
+			})
+		purged, err := shortener.PurgeDeleted(context.Background())
+		Expect(err).To(BeNil())
+		Expect(purged).To(Equal(int64(2)))
+	})
+})
+
+var _ = Describe("GetQRCode", func() {
+	var (
+		ctrl      *gomock.Controller
+		store     *mocks.MockStorage
+		shortener service.Shortener
+	)
+
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		store = mocks.NewMockStorage(ctrl)
+		shortener = service.NewShortener(store, store, store, store, "http://short")
+	})
+
+	AfterEach(func() {
+		ctrl.Finish()
+	})
+
+	It("should render and cache the QR code of an existing URL", func() {
+		store.EXPECT().Get(gomock.Any(), "abc123").Return(models.URL{ShortURL: "abc123", OriginalURL: "https://example.com"}, nil).Times(2)
+
+		img, err := shortener.GetQRCode(context.Background(), "abc123", qr.Options{Format: qr.FormatSVG, Margin: qr.DefaultMargin})
+		Expect(err).To(BeNil())
+		Expect(img.ContentType).To(Equal("image/svg+xml"))
+
+		cached, err := shortener.GetQRCode(context.Background(), "abc123", qr.Options{Format: qr.FormatSVG, Margin: qr.DefaultMargin})
+		Expect(err).To(BeNil())
+		Expect(cached).To(BeIdenticalTo(img))
+	})
+
+	It("should reject invalid options without touching storage", func() {
+		_, err := shortener.GetQRCode(context.Background(), "abc123", qr.Options{Size: 1})
+		Expect(err).To(MatchError(qr.ErrInvalidOptions))
+	})
+
+	It("should return ErrNotFound for unknown URLs", func() {
+		store.EXPECT().Get(gomock.Any(), "missing").Return(models.URL{}, storage.ErrNotFound)
+		_, err := shortener.GetQRCode(context.Background(), "missing", qr.Options{})
+		Expect(err).To(MatchError(storage.ErrNotFound))
+	})
+
+	It("should return ErrDeleted for deleted URLs", func() {
+		store.EXPECT().Get(gomock.Any(), "gone").Return(models.URL{}, storage.ErrDeleted)
+		_, err := shortener.GetQRCode(context.Background(), "gone", qr.Options{})
+		Expect(err).To(MatchError(storage.ErrDeleted))
+	})
+})
+
+var _ = Describe("APIKeys", func() {
+	const userID = "ffffffff-ffff-ffff-ffff-ffffffffffff"
+	var (
+		ctrl      *gomock.Controller
+		store     *mocks.MockStorage
+		shortener service.Shortener
+	)
+
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		store = mocks.NewMockStorage(ctrl)
+		shortener = service.NewShortener(store, store, store, store, "http://short", service.WithKeyStorage(store))
+	})
+
+	AfterEach(func() {
+		ctrl.Finish()
+	})
+
+	It("should create a key and verify it by its hash", func() {
+		var saved models.APIKey
+		store.EXPECT().SaveAPIKey(gomock.Any(), gomock.Any()).DoAndReturn(
+			func(ctx context.Context, key models.APIKey) error {
+				saved = key
+				return nil
+			},
+		)
+		resp, err := shortener.CreateAPIKey(context.Background(), userID, "ci", []string{models.ScopeShorten})
+		Expect(err).To(BeNil())
+		Expect(resp.Key).To(HavePrefix(resp.Prefix))
+		Expect(resp.KeyHash).To(BeEmpty())
+		Expect(saved.KeyHash).NotTo(BeEmpty())
+		Expect(saved.KeyHash).NotTo(ContainSubstring(resp.Key))
+		Expect(saved.UserID).To(Equal(userID))
+
+		store.EXPECT().GetAPIKeyByHash(gomock.Any(), saved.KeyHash).Return(saved, nil)
+		store.EXPECT().TouchAPIKey(gomock.Any(), saved.ID, gomock.Any()).Return(nil)
+		key, err := shortener.VerifyAPIKey(context.Background(), resp.Key)
+		Expect(err).To(BeNil())
+		Expect(key.UserID).To(Equal(userID))
+		Expect(key.LastUsedAt).NotTo(BeNil())
+	})
+
+	It("should reject unknown scopes", func() {
+		_, err := shortener.CreateAPIKey(context.Background(), userID, "ci", []string{"admin"})
+		Expect(err).To(MatchError(service.ErrInvalidScope))
+	})
+
+	It("should reject revoked keys", func() {
+		revokedAt := time.Now()
+		store.EXPECT().GetAPIKeyByHash(gomock.Any(), gomock.Any()).Return(models.APIKey{RevokedAt: &revokedAt}, nil)
+		_, err := shortener.VerifyAPIKey(context.Background(), "sk_revoked")
+		Expect(err).To(MatchError(service.ErrInvalidAPIKey))
+	})
+
+	It("should reject unknown keys", func() {
+		store.EXPECT().GetAPIKeyByHash(gomock.Any(), gomock.Any()).Return(models.APIKey{}, storage.ErrNotFound)
+		_, err := shortener.VerifyAPIKey(context.Background(), "sk_unknown")
+		Expect(err).To(MatchError(service.ErrInvalidAPIKey))
+	})
+})
+
+var _ = Describe("Webhooks", func() {
+	const userID = "ffffffff-ffff-ffff-ffff-ffffffffffff"
+	var (
+		ctrl      *gomock.Controller
+		store     *mocks.MockStorage
+		shortener service.Shortener
+		hook      models.Webhook
+	)
+
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		store = mocks.NewMockStorage(ctrl)
+		shortener = service.NewShortener(store, store, store, store, "http://short", service.WithWebhooks(store, store))
+		hook = models.Webhook{ID: "11111111-1111-1111-1111-111111111111", UserID: userID, Events: models.AllEvents}
+	})
+
+	AfterEach(func() {
+		ctrl.Finish()
+	})
+
+	// expectDelivery expects a single delivery to be queued and returns its event, decoded once it is.
+	expectDelivery := func() *models.WebhookEvent {
+		event := &models.WebhookEvent{}
+		store.EXPECT().SaveDeliveries(gomock.Any(), gomock.Len(1)).DoAndReturn(
+			func(_ context.Context, deliveries []models.WebhookDelivery) error {
+				defer GinkgoRecover()
+				Expect(deliveries[0].WebhookID).To(Equal(hook.ID))
+				Expect(deliveries[0].Status).To(Equal(models.DeliveryPending))
+				Expect(deliveries[0].NextAttemptAt).NotTo(BeNil())
+				Expect(json.Unmarshal(deliveries[0].Payload, event)).To(Succeed())
+				Expect(event.ID).To(Equal(deliveries[0].ID))
+				return nil
+			},
+		)
+		return event
+	}
+
+	It("should create a webhook for all events with a secret shown once", func() {
+		var saved models.Webhook
+		store.EXPECT().GetWebhooks(gomock.Any(), userID).Return(nil, nil)
+		store.EXPECT().SaveWebhook(gomock.Any(), gomock.Any()).DoAndReturn(
+			func(_ context.Context, hook models.Webhook) error {
+				saved = hook
+				return nil
+			},
+		)
+		created, err := shortener.CreateWebhook(context.Background(), userID, "https://crm.example.com/hooks", nil)
+		Expect(err).To(BeNil())
+		Expect(created.Secret).To(HavePrefix("whsec_"))
+		Expect(created.Secret).To(Equal(saved.Secret))
+		Expect(created.Events).To(ConsistOf(models.EventLinkCreated, models.EventLinkDeleted, models.EventLinkFirstClicked))
+		Expect(saved.UserID).To(Equal(userID))
+
+		store.EXPECT().GetWebhooks(gomock.Any(), userID).Return([]models.Webhook{saved}, nil)
+		hooks, err := shortener.GetWebhooks(context.Background(), userID)
+		Expect(err).To(BeNil())
+		Expect(hooks).To(HaveLen(1))
+		Expect(hooks[0].Secret).To(BeEmpty())
+	})
+
+	It("should reject invalid webhooks", func() {
+		_, err := shortener.CreateWebhook(context.Background(), userID, "ftp://example.com", nil)
+		Expect(err).To(MatchError(service.ErrInvalidURL))
+		_, err = shortener.CreateWebhook(context.Background(), userID, "https://example.com", []string{"link.renamed"})
+		Expect(err).To(MatchError(service.ErrInvalidEvent))
+
+		store.EXPECT().GetWebhooks(gomock.Any(), userID).Return(make([]models.Webhook, service.MaxWebhooks), nil)
+		_, err = shortener.CreateWebhook(context.Background(), userID, "https://example.com", nil)
+		Expect(err).To(MatchError(service.ErrTooManyWebhooks))
+	})
+
+	It("should queue an event for new links", func() {
+		store.EXPECT().Save(gomock.Any(), gomock.Any()).Return(nil)
+		store.EXPECT().GetWebhooks(gomock.Any(), userID).Return([]models.Webhook{hook}, nil)
+		event := expectDelivery()
+
+		link, _, err := shortener.Shorten(context.Background(), "http://example.com", userID)
+		Expect(err).To(BeNil())
+		Expect(event.Event).To(Equal(models.EventLinkCreated))
+		Expect(event.Link.ShortURL).To(Equal(link.ShortURL))
+		Expect(event.Link.ID).To(Equal(strings.TrimPrefix(link.ShortURL, "http://short/")))
+	})
+
+	It("should log errors of queuing events for saved links instead of returning them", func() {
+		log := mocks.NewMockLogger(ctrl)
+		shortener = service.NewShortener(store, store, store, store, "http://short", service.WithWebhooks(store, store), service.WithLogger(log))
+		store.EXPECT().Save(gomock.Any(), gomock.Any()).Return(nil)
+		store.EXPECT().GetWebhooks(gomock.Any(), userID).Return(nil, errors.New("fail"))
+		log.EXPECT().Error(errors.New("fail"))
+
+		link, alreadyExists, err := shortener.Shorten(context.Background(), "http://example.com", userID)
+		Expect(err).To(BeNil())
+		Expect(alreadyExists).To(BeFalse())
+		Expect(link.OriginalURL).To(Equal("http://example.com"))
+
+		store.EXPECT().SaveMany(gomock.Any(), gomock.Len(1)).Return([]error{nil}, nil)
+		store.EXPECT().GetWebhooks(gomock.Any(), userID).Return([]models.Webhook{hook}, nil)
+		store.EXPECT().SaveDeliveries(gomock.Any(), gomock.Len(1)).Return(errors.New("fail"))
+		log.EXPECT().Error(errors.New("fail"))
+
+		resp, err := shortener.ShortenBatch(context.Background(), models.BatchRequest{{CorrelationID: "1", OriginalURL: "http://example.com/1"}}, userID, "")
+		Expect(err).To(BeNil())
+		Expect(resp[0].Status).To(Equal(models.BatchCreated))
+	})
+
+	It("should log errors of the audit log and events of changes that were made instead of returning them", func() {
+		log := mocks.NewMockLogger(ctrl)
+		shortener = service.NewShortener(store, store, store, store, "http://short",
+			service.WithWebhooks(store, store), service.WithAuditLog(store, time.Hour),
+			service.WithRestorer(store, time.Hour), service.WithUpdater(store), service.WithLogger(log))
+		url := models.URL{ShortURL: "mine", UserID: userID, OriginalURL: "http://example.com"}
+		store.EXPECT().SaveAuditEntries(gomock.Any(), gomock.Any()).Return(errors.New("audit failed")).Times(3)
+		log.EXPECT().Error(errors.New("audit failed")).Times(3)
+
+		store.EXPECT().Get(gomock.Any(), "mine").Return(url, nil)
+		store.EXPECT().DeleteMany(gomock.Any(), userID, []string{"mine"}).Return(nil)
+		store.EXPECT().GetWebhooks(gomock.Any(), userID).Return(nil, errors.New("webhooks failed"))
+		log.EXPECT().Error(errors.New("webhooks failed"))
+		Expect(shortener.DeleteMany(context.Background(), userID, []string{"mine"})).To(Succeed())
+
+		store.EXPECT().Restore(gomock.Any(), userID, "mine", gomock.Any()).Return(nil)
+		store.EXPECT().Get(gomock.Any(), "mine").Return(url, nil)
+		Expect(shortener.RestoreURL(context.Background(), userID, "mine")).To(Succeed())
+
+		store.EXPECT().Get(gomock.Any(), "mine").Return(url, nil)
+		store.EXPECT().PatchURL(gomock.Any(), userID, "mine", models.URLPatch{OriginalURL: "http://example.com/new"}, gomock.Any()).Return(nil)
+		updated, err := shortener.UpdateURL(context.Background(), userID, "mine", models.UpdateURLRequest{URL: "http://example.com/new"})
+		Expect(err).To(BeNil())
+		Expect(updated.OriginalURL).To(Equal("http://example.com/new"))
+	})
+
+	It("should not queue events nobody subscribed to", func() {
+		hook.Events = models.Events{models.EventLinkDeleted}
+		store.EXPECT().SaveMany(gomock.Any(), gomock.Len(1)).Return([]error{nil}, nil)
+		store.EXPECT().GetWebhooks(gomock.Any(), userID).Return([]models.Webhook{hook}, nil)
+
+		_, err := shortener.ShortenBatch(context.Background(), models.BatchRequest{{CorrelationID: "1", OriginalURL: "http://example.com"}}, userID, "")
+		Expect(err).To(BeNil())
+	})
+
+	It("should queue events only for deleted links of the user", func() {
+		store.EXPECT().GetWebhooks(gomock.Any(), userID).Return([]models.Webhook{hook}, nil)
+		store.EXPECT().Get(gomock.Any(), "mine").Return(models.URL{ShortURL: "mine", UserID: userID, OriginalURL: "http://example.com"}, nil)
+		store.EXPECT().Get(gomock.Any(), "theirs").Return(models.URL{ShortURL: "theirs", UserID: "other"}, nil)
+		store.EXPECT().Get(gomock.Any(), "gone").Return(models.URL{}, storage.ErrDeleted)
+		store.EXPECT().DeleteMany(gomock.Any(), userID, []string{"mine", "theirs", "gone"}).Return(nil)
+		event := expectDelivery()
+
+		Expect(shortener.DeleteMany(context.Background(), userID, []string{"mine", "theirs", "gone"})).To(Succeed())
+		Expect(event.Event).To(Equal(models.EventLinkDeleted))
+		Expect(event.Link.ID).To(Equal("mine"))
+	})
+
+	It("should queue an event for the first click only", func() {
+		url := models.URL{ShortURL: "abc", UserID: userID, OriginalURL: "http://example.com"}
+		store.EXPECT().Get(gomock.Any(), "abc").Return(url, nil)
+		store.EXPECT().RecordFirstClick(gomock.Any(), "abc", gomock.Any()).Return(true, nil)
+		store.EXPECT().GetWebhooks(gomock.Any(), userID).Return([]models.Webhook{hook}, nil)
+		event := expectDelivery()
+
+		_, _, err := shortener.ExpandURL(context.Background(), "abc")
+		Expect(err).To(BeNil())
+		Expect(event.Event).To(Equal(models.EventLinkFirstClicked))
+		Expect(event.Link.ShortURL).To(Equal("http://short/abc"))
+
+		clickedAt := time.Now()
+		url.ClickedAt = &clickedAt
+		store.EXPECT().Get(gomock.Any(), "abc").Return(url, nil)
+		_, _, err = shortener.ExpandURL(context.Background(), "abc")
+		Expect(err).To(BeNil())
+	})
+
+	It("should not fail redirects when events cannot be queued", func() {
+		store.EXPECT().Get(gomock.Any(), "abc").Return(models.URL{ShortURL: "abc", UserID: userID, OriginalURL: "http://example.com"}, nil)
+		store.EXPECT().RecordFirstClick(gomock.Any(), "abc", gomock.Any()).Return(false, errors.New("fail"))
+
+		url, _, err := shortener.ExpandURL(context.Background(), "abc")
+		Expect(err).To(BeNil())
+		Expect(url).To(Equal("http://example.com"))
+	})
+
+	It("should return deliveries of the user's webhooks only", func() {
+		store.EXPECT().GetWebhook(gomock.Any(), hook.ID).Return(hook, nil)
+		store.EXPECT().GetDeliveries(gomock.Any(), hook.ID, service.MaxWebhookDeliveries).
+			Return([]models.WebhookDelivery{{ID: "1", WebhookID: hook.ID}}, nil)
+		deliveries, err := shortener.GetWebhookDeliveries(context.Background(), userID, hook.ID)
+		Expect(err).To(BeNil())
+		Expect(deliveries).To(HaveLen(1))
+
+		store.EXPECT().GetWebhook(gomock.Any(), hook.ID).Return(hook, nil)
+		_, err = shortener.GetWebhookDeliveries(context.Background(), "other", hook.ID)
+		Expect(err).To(MatchError(storage.ErrNotFound))
+
+		_, err = shortener.GetWebhookDeliveries(context.Background(), userID, "not-a-uuid")
+		Expect(err).To(MatchError(storage.ErrNotFound))
+	})
+
+	It("should be unsupported without a webhook storage", func() {
+		shortener = service.NewShortener(store, store, store, store, "http://short")
+		_, err := shortener.GetWebhooks(context.Background(), userID)
+		Expect(err).To(MatchError(service.ErrUnsupported))
+	})
+})
+
+var _ = Describe("Password-protected URLs", func() {
+	const userID = "ffffffff-ffff-ffff-ffff-ffffffffffff"
+	var (
+		ctrl      *gomock.Controller
+		store     *mocks.MockStorage
+		shortener service.Shortener
+		protected models.URL
+	)
+
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		store = mocks.NewMockStorage(ctrl)
+		shortener = service.NewShortener(store, store, store, store, "http://short")
+
+		store.EXPECT().Save(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, url models.URL) error {
+			protected = url
+			return nil
+		})
+		_, _, err := shortener.ShortenURL(context.Background(), "http://example.com/1", userID, service.WithPassword("secret"))
+		Expect(err).To(BeNil())
+	})
+
+	AfterEach(func() {
+		ctrl.Finish()
+	})
+
+	It("should store a hash instead of the password", func() {
+		Expect(protected.PasswordHash).NotTo(BeEmpty())
+		Expect(protected.PasswordHash).NotTo(ContainSubstring("secret"))
+	})
+
+	It("should not expand without the password", func() {
+		store.EXPECT().Get(gomock.Any(), protected.ShortURL).Return(protected, nil)
+		orig, _, err := shortener.ExpandURL(context.Background(), protected.ShortURL)
+		Expect(err).To(MatchError(service.ErrPasswordRequired))
+		Expect(orig).To(BeEmpty())
+	})
+
+	It("should unlock with the right password and accept the issued token", func() {
+		store.EXPECT().Get(gomock.Any(), protected.ShortURL).Return(protected, nil).Times(3)
+
+		orig, token, err := shortener.UnlockURL(context.Background(), protected.ShortURL, "secret", "client")
+		Expect(err).To(BeNil())
+		Expect(orig).To(Equal("http://example.com/1"))
+		Expect(token).NotTo(BeEmpty())
+
+		orig, _, err = shortener.ExpandUnlockedURL(context.Background(), protected.ShortURL, token)
+		Expect(err).To(BeNil())
+		Expect(orig).To(Equal("http://example.com/1"))
+
+		_, _, err = shortener.ExpandUnlockedURL(context.Background(), protected.ShortURL, token+"x")
+		Expect(err).To(MatchError(service.ErrPasswordRequired))
+	})
+
+	It("should throttle failed attempts per client", func() {
+		store.EXPECT().Get(gomock.Any(), protected.ShortURL).Return(protected, nil).Times(service.MaxUnlockAttempts + 1)
+
+		for range service.MaxUnlockAttempts {
+			_, _, err := shortener.UnlockURL(context.Background(), protected.ShortURL, "wrong", "client")
+			Expect(err).To(MatchError(service.ErrInvalidPassword))
+		}
+		_, _, err := shortener.UnlockURL(context.Background(), protected.ShortURL, "secret", "client")
+		Expect(err).To(MatchError(service.ErrTooManyAttempts))
+
+		_, _, err = shortener.UnlockURL(context.Background(), protected.ShortURL, "secret", "other")
+		Expect(err).To(BeNil())
+	})
+})
+
+// txtRecords is a service.TXTResolver answering from a map.
+type txtRecords map[string][]string
+
+func (r txtRecords) LookupTXT(_ context.Context, name string) ([]string, error) {
+	records, ok := r[name]
+	if !ok {
+		return nil, errors.New("no such host")
+	}
+	return records, nil
+}
+
+var _ = Describe("Custom domains", func() {
+	const userID = "ffffffff-ffff-ffff-ffff-ffffffffffff"
+	var (
+		ctrl      *gomock.Controller
+		store     *mocks.MockStorage
+		records   txtRecords
+		shortener service.Shortener
+		verified  models.Domain
+	)
+
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		store = mocks.NewMockStorage(ctrl)
+		records = txtRecords{}
+		shortener = service.NewShortener(store, store, store, store, "https://short.example", service.WithDomains(store, records))
+		now := time.Now()
+		verified = models.Domain{Name: "go.brand.com", UserID: userID, VerificationToken: "token", VerifiedAt: &now}
+	})
+
+	AfterEach(func() {
+		ctrl.Finish()
+	})
+
+	It("should shorten links on a verified domain of the user with codes unique per domain", func() {
+		var saved models.URL
+		store.EXPECT().GetVerifiedDomain(gomock.Any(), "go.brand.com").Return(verified, nil)
+		store.EXPECT().Save(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, url models.URL) error {
+			saved = url
+			return nil
+		})
+		link, _, err := shortener.Shorten(context.Background(), "http://example.com", userID, service.WithDomain("Go.Brand.com"))
+		Expect(err).To(BeNil())
+		domain, code := models.SplitLinkKey(saved.ShortURL)
+		Expect(domain).To(Equal("go.brand.com"))
+		Expect(link.ShortURL).To(Equal("https://go.brand.com/" + code))
+
+		l := models.NewLink(*link, "https://short.example")
+		Expect(l.ID).To(Equal(saved.ShortURL))
+		Expect(l.Domain).To(Equal("go.brand.com"))
+
+		var plain models.URL
+		store.EXPECT().Save(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, url models.URL) error {
+			plain = url
+			return nil
+		})
+		link, _, err = shortener.Shorten(context.Background(), "http://example.com", userID)
+		Expect(err).To(BeNil())
+		Expect(plain.UUID).NotTo(Equal(saved.UUID), "the same URL gets a link on every domain")
+		Expect(link.ShortURL).To(Equal("https://short.example/" + plain.ShortURL))
+	})
+
+	It("should not shorten links on domains the user has not verified", func() {
+		store.EXPECT().GetVerifiedDomain(gomock.Any(), "brand.link").Return(models.Domain{}, storage.ErrNotFound)
+		_, _, err := shortener.Shorten(context.Background(), "http://example.com", userID, service.WithDomain("brand.link"))
+		Expect(err).To(MatchError(service.ErrDomainNotVerified))
+
+		store.EXPECT().GetVerifiedDomain(gomock.Any(), "go.brand.com").Return(verified, nil)
+		_, _, err = shortener.Shorten(context.Background(), "http://example.com", "other", service.WithDomain("go.brand.com"))
+		Expect(err).To(MatchError(service.ErrDomainNotVerified))
+
+		_, _, err = shortener.Shorten(context.Background(), "http://example.com", userID, service.WithDomain("localhost"))
+		Expect(err).To(MatchError(service.ErrInvalidDomain))
+	})
+
+	It("should shorten batches on a verified domain of the user", func() {
+		var saved []models.URL
+		store.EXPECT().GetVerifiedDomain(gomock.Any(), "go.brand.com").Return(verified, nil)
+		store.EXPECT().SaveMany(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, urls []models.URL) ([]error, error) {
+			saved = urls
+			return make([]error, len(urls)), nil
+		})
+		resp, err := shortener.ShortenBatch(context.Background(), models.BatchRequest{
+			{CorrelationID: "1", OriginalURL: "http://example.com/1"},
+			{CorrelationID: "2", OriginalURL: "http://example.com/2"},
+		}, userID, "", service.WithDomain("go.brand.com"))
+		Expect(err).To(BeNil())
+		Expect(saved).To(HaveLen(2))
+		for i, url := range saved {
+			domain, code := models.SplitLinkKey(url.ShortURL)
+			Expect(domain).To(Equal("go.brand.com"))
+			Expect(resp[i].ShortURL).To(Equal("https://go.brand.com/" + code))
+		}
+
+		store.EXPECT().GetVerifiedDomain(gomock.Any(), "brand.link").Return(models.Domain{}, storage.ErrNotFound)
+		_, err = shortener.ShortenBatch(context.Background(), models.BatchRequest{{CorrelationID: "1", OriginalURL: "http://example.com/1"}},
+			userID, "", service.WithDomain("brand.link"))
+		Expect(err).To(MatchError(service.ErrDomainNotVerified))
+	})
+
+	It("should look short codes up on the domain they are requested on", func() {
+		url := models.URL{ShortURL: "go.brand.com:abc", OriginalURL: "http://example.com"}
+		store.EXPECT().GetVerifiedDomain(gomock.Any(), "go.brand.com").Return(verified, nil)
+		store.EXPECT().Get(gomock.Any(), "go.brand.com:abc").Return(url, nil)
+		orig, _, err := shortener.ExpandURL(service.WithHost(context.Background(), "GO.brand.com:443"), "abc")
+		Expect(err).To(BeNil())
+		Expect(orig).To(Equal("http://example.com"))
+
+		store.EXPECT().Get(gomock.Any(), "abc").Return(models.URL{ShortURL: "abc", OriginalURL: "http://default.com"}, nil)
+		orig, _, err = shortener.ExpandURL(service.WithHost(context.Background(), "short.example"), "abc")
+		Expect(err).To(BeNil())
+		Expect(orig).To(Equal("http://default.com"))
+
+		store.EXPECT().GetVerifiedDomain(gomock.Any(), "127.0.0.1").Return(models.Domain{}, storage.ErrNotFound)
+		store.EXPECT().Get(gomock.Any(), "abc").Return(models.URL{ShortURL: "abc", OriginalURL: "http://default.com"}, nil)
+		_, _, err = shortener.ExpandURL(service.WithHost(context.Background(), "127.0.0.1:8080"), "abc")
+		Expect(err).To(BeNil(), "unknown hosts serve the default domain")
+
+		_, _, err = shortener.ExpandURL(service.WithHost(context.Background(), "short.example"), "go.brand.com:abc")
+		Expect(err).To(MatchError(storage.ErrNotFound), "keys of other domains are not codes")
+	})
+
+	It("should verify domains by their TXT record", func() {
+		var saved models.Domain
+		store.EXPECT().GetDomains(gomock.Any(), userID).Return(nil, nil)
+		store.EXPECT().SaveDomain(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, domain models.Domain) error {
+			saved = domain
+			return nil
+		})
+		added, err := shortener.AddDomain(context.Background(), userID, "Brand.link.")
+		Expect(err).To(BeNil())
+		Expect(added.Name).To(Equal("brand.link"))
+		Expect(added.VerificationToken).NotTo(BeEmpty())
+		Expect(added.UserID).To(BeEmpty())
+		Expect(saved.UserID).To(Equal(userID))
+
+		store.EXPECT().GetDomains(gomock.Any(), userID).Return([]models.Domain{saved}, nil).Times(2)
+		_, err = shortener.VerifyDomain(context.Background(), userID, "brand.link")
+		Expect(err).To(MatchError(service.ErrDomainVerificationFailed))
+
+		records["_shortener.brand.link"] = []string{"v=spf1 -all", saved.VerificationToken}
+		store.EXPECT().VerifyDomain(gomock.Any(), userID, "brand.link", gomock.Any()).Return(nil)
+		domain, err := shortener.VerifyDomain(context.Background(), userID, "brand.link")
+		Expect(err).To(BeNil())
+		Expect(domain.Verified()).To(BeTrue())
+	})
+
+	It("should reject invalid domains", func() {
+		for _, name := range []string{"", "localhost", "short.example", "-brand.com", "brand..com", "10.0.0.1", "brand.com/path", "bränd.com"} {
+			_, err := shortener.AddDomain(context.Background(), userID, name)
+			Expect(err).To(MatchError(service.ErrInvalidDomain), name)
+		}
+	})
+})
+
+var _ = Describe("Workspaces", func() {
+	const (
+		ownerID  = "11111111-1111-1111-1111-111111111111"
+		editorID = "22222222-2222-2222-2222-222222222222"
+		viewerID = "33333333-3333-3333-3333-333333333333"
+	)
+	var (
+		ctx       context.Context
+		store     *storage.MemoryStorage
+		shortener service.Shortener
+		workspace *models.Workspace
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		var err error
+		store, err = storage.NewMemoryStorage(ctx)
+		Expect(err).To(BeNil())
+		shortener = service.NewShortener(store, store, store, store, "http://short",
+			service.WithWorkspaces(store), service.WithUpdater(store), service.WithWebhooks(store, store))
+
+		workspace, err = shortener.CreateWorkspace(ctx, ownerID, " Campaigns ")
+		Expect(err).To(BeNil())
+		Expect(workspace.Name).To(Equal("Campaigns"))
+		Expect(workspace.Role).To(Equal(models.RoleOwner))
+		_, err = shortener.SetMember(ctx, ownerID, workspace.ID, editorID, models.RoleEditor)
+		Expect(err).To(BeNil())
+		_, err = shortener.SetMember(ctx, ownerID, workspace.ID, viewerID, models.RoleViewer)
+		Expect(err).To(BeNil())
+	})
+
+	It("should send the events of the links of the workspace to the webhooks of its members", func() {
+		hook, err := shortener.CreateWebhook(ctx, viewerID, "https://hooks.example.com", nil)
+		Expect(err).To(BeNil())
+		_, err = shortener.CreateWebhook(ctx, "44444444-4444-4444-4444-444444444444", "https://other.example.com", nil)
+		Expect(err).To(BeNil())
+
+		inWorkspace := service.WithWorkspace(ctx, workspace.ID)
+		link, _, err := shortener.Shorten(inWorkspace, "http://example.com/1", editorID)
+		Expect(err).To(BeNil())
+		id := strings.TrimPrefix(link.ShortURL, "http://short/")
+		_, _, err = shortener.ExpandURL(ctx, id)
+		Expect(err).To(BeNil())
+		Expect(shortener.DeleteMany(inWorkspace, ownerID, []string{id})).To(Succeed())
+
+		deliveries, err := shortener.GetWebhookDeliveries(ctx, viewerID, hook.ID)
+		Expect(err).To(BeNil())
+		var events []string
+		for _, delivery := range deliveries {
+			events = append(events, delivery.Event)
+		}
+		Expect(events).To(ConsistOf(models.EventLinkCreated, models.EventLinkFirstClicked, models.EventLinkDeleted))
+	})
+
+	It("should share the links of the active workspace between its members as far as their roles allow", func() {
+		inWorkspace := service.WithWorkspace(ctx, workspace.ID)
+		link, _, err := shortener.Shorten(inWorkspace, "http://example.com/campaign", editorID)
+		Expect(err).To(BeNil())
+		short := strings.TrimPrefix(link.ShortURL, "http://short/")
+
+		urls, err := shortener.GetAll(inWorkspace, viewerID, models.URLFilter{})
+		Expect(err).To(BeNil())
+		Expect(urls).To(HaveLen(1))
+		urls, err = shortener.GetAll(ctx, editorID, models.URLFilter{})
+		Expect(err).To(BeNil())
+		Expect(urls).To(BeEmpty())
+
+		_, _, err = shortener.Shorten(inWorkspace, "http://example.com/other", viewerID)
+		Expect(err).To(MatchError(service.ErrInsufficientRole))
+		_, err = shortener.UpdateURL(inWorkspace, viewerID, short, models.UpdateURLRequest{URL: "http://example.com/new"})
+		Expect(err).To(MatchError(service.ErrInsufficientRole))
+		Expect(shortener.DeleteMany(inWorkspace, viewerID, []string{short})).To(MatchError(service.ErrInsufficientRole))
+
+		updated, err := shortener.UpdateURL(inWorkspace, ownerID, short, models.UpdateURLRequest{URL: "http://example.com/new"})
+		Expect(err).To(BeNil())
+		Expect(updated.OriginalURL).To(Equal("http://example.com/new"))
+		Expect(shortener.DeleteMany(inWorkspace, editorID, []string{short})).To(Succeed())
+	})
+
+	It("should reject users who are not members and invalid workspaces", func() {
+		const strangerID = "44444444-4444-4444-4444-444444444444"
+		_, err := shortener.GetAll(service.WithWorkspace(ctx, workspace.ID), strangerID, models.URLFilter{})
+		Expect(err).To(MatchError(service.ErrNotMember))
+		_, err = shortener.GetAll(service.WithWorkspace(ctx, "team"), ownerID, models.URLFilter{})
+		Expect(err).To(MatchError(service.ErrInvalidWorkspace))
+		_, err = shortener.GetMembers(ctx, strangerID, workspace.ID)
+		Expect(err).To(MatchError(service.ErrNotMember))
+	})
+
+	It("should let only owners manage members and keep the last owner", func() {
+		_, err := shortener.SetMember(ctx, editorID, workspace.ID, viewerID, models.RoleEditor)
+		Expect(err).To(MatchError(service.ErrInsufficientRole))
+		_, err = shortener.SetMember(ctx, ownerID, workspace.ID, viewerID, "admin")
+		Expect(err).To(MatchError(service.ErrInvalidRole))
+		_, err = shortener.SetMember(ctx, ownerID, workspace.ID, "someone", models.RoleViewer)
+		Expect(err).To(MatchError(service.ErrInvalidMember))
+		Expect(shortener.RemoveMember(ctx, editorID, workspace.ID, viewerID)).To(MatchError(service.ErrInsufficientRole))
+
+		Expect(shortener.RemoveMember(ctx, viewerID, workspace.ID, viewerID)).To(Succeed())
+		Expect(shortener.RemoveMember(ctx, ownerID, workspace.ID, viewerID)).To(MatchError(service.ErrMemberNotFound))
+		Expect(shortener.RemoveMember(ctx, ownerID, workspace.ID, ownerID)).To(MatchError(storage.ErrLastOwner))
+		_, err = shortener.SetMember(ctx, ownerID, workspace.ID, ownerID, models.RoleEditor)
+		Expect(err).To(MatchError(storage.ErrLastOwner))
+
+		promoted, err := shortener.SetMember(ctx, ownerID, workspace.ID, editorID, models.RoleOwner)
+		Expect(err).To(BeNil())
+		Expect(promoted.Role).To(Equal(models.RoleOwner))
+		Expect(shortener.RemoveMember(ctx, ownerID, workspace.ID, ownerID)).To(Succeed())
+
+		workspaces, err := shortener.GetWorkspaces(ctx, editorID)
+		Expect(err).To(BeNil())
+		Expect(workspaces).To(HaveLen(1))
+		Expect(workspaces[0].Role).To(Equal(models.RoleOwner))
+		members, err := shortener.GetMembers(ctx, editorID, workspace.ID)
+		Expect(err).To(BeNil())
+		Expect(members).To(HaveLen(1))
+	})
+})
+
+var _ = Describe("Quotas", func() {
+	const userID = "11111111-1111-1111-1111-111111111111"
+	var (
+		ctx       context.Context
+		store     *storage.MemoryStorage
+		shortener service.Shortener
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		var err error
+		store, err = storage.NewMemoryStorage(ctx)
+		Expect(err).To(BeNil())
+		shortener = service.NewShortener(store, store, store, store, "http://short", service.WithWorkspaces(store), service.WithQuotas(store, []models.Plan{
+			{Name: "free", MaxActiveLinks: 2, MaxMonthlyLinks: 3},
+			{Name: "pro"},
+		}, "free"))
+	})
+
+	It("should cap the active links and free them up on deletion", func() {
+		link, _, err := shortener.Shorten(ctx, "http://example.com/1", userID)
+		Expect(err).To(BeNil())
+		_, exists, err := shortener.Shorten(ctx, "http://example.com/1", userID)
+		Expect(err).To(BeNil())
+		Expect(exists).To(BeTrue())
+		_, err = shortener.ShortenBatch(ctx, models.BatchRequest{
+			{CorrelationID: "1", OriginalURL: "http://example.com/2"},
+			{CorrelationID: "2", OriginalURL: "http://example.com/3"},
+		}, userID, "")
+		Expect(err).To(MatchError(service.ErrQuotaExceeded))
+		_, _, err = shortener.Shorten(ctx, "http://example.com/2", userID)
+		Expect(err).To(BeNil())
+		_, _, err = shortener.Shorten(ctx, "http://example.com/3", userID)
+		Expect(err).To(MatchError(service.ErrQuotaExceeded))
+
+		Expect(shortener.DeleteMany(ctx, userID, []string{strings.TrimPrefix(link.ShortURL, "http://short/")})).To(Succeed())
+		_, _, err = shortener.Shorten(ctx, "http://example.com/3", userID)
+		Expect(err).To(BeNil())
+
+		quota, err := shortener.GetQuota(ctx, userID)
+		Expect(err).To(BeNil())
+		Expect(quota.Plan).To(Equal("free"))
+		Expect(quota.ActiveLinks).To(Equal(2))
+		Expect(quota.MonthlyLinks).To(Equal(3))
+		Expect(quota.ResetsAt.After(time.Now())).To(BeTrue())
+	})
+
+	It("should cap the links created a month and lift the caps on another plan", func() {
+		resp, err := shortener.ShortenBatch(ctx, models.BatchRequest{
+			{CorrelationID: "1", OriginalURL: "http://example.com/1"},
+			{CorrelationID: "2", OriginalURL: "http://example.com/2"},
+		}, userID, "")
+		Expect(err).To(BeNil())
+		Expect(resp[1].Status).To(Equal(models.BatchCreated))
+		Expect(shortener.DeleteMany(ctx, userID, []string{strings.TrimPrefix(resp[0].ShortURL, "http://short/")})).To(Succeed())
+		_, _, err = shortener.Shorten(ctx, "http://example.com/3", userID)
+		Expect(err).To(BeNil())
+		_, _, err = shortener.Shorten(ctx, "http://example.com/4", userID)
+		Expect(err).To(MatchError(service.ErrQuotaExceeded))
+
+		Expect(shortener.SetPlan(ctx, userID, "enterprise")).To(MatchError(service.ErrUnknownPlan))
+		Expect(shortener.SetPlan(ctx, "user", "pro")).To(MatchError(service.ErrInvalidUser))
+		Expect(shortener.SetPlan(ctx, userID, "pro")).To(Succeed())
+		_, _, err = shortener.Shorten(ctx, "http://example.com/4", userID)
+		Expect(err).To(BeNil())
+	})
+
+	It("should count the links of workspaces against the plan of their owner", func() {
+		_, err := shortener.ShortenBatch(ctx, models.BatchRequest{
+			{CorrelationID: "1", OriginalURL: "http://example.com/1"},
+			{CorrelationID: "2", OriginalURL: "http://example.com/2"},
+		}, userID, "")
+		Expect(err).To(BeNil())
+		for range 2 {
+			workspace, createErr := shortener.CreateWorkspace(ctx, userID, "Team")
+			Expect(createErr).To(BeNil())
+			_, _, createErr = shortener.Shorten(service.WithWorkspace(ctx, workspace.ID), "http://example.com/3", userID)
+			Expect(createErr).To(MatchError(service.ErrQuotaExceeded))
+		}
+
+		workspace, err := shortener.CreateWorkspace(ctx, userID, "Paid")
+		Expect(err).To(BeNil())
+		inWorkspace := service.WithWorkspace(ctx, workspace.ID)
+		quota, err := shortener.GetQuota(inWorkspace, userID)
+		Expect(err).To(BeNil())
+		Expect(quota.Plan).To(Equal("free"))
+		Expect(quota.ActiveLinks).To(Equal(2))
+
+		Expect(shortener.SetPlan(ctx, workspace.ID, "pro")).To(Succeed())
+		_, _, err = shortener.Shorten(inWorkspace, "http://example.com/3", userID)
+		Expect(err).To(BeNil())
+		quota, err = shortener.GetQuota(ctx, userID)
+		Expect(err).To(BeNil())
+		Expect(quota.ActiveLinks).To(Equal(2))
+		Expect(quota.MonthlyLinks).To(Equal(2))
+	})
+})
+
+var _ = Describe("Audit log", func() {
+	const (
+		userID   = "11111111-1111-1111-1111-111111111111"
+		memberID = "22222222-2222-2222-2222-222222222222"
+	)
+	var (
+		ctx       context.Context
+		store     *storage.MemoryStorage
+		shortener service.Shortener
+	)
+
+	BeforeEach(func() {
+		var err error
+		store, err = storage.NewMemoryStorage(context.Background())
+		Expect(err).To(BeNil())
+		shortener = service.NewShortener(store, store, store, store, "http://short",
+			service.WithRestorer(store, time.Hour), service.WithWorkspaces(store), service.WithAuditLog(store, time.Hour))
+		ctx = service.WithRequestInfo(context.Background(), models.RequestInfo{
+			Actor: userID, IP: "192.0.2.1", UserAgent: "test", Transport: models.TransportGRPC,
+		})
+	})
+
+	It("should record the changes of links with the request and the links before and after them", func() {
+		link, _, err := shortener.Shorten(ctx, "http://example.com/1", userID)
+		Expect(err).To(BeNil())
+		id := strings.TrimPrefix(link.ShortURL, "http://short/")
+		_, err = shortener.ShortenBatch(ctx, models.BatchRequest{{CorrelationID: "1", OriginalURL: "http://example.com/2"}}, userID, "")
+		Expect(err).To(BeNil())
+		Expect(shortener.DeleteMany(ctx, userID, []string{id, "missing"})).To(Succeed())
+		Expect(shortener.RestoreURL(ctx, userID, id)).To(Succeed())
+
+		entries, err := shortener.GetAuditLog(ctx, models.AuditFilter{Target: id})
+		Expect(err).To(BeNil())
+		Expect(entries).To(HaveLen(3))
+		Expect(entries[0].Action).To(Equal(models.AuditLinkRestored))
+		Expect(entries[1].Action).To(Equal(models.AuditLinkDeleted))
+		Expect(entries[2].Action).To(Equal(models.AuditLinkCreated))
+		for _, entry := range entries {
+			Expect(entry.Actor).To(Equal(userID))
+			Expect(entry.IP).To(Equal("192.0.2.1"))
+			Expect(entry.UserAgent).To(Equal("test"))
+			Expect(entry.Transport).To(Equal(models.TransportGRPC))
+		}
+		var before models.Link
+		Expect(json.Unmarshal(entries[1].Before, &before)).To(Succeed())
+		Expect(before.OriginalURL).To(Equal("http://example.com/1"))
+		Expect(entries[1].After).To(BeEmpty())
+
+		entries, err = shortener.GetAuditLog(ctx, models.AuditFilter{Action: models.AuditLinkCreated})
+		Expect(err).To(BeNil())
+		Expect(entries).To(HaveLen(2))
+	})
+
+	It("should record the changes of members of workspaces with their roles before and after", func() {
+		workspace, err := shortener.CreateWorkspace(ctx, userID, "Team")
+		Expect(err).To(BeNil())
+		_, err = shortener.SetMember(ctx, userID, workspace.ID, memberID, models.RoleViewer)
+		Expect(err).To(BeNil())
+		_, err = shortener.SetMember(ctx, userID, workspace.ID, memberID, models.RoleEditor)
+		Expect(err).To(BeNil())
+		Expect(shortener.RemoveMember(ctx, userID, workspace.ID, memberID)).To(Succeed())
+
+		entries, err := shortener.GetAuditLog(ctx, models.AuditFilter{Target: workspace.ID + "/" + memberID})
+		Expect(err).To(BeNil())
+		Expect(entries).To(HaveLen(3))
+		Expect(entries[0].Action).To(Equal(models.AuditMemberRemoved))
+		var member models.WorkspaceMember
+		Expect(json.Unmarshal(entries[1].Before, &member)).To(Succeed())
+		Expect(member.Role).To(Equal(models.RoleViewer))
+		Expect(json.Unmarshal(entries[1].After, &member)).To(Succeed())
+		Expect(member.Role).To(Equal(models.RoleEditor))
+		Expect(entries[2].Before).To(BeEmpty())
+	})
+
+	It("should validate filters and purge entries past the retention window", func() {
+		_, err := shortener.GetAuditLog(ctx, models.AuditFilter{Limit: service.MaxAuditLimit + 1})
+		Expect(err).To(MatchError(service.ErrInvalidAuditFilter))
+		_, err = shortener.GetAuditLog(ctx, models.AuditFilter{Since: time.Now(), Until: time.Now().Add(-time.Hour)})
+		Expect(err).To(MatchError(service.ErrInvalidAuditFilter))
+
+		Expect(store.SaveAuditEntries(ctx, []models.AuditEntry{
+			{ID: "old", Actor: userID, Action: models.AuditLinkCreated, CreatedAt: time.Now().Add(-2 * time.Hour)},
+		})).To(Succeed())
+		_, _, err = shortener.Shorten(ctx, "http://example.com/1", userID)
+		Expect(err).To(BeNil())
+		purged, err := shortener.PurgeAuditLog(ctx)
+		Expect(err).To(BeNil())
+		Expect(purged).To(Equal(int64(1)))
+		entries, err := shortener.GetAuditLog(ctx, models.AuditFilter{})
+		Expect(err).To(BeNil())
+		Expect(entries).To(HaveLen(1))
+
+		plain := service.NewShortener(store, store, store, store, "http://short")
+		_, err = plain.GetAuditLog(ctx, models.AuditFilter{})
+		Expect(err).To(MatchError(service.ErrUnsupported))
+	})
+})
@@ -5,9 +5,12 @@ import (
 	"context"
 	"encoding/base64"
 	"errors"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/grnsv/shortener/internal/logger"
 	"github.com/grnsv/shortener/internal/models"
+	"github.com/grnsv/shortener/internal/qr"
 	"github.com/grnsv/shortener/internal/storage"
 )
 
@@ -20,25 +23,45 @@ type Shortener interface {
 	URLShortener
 	BatchShortener
 	URLExpander
+	URLPreviewer
 	StoragePinger
 	URLLister
+	URLSearcher
+	URLTransferer
 	URLDeleter
+	URLRestorer
+	URLUpdater
 	StatsRetriever
+	URLUnlocker
+	QRCodeGenerator
+	APIKeyManager
+	APIKeyVerifier
+	WebhookManager
+	DomainManager
+	WorkspaceManager
+	QuotaManager
+	AuditLogger
 }
 
-// URLShortener provides a method to shorten a single URL.
+// URLShortener provides methods to shorten a single URL, returning either the short URL or the link.
 type URLShortener interface {
-	ShortenURL(ctx context.Context, url string, userID string) (shortURL string, alreadyExists bool, err error)
+	ShortenURL(ctx context.Context, url string, userID string, opts ...ShortenOption) (shortURL string, alreadyExists bool, err error)
+	Shorten(ctx context.Context, url string, userID string, opts ...ShortenOption) (link *models.URL, alreadyExists bool, err error)
 }
 
 // BatchShortener provides a method to shorten a batch of URLs.
 type BatchShortener interface {
-	ShortenBatch(ctx context.Context, longs models.BatchRequest, userID string) (models.BatchResponse, error)
+	// ShortenBatch shortens the URLs with the options applied to every link and reports the status of every item.
+	// Retrying a batch with the same non-empty idempotency key returns the original response.
+	ShortenBatch(ctx context.Context, longs models.BatchRequest, userID string, idempotencyKey string, opts ...ShortenOption) (models.BatchResponse, error)
 }
 
-// URLExpander provides a method to expand a shortened URL to its original form.
+// URLExpander provides a method to expand a shortened URL to its original form
+// and the HTTP status to redirect with, zero if the link has none of its own.
+// Password-protected links are not expanded and yield ErrPasswordRequired;
+// links with an interstitial page yield ErrInterstitial.
 type URLExpander interface {
-	ExpandURL(ctx context.Context, shortURL string) (string, error)
+	ExpandURL(ctx context.Context, shortURL string) (url string, redirectCode int, err error)
 }
 
 // StoragePinger provides a method to check the availability of the underlying storage.
@@ -46,9 +69,9 @@ type StoragePinger interface {
 	PingStorage(ctx context.Context) error
 }
 
-// URLLister provides a method to list all URLs for a user.
+// URLLister provides a method to list the URLs of a user matching the filter.
 type URLLister interface {
-	GetAll(ctx context.Context, userID string) ([]models.URL, error)
+	GetAll(ctx context.Context, userID string, filter models.URLFilter) ([]models.URL, error)
 }
 
 // URLDeleter provides a method to delete multiple shortened URLs for a user.
@@ -63,11 +86,75 @@ type StatsRetriever interface {
 
 // Service implements the Shortener interface and provides URL shortening services.
 type Service struct {
-	saver     storage.Saver
-	retriever storage.Retriever
-	deleter   storage.Deleter
-	pinger    storage.Pinger
-	BaseURL   string
+	saver          storage.Saver
+	retriever      storage.Retriever
+	deleter        storage.Deleter
+	pinger         storage.Pinger
+	restorer       storage.Restorer
+	retention      time.Duration
+	updater        storage.Updater
+	searcher       storage.Searcher
+	streamer       storage.Streamer
+	fetcher        MetadataFetcher
+	keys           storage.KeyStorage
+	webhooks       storage.WebhookStorage
+	domains        storage.DomainStorage
+	resolver       TXTResolver
+	workspaces     storage.WorkspaceStorage
+	quotas         storage.QuotaStorage
+	plans          map[string]models.Plan
+	defaultPlan    string
+	audit          storage.AuditStorage
+	auditRetention time.Duration
+	clicks         storage.ClickRecorder
+	attempts       *attemptLimiter
+	qrCodes        *qr.Cache
+	batches        *idempotencyCache
+	logger         logger.Logger
+	BaseURL        string
+}
+
+// Option is a function that applies an optional dependency to Service.
+type Option func(*Service)
+
+// WithKeyStorage sets the storage used for API keys.
+func WithKeyStorage(keys storage.KeyStorage) Option {
+	return func(s *Service) {
+		s.keys = keys
+	}
+}
+
+// WithRestorer sets the storage used to restore and purge deleted URLs
+// and how long deleted URLs can be restored.
+func WithRestorer(restorer storage.Restorer, retention time.Duration) Option {
+	return func(s *Service) {
+		s.restorer = restorer
+		if retention > 0 {
+			s.retention = retention
+		}
+	}
+}
+
+// WithUpdater sets the storage used to change destinations of short URLs.
+func WithUpdater(updater storage.Updater) Option {
+	return func(s *Service) {
+		s.updater = updater
+	}
+}
+
+// WithLogger sets the logger of errors that do not fail the operation causing them,
+// like those of queuing webhook events for a link that was already saved.
+func WithLogger(logger logger.Logger) Option {
+	return func(s *Service) {
+		s.logger = logger
+	}
+}
+
+// logError logs a non-nil error that does not fail the operation causing it, if the service has a logger.
+func (s *Service) logError(err error) {
+	if err != nil && s.logger != nil {
+		s.logger.Error(err)
+	}
 }
 
 // NewShortener creates a new Service implementing the Shortener interface.
@@ -77,14 +164,23 @@ func NewShortener(
 	deleter storage.Deleter,
 	pinger storage.Pinger,
 	BaseURL string,
+	opts ...Option,
 ) Shortener {
-	return &Service{
+	s := &Service{
 		saver:     saver,
 		retriever: retriever,
 		deleter:   deleter,
 		pinger:    pinger,
+		attempts:  newAttemptLimiter(MaxUnlockAttempts, UnlockWindow),
+		retention: defaultRetention,
+		qrCodes:   qr.NewCache(qrCacheSize),
+		batches:   newIdempotencyCache(IdempotencyKeyTTL, maxIdempotencyKeys),
 		BaseURL:   BaseURL,
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
 func (s *Service) generateShortURL(url string, userID string) models.URL {
@@ -92,54 +188,230 @@ func (s *Service) generateShortURL(url string, userID string) models.URL {
 	return models.URL{
 		UUID:        uuid.String(),
 		UserID:      userID,
-		ShortURL:    base64.URLEncoding.EncodeToString(uuid[:])[:shortURLLength],
+		ShortURL:    shortCode(uuid),
 		OriginalURL: url,
+		CreatedAt:   time.Now().UTC(),
 	}
 }
 
+// shortCode derives a short code from the ID of a link.
+func shortCode(id uuid.UUID) string {
+	return base64.URLEncoding.EncodeToString(id[:])[:shortURLLength]
+}
+
+// needsRandomShortURL reports whether the link differs from a plain link to its URL,
+// so it must not take the deterministic short URL.
+func needsRandomShortURL(model models.URL) bool {
+	return model.PasswordHash != "" || model.Interstitial || model.RedirectCode != 0 || !model.URLMetadata.IsZero()
+}
+
+// randomizeShortURL replaces the deterministic short URL of the model with a random one on the same domain.
+func randomizeShortURL(model *models.URL) {
+	id := uuid.New()
+	model.UUID = id.String()
+	domain, _ := models.SplitLinkKey(model.ShortURL)
+	model.ShortURL = models.LinkKey(domain, shortCode(id))
+}
+
 // ShortenURL shortens the given URL for the specified user and returns the shortened URL.
-func (s *Service) ShortenURL(ctx context.Context, url string, userID string) (shortURL string, alreadyExists bool, err error) {
-	model := s.generateShortURL(url, userID)
-	shortURL = s.BaseURL + "/" + model.ShortURL
-	err = s.saver.Save(ctx, model)
+// URLs other than absolute http and https ones are rejected with ErrInvalidURL.
+// Password-protected, interstitial, custom redirect code and described links get a random short URL
+// so they never collide with a plain link to the same URL.
+// Without a title, the title and description of the destination page are fetched in the background
+// if a MetadataFetcher is set.
+// A random short URL is also used when the deterministic one has been edited to point elsewhere.
+// Links shortened WithDomain must be on a verified domain of the user, or ErrDomainNotVerified is returned.
+// In an active workspace, see WithWorkspace, the link belongs to the workspace.
+// With quotas, see WithQuotas, ErrQuotaExceeded is returned when the plan of the owner allows no more links,
+// even if the link already exists.
+// Once the link is saved, counting it against the quota, queuing its webhook events and recording it
+// in the audit log cannot fail the request: their errors are logged, see WithLogger.
+func (s *Service) ShortenURL(ctx context.Context, url string, userID string, opts ...ShortenOption) (shortURL string, alreadyExists bool, err error) {
+	link, alreadyExists, err := s.Shorten(ctx, url, userID, opts...)
 	if err != nil {
-		if errors.Is(err, storage.ErrAlreadyExist) {
-			alreadyExists = true
-			err = nil
-		} else {
-			shortURL = ""
+		return "", false, err
+	}
+	return link.ShortURL, alreadyExists, nil
+}
+
+// Shorten shortens the given URL like ShortenURL and returns the link with its full short URL.
+// An already existing link may belong to another user, so only its short and original URLs are returned.
+func (s *Service) Shorten(ctx context.Context, url string, userID string, opts ...ShortenOption) (*models.URL, bool, error) {
+	if err := validateURL(url); err != nil {
+		return nil, false, err
+	}
+	owner, err := s.LinkOwner(ctx, userID, models.ScopeShorten)
+	if err != nil {
+		return nil, false, err
+	}
+	model := s.generateShortURL(url, owner)
+	for _, opt := range opts {
+		if err = opt(&model); err != nil {
+			return nil, false, err
 		}
 	}
+	if err = s.checkDomain(ctx, userID, model.ShortURL); err != nil {
+		return nil, false, err
+	}
+	if err = s.checkQuota(ctx, owner, 1); err != nil {
+		return nil, false, err
+	}
+	if needsRandomShortURL(model) {
+		randomizeShortURL(&model)
+	}
+
+	err = s.saver.Save(ctx, model)
+	if errors.Is(err, storage.ErrAlreadyExist) {
+		existing, getErr := s.retriever.Get(ctx, model.ShortURL)
+		if getErr == nil && existing.OriginalURL != url {
+			randomizeShortURL(&model)
+			err = s.saver.Save(ctx, model)
+		}
+	}
+	if errors.Is(err, storage.ErrAlreadyExist) {
+		return &models.URL{ShortURL: s.shortURL(model.ShortURL), OriginalURL: url}, true, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	s.logError(s.countUsage(ctx, owner, 1))
+	if model.Title == "" && s.fetcher != nil && s.updater != nil {
+		go s.fetchMetadata(owner, model.ShortURL, url)
+	}
 
-	return
+	model.ShortURL = s.shortURL(model.ShortURL)
+	model.PasswordHash = ""
+	s.logError(s.publish(ctx, owner, models.EventLinkCreated, model))
+	s.logError(s.record(ctx, userID, s.linkEntry(models.AuditLinkCreated, nil, &model)))
+	return &model, false, nil
 }
 
-// ShortenBatch shortens a batch of URLs for the specified user and returns the batch response.
-func (s *Service) ShortenBatch(ctx context.Context, longs models.BatchRequest, userID string) (models.BatchResponse, error) {
-	length := len(longs)
-	shorts := make([]models.BatchResponseItem, length)
-	urls := make([]models.URL, length)
+// ShortenBatch shortens a batch of URLs for the specified user and reports the status of every item:
+// created, already existing or invalid. New links are saved atomically, so a failed batch saves nothing.
+// Like ShortenURL, a random short URL is used when the deterministic one has been edited to point elsewhere.
+// A batch with a non-empty idempotency key returns the response of the first batch with the same key,
+// see idempotencyCache. Keys are scoped to the owner of the links, so members of a workspace share them.
+// A batch of more valid URLs than the plan of the owner allows fails with ErrQuotaExceeded.
+// Like in ShortenURL, errors after the links are saved are logged instead of failing the batch.
+// The options are applied to every link like in ShortenURL, so a batch WithDomain is shortened on a verified domain
+// of the user or fails with ErrDomainNotVerified.
+func (s *Service) ShortenBatch(ctx context.Context, longs models.BatchRequest, userID string, idempotencyKey string, opts ...ShortenOption) (models.BatchResponse, error) {
+	owner, err := s.LinkOwner(ctx, userID, models.ScopeShorten)
+	if err != nil {
+		return nil, err
+	}
+	if idempotencyKey == "" {
+		return s.shortenBatch(ctx, longs, userID, owner, opts)
+	}
+	if len(idempotencyKey) > MaxIdempotencyKeyLength {
+		return nil, ErrInvalidIdempotencyKey
+	}
+	return s.batches.do(ctx, owner+"\x00"+idempotencyKey, longs, func() (models.BatchResponse, error) {
+		return s.shortenBatch(ctx, longs, userID, owner, opts)
+	})
+}
+
+// shortenBatch shortens the URLs of a batch for the owner, recording the user who made the request as the actor.
+func (s *Service) shortenBatch(ctx context.Context, longs models.BatchRequest, actor string, userID string, opts []ShortenOption) (models.BatchResponse, error) {
+	shorts := make(models.BatchResponse, len(longs))
+	urls := make([]models.URL, 0, len(longs))
+	items := make([]int, 0, len(longs))
+	var created []models.URL
 
 	for i, long := range longs {
+		shorts[i].CorrelationID = long.CorrelationID
+		if err := validateURL(long.OriginalURL); err != nil {
+			shorts[i].Status, shorts[i].Error = models.BatchInvalid, err.Error()
+			continue
+		}
 		url := s.generateShortURL(long.OriginalURL, userID)
-		urls[i] = url
-		shorts[i] = models.BatchResponseItem{
-			CorrelationID: long.CorrelationID,
-			ShortURL:      s.BaseURL + "/" + url.ShortURL,
+		for _, opt := range opts {
+			if err := opt(&url); err != nil {
+				return nil, err
+			}
+		}
+		// Every link gets the same options, so checking the domain of the first one checks them all.
+		if len(urls) == 0 {
+			if err := s.checkDomain(ctx, actor, url.ShortURL); err != nil {
+				return nil, err
+			}
 		}
+		if needsRandomShortURL(url) {
+			randomizeShortURL(&url)
+		}
+		urls = append(urls, url)
+		items = append(items, i)
 	}
-
-	err := s.saver.SaveMany(ctx, urls)
-	if err != nil {
+	if err := s.checkQuota(ctx, userID, len(urls)); err != nil {
 		return nil, err
 	}
 
+	for len(urls) > 0 {
+		results, err := s.saver.SaveMany(ctx, urls)
+		if err != nil {
+			return nil, err
+		}
+
+		var retries []models.URL
+		var retryItems []int
+		for j, result := range results {
+			item := &shorts[items[j]]
+			item.ShortURL = s.shortURL(urls[j].ShortURL)
+			switch {
+			case result == nil:
+				item.Status = models.BatchCreated
+				url := urls[j]
+				url.ShortURL = item.ShortURL
+				created = append(created, url)
+			case !errors.Is(result, storage.ErrAlreadyExist):
+				return nil, result
+			case item.Status == "" && s.pointsElsewhere(ctx, urls[j]):
+				randomizeShortURL(&urls[j])
+				retries = append(retries, urls[j])
+				retryItems = append(retryItems, items[j])
+			default:
+				item.Status = models.BatchExists
+			}
+		}
+		// Retried items are marked as existing unless saved, so they are retried only once.
+		for _, i := range retryItems {
+			shorts[i].Status = models.BatchExists
+		}
+		urls, items = retries, retryItems
+	}
+
+	s.logError(s.countUsage(ctx, userID, len(created)))
+	s.logError(s.publish(ctx, userID, models.EventLinkCreated, created...))
+	entries := make([]models.AuditEntry, len(created))
+	for i := range created {
+		entries[i] = s.linkEntry(models.AuditLinkCreated, nil, &created[i])
+	}
+	s.logError(s.record(ctx, actor, entries...))
 	return shorts, nil
 }
 
-// ExpandURL expands the given shortened URL to its original URL.
-func (s *Service) ExpandURL(ctx context.Context, shortURL string) (string, error) {
-	return s.retriever.Get(ctx, shortURL)
+// pointsElsewhere reports whether the stored link with the short URL of model has another original URL.
+func (s *Service) pointsElsewhere(ctx context.Context, model models.URL) bool {
+	existing, err := s.retriever.Get(ctx, model.ShortURL)
+	return err == nil && existing.OriginalURL != model.OriginalURL
+}
+
+// ExpandURL expands the given shortened URL to its original URL and redirect status code.
+// Expired links yield ErrExpired.
+func (s *Service) ExpandURL(ctx context.Context, shortURL string) (string, int, error) {
+	url, err := s.getLiveURL(ctx, shortURL)
+	if err != nil {
+		return "", 0, err
+	}
+	if url.PasswordHash != "" {
+		return "", 0, ErrPasswordRequired
+	}
+	if url.Interstitial {
+		return "", 0, ErrInterstitial
+	}
+	s.recordClick(ctx, url)
+
+	return url.OriginalURL, url.RedirectCode, nil
 }
 
 // PingStorage checks the availability of the underlying storage.
@@ -147,23 +419,61 @@ func (s *Service) PingStorage(ctx context.Context) error {
 	return s.pinger.Ping(ctx)
 }
 
-// GetAll returns all URLs associated with the specified user.
-func (s *Service) GetAll(ctx context.Context, userID string) ([]models.URL, error) {
-	urls, err := s.retriever.GetAll(ctx, userID)
+// GetAll returns the URLs of the specified user, or of the active workspace, matching the filter.
+func (s *Service) GetAll(ctx context.Context, userID string, filter models.URLFilter) ([]models.URL, error) {
+	tags, err := normalizeTags(filter.Tags)
+	if err != nil {
+		return nil, err
+	}
+	filter.Tags = tags
+	owner, err := s.LinkOwner(ctx, userID, models.ScopeRead)
+	if err != nil {
+		return nil, err
+	}
+
+	urls, err := s.retriever.GetAll(ctx, owner, filter)
 	if err != nil {
 		return nil, err
 	}
 
 	for i := range urls {
-		urls[i].ShortURL = s.BaseURL + "/" + urls[i].ShortURL
+		urls[i].ShortURL = s.shortURL(urls[i].ShortURL)
+		urls[i].PasswordHash = ""
 	}
 
 	return urls, nil
 }
 
-// DeleteMany deletes multiple shortened URLs for the specified user.
+// DeleteMany soft-deletes multiple shortened URLs for the specified user, or of the active workspace.
+// They can be restored with RestoreURL until the retention window passes.
+// Webhooks subscribed to deletions are notified of the URLs of the user that were not deleted yet,
+// and the audit log records their deletion. Like in ShortenURL, their errors are logged instead of returned.
 func (s *Service) DeleteMany(ctx context.Context, userID string, shortURLs []string) error {
-	return s.deleter.DeleteMany(ctx, userID, shortURLs)
+	owner, err := s.LinkOwner(ctx, userID, models.ScopeDelete)
+	if err != nil {
+		return err
+	}
+	if s.webhooks == nil && s.audit == nil {
+		return s.deleter.DeleteMany(ctx, owner, shortURLs)
+	}
+
+	deleted, err := s.deletedByUser(ctx, owner, shortURLs)
+	if err != nil {
+		return err
+	}
+	if err = s.deleter.DeleteMany(ctx, owner, shortURLs); err != nil {
+		return err
+	}
+	if len(deleted) == 0 {
+		return nil
+	}
+	entries := make([]models.AuditEntry, len(deleted))
+	for i := range deleted {
+		entries[i] = s.linkEntry(models.AuditLinkDeleted, &deleted[i], nil)
+	}
+	s.logError(s.record(ctx, userID, entries...))
+	s.logError(s.publish(ctx, owner, models.EventLinkDeleted, deleted...))
+	return nil
 }
 
 // GetStats returns statistics about the service, such as the number of URLs and users.
@@ -0,0 +1,258 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"regexp"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/grnsv/shortener/internal/models"
+	"github.com/grnsv/shortener/internal/storage"
+)
+
+// Limits of imports.
+const (
+	MaxImportRecords = 100_000
+	MaxAliasLength   = 64
+)
+
+// importBatchSize is how many links are saved at once during an import.
+const importBatchSize = 500
+
+// Import errors.
+var (
+	ErrInvalidAlias   = errors.New("alias must be up to 64 letters, digits, '-' or '_' and not a reserved word")
+	ErrInvalidExpiry  = errors.New("expiry must be in the future")
+	ErrTooManyRecords = errors.New("too many records")
+)
+
+var aliasPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// reservedAliases collide with the routes of the HTTP API.
+var reservedAliases = []string{"api", "ping"}
+
+// LinkSeq is a sequence of link records. A record paired with an error is malformed;
+// its Row tells where it is in the file.
+type LinkSeq iter.Seq2[models.LinkRecord, error]
+
+// URLTransferer provides methods to import and export the links of a user in bulk.
+type URLTransferer interface {
+	ImportURLs(ctx context.Context, userID string, records LinkSeq) (*models.ImportReport, error)
+	ExportURLs(ctx context.Context, userID string) LinkSeq
+}
+
+// WithStreamer sets the storage used to export URLs.
+func WithStreamer(streamer storage.Streamer) Option {
+	return func(s *Service) {
+		s.streamer = streamer
+	}
+}
+
+// pendingImport is a valid record waiting to be saved with the next batch.
+type pendingImport struct {
+	row   int
+	model models.URL
+	alias bool
+}
+
+// ImportURLs saves the links of the records for the user in batches and reports the result of every record,
+// ordered by row. Links without an alias get the same short URL as shortening them would, so importing a file
+// again skips the links already imported; expiring links get a random one.
+// At most MaxImportRecords records are read. In an active workspace, the links belong to the workspace.
+// The error is only set if the context is done or the workspace cannot be used.
+func (s *Service) ImportURLs(ctx context.Context, userID string, records LinkSeq) (*models.ImportReport, error) {
+	owner, err := s.LinkOwner(ctx, userID, models.ScopeShorten)
+	if err != nil {
+		return nil, err
+	}
+	report := &models.ImportReport{Results: []models.ImportResult{}}
+	pending := make([]pendingImport, 0, importBatchSize)
+
+	var count int
+	for record, err := range records {
+		if count++; count > MaxImportRecords {
+			report.Error = ErrTooManyRecords.Error()
+			break
+		}
+		if err == nil {
+			err = s.prepareImport(owner, &record, &pending)
+		}
+		if err != nil {
+			report.Add(models.ImportResult{Row: record.Row, Status: models.ImportInvalid, Error: err.Error()})
+			continue
+		}
+
+		if len(pending) == importBatchSize {
+			s.importBatch(ctx, userID, owner, pending, report)
+			pending = pending[:0]
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	s.importBatch(ctx, userID, owner, pending, report)
+
+	slices.SortStableFunc(report.Results, func(a, b models.ImportResult) int {
+		return a.Row - b.Row
+	})
+	return report, ctx.Err()
+}
+
+// prepareImport validates a record and appends its link to pending.
+func (s *Service) prepareImport(userID string, record *models.LinkRecord, pending *[]pendingImport) error {
+	if err := validateURL(record.OriginalURL); err != nil {
+		return err
+	}
+	tags, err := normalizeTags(record.Tags)
+	if err != nil {
+		return err
+	}
+	if record.ExpiresAt != nil && !record.ExpiresAt.After(time.Now()) {
+		return ErrInvalidExpiry
+	}
+
+	model := s.generateShortURL(record.OriginalURL, userID)
+	if record.Alias != "" {
+		if len(record.Alias) > MaxAliasLength || !aliasPattern.MatchString(record.Alias) || slices.Contains(reservedAliases, record.Alias) {
+			return ErrInvalidAlias
+		}
+		model.UUID = uuid.NewString()
+		model.ShortURL = record.Alias
+	} else if record.ExpiresAt != nil {
+		randomizeShortURL(&model)
+	}
+	model.Tags = tags
+	model.ExpiresAt = record.ExpiresAt
+
+	*pending = append(*pending, pendingImport{row: record.Row, model: model, alias: record.Alias != ""})
+	return nil
+}
+
+// importBatch saves pending links that are not taken yet and adds their results to the report.
+// Links taken since they were looked up are reported as conflicts. A batch of more links than the plan
+// of the owner allows fails with ErrQuotaExceeded. The actor is the user importing the links.
+func (s *Service) importBatch(ctx context.Context, actor string, userID string, pending []pendingImport, report *models.ImportReport) {
+	var batch []pendingImport
+	taken := make(map[string]models.URL, len(pending))
+	for _, p := range pending {
+		result := models.ImportResult{Row: p.row}
+		existing, found, err := s.lookupShortURL(ctx, p.model.ShortURL, taken)
+		switch {
+		case err != nil:
+			result.Status, result.Error = models.ImportFailed, err.Error()
+		case found && existing.UserID == userID && existing.OriginalURL == p.model.OriginalURL:
+			result.Status, result.ShortURL = models.ImportExists, s.shortURL(p.model.ShortURL)
+		case found && p.alias:
+			result.Status, result.Error = models.ImportConflict, storage.ErrAlreadyExist.Error()
+		default:
+			if found {
+				randomizeShortURL(&p.model)
+			}
+			taken[p.model.ShortURL] = p.model
+			batch = append(batch, p)
+			continue
+		}
+		report.Add(result)
+	}
+	if len(batch) == 0 {
+		return
+	}
+
+	urls := make([]models.URL, len(batch))
+	for i, p := range batch {
+		urls[i] = p.model
+	}
+	results, err := s.saveImport(ctx, actor, userID, urls)
+	for i, p := range batch {
+		result := models.ImportResult{Row: p.row, Status: models.ImportCreated, ShortURL: s.shortURL(p.model.ShortURL)}
+		switch {
+		case err != nil:
+			result.Status, result.ShortURL, result.Error = models.ImportFailed, "", err.Error()
+		case errors.Is(results[i], storage.ErrAlreadyExist):
+			result.Status, result.ShortURL, result.Error = models.ImportConflict, "", results[i].Error()
+		case results[i] != nil:
+			result.Status, result.ShortURL, result.Error = models.ImportFailed, "", results[i].Error()
+		}
+		report.Add(result)
+	}
+}
+
+// saveImport saves the links of a batch within the quota of the owner, counts the links created
+// and records them in the audit log as created by the actor.
+func (s *Service) saveImport(ctx context.Context, actor string, userID string, urls []models.URL) ([]error, error) {
+	if err := s.checkQuota(ctx, userID, len(urls)); err != nil {
+		return nil, err
+	}
+	results, err := s.saver.SaveMany(ctx, urls)
+	if err != nil {
+		return nil, err
+	}
+	var entries []models.AuditEntry
+	for i, result := range results {
+		if result == nil {
+			url := urls[i]
+			url.ShortURL = s.shortURL(url.ShortURL)
+			entries = append(entries, s.linkEntry(models.AuditLinkCreated, nil, &url))
+		}
+	}
+	s.logError(s.countUsage(ctx, userID, len(entries)))
+	s.logError(s.record(ctx, actor, entries...))
+	return results, nil
+}
+
+// lookupShortURL reports whether a short URL is taken, either in the storage or earlier in the batch.
+// Deleted links keep their short URL, so they are found too, but without their owner.
+func (s *Service) lookupShortURL(ctx context.Context, shortURL string, taken map[string]models.URL) (models.URL, bool, error) {
+	if model, ok := taken[shortURL]; ok {
+		return model, true, nil
+	}
+
+	existing, err := s.retriever.Get(ctx, shortURL)
+	switch {
+	case err == nil:
+		return existing, true, nil
+	case errors.Is(err, storage.ErrDeleted):
+		return models.URL{}, true, nil
+	case errors.Is(err, storage.ErrNotFound):
+		return models.URL{}, false, nil
+	default:
+		return models.URL{}, false, err
+	}
+}
+
+// ExportURLs streams the links of the user, except deleted ones, as records that ImportURLs accepts:
+// the short code of each link is its alias. In an active workspace, the links of the workspace are exported.
+func (s *Service) ExportURLs(ctx context.Context, userID string) LinkSeq {
+	return func(yield func(models.LinkRecord, error) bool) {
+		if s.streamer == nil {
+			yield(models.LinkRecord{}, ErrUnsupported)
+			return
+		}
+		owner, err := s.LinkOwner(ctx, userID, models.ScopeRead)
+		if err != nil {
+			yield(models.LinkRecord{}, err)
+			return
+		}
+
+		for url, err := range s.streamer.IterateAll(ctx, owner) {
+			if err != nil {
+				yield(models.LinkRecord{}, err)
+				return
+			}
+			record := models.LinkRecord{
+				OriginalURL: url.OriginalURL,
+				Alias:       url.ShortURL,
+				Tags:        url.Tags,
+				ExpiresAt:   url.ExpiresAt,
+				ShortURL:    s.shortURL(url.ShortURL),
+				Title:       url.Title,
+			}
+			if !yield(record, nil) {
+				return
+			}
+		}
+	}
+}
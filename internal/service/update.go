@@ -0,0 +1,128 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"time"
+
+	"github.com/grnsv/shortener/internal/models"
+	"github.com/grnsv/shortener/internal/storage"
+)
+
+// ErrInvalidURL is returned when a destination is not an absolute http(s) URL.
+var ErrInvalidURL = errors.New("invalid URL")
+
+// URLUpdater provides methods to change the destination, redirect status code and metadata of a user's short URL.
+type URLUpdater interface {
+	UpdateURL(ctx context.Context, userID string, shortURL string, req models.UpdateURLRequest) (*models.URL, error)
+	GetURLHistory(ctx context.Context, userID string, shortURL string) ([]models.URLHistory, error)
+}
+
+// UpdateURL changes the destination, redirect status code and/or metadata of a short URL owned by the user
+// or the active workspace.
+// All changes are applied at once, keeping the previous destination in the URL's history.
+// A request changing nothing yields ErrInvalidURL.
+// Changes are recorded in the audit log with the link before and after them.
+func (s *Service) UpdateURL(ctx context.Context, userID string, shortURL string, req models.UpdateURLRequest) (*models.URL, error) {
+	if s.updater == nil {
+		return nil, ErrUnsupported
+	}
+	hasMetadata := req.Title != nil || req.Description != nil || req.Notes != nil || req.Tags != nil
+	if req.URL != "" || (req.RedirectCode == 0 && !hasMetadata) {
+		if err := validateURL(req.URL); err != nil {
+			return nil, err
+		}
+	}
+	if req.RedirectCode != 0 && !models.ValidRedirectCode(req.RedirectCode) {
+		return nil, ErrInvalidRedirectCode
+	}
+	owner, err := s.LinkOwner(ctx, userID, models.ScopeUpdate)
+	if err != nil {
+		return nil, err
+	}
+
+	model, err := s.getOwnURL(ctx, owner, shortURL)
+	if err != nil {
+		return nil, err
+	}
+	before := model
+	var patch models.URLPatch
+	metadataChanged, err := updateMetadata(&model.URLMetadata, req)
+	if err != nil {
+		return nil, err
+	}
+	if metadataChanged {
+		patch.Metadata = &model.URLMetadata
+	}
+	if req.URL != "" && model.OriginalURL != req.URL {
+		patch.OriginalURL, model.OriginalURL = req.URL, req.URL
+	}
+	if req.RedirectCode != 0 && model.RedirectCode != req.RedirectCode {
+		patch.RedirectCode, model.RedirectCode = req.RedirectCode, req.RedirectCode
+	}
+	changed := patch != models.URLPatch{}
+	if changed {
+		if err = s.updater.PatchURL(ctx, owner, shortURL, patch, time.Now().UTC()); err != nil {
+			return nil, err
+		}
+	}
+
+	model.ShortURL = s.shortURL(model.ShortURL)
+	model.PasswordHash = ""
+	if changed {
+		before.ShortURL = model.ShortURL
+		s.logError(s.record(ctx, userID, s.linkEntry(models.AuditLinkUpdated, &before, &model)))
+	}
+	return &model, nil
+}
+
+// GetURLHistory returns the previous destinations of a short URL owned by the user or the active workspace,
+// oldest first.
+func (s *Service) GetURLHistory(ctx context.Context, userID string, shortURL string) ([]models.URLHistory, error) {
+	if s.updater == nil {
+		return nil, ErrUnsupported
+	}
+	owner, err := s.LinkOwner(ctx, userID, models.ScopeRead)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = s.getOwnURL(ctx, owner, shortURL); err != nil {
+		return nil, err
+	}
+
+	history, err := s.updater.GetURLHistory(ctx, shortURL)
+	if err != nil {
+		return nil, err
+	}
+	for i := range history {
+		history[i].ShortURL = s.shortURL(history[i].ShortURL)
+	}
+
+	return history, nil
+}
+
+// getOwnURL retrieves a short URL and hides it from users who do not own it.
+func (s *Service) getOwnURL(ctx context.Context, userID string, shortURL string) (models.URL, error) {
+	model, err := s.retriever.Get(ctx, shortURL)
+	if err != nil {
+		if errors.Is(err, storage.ErrDeleted) {
+			return models.URL{}, storage.ErrNotFound
+		}
+		return models.URL{}, err
+	}
+	if model.UserID != userID {
+		return models.URL{}, storage.ErrNotFound
+	}
+
+	return model, nil
+}
+
+// validateURL checks that raw is an absolute http or https URL with a host.
+func validateURL(raw string) error {
+	u, err := url.ParseRequestURI(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ErrInvalidURL
+	}
+	return nil
+}
@@ -0,0 +1,269 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/grnsv/shortener/internal/models"
+	"github.com/grnsv/shortener/internal/storage"
+)
+
+const (
+	// MaxWebhooks is the number of webhooks a user can have.
+	MaxWebhooks = 10
+	// MaxWebhookDeliveries is the number of latest deliveries returned by GetWebhookDeliveries.
+	MaxWebhookDeliveries = 100
+
+	webhookSecretPrefix = "whsec_"
+	webhookSecretBytes  = 32
+)
+
+// Webhook error variables.
+var (
+	ErrInvalidEvent    = errors.New("invalid event")
+	ErrTooManyWebhooks = errors.New("too many webhooks")
+)
+
+// WebhookManager provides methods for managing webhooks of a user and inspecting their deliveries.
+type WebhookManager interface {
+	// CreateWebhook subscribes url to the events, all if none is given. The returned webhook
+	// has the secret signing its requests, which is not returned afterwards.
+	CreateWebhook(ctx context.Context, userID string, url string, events []string) (*models.Webhook, error)
+	GetWebhooks(ctx context.Context, userID string) ([]models.Webhook, error)
+	DeleteWebhook(ctx context.Context, userID string, id string) error
+	// GetWebhookDeliveries returns the latest deliveries of a webhook of the user, newest first.
+	GetWebhookDeliveries(ctx context.Context, userID string, id string) ([]models.WebhookDelivery, error)
+}
+
+// WithWebhooks enables webhooks: the storage keeps them and queues their deliveries,
+// and the click recorder tells when links are followed for the first time.
+// Deliveries are sent by a webhook.Dispatcher reading the same storage.
+func WithWebhooks(webhooks storage.WebhookStorage, clicks storage.ClickRecorder) Option {
+	return func(s *Service) {
+		s.webhooks = webhooks
+		s.clicks = clicks
+	}
+}
+
+// CreateWebhook creates a webhook of the user with a random secret.
+func (s *Service) CreateWebhook(ctx context.Context, userID string, url string, events []string) (*models.Webhook, error) {
+	if s.webhooks == nil {
+		return nil, ErrUnsupported
+	}
+	if err := validateURL(url); err != nil {
+		return nil, err
+	}
+	for _, event := range events {
+		if !models.AllEvents.Has(event) {
+			return nil, ErrInvalidEvent
+		}
+	}
+	if len(events) == 0 {
+		events = models.AllEvents
+	}
+
+	hooks, err := s.webhooks.GetWebhooks(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(hooks) >= MaxWebhooks {
+		return nil, ErrTooManyWebhooks
+	}
+
+	raw := make([]byte, webhookSecretBytes)
+	if _, err = rand.Read(raw); err != nil {
+		return nil, err
+	}
+	hook := models.Webhook{
+		ID:        uuid.NewString(),
+		UserID:    userID,
+		URL:       url,
+		Secret:    webhookSecretPrefix + base64.RawURLEncoding.EncodeToString(raw),
+		Events:    slices.Compact(slices.Sorted(slices.Values(events))),
+		CreatedAt: time.Now().UTC(),
+	}
+	if err = s.webhooks.SaveWebhook(ctx, hook); err != nil {
+		return nil, err
+	}
+	entry := models.AuditEntry{Action: models.AuditWebhookCreated, Target: hook.ID, After: auditValue(publicWebhook(hook))}
+	s.logError(s.record(ctx, userID, entry))
+
+	hook.UserID = ""
+	return &hook, nil
+}
+
+// GetWebhooks returns all webhooks of the user without their secrets.
+func (s *Service) GetWebhooks(ctx context.Context, userID string) ([]models.Webhook, error) {
+	if s.webhooks == nil {
+		return nil, ErrUnsupported
+	}
+	hooks, err := s.webhooks.GetWebhooks(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range hooks {
+		hooks[i] = publicWebhook(hooks[i])
+	}
+
+	return hooks, nil
+}
+
+// DeleteWebhook deletes a webhook of the user with its pending and past deliveries.
+func (s *Service) DeleteWebhook(ctx context.Context, userID string, id string) error {
+	if s.webhooks == nil {
+		return ErrUnsupported
+	}
+	if _, err := uuid.Parse(id); err != nil {
+		return storage.ErrNotFound
+	}
+	if err := s.webhooks.DeleteWebhook(ctx, userID, id); err != nil {
+		return err
+	}
+	s.logError(s.record(ctx, userID, models.AuditEntry{Action: models.AuditWebhookDeleted, Target: id}))
+	return nil
+}
+
+// GetWebhookDeliveries returns up to MaxWebhookDeliveries latest deliveries of a webhook of the user.
+func (s *Service) GetWebhookDeliveries(ctx context.Context, userID string, id string) ([]models.WebhookDelivery, error) {
+	if s.webhooks == nil {
+		return nil, ErrUnsupported
+	}
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, storage.ErrNotFound
+	}
+	hook, err := s.webhooks.GetWebhook(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if hook.UserID != userID {
+		return nil, storage.ErrNotFound
+	}
+
+	return s.webhooks.GetDeliveries(ctx, id, MaxWebhookDeliveries)
+}
+
+// subscribers returns the webhooks subscribed to the event of the owner of links, none if webhooks are disabled.
+// Webhooks belong to users, so the events of the links of a workspace go to the webhooks of all its members.
+func (s *Service) subscribers(ctx context.Context, owner string, event string) ([]models.Webhook, error) {
+	if s.webhooks == nil {
+		return nil, nil
+	}
+	users := []string{owner}
+	if s.workspaces != nil {
+		members, err := s.workspaces.GetMembers(ctx, owner)
+		if err != nil {
+			return nil, err
+		}
+		if len(members) > 0 {
+			users = users[:0]
+			for _, member := range members {
+				users = append(users, member.UserID)
+			}
+		}
+	}
+
+	var subscribed []models.Webhook
+	for _, user := range users {
+		hooks, err := s.webhooks.GetWebhooks(ctx, user)
+		if err != nil {
+			return nil, err
+		}
+		subscribed = append(subscribed, slices.DeleteFunc(hooks, func(hook models.Webhook) bool {
+			return !hook.Events.Has(event)
+		})...)
+	}
+	return subscribed, nil
+}
+
+// publish queues a delivery of the event to every webhook subscribed to it, see subscribers, for each of the URLs,
+// which must have full short URLs.
+func (s *Service) publish(ctx context.Context, userID string, event string, urls ...models.URL) error {
+	if len(urls) == 0 {
+		return nil
+	}
+	hooks, err := s.subscribers(ctx, userID, event)
+	if err != nil || len(hooks) == 0 {
+		return err
+	}
+	return s.enqueue(ctx, hooks, event, urls)
+}
+
+// enqueue queues a delivery of the event to every webhook for each of the URLs.
+func (s *Service) enqueue(ctx context.Context, hooks []models.Webhook, event string, urls []models.URL) error {
+	now := time.Now().UTC()
+	deliveries := make([]models.WebhookDelivery, 0, len(hooks)*len(urls))
+	for _, hook := range hooks {
+		for _, url := range urls {
+			id := uuid.NewString()
+			payload, err := json.Marshal(models.WebhookEvent{
+				ID:        id,
+				Event:     event,
+				CreatedAt: now,
+				Link:      models.NewLink(url, s.BaseURL),
+			})
+			if err != nil {
+				return err
+			}
+			deliveries = append(deliveries, models.WebhookDelivery{
+				ID:            id,
+				WebhookID:     hook.ID,
+				Event:         event,
+				Payload:       payload,
+				Status:        models.DeliveryPending,
+				CreatedAt:     now,
+				NextAttemptAt: &now,
+			})
+		}
+	}
+	return s.webhooks.SaveDeliveries(ctx, deliveries)
+}
+
+// recordClick publishes the first click of a link if it has not been clicked before.
+// It is best-effort, so that following links never fails because of webhooks.
+func (s *Service) recordClick(ctx context.Context, url models.URL) {
+	if s.clicks == nil || url.ClickedAt != nil {
+		return
+	}
+	now := time.Now().UTC()
+	first, err := s.clicks.RecordFirstClick(ctx, url.ShortURL, now)
+	if err != nil || !first {
+		s.logError(err)
+		return
+	}
+	url.ClickedAt = &now
+	url.ShortURL = s.shortURL(url.ShortURL)
+	s.logError(s.publish(ctx, url.UserID, models.EventLinkFirstClicked, url))
+}
+
+// deletedByUser returns the URLs of the user among shortURLs that DeleteMany is going to delete,
+// with full short URLs. Missing, deleted and foreign URLs are skipped.
+func (s *Service) deletedByUser(ctx context.Context, userID string, shortURLs []string) ([]models.URL, error) {
+	var urls []models.URL
+	for _, short := range slices.Compact(slices.Sorted(slices.Values(shortURLs))) {
+		url, err := s.retriever.Get(ctx, short)
+		if errors.Is(err, storage.ErrNotFound) || errors.Is(err, storage.ErrDeleted) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if url.UserID == userID {
+			url.ShortURL = s.shortURL(url.ShortURL)
+			urls = append(urls, url)
+		}
+	}
+	return urls, nil
+}
+
+func publicWebhook(hook models.Webhook) models.Webhook {
+	hook.UserID = ""
+	hook.Secret = ""
+	return hook
+}
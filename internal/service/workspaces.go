@@ -0,0 +1,201 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/grnsv/shortener/internal/models"
+	"github.com/grnsv/shortener/internal/storage"
+)
+
+// MaxWorkspaceNameLength is the maximum length of a workspace name in characters.
+const MaxWorkspaceNameLength = 100
+
+// Workspace error variables.
+var (
+	ErrInvalidWorkspace     = errors.New("workspace ID must be a UUID")
+	ErrInvalidWorkspaceName = errors.New("workspace name must be 1 to 100 characters")
+	ErrInvalidMember        = errors.New("member must be a user ID")
+	ErrInvalidRole          = errors.New("role must be owner, editor or viewer")
+	ErrNotMember            = errors.New("user is not a member of the workspace")
+	ErrInsufficientRole     = errors.New("role in the workspace does not allow this operation")
+	ErrMemberNotFound       = errors.New("member not found")
+)
+
+// WorkspaceManager provides methods for managing workspaces and their members.
+type WorkspaceManager interface {
+	// CreateWorkspace creates a workspace owned by the user.
+	CreateWorkspace(ctx context.Context, userID string, name string) (*models.Workspace, error)
+	// GetWorkspaces returns the workspaces the user is a member of with the role of the user.
+	GetWorkspaces(ctx context.Context, userID string) ([]models.Workspace, error)
+	// GetMembers returns the members of a workspace of the user.
+	GetMembers(ctx context.Context, userID string, workspaceID string) ([]models.WorkspaceMember, error)
+	// SetMember adds a user to a workspace owned by the user or changes the role of a member.
+	SetMember(ctx context.Context, userID string, workspaceID string, memberID string, role string) (*models.WorkspaceMember, error)
+	// RemoveMember removes a member from a workspace owned by the user. Any member can remove themselves.
+	RemoveMember(ctx context.Context, userID string, workspaceID string, memberID string) error
+	// LinkOwner returns the owner of the links the user manages in ctx, see WithWorkspace.
+	LinkOwner(ctx context.Context, userID string, scope string) (string, error)
+}
+
+// WithWorkspaces sets the storage used for workspaces and their members.
+func WithWorkspaces(workspaces storage.WorkspaceStorage) Option {
+	return func(s *Service) {
+		s.workspaces = workspaces
+	}
+}
+
+// workspaceContextKey is the context key of the active workspace.
+type workspaceContextKey struct{}
+
+// WithWorkspace returns a copy of ctx making the workspace with the ID active: the links of the user are then
+// the links of the workspace, which the user may list, shorten, update and delete as far as their role allows.
+// Without an active workspace, users manage their own links.
+func WithWorkspace(ctx context.Context, workspaceID string) context.Context {
+	return context.WithValue(ctx, workspaceContextKey{}, workspaceID)
+}
+
+// LinkOwner returns the owner of the links the user manages in ctx: the active workspace, if the role of the user
+// in it allows the operations the API key scope is needed for, or the user. Passed as the user ID without
+// an active workspace, the owner lets work outliving the request, such as asynchronous deletion, act on the same links.
+func (s *Service) LinkOwner(ctx context.Context, userID string, scope string) (string, error) {
+	workspaceID, ok := ctx.Value(workspaceContextKey{}).(string)
+	if !ok {
+		return userID, nil
+	}
+	member, err := s.member(ctx, workspaceID, userID)
+	if err != nil {
+		return "", err
+	}
+	if !models.RoleAllows(member.Role, scope) {
+		return "", ErrInsufficientRole
+	}
+	return workspaceID, nil
+}
+
+// member returns the membership of the user in a workspace, or ErrNotMember.
+func (s *Service) member(ctx context.Context, workspaceID string, userID string) (models.WorkspaceMember, error) {
+	if s.workspaces == nil {
+		return models.WorkspaceMember{}, ErrUnsupported
+	}
+	if uuid.Validate(workspaceID) != nil {
+		return models.WorkspaceMember{}, ErrInvalidWorkspace
+	}
+	member, err := s.workspaces.GetMember(ctx, workspaceID, userID)
+	if errors.Is(err, storage.ErrNotFound) {
+		return models.WorkspaceMember{}, ErrNotMember
+	}
+	return member, err
+}
+
+// owns checks that the user is an owner of the workspace.
+func (s *Service) owns(ctx context.Context, userID string, workspaceID string) error {
+	member, err := s.member(ctx, workspaceID, userID)
+	if err != nil {
+		return err
+	}
+	if member.Role != models.RoleOwner {
+		return ErrInsufficientRole
+	}
+	return nil
+}
+
+// CreateWorkspace creates a workspace with a random ID and the user as its owner.
+func (s *Service) CreateWorkspace(ctx context.Context, userID string, name string) (*models.Workspace, error) {
+	if s.workspaces == nil {
+		return nil, ErrUnsupported
+	}
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > MaxWorkspaceNameLength {
+		return nil, ErrInvalidWorkspaceName
+	}
+
+	now := time.Now().UTC()
+	workspace := models.Workspace{ID: uuid.NewString(), Name: name, CreatedAt: now}
+	owner := models.WorkspaceMember{WorkspaceID: workspace.ID, UserID: userID, Role: models.RoleOwner, AddedAt: now}
+	if err := s.workspaces.CreateWorkspace(ctx, workspace, owner); err != nil {
+		return nil, err
+	}
+
+	workspace.Role = models.RoleOwner
+	entry := models.AuditEntry{Action: models.AuditWorkspaceCreated, Target: workspace.ID, After: auditValue(workspace)}
+	s.logError(s.record(ctx, userID, entry))
+	return &workspace, nil
+}
+
+// GetWorkspaces returns the workspaces of the user, oldest first.
+func (s *Service) GetWorkspaces(ctx context.Context, userID string) ([]models.Workspace, error) {
+	if s.workspaces == nil {
+		return nil, ErrUnsupported
+	}
+	return s.workspaces.GetWorkspaces(ctx, userID)
+}
+
+// GetMembers returns the members of a workspace the user is a member of, oldest first.
+func (s *Service) GetMembers(ctx context.Context, userID string, workspaceID string) ([]models.WorkspaceMember, error) {
+	if _, err := s.member(ctx, workspaceID, userID); err != nil {
+		return nil, err
+	}
+	return s.workspaces.GetMembers(ctx, workspaceID)
+}
+
+// SetMember adds a user to a workspace owned by the user with the role, or changes the role of a member.
+// The last owner cannot give up the role, which yields storage.ErrLastOwner.
+func (s *Service) SetMember(ctx context.Context, userID string, workspaceID string, memberID string, role string) (*models.WorkspaceMember, error) {
+	if !models.ValidRole(role) {
+		return nil, ErrInvalidRole
+	}
+	if uuid.Validate(memberID) != nil {
+		return nil, ErrInvalidMember
+	}
+	if err := s.owns(ctx, userID, workspaceID); err != nil {
+		return nil, err
+	}
+
+	entry := models.AuditEntry{Action: models.AuditMemberSet, Target: workspaceID + "/" + memberID}
+	previous, err := s.workspaces.GetMember(ctx, workspaceID, memberID)
+	switch {
+	case err == nil:
+		entry.Before = auditValue(previous)
+	case !errors.Is(err, storage.ErrNotFound):
+		return nil, err
+	}
+
+	member := models.WorkspaceMember{WorkspaceID: workspaceID, UserID: memberID, Role: role, AddedAt: time.Now().UTC()}
+	if err = s.workspaces.SaveMember(ctx, member); err != nil {
+		return nil, err
+	}
+	saved, err := s.workspaces.GetMember(ctx, workspaceID, memberID)
+	if err != nil {
+		return nil, err
+	}
+	entry.After = auditValue(saved)
+	s.logError(s.record(ctx, userID, entry))
+	return &saved, nil
+}
+
+// RemoveMember removes a member from a workspace owned by the user, or the user from a workspace they are a member of.
+// The last owner cannot be removed, which yields storage.ErrLastOwner.
+func (s *Service) RemoveMember(ctx context.Context, userID string, workspaceID string, memberID string) error {
+	if memberID == userID {
+		if _, err := s.member(ctx, workspaceID, userID); err != nil {
+			return err
+		}
+	} else if err := s.owns(ctx, userID, workspaceID); err != nil {
+		return err
+	}
+
+	err := s.workspaces.DeleteMember(ctx, workspaceID, memberID)
+	if errors.Is(err, storage.ErrNotFound) {
+		return ErrMemberNotFound
+	}
+	if err != nil {
+		return err
+	}
+	s.logError(s.record(ctx, userID, models.AuditEntry{Action: models.AuditMemberRemoved, Target: workspaceID + "/" + memberID}))
+	return nil
+}
@@ -2,6 +2,13 @@ package storage
 
 import (
 	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/grnsv/shortener/internal/models"
 	"github.com/jmoiron/sqlx"
@@ -29,18 +36,112 @@ type StmtWrapper struct {
 }
 
 // DBStorage provides methods to interact with the URLs database.
+// Statements creating, changing or deleting URLs also record the changes in the outbox, see Outbox,
+// unless it is created WithoutOutbox.
 type DBStorage struct {
-	db           DB
-	saveStmt     Stmt
-	getAllStmt   Stmt
-	getStmt      Stmt
-	deleteStmt   Stmt
-	getStatsStmt Stmt
+	db                 DB
+	withoutOutbox      bool
+	trigram            bool
+	saveStmt           Stmt
+	getAllStmt         Stmt
+	getStmt            Stmt
+	deleteStmt         Stmt
+	getStatsStmt       Stmt
+	saveKeyStmt        Stmt
+	getKeysStmt        Stmt
+	getKeyByHashStmt   Stmt
+	revokeKeyStmt      Stmt
+	touchKeyStmt       Stmt
+	patchStmt          Stmt
+	getHistoryStmt     Stmt
+	restoreStmt        Stmt
+	purgeStmt          Stmt
+	setMetadataStmt    Stmt
+	searchStmt         Stmt
+	iterateStmt        Stmt
+	recordClickStmt    Stmt
+	saveHookStmt       Stmt
+	getHookStmt        Stmt
+	getHooksStmt       Stmt
+	deleteHookStmt     Stmt
+	claimStmt          Stmt
+	getDeliveriesStmt  Stmt
+	updateDeliveryStmt Stmt
+	leaseOutboxStmt    Stmt
+	getChangesStmt     Stmt
+	deleteChangesStmt  Stmt
+	saveDomainStmt     Stmt
+	getDomainsStmt     Stmt
+	getDomainStmt      Stmt
+	verifyDomainStmt   Stmt
+	saveWorkspaceStmt  Stmt
+	getWorkspacesStmt  Stmt
+	getMemberStmt      Stmt
+	getMembersStmt     Stmt
+	saveMemberStmt     Stmt
+	deleteMemberStmt   Stmt
+	getPlanStmt        Stmt
+	setPlanStmt        Stmt
+	countActiveStmt    Stmt
+	getUsageStmt       Stmt
+	addUsageStmt       Stmt
+	getAuditStmt       Stmt
+	purgeAuditStmt     Stmt
+}
+
+// uniqueViolation is the PostgreSQL error code of unique constraint violations.
+const uniqueViolation = "23505"
+
+// searchDocumentSQL is the text searched for a URL: its short code, destination, title and tags,
+// lowercased with runs of other characters than letters and digits replaced by a space, as searchTerms splits them.
+// Queries must use the same expression for the search indexes to apply.
+const searchDocumentSQL = `regexp_replace(lower(short_url || ' ' || original_url || ' ' || title || ' ' || tags), '[^[:alnum:]]+', ' ', 'g')`
+
+// changePayloadSQL is the state of a URL recorded in the outbox: a JSON object with the fields of models.URL
+// except the password hash.
+const changePayloadSQL = `json_build_object(
+	'user_id', user_id, 'short_url', short_url, 'original_url', original_url,
+	'interstitial', interstitial, 'redirect_code', redirect_code,
+	'is_deleted', is_deleted, 'deleted_at', deleted_at, 'expires_at', expires_at,
+	'created_at', created_at, 'clicked_at', clicked_at,
+	'title', title, 'description', description, 'notes', notes, 'tags', string_to_array(NULLIF(tags, ''), ',')
+)`
+
+// recordChangesSQL returns a statement recording the event in the outbox for every URL returned by source,
+// a data-modifying CTE. Run in the same statement as the change, it commits or rolls back with it.
+// The sequence number is drawn after the change locked the URL, so changes of a short code are numbered
+// in the order they are committed.
+// Without the outbox it only selects the URLs, so the statement affects as many rows either way.
+func (s *DBStorage) recordChangesSQL(event string, source string) string {
+	if s.withoutOutbox {
+		return `
+		SELECT short_url FROM ` + source
+	}
+
+	return `
+		INSERT INTO outbox (event, short_url, user_id, payload)
+		SELECT '` + event + `', short_url, user_id, ` + changePayloadSQL + `
+		FROM ` + source
+}
+
+// DBOption is a function that configures a DBStorage.
+type DBOption func(*DBStorage)
+
+// WithoutOutbox stops recording changes of links in the outbox, for when no relay publishes them.
+func WithoutOutbox() DBOption {
+	return func(s *DBStorage) {
+		s.withoutOutbox = true
+	}
 }
 
 // NewDBStorage creates a new DBStorage and initializes the database schema and prepared statements.
-func NewDBStorage(ctx context.Context, db DB) (*DBStorage, error) {
+// Substring search is indexed and ranked by similarity if the pg_trgm extension is installed in the database,
+// which takes a privileged user: CREATE EXTENSION pg_trgm. Without it, substring search scans the links of the user.
+func NewDBStorage(ctx context.Context, db DB, opts ...DBOption) (*DBStorage, error) {
 	storage := &DBStorage{db: db}
+	for _, opt := range opts {
+		opt(storage)
+	}
 	if err := storage.initDB(ctx); err != nil {
 		return nil, err
 	}
@@ -48,6 +149,36 @@ func NewDBStorage(ctx context.Context, db DB) (*DBStorage, error) {
 	return storage, nil
 }
 
+// initTrigram indexes the search documents for substring search if the pg_trgm extension is installed.
+func (s *DBStorage) initTrigram(ctx context.Context) error {
+	result, err := s.db.ExecContext(ctx, `SELECT 1 FROM pg_extension WHERE extname = 'pg_trgm'`)
+	if err != nil {
+		return err
+	}
+	installed, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if s.trigram = installed > 0; !s.trigram {
+		return nil
+	}
+
+	_, err = s.db.ExecContext(ctx, `
+		CREATE INDEX IF NOT EXISTS urls_search_trgm_idx ON urls USING gin ((`+searchDocumentSQL+`) gin_trgm_ops)
+	`)
+	return err
+}
+
+// similaritySQL returns the ordering of search results by their trigram similarity to the phrase,
+// or, without the pg_trgm extension, by whether they contain it at all.
+func (s *DBStorage) similaritySQL(phrase string) string {
+	if !s.trigram {
+		return `strpos(` + searchDocumentSQL + `, ` + phrase + `) = 0,`
+	}
+
+	return `similarity(` + searchDocumentSQL + `, ` + phrase + `) DESC,`
+}
+
 func (s *DBStorage) initDB(ctx context.Context) error {
 	_, err := s.db.ExecContext(ctx, `
 		CREATE TABLE IF NOT EXISTS urls (
@@ -60,27 +191,225 @@ func (s *DBStorage) initDB(ctx context.Context) error {
 			CONSTRAINT urls_short_url_unique UNIQUE (short_url)
 		);
 		CREATE INDEX IF NOT EXISTS urls_user_id_idx ON urls (user_id);
+		ALTER TABLE urls ADD COLUMN IF NOT EXISTS password_hash text NOT NULL DEFAULT '';
+		ALTER TABLE urls ADD COLUMN IF NOT EXISTS deleted_at timestamptz;
+		ALTER TABLE urls ADD COLUMN IF NOT EXISTS interstitial boolean NOT NULL DEFAULT false;
+		ALTER TABLE urls ADD COLUMN IF NOT EXISTS redirect_code smallint NOT NULL DEFAULT 0;
+		ALTER TABLE urls ADD COLUMN IF NOT EXISTS title text NOT NULL DEFAULT '';
+		ALTER TABLE urls ADD COLUMN IF NOT EXISTS description text NOT NULL DEFAULT '';
+		ALTER TABLE urls ADD COLUMN IF NOT EXISTS notes text NOT NULL DEFAULT '';
+		ALTER TABLE urls ADD COLUMN IF NOT EXISTS tags text NOT NULL DEFAULT '';
+		ALTER TABLE urls ADD COLUMN IF NOT EXISTS expires_at timestamptz;
+		ALTER TABLE urls ADD COLUMN IF NOT EXISTS created_at timestamptz NOT NULL DEFAULT now();
+		CREATE INDEX IF NOT EXISTS urls_search_idx ON urls USING gin (to_tsvector('simple', `+searchDocumentSQL+`));
+		CREATE TABLE IF NOT EXISTS api_keys (
+			id uuid NOT NULL,
+			user_id uuid NOT NULL,
+			name text NOT NULL,
+			prefix text NOT NULL,
+			key_hash text NOT NULL,
+			scopes text NOT NULL DEFAULT '',
+			created_at timestamptz NOT NULL DEFAULT now(),
+			last_used_at timestamptz,
+			revoked_at timestamptz,
+			CONSTRAINT api_keys_pk PRIMARY KEY (id),
+			CONSTRAINT api_keys_key_hash_unique UNIQUE (key_hash)
+		);
+		CREATE INDEX IF NOT EXISTS api_keys_user_id_idx ON api_keys (user_id);
+		CREATE TABLE IF NOT EXISTS url_history (
+			id bigserial NOT NULL,
+			short_url text NOT NULL,
+			original_url text NOT NULL,
+			changed_at timestamptz NOT NULL DEFAULT now(),
+			CONSTRAINT url_history_pk PRIMARY KEY (id)
+		);
+		CREATE INDEX IF NOT EXISTS url_history_short_url_idx ON url_history (short_url);
+		ALTER TABLE urls ADD COLUMN IF NOT EXISTS clicked_at timestamptz;
+		CREATE TABLE IF NOT EXISTS webhooks (
+			id uuid NOT NULL,
+			user_id uuid NOT NULL,
+			url text NOT NULL,
+			secret text NOT NULL,
+			events text NOT NULL DEFAULT '',
+			created_at timestamptz NOT NULL DEFAULT now(),
+			CONSTRAINT webhooks_pk PRIMARY KEY (id)
+		);
+		CREATE INDEX IF NOT EXISTS webhooks_user_id_idx ON webhooks (user_id);
+		CREATE TABLE IF NOT EXISTS webhook_deliveries (
+			id uuid NOT NULL,
+			webhook_id uuid NOT NULL,
+			event text NOT NULL,
+			payload json NOT NULL,
+			status text NOT NULL,
+			attempts integer NOT NULL DEFAULT 0,
+			response_status integer NOT NULL DEFAULT 0,
+			last_error text NOT NULL DEFAULT '',
+			created_at timestamptz NOT NULL DEFAULT now(),
+			next_attempt_at timestamptz,
+			delivered_at timestamptz,
+			CONSTRAINT webhook_deliveries_pk PRIMARY KEY (id),
+			CONSTRAINT webhook_deliveries_webhook_fk FOREIGN KEY (webhook_id) REFERENCES webhooks (id) ON DELETE CASCADE
+		);
+		CREATE INDEX IF NOT EXISTS webhook_deliveries_webhook_id_idx ON webhook_deliveries (webhook_id, created_at);
+		CREATE INDEX IF NOT EXISTS webhook_deliveries_due_idx ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+		CREATE TABLE IF NOT EXISTS outbox (
+			id bigserial NOT NULL,
+			event text NOT NULL,
+			short_url text NOT NULL,
+			user_id uuid NOT NULL,
+			payload json NOT NULL,
+			created_at timestamptz NOT NULL DEFAULT now(),
+			CONSTRAINT outbox_pk PRIMARY KEY (id)
+		);
+		CREATE TABLE IF NOT EXISTS outbox_lease (
+			id boolean NOT NULL DEFAULT true,
+			holder text NOT NULL,
+			leased_until timestamptz NOT NULL,
+			CONSTRAINT outbox_lease_pk PRIMARY KEY (id),
+			CONSTRAINT outbox_lease_single CHECK (id)
+		);
+		CREATE TABLE IF NOT EXISTS domains (
+			name text NOT NULL,
+			user_id uuid NOT NULL,
+			verification_token text NOT NULL,
+			verified_at timestamptz,
+			created_at timestamptz NOT NULL DEFAULT now(),
+			CONSTRAINT domains_pk PRIMARY KEY (name, user_id)
+		);
+		CREATE INDEX IF NOT EXISTS domains_user_id_idx ON domains (user_id);
+		CREATE UNIQUE INDEX IF NOT EXISTS domains_verified_idx ON domains (name) WHERE verified_at IS NOT NULL;
+		CREATE TABLE IF NOT EXISTS workspaces (
+			id uuid NOT NULL,
+			name text NOT NULL,
+			created_at timestamptz NOT NULL DEFAULT now(),
+			CONSTRAINT workspaces_pk PRIMARY KEY (id)
+		);
+		CREATE TABLE IF NOT EXISTS workspace_members (
+			workspace_id uuid NOT NULL,
+			user_id uuid NOT NULL,
+			role text NOT NULL,
+			added_at timestamptz NOT NULL DEFAULT now(),
+			CONSTRAINT workspace_members_pk PRIMARY KEY (workspace_id, user_id),
+			CONSTRAINT workspace_members_workspace_fk FOREIGN KEY (workspace_id) REFERENCES workspaces (id) ON DELETE CASCADE
+		);
+		CREATE INDEX IF NOT EXISTS workspace_members_user_id_idx ON workspace_members (user_id);
+		CREATE TABLE IF NOT EXISTS user_plans (
+			user_id uuid NOT NULL,
+			plan text NOT NULL,
+			CONSTRAINT user_plans_pk PRIMARY KEY (user_id)
+		);
+		CREATE TABLE IF NOT EXISTS link_usage (
+			user_id uuid NOT NULL,
+			period text NOT NULL,
+			links integer NOT NULL DEFAULT 0,
+			CONSTRAINT link_usage_pk PRIMARY KEY (user_id, period)
+		);
+		CREATE TABLE IF NOT EXISTS audit_log (
+			id uuid NOT NULL,
+			actor text NOT NULL,
+			action text NOT NULL,
+			target text NOT NULL,
+			before jsonb,
+			after jsonb,
+			ip text NOT NULL DEFAULT '',
+			user_agent text NOT NULL DEFAULT '',
+			transport text NOT NULL DEFAULT '',
+			created_at timestamptz NOT NULL DEFAULT now(),
+			CONSTRAINT audit_log_pk PRIMARY KEY (id)
+		);
+		CREATE INDEX IF NOT EXISTS audit_log_created_at_idx ON audit_log (created_at);
+		CREATE INDEX IF NOT EXISTS audit_log_actor_idx ON audit_log (actor, created_at);
+		CREATE INDEX IF NOT EXISTS audit_log_target_idx ON audit_log (target, created_at);
 	`)
 	if err != nil {
 		return err
 	}
 
+	if err = s.initTrigram(ctx); err != nil {
+		return err
+	}
+
 	if s.saveStmt, err = s.db.PreparexContext(ctx, `
-		INSERT INTO urls (id, user_id, short_url, original_url)
-		VALUES ($1::uuid, $2::uuid, $3, $4)
-		ON CONFLICT DO NOTHING
-	`); err != nil {
+		WITH saved AS (
+			INSERT INTO urls (
+				id, user_id, short_url, original_url, password_hash, interstitial, redirect_code,
+				title, description, notes, tags, expires_at, created_at
+			)
+			VALUES ($1::uuid, $2::uuid, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+			ON CONFLICT DO NOTHING
+			RETURNING *
+		)`+s.recordChangesSQL(models.EventLinkCreated, "saved")); err != nil {
 		return err
 	}
 
 	if s.getAllStmt, err = s.db.PreparexContext(ctx, `
 		SELECT
 			short_url,
-			original_url
+			original_url,
+			redirect_code,
+			interstitial,
+			title,
+			description,
+			notes,
+			tags,
+			expires_at,
+			created_at
+		FROM
+			urls
+		WHERE
+			user_id = $1::uuid AND NOT is_deleted
+			AND ($2::text[] IS NULL OR string_to_array(tags, ',') @> $2::text[])
+	`); err != nil {
+		return err
+	}
+
+	if s.iterateStmt, err = s.db.PreparexContext(ctx, `
+		SELECT
+			short_url,
+			original_url,
+			redirect_code,
+			interstitial,
+			title,
+			description,
+			notes,
+			tags,
+			expires_at,
+			created_at
+		FROM
+			urls
+		WHERE
+			user_id = $1::uuid AND NOT is_deleted
+		ORDER BY
+			short_url
+	`); err != nil {
+		return err
+	}
+
+	if s.searchStmt, err = s.db.PreparexContext(ctx, `
+		SELECT
+			short_url,
+			original_url,
+			redirect_code,
+			interstitial,
+			title,
+			description,
+			notes,
+			tags,
+			expires_at,
+			created_at
 		FROM
 			urls
 		WHERE
-			user_id = $1::uuid
+			user_id = $1::uuid AND NOT is_deleted
+			AND (
+				to_tsvector('simple', `+searchDocumentSQL+`) @@ to_tsquery('simple', $2)
+				OR `+searchDocumentSQL+` LIKE $3
+			)
+		ORDER BY
+			ts_rank(to_tsvector('simple', `+searchDocumentSQL+`), to_tsquery('simple', $2)) DESC,
+			`+s.similaritySQL("$4")+`
+			short_url
+		LIMIT NULLIF($5::int, 0)
 	`); err != nil {
 		return err
 	}
@@ -95,10 +424,12 @@ func (s *DBStorage) initDB(ctx context.Context) error {
 	}
 
 	if s.deleteStmt, err = s.db.PreparexContext(ctx, `
-		UPDATE urls
-		SET is_deleted = true
-		WHERE user_id = $1 AND short_url = ANY($2)
-	`); err != nil {
+		WITH deleted AS (
+			UPDATE urls
+			SET is_deleted = true, deleted_at = now()
+			WHERE user_id = $1 AND short_url = ANY($2) AND NOT is_deleted
+			RETURNING *
+		)`+s.recordChangesSQL(models.EventLinkDeleted, "deleted")); err != nil {
 		return err
 	}
 
@@ -112,35 +443,462 @@ func (s *DBStorage) initDB(ctx context.Context) error {
 		return err
 	}
 
-	return nil
-}
+	if s.saveKeyStmt, err = s.db.PreparexContext(ctx, `
+		INSERT INTO api_keys (id, user_id, name, prefix, key_hash, scopes, created_at)
+		VALUES ($1::uuid, $2::uuid, $3, $4, $5, $6, $7)
+		ON CONFLICT DO NOTHING
+	`); err != nil {
+		return err
+	}
 
-// Close closes all prepared statements and the underlying database connection.
-func (s *DBStorage) Close() error {
-	if err := s.getStmt.Close(); err != nil {
+	if s.getKeysStmt, err = s.db.PreparexContext(ctx, `
+		SELECT *
+		FROM api_keys
+		WHERE user_id = $1::uuid
+		ORDER BY created_at
+	`); err != nil {
+		return err
+	}
+
+	if s.getKeyByHashStmt, err = s.db.PreparexContext(ctx, `
+		SELECT *
+		FROM api_keys
+		WHERE key_hash = $1
+		LIMIT 1
+	`); err != nil {
+		return err
+	}
+
+	if s.revokeKeyStmt, err = s.db.PreparexContext(ctx, `
+		UPDATE api_keys
+		SET revoked_at = COALESCE(revoked_at, $3)
+		WHERE user_id = $1::uuid AND id = $2::uuid
+	`); err != nil {
+		return err
+	}
+
+	if s.touchKeyStmt, err = s.db.PreparexContext(ctx, `
+		UPDATE api_keys
+		SET last_used_at = $2
+		WHERE id = $1::uuid
+	`); err != nil {
+		return err
+	}
+
+	// NULL parameters leave their columns unchanged.
+	if s.patchStmt, err = s.db.PreparexContext(ctx, `
+		WITH previous AS (
+			SELECT short_url, original_url
+			FROM urls
+			WHERE user_id = $1::uuid AND short_url = $2 AND NOT is_deleted
+			FOR UPDATE
+		), updated AS (
+			UPDATE urls
+			SET original_url = COALESCE($3::text, urls.original_url),
+				redirect_code = COALESCE($4::smallint, urls.redirect_code),
+				title = COALESCE($5::text, urls.title),
+				description = COALESCE($6::text, urls.description),
+				notes = COALESCE($7::text, urls.notes),
+				tags = COALESCE($8::text, urls.tags)
+			FROM previous
+			WHERE urls.short_url = previous.short_url
+			RETURNING urls.*
+		), changed AS (`+s.recordChangesSQL(models.EventLinkUpdated, "updated")+`
+		), history AS (
+			INSERT INTO url_history (short_url, original_url, changed_at)
+			SELECT short_url, original_url, $9
+			FROM previous
+			WHERE original_url <> COALESCE($3::text, original_url)
+		)
+		SELECT count(*) FROM updated
+	`); err != nil {
+		return err
+	}
+
+	if s.setMetadataStmt, err = s.db.PreparexContext(ctx, `
+		WITH updated AS (
+			UPDATE urls
+			SET title = $3, description = $4, notes = $5, tags = $6
+			WHERE user_id = $1::uuid AND short_url = $2 AND NOT is_deleted
+			RETURNING *
+		)`+s.recordChangesSQL(models.EventLinkUpdated, "updated")); err != nil {
+		return err
+	}
+
+	if s.getHistoryStmt, err = s.db.PreparexContext(ctx, `
+		SELECT short_url, original_url, changed_at
+		FROM url_history
+		WHERE short_url = $1
+		ORDER BY changed_at, id
+	`); err != nil {
+		return err
+	}
+
+	if s.restoreStmt, err = s.db.PreparexContext(ctx, `
+		WITH restored AS (
+			UPDATE urls
+			SET is_deleted = false, deleted_at = NULL
+			WHERE user_id = $1::uuid AND short_url = $2 AND is_deleted AND deleted_at > $3
+			RETURNING *
+		)`+s.recordChangesSQL(models.EventLinkUpdated, "restored")); err != nil {
+		return err
+	}
+
+	if s.purgeStmt, err = s.db.PreparexContext(ctx, `
+		WITH purged AS (
+			DELETE FROM urls
+			WHERE is_deleted AND (deleted_at IS NULL OR deleted_at < $1)
+			RETURNING short_url
+		), history AS (
+			DELETE FROM url_history
+			WHERE short_url IN (SELECT short_url FROM purged)
+		)
+		SELECT COUNT(*) FROM purged
+	`); err != nil {
+		return err
+	}
+
+	if s.recordClickStmt, err = s.db.PreparexContext(ctx, `
+		UPDATE urls
+		SET clicked_at = $2
+		WHERE short_url = $1 AND clicked_at IS NULL
+	`); err != nil {
+		return err
+	}
+
+	if s.saveHookStmt, err = s.db.PreparexContext(ctx, `
+		INSERT INTO webhooks (id, user_id, url, secret, events, created_at)
+		VALUES ($1::uuid, $2::uuid, $3, $4, $5, $6)
+		ON CONFLICT DO NOTHING
+	`); err != nil {
+		return err
+	}
+
+	if s.getHookStmt, err = s.db.PreparexContext(ctx, `
+		SELECT *
+		FROM webhooks
+		WHERE id = $1::uuid
+	`); err != nil {
+		return err
+	}
+
+	if s.getHooksStmt, err = s.db.PreparexContext(ctx, `
+		SELECT *
+		FROM webhooks
+		WHERE user_id = $1::uuid
+		ORDER BY created_at
+	`); err != nil {
+		return err
+	}
+
+	if s.deleteHookStmt, err = s.db.PreparexContext(ctx, `
+		DELETE FROM webhooks
+		WHERE user_id = $1::uuid AND id = $2::uuid
+	`); err != nil {
+		return err
+	}
+
+	if s.claimStmt, err = s.db.PreparexContext(ctx, `
+		WITH due AS (
+			SELECT id
+			FROM webhook_deliveries
+			WHERE status = 'pending' AND next_attempt_at <= $1
+			ORDER BY next_attempt_at
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		)
+		UPDATE webhook_deliveries
+		SET next_attempt_at = $2
+		FROM due
+		WHERE webhook_deliveries.id = due.id
+		RETURNING webhook_deliveries.*
+	`); err != nil {
+		return err
+	}
+
+	if s.getDeliveriesStmt, err = s.db.PreparexContext(ctx, `
+		SELECT *
+		FROM webhook_deliveries
+		WHERE webhook_id = $1::uuid
+		ORDER BY created_at DESC
+		LIMIT NULLIF($2::int, 0)
+	`); err != nil {
+		return err
+	}
+
+	if s.updateDeliveryStmt, err = s.db.PreparexContext(ctx, `
+		UPDATE webhook_deliveries
+		SET status = $2, attempts = $3, response_status = $4, last_error = $5, next_attempt_at = $6, delivered_at = $7
+		WHERE id = $1::uuid
+	`); err != nil {
+		return err
+	}
+
+	if s.leaseOutboxStmt, err = s.db.PreparexContext(ctx, `
+		INSERT INTO outbox_lease (holder, leased_until)
+		VALUES ($1, $3)
+		ON CONFLICT (id) DO UPDATE
+		SET holder = excluded.holder, leased_until = excluded.leased_until
+		WHERE outbox_lease.holder = excluded.holder OR outbox_lease.leased_until <= $2
+	`); err != nil {
+		return err
+	}
+
+	if s.getChangesStmt, err = s.db.PreparexContext(ctx, `
+		SELECT *
+		FROM outbox
+		ORDER BY id
+		LIMIT $1
+	`); err != nil {
+		return err
+	}
+
+	if s.deleteChangesStmt, err = s.db.PreparexContext(ctx, `
+		DELETE FROM outbox
+		WHERE id = ANY($1)
+	`); err != nil {
+		return err
+	}
+
+	if s.saveDomainStmt, err = s.db.PreparexContext(ctx, `
+		INSERT INTO domains (name, user_id, verification_token, created_at)
+		VALUES ($1, $2::uuid, $3, $4)
+		ON CONFLICT DO NOTHING
+	`); err != nil {
+		return err
+	}
+
+	if s.getDomainsStmt, err = s.db.PreparexContext(ctx, `
+		SELECT *
+		FROM domains
+		WHERE user_id = $1::uuid
+		ORDER BY created_at
+	`); err != nil {
+		return err
+	}
+
+	if s.getDomainStmt, err = s.db.PreparexContext(ctx, `
+		SELECT *
+		FROM domains
+		WHERE name = $1 AND verified_at IS NOT NULL
+	`); err != nil {
+		return err
+	}
+
+	if s.verifyDomainStmt, err = s.db.PreparexContext(ctx, `
+		UPDATE domains
+		SET verified_at = COALESCE(verified_at, $3)
+		WHERE user_id = $1::uuid AND name = $2
+	`); err != nil {
+		return err
+	}
+
+	// The member is inserted in the same statement as the workspace, so a workspace never lacks its owner.
+	if s.saveWorkspaceStmt, err = s.db.PreparexContext(ctx, `
+		WITH workspace AS (
+			INSERT INTO workspaces (id, name, created_at)
+			VALUES ($1::uuid, $2, $3)
+		)
+		INSERT INTO workspace_members (workspace_id, user_id, role, added_at)
+		VALUES ($1::uuid, $4::uuid, $5, $6)
+	`); err != nil {
+		return err
+	}
+
+	if s.getWorkspacesStmt, err = s.db.PreparexContext(ctx, `
+		SELECT w.id, w.name, m.role, w.created_at
+		FROM workspaces w
+		JOIN workspace_members m ON m.workspace_id = w.id
+		WHERE m.user_id = $1::uuid
+		ORDER BY w.created_at
+	`); err != nil {
+		return err
+	}
+
+	if s.getMemberStmt, err = s.db.PreparexContext(ctx, `
+		SELECT *
+		FROM workspace_members
+		WHERE workspace_id = $1::uuid AND user_id = $2::uuid
+	`); err != nil {
+		return err
+	}
+
+	if s.getMembersStmt, err = s.db.PreparexContext(ctx, `
+		SELECT *
+		FROM workspace_members
+		WHERE workspace_id = $1::uuid
+		ORDER BY added_at
+	`); err != nil {
+		return err
+	}
+
+	// The owners of the workspace are locked, so owners removing each other concurrently
+	// cannot leave the workspace without one.
+	if s.saveMemberStmt, err = s.db.PreparexContext(ctx, `
+		WITH owners AS (
+			SELECT user_id
+			FROM workspace_members
+			WHERE workspace_id = $1::uuid AND role = '`+models.RoleOwner+`'
+			FOR UPDATE
+		)
+		INSERT INTO workspace_members (workspace_id, user_id, role, added_at)
+		VALUES ($1::uuid, $2::uuid, $3, $4)
+		ON CONFLICT (workspace_id, user_id) DO UPDATE
+		SET role = EXCLUDED.role
+		WHERE EXCLUDED.role = '`+models.RoleOwner+`' OR EXISTS (SELECT 1 FROM owners WHERE user_id <> $2::uuid)
+	`); err != nil {
+		return err
+	}
+
+	if s.deleteMemberStmt, err = s.db.PreparexContext(ctx, `
+		WITH owners AS (
+			SELECT user_id
+			FROM workspace_members
+			WHERE workspace_id = $1::uuid AND role = '`+models.RoleOwner+`'
+			FOR UPDATE
+		)
+		DELETE FROM workspace_members
+		WHERE workspace_id = $1::uuid AND user_id = $2::uuid
+			AND (role <> '`+models.RoleOwner+`' OR EXISTS (SELECT 1 FROM owners WHERE user_id <> $2::uuid))
+	`); err != nil {
+		return err
+	}
+
+	if s.getPlanStmt, err = s.db.PreparexContext(ctx, `
+		SELECT plan
+		FROM user_plans
+		WHERE user_id = $1::uuid
+	`); err != nil {
 		return err
 	}
-	if err := s.getAllStmt.Close(); err != nil {
+
+	if s.setPlanStmt, err = s.db.PreparexContext(ctx, `
+		INSERT INTO user_plans (user_id, plan)
+		VALUES ($1::uuid, $2)
+		ON CONFLICT (user_id) DO UPDATE
+		SET plan = EXCLUDED.plan
+	`); err != nil {
+		return err
+	}
+
+	if s.countActiveStmt, err = s.db.PreparexContext(ctx, `
+		SELECT count(*)
+		FROM urls
+		WHERE user_id = $1::uuid AND NOT is_deleted
+	`); err != nil {
+		return err
+	}
+
+	if s.getUsageStmt, err = s.db.PreparexContext(ctx, `
+		SELECT COALESCE((SELECT links FROM link_usage WHERE user_id = $1::uuid AND period = $2), 0)
+	`); err != nil {
 		return err
 	}
-	if err := s.saveStmt.Close(); err != nil {
+
+	if s.addUsageStmt, err = s.db.PreparexContext(ctx, `
+		INSERT INTO link_usage (user_id, period, links)
+		VALUES ($1::uuid, $2, $3)
+		ON CONFLICT (user_id, period) DO UPDATE
+		SET links = link_usage.links + EXCLUDED.links
+	`); err != nil {
 		return err
 	}
-	if err := s.deleteStmt.Close(); err != nil {
+
+	// Empty filters, zero times and a zero limit are passed as NULL and match every entry.
+	// Missing values are selected as empty strings, as NULL cannot be scanned into json.RawMessage.
+	if s.getAuditStmt, err = s.db.PreparexContext(ctx, `
+		SELECT id, actor, action, target, COALESCE(before::text, '') AS before, COALESCE(after::text, '') AS after,
+			ip, user_agent, transport, created_at
+		FROM audit_log
+		WHERE ($1::text IS NULL OR actor = $1)
+			AND ($2::text IS NULL OR action = $2)
+			AND ($3::text IS NULL OR target = $3)
+			AND ($4::timestamptz IS NULL OR created_at >= $4)
+			AND ($5::timestamptz IS NULL OR created_at < $5)
+		ORDER BY created_at DESC
+		LIMIT $6
+	`); err != nil {
 		return err
 	}
-	if err := s.getStatsStmt.Close(); err != nil {
+
+	if s.purgeAuditStmt, err = s.db.PreparexContext(ctx, `
+		DELETE FROM audit_log
+		WHERE created_at < $1
+	`); err != nil {
 		return err
 	}
+
+	return nil
+}
+
+// Close closes all prepared statements and the underlying database connection.
+func (s *DBStorage) Close() error {
+	for _, stmt := range []Stmt{
+		s.getStmt,
+		s.getAllStmt,
+		s.saveStmt,
+		s.deleteStmt,
+		s.getStatsStmt,
+		s.saveKeyStmt,
+		s.getKeysStmt,
+		s.getKeyByHashStmt,
+		s.revokeKeyStmt,
+		s.touchKeyStmt,
+		s.patchStmt,
+		s.getHistoryStmt,
+		s.restoreStmt,
+		s.purgeStmt,
+		s.setMetadataStmt,
+		s.searchStmt,
+		s.iterateStmt,
+		s.recordClickStmt,
+		s.saveHookStmt,
+		s.getHookStmt,
+		s.getHooksStmt,
+		s.deleteHookStmt,
+		s.claimStmt,
+		s.getDeliveriesStmt,
+		s.updateDeliveryStmt,
+		s.leaseOutboxStmt,
+		s.getChangesStmt,
+		s.deleteChangesStmt,
+		s.saveDomainStmt,
+		s.getDomainsStmt,
+		s.getDomainStmt,
+		s.verifyDomainStmt,
+		s.saveWorkspaceStmt,
+		s.getWorkspacesStmt,
+		s.getMemberStmt,
+		s.getMembersStmt,
+		s.saveMemberStmt,
+		s.deleteMemberStmt,
+		s.getPlanStmt,
+		s.setPlanStmt,
+		s.countActiveStmt,
+		s.getUsageStmt,
+		s.addUsageStmt,
+		s.getAuditStmt,
+		s.purgeAuditStmt,
+	} {
+		if err := stmt.Close(); err != nil {
+			return err
+		}
+	}
 	if err := s.db.Close(); err != nil {
 		return err
 	}
 	return nil
 }
 
-// Save inserts a new URL record into the database.
+// Save inserts a new URL record into the database. A record without a creation time is saved with the current time.
 func (s *DBStorage) Save(ctx context.Context, model models.URL) error {
-	result, err := s.saveStmt.ExecContext(ctx, model.UUID, model.UserID, model.ShortURL, model.OriginalURL)
+	if model.CreatedAt.IsZero() {
+		model.CreatedAt = time.Now().UTC()
+	}
+	result, err := s.saveStmt.ExecContext(ctx,
+		model.UUID, model.UserID, model.ShortURL, model.OriginalURL, model.PasswordHash, model.Interstitial, model.RedirectCode,
+		model.Title, model.Description, model.Notes, model.Tags, model.ExpiresAt, model.CreatedAt,
+	)
 	if err != nil {
 		return err
 	}
@@ -157,31 +915,116 @@ func (s *DBStorage) Save(ctx context.Context, model models.URL) error {
 	return nil
 }
 
-// SaveMany inserts multiple URL records into the database.
-func (s *DBStorage) SaveMany(ctx context.Context, models []models.URL) error {
-	_, err := s.db.NamedExecContext(ctx, `
-		INSERT INTO urls (id, user_id, short_url, original_url)
-		VALUES (:id, :user_id, :short_url, :original_url)
-	`, models)
+// SaveMany inserts multiple URL records into the database with a single statement, so either all
+// new records are saved or none. Records whose ID or short URL is taken are skipped and reported
+// with ErrAlreadyExist. Records without a creation time are saved with the current time.
+func (s *DBStorage) SaveMany(ctx context.Context, urls []models.URL) ([]error, error) {
+	urls = withCreatedAt(urls)
+	rows, err := s.db.NamedQueryContext(ctx, `
+		WITH saved AS (
+			INSERT INTO urls (
+				id, user_id, short_url, original_url, password_hash, interstitial, redirect_code,
+				title, description, notes, tags, expires_at, created_at
+			)
+			VALUES (
+				:id, :user_id, :short_url, :original_url, :password_hash, :interstitial, :redirect_code,
+				:title, :description, :notes, :tags, :expires_at, :created_at
+			)
+			ON CONFLICT DO NOTHING
+			RETURNING *
+		), changed AS (`+s.recordChangesSQL(models.EventLinkCreated, "saved")+`
+		)
+		SELECT short_url FROM saved
+	`, urls)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return nil
-}
-
-// Get retrieves the original URL for a given short URL.
-func (s *DBStorage) Get(ctx context.Context, short string) (string, error) {
-	var url models.URL
-	err := s.getStmt.GetContext(ctx, &url, short)
+	saved, err := scanShortURLs(rows)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+
+	results := make([]error, len(urls))
+	for i, model := range urls {
+		if !saved[model.ShortURL] {
+			results[i] = ErrAlreadyExist
+		}
+		delete(saved, model.ShortURL)
+	}
+	return results, nil
+}
+
+// notFound translates the error of a single-row lookup that found no row to ErrNotFound.
+func notFound(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// Get retrieves the URL model for a given short URL.
+func (s *DBStorage) Get(ctx context.Context, short string) (models.URL, error) {
+	var url models.URL
+	err := s.getStmt.GetContext(ctx, &url, short)
+	if err != nil {
+		return models.URL{}, notFound(err)
 	}
 	if url.IsDeleted {
-		return "", ErrDeleted
+		return models.URL{}, ErrDeleted
+	}
+
+	return url, nil
+}
+
+// PatchURL applies the changes of the patch to a user's short URL, records the previous destination
+// in url_history if it changes and a single change in the outbox, all within a single statement.
+func (s *DBStorage) PatchURL(ctx context.Context, userID string, short string, patch models.URLPatch, changedAt time.Time) error {
+	var title, description, notes, tags any
+	if meta := patch.Metadata; meta != nil {
+		title, description, notes, tags = meta.Title, meta.Description, meta.Notes, meta.Tags
+	}
+	var updated int
+	err := s.patchStmt.GetContext(ctx, &updated, userID, short,
+		nullString(patch.OriginalURL), nullInt(patch.RedirectCode), title, description, notes, tags, changedAt)
+	if err != nil {
+		return err
+	}
+
+	if updated == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// SetMetadata replaces the title, description, notes and tags of a user's short URL.
+func (s *DBStorage) SetMetadata(ctx context.Context, userID string, short string, meta models.URLMetadata) error {
+	result, err := s.setMetadataStmt.ExecContext(ctx, userID, short, meta.Title, meta.Description, meta.Notes, meta.Tags)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// GetURLHistory returns the previous destinations of a short URL, oldest first.
+func (s *DBStorage) GetURLHistory(ctx context.Context, short string) ([]models.URLHistory, error) {
+	var history []models.URLHistory
+	if err := s.getHistoryStmt.SelectContext(ctx, &history, short); err != nil {
+		return nil, err
 	}
 
-	return url.OriginalURL, nil
+	return history, nil
 }
 
 // Ping checks the database connection.
@@ -189,10 +1032,96 @@ func (s *DBStorage) Ping(ctx context.Context) error {
 	return s.db.PingContext(ctx)
 }
 
-// GetAll retrieves all URLs for a given user.
-func (s *DBStorage) GetAll(ctx context.Context, userID string) ([]models.URL, error) {
+// GetAll retrieves the URLs of a given user having all tags of the filter.
+func (s *DBStorage) GetAll(ctx context.Context, userID string, filter models.URLFilter) ([]models.URL, error) {
+	var urls []models.URL
+	if err := s.getAllStmt.SelectContext(ctx, &urls, userID, pq.Array(filter.Tags)); err != nil {
+		return nil, err
+	}
+
+	return urls, nil
+}
+
+// IterateAll streams the URLs of a user, except deleted ones, ordered by short URL.
+func (s *DBStorage) IterateAll(ctx context.Context, userID string) URLSeq {
+	return func(yield func(models.URL, error) bool) {
+		rows, err := s.iterateStmt.QueryxContext(ctx, userID)
+		if err != nil {
+			yield(models.URL{}, err)
+			return
+		}
+		if stopped, err := yieldRows(rows, yield); err != nil && !stopped {
+			yield(models.URL{}, err)
+		}
+	}
+}
+
+// yieldRows passes the URLs scanned from rows to yield until it returns false, then closes the rows.
+// It reports whether yield stopped the iteration and the error that ended it otherwise.
+func yieldRows(rows *sqlx.Rows, yield func(models.URL, error) bool) (stopped bool, err error) {
+	defer func() {
+		err = errors.Join(err, rows.Close())
+	}()
+
+	for rows.Next() {
+		var url models.URL
+		if err = rows.StructScan(&url); err != nil {
+			return false, err
+		}
+		if !yield(url, nil) {
+			return true, nil
+		}
+	}
+	return false, rows.Err()
+}
+
+// withCreatedAt returns urls with the current time as the creation time of those without one,
+// copying the slice instead of modifying it.
+func withCreatedAt(urls []models.URL) []models.URL {
+	var now time.Time
+	for i := range urls {
+		if !urls[i].CreatedAt.IsZero() {
+			continue
+		}
+		if now.IsZero() {
+			now = time.Now().UTC()
+			urls = slices.Clone(urls)
+		}
+		urls[i].CreatedAt = now
+	}
+	return urls
+}
+
+// scanShortURLs reads the set of short URLs returned by rows and closes them.
+func scanShortURLs(rows *sqlx.Rows) (shorts map[string]bool, err error) {
+	defer func() {
+		err = errors.Join(err, rows.Close())
+	}()
+
+	shorts = make(map[string]bool)
+	for rows.Next() {
+		var short string
+		if err = rows.Scan(&short); err != nil {
+			return nil, err
+		}
+		shorts[short] = true
+	}
+	return shorts, rows.Err()
+}
+
+// Search returns up to limit URLs of a user, except deleted ones, matching the query
+// as a prefix of each word or a substring of the search document, best ranked first.
+func (s *DBStorage) Search(ctx context.Context, userID string, query string, limit int) ([]models.URL, error) {
+	terms := searchTerms(query)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+	phrase := strings.Join(terms, " ")
+
 	var urls []models.URL
-	if err := s.getAllStmt.SelectContext(ctx, &urls, userID); err != nil {
+	if err := s.searchStmt.SelectContext(ctx, &urls,
+		userID, strings.Join(terms, ":* & ")+":*", "%"+phrase+"%", phrase, limit,
+	); err != nil {
 		return nil, err
 	}
 
@@ -205,7 +1134,500 @@ func (s *DBStorage) DeleteMany(ctx context.Context, userID string, shortURLs []s
 	return err
 }
 
+// Restore undeletes a user's short URL if it was deleted after deletedAfter.
+func (s *DBStorage) Restore(ctx context.Context, userID string, short string, deletedAfter time.Time) error {
+	result, err := s.restoreStmt.ExecContext(ctx, userID, short, deletedAfter)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// Purge permanently removes short URLs deleted before deletedBefore, together with their history.
+// URLs deleted before deletion times were recorded are purged as well.
+// Changes in the outbox are left to the relay, which removes them once published.
+func (s *DBStorage) Purge(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	var purged int64
+	if err := s.purgeStmt.GetContext(ctx, &purged, deletedBefore); err != nil {
+		return 0, err
+	}
+
+	return purged, nil
+}
+
 // GetStats retrieves service statistics and populates the provided Stats struct.
 func (s *DBStorage) GetStats(ctx context.Context, stats *models.Stats) error {
 	return s.getStatsStmt.GetContext(ctx, stats)
 }
+
+// SaveAPIKey inserts a new API key record into the database.
+func (s *DBStorage) SaveAPIKey(ctx context.Context, key models.APIKey) error {
+	result, err := s.saveKeyStmt.ExecContext(ctx, key.ID, key.UserID, key.Name, key.Prefix, key.KeyHash, key.Scopes, key.CreatedAt)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrAlreadyExist
+	}
+	return nil
+}
+
+// GetAPIKeys retrieves all API keys of a user.
+func (s *DBStorage) GetAPIKeys(ctx context.Context, userID string) ([]models.APIKey, error) {
+	var keys []models.APIKey
+	if err := s.getKeysStmt.SelectContext(ctx, &keys, userID); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// GetAPIKeyByHash retrieves an API key by the hash of its value.
+func (s *DBStorage) GetAPIKeyByHash(ctx context.Context, hash string) (models.APIKey, error) {
+	var key models.APIKey
+	if err := s.getKeyByHashStmt.GetContext(ctx, &key, hash); err != nil {
+		return models.APIKey{}, notFound(err)
+	}
+	return key, nil
+}
+
+// RevokeAPIKey marks an API key of a user as revoked.
+func (s *DBStorage) RevokeAPIKey(ctx context.Context, userID string, id string, revokedAt time.Time) error {
+	result, err := s.revokeKeyStmt.ExecContext(ctx, userID, id, revokedAt)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// TouchAPIKey updates the last usage time of an API key.
+func (s *DBStorage) TouchAPIKey(ctx context.Context, id string, usedAt time.Time) error {
+	_, err := s.touchKeyStmt.ExecContext(ctx, id, usedAt)
+	return err
+}
+
+// RecordFirstClick sets the first click time of a short URL unless it is already set.
+func (s *DBStorage) RecordFirstClick(ctx context.Context, short string, clickedAt time.Time) (bool, error) {
+	result, err := s.recordClickStmt.ExecContext(ctx, short, clickedAt)
+	if err != nil {
+		return false, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rowsAffected > 0, nil
+}
+
+// SaveWebhook inserts a new webhook record into the database.
+func (s *DBStorage) SaveWebhook(ctx context.Context, hook models.Webhook) error {
+	result, err := s.saveHookStmt.ExecContext(ctx, hook.ID, hook.UserID, hook.URL, hook.Secret, hook.Events, hook.CreatedAt)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrAlreadyExist
+	}
+	return nil
+}
+
+// GetWebhook retrieves a webhook by its ID.
+func (s *DBStorage) GetWebhook(ctx context.Context, id string) (models.Webhook, error) {
+	var hook models.Webhook
+	if err := s.getHookStmt.GetContext(ctx, &hook, id); err != nil {
+		return models.Webhook{}, notFound(err)
+	}
+	return hook, nil
+}
+
+// GetWebhooks retrieves all webhooks of a user, oldest first.
+func (s *DBStorage) GetWebhooks(ctx context.Context, userID string) ([]models.Webhook, error) {
+	var hooks []models.Webhook
+	if err := s.getHooksStmt.SelectContext(ctx, &hooks, userID); err != nil {
+		return nil, err
+	}
+	return hooks, nil
+}
+
+// DeleteWebhook deletes a webhook of a user. Its deliveries are deleted by the foreign key.
+func (s *DBStorage) DeleteWebhook(ctx context.Context, userID string, id string) error {
+	result, err := s.deleteHookStmt.ExecContext(ctx, userID, id)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// deliveryColumns is the number of columns inserted per delivery by SaveDeliveries.
+const deliveryColumns = 7
+
+// SaveDeliveries inserts webhook deliveries with a single statement.
+func (s *DBStorage) SaveDeliveries(ctx context.Context, deliveries []models.WebhookDelivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+
+	var query strings.Builder
+	query.WriteString(`INSERT INTO webhook_deliveries (id, webhook_id, event, payload, status, created_at, next_attempt_at) VALUES `)
+	args := make([]any, 0, len(deliveries)*deliveryColumns)
+	for i, d := range deliveries {
+		if i > 0 {
+			query.WriteString(", ")
+		}
+		n := len(args)
+		fmt.Fprintf(&query, "($%d::uuid, $%d::uuid, $%d, $%d::json, $%d, $%d, $%d)", n+1, n+2, n+3, n+4, n+5, n+6, n+7)
+		// The payload is passed as a string, as byte slices are sent as bytea.
+		args = append(args, d.ID, d.WebhookID, d.Event, string(d.Payload), d.Status, d.CreatedAt, d.NextAttemptAt)
+	}
+
+	_, err := s.db.ExecContext(ctx, query.String(), args...)
+	return err
+}
+
+// ClaimDeliveries returns up to limit pending deliveries due at now and postpones their next attempt to leaseUntil
+// in a single statement. Rows being claimed by another worker are skipped.
+func (s *DBStorage) ClaimDeliveries(ctx context.Context, now time.Time, leaseUntil time.Time, limit int) ([]models.WebhookDelivery, error) {
+	var deliveries []models.WebhookDelivery
+	if err := s.claimStmt.SelectContext(ctx, &deliveries, now, leaseUntil, limit); err != nil {
+		return nil, err
+	}
+	slices.SortFunc(deliveries, func(a, b models.WebhookDelivery) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+	return deliveries, nil
+}
+
+// GetDeliveries retrieves up to limit deliveries of a webhook, all if limit is zero, newest first.
+func (s *DBStorage) GetDeliveries(ctx context.Context, webhookID string, limit int) ([]models.WebhookDelivery, error) {
+	var deliveries []models.WebhookDelivery
+	if err := s.getDeliveriesStmt.SelectContext(ctx, &deliveries, webhookID, limit); err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+// UpdateDelivery stores the status, attempts and outcome of the last attempt of a delivery.
+func (s *DBStorage) UpdateDelivery(ctx context.Context, d models.WebhookDelivery) error {
+	result, err := s.updateDeliveryStmt.ExecContext(ctx,
+		d.ID, d.Status, d.Attempts, d.ResponseStatus, d.LastError, d.NextAttemptAt, d.DeliveredAt,
+	)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// LeaseOutbox makes holder the only publisher of the outbox until leaseUntil unless another holder's lease
+// has not expired at now, and reports whether it did.
+func (s *DBStorage) LeaseOutbox(ctx context.Context, holder string, now time.Time, leaseUntil time.Time) (bool, error) {
+	result, err := s.leaseOutboxStmt.ExecContext(ctx, holder, now, leaseUntil)
+	if err != nil {
+		return false, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rowsAffected > 0, nil
+}
+
+// GetChanges retrieves up to limit changes recorded in the outbox, oldest first.
+func (s *DBStorage) GetChanges(ctx context.Context, limit int) ([]models.Change, error) {
+	var changes []models.Change
+	if err := s.getChangesStmt.SelectContext(ctx, &changes, limit); err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+// DeleteChanges removes published changes from the outbox.
+func (s *DBStorage) DeleteChanges(ctx context.Context, sequences []int64) error {
+	_, err := s.deleteChangesStmt.ExecContext(ctx, pq.Array(sequences))
+	return err
+}
+
+// SaveDomain inserts a domain added by a user into the database.
+func (s *DBStorage) SaveDomain(ctx context.Context, domain models.Domain) error {
+	result, err := s.saveDomainStmt.ExecContext(ctx, domain.Name, domain.UserID, domain.VerificationToken, domain.CreatedAt)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrAlreadyExist
+	}
+	return nil
+}
+
+// GetDomains retrieves the domains added by a user, oldest first.
+func (s *DBStorage) GetDomains(ctx context.Context, userID string) ([]models.Domain, error) {
+	var domains []models.Domain
+	if err := s.getDomainsStmt.SelectContext(ctx, &domains, userID); err != nil {
+		return nil, err
+	}
+	return domains, nil
+}
+
+// GetVerifiedDomain retrieves the domain with the name verified by any user.
+func (s *DBStorage) GetVerifiedDomain(ctx context.Context, name string) (models.Domain, error) {
+	var domain models.Domain
+	if err := s.getDomainStmt.GetContext(ctx, &domain, name); err != nil {
+		return models.Domain{}, notFound(err)
+	}
+	return domain, nil
+}
+
+// VerifyDomain marks a domain of a user as verified. The unique index on verified names
+// rejects the domain if another user verified it.
+func (s *DBStorage) VerifyDomain(ctx context.Context, userID string, name string, verifiedAt time.Time) error {
+	result, err := s.verifyDomainStmt.ExecContext(ctx, userID, name, verifiedAt)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+			return ErrAlreadyExist
+		}
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// CreateWorkspace inserts a workspace and its owner.
+func (s *DBStorage) CreateWorkspace(ctx context.Context, workspace models.Workspace, owner models.WorkspaceMember) error {
+	_, err := s.saveWorkspaceStmt.ExecContext(ctx,
+		workspace.ID, workspace.Name, workspace.CreatedAt, owner.UserID, owner.Role, owner.AddedAt)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+		return ErrAlreadyExist
+	}
+	return err
+}
+
+// GetWorkspaces retrieves the workspaces of a user with the role of the user, oldest first.
+func (s *DBStorage) GetWorkspaces(ctx context.Context, userID string) ([]models.Workspace, error) {
+	var workspaces []models.Workspace
+	if err := s.getWorkspacesStmt.SelectContext(ctx, &workspaces, userID); err != nil {
+		return nil, err
+	}
+	return workspaces, nil
+}
+
+// GetMember retrieves the membership of a user in a workspace.
+func (s *DBStorage) GetMember(ctx context.Context, workspaceID string, userID string) (models.WorkspaceMember, error) {
+	var member models.WorkspaceMember
+	if err := s.getMemberStmt.GetContext(ctx, &member, workspaceID, userID); err != nil {
+		return models.WorkspaceMember{}, notFound(err)
+	}
+	return member, nil
+}
+
+// GetMembers retrieves the members of a workspace, oldest first.
+func (s *DBStorage) GetMembers(ctx context.Context, workspaceID string) ([]models.WorkspaceMember, error) {
+	var members []models.WorkspaceMember
+	if err := s.getMembersStmt.SelectContext(ctx, &members, workspaceID); err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+// SaveMember inserts a member of a workspace or changes their role unless they are its only owner losing the role.
+func (s *DBStorage) SaveMember(ctx context.Context, member models.WorkspaceMember) error {
+	result, err := s.saveMemberStmt.ExecContext(ctx, member.WorkspaceID, member.UserID, member.Role, member.AddedAt)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrLastOwner
+	}
+	return nil
+}
+
+// DeleteMember deletes a member of a workspace unless they are its only owner.
+func (s *DBStorage) DeleteMember(ctx context.Context, workspaceID string, userID string) error {
+	result, err := s.deleteMemberStmt.ExecContext(ctx, workspaceID, userID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected > 0 {
+		return nil
+	}
+	// Nothing was deleted: tell a missing member from the last owner.
+	if _, err = s.GetMember(ctx, workspaceID, userID); err != nil {
+		return err
+	}
+	return ErrLastOwner
+}
+
+// GetPlan retrieves the name of the plan of a user.
+func (s *DBStorage) GetPlan(ctx context.Context, userID string) (string, error) {
+	var plan string
+	if err := s.getPlanStmt.GetContext(ctx, &plan, userID); err != nil {
+		return "", notFound(err)
+	}
+	return plan, nil
+}
+
+// SetPlan inserts or replaces the plan of a user.
+func (s *DBStorage) SetPlan(ctx context.Context, userID string, plan string) error {
+	_, err := s.setPlanStmt.ExecContext(ctx, userID, plan)
+	return err
+}
+
+// CountActiveURLs counts the URLs of a user, except deleted ones.
+func (s *DBStorage) CountActiveURLs(ctx context.Context, userID string) (int, error) {
+	var count int
+	if err := s.countActiveStmt.GetContext(ctx, &count, userID); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// GetUsage retrieves the number of URLs a user created in the period.
+func (s *DBStorage) GetUsage(ctx context.Context, userID string, period string) (int, error) {
+	var links int
+	if err := s.getUsageStmt.GetContext(ctx, &links, userID, period); err != nil {
+		return 0, err
+	}
+	return links, nil
+}
+
+// AddUsage adds n to the number of URLs a user created in the period in a single upsert,
+// so concurrent additions are not lost.
+func (s *DBStorage) AddUsage(ctx context.Context, userID string, period string, n int) error {
+	_, err := s.addUsageStmt.ExecContext(ctx, userID, period, n)
+	return err
+}
+
+// auditColumns is the number of columns inserted per entry by SaveAuditEntries.
+const auditColumns = 10
+
+// SaveAuditEntries inserts audit entries in a single statement.
+func (s *DBStorage) SaveAuditEntries(ctx context.Context, entries []models.AuditEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	var query strings.Builder
+	query.WriteString(`INSERT INTO audit_log (id, actor, action, target, before, after, ip, user_agent, transport, created_at) VALUES `)
+	args := make([]any, 0, len(entries)*auditColumns)
+	for i, e := range entries {
+		if i > 0 {
+			query.WriteString(", ")
+		}
+		n := len(args)
+		fmt.Fprintf(&query, "($%d::uuid, $%d, $%d, $%d, $%d::jsonb, $%d::jsonb, $%d, $%d, $%d, $%d)",
+			n+1, n+2, n+3, n+4, n+5, n+6, n+7, n+8, n+9, n+10)
+		args = append(args, e.ID, e.Actor, e.Action, e.Target, jsonArg(e.Before), jsonArg(e.After),
+			e.IP, e.UserAgent, e.Transport, e.CreatedAt)
+	}
+
+	_, err := s.db.ExecContext(ctx, query.String(), args...)
+	return err
+}
+
+// jsonArg returns a JSON value as a query argument: a string, as byte slices are sent as bytea, or NULL if it is empty.
+func jsonArg(value json.RawMessage) any {
+	if len(value) == 0 {
+		return nil
+	}
+	return string(value)
+}
+
+// GetAuditEntries retrieves the audit entries matching the filter, newest first.
+func (s *DBStorage) GetAuditEntries(ctx context.Context, filter models.AuditFilter) ([]models.AuditEntry, error) {
+	var entries []models.AuditEntry
+	err := s.getAuditStmt.SelectContext(ctx, &entries, nullString(filter.Actor), nullString(filter.Action),
+		nullString(filter.Target), nullTime(filter.Since), nullTime(filter.Until), nullInt(filter.Limit))
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// PurgeAuditEntries deletes audit entries made before createdBefore.
+func (s *DBStorage) PurgeAuditEntries(ctx context.Context, createdBefore time.Time) (int64, error) {
+	result, err := s.purgeAuditStmt.ExecContext(ctx, createdBefore)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// nullString returns s as a query argument, NULL if it is empty.
+func nullString(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// nullTime returns t as a query argument, NULL if it is zero.
+func nullTime(t time.Time) any {
+	if t.IsZero() {
+		return nil
+	}
+	return t
+}
+
+// nullInt returns n as a query argument, NULL if it is zero.
+func nullInt(n int) any {
+	if n == 0 {
+		return nil
+	}
+	return n
+}
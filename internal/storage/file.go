@@ -4,16 +4,54 @@ import (
 	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
+	"maps"
 	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
 
 	"github.com/grnsv/shortener/internal/models"
 )
 
 // FileStorage implements persistent storage using a file and in-memory cache.
+// API keys, the history of URL destinations, webhooks, their deliveries, custom domains, workspaces, their members,
+// the plans of users, their usage and the audit log are kept in separate files next to the main one.
 type FileStorage struct {
-	file   *os.File
-	writer *bufio.Writer
-	memory *MemoryStorage
+	file           *os.File
+	writer         *bufio.Writer
+	memory         *MemoryStorage
+	mu             sync.Mutex // guards writes to the main and history files
+	keysPath       string
+	keysMu         sync.Mutex
+	historyPath    string
+	hooksMu        sync.Mutex // guards writes to the webhooks and deliveries files
+	webhooksPath   string
+	deliveriesPath string
+	domainsMu      sync.Mutex // guards writes to the domains file
+	domainsPath    string
+	workspacesMu   sync.Mutex // guards writes to the workspaces and members files
+	workspacesPath string
+	membersPath    string
+	quotaMu        sync.Mutex // guards writes to the plans and usage files
+	plansPath      string
+	usagePath      string
+	auditMu        sync.Mutex // guards writes to the audit file
+	auditPath      string
+}
+
+// planRecord is a line of the plans file.
+type planRecord struct {
+	UserID string `json:"user_id"`
+	Plan   string `json:"plan"`
+}
+
+// usageRecord is a line of the usage file: the number of URLs a user created in a period.
+type usageRecord struct {
+	UserID string `json:"user_id"`
+	Period string `json:"period"`
+	Links  int    `json:"links"`
 }
 
 // NewFileStorage creates a new FileStorage instance with the given file path.
@@ -28,9 +66,19 @@ func NewFileStorage(ctx context.Context, path string) (*FileStorage, error) {
 	}
 
 	storage := &FileStorage{
-		file:   file,
-		writer: writer,
-		memory: memory,
+		file:           file,
+		writer:         writer,
+		memory:         memory,
+		keysPath:       path + ".keys",
+		historyPath:    path + ".history",
+		webhooksPath:   path + ".webhooks",
+		deliveriesPath: path + ".deliveries",
+		domainsPath:    path + ".domains",
+		workspacesPath: path + ".workspaces",
+		membersPath:    path + ".members",
+		plansPath:      path + ".plans",
+		usagePath:      path + ".usage",
+		auditPath:      path + ".audit",
 	}
 	if err = storage.loadFromFile(ctx); err != nil {
 		return nil, err
@@ -48,6 +96,9 @@ func openFile(path string) (*os.File, *bufio.Writer, error) {
 	return file, bufio.NewWriter(file), nil
 }
 
+// loadFromFile restores URLs, API keys, URL history, webhooks, their deliveries, domains, workspaces,
+// their members, plans, usage and the audit log into memory. URLs, deliveries, members, plans and usage are stored as append-only logs,
+// so a later line for the same short URL, delivery, member, user or period replaces an earlier one.
 func (s *FileStorage) loadFromFile(ctx context.Context) error {
 	var err error
 	scanner := bufio.NewScanner(s.file)
@@ -57,12 +108,82 @@ func (s *FileStorage) loadFromFile(ctx context.Context) error {
 			return err
 		}
 
-		if err = s.memory.Save(ctx, *model); err != nil {
-			return err
-		}
+		s.memory.store(*model)
 	}
 
-	return nil
+	err = loadJSONLines(s.keysPath, func(key models.APIKey) error {
+		return s.memory.SaveAPIKey(ctx, key)
+	})
+	if err != nil {
+		return err
+	}
+
+	err = loadJSONLines(s.historyPath, func(entry models.URLHistory) error {
+		s.memory.history[entry.ShortURL] = append(s.memory.history[entry.ShortURL], entry)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	err = loadJSONLines(s.webhooksPath, func(hook models.Webhook) error {
+		return s.memory.SaveWebhook(ctx, hook)
+	})
+	if err != nil {
+		return err
+	}
+
+	err = loadJSONLines(s.deliveriesPath, func(delivery models.WebhookDelivery) error {
+		s.memory.deliveries[delivery.ID] = delivery
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	err = loadJSONLines(s.domainsPath, func(domain models.Domain) error {
+		return s.memory.SaveDomain(ctx, domain)
+	})
+	if err != nil {
+		return err
+	}
+
+	err = loadJSONLines(s.workspacesPath, func(workspace models.Workspace) error {
+		s.memory.workspaces[workspace.ID] = workspace
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	err = loadJSONLines(s.membersPath, func(member models.WorkspaceMember) error {
+		s.memory.members[memberKey{workspaceID: member.WorkspaceID, userID: member.UserID}] = member
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	err = loadJSONLines(s.plansPath, func(record planRecord) error {
+		s.memory.plans[record.UserID] = record.Plan
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	err = loadJSONLines(s.usagePath, func(record usageRecord) error {
+		s.memory.usage[usageKey{userID: record.UserID, period: record.Period}] = record.Links
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return loadJSONLines(s.auditPath, func(entry models.AuditEntry) error {
+		s.memory.audit = append(s.memory.audit, entry)
+		return nil
+	})
 }
 
 // Close closes the underlying file and memory storage.
@@ -76,35 +197,47 @@ func (s *FileStorage) Close() error {
 	return s.file.Close()
 }
 
-// Save persists a single URL model to file and memory.
+// Save persists a single URL model to memory and file.
 func (s *FileStorage) Save(ctx context.Context, model models.URL) error {
-	if err := json.NewEncoder(s.writer).Encode(model); err != nil {
-		return err
-	}
-	if err := s.writer.Flush(); err != nil {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.memory.Save(ctx, model); err != nil {
 		return err
 	}
 
-	return s.memory.Save(ctx, model)
+	return s.append(model)
 }
 
-// SaveMany persists multiple URL models to file and memory.
-func (s *FileStorage) SaveMany(ctx context.Context, models []models.URL) error {
-	encoder := json.NewEncoder(s.writer)
-	for _, model := range models {
-		if err := encoder.Encode(model); err != nil {
-			return err
+// SaveMany persists multiple URL models to memory and file, keeping existing ones.
+// The result of a model is ErrAlreadyExist if its short URL was taken.
+func (s *FileStorage) SaveMany(ctx context.Context, models []models.URL) ([]error, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	results := make([]error, len(models))
+	for i, model := range models {
+		if results[i] = s.memory.Save(ctx, model); results[i] != nil {
+			continue
+		}
+		if err := json.NewEncoder(s.writer).Encode(model); err != nil {
+			return nil, err
 		}
 	}
-	if err := s.writer.Flush(); err != nil {
+
+	return results, s.writer.Flush()
+}
+
+// append writes a URL model to the end of the file.
+func (s *FileStorage) append(model models.URL) error {
+	if err := json.NewEncoder(s.writer).Encode(model); err != nil {
 		return err
 	}
-
-	return s.memory.SaveMany(ctx, models)
+	return s.writer.Flush()
 }
 
-// Get retrieves the original URL for a given short URL from memory.
-func (s *FileStorage) Get(ctx context.Context, short string) (string, error) {
+// Get retrieves the URL model for a given short URL from memory.
+func (s *FileStorage) Get(ctx context.Context, short string) (models.URL, error) {
 	return s.memory.Get(ctx, short)
 }
 
@@ -113,48 +246,100 @@ func (s *FileStorage) Ping(ctx context.Context) error {
 	return nil
 }
 
-// GetAll returns all URL models for a given user from memory.
-func (s *FileStorage) GetAll(ctx context.Context, userID string) ([]models.URL, error) {
-	return s.memory.GetAll(ctx, userID)
+// GetAll returns the URL models of a given user matching the filter from memory.
+func (s *FileStorage) GetAll(ctx context.Context, userID string, filter models.URLFilter) ([]models.URL, error) {
+	return s.memory.GetAll(ctx, userID, filter)
+}
+
+// IterateAll streams the URLs of a user from memory.
+func (s *FileStorage) IterateAll(ctx context.Context, userID string) URLSeq {
+	return s.memory.IterateAll(ctx, userID)
+}
+
+// Search returns up to limit URLs of a user matching the query from memory.
+func (s *FileStorage) Search(ctx context.Context, userID string, query string, limit int) ([]models.URL, error) {
+	return s.memory.Search(ctx, userID, query, limit)
 }
 
-// DeleteMany deletes multiple short URLs for a user and updates the file.
+// DeleteMany soft-deletes multiple short URLs for a user and appends the deleted models to the file.
 func (s *FileStorage) DeleteMany(ctx context.Context, userID string, shortURLs []string) error {
-	if err := s.memory.DeleteMany(ctx, userID, shortURLs); err != nil {
-		return err
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, url := range s.memory.deleteMany(userID, shortURLs, time.Now().UTC()) {
+		if err := json.NewEncoder(s.writer).Encode(url); err != nil {
+			return err
+		}
 	}
 
-	tempFile, err := os.CreateTemp("", "storage")
+	return s.writer.Flush()
+}
+
+// Restore undeletes a user's short URL deleted after deletedAfter and appends the restored model to the file.
+func (s *FileStorage) Restore(ctx context.Context, userID string, short string, deletedAfter time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	url, err := s.memory.restore(userID, short, deletedAfter)
 	if err != nil {
 		return err
 	}
 
-	writer := bufio.NewWriter(tempFile)
+	return s.append(url)
+}
 
-	encoder := json.NewEncoder(writer)
-	s.memory.urls.Range(func(key, value interface{}) bool {
-		if err = encoder.Encode(value.(models.URL)); err != nil {
-			return false
-		}
+// Purge permanently removes short URLs deleted before deletedBefore and compacts the files.
+func (s *FileStorage) Purge(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	purged, err := s.memory.Purge(ctx, deletedBefore)
+	if err != nil || purged == 0 {
+		return purged, err
+	}
+
+	return purged, s.compact()
+}
+
+// compact rewrites the main, history and deliveries files from memory, dropping superseded and purged entries.
+func (s *FileStorage) compact() error {
+	if err := s.writer.Flush(); err != nil {
+		return err
+	}
+
+	var urls []models.URL
+	s.memory.urls.Range(func(_, value any) bool {
+		urls = append(urls, value.(models.URL))
 		return true
 	})
-
-	if err = writer.Flush(); err != nil {
+	if err := dumpJSONLines(s.file.Name(), urls); err != nil {
 		return err
 	}
-	if err = tempFile.Close(); err != nil {
+
+	var history []models.URLHistory
+	s.memory.mu.Lock()
+	for _, entries := range s.memory.history {
+		history = append(history, entries...)
+	}
+	s.memory.mu.Unlock()
+	if err := dumpJSONLines(s.historyPath, history); err != nil {
 		return err
 	}
-	if err = s.file.Close(); err != nil {
+	s.hooksMu.Lock()
+	err := s.dumpDeliveries()
+	s.hooksMu.Unlock()
+	if err != nil {
 		return err
 	}
-	if err = os.Rename(tempFile.Name(), s.file.Name()); err != nil {
+
+	if err = s.file.Close(); err != nil {
 		return err
 	}
-	s.file, s.writer, err = openFile(s.file.Name())
+	file, writer, err := openFile(s.file.Name())
 	if err != nil {
 		return err
 	}
+	s.file, s.writer = file, writer
 
 	return nil
 }
@@ -163,3 +348,432 @@ func (s *FileStorage) DeleteMany(ctx context.Context, userID string, shortURLs [
 func (s *FileStorage) GetStats(ctx context.Context, stats *models.Stats) error {
 	return s.memory.GetStats(ctx, stats)
 }
+
+// PatchURL applies the changes of the patch to a user's short URL, appends the updated model to the file
+// and the previous destination, if it changes, to the history file.
+func (s *FileStorage) PatchURL(ctx context.Context, userID string, short string, patch models.URLPatch, changedAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	before, err := s.memory.GetURLHistory(ctx, short)
+	if err != nil {
+		return err
+	}
+	if err = s.memory.PatchURL(ctx, userID, short, patch, changedAt); err != nil {
+		return err
+	}
+
+	value, _ := s.memory.urls.Load(short)
+	if err = s.append(value.(models.URL)); err != nil {
+		return err
+	}
+
+	history, err := s.memory.GetURLHistory(ctx, short)
+	if err != nil || len(history) == len(before) {
+		return err
+	}
+	return appendJSONLine(s.historyPath, history[len(history)-1])
+}
+
+// SetMetadata replaces the metadata of a user's short URL and appends the updated model to the file.
+func (s *FileStorage) SetMetadata(ctx context.Context, userID string, short string, meta models.URLMetadata) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.memory.SetMetadata(ctx, userID, short, meta); err != nil {
+		return err
+	}
+
+	value, _ := s.memory.urls.Load(short)
+	return s.append(value.(models.URL))
+}
+
+// RecordFirstClick sets the first click time of a short URL unless it is already set
+// and appends the updated model to the file if it was.
+func (s *FileStorage) RecordFirstClick(ctx context.Context, short string, clickedAt time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	url, first, err := s.memory.recordFirstClick(short, clickedAt)
+	if err != nil || !first {
+		return first, err
+	}
+
+	return true, s.append(url)
+}
+
+// GetURLHistory returns the previous destinations of a short URL from memory.
+func (s *FileStorage) GetURLHistory(ctx context.Context, short string) ([]models.URLHistory, error) {
+	return s.memory.GetURLHistory(ctx, short)
+}
+
+// SaveAPIKey persists an API key to the keys file and memory.
+func (s *FileStorage) SaveAPIKey(ctx context.Context, key models.APIKey) error {
+	s.keysMu.Lock()
+	defer s.keysMu.Unlock()
+	if err := s.memory.SaveAPIKey(ctx, key); err != nil {
+		return err
+	}
+	return s.dumpKeys()
+}
+
+// GetAPIKeys returns all API keys of a user from memory.
+func (s *FileStorage) GetAPIKeys(ctx context.Context, userID string) ([]models.APIKey, error) {
+	return s.memory.GetAPIKeys(ctx, userID)
+}
+
+// GetAPIKeyByHash retrieves an API key by the hash of its value from memory.
+func (s *FileStorage) GetAPIKeyByHash(ctx context.Context, hash string) (models.APIKey, error) {
+	return s.memory.GetAPIKeyByHash(ctx, hash)
+}
+
+// RevokeAPIKey marks an API key of a user as revoked and updates the keys file.
+func (s *FileStorage) RevokeAPIKey(ctx context.Context, userID string, id string, revokedAt time.Time) error {
+	s.keysMu.Lock()
+	defer s.keysMu.Unlock()
+	if err := s.memory.RevokeAPIKey(ctx, userID, id, revokedAt); err != nil {
+		return err
+	}
+	return s.dumpKeys()
+}
+
+// TouchAPIKey updates the last usage time of an API key and the keys file.
+func (s *FileStorage) TouchAPIKey(ctx context.Context, id string, usedAt time.Time) error {
+	s.keysMu.Lock()
+	defer s.keysMu.Unlock()
+	if err := s.memory.TouchAPIKey(ctx, id, usedAt); err != nil {
+		return err
+	}
+	return s.dumpKeys()
+}
+
+func (s *FileStorage) dumpKeys() error {
+	var keys []models.APIKey
+	s.memory.keys.Range(func(_, value any) bool {
+		keys = append(keys, value.(models.APIKey))
+		return true
+	})
+	return dumpJSONLines(s.keysPath, keys)
+}
+
+// SaveWebhook persists a webhook to the webhooks file and memory.
+func (s *FileStorage) SaveWebhook(ctx context.Context, hook models.Webhook) error {
+	s.hooksMu.Lock()
+	defer s.hooksMu.Unlock()
+	if err := s.memory.SaveWebhook(ctx, hook); err != nil {
+		return err
+	}
+	return appendJSONLine(s.webhooksPath, hook)
+}
+
+// GetWebhook retrieves a webhook by its ID from memory.
+func (s *FileStorage) GetWebhook(ctx context.Context, id string) (models.Webhook, error) {
+	return s.memory.GetWebhook(ctx, id)
+}
+
+// GetWebhooks returns all webhooks of a user from memory.
+func (s *FileStorage) GetWebhooks(ctx context.Context, userID string) ([]models.Webhook, error) {
+	return s.memory.GetWebhooks(ctx, userID)
+}
+
+// DeleteWebhook removes a webhook of a user and its deliveries and rewrites the webhooks and deliveries files.
+func (s *FileStorage) DeleteWebhook(ctx context.Context, userID string, id string) error {
+	s.hooksMu.Lock()
+	defer s.hooksMu.Unlock()
+	if err := s.memory.DeleteWebhook(ctx, userID, id); err != nil {
+		return err
+	}
+
+	s.memory.hooksMu.Lock()
+	hooks := slices.Collect(maps.Values(s.memory.webhooks))
+	s.memory.hooksMu.Unlock()
+	if err := dumpJSONLines(s.webhooksPath, hooks); err != nil {
+		return err
+	}
+	return s.dumpDeliveries()
+}
+
+// SaveDeliveries appends webhook deliveries to the deliveries file and stores them in memory.
+func (s *FileStorage) SaveDeliveries(ctx context.Context, deliveries []models.WebhookDelivery) error {
+	s.hooksMu.Lock()
+	defer s.hooksMu.Unlock()
+	if err := s.memory.SaveDeliveries(ctx, deliveries); err != nil {
+		return err
+	}
+	for _, delivery := range deliveries {
+		if err := appendJSONLine(s.deliveriesPath, delivery); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ClaimDeliveries returns up to limit pending deliveries due at now from memory and postpones their next attempt
+// to leaseUntil. The lease is not persisted: claimed deliveries are due again after a restart.
+func (s *FileStorage) ClaimDeliveries(ctx context.Context, now time.Time, leaseUntil time.Time, limit int) ([]models.WebhookDelivery, error) {
+	return s.memory.ClaimDeliveries(ctx, now, leaseUntil, limit)
+}
+
+// GetDeliveries returns up to limit deliveries of a webhook from memory.
+func (s *FileStorage) GetDeliveries(ctx context.Context, webhookID string, limit int) ([]models.WebhookDelivery, error) {
+	return s.memory.GetDeliveries(ctx, webhookID, limit)
+}
+
+// UpdateDelivery replaces a webhook delivery in memory and appends it to the deliveries file.
+func (s *FileStorage) UpdateDelivery(ctx context.Context, delivery models.WebhookDelivery) error {
+	s.hooksMu.Lock()
+	defer s.hooksMu.Unlock()
+	if err := s.memory.UpdateDelivery(ctx, delivery); err != nil {
+		return err
+	}
+	return appendJSONLine(s.deliveriesPath, delivery)
+}
+
+// dumpDeliveries rewrites the deliveries file from memory, dropping superseded entries.
+func (s *FileStorage) dumpDeliveries() error {
+	s.memory.hooksMu.Lock()
+	deliveries := slices.Collect(maps.Values(s.memory.deliveries))
+	s.memory.hooksMu.Unlock()
+	return dumpJSONLines(s.deliveriesPath, deliveries)
+}
+
+// SaveDomain persists a domain to the domains file and memory.
+func (s *FileStorage) SaveDomain(ctx context.Context, domain models.Domain) error {
+	s.domainsMu.Lock()
+	defer s.domainsMu.Unlock()
+	if err := s.memory.SaveDomain(ctx, domain); err != nil {
+		return err
+	}
+	return appendJSONLine(s.domainsPath, domain)
+}
+
+// GetDomains returns the domains added by a user from memory.
+func (s *FileStorage) GetDomains(ctx context.Context, userID string) ([]models.Domain, error) {
+	return s.memory.GetDomains(ctx, userID)
+}
+
+// GetVerifiedDomain retrieves the domain with the name verified by any user from memory.
+func (s *FileStorage) GetVerifiedDomain(ctx context.Context, name string) (models.Domain, error) {
+	return s.memory.GetVerifiedDomain(ctx, name)
+}
+
+// VerifyDomain marks a domain of a user as verified and rewrites the domains file.
+func (s *FileStorage) VerifyDomain(ctx context.Context, userID string, name string, verifiedAt time.Time) error {
+	s.domainsMu.Lock()
+	defer s.domainsMu.Unlock()
+	if err := s.memory.VerifyDomain(ctx, userID, name, verifiedAt); err != nil {
+		return err
+	}
+
+	s.memory.domainsMu.Lock()
+	domains := slices.Collect(maps.Values(s.memory.domains))
+	s.memory.domainsMu.Unlock()
+	return dumpJSONLines(s.domainsPath, domains)
+}
+
+// CreateWorkspace persists a workspace and its owner to the workspaces and members files and memory.
+func (s *FileStorage) CreateWorkspace(ctx context.Context, workspace models.Workspace, owner models.WorkspaceMember) error {
+	s.workspacesMu.Lock()
+	defer s.workspacesMu.Unlock()
+	if err := s.memory.CreateWorkspace(ctx, workspace, owner); err != nil {
+		return err
+	}
+	workspace.Role = ""
+	if err := appendJSONLine(s.workspacesPath, workspace); err != nil {
+		return err
+	}
+	return appendJSONLine(s.membersPath, owner)
+}
+
+// GetWorkspaces returns the workspaces of a user from memory.
+func (s *FileStorage) GetWorkspaces(ctx context.Context, userID string) ([]models.Workspace, error) {
+	return s.memory.GetWorkspaces(ctx, userID)
+}
+
+// GetMember retrieves the membership of a user in a workspace from memory.
+func (s *FileStorage) GetMember(ctx context.Context, workspaceID string, userID string) (models.WorkspaceMember, error) {
+	return s.memory.GetMember(ctx, workspaceID, userID)
+}
+
+// GetMembers returns the members of a workspace from memory.
+func (s *FileStorage) GetMembers(ctx context.Context, workspaceID string) ([]models.WorkspaceMember, error) {
+	return s.memory.GetMembers(ctx, workspaceID)
+}
+
+// SaveMember adds or updates a member of a workspace and appends it to the members file.
+func (s *FileStorage) SaveMember(ctx context.Context, member models.WorkspaceMember) error {
+	s.workspacesMu.Lock()
+	defer s.workspacesMu.Unlock()
+	if err := s.memory.SaveMember(ctx, member); err != nil {
+		return err
+	}
+	member, err := s.memory.GetMember(ctx, member.WorkspaceID, member.UserID)
+	if err != nil {
+		return err
+	}
+	return appendJSONLine(s.membersPath, member)
+}
+
+// DeleteMember removes a member of a workspace and rewrites the members file.
+func (s *FileStorage) DeleteMember(ctx context.Context, workspaceID string, userID string) error {
+	s.workspacesMu.Lock()
+	defer s.workspacesMu.Unlock()
+	if err := s.memory.DeleteMember(ctx, workspaceID, userID); err != nil {
+		return err
+	}
+
+	s.memory.workspacesMu.Lock()
+	members := slices.Collect(maps.Values(s.memory.members))
+	s.memory.workspacesMu.Unlock()
+	return dumpJSONLines(s.membersPath, members)
+}
+
+// GetPlan returns the name of the plan of a user from memory.
+func (s *FileStorage) GetPlan(ctx context.Context, userID string) (string, error) {
+	return s.memory.GetPlan(ctx, userID)
+}
+
+// SetPlan stores the plan of a user and appends it to the plans file.
+func (s *FileStorage) SetPlan(ctx context.Context, userID string, plan string) error {
+	s.quotaMu.Lock()
+	defer s.quotaMu.Unlock()
+	if err := s.memory.SetPlan(ctx, userID, plan); err != nil {
+		return err
+	}
+	return appendJSONLine(s.plansPath, planRecord{UserID: userID, Plan: plan})
+}
+
+// CountActiveURLs counts the URLs of a user in memory, except deleted ones.
+func (s *FileStorage) CountActiveURLs(ctx context.Context, userID string) (int, error) {
+	return s.memory.CountActiveURLs(ctx, userID)
+}
+
+// GetUsage returns the number of URLs a user created in the period from memory.
+func (s *FileStorage) GetUsage(ctx context.Context, userID string, period string) (int, error) {
+	return s.memory.GetUsage(ctx, userID, period)
+}
+
+// AddUsage adds n to the number of URLs a user created in the period and appends the new number to the usage file.
+func (s *FileStorage) AddUsage(ctx context.Context, userID string, period string, n int) error {
+	s.quotaMu.Lock()
+	defer s.quotaMu.Unlock()
+	if err := s.memory.AddUsage(ctx, userID, period, n); err != nil {
+		return err
+	}
+	links, err := s.memory.GetUsage(ctx, userID, period)
+	if err != nil {
+		return err
+	}
+	return appendJSONLine(s.usagePath, usageRecord{UserID: userID, Period: period, Links: links})
+}
+
+// SaveAuditEntries appends audit entries to memory and the audit file.
+func (s *FileStorage) SaveAuditEntries(ctx context.Context, entries []models.AuditEntry) error {
+	s.auditMu.Lock()
+	defer s.auditMu.Unlock()
+	if err := s.memory.SaveAuditEntries(ctx, entries); err != nil {
+		return err
+	}
+	return appendJSONLines(s.auditPath, entries)
+}
+
+// GetAuditEntries returns the audit entries matching the filter from memory.
+func (s *FileStorage) GetAuditEntries(ctx context.Context, filter models.AuditFilter) ([]models.AuditEntry, error) {
+	return s.memory.GetAuditEntries(ctx, filter)
+}
+
+// PurgeAuditEntries removes audit entries made before createdBefore and rewrites the audit file if any were removed.
+func (s *FileStorage) PurgeAuditEntries(ctx context.Context, createdBefore time.Time) (int64, error) {
+	s.auditMu.Lock()
+	defer s.auditMu.Unlock()
+	purged, err := s.memory.PurgeAuditEntries(ctx, createdBefore)
+	if err != nil || purged == 0 {
+		return purged, err
+	}
+
+	s.memory.auditMu.Lock()
+	entries := slices.Clone(s.memory.audit)
+	s.memory.auditMu.Unlock()
+	return purged, dumpJSONLines(s.auditPath, entries)
+}
+
+// loadJSONLines decodes every line of the file at path and passes it to fn.
+// A missing file is treated as empty.
+func loadJSONLines[T any](path string, fn func(T) error) (err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	defer func() {
+		err = errors.Join(err, file.Close())
+	}()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var item T
+		if err = json.Unmarshal(scanner.Bytes(), &item); err != nil {
+			return err
+		}
+		if err = fn(item); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// appendJSONLine appends item encoded as a JSON line to the file at path, creating it if needed.
+func appendJSONLine[T any](path string, item T) error {
+	return appendJSONLines(path, []T{item})
+}
+
+// appendJSONLines appends items encoded as JSON lines to the file at path, creating it if needed.
+func appendJSONLines[T any](path string, items []T) (err error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err = errors.Join(err, file.Close())
+	}()
+
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+	for _, item := range items {
+		if err = encoder.Encode(item); err != nil {
+			return err
+		}
+	}
+	return writer.Flush()
+}
+
+// dumpJSONLines atomically replaces the file at path with items encoded as JSON lines.
+func dumpJSONLines[T any](path string, items []T) error {
+	tempFile, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+
+	writer := bufio.NewWriter(tempFile)
+	encoder := json.NewEncoder(writer)
+	for _, item := range items {
+		if err = encoder.Encode(item); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = writer.Flush()
+	}
+	if closeErr := tempFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tempFile.Name(), path)
+	}
+	if err != nil {
+		return errors.Join(err, os.Remove(tempFile.Name()))
+	}
+	return nil
+}
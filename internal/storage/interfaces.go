@@ -4,6 +4,8 @@ import (
 	"context"
 	"database/sql"
 	"io"
+	"iter"
+	"time"
 
 	"github.com/grnsv/shortener/internal/models"
 	"github.com/jmoiron/sqlx"
@@ -11,11 +13,23 @@ import (
 
 //go:generate go tool mockgen -destination=../mocks/mock_storage.go -package=mocks github.com/grnsv/shortener/internal/storage Storage,DB,Stmt
 
-// Storage is the main interface that combines Saver, Retriever, Deleter, Pinger, and Closer interfaces.
+// Storage is the main interface that combines Saver, Retriever, Streamer, Searcher, Deleter, Restorer, Updater, ClickRecorder,
+// KeyStorage, WebhookStorage, DomainStorage, WorkspaceStorage, QuotaStorage, AuditStorage, Pinger, and Closer interfaces.
 type Storage interface {
 	Saver
 	Retriever
+	Streamer
+	Searcher
 	Deleter
+	Restorer
+	Updater
+	ClickRecorder
+	KeyStorage
+	WebhookStorage
+	DomainStorage
+	WorkspaceStorage
+	QuotaStorage
+	AuditStorage
 	Pinger
 	Closer
 }
@@ -23,21 +37,160 @@ type Storage interface {
 // Saver provides methods for saving URL models.
 type Saver interface {
 	Save(ctx context.Context, model models.URL) error
-	SaveMany(ctx context.Context, models []models.URL) error
+	// SaveMany saves the models atomically. It returns one result per model: nil if the model was
+	// saved, or ErrAlreadyExist if its short URL was taken, including by an earlier model of the batch.
+	SaveMany(ctx context.Context, models []models.URL) ([]error, error)
 }
 
 // Retriever provides methods for retrieving URL models.
 type Retriever interface {
-	Get(ctx context.Context, short string) (string, error)
-	GetAll(ctx context.Context, userID string) ([]models.URL, error)
+	Get(ctx context.Context, short string) (models.URL, error)
+	GetAll(ctx context.Context, userID string, filter models.URLFilter) ([]models.URL, error)
 	GetStats(ctx context.Context, stats *models.Stats) error
 }
 
-// Deleter provides a method for deleting multiple short URLs for a user.
+// Streamer provides a method to go through all URLs of a user without loading them at once.
+type Streamer interface {
+	// IterateAll yields the URLs of a user, except deleted ones. Iteration stops at the first error.
+	IterateAll(ctx context.Context, userID string) URLSeq
+}
+
+// URLSeq is a sequence of URLs, each paired with the error that stopped the iteration, if any.
+type URLSeq iter.Seq2[models.URL, error]
+
+// Searcher provides full-text search over the URLs of a user.
+type Searcher interface {
+	// Search returns up to limit URLs of a user, all if limit is zero, except deleted ones, whose short code, destination, title
+	// or tags contain a word starting with each word of the query, best matches first.
+	Search(ctx context.Context, userID string, query string, limit int) ([]models.URL, error)
+}
+
+// Deleter provides a method for soft-deleting multiple short URLs for a user.
+// Deleted URLs are kept, marked with the deletion time, until they are purged.
 type Deleter interface {
 	DeleteMany(ctx context.Context, userID string, shortURLs []string) error
 }
 
+// Restorer provides methods for restoring soft-deleted short URLs and permanently removing them.
+type Restorer interface {
+	// Restore undeletes a user's short URL deleted after deletedAfter.
+	Restore(ctx context.Context, userID string, short string, deletedAfter time.Time) error
+	// Purge permanently removes short URLs deleted before deletedBefore and returns their number.
+	Purge(ctx context.Context, deletedBefore time.Time) (int64, error)
+}
+
+// Updater provides methods for changing the destination, settings and metadata of a short URL.
+// Every change of the destination records the previous one in the URL's history.
+type Updater interface {
+	// PatchURL applies all changes of the patch to a user's short URL or none of them, and records
+	// the previous destination in the history of the URL if it changes.
+	PatchURL(ctx context.Context, userID string, short string, patch models.URLPatch, changedAt time.Time) error
+	GetURLHistory(ctx context.Context, short string) ([]models.URLHistory, error)
+	SetMetadata(ctx context.Context, userID string, short string, meta models.URLMetadata) error
+}
+
+// KeyStorage provides methods for managing API keys.
+type KeyStorage interface {
+	SaveAPIKey(ctx context.Context, key models.APIKey) error
+	GetAPIKeys(ctx context.Context, userID string) ([]models.APIKey, error)
+	GetAPIKeyByHash(ctx context.Context, hash string) (models.APIKey, error)
+	RevokeAPIKey(ctx context.Context, userID string, id string, revokedAt time.Time) error
+	TouchAPIKey(ctx context.Context, id string, usedAt time.Time) error
+}
+
+// ClickRecorder provides a method to record when a short URL is first followed.
+type ClickRecorder interface {
+	// RecordFirstClick sets the first click time of a short URL unless it is already set,
+	// and reports whether it was.
+	RecordFirstClick(ctx context.Context, short string, clickedAt time.Time) (bool, error)
+}
+
+// WebhookStorage provides methods for managing webhooks and queueing their deliveries.
+type WebhookStorage interface {
+	SaveWebhook(ctx context.Context, hook models.Webhook) error
+	GetWebhook(ctx context.Context, id string) (models.Webhook, error)
+	GetWebhooks(ctx context.Context, userID string) ([]models.Webhook, error)
+	// DeleteWebhook removes a webhook of a user together with its deliveries.
+	DeleteWebhook(ctx context.Context, userID string, id string) error
+	SaveDeliveries(ctx context.Context, deliveries []models.WebhookDelivery) error
+	// ClaimDeliveries returns up to limit pending deliveries whose next attempt is not after now, oldest first,
+	// and postpones their next attempt to leaseUntil, so other workers skip them while they are sent
+	// and they are retried if the worker stops before updating them.
+	ClaimDeliveries(ctx context.Context, now time.Time, leaseUntil time.Time, limit int) ([]models.WebhookDelivery, error)
+	// GetDeliveries returns up to limit deliveries of a webhook, newest first.
+	GetDeliveries(ctx context.Context, webhookID string, limit int) ([]models.WebhookDelivery, error)
+	// UpdateDelivery stores the status, attempts and outcome of the last attempt of a delivery.
+	UpdateDelivery(ctx context.Context, delivery models.WebhookDelivery) error
+}
+
+// DomainStorage provides methods for managing custom domains. Any number of users may add the same name,
+// but only one of them can verify it, so a domain never serves the links of two users.
+type DomainStorage interface {
+	// SaveDomain stores a domain added by a user. It returns ErrAlreadyExist if the user already added the name.
+	SaveDomain(ctx context.Context, domain models.Domain) error
+	// GetDomains returns the domains added by a user, oldest first.
+	GetDomains(ctx context.Context, userID string) ([]models.Domain, error)
+	// GetVerifiedDomain returns the domain with the name verified by any user, or ErrNotFound.
+	GetVerifiedDomain(ctx context.Context, name string) (models.Domain, error)
+	// VerifyDomain marks a domain of a user as verified at verifiedAt unless it already is. It returns ErrNotFound
+	// if the user has not added the name and ErrAlreadyExist if another user verified it.
+	VerifyDomain(ctx context.Context, userID string, name string, verifiedAt time.Time) error
+}
+
+// WorkspaceStorage provides methods for managing workspaces and their members.
+// A workspace always keeps at least one owner.
+type WorkspaceStorage interface {
+	// CreateWorkspace stores a workspace together with its first member, the owner, atomically.
+	CreateWorkspace(ctx context.Context, workspace models.Workspace, owner models.WorkspaceMember) error
+	// GetWorkspaces returns the workspaces a user is a member of with the role of the user, oldest first.
+	GetWorkspaces(ctx context.Context, userID string) ([]models.Workspace, error)
+	// GetMember returns the membership of a user in a workspace, or ErrNotFound.
+	GetMember(ctx context.Context, workspaceID string, userID string) (models.WorkspaceMember, error)
+	// GetMembers returns the members of a workspace, oldest first.
+	GetMembers(ctx context.Context, workspaceID string) ([]models.WorkspaceMember, error)
+	// SaveMember adds a member to a workspace or changes the role of an existing one, keeping the time they were added.
+	// It returns ErrLastOwner if the member is the only owner and loses the role.
+	SaveMember(ctx context.Context, member models.WorkspaceMember) error
+	// DeleteMember removes a member from a workspace. It returns ErrNotFound if the user is not a member
+	// and ErrLastOwner if the member is the only owner.
+	DeleteMember(ctx context.Context, workspaceID string, userID string) error
+}
+
+// QuotaStorage provides methods for the plans of users and the numbers of links they have and create.
+// Workspaces may have plans and usage of their own, keyed by their IDs.
+type QuotaStorage interface {
+	// GetPlan returns the name of the plan of a user, or ErrNotFound if the user has none of their own.
+	GetPlan(ctx context.Context, userID string) (string, error)
+	SetPlan(ctx context.Context, userID string, plan string) error
+	// CountActiveURLs returns the number of URLs of a user, except deleted ones.
+	CountActiveURLs(ctx context.Context, userID string) (int, error)
+	// GetUsage returns the number of URLs a user created in the period, see models.UsagePeriod.
+	GetUsage(ctx context.Context, userID string, period string) (int, error)
+	// AddUsage adds n to the number of URLs a user created in the period.
+	AddUsage(ctx context.Context, userID string, period string, n int) error
+}
+
+// AuditStorage provides an append-only log of the changes users make. Entries are only removed by purging.
+type AuditStorage interface {
+	SaveAuditEntries(ctx context.Context, entries []models.AuditEntry) error
+	// GetAuditEntries returns the entries matching the filter, newest first.
+	GetAuditEntries(ctx context.Context, filter models.AuditFilter) ([]models.AuditEntry, error)
+	// PurgeAuditEntries permanently removes entries made before createdBefore and returns their number.
+	PurgeAuditEntries(ctx context.Context, createdBefore time.Time) (int64, error)
+}
+
+// Outbox provides the changes of links recorded atomically with the changes themselves, for publishing them.
+// Only DBStorage implements it.
+type Outbox interface {
+	// LeaseOutbox makes holder the only publisher of the outbox until leaseUntil and reports whether it is:
+	// the lease is taken unless another holder has it past now.
+	LeaseOutbox(ctx context.Context, holder string, now time.Time, leaseUntil time.Time) (bool, error)
+	// GetChanges returns up to limit unpublished changes in the order of their sequence numbers.
+	GetChanges(ctx context.Context, limit int) ([]models.Change, error)
+	// DeleteChanges removes published changes by their sequence numbers.
+	DeleteChanges(ctx context.Context, sequences []int64) error
+}
+
 // Pinger provides a method to check the health of the storage.
 type Pinger interface {
 	Ping(ctx context.Context) error
@@ -53,6 +206,7 @@ type DB interface {
 	sqlx.ExtContext
 	PingContext(ctx context.Context) error
 	NamedExecContext(ctx context.Context, query string, arg interface{}) (sql.Result, error)
+	NamedQueryContext(ctx context.Context, query string, arg interface{}) (*sqlx.Rows, error)
 	PreparexContext(ctx context.Context, query string) (Stmt, error)
 	Closer
 }
@@ -2,7 +2,11 @@ package storage
 
 import (
 	"context"
+	"maps"
+	"slices"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/grnsv/shortener/internal/models"
 )
@@ -11,12 +15,62 @@ import (
 // It is safe for concurrent use and is primarily intended for development or testing environments.
 // The storage uses a sync.Map to store short URL to original URL mappings.
 type MemoryStorage struct {
-	urls sync.Map
+	urls    sync.Map
+	keys    sync.Map
+	mu      sync.Mutex // serializes URL updates together with their history
+	history map[string][]models.URLHistory
+	index   *searchIndex
+
+	hooksMu    sync.Mutex // guards webhooks and deliveries
+	webhooks   map[string]models.Webhook
+	deliveries map[string]models.WebhookDelivery
+
+	domainsMu sync.Mutex // guards domains
+	domains   map[domainKey]models.Domain
+
+	workspacesMu sync.Mutex // guards workspaces and members
+	workspaces   map[string]models.Workspace
+	members      map[memberKey]models.WorkspaceMember
+
+	quotaMu sync.Mutex // guards plans and usage
+	plans   map[string]string
+	usage   map[usageKey]int
+
+	auditMu sync.Mutex          // guards audit
+	audit   []models.AuditEntry // oldest first
+}
+
+// domainKey identifies a domain added by a user.
+type domainKey struct {
+	name   string
+	userID string
+}
+
+// memberKey identifies the membership of a user in a workspace.
+type memberKey struct {
+	workspaceID string
+	userID      string
+}
+
+// usageKey identifies the number of URLs a user created in a period.
+type usageKey struct {
+	userID string
+	period string
 }
 
 // NewMemoryStorage creates and returns a new in-memory storage instance.
 func NewMemoryStorage(ctx context.Context) (*MemoryStorage, error) {
-	return &MemoryStorage{}, nil
+	return &MemoryStorage{
+		history:    make(map[string][]models.URLHistory),
+		index:      newSearchIndex(),
+		webhooks:   make(map[string]models.Webhook),
+		deliveries: make(map[string]models.WebhookDelivery),
+		domains:    make(map[domainKey]models.Domain),
+		workspaces: make(map[string]models.Workspace),
+		members:    make(map[memberKey]models.WorkspaceMember),
+		plans:      make(map[string]string),
+		usage:      make(map[usageKey]int),
+	}, nil
 }
 
 // Close closes the in-memory storage. It is a no-op for MemoryStorage.
@@ -25,26 +79,43 @@ func (s *MemoryStorage) Close() error {
 }
 
 // Save stores a single URL mapping in memory.
+// An existing mapping is kept and ErrAlreadyExist is returned.
 func (s *MemoryStorage) Save(ctx context.Context, model models.URL) error {
-	s.urls.Store(model.ShortURL, model)
+	if _, loaded := s.urls.LoadOrStore(model.ShortURL, model); loaded {
+		return ErrAlreadyExist
+	}
+	s.index.add(model)
 	return nil
 }
 
-// SaveMany stores multiple URL mappings in memory.
-func (s *MemoryStorage) SaveMany(ctx context.Context, models []models.URL) error {
-	for _, model := range models {
-		s.urls.Store(model.ShortURL, model)
+// SaveMany stores multiple URL mappings in memory, keeping existing ones.
+// The result of a model is ErrAlreadyExist if its short URL was taken.
+func (s *MemoryStorage) SaveMany(ctx context.Context, models []models.URL) ([]error, error) {
+	results := make([]error, len(models))
+	for i, model := range models {
+		results[i] = s.Save(ctx, model)
 	}
-	return nil
+	return results, nil
+}
+
+// store saves model under its short URL, replacing and unindexing any previous version.
+func (s *MemoryStorage) store(model models.URL) {
+	if previous, loaded := s.urls.Swap(model.ShortURL, model); loaded {
+		s.index.remove(previous.(models.URL))
+	}
+	s.index.add(model)
 }
 
-// Get retrieves the original URL for a given short URL from memory.
-func (s *MemoryStorage) Get(ctx context.Context, short string) (string, error) {
+// Get retrieves the URL model for a given short URL from memory.
+func (s *MemoryStorage) Get(ctx context.Context, short string) (models.URL, error) {
 	value, ok := s.urls.Load(short)
 	if !ok {
-		return "", ErrNotFound
+		return models.URL{}, ErrNotFound
 	}
-	return value.(models.URL).OriginalURL, nil
+	if value.(models.URL).IsDeleted {
+		return models.URL{}, ErrDeleted
+	}
+	return value.(models.URL), nil
 }
 
 // Ping checks the availability of the in-memory storage. Always returns nil.
@@ -52,25 +123,142 @@ func (s *MemoryStorage) Ping(ctx context.Context) error {
 	return nil
 }
 
-// GetAll returns all URL mappings for a user from memory.
-func (s *MemoryStorage) GetAll(ctx context.Context, userID string) ([]models.URL, error) {
+// GetAll returns the URL mappings of a user matching the filter from memory, except deleted ones.
+func (s *MemoryStorage) GetAll(ctx context.Context, userID string, filter models.URLFilter) ([]models.URL, error) {
 	var urls []models.URL
 
 	s.urls.Range(func(key, value interface{}) bool {
-		urls = append(urls, value.(models.URL))
+		if url := value.(models.URL); url.UserID == userID && !url.IsDeleted && url.Tags.ContainsAll(filter.Tags) {
+			urls = append(urls, url)
+		}
 		return true
 	})
 
 	return urls, nil
 }
 
-// DeleteMany deletes multiple short URLs for a user from memory.
+// IterateAll streams the URLs of a user, except deleted ones, from memory.
+func (s *MemoryStorage) IterateAll(ctx context.Context, userID string) URLSeq {
+	return func(yield func(models.URL, error) bool) {
+		s.urls.Range(func(_, value any) bool {
+			if err := ctx.Err(); err != nil {
+				yield(models.URL{}, err)
+				return false
+			}
+			if url := value.(models.URL); url.UserID == userID && !url.IsDeleted {
+				return yield(url, nil)
+			}
+			return true
+		})
+	}
+}
+
+// Search returns up to limit URLs of a user, except deleted ones, having a term starting with each term of the query
+// in their short code, destination, title or tags. URLs matching more terms exactly come first.
+func (s *MemoryStorage) Search(ctx context.Context, userID string, query string, limit int) ([]models.URL, error) {
+	terms := searchTerms(query)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+
+	scores := s.index.search(terms)
+	urls := make([]models.URL, 0, len(scores))
+	for short := range scores {
+		value, ok := s.urls.Load(short)
+		if !ok {
+			continue
+		}
+		if url := value.(models.URL); url.UserID == userID && !url.IsDeleted {
+			urls = append(urls, url)
+		}
+	}
+	slices.SortFunc(urls, func(a, b models.URL) int {
+		if scores[a.ShortURL] != scores[b.ShortURL] {
+			return scores[b.ShortURL] - scores[a.ShortURL]
+		}
+		return strings.Compare(a.ShortURL, b.ShortURL)
+	})
+	if limit > 0 && len(urls) > limit {
+		urls = urls[:limit]
+	}
+
+	return urls, nil
+}
+
+// DeleteMany soft-deletes multiple short URLs for a user in memory.
 func (s *MemoryStorage) DeleteMany(ctx context.Context, userID string, shortURLs []string) error {
+	s.deleteMany(userID, shortURLs, time.Now().UTC())
+	return nil
+}
+
+// deleteMany soft-deletes the user's short URLs and returns the models it changed.
+func (s *MemoryStorage) deleteMany(userID string, shortURLs []string, deletedAt time.Time) []models.URL {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var deleted []models.URL
 	for _, shortURL := range shortURLs {
-		s.urls.Delete(shortURL)
+		value, ok := s.urls.Load(shortURL)
+		if !ok {
+			continue
+		}
+		url := value.(models.URL)
+		if url.UserID != userID || url.IsDeleted {
+			continue
+		}
+		url.IsDeleted = true
+		url.DeletedAt = &deletedAt
+		s.urls.Store(shortURL, url)
+		deleted = append(deleted, url)
 	}
 
-	return nil
+	return deleted
+}
+
+// Restore undeletes a user's short URL in memory if it was deleted after deletedAfter.
+func (s *MemoryStorage) Restore(ctx context.Context, userID string, short string, deletedAfter time.Time) error {
+	_, err := s.restore(userID, short, deletedAfter)
+	return err
+}
+
+// restore undeletes the user's short URL and returns the restored model.
+func (s *MemoryStorage) restore(userID string, short string, deletedAfter time.Time) (models.URL, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	value, ok := s.urls.Load(short)
+	if !ok {
+		return models.URL{}, ErrNotFound
+	}
+	url := value.(models.URL)
+	if url.UserID != userID || !url.IsDeleted || url.DeletedAt == nil || !url.DeletedAt.After(deletedAfter) {
+		return models.URL{}, ErrNotFound
+	}
+	url.IsDeleted = false
+	url.DeletedAt = nil
+	s.urls.Store(short, url)
+
+	return url, nil
+}
+
+// Purge permanently removes short URLs deleted before deletedBefore, together with their history.
+func (s *MemoryStorage) Purge(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var purged int64
+	s.urls.Range(func(key, value any) bool {
+		url := value.(models.URL)
+		if url.IsDeleted && (url.DeletedAt == nil || url.DeletedAt.Before(deletedBefore)) {
+			s.urls.Delete(key)
+			s.index.remove(url)
+			delete(s.history, url.ShortURL)
+			purged++
+		}
+		return true
+	})
+
+	return purged, nil
 }
 
 // GetStats retrieves service statistics and populates the provided Stats struct.
@@ -85,3 +273,544 @@ func (s *MemoryStorage) GetStats(ctx context.Context, stats *models.Stats) error
 
 	return nil
 }
+
+// PatchURL applies the changes of the patch to a user's short URL at once and records
+// the previous destination in its history if it changes.
+func (s *MemoryStorage) PatchURL(ctx context.Context, userID string, short string, patch models.URLPatch, changedAt time.Time) error {
+	return s.modify(userID, short, func(url *models.URL) {
+		if patch.OriginalURL != "" && patch.OriginalURL != url.OriginalURL {
+			s.history[short] = append(s.history[short], models.URLHistory{
+				ShortURL:    short,
+				OriginalURL: url.OriginalURL,
+				ChangedAt:   changedAt,
+			})
+			url.OriginalURL = patch.OriginalURL
+		}
+		if patch.RedirectCode != 0 {
+			url.RedirectCode = patch.RedirectCode
+		}
+		if patch.Metadata != nil {
+			url.URLMetadata = *patch.Metadata
+		}
+	})
+}
+
+// SetMetadata replaces the title, description, notes and tags of a user's short URL.
+func (s *MemoryStorage) SetMetadata(ctx context.Context, userID string, short string, meta models.URLMetadata) error {
+	return s.modify(userID, short, func(url *models.URL) {
+		url.URLMetadata = meta
+	})
+}
+
+// modify applies fn to a user's short URL unless it is deleted.
+func (s *MemoryStorage) modify(userID string, short string, fn func(*models.URL)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	value, ok := s.urls.Load(short)
+	if !ok || value.(models.URL).UserID != userID || value.(models.URL).IsDeleted {
+		return ErrNotFound
+	}
+	url := value.(models.URL)
+	fn(&url)
+	s.store(url)
+
+	return nil
+}
+
+// RecordFirstClick sets the first click time of a short URL unless it is already set.
+func (s *MemoryStorage) RecordFirstClick(ctx context.Context, short string, clickedAt time.Time) (bool, error) {
+	_, first, err := s.recordFirstClick(short, clickedAt)
+	return first, err
+}
+
+// recordFirstClick sets the first click time of a short URL unless it is already set
+// and returns the model and whether it changed.
+func (s *MemoryStorage) recordFirstClick(short string, clickedAt time.Time) (models.URL, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	value, ok := s.urls.Load(short)
+	if !ok {
+		return models.URL{}, false, ErrNotFound
+	}
+	url := value.(models.URL)
+	if url.ClickedAt != nil {
+		return url, false, nil
+	}
+	url.ClickedAt = &clickedAt
+	s.urls.Store(short, url)
+
+	return url, true, nil
+}
+
+// GetURLHistory returns the previous destinations of a short URL, oldest first.
+func (s *MemoryStorage) GetURLHistory(ctx context.Context, short string) ([]models.URLHistory, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return slices.Clone(s.history[short]), nil
+}
+
+// SaveAPIKey stores an API key in memory.
+func (s *MemoryStorage) SaveAPIKey(ctx context.Context, key models.APIKey) error {
+	if _, loaded := s.keys.LoadOrStore(key.ID, key); loaded {
+		return ErrAlreadyExist
+	}
+	return nil
+}
+
+// GetAPIKeys returns all API keys of a user from memory.
+func (s *MemoryStorage) GetAPIKeys(ctx context.Context, userID string) ([]models.APIKey, error) {
+	var keys []models.APIKey
+	s.keys.Range(func(_, value any) bool {
+		if key := value.(models.APIKey); key.UserID == userID {
+			keys = append(keys, key)
+		}
+		return true
+	})
+	return keys, nil
+}
+
+// GetAPIKeyByHash retrieves an API key by the hash of its value from memory.
+func (s *MemoryStorage) GetAPIKeyByHash(ctx context.Context, hash string) (models.APIKey, error) {
+	var found models.APIKey
+	var ok bool
+	s.keys.Range(func(_, value any) bool {
+		if key := value.(models.APIKey); key.KeyHash == hash {
+			found, ok = key, true
+			return false
+		}
+		return true
+	})
+	if !ok {
+		return models.APIKey{}, ErrNotFound
+	}
+	return found, nil
+}
+
+// RevokeAPIKey marks an API key of a user as revoked.
+func (s *MemoryStorage) RevokeAPIKey(ctx context.Context, userID string, id string, revokedAt time.Time) error {
+	value, ok := s.keys.Load(id)
+	if !ok || value.(models.APIKey).UserID != userID {
+		return ErrNotFound
+	}
+	key := value.(models.APIKey)
+	if key.RevokedAt == nil {
+		key.RevokedAt = &revokedAt
+		s.keys.Store(id, key)
+	}
+	return nil
+}
+
+// TouchAPIKey updates the last usage time of an API key.
+func (s *MemoryStorage) TouchAPIKey(ctx context.Context, id string, usedAt time.Time) error {
+	value, ok := s.keys.Load(id)
+	if !ok {
+		return ErrNotFound
+	}
+	key := value.(models.APIKey)
+	key.LastUsedAt = &usedAt
+	s.keys.Store(id, key)
+	return nil
+}
+
+// SaveWebhook stores a webhook in memory.
+func (s *MemoryStorage) SaveWebhook(ctx context.Context, hook models.Webhook) error {
+	s.hooksMu.Lock()
+	defer s.hooksMu.Unlock()
+
+	if _, ok := s.webhooks[hook.ID]; ok {
+		return ErrAlreadyExist
+	}
+	s.webhooks[hook.ID] = hook
+	return nil
+}
+
+// GetWebhook retrieves a webhook by its ID from memory.
+func (s *MemoryStorage) GetWebhook(ctx context.Context, id string) (models.Webhook, error) {
+	s.hooksMu.Lock()
+	defer s.hooksMu.Unlock()
+
+	hook, ok := s.webhooks[id]
+	if !ok {
+		return models.Webhook{}, ErrNotFound
+	}
+	return hook, nil
+}
+
+// GetWebhooks returns all webhooks of a user from memory, oldest first.
+func (s *MemoryStorage) GetWebhooks(ctx context.Context, userID string) ([]models.Webhook, error) {
+	s.hooksMu.Lock()
+	defer s.hooksMu.Unlock()
+
+	var hooks []models.Webhook
+	for _, hook := range s.webhooks {
+		if hook.UserID == userID {
+			hooks = append(hooks, hook)
+		}
+	}
+	slices.SortFunc(hooks, func(a, b models.Webhook) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+	return hooks, nil
+}
+
+// DeleteWebhook removes a webhook of a user and its deliveries from memory.
+func (s *MemoryStorage) DeleteWebhook(ctx context.Context, userID string, id string) error {
+	s.hooksMu.Lock()
+	defer s.hooksMu.Unlock()
+
+	if hook, ok := s.webhooks[id]; !ok || hook.UserID != userID {
+		return ErrNotFound
+	}
+	delete(s.webhooks, id)
+	maps.DeleteFunc(s.deliveries, func(_ string, delivery models.WebhookDelivery) bool {
+		return delivery.WebhookID == id
+	})
+	return nil
+}
+
+// SaveDeliveries stores webhook deliveries in memory.
+func (s *MemoryStorage) SaveDeliveries(ctx context.Context, deliveries []models.WebhookDelivery) error {
+	s.hooksMu.Lock()
+	defer s.hooksMu.Unlock()
+
+	for _, delivery := range deliveries {
+		s.deliveries[delivery.ID] = delivery
+	}
+	return nil
+}
+
+// ClaimDeliveries returns up to limit pending deliveries due at now, oldest first, and postpones their next attempt to leaseUntil.
+func (s *MemoryStorage) ClaimDeliveries(ctx context.Context, now time.Time, leaseUntil time.Time, limit int) ([]models.WebhookDelivery, error) {
+	s.hooksMu.Lock()
+	defer s.hooksMu.Unlock()
+
+	deliveries := s.selectDeliveries(limit, func(d models.WebhookDelivery) bool {
+		return d.Status == models.DeliveryPending && d.NextAttemptAt != nil && !d.NextAttemptAt.After(now)
+	}, func(a, b models.WebhookDelivery) int {
+		return a.NextAttemptAt.Compare(*b.NextAttemptAt)
+	})
+	for _, delivery := range deliveries {
+		delivery.NextAttemptAt = &leaseUntil
+		s.deliveries[delivery.ID] = delivery
+	}
+	return deliveries, nil
+}
+
+// GetDeliveries returns up to limit deliveries of a webhook, newest first.
+func (s *MemoryStorage) GetDeliveries(ctx context.Context, webhookID string, limit int) ([]models.WebhookDelivery, error) {
+	s.hooksMu.Lock()
+	defer s.hooksMu.Unlock()
+
+	return s.selectDeliveries(limit, func(d models.WebhookDelivery) bool {
+		return d.WebhookID == webhookID
+	}, func(a, b models.WebhookDelivery) int {
+		return b.CreatedAt.Compare(a.CreatedAt)
+	}), nil
+}
+
+// selectDeliveries returns up to limit deliveries matching the filter, all if limit is zero, sorted by cmp.
+// The caller must hold hooksMu.
+func (s *MemoryStorage) selectDeliveries(
+	limit int,
+	filter func(models.WebhookDelivery) bool,
+	cmp func(a, b models.WebhookDelivery) int,
+) []models.WebhookDelivery {
+	var deliveries []models.WebhookDelivery
+	for _, delivery := range s.deliveries {
+		if filter(delivery) {
+			deliveries = append(deliveries, delivery)
+		}
+	}
+	slices.SortFunc(deliveries, cmp)
+	if limit > 0 && len(deliveries) > limit {
+		deliveries = deliveries[:limit]
+	}
+	return deliveries
+}
+
+// UpdateDelivery replaces a webhook delivery in memory.
+func (s *MemoryStorage) UpdateDelivery(ctx context.Context, delivery models.WebhookDelivery) error {
+	s.hooksMu.Lock()
+	defer s.hooksMu.Unlock()
+
+	if _, ok := s.deliveries[delivery.ID]; !ok {
+		return ErrNotFound
+	}
+	s.deliveries[delivery.ID] = delivery
+	return nil
+}
+
+// SaveDomain stores a domain added by a user in memory.
+func (s *MemoryStorage) SaveDomain(ctx context.Context, domain models.Domain) error {
+	s.domainsMu.Lock()
+	defer s.domainsMu.Unlock()
+
+	key := domainKey{name: domain.Name, userID: domain.UserID}
+	if _, ok := s.domains[key]; ok {
+		return ErrAlreadyExist
+	}
+	s.domains[key] = domain
+	return nil
+}
+
+// GetDomains returns the domains added by a user from memory, oldest first.
+func (s *MemoryStorage) GetDomains(ctx context.Context, userID string) ([]models.Domain, error) {
+	s.domainsMu.Lock()
+	defer s.domainsMu.Unlock()
+
+	var domains []models.Domain
+	for key, domain := range s.domains {
+		if key.userID == userID {
+			domains = append(domains, domain)
+		}
+	}
+	slices.SortFunc(domains, func(a, b models.Domain) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+	return domains, nil
+}
+
+// GetVerifiedDomain retrieves the domain with the name verified by any user from memory.
+func (s *MemoryStorage) GetVerifiedDomain(ctx context.Context, name string) (models.Domain, error) {
+	s.domainsMu.Lock()
+	defer s.domainsMu.Unlock()
+
+	for key, domain := range s.domains {
+		if key.name == name && domain.Verified() {
+			return domain, nil
+		}
+	}
+	return models.Domain{}, ErrNotFound
+}
+
+// VerifyDomain marks a domain of a user as verified in memory.
+func (s *MemoryStorage) VerifyDomain(ctx context.Context, userID string, name string, verifiedAt time.Time) error {
+	s.domainsMu.Lock()
+	defer s.domainsMu.Unlock()
+
+	key := domainKey{name: name, userID: userID}
+	domain, ok := s.domains[key]
+	if !ok {
+		return ErrNotFound
+	}
+	if domain.Verified() {
+		return nil
+	}
+	for other, d := range s.domains {
+		if other.name == name && d.Verified() {
+			return ErrAlreadyExist
+		}
+	}
+	domain.VerifiedAt = &verifiedAt
+	s.domains[key] = domain
+	return nil
+}
+
+// CreateWorkspace stores a workspace and its owner in memory.
+func (s *MemoryStorage) CreateWorkspace(ctx context.Context, workspace models.Workspace, owner models.WorkspaceMember) error {
+	s.workspacesMu.Lock()
+	defer s.workspacesMu.Unlock()
+
+	if _, ok := s.workspaces[workspace.ID]; ok {
+		return ErrAlreadyExist
+	}
+	workspace.Role = ""
+	s.workspaces[workspace.ID] = workspace
+	s.members[memberKey{workspaceID: owner.WorkspaceID, userID: owner.UserID}] = owner
+	return nil
+}
+
+// GetWorkspaces returns the workspaces of a user from memory, oldest first.
+func (s *MemoryStorage) GetWorkspaces(ctx context.Context, userID string) ([]models.Workspace, error) {
+	s.workspacesMu.Lock()
+	defer s.workspacesMu.Unlock()
+
+	var workspaces []models.Workspace
+	for key, member := range s.members {
+		if key.userID == userID {
+			workspace := s.workspaces[key.workspaceID]
+			workspace.Role = member.Role
+			workspaces = append(workspaces, workspace)
+		}
+	}
+	slices.SortFunc(workspaces, func(a, b models.Workspace) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+	return workspaces, nil
+}
+
+// GetMember retrieves the membership of a user in a workspace from memory.
+func (s *MemoryStorage) GetMember(ctx context.Context, workspaceID string, userID string) (models.WorkspaceMember, error) {
+	s.workspacesMu.Lock()
+	defer s.workspacesMu.Unlock()
+
+	member, ok := s.members[memberKey{workspaceID: workspaceID, userID: userID}]
+	if !ok {
+		return models.WorkspaceMember{}, ErrNotFound
+	}
+	return member, nil
+}
+
+// GetMembers returns the members of a workspace from memory, oldest first.
+func (s *MemoryStorage) GetMembers(ctx context.Context, workspaceID string) ([]models.WorkspaceMember, error) {
+	s.workspacesMu.Lock()
+	defer s.workspacesMu.Unlock()
+
+	var members []models.WorkspaceMember
+	for key, member := range s.members {
+		if key.workspaceID == workspaceID {
+			members = append(members, member)
+		}
+	}
+	slices.SortFunc(members, func(a, b models.WorkspaceMember) int {
+		return a.AddedAt.Compare(b.AddedAt)
+	})
+	return members, nil
+}
+
+// SaveMember adds or updates a member of a workspace in memory.
+func (s *MemoryStorage) SaveMember(ctx context.Context, member models.WorkspaceMember) error {
+	s.workspacesMu.Lock()
+	defer s.workspacesMu.Unlock()
+
+	key := memberKey{workspaceID: member.WorkspaceID, userID: member.UserID}
+	if existing, ok := s.members[key]; ok {
+		if s.lastOwner(existing) && member.Role != models.RoleOwner {
+			return ErrLastOwner
+		}
+		member.AddedAt = existing.AddedAt
+	}
+	s.members[key] = member
+	return nil
+}
+
+// DeleteMember removes a member of a workspace from memory.
+func (s *MemoryStorage) DeleteMember(ctx context.Context, workspaceID string, userID string) error {
+	s.workspacesMu.Lock()
+	defer s.workspacesMu.Unlock()
+
+	key := memberKey{workspaceID: workspaceID, userID: userID}
+	member, ok := s.members[key]
+	if !ok {
+		return ErrNotFound
+	}
+	if s.lastOwner(member) {
+		return ErrLastOwner
+	}
+	delete(s.members, key)
+	return nil
+}
+
+// lastOwner reports whether the member is the only owner of their workspace. The caller must hold workspacesMu.
+func (s *MemoryStorage) lastOwner(member models.WorkspaceMember) bool {
+	if member.Role != models.RoleOwner {
+		return false
+	}
+	for key, other := range s.members {
+		if key.workspaceID == member.WorkspaceID && key.userID != member.UserID && other.Role == models.RoleOwner {
+			return false
+		}
+	}
+	return true
+}
+
+// GetPlan returns the name of the plan of a user from memory.
+func (s *MemoryStorage) GetPlan(ctx context.Context, userID string) (string, error) {
+	s.quotaMu.Lock()
+	defer s.quotaMu.Unlock()
+
+	plan, ok := s.plans[userID]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return plan, nil
+}
+
+// SetPlan stores the plan of a user in memory.
+func (s *MemoryStorage) SetPlan(ctx context.Context, userID string, plan string) error {
+	s.quotaMu.Lock()
+	defer s.quotaMu.Unlock()
+
+	s.plans[userID] = plan
+	return nil
+}
+
+// CountActiveURLs counts the URLs of a user in memory, except deleted ones.
+func (s *MemoryStorage) CountActiveURLs(ctx context.Context, userID string) (int, error) {
+	var count int
+	s.urls.Range(func(_, value any) bool {
+		if url := value.(models.URL); url.UserID == userID && !url.IsDeleted {
+			count++
+		}
+		return true
+	})
+	return count, nil
+}
+
+// GetUsage returns the number of URLs a user created in the period from memory.
+func (s *MemoryStorage) GetUsage(ctx context.Context, userID string, period string) (int, error) {
+	s.quotaMu.Lock()
+	defer s.quotaMu.Unlock()
+
+	return s.usage[usageKey{userID: userID, period: period}], nil
+}
+
+// AddUsage adds n to the number of URLs a user created in the period in memory.
+func (s *MemoryStorage) AddUsage(ctx context.Context, userID string, period string, n int) error {
+	s.quotaMu.Lock()
+	defer s.quotaMu.Unlock()
+
+	s.usage[usageKey{userID: userID, period: period}] += n
+	return nil
+}
+
+// SaveAuditEntries appends audit entries in memory.
+func (s *MemoryStorage) SaveAuditEntries(ctx context.Context, entries []models.AuditEntry) error {
+	s.auditMu.Lock()
+	defer s.auditMu.Unlock()
+
+	s.audit = append(s.audit, entries...)
+	return nil
+}
+
+// GetAuditEntries returns the audit entries matching the filter from memory, newest first.
+func (s *MemoryStorage) GetAuditEntries(ctx context.Context, filter models.AuditFilter) ([]models.AuditEntry, error) {
+	s.auditMu.Lock()
+	defer s.auditMu.Unlock()
+
+	var entries []models.AuditEntry
+	for _, entry := range slices.Backward(s.audit) {
+		if filter.Limit > 0 && len(entries) == filter.Limit {
+			break
+		}
+		if auditMatches(entry, filter) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+// auditMatches reports whether an audit entry matches the filter.
+func auditMatches(entry models.AuditEntry, filter models.AuditFilter) bool {
+	return (filter.Actor == "" || entry.Actor == filter.Actor) &&
+		(filter.Action == "" || entry.Action == filter.Action) &&
+		(filter.Target == "" || entry.Target == filter.Target) &&
+		(filter.Since.IsZero() || !entry.CreatedAt.Before(filter.Since)) &&
+		(filter.Until.IsZero() || entry.CreatedAt.Before(filter.Until))
+}
+
+// PurgeAuditEntries removes audit entries made before createdBefore from memory.
+func (s *MemoryStorage) PurgeAuditEntries(ctx context.Context, createdBefore time.Time) (int64, error) {
+	s.auditMu.Lock()
+	defer s.auditMu.Unlock()
+
+	kept := slices.DeleteFunc(s.audit, func(entry models.AuditEntry) bool {
+		return entry.CreatedAt.Before(createdBefore)
+	})
+	purged := len(s.audit) - len(kept)
+	s.audit = kept
+	return int64(purged), nil
+}
@@ -0,0 +1,104 @@
+package storage
+
+import (
+	"slices"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/grnsv/shortener/internal/models"
+)
+
+// searchTerms splits text into lowercase runs of letters and digits.
+// Both indexed documents and queries are split this way, so "Q3-Report.pdf" matches "q3 report".
+func searchTerms(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// searchDocument returns the searchable terms of a URL: its short code, destination, title and tags.
+func searchDocument(url models.URL) []string {
+	terms := []string{strings.ToLower(url.ShortURL)}
+	for _, text := range append([]string{url.ShortURL, url.OriginalURL, url.Title}, url.Tags...) {
+		terms = append(terms, searchTerms(text)...)
+	}
+	slices.Sort(terms)
+	return slices.Compact(terms)
+}
+
+// searchIndex is an inverted index from terms to the short URLs whose documents contain them.
+type searchIndex struct {
+	mu    sync.RWMutex
+	terms map[string]map[string]struct{}
+}
+
+func newSearchIndex() *searchIndex {
+	return &searchIndex{terms: make(map[string]map[string]struct{})}
+}
+
+// add indexes the document of url.
+func (idx *searchIndex) add(url models.URL) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	for _, term := range searchDocument(url) {
+		shorts, ok := idx.terms[term]
+		if !ok {
+			shorts = make(map[string]struct{})
+			idx.terms[term] = shorts
+		}
+		shorts[url.ShortURL] = struct{}{}
+	}
+}
+
+// remove drops the document of url from the index.
+func (idx *searchIndex) remove(url models.URL) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	for _, term := range searchDocument(url) {
+		delete(idx.terms[term], url.ShortURL)
+		if len(idx.terms[term]) == 0 {
+			delete(idx.terms, term)
+		}
+	}
+}
+
+// search returns the short URLs whose documents contain a term starting with each query term,
+// scored by how many query terms they match exactly.
+func (idx *searchIndex) search(terms []string) map[string]int {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	var scores map[string]int
+	for _, query := range terms {
+		matches := make(map[string]int)
+		for term, shorts := range idx.terms {
+			if !strings.HasPrefix(term, query) {
+				continue
+			}
+			score := 1
+			if term == query {
+				score = 2
+			}
+			for short := range shorts {
+				matches[short] = max(matches[short], score)
+			}
+		}
+
+		if scores == nil {
+			scores = matches
+			continue
+		}
+		for short, score := range scores {
+			if match, ok := matches[short]; ok {
+				scores[short] = score + match
+			} else {
+				delete(scores, short)
+			}
+		}
+	}
+
+	return scores
+}
@@ -17,10 +17,12 @@ var (
 	ErrAlreadyExist = errors.New("already exist")
 	ErrNotFound     = errors.New("not found")
 	ErrDeleted      = errors.New("deleted")
+	ErrLastOwner    = errors.New("workspace must keep an owner")
 )
 
 // New creates a new Storage implementation based on the provided configuration.
 // It selects the storage backend in the following order: PostgreSQL, file storage, or in-memory storage.
+// The database records changes of links in the outbox only if an event sink publishes them.
 func New(ctx context.Context, cfg *config.Config) (Storage, error) {
 	if cfg.DatabaseDSN != "" {
 		db, err := sqlx.Open("postgres", cfg.DatabaseDSN)
@@ -28,7 +30,12 @@ func New(ctx context.Context, cfg *config.Config) (Storage, error) {
 			return nil, err
 		}
 
-		return NewDBStorage(ctx, &DBWrapper{db})
+		var opts []DBOption
+		if cfg.EventSink == "" {
+			opts = append(opts, WithoutOutbox())
+		}
+
+		return NewDBStorage(ctx, &DBWrapper{db}, opts...)
 	}
 
 	if cfg.FileStoragePath != "" {
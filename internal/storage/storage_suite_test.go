@@ -2,17 +2,29 @@ package storage_test
 
 import (
 	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
 	"errors"
+	"net/http"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/golang/mock/gomock"
 	"github.com/grnsv/shortener/internal/mocks"
 	"github.com/grnsv/shortener/internal/models"
 	"github.com/grnsv/shortener/internal/storage"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 )
 
+// preparedStatements is the number of statements NewDBStorage prepares.
+const preparedStatements = 45
+
 func TestStorage(t *testing.T) {
 	RegisterFailHandler(Fail)
 	RunSpecs(t, "Storage Suite")
@@ -31,8 +43,8 @@ var _ = Describe("DBStorage_SaveMany", func() {
 		ctrl = gomock.NewController(GinkgoT())
 		db = mocks.NewMockDB(ctrl)
 		stmt = mocks.NewMockStmt(ctrl)
-		db.EXPECT().ExecContext(gomock.Any(), gomock.Any()).Return(nil, nil)
-		db.EXPECT().PreparexContext(gomock.Any(), gomock.Any()).Return(stmt, nil).Times(5)
+		db.EXPECT().ExecContext(gomock.Any(), gomock.Any()).Return(driver.RowsAffected(0), nil).Times(2)
+		db.EXPECT().PreparexContext(gomock.Any(), gomock.Any()).Return(stmt, nil).Times(preparedStatements)
 		s, err = storage.NewDBStorage(context.Background(), db)
 		Expect(err).To(BeNil())
 	})
@@ -41,7 +53,7 @@ var _ = Describe("DBStorage_SaveMany", func() {
 		ctrl.Finish()
 	})
 
-	It("should save a batch of URLs", func() {
+	It("should skip taken URLs in a single statement", func() {
 		models := []models.URL{
 			{
 				UUID:        "00000000-0000-0000-0000-000000000001",
@@ -55,9 +67,17 @@ var _ = Describe("DBStorage_SaveMany", func() {
 			},
 		}
 
-		db.EXPECT().NamedExecContext(gomock.Any(), gomock.Any(), gomock.Len(2)).Return(nil, nil)
-		err = s.SaveMany(context.Background(), models)
-		Expect(err).To(BeNil())
+		var query string
+		db.EXPECT().NamedQueryContext(gomock.Any(), gomock.Any(), gomock.Len(2)).
+			DoAndReturn(func(_ context.Context, q string, _ interface{}) (*sqlx.Rows, error) {
+				query = q
+				return nil, errors.New("db error")
+			})
+		_, err = s.SaveMany(context.Background(), models)
+		Expect(err).To(MatchError("db error"))
+		Expect(query).To(ContainSubstring("ON CONFLICT DO NOTHING"))
+		Expect(query).To(ContainSubstring("SELECT short_url FROM saved"))
+		Expect(query).To(ContainSubstring("INSERT INTO outbox"))
 	})
 
 	When("db fails", func() {
@@ -76,9 +96,9 @@ var _ = Describe("DBStorage_SaveMany", func() {
 			}
 
 			db.EXPECT().
-				NamedExecContext(gomock.Any(), gomock.Any(), gomock.Len(2)).
+				NamedQueryContext(gomock.Any(), gomock.Any(), gomock.Len(2)).
 				Return(nil, errors.New("db error"))
-			err = s.SaveMany(context.Background(), models)
+			_, err = s.SaveMany(context.Background(), models)
 			Expect(err).To(HaveOccurred())
 		})
 	})
@@ -97,8 +117,8 @@ var _ = Describe("DBStorage_Get", func() {
 		ctrl = gomock.NewController(GinkgoT())
 		db = mocks.NewMockDB(ctrl)
 		stmt = mocks.NewMockStmt(ctrl)
-		db.EXPECT().ExecContext(gomock.Any(), gomock.Any()).Return(nil, nil)
-		db.EXPECT().PreparexContext(gomock.Any(), gomock.Any()).Return(stmt, nil).Times(5)
+		db.EXPECT().ExecContext(gomock.Any(), gomock.Any()).Return(driver.RowsAffected(0), nil).Times(2)
+		db.EXPECT().PreparexContext(gomock.Any(), gomock.Any()).Return(stmt, nil).Times(preparedStatements)
 		s, err = storage.NewDBStorage(context.Background(), db)
 		Expect(err).To(BeNil())
 	})
@@ -119,7 +139,7 @@ var _ = Describe("DBStorage_Get", func() {
 		)
 		orig, err := s.Get(context.Background(), short)
 		Expect(err).To(BeNil())
-		Expect(orig).To(Equal("http://original.com"))
+		Expect(orig.OriginalURL).To(Equal("http://original.com"))
 	})
 
 	It("should return ErrDeleted if url is deleted", func() {
@@ -157,8 +177,8 @@ var _ = Describe("DBStorage_GetAll", func() {
 		ctrl = gomock.NewController(GinkgoT())
 		db = mocks.NewMockDB(ctrl)
 		stmt = mocks.NewMockStmt(ctrl)
-		db.EXPECT().ExecContext(gomock.Any(), gomock.Any()).Return(nil, nil)
-		db.EXPECT().PreparexContext(gomock.Any(), gomock.Any()).Return(stmt, nil).Times(5)
+		db.EXPECT().ExecContext(gomock.Any(), gomock.Any()).Return(driver.RowsAffected(0), nil).Times(2)
+		db.EXPECT().PreparexContext(gomock.Any(), gomock.Any()).Return(stmt, nil).Times(preparedStatements)
 		s, err = storage.NewDBStorage(context.Background(), db)
 		Expect(err).To(BeNil())
 	})
@@ -180,7 +200,7 @@ var _ = Describe("DBStorage_GetAll", func() {
 				return nil
 			},
 		)
-		result, err := s.GetAll(context.Background(), userID)
+		result, err := s.GetAll(context.Background(), userID, models.URLFilter{})
 		Expect(err).To(BeNil())
 		Expect(result).To(Equal(urls))
 	})
@@ -188,7 +208,7 @@ var _ = Describe("DBStorage_GetAll", func() {
 	It("should return error if SelectContext fails", func() {
 		userID := "user-err"
 		stmt.EXPECT().SelectContext(gomock.Any(), gomock.Any(), userID).Return(errors.New("select error"))
-		_, err := s.GetAll(context.Background(), userID)
+		_, err := s.GetAll(context.Background(), userID, models.URLFilter{})
 		Expect(err).To(MatchError("select error"))
 	})
 })
@@ -206,8 +226,8 @@ var _ = Describe("DBStorage_DeleteMany", func() {
 		ctrl = gomock.NewController(GinkgoT())
 		db = mocks.NewMockDB(ctrl)
 		stmt = mocks.NewMockStmt(ctrl)
-		db.EXPECT().ExecContext(gomock.Any(), gomock.Any()).Return(nil, nil)
-		db.EXPECT().PreparexContext(gomock.Any(), gomock.Any()).Return(stmt, nil).Times(5)
+		db.EXPECT().ExecContext(gomock.Any(), gomock.Any()).Return(driver.RowsAffected(0), nil).Times(2)
+		db.EXPECT().PreparexContext(gomock.Any(), gomock.Any()).Return(stmt, nil).Times(preparedStatements)
 		s, err = storage.NewDBStorage(context.Background(), db)
 		Expect(err).To(BeNil())
 	})
@@ -232,3 +252,827 @@ var _ = Describe("DBStorage_DeleteMany", func() {
 		Expect(err).To(MatchError("delete error"))
 	})
 })
+
+var _ = Describe("DBStorage_GetAPIKeyByHash", func() {
+	var (
+		ctrl *gomock.Controller
+		db   *mocks.MockDB
+		stmt *mocks.MockStmt
+		s    storage.Storage
+		err  error
+	)
+
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		db = mocks.NewMockDB(ctrl)
+		stmt = mocks.NewMockStmt(ctrl)
+		db.EXPECT().ExecContext(gomock.Any(), gomock.Any()).Return(driver.RowsAffected(0), nil).Times(2)
+		db.EXPECT().PreparexContext(gomock.Any(), gomock.Any()).Return(stmt, nil).Times(preparedStatements)
+		s, err = storage.NewDBStorage(context.Background(), db)
+		Expect(err).To(BeNil())
+	})
+
+	AfterEach(func() {
+		ctrl.Finish()
+	})
+
+	It("should get the API key", func() {
+		stmt.EXPECT().GetContext(gomock.Any(), gomock.Any(), "hash").DoAndReturn(
+			func(ctx context.Context, dest any, args ...any) error {
+				ptr := dest.(*models.APIKey)
+				*ptr = models.APIKey{ID: "key-id"}
+				return nil
+			},
+		)
+		key, err := s.GetAPIKeyByHash(context.Background(), "hash")
+		Expect(err).To(BeNil())
+		Expect(key.ID).To(Equal("key-id"))
+	})
+
+	It("should return ErrNotFound if there is no such key", func() {
+		stmt.EXPECT().GetContext(gomock.Any(), gomock.Any(), "missing").Return(sql.ErrNoRows)
+		_, err := s.GetAPIKeyByHash(context.Background(), "missing")
+		Expect(err).To(MatchError(storage.ErrNotFound))
+	})
+})
+
+var _ = Describe("DBStorage_UpdateURL", func() {
+	var (
+		ctrl *gomock.Controller
+		db   *mocks.MockDB
+		stmt *mocks.MockStmt
+		s    storage.Storage
+		err  error
+	)
+
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		db = mocks.NewMockDB(ctrl)
+		stmt = mocks.NewMockStmt(ctrl)
+		db.EXPECT().ExecContext(gomock.Any(), gomock.Any()).Return(driver.RowsAffected(0), nil).Times(2)
+		db.EXPECT().PreparexContext(gomock.Any(), gomock.Any()).Return(stmt, nil).Times(preparedStatements)
+		s, err = storage.NewDBStorage(context.Background(), db)
+		Expect(err).To(BeNil())
+	})
+
+	AfterEach(func() {
+		ctrl.Finish()
+	})
+
+	// expectPatch expects a single patch statement with the arguments after the user and the short URL
+	// and makes it report the number of updated URLs.
+	expectPatch := func(updated int, args ...any) {
+		stmt.EXPECT().GetContext(gomock.Any(), gomock.Any(), append([]any{"user", "short1"}, args...)...).
+			SetArg(1, updated).Return(nil)
+	}
+
+	It("should update the destination", func() {
+		expectPatch(1, "http://new.com", nil, nil, nil, nil, nil, gomock.Any())
+		err = s.PatchURL(context.Background(), "user", "short1", models.URLPatch{OriginalURL: "http://new.com"}, time.Now())
+		Expect(err).To(BeNil())
+	})
+
+	It("should return ErrNotFound if the user has no such URL", func() {
+		expectPatch(0, "http://new.com", nil, nil, nil, nil, nil, gomock.Any())
+		err = s.PatchURL(context.Background(), "user", "short1", models.URLPatch{OriginalURL: "http://new.com"}, time.Now())
+		Expect(err).To(MatchError(storage.ErrNotFound))
+	})
+
+	It("should set the redirect code", func() {
+		expectPatch(1, nil, http.StatusMovedPermanently, nil, nil, nil, nil, gomock.Any())
+		err = s.PatchURL(context.Background(), "user", "short1", models.URLPatch{RedirectCode: http.StatusMovedPermanently}, time.Now())
+		Expect(err).To(BeNil())
+	})
+
+	It("should apply every change of a patch in a single statement", func() {
+		tags := models.Tags{"docs"}
+		expectPatch(1, "http://new.com", http.StatusFound, "Title", "", "notes", tags, gomock.Any())
+		err = s.PatchURL(context.Background(), "user", "short1", models.URLPatch{
+			OriginalURL:  "http://new.com",
+			RedirectCode: http.StatusFound,
+			Metadata:     &models.URLMetadata{Title: "Title", Notes: "notes", Tags: tags},
+		}, time.Now())
+		Expect(err).To(BeNil())
+	})
+
+	It("should search by word prefixes and substrings", func() {
+		stmt.EXPECT().SelectContext(gomock.Any(), gomock.Any(), "user", "q3:* & report:*", "%q3 report%", "q3 report", 10).Return(nil)
+		_, err = s.Search(context.Background(), "user", "Q3-Report", 10)
+		Expect(err).To(BeNil())
+	})
+
+	It("should stop iterating at a query error", func() {
+		stmt.EXPECT().QueryxContext(gomock.Any(), "user").Return(nil, errors.New("query error"))
+		var errs []error
+		for _, iterErr := range s.IterateAll(context.Background(), "user") {
+			errs = append(errs, iterErr)
+		}
+		Expect(errs).To(HaveLen(1))
+		Expect(errs[0]).To(MatchError("query error"))
+	})
+
+	It("should not query the database for queries without words", func() {
+		urls, searchErr := s.Search(context.Background(), "user", " -- ", 10)
+		Expect(searchErr).To(BeNil())
+		Expect(urls).To(BeEmpty())
+	})
+
+	It("should record only the first click", func() {
+		stmt.EXPECT().ExecContext(gomock.Any(), "short1", gomock.Any()).Return(driver.RowsAffected(1), nil)
+		Expect(s.RecordFirstClick(context.Background(), "short1", time.Now())).To(BeTrue())
+		stmt.EXPECT().ExecContext(gomock.Any(), "short1", gomock.Any()).Return(driver.RowsAffected(0), nil)
+		Expect(s.RecordFirstClick(context.Background(), "short1", time.Now())).To(BeFalse())
+	})
+
+	It("should reject domains verified by another user", func() {
+		now := time.Now()
+		stmt.EXPECT().ExecContext(gomock.Any(), "user", "go.brand.com", now).Return(nil, &pq.Error{Code: "23505"})
+		Expect(s.VerifyDomain(context.Background(), "user", "go.brand.com", now)).To(MatchError(storage.ErrAlreadyExist))
+		stmt.EXPECT().ExecContext(gomock.Any(), "user", "brand.link", now).Return(driver.RowsAffected(0), nil)
+		Expect(s.VerifyDomain(context.Background(), "user", "brand.link", now)).To(MatchError(storage.ErrNotFound))
+	})
+
+	It("should tell a missing member from the last owner when nothing is deleted", func() {
+		stmt.EXPECT().ExecContext(gomock.Any(), "workspace", "owner").Return(driver.RowsAffected(0), nil)
+		stmt.EXPECT().GetContext(gomock.Any(), gomock.Any(), "workspace", "owner").Return(nil)
+		Expect(s.DeleteMember(context.Background(), "workspace", "owner")).To(MatchError(storage.ErrLastOwner))
+		stmt.EXPECT().ExecContext(gomock.Any(), "workspace", "user").Return(driver.RowsAffected(0), nil)
+		stmt.EXPECT().GetContext(gomock.Any(), gomock.Any(), "workspace", "user").Return(sql.ErrNoRows)
+		Expect(s.DeleteMember(context.Background(), "workspace", "user")).To(MatchError(storage.ErrNotFound))
+	})
+
+	It("should tell a user without a plan of their own", func() {
+		stmt.EXPECT().GetContext(gomock.Any(), gomock.Any(), "user").Return(sql.ErrNoRows)
+		_, planErr := s.GetPlan(context.Background(), "user")
+		Expect(planErr).To(MatchError(storage.ErrNotFound))
+		stmt.EXPECT().ExecContext(gomock.Any(), "user", "2026-10", 2).Return(driver.RowsAffected(1), nil)
+		Expect(s.AddUsage(context.Background(), "user", "2026-10", 2)).To(Succeed())
+	})
+
+	It("should report the number of purged audit entries", func() {
+		before := time.Now()
+		stmt.EXPECT().ExecContext(gomock.Any(), before).Return(driver.RowsAffected(3), nil)
+		Expect(s.PurgeAuditEntries(context.Background(), before)).To(Equal(int64(3)))
+	})
+
+	It("should set the metadata", func() {
+		tags := models.Tags{"docs", "work"}
+		stmt.EXPECT().ExecContext(gomock.Any(), "user", "short1", "Title", "", "notes", tags).Return(driver.RowsAffected(1), nil)
+		err = s.SetMetadata(context.Background(), "user", "short1", models.URLMetadata{Title: "Title", Notes: "notes", Tags: tags})
+		Expect(err).To(BeNil())
+	})
+})
+
+var _ = Describe("DBStorage_Search", func() {
+	var (
+		ctrl    *gomock.Controller
+		db      *mocks.MockDB
+		stmt    *mocks.MockStmt
+		execs   []string
+		queries []string
+	)
+
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		db = mocks.NewMockDB(ctrl)
+		stmt = mocks.NewMockStmt(ctrl)
+		execs, queries = nil, nil
+		db.EXPECT().PreparexContext(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, query string) (storage.Stmt, error) {
+				queries = append(queries, query)
+				return stmt, nil
+			}).Times(preparedStatements)
+	})
+
+	AfterEach(func() {
+		ctrl.Finish()
+	})
+
+	// expectSchema expects the schema statements, with the pg_trgm extension installed if trigram is set.
+	expectSchema := func(trigram bool) {
+		installed := driver.RowsAffected(0)
+		if trigram {
+			installed = 1
+		}
+		db.EXPECT().ExecContext(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, query string, _ ...any) (sql.Result, error) {
+				execs = append(execs, query)
+				if strings.Contains(query, "pg_extension") {
+					return installed, nil
+				}
+				return driver.RowsAffected(0), nil
+			}).AnyTimes()
+	}
+
+	searchQuery := func() string {
+		for _, query := range queries {
+			if strings.Contains(query, "to_tsquery") {
+				return query
+			}
+		}
+		return ""
+	}
+
+	It("should index and rank by trigrams if pg_trgm is installed", func() {
+		expectSchema(true)
+		_, err := storage.NewDBStorage(context.Background(), db)
+		Expect(err).To(BeNil())
+		Expect(execs).To(ContainElement(ContainSubstring("gin_trgm_ops")))
+		Expect(searchQuery()).To(ContainSubstring("similarity("))
+	})
+
+	It("should search without trigrams if pg_trgm is not installed", func() {
+		expectSchema(false)
+		_, err := storage.NewDBStorage(context.Background(), db)
+		Expect(err).To(BeNil())
+		Expect(execs).NotTo(ContainElement(ContainSubstring("CREATE EXTENSION")))
+		Expect(execs).NotTo(ContainElement(ContainSubstring("gin_trgm_ops")))
+		Expect(searchQuery()).To(ContainSubstring("LIKE $3"))
+		Expect(searchQuery()).NotTo(ContainSubstring("similarity("))
+	})
+})
+
+var _ = Describe("DBStorage_Outbox", func() {
+	var (
+		ctrl    *gomock.Controller
+		db      *mocks.MockDB
+		stmt    *mocks.MockStmt
+		s       *storage.DBStorage
+		queries []string
+		err     error
+	)
+
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		db = mocks.NewMockDB(ctrl)
+		stmt = mocks.NewMockStmt(ctrl)
+		queries = nil
+		db.EXPECT().ExecContext(gomock.Any(), gomock.Any()).Return(driver.RowsAffected(0), nil).Times(2)
+		db.EXPECT().PreparexContext(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, query string) (storage.Stmt, error) {
+				queries = append(queries, query)
+				return stmt, nil
+			}).Times(preparedStatements)
+		s, err = storage.NewDBStorage(context.Background(), db)
+		Expect(err).To(BeNil())
+	})
+
+	AfterEach(func() {
+		ctrl.Finish()
+	})
+
+	It("should record every change of links in the statement making it", func() {
+		recorded := map[string]int{}
+		for _, query := range queries {
+			if strings.Contains(query, "INSERT INTO outbox") {
+				for _, event := range []string{models.EventLinkCreated, models.EventLinkUpdated, models.EventLinkDeleted} {
+					if strings.Contains(query, "'"+event+"'") {
+						recorded[event]++
+					}
+				}
+			}
+		}
+		// Save; PatchURL, SetMetadata and Restore; DeleteMany.
+		Expect(recorded).To(Equal(map[string]int{
+			models.EventLinkCreated: 1,
+			models.EventLinkUpdated: 3,
+			models.EventLinkDeleted: 1,
+		}))
+	})
+
+	It("should leave unpublished changes to the relay when purging", func() {
+		for _, query := range queries {
+			if strings.Contains(query, "DELETE FROM urls") {
+				Expect(query).NotTo(ContainSubstring("outbox"))
+			}
+		}
+	})
+
+	It("should not record changes without the outbox", func() {
+		db.EXPECT().ExecContext(gomock.Any(), gomock.Any()).Return(driver.RowsAffected(0), nil).Times(2)
+		db.EXPECT().PreparexContext(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, query string) (storage.Stmt, error) {
+				queries = append(queries, query)
+				return stmt, nil
+			}).Times(preparedStatements)
+		unrecorded, newErr := storage.NewDBStorage(context.Background(), db, storage.WithoutOutbox())
+		Expect(newErr).To(BeNil())
+		Expect(queries).To(HaveLen(2 * preparedStatements))
+		for _, query := range queries[preparedStatements:] {
+			Expect(query).NotTo(ContainSubstring("INSERT INTO outbox ("))
+		}
+
+		var query string
+		db.EXPECT().NamedQueryContext(gomock.Any(), gomock.Any(), gomock.Len(1)).
+			DoAndReturn(func(_ context.Context, q string, _ interface{}) (*sqlx.Rows, error) {
+				query = q
+				return nil, errors.New("db error")
+			})
+		_, err = unrecorded.SaveMany(context.Background(), []models.URL{{ShortURL: "00000001", OriginalURL: "http://example.com/1"}})
+		Expect(err).To(MatchError("db error"))
+		Expect(query).NotTo(ContainSubstring("INSERT INTO outbox ("))
+	})
+
+	It("should lease the outbox unless another holder has it", func() {
+		now := time.Now()
+		stmt.EXPECT().ExecContext(gomock.Any(), "relay", now, now.Add(time.Minute)).Return(driver.RowsAffected(1), nil)
+		Expect(s.LeaseOutbox(context.Background(), "relay", now, now.Add(time.Minute))).To(BeTrue())
+		stmt.EXPECT().ExecContext(gomock.Any(), "another", now, now.Add(time.Minute)).Return(driver.RowsAffected(0), nil)
+		Expect(s.LeaseOutbox(context.Background(), "another", now, now.Add(time.Minute))).To(BeFalse())
+	})
+
+	It("should get changes oldest first", func() {
+		stmt.EXPECT().SelectContext(gomock.Any(), gomock.Any(), 100).DoAndReturn(
+			func(_ context.Context, dest interface{}, _ ...interface{}) error {
+				*dest.(*[]models.Change) = []models.Change{{Sequence: 1}, {Sequence: 2}}
+				return nil
+			})
+		changes, getErr := s.GetChanges(context.Background(), 100)
+		Expect(getErr).To(BeNil())
+		Expect(changes).To(HaveLen(2))
+	})
+})
+
+var _ = Describe("FileStorage", func() {
+	var (
+		path string
+		s    *storage.FileStorage
+		err  error
+	)
+
+	BeforeEach(func() {
+		path = filepath.Join(GinkgoT().TempDir(), "storage.json")
+		s, err = storage.NewFileStorage(context.Background(), path)
+		Expect(err).To(BeNil())
+	})
+
+	It("should keep updated destinations, their history and API keys after reopening", func() {
+		ctx := context.Background()
+		Expect(s.Save(ctx, models.URL{UUID: "id", UserID: "user", ShortURL: "short1", OriginalURL: "http://old.com"})).To(Succeed())
+		Expect(s.PatchURL(ctx, "user", "short1", models.URLPatch{OriginalURL: "http://new.com"}, time.Now())).To(Succeed())
+		Expect(s.PatchURL(ctx, "other", "short1", models.URLPatch{OriginalURL: "http://evil.com"}, time.Now())).To(MatchError(storage.ErrNotFound))
+		Expect(s.SaveAPIKey(ctx, models.APIKey{ID: "key", UserID: "user", KeyHash: "hash"})).To(Succeed())
+		Expect(s.Save(ctx, models.URL{UserID: "user", ShortURL: "short2", OriginalURL: "http://deleted.com"})).To(Succeed())
+		Expect(s.DeleteMany(ctx, "user", []string{"short2"})).To(Succeed())
+		Expect(s.Close()).To(Succeed())
+
+		s, err = storage.NewFileStorage(ctx, path)
+		Expect(err).To(BeNil())
+		DeferCleanup(s.Close)
+
+		url, err := s.Get(ctx, "short1")
+		Expect(err).To(BeNil())
+		Expect(url.OriginalURL).To(Equal("http://new.com"))
+
+		history, err := s.GetURLHistory(ctx, "short1")
+		Expect(err).To(BeNil())
+		Expect(history).To(HaveLen(1))
+		Expect(history[0].OriginalURL).To(Equal("http://old.com"))
+
+		key, err := s.GetAPIKeyByHash(ctx, "hash")
+		Expect(err).To(BeNil())
+		Expect(key.ID).To(Equal("key"))
+
+		_, err = s.Get(ctx, "short2")
+		Expect(err).To(MatchError(storage.ErrDeleted))
+		Expect(s.Restore(ctx, "user", "short2", time.Now().Add(-time.Hour))).To(Succeed())
+	})
+
+	It("should report taken URLs of a batch and persist the others once", func() {
+		ctx := context.Background()
+		Expect(s.Save(ctx, models.URL{UserID: "user", ShortURL: "short1", OriginalURL: "http://one.com"})).To(Succeed())
+		results, saveErr := s.SaveMany(ctx, []models.URL{
+			{UserID: "user", ShortURL: "short1", OriginalURL: "http://one.com"},
+			{UserID: "user", ShortURL: "short2", OriginalURL: "http://two.com"},
+			{UserID: "user", ShortURL: "short2", OriginalURL: "http://two.com"},
+		})
+		Expect(saveErr).To(BeNil())
+		Expect(results).To(Equal([]error{storage.ErrAlreadyExist, nil, storage.ErrAlreadyExist}))
+		Expect(s.Close()).To(Succeed())
+
+		reopened, err := storage.NewFileStorage(ctx, path)
+		Expect(err).To(BeNil())
+		DeferCleanup(reopened.Close)
+		urls, err := reopened.GetAll(ctx, "user", models.URLFilter{})
+		Expect(err).To(BeNil())
+		Expect(urls).To(HaveLen(2))
+	})
+
+	It("should keep redirect codes after reopening", func() {
+		ctx := context.Background()
+		Expect(s.Save(ctx, models.URL{UserID: "user", ShortURL: "short1", OriginalURL: "http://old.com"})).To(Succeed())
+		Expect(s.PatchURL(ctx, "user", "short1", models.URLPatch{RedirectCode: http.StatusPermanentRedirect}, time.Now())).To(Succeed())
+		Expect(s.PatchURL(ctx, "other", "short1", models.URLPatch{RedirectCode: http.StatusFound}, time.Now())).To(MatchError(storage.ErrNotFound))
+		Expect(s.Close()).To(Succeed())
+
+		reopened, err := storage.NewFileStorage(ctx, path)
+		Expect(err).To(BeNil())
+		DeferCleanup(reopened.Close)
+		url, err := reopened.Get(ctx, "short1")
+		Expect(err).To(BeNil())
+		Expect(url.RedirectCode).To(Equal(http.StatusPermanentRedirect))
+	})
+
+	It("should apply a patch completely or not at all and keep it after reopening", func() {
+		ctx := context.Background()
+		Expect(s.Save(ctx, models.URL{UserID: "user", ShortURL: "short1", OriginalURL: "http://old.com"})).To(Succeed())
+		patch := models.URLPatch{
+			OriginalURL:  "http://new.com",
+			RedirectCode: http.StatusFound,
+			Metadata:     &models.URLMetadata{Title: "New", Tags: models.Tags{"docs"}},
+		}
+		Expect(s.PatchURL(ctx, "other", "short1", patch, time.Now())).To(MatchError(storage.ErrNotFound))
+		url, err := s.Get(ctx, "short1")
+		Expect(err).To(BeNil())
+		Expect(url.OriginalURL).To(Equal("http://old.com"))
+		Expect(url.RedirectCode).To(BeZero())
+		Expect(url.Title).To(BeEmpty())
+		Expect(s.GetURLHistory(ctx, "short1")).To(BeEmpty())
+
+		Expect(s.PatchURL(ctx, "user", "short1", patch, time.Now())).To(Succeed())
+		Expect(s.PatchURL(ctx, "user", "short1", models.URLPatch{RedirectCode: http.StatusMovedPermanently}, time.Now())).To(Succeed())
+		Expect(s.Close()).To(Succeed())
+
+		reopened, err := storage.NewFileStorage(ctx, path)
+		Expect(err).To(BeNil())
+		DeferCleanup(reopened.Close)
+		url, err = reopened.Get(ctx, "short1")
+		Expect(err).To(BeNil())
+		Expect(url.OriginalURL).To(Equal("http://new.com"))
+		Expect(url.RedirectCode).To(Equal(http.StatusMovedPermanently))
+		Expect(url.Title).To(Equal("New"))
+		Expect(url.Tags).To(Equal(models.Tags{"docs"}))
+		history, err := reopened.GetURLHistory(ctx, "short1")
+		Expect(err).To(BeNil())
+		Expect(history).To(HaveLen(1))
+		Expect(history[0].OriginalURL).To(Equal("http://old.com"))
+	})
+
+	It("should keep expiry times after reopening", func() {
+		ctx := context.Background()
+		expiresAt := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+		Expect(s.Save(ctx, models.URL{UserID: "user", ShortURL: "short1", OriginalURL: "http://old.com", ExpiresAt: &expiresAt})).To(Succeed())
+		Expect(s.Close()).To(Succeed())
+
+		reopened, err := storage.NewFileStorage(ctx, path)
+		Expect(err).To(BeNil())
+		DeferCleanup(reopened.Close)
+		url, err := reopened.Get(ctx, "short1")
+		Expect(err).To(BeNil())
+		Expect(url.ExpiresAt).NotTo(BeNil())
+		Expect(url.ExpiresAt.Equal(expiresAt)).To(BeTrue())
+	})
+
+	It("should keep metadata after reopening", func() {
+		ctx := context.Background()
+		meta := models.URLMetadata{Title: "Example", Notes: "notes", Tags: models.Tags{"docs", "work"}}
+		Expect(s.Save(ctx, models.URL{UserID: "user", ShortURL: "short1", OriginalURL: "http://old.com"})).To(Succeed())
+		Expect(s.SetMetadata(ctx, "user", "short1", meta)).To(Succeed())
+		Expect(s.SetMetadata(ctx, "other", "short1", models.URLMetadata{})).To(MatchError(storage.ErrNotFound))
+		Expect(s.Close()).To(Succeed())
+
+		reopened, err := storage.NewFileStorage(ctx, path)
+		Expect(err).To(BeNil())
+		DeferCleanup(reopened.Close)
+		url, err := reopened.Get(ctx, "short1")
+		Expect(err).To(BeNil())
+		Expect(url.URLMetadata).To(Equal(meta))
+
+		urls, err := reopened.GetAll(ctx, "user", models.URLFilter{Tags: []string{"work"}})
+		Expect(err).To(BeNil())
+		Expect(urls).To(HaveLen(1))
+		urls, err = reopened.GetAll(ctx, "user", models.URLFilter{Tags: []string{"work", "personal"}})
+		Expect(err).To(BeNil())
+		Expect(urls).To(BeEmpty())
+
+		urls, err = reopened.Search(ctx, "user", "example work", 0)
+		Expect(err).To(BeNil())
+		Expect(urls).To(HaveLen(1))
+	})
+
+	It("should keep first clicks, webhooks and pending deliveries after reopening", func() {
+		ctx := context.Background()
+		Expect(s.Save(ctx, models.URL{UserID: "user", ShortURL: "short1", OriginalURL: "http://old.com"})).To(Succeed())
+		Expect(s.RecordFirstClick(ctx, "short1", time.Now())).To(BeTrue())
+		Expect(s.RecordFirstClick(ctx, "short1", time.Now())).To(BeFalse())
+
+		now := time.Now().UTC()
+		Expect(s.SaveWebhook(ctx, models.Webhook{ID: "hook", UserID: "user", URL: "http://crm.com", Secret: "secret"})).To(Succeed())
+		Expect(s.SaveDeliveries(ctx, []models.WebhookDelivery{
+			{ID: "sent", WebhookID: "hook", Payload: []byte(`{}`), Status: models.DeliveryPending, CreatedAt: now, NextAttemptAt: &now},
+			{ID: "pending", WebhookID: "hook", Payload: []byte(`{}`), Status: models.DeliveryPending, CreatedAt: now, NextAttemptAt: &now},
+		})).To(Succeed())
+		claimed, err := s.ClaimDeliveries(ctx, now, now.Add(time.Minute), 1)
+		Expect(err).To(BeNil())
+		Expect(claimed).To(HaveLen(1))
+		Expect(s.ClaimDeliveries(ctx, now, now.Add(time.Minute), 1)).To(HaveLen(1), "claimed deliveries are skipped")
+		Expect(s.ClaimDeliveries(ctx, now, now.Add(time.Minute), 1)).To(BeEmpty())
+		sent := claimed[0]
+		sent.Status, sent.Attempts, sent.NextAttemptAt = models.DeliveryDelivered, 1, nil
+		Expect(s.UpdateDelivery(ctx, sent)).To(Succeed())
+		Expect(s.Close()).To(Succeed())
+
+		s, err = storage.NewFileStorage(ctx, path)
+		Expect(err).To(BeNil())
+		DeferCleanup(s.Close)
+
+		url, err := s.Get(ctx, "short1")
+		Expect(err).To(BeNil())
+		Expect(url.ClickedAt).NotTo(BeNil())
+
+		hook, err := s.GetWebhook(ctx, "hook")
+		Expect(err).To(BeNil())
+		Expect(hook.Secret).To(Equal("secret"))
+
+		deliveries, err := s.ClaimDeliveries(ctx, now.Add(time.Hour), now.Add(time.Hour), 0)
+		Expect(err).To(BeNil())
+		Expect(deliveries).To(HaveLen(1), "pending deliveries are due again after a restart")
+		Expect(deliveries[0].ID).NotTo(Equal(sent.ID))
+
+		Expect(s.DeleteWebhook(ctx, "other", "hook")).To(MatchError(storage.ErrNotFound))
+		Expect(s.DeleteWebhook(ctx, "user", "hook")).To(Succeed())
+		Expect(s.GetDeliveries(ctx, "hook", 0)).To(BeEmpty())
+	})
+
+	It("should let only one user verify a domain and keep domains after reopening", func() {
+		ctx := context.Background()
+		now := time.Now().UTC()
+		Expect(s.SaveDomain(ctx, models.Domain{Name: "go.brand.com", UserID: "user", VerificationToken: "a", CreatedAt: now})).To(Succeed())
+		Expect(s.SaveDomain(ctx, models.Domain{Name: "go.brand.com", UserID: "other", VerificationToken: "b", CreatedAt: now})).To(Succeed())
+		Expect(s.SaveDomain(ctx, models.Domain{Name: "go.brand.com", UserID: "user"})).To(MatchError(storage.ErrAlreadyExist))
+		_, err := s.GetVerifiedDomain(ctx, "go.brand.com")
+		Expect(err).To(MatchError(storage.ErrNotFound))
+
+		Expect(s.VerifyDomain(ctx, "user", "go.brand.com", now)).To(Succeed())
+		Expect(s.VerifyDomain(ctx, "other", "go.brand.com", now)).To(MatchError(storage.ErrAlreadyExist))
+		Expect(s.VerifyDomain(ctx, "user", "brand.link", now)).To(MatchError(storage.ErrNotFound))
+		Expect(s.Close()).To(Succeed())
+
+		s, err = storage.NewFileStorage(ctx, path)
+		Expect(err).To(BeNil())
+		DeferCleanup(s.Close)
+
+		domain, err := s.GetVerifiedDomain(ctx, "go.brand.com")
+		Expect(err).To(BeNil())
+		Expect(domain.UserID).To(Equal("user"))
+		Expect(domain.Verified()).To(BeTrue())
+		domains, err := s.GetDomains(ctx, "other")
+		Expect(err).To(BeNil())
+		Expect(domains).To(HaveLen(1))
+		Expect(domains[0].Verified()).To(BeFalse())
+	})
+
+	It("should keep a workspace owner and members after reopening", func() {
+		ctx := context.Background()
+		now := time.Now().UTC()
+		workspace := models.Workspace{ID: "team", Name: "Team", CreatedAt: now}
+		owner := models.WorkspaceMember{WorkspaceID: "team", UserID: "owner", Role: models.RoleOwner, AddedAt: now}
+		Expect(s.CreateWorkspace(ctx, workspace, owner)).To(Succeed())
+		Expect(s.CreateWorkspace(ctx, workspace, owner)).To(MatchError(storage.ErrAlreadyExist))
+		editor := models.WorkspaceMember{WorkspaceID: "team", UserID: "editor", Role: models.RoleEditor, AddedAt: now.Add(time.Second)}
+		Expect(s.SaveMember(ctx, editor)).To(Succeed())
+		viewer := models.WorkspaceMember{WorkspaceID: "team", UserID: "viewer", Role: models.RoleViewer, AddedAt: now.Add(2 * time.Second)}
+		Expect(s.SaveMember(ctx, viewer)).To(Succeed())
+
+		owner.Role = models.RoleEditor
+		Expect(s.SaveMember(ctx, owner)).To(MatchError(storage.ErrLastOwner))
+		Expect(s.DeleteMember(ctx, "team", "owner")).To(MatchError(storage.ErrLastOwner))
+		Expect(s.DeleteMember(ctx, "team", "nobody")).To(MatchError(storage.ErrNotFound))
+		Expect(s.DeleteMember(ctx, "team", "viewer")).To(Succeed())
+		editor.Role = models.RoleOwner
+		Expect(s.SaveMember(ctx, editor)).To(Succeed())
+		Expect(s.SaveMember(ctx, owner)).To(Succeed())
+		Expect(s.Close()).To(Succeed())
+
+		reopened, err := storage.NewFileStorage(ctx, path)
+		Expect(err).To(BeNil())
+		DeferCleanup(reopened.Close)
+
+		members, err := reopened.GetMembers(ctx, "team")
+		Expect(err).To(BeNil())
+		Expect(members).To(HaveLen(2))
+		Expect(members[0].UserID).To(Equal("owner"))
+		Expect(members[0].Role).To(Equal(models.RoleEditor))
+		Expect(members[1].AddedAt).To(BeTemporally("==", now.Add(time.Second)))
+		Expect(members[1].Role).To(Equal(models.RoleOwner))
+		workspaces, err := reopened.GetWorkspaces(ctx, "editor")
+		Expect(err).To(BeNil())
+		Expect(workspaces).To(ConsistOf(models.Workspace{ID: "team", Name: "Team", Role: models.RoleOwner, CreatedAt: now}))
+		_, err = reopened.GetMember(ctx, "team", "viewer")
+		Expect(err).To(MatchError(storage.ErrNotFound))
+	})
+
+	It("should keep plans and usage after reopening", func() {
+		ctx := context.Background()
+		Expect(s.Save(ctx, models.URL{UUID: "1", ShortURL: "short1", OriginalURL: "http://a.com", UserID: "user"})).To(Succeed())
+		Expect(s.Save(ctx, models.URL{UUID: "2", ShortURL: "short2", OriginalURL: "http://b.com", UserID: "user"})).To(Succeed())
+		Expect(s.DeleteMany(ctx, "user", []string{"short2"})).To(Succeed())
+		_, err := s.GetPlan(ctx, "user")
+		Expect(err).To(MatchError(storage.ErrNotFound))
+		Expect(s.SetPlan(ctx, "user", "free")).To(Succeed())
+		Expect(s.SetPlan(ctx, "user", "pro")).To(Succeed())
+		Expect(s.AddUsage(ctx, "user", "2026-10", 2)).To(Succeed())
+		Expect(s.AddUsage(ctx, "user", "2026-10", 3)).To(Succeed())
+		Expect(s.AddUsage(ctx, "user", "2026-11", 1)).To(Succeed())
+		Expect(s.Close()).To(Succeed())
+
+		reopened, err := storage.NewFileStorage(ctx, path)
+		Expect(err).To(BeNil())
+		DeferCleanup(reopened.Close)
+
+		Expect(reopened.GetPlan(ctx, "user")).To(Equal("pro"))
+		Expect(reopened.GetUsage(ctx, "user", "2026-10")).To(Equal(5))
+		Expect(reopened.GetUsage(ctx, "user", "2026-11")).To(Equal(1))
+		Expect(reopened.GetUsage(ctx, "user", "2026-12")).To(BeZero())
+		Expect(reopened.CountActiveURLs(ctx, "user")).To(Equal(1))
+	})
+
+	It("should keep audit entries after reopening and drop purged ones from the file", func() {
+		ctx := context.Background()
+		now := time.Now().UTC().Truncate(time.Second)
+		Expect(s.SaveAuditEntries(ctx, []models.AuditEntry{
+			{ID: "1", Actor: "user", Action: models.AuditLinkCreated, Target: "short1", After: json.RawMessage(`{"id":"short1"}`), CreatedAt: now.Add(-2 * time.Hour)},
+			{ID: "2", Actor: "user", Action: models.AuditLinkDeleted, Target: "short1", Before: json.RawMessage(`{"id":"short1"}`), CreatedAt: now},
+		})).To(Succeed())
+		Expect(s.PurgeAuditEntries(ctx, now.Add(-time.Hour))).To(Equal(int64(1)))
+		Expect(s.Close()).To(Succeed())
+
+		reopened, err := storage.NewFileStorage(ctx, path)
+		Expect(err).To(BeNil())
+		DeferCleanup(reopened.Close)
+
+		entries, err := reopened.GetAuditEntries(ctx, models.AuditFilter{Target: "short1"})
+		Expect(err).To(BeNil())
+		Expect(entries).To(HaveLen(1))
+		Expect(entries[0].ID).To(Equal("2"))
+		Expect(entries[0].Before).To(MatchJSON(`{"id":"short1"}`))
+		Expect(entries[0].CreatedAt.Equal(now)).To(BeTrue())
+	})
+
+	It("should drop purged URLs from the file", func() {
+		ctx := context.Background()
+		Expect(s.Save(ctx, models.URL{UserID: "user", ShortURL: "short1", OriginalURL: "http://old.com"})).To(Succeed())
+		Expect(s.DeleteMany(ctx, "user", []string{"short1"})).To(Succeed())
+		purged, err := s.Purge(ctx, time.Now().Add(time.Hour))
+		Expect(err).To(BeNil())
+		Expect(purged).To(Equal(int64(1)))
+		Expect(s.Close()).To(Succeed())
+
+		s, err = storage.NewFileStorage(ctx, path)
+		Expect(err).To(BeNil())
+		DeferCleanup(s.Close)
+		_, err = s.Get(ctx, "short1")
+		Expect(err).To(MatchError(storage.ErrNotFound))
+	})
+})
+
+var _ = Describe("MemoryStorage_Search", func() {
+	var (
+		s   *storage.MemoryStorage
+		ctx context.Context
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		var err error
+		s, err = storage.NewMemoryStorage(ctx)
+		Expect(err).To(BeNil())
+		results, err := s.SaveMany(ctx, []models.URL{
+			{UserID: "user", ShortURL: "Report01", OriginalURL: "https://docs.example.com/reports/q3-report.pdf",
+				URLMetadata: models.URLMetadata{Title: "Quarterly report", Tags: models.Tags{"finance"}}},
+			{UserID: "user", ShortURL: "Report02", OriginalURL: "https://docs.example.com/reports/q4-reporting.pdf"},
+			{UserID: "user", ShortURL: "Blog0001", OriginalURL: "https://blog.example.com/posts/hello",
+				URLMetadata: models.URLMetadata{Title: "Hello world", Tags: models.Tags{"personal"}}},
+			{UserID: "other", ShortURL: "Report03", OriginalURL: "https://docs.example.com/reports/q3-report.pdf"},
+		})
+		Expect(err).To(BeNil())
+		Expect(results).To(HaveEach(BeNil()))
+	})
+
+	shortURLs := func(urls []models.URL) []string {
+		var shorts []string
+		for _, url := range urls {
+			shorts = append(shorts, url.ShortURL)
+		}
+		return shorts
+	}
+
+	It("should match every word by prefix, exact matches first", func() {
+		urls, err := s.Search(ctx, "user", "report", 0)
+		Expect(err).To(BeNil())
+		Expect(shortURLs(urls)).To(Equal([]string{"Report01", "Report02"}))
+
+		urls, err = s.Search(ctx, "user", "Q3 REP", 0)
+		Expect(err).To(BeNil())
+		Expect(shortURLs(urls)).To(Equal([]string{"Report01"}))
+
+		urls, err = s.Search(ctx, "user", "report personal", 0)
+		Expect(err).To(BeNil())
+		Expect(urls).To(BeEmpty())
+	})
+
+	It("should match short codes, titles and tags", func() {
+		for query, want := range map[string]string{"blog0001": "Blog0001", "hello world": "Blog0001", "quarterly": "Report01", "finance": "Report01"} {
+			urls, err := s.Search(ctx, "user", query, 0)
+			Expect(err).To(BeNil())
+			Expect(shortURLs(urls)).To(Equal([]string{want}), query)
+		}
+	})
+
+	It("should limit the results", func() {
+		urls, err := s.Search(ctx, "user", "example", 2)
+		Expect(err).To(BeNil())
+		Expect(urls).To(HaveLen(2))
+	})
+
+	It("should iterate over the user's URLs until stopped", func() {
+		var shorts []string
+		for url, err := range s.IterateAll(ctx, "user") {
+			Expect(err).To(BeNil())
+			shorts = append(shorts, url.ShortURL)
+		}
+		Expect(shorts).To(ConsistOf("Report01", "Report02", "Blog0001"))
+
+		var count int
+		for range s.IterateAll(ctx, "user") {
+			count++
+			break
+		}
+		Expect(count).To(Equal(1))
+	})
+
+	It("should follow changes and skip deleted URLs", func() {
+		Expect(s.PatchURL(ctx, "user", "Blog0001", models.URLPatch{OriginalURL: "https://blog.example.com/posts/goodbye"}, time.Now())).To(Succeed())
+		Expect(s.SetMetadata(ctx, "user", "Report02", models.URLMetadata{Tags: models.Tags{"archive"}})).To(Succeed())
+		Expect(s.DeleteMany(ctx, "user", []string{"Report01"})).To(Succeed())
+
+		urls, err := s.Search(ctx, "user", "posts goodbye", 0)
+		Expect(err).To(BeNil())
+		Expect(shortURLs(urls)).To(Equal([]string{"Blog0001"}))
+		urls, err = s.Search(ctx, "user", "reporting", 0)
+		Expect(err).To(BeNil())
+		Expect(shortURLs(urls)).To(Equal([]string{"Report02"}))
+
+		urls, err = s.Search(ctx, "user", "archive", 0)
+		Expect(err).To(BeNil())
+		Expect(shortURLs(urls)).To(Equal([]string{"Report02"}))
+
+		urls, err = s.Search(ctx, "user", "q3", 0)
+		Expect(err).To(BeNil())
+		Expect(urls).To(BeEmpty())
+	})
+})
+
+var _ = Describe("MemoryStorage_Restore", func() {
+	var (
+		s   *storage.MemoryStorage
+		ctx context.Context
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		var err error
+		s, err = storage.NewMemoryStorage(ctx)
+		Expect(err).To(BeNil())
+		Expect(s.Save(ctx, models.URL{UserID: "user", ShortURL: "short1", OriginalURL: "http://example.com"})).To(Succeed())
+	})
+
+	It("should soft-delete only the owner's URLs", func() {
+		Expect(s.DeleteMany(ctx, "other", []string{"short1"})).To(Succeed())
+		_, err := s.Get(ctx, "short1")
+		Expect(err).To(BeNil())
+
+		Expect(s.DeleteMany(ctx, "user", []string{"short1"})).To(Succeed())
+		_, err = s.Get(ctx, "short1")
+		Expect(err).To(MatchError(storage.ErrDeleted))
+		urls, err := s.GetAll(ctx, "user", models.URLFilter{})
+		Expect(err).To(BeNil())
+		Expect(urls).To(BeEmpty())
+	})
+
+	It("should restore a URL deleted within the window", func() {
+		Expect(s.DeleteMany(ctx, "user", []string{"short1"})).To(Succeed())
+		Expect(s.Restore(ctx, "other", "short1", time.Now().Add(-time.Hour))).To(MatchError(storage.ErrNotFound))
+		Expect(s.Restore(ctx, "user", "short1", time.Now().Add(-time.Hour))).To(Succeed())
+
+		url, err := s.Get(ctx, "short1")
+		Expect(err).To(BeNil())
+		Expect(url.DeletedAt).To(BeNil())
+		Expect(s.Restore(ctx, "user", "short1", time.Now().Add(-time.Hour))).To(MatchError(storage.ErrNotFound))
+	})
+
+	It("should purge URLs deleted before the window and not restore them", func() {
+		Expect(s.DeleteMany(ctx, "user", []string{"short1"})).To(Succeed())
+		Expect(s.Restore(ctx, "user", "short1", time.Now().Add(time.Hour))).To(MatchError(storage.ErrNotFound))
+
+		purged, err := s.Purge(ctx, time.Now().Add(-time.Hour))
+		Expect(err).To(BeNil())
+		Expect(purged).To(BeZero())
+
+		purged, err = s.Purge(ctx, time.Now().Add(time.Hour))
+		Expect(err).To(BeNil())
+		Expect(purged).To(Equal(int64(1)))
+		_, err = s.Get(ctx, "short1")
+		Expect(err).To(MatchError(storage.ErrNotFound))
+	})
+})
@@ -0,0 +1,233 @@
+// Package transfer reads and writes the CSV and JSON-lines files links are imported from and exported to.
+//
+// CSV files have the columns original_url, alias, tags, expires_at, short_url and title, in this order
+// unless the first row is a header naming them. Only original_url is required on import; short_url and title
+// are ignored. Tags are separated by commas or semicolons and the expiry is an RFC 3339 timestamp.
+// JSON-lines files hold one models.LinkRecord object per line.
+package transfer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"mime"
+	"strings"
+	"time"
+
+	"github.com/grnsv/shortener/internal/models"
+)
+
+// Format is a file format of links.
+type Format string
+
+// Supported formats.
+const (
+	FormatCSV       Format = "csv"
+	FormatJSONLines Format = "jsonl"
+)
+
+// maxJSONLineSize limits the length of a line of a JSON-lines file.
+const maxJSONLineSize = 64 << 10
+
+// ErrUnknownFormat is returned for formats other than CSV and JSON lines.
+var ErrUnknownFormat = errors.New("unknown format, use csv or jsonl")
+
+// columns are the CSV columns in their default order.
+var columns = []string{"original_url", "alias", "tags", "expires_at", "short_url", "title"}
+
+// ParseFormat recognizes a format by its name or media type.
+func ParseFormat(s string) (Format, error) {
+	if mediaType, _, err := mime.ParseMediaType(s); err == nil {
+		s = mediaType
+	}
+	switch strings.ToLower(s) {
+	case "csv", "text/csv":
+		return FormatCSV, nil
+	case "jsonl", "ndjson", "application/jsonl", "application/x-ndjson", "application/x-jsonlines":
+		return FormatJSONLines, nil
+	}
+	return "", ErrUnknownFormat
+}
+
+// ContentType returns the media type of files in the format.
+func (f Format) ContentType() string {
+	if f == FormatJSONLines {
+		return "application/x-ndjson"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// Reader reads link records from a file.
+type Reader struct {
+	r      io.Reader
+	format Format
+	err    error
+}
+
+// NewReader creates a Reader of a file in the given format.
+func NewReader(r io.Reader, format Format) *Reader {
+	return &Reader{r: r, format: format}
+}
+
+// Records yields the records of the file numbered from 1, not counting a CSV header or blank lines.
+// Malformed records are yielded with an error and skipped. Reading stops at the first error of the
+// underlying reader, which Err returns afterwards.
+func (r *Reader) Records() iter.Seq2[models.LinkRecord, error] {
+	if r.format == FormatJSONLines {
+		return r.jsonLines
+	}
+	return r.csv
+}
+
+// Err returns the error that stopped reading, if any.
+func (r *Reader) Err() error {
+	return r.err
+}
+
+func (r *Reader) csv(yield func(models.LinkRecord, error) bool) {
+	reader := csv.NewReader(r.r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	index := make(map[string]int, len(columns))
+	for i, column := range columns {
+		index[column] = i
+	}
+	field := func(fields []string, column string) string {
+		if i, ok := index[column]; ok && i < len(fields) {
+			return strings.TrimSpace(fields[i])
+		}
+		return ""
+	}
+
+	for row, first := 0, true; ; first = false {
+		fields, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return
+		}
+		var parseErr *csv.ParseError
+		if err != nil && !errors.As(err, &parseErr) {
+			r.err = err
+			return
+		}
+
+		if first && err == nil && len(fields) > 0 && strings.EqualFold(strings.TrimSpace(fields[0]), columns[0]) {
+			clear(index)
+			for i, name := range fields {
+				index[strings.ToLower(strings.TrimSpace(name))] = i
+			}
+			continue
+		}
+
+		row++
+		record := models.LinkRecord{Row: row}
+		if err == nil {
+			record.OriginalURL = field(fields, "original_url")
+			record.Alias = field(fields, "alias")
+			if tags := field(fields, "tags"); tags != "" {
+				record.Tags = strings.FieldsFunc(tags, func(r rune) bool {
+					return r == ',' || r == ';'
+				})
+			}
+			record.ExpiresAt, err = parseExpiry(field(fields, "expires_at"))
+		}
+		if !yield(record, err) {
+			return
+		}
+	}
+}
+
+func (r *Reader) jsonLines(yield func(models.LinkRecord, error) bool) {
+	scanner := bufio.NewScanner(r.r)
+	scanner.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), maxJSONLineSize)
+
+	for row := 0; scanner.Scan(); {
+		line := scanner.Bytes()
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		row++
+		record := models.LinkRecord{}
+		err := json.Unmarshal(line, &record)
+		record.Row = row
+		if !yield(record, err) {
+			return
+		}
+	}
+	r.err = scanner.Err()
+}
+
+func parseExpiry(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	expiresAt, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, fmt.Errorf("expires_at: %w", err)
+	}
+	return &expiresAt, nil
+}
+
+// Writer writes link records to a file.
+type Writer struct {
+	csv         *csv.Writer
+	json        *json.Encoder
+	wroteHeader bool
+}
+
+// NewWriter creates a Writer of a file in the given format. CSV files start with a header.
+func NewWriter(w io.Writer, format Format) *Writer {
+	if format == FormatJSONLines {
+		return &Writer{json: json.NewEncoder(w)}
+	}
+	return &Writer{csv: csv.NewWriter(w)}
+}
+
+// Write writes a record.
+func (w *Writer) Write(record models.LinkRecord) error {
+	if w.json != nil {
+		return w.json.Encode(record)
+	}
+
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+	var expiresAt string
+	if record.ExpiresAt != nil {
+		expiresAt = record.ExpiresAt.UTC().Format(time.RFC3339)
+	}
+	return w.csv.Write([]string{
+		record.OriginalURL,
+		record.Alias,
+		strings.Join(record.Tags, ","),
+		expiresAt,
+		record.ShortURL,
+		record.Title,
+	})
+}
+
+// Flush writes any buffered data, and the CSV header if no record has been written.
+func (w *Writer) Flush() error {
+	if w.csv == nil {
+		return nil
+	}
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+	w.csv.Flush()
+	return w.csv.Error()
+}
+
+func (w *Writer) writeHeader() error {
+	if w.wroteHeader {
+		return nil
+	}
+	w.wroteHeader = true
+	return w.csv.Write(columns)
+}
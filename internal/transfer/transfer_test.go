@@ -0,0 +1,141 @@
+package transfer
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grnsv/shortener/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readAll(t *testing.T, input string, format Format) ([]models.LinkRecord, []error) {
+	t.Helper()
+	reader := NewReader(strings.NewReader(input), format)
+	var records []models.LinkRecord
+	var errs []error
+	for record, err := range reader.Records() {
+		records = append(records, record)
+		errs = append(errs, err)
+	}
+	require.NoError(t, reader.Err())
+	return records, errs
+}
+
+func TestParseFormat(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Format
+		wantErr bool
+	}{
+		{in: "csv", want: FormatCSV},
+		{in: "text/csv; charset=utf-8", want: FormatCSV},
+		{in: "JSONL", want: FormatJSONLines},
+		{in: "application/x-ndjson", want: FormatJSONLines},
+		{in: "application/json", wantErr: true},
+		{in: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseFormat(tt.in)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrUnknownFormat)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestReadCSV(t *testing.T) {
+	t.Run("default columns", func(t *testing.T) {
+		records, errs := readAll(t, "http://example.com/1\nhttp://example.com/2, docs ,\"work;Docs\",2030-01-02T03:04:05Z\n", FormatCSV)
+		require.Len(t, records, 2)
+		assert.Equal(t, []error{nil, nil}, errs)
+		assert.Equal(t, models.LinkRecord{Row: 1, OriginalURL: "http://example.com/1"}, records[0])
+		assert.Equal(t, 2, records[1].Row)
+		assert.Equal(t, "docs", records[1].Alias)
+		assert.Equal(t, models.Tags{"work", "Docs"}, records[1].Tags)
+		require.NotNil(t, records[1].ExpiresAt)
+		assert.True(t, records[1].ExpiresAt.Equal(time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)))
+	})
+
+	t.Run("header selects columns", func(t *testing.T) {
+		records, errs := readAll(t, "original_url,tags\nhttp://example.com/1,\"a,b\"\n", FormatCSV)
+		require.Len(t, records, 1)
+		assert.NoError(t, errs[0])
+		assert.Equal(t, models.LinkRecord{Row: 1, OriginalURL: "http://example.com/1", Tags: models.Tags{"a", "b"}}, records[0])
+	})
+
+	t.Run("malformed rows are reported and skipped", func(t *testing.T) {
+		records, errs := readAll(t, "http://example.com/1,,,tomorrow\nhttp://exa\"mple.com\nhttp://example.com/3\n", FormatCSV)
+		require.Len(t, records, 3)
+		assert.Error(t, errs[0])
+		assert.Error(t, errs[1])
+		assert.NoError(t, errs[2])
+		assert.Equal(t, 3, records[2].Row)
+	})
+}
+
+func TestReadJSONLines(t *testing.T) {
+	records, errs := readAll(t, `{"original_url":"http://example.com/1","alias":"one","tags":["a"]}
+
+not json
+{"original_url":"http://example.com/3","expires_at":"2030-01-02T03:04:05Z"}
+`, FormatJSONLines)
+	require.Len(t, records, 3)
+	assert.NoError(t, errs[0])
+	assert.Equal(t, models.LinkRecord{Row: 1, OriginalURL: "http://example.com/1", Alias: "one", Tags: models.Tags{"a"}}, records[0])
+	assert.Error(t, errs[1])
+	assert.Equal(t, 2, records[1].Row)
+	assert.NoError(t, errs[2])
+	assert.NotNil(t, records[2].ExpiresAt)
+}
+
+func TestReadStopsAtReaderError(t *testing.T) {
+	reader := NewReader(strings.NewReader(`{"original_url":"`+strings.Repeat("a", maxJSONLineSize)+`"}`), FormatJSONLines)
+	for range reader.Records() {
+		t.Fatal("no record expected")
+	}
+	assert.True(t, errors.Is(reader.Err(), bufio.ErrTooLong))
+}
+
+func TestWriteRoundTrip(t *testing.T) {
+	expiresAt := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	record := models.LinkRecord{
+		OriginalURL: "http://example.com/1",
+		Alias:       "abc",
+		Tags:        models.Tags{"a", "b"},
+		ExpiresAt:   &expiresAt,
+		ShortURL:    "http://localhost/abc",
+		Title:       "Example, Inc.",
+	}
+
+	for _, format := range []Format{FormatCSV, FormatJSONLines} {
+		t.Run(string(format), func(t *testing.T) {
+			var buf bytes.Buffer
+			writer := NewWriter(&buf, format)
+			require.NoError(t, writer.Write(record))
+			require.NoError(t, writer.Flush())
+
+			records, errs := readAll(t, buf.String(), format)
+			require.Len(t, records, 1)
+			assert.NoError(t, errs[0])
+			assert.Equal(t, record.OriginalURL, records[0].OriginalURL)
+			assert.Equal(t, record.Alias, records[0].Alias)
+			assert.Equal(t, record.Tags, records[0].Tags)
+			assert.True(t, record.ExpiresAt.Equal(*records[0].ExpiresAt))
+		})
+	}
+
+	t.Run("empty CSV has a header", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, NewWriter(&buf, FormatCSV).Flush())
+		assert.Equal(t, "original_url,alias,tags,expires_at,short_url,title\n", buf.String())
+	})
+}
@@ -0,0 +1,276 @@
+// Package webhook delivers events of links to the URLs their owners subscribed with webhooks.
+// Deliveries are queued in the storage, so they survive restarts. Every request is signed with
+// the secret of its webhook and retried with exponential backoff until the endpoint accepts it
+// or the attempts run out.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/grnsv/shortener/internal/logger"
+	"github.com/grnsv/shortener/internal/metadata"
+	"github.com/grnsv/shortener/internal/models"
+	"github.com/grnsv/shortener/internal/storage"
+)
+
+// Headers of webhook requests.
+const (
+	SignatureHeader = "X-Webhook-Signature" // signature of the timestamp and the body, see Sign
+	TimestampHeader = "X-Webhook-Timestamp" // Unix time the request was sent at
+	EventHeader     = "X-Webhook-Event"     // name of the event, such as link.created
+	DeliveryHeader  = "X-Webhook-Delivery"  // ID of the delivery, the same for all its attempts
+)
+
+// Defaults of the Dispatcher.
+const (
+	DefaultMaxAttempts  = 8
+	DefaultBackoff      = 30 * time.Second
+	DefaultMaxBackoff   = 6 * time.Hour
+	DefaultPollInterval = time.Second
+)
+
+const (
+	// requestTimeout limits how long an endpoint may take to answer.
+	requestTimeout = 10 * time.Second
+	// lease is how long claimed deliveries are hidden from other workers. It must exceed requestTimeout.
+	lease = time.Minute
+	// batchSize is the number of deliveries claimed and sent in parallel at a time.
+	batchSize = 16
+	// maxResponseSize is how much of a response body is read so the connection can be reused.
+	maxResponseSize = 64 << 10
+)
+
+// Sign returns the signature of a request body sent at the Unix time timestamp:
+// "sha256=" followed by the hex-encoded HMAC-SHA256 of "<timestamp>.<body>" keyed with the secret of the webhook.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether signature is the signature of body sent at timestamp, in constant time.
+// Receivers should also reject timestamps too far from the current time to prevent replays.
+func Verify(secret string, timestamp int64, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
+
+// Dispatcher sends queued webhook deliveries.
+type Dispatcher struct {
+	store        storage.WebhookStorage
+	client       *http.Client
+	log          logger.Logger
+	maxAttempts  int
+	backoff      time.Duration
+	maxBackoff   time.Duration
+	pollInterval time.Duration
+	now          func() time.Time
+}
+
+// Option is a function that configures a Dispatcher.
+type Option func(*Dispatcher)
+
+// WithHTTPClient sets the client sending the requests.
+func WithHTTPClient(client *http.Client) Option {
+	return func(d *Dispatcher) {
+		d.client = client
+	}
+}
+
+// WithMaxAttempts sets how many times a delivery is attempted before it fails.
+func WithMaxAttempts(attempts int) Option {
+	return func(d *Dispatcher) {
+		if attempts > 0 {
+			d.maxAttempts = attempts
+		}
+	}
+}
+
+// WithBackoff sets the delay before the first retry, doubled for each next one up to maxBackoff.
+func WithBackoff(backoff, maxBackoff time.Duration) Option {
+	return func(d *Dispatcher) {
+		if backoff > 0 && maxBackoff >= backoff {
+			d.backoff, d.maxBackoff = backoff, maxBackoff
+		}
+	}
+}
+
+// WithPollInterval sets how often the queue is checked for due deliveries.
+func WithPollInterval(interval time.Duration) Option {
+	return func(d *Dispatcher) {
+		if interval > 0 {
+			d.pollInterval = interval
+		}
+	}
+}
+
+// NewDispatcher creates a Dispatcher sending the deliveries queued in store.
+// Unless another client is set, requests are sent with metadata.NewHTTPClient, so webhooks cannot reach
+// the internal network, and redirects are not followed.
+func NewDispatcher(store storage.WebhookStorage, log logger.Logger, opts ...Option) *Dispatcher {
+	d := &Dispatcher{
+		store:        store,
+		log:          log,
+		maxAttempts:  DefaultMaxAttempts,
+		backoff:      DefaultBackoff,
+		maxBackoff:   DefaultMaxBackoff,
+		pollInterval: DefaultPollInterval,
+		now:          time.Now,
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	if d.client == nil {
+		d.client = metadata.NewHTTPClient()
+		d.client.CheckRedirect = func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		}
+	}
+	return d
+}
+
+// Run sends due deliveries every poll interval until ctx is done.
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := d.DeliverDue(ctx); err != nil && ctx.Err() == nil {
+			d.log.Errorf("Failed to deliver webhooks: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// DeliverDue sends the deliveries that are due, in batches sent in parallel, and returns their number.
+func (d *Dispatcher) DeliverDue(ctx context.Context) (int, error) {
+	var sent int
+	for ctx.Err() == nil {
+		now := d.now()
+		deliveries, err := d.store.ClaimDeliveries(ctx, now, now.Add(lease), batchSize)
+		if err != nil {
+			return sent, err
+		}
+
+		var wg sync.WaitGroup
+		errs := make([]error, len(deliveries))
+		for i, delivery := range deliveries {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				errs[i] = d.deliver(ctx, delivery)
+			}()
+		}
+		wg.Wait()
+		sent += len(deliveries)
+		if err = errors.Join(errs...); err != nil {
+			return sent, err
+		}
+		if len(deliveries) < batchSize {
+			break
+		}
+	}
+	return sent, nil
+}
+
+// deliver makes an attempt to send the delivery and records its outcome.
+// An attempt interrupted by ctx is not recorded, so the delivery is sent again once its lease expires.
+func (d *Dispatcher) deliver(ctx context.Context, delivery models.WebhookDelivery) error {
+	hook, err := d.store.GetWebhook(ctx, delivery.WebhookID)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil // the webhook was deleted together with its deliveries
+	}
+	if err != nil {
+		return err
+	}
+
+	status, err := d.send(ctx, hook, delivery)
+	if ctx.Err() != nil {
+		return nil
+	}
+
+	now := d.now().UTC()
+	delivery.Attempts++
+	delivery.ResponseStatus = status
+	switch {
+	case err == nil:
+		delivery.Status = models.DeliveryDelivered
+		delivery.LastError = ""
+		delivery.NextAttemptAt = nil
+		delivery.DeliveredAt = &now
+	case delivery.Attempts >= d.maxAttempts:
+		delivery.Status = models.DeliveryFailed
+		delivery.LastError = err.Error()
+		delivery.NextAttemptAt = nil
+	default:
+		next := now.Add(d.retryDelay(delivery.Attempts))
+		delivery.LastError = err.Error()
+		delivery.NextAttemptAt = &next
+	}
+
+	err = d.store.UpdateDelivery(ctx, delivery)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+// send posts the payload of the delivery to the webhook and returns the response status.
+// Statuses other than 2xx are errors.
+func (d *Dispatcher) send(ctx context.Context, hook models.Webhook, delivery models.WebhookDelivery) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := d.now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "shortener-webhooks")
+	req.Header.Set(EventHeader, delivery.Event)
+	req.Header.Set(DeliveryHeader, delivery.ID)
+	req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(SignatureHeader, Sign(hook.Secret, timestamp, delivery.Payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxResponseSize))
+	_ = resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// retryDelay returns the delay after the given number of failed attempts: the backoff doubled for each
+// attempt after the first, capped at the maximum backoff, with the upper half randomized
+// so that deliveries failed together are not retried together.
+func (d *Dispatcher) retryDelay(attempts int) time.Duration {
+	delay := d.backoff
+	for i := 1; i < attempts && delay < d.maxBackoff; i++ {
+		delay *= 2
+	}
+	delay = min(delay, d.maxBackoff)
+	return delay/2 + rand.N(delay/2+1)
+}
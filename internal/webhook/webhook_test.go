@@ -0,0 +1,203 @@
+package webhook
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/grnsv/shortener/internal/logger"
+	"github.com/grnsv/shortener/internal/models"
+	"github.com/grnsv/shortener/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const secret = "whsec_test"
+
+// newQueue returns a storage with a webhook posting to url and a delivery due now.
+func newQueue(t *testing.T, url string) (*storage.MemoryStorage, models.WebhookDelivery) {
+	t.Helper()
+	store, err := storage.NewMemoryStorage(context.Background())
+	require.NoError(t, err)
+
+	hook := models.Webhook{ID: "hook", UserID: "user", URL: url, Secret: secret, Events: models.AllEvents}
+	require.NoError(t, store.SaveWebhook(context.Background(), hook))
+	now := time.Now().UTC()
+	delivery := models.WebhookDelivery{
+		ID:            "delivery",
+		WebhookID:     hook.ID,
+		Event:         models.EventLinkCreated,
+		Payload:       []byte(`{"id":"delivery","event":"link.created"}`),
+		Status:        models.DeliveryPending,
+		CreatedAt:     now,
+		NextAttemptAt: &now,
+	}
+	require.NoError(t, store.SaveDeliveries(context.Background(), []models.WebhookDelivery{delivery}))
+	return store, delivery
+}
+
+func newDispatcher(t *testing.T, store storage.WebhookStorage, ts *httptest.Server, opts ...Option) *Dispatcher {
+	t.Helper()
+	log, err := logger.New("testing")
+	require.NoError(t, err)
+	return NewDispatcher(store, log, append([]Option{WithHTTPClient(ts.Client())}, opts...)...)
+}
+
+func lastDelivery(t *testing.T, store storage.WebhookStorage) models.WebhookDelivery {
+	t.Helper()
+	deliveries, err := store.GetDeliveries(context.Background(), "hook", 1)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	return deliveries[0]
+}
+
+func TestDeliverSignsRequests(t *testing.T) {
+	received := make(chan *http.Request, 1)
+	var body []byte
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		received <- r
+	}))
+	defer ts.Close()
+	store, delivery := newQueue(t, ts.URL)
+
+	sent, err := newDispatcher(t, store, ts).DeliverDue(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, sent)
+
+	r := <-received
+	assert.Equal(t, http.MethodPost, r.Method)
+	assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+	assert.Equal(t, models.EventLinkCreated, r.Header.Get(EventHeader))
+	assert.Equal(t, delivery.ID, r.Header.Get(DeliveryHeader))
+	assert.JSONEq(t, string(delivery.Payload), string(body))
+	timestamp, err := strconv.ParseInt(r.Header.Get(TimestampHeader), 10, 64)
+	require.NoError(t, err)
+	assert.True(t, Verify(secret, timestamp, body, r.Header.Get(SignatureHeader)))
+	assert.False(t, Verify("another", timestamp, body, r.Header.Get(SignatureHeader)))
+
+	delivered := lastDelivery(t, store)
+	assert.Equal(t, models.DeliveryDelivered, delivered.Status)
+	assert.Equal(t, 1, delivered.Attempts)
+	assert.Equal(t, http.StatusOK, delivered.ResponseStatus)
+	assert.NotNil(t, delivered.DeliveredAt)
+	assert.Nil(t, delivered.NextAttemptAt)
+
+	sent, err = newDispatcher(t, store, ts).DeliverDue(context.Background())
+	require.NoError(t, err)
+	assert.Zero(t, sent, "delivered deliveries are not sent again")
+}
+
+func TestDeliverRetries(t *testing.T) {
+	var attempts atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer ts.Close()
+	store, _ := newQueue(t, ts.URL)
+	d := newDispatcher(t, store, ts, WithBackoff(time.Minute, time.Hour))
+	now := time.Now()
+	d.now = func() time.Time { return now }
+
+	_, err := d.DeliverDue(context.Background())
+	require.NoError(t, err)
+	failed := lastDelivery(t, store)
+	assert.Equal(t, models.DeliveryPending, failed.Status)
+	assert.Equal(t, 1, failed.Attempts)
+	assert.Equal(t, http.StatusServiceUnavailable, failed.ResponseStatus)
+	assert.Contains(t, failed.LastError, "503")
+	require.NotNil(t, failed.NextAttemptAt)
+	assert.WithinRange(t, *failed.NextAttemptAt, now.Add(30*time.Second), now.Add(time.Minute))
+
+	sent, err := d.DeliverDue(context.Background())
+	require.NoError(t, err)
+	assert.Zero(t, sent, "deliveries are not retried before their next attempt")
+
+	now = now.Add(time.Minute)
+	_, err = d.DeliverDue(context.Background())
+	require.NoError(t, err)
+	assert.WithinRange(t, *lastDelivery(t, store).NextAttemptAt, now.Add(time.Minute), now.Add(2*time.Minute),
+		"the backoff doubles")
+
+	now = now.Add(2 * time.Minute)
+	_, err = d.DeliverDue(context.Background())
+	require.NoError(t, err)
+	delivered := lastDelivery(t, store)
+	assert.Equal(t, models.DeliveryDelivered, delivered.Status)
+	assert.Equal(t, 3, delivered.Attempts)
+	assert.Empty(t, delivered.LastError)
+}
+
+func TestDeliverGivesUp(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+	store, _ := newQueue(t, ts.URL)
+	d := newDispatcher(t, store, ts, WithMaxAttempts(2), WithBackoff(time.Millisecond, time.Millisecond))
+
+	for range 2 {
+		time.Sleep(2 * time.Millisecond)
+		_, err := d.DeliverDue(context.Background())
+		require.NoError(t, err)
+	}
+
+	failed := lastDelivery(t, store)
+	assert.Equal(t, models.DeliveryFailed, failed.Status)
+	assert.Equal(t, 2, failed.Attempts)
+	assert.Nil(t, failed.NextAttemptAt)
+}
+
+func TestDeliverSkipsDeletedWebhooks(t *testing.T) {
+	var attempts atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+	}))
+	defer ts.Close()
+	store, delivery := newQueue(t, ts.URL)
+	d := newDispatcher(t, store, ts)
+
+	require.NoError(t, store.DeleteWebhook(context.Background(), "user", "hook"))
+	require.NoError(t, d.deliver(context.Background(), delivery))
+	assert.Zero(t, attempts.Load())
+}
+
+func TestDefaultClientRefusesLoopback(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+	store, _ := newQueue(t, ts.URL)
+	log, err := logger.New("testing")
+	require.NoError(t, err)
+
+	_, err = NewDispatcher(store, log).DeliverDue(context.Background())
+	require.NoError(t, err)
+	failed := lastDelivery(t, store)
+	assert.Equal(t, models.DeliveryPending, failed.Status)
+	assert.Contains(t, failed.LastError, "not publicly routable")
+}
+
+func TestRetryDelay(t *testing.T) {
+	d := &Dispatcher{backoff: time.Second, maxBackoff: time.Minute}
+	tests := []struct {
+		attempts int
+		max      time.Duration
+	}{
+		{attempts: 1, max: time.Second},
+		{attempts: 2, max: 2 * time.Second},
+		{attempts: 4, max: 8 * time.Second},
+		{attempts: 7, max: time.Minute},
+		{attempts: 100, max: time.Minute},
+	}
+	for _, tt := range tests {
+		delay := d.retryDelay(tt.attempts)
+		assert.GreaterOrEqual(t, delay, tt.max/2, tt.attempts)
+		assert.LessOrEqual(t, delay, tt.max, tt.attempts)
+	}
+}
@@ -0,0 +1,208 @@
+// Package client is a Go client of the URL shortener.
+//
+// Client is implemented over HTTP by NewHTTP and over gRPC by NewGRPC, so callers can switch transports
+// without changing their code. Both authenticate with an API key if one is given, and otherwise with the token
+// the server issues on the first call, which a TokenStore keeps for the next calls, so they act on behalf
+// of the same user. Calls failing with a server error or a network error are retried with exponential backoff.
+//
+// Errors reported by the server are *Error values with the HTTP status and the stable problem code,
+// which the gRPC implementation derives from the gRPC status.
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// Client calls the URL shortener.
+type Client interface {
+	// Shorten shortens a URL and reports whether it was already shortened.
+	// An already shortened URL may belong to another user, so only its ID, short and original URLs are known.
+	Shorten(ctx context.Context, req ShortenRequest) (link *Link, alreadyExists bool, err error)
+	// ShortenBatch shortens URLs and reports the status of every item. Batches are sent with an idempotency key,
+	// so retrying them never shortens a URL twice.
+	ShortenBatch(ctx context.Context, items []BatchItem) ([]BatchResult, error)
+	// Expand returns where a link leads without following it.
+	// Password-protected links are expanded only with the password.
+	Expand(ctx context.Context, id string, password string) (*Expansion, error)
+	// List lists the links of the user having all of the tags.
+	List(ctx context.Context, tags ...string) ([]Link, error)
+	// Delete deletes links of the user by their IDs. The server deletes them asynchronously.
+	Delete(ctx context.Context, ids ...string) error
+	// Stats returns statistics of the service. The HTTP API serves them only to its trusted subnet.
+	Stats(ctx context.Context) (*Stats, error)
+	// Close releases the connections of the client.
+	Close() error
+}
+
+// Link is a short URL.
+type Link struct {
+	ID           string     `json:"id"` // the short code, prefixed with the domain and ':' on custom domains
+	ShortURL     string     `json:"short_url"`
+	Domain       string     `json:"domain,omitempty"` // the custom domain of the link, empty on the default one
+	OriginalURL  string     `json:"original_url"`
+	RedirectCode int        `json:"redirect_code,omitempty"` // the server default if zero
+	Interstitial bool       `json:"interstitial"`
+	Metadata     Metadata   `json:"metadata"`
+	CreatedAt    time.Time  `json:"created_at,omitzero"` // zero if unknown; the gRPC API does not report it
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
+}
+
+// Metadata helps users tell their links apart.
+type Metadata struct {
+	Title       string   `json:"title,omitempty"`
+	Description string   `json:"description,omitempty"`
+	Notes       string   `json:"notes,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+}
+
+// ShortenRequest describes a URL to shorten.
+type ShortenRequest struct {
+	URL          string   `json:"url"`
+	Password     string   `json:"password,omitempty"`      // passphrase required to follow the link
+	Interstitial bool     `json:"interstitial,omitempty"`  // show a preview page instead of redirecting
+	RedirectCode int      `json:"redirect_code,omitempty"` // 301, 302, 307 or 308; the server default if zero
+	Metadata     Metadata `json:"metadata,omitzero"`
+	Domain       string   `json:"domain,omitempty"` // a verified custom domain of the user; not supported by the gRPC API
+}
+
+// BatchItem is a URL of a batch, identified by a correlation ID of the caller.
+type BatchItem struct {
+	CorrelationID string `json:"correlation_id"`
+	URL           string `json:"original_url"`
+}
+
+// Statuses of batch results.
+const (
+	BatchCreated = "created" // the link was created
+	BatchExists  = "exists"  // the URL was already shortened
+	BatchInvalid = "invalid" // the URL is not a valid HTTP(S) URL
+)
+
+// BatchResult is the result of shortening a BatchItem. Invalid items have no link and an error explaining why.
+type BatchResult struct {
+	CorrelationID string `json:"correlation_id"`
+	ID            string `json:"id,omitempty"`
+	ShortURL      string `json:"short_url,omitempty"`
+	Status        string `json:"status"`
+	Error         string `json:"error,omitempty"`
+}
+
+// Expansion describes where a short link leads.
+type Expansion struct {
+	URL          string `json:"url"`
+	RedirectCode int    `json:"redirect_code,omitempty"` // the server default if zero or unknown
+	Interstitial bool   `json:"interstitial,omitempty"`  // browsers are shown a preview page first
+}
+
+// Stats are statistics of the service.
+type Stats struct {
+	URLs  int `json:"urls"`
+	Users int `json:"users"`
+}
+
+// Error is an error reported by the server.
+type Error struct {
+	StatusCode int    // HTTP status, or the one corresponding to the gRPC status
+	Code       string // stable problem code, e.g. not_found or invalid_url
+	Detail     string
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("shortener responded with %d", e.StatusCode)
+	if e.Code != "" {
+		msg += " " + e.Code
+	}
+	if e.Detail != "" {
+		msg += ": " + e.Detail
+	}
+	return msg
+}
+
+// ErrPageServed means the HTTP API answered a short link with a page instead of a redirect:
+// the link has a preview page or needs a password. The gRPC API expands such links.
+var ErrPageServed = errors.New("the link shows a page instead of redirecting: it needs a password or has a preview page")
+
+// ErrDomainUnsupported means a link was to be shortened on a custom domain with the gRPC API,
+// which shortens links on the default domain only.
+var ErrDomainUnsupported = errors.New("the gRPC API does not support custom domains")
+
+// Option configures a Client.
+type Option func(*options)
+
+type options struct {
+	apiKey      string
+	workspace   string
+	tokens      TokenStore
+	httpClient  *http.Client
+	retries     int
+	backoff     time.Duration
+	maxBackoff  time.Duration
+	dialOptions []grpc.DialOption
+}
+
+func newOptions(opts []Option) options {
+	o := options{
+		tokens:     NewMemoryTokenStore(),
+		httpClient: http.DefaultClient,
+		retries:    3,
+		backoff:    100 * time.Millisecond,
+		maxBackoff: 5 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithAPIKey authenticates calls with the API key instead of a token.
+func WithAPIKey(key string) Option {
+	return func(o *options) {
+		o.apiKey = key
+	}
+}
+
+// WithWorkspace makes calls act on the links of the workspace with the ID instead of the user's own,
+// as far as the role of the user in the workspace allows.
+func WithWorkspace(id string) Option {
+	return func(o *options) {
+		o.workspace = id
+	}
+}
+
+// WithTokenStore keeps the authentication token in the store, e.g. a FileTokenStore to share it between runs.
+// Tokens are kept in memory by default.
+func WithTokenStore(store TokenStore) Option {
+	return func(o *options) {
+		o.tokens = store
+	}
+}
+
+// WithHTTPClient makes the HTTP implementation send requests with a copy of c, which never follows redirects.
+func WithHTTPClient(c *http.Client) Option {
+	return func(o *options) {
+		o.httpClient = c
+	}
+}
+
+// WithRetries sets how many times failed calls are retried, 3 by default, and the delay before the first retry,
+// 100ms by default, which doubles with every retry up to 5s. Zero retries disable retrying.
+func WithRetries(retries int, backoff time.Duration) Option {
+	return func(o *options) {
+		o.retries = retries
+		o.backoff = backoff
+	}
+}
+
+// WithDialOptions sets the options the gRPC implementation connects with.
+// Connections are not encrypted by default, as the server serves gRPC without TLS.
+func WithDialOptions(opts ...grpc.DialOption) Option {
+	return func(o *options) {
+		o.dialOptions = opts
+	}
+}
@@ -0,0 +1,298 @@
+package client_test
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/grnsv/shortener/internal/api"
+	"github.com/grnsv/shortener/internal/api/middleware"
+	"github.com/grnsv/shortener/internal/api/pb"
+	"github.com/grnsv/shortener/internal/config"
+	"github.com/grnsv/shortener/internal/logger"
+	"github.com/grnsv/shortener/internal/mocks"
+	"github.com/grnsv/shortener/internal/models"
+	"github.com/grnsv/shortener/internal/service"
+	"github.com/grnsv/shortener/internal/storage"
+	"github.com/grnsv/shortener/pkg/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+var signer = middleware.NewHMACSigner("secret")
+
+// newClients starts the HTTP and gRPC APIs over the mock and returns a client of each.
+func newClients(t *testing.T, mock *mocks.MockShortener, opts ...client.Option) map[string]client.Client {
+	t.Helper()
+	log, err := logger.New("testing")
+	require.NoError(t, err)
+
+	cfg := config.New()
+	ts := httptest.NewServer(api.NewRouter(api.NewURLHandler(mock, cfg, log), cfg, signer, log))
+	t.Cleanup(ts.Close)
+	httpClient, err := client.NewHTTP(ts.URL, opts...)
+	require.NoError(t, err)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	server := grpc.NewServer(grpc.ChainUnaryInterceptor(
+		middleware.GRPCAuthenticateInterceptor(signer, mock, log),
+		middleware.GRPCRequestInfoInterceptor(),
+		middleware.GRPCWorkspaceInterceptor(),
+	))
+	pb.RegisterShortenerServer(server, pb.NewGRPCShortenerServer(mock, log))
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(server.Stop)
+	grpcClient, err := client.NewGRPC(listener.Addr().String(), opts...)
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		_ = httpClient.Close()
+		_ = grpcClient.Close()
+	})
+	return map[string]client.Client{"http": httpClient, "grpc": grpcClient}
+}
+
+func TestClients(t *testing.T) {
+	baseURL := config.New().BaseURL.String()
+
+	for _, transport := range []string{"http", "grpc"} {
+		t.Run(transport, func(t *testing.T) {
+			ctx := context.Background()
+			ctrl := gomock.NewController(t)
+			mock := mocks.NewMockShortener(ctrl)
+			c := newClients(t, mock)[transport]
+
+			var userID string
+			shortened := func(_ context.Context, _ string, id string, _ ...service.ShortenOption) {
+				userID = id
+			}
+			mock.EXPECT().Shorten(gomock.Any(), "http://example.com", gomock.Any(), gomock.Any()).
+				Do(shortened).Return(&models.URL{ShortURL: baseURL + "/abc", OriginalURL: "http://example.com"}, false, nil).AnyTimes()
+			mock.EXPECT().ShortenURL(gomock.Any(), "http://example.com", gomock.Any(), gomock.Any()).
+				Do(shortened).Return(baseURL+"/abc", false, nil).AnyTimes()
+
+			link, alreadyExists, err := c.Shorten(ctx, client.ShortenRequest{URL: "http://example.com"})
+			require.NoError(t, err)
+			assert.False(t, alreadyExists)
+			assert.Equal(t, "abc", link.ID)
+			assert.Equal(t, baseURL+"/abc", link.ShortURL)
+			require.NotEmpty(t, userID)
+
+			// The token issued on the first call authenticates the next ones as the same user.
+			mock.EXPECT().GetAll(gomock.Any(), userID, models.URLFilter{Tags: []string{"work"}}).
+				Return([]models.URL{{ShortURL: baseURL + "/abc", OriginalURL: "http://example.com"}}, nil)
+			links, err := c.List(ctx, "work")
+			require.NoError(t, err)
+			require.Len(t, links, 1)
+			assert.Equal(t, "abc", links[0].ID)
+
+			mock.EXPECT().ShortenBatch(gomock.Any(), models.BatchRequest{{CorrelationID: "1", OriginalURL: "http://example.com/1"}}, userID, gomock.Not(""), gomock.Any()).
+				Return(models.BatchResponse{{CorrelationID: "1", ShortURL: baseURL + "/one", Status: models.BatchCreated}}, nil)
+			results, err := c.ShortenBatch(ctx, []client.BatchItem{{CorrelationID: "1", URL: "http://example.com/1"}})
+			require.NoError(t, err)
+			assert.Equal(t, []client.BatchResult{{CorrelationID: "1", ID: "one", ShortURL: baseURL + "/one", Status: client.BatchCreated}}, results)
+
+			deleted := make(chan struct{})
+			mock.EXPECT().LinkOwner(gomock.Any(), userID, models.ScopeDelete).Return(userID, nil).AnyTimes()
+			mock.EXPECT().DeleteMany(gomock.Any(), userID, []string{"abc"}).
+				DoAndReturn(func(context.Context, string, []string) error {
+					close(deleted)
+					return nil
+				})
+			require.NoError(t, c.Delete(ctx, "abc"))
+			select {
+			case <-deleted:
+			case <-time.After(time.Second):
+				t.Fatal("links were not deleted")
+			}
+
+			mock.EXPECT().ExpandURL(gomock.Any(), "abc").Return("http://example.com", http.StatusMovedPermanently, nil)
+			exp, err := c.Expand(ctx, "abc", "")
+			require.NoError(t, err)
+			assert.Equal(t, &client.Expansion{URL: "http://example.com", RedirectCode: http.StatusMovedPermanently}, exp)
+
+			mock.EXPECT().UnlockURL(gomock.Any(), "locked", "wrong", gomock.Any()).Return("", "", service.ErrInvalidPassword)
+			_, err = c.Expand(ctx, "locked", "wrong")
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestShortenExisting(t *testing.T) {
+	baseURL := config.New().BaseURL.String()
+
+	for _, transport := range []string{"http", "grpc"} {
+		t.Run(transport, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			mock := mocks.NewMockShortener(ctrl)
+			c := newClients(t, mock)[transport]
+
+			mock.EXPECT().Shorten(gomock.Any(), "http://example.com", gomock.Any(), gomock.Any()).
+				Return(&models.URL{ShortURL: baseURL + "/abc", OriginalURL: "http://example.com"}, true, nil).AnyTimes()
+			mock.EXPECT().ShortenURL(gomock.Any(), "http://example.com", gomock.Any(), gomock.Any()).
+				Return(baseURL+"/abc", true, nil).AnyTimes()
+			mock.EXPECT().ShortenBatch(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+				Return(models.BatchResponse{{CorrelationID: "1", ShortURL: baseURL + "/abc", Status: models.BatchExists}}, nil).AnyTimes()
+
+			link, alreadyExists, err := c.Shorten(context.Background(), client.ShortenRequest{URL: "http://example.com"})
+			require.NoError(t, err)
+			assert.True(t, alreadyExists)
+			assert.Equal(t, "abc", link.ID)
+		})
+	}
+}
+
+func TestErrors(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mock := mocks.NewMockShortener(ctrl)
+	clients := newClients(t, mock)
+
+	mock.EXPECT().ExpandURL(gomock.Any(), "gone").Return("", 0, storage.ErrNotFound)
+	_, err := clients["http"].Expand(context.Background(), "gone", "")
+	var apiErr *client.Error
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+	assert.Equal(t, "not_found", apiErr.Code)
+
+	_, err = clients["grpc"].Expand(context.Background(), "", "")
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusBadRequest, apiErr.StatusCode)
+	assert.Equal(t, "bad_request", apiErr.Code)
+
+	mock.EXPECT().ExpandURL(gomock.Any(), "preview").Return("", 0, service.ErrInterstitial)
+	mock.EXPECT().PreviewURL(gomock.Any(), "preview").Return(&models.Preview{ShortURL: "preview", OriginalURL: "http://example.com"}, nil)
+	_, err = clients["http"].Expand(context.Background(), "preview", "")
+	assert.ErrorIs(t, err, client.ErrPageServed)
+
+	_, err = clients["http"].Stats(context.Background())
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusForbidden, apiErr.StatusCode)
+}
+
+func TestWorkspace(t *testing.T) {
+	const workspaceID = "11111111-1111-1111-1111-111111111111"
+	store, err := storage.NewMemoryStorage(context.Background())
+	require.NoError(t, err)
+	workspaces := service.NewShortener(store, store, store, store, "http://short", service.WithWorkspaces(store))
+
+	for _, transport := range []string{"http", "grpc"} {
+		t.Run(transport, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			mock := mocks.NewMockShortener(ctrl)
+			c := newClients(t, mock, client.WithWorkspace(workspaceID))[transport]
+
+			// The service denies the workspace the user is not a member of, so the call must carry it.
+			mock.EXPECT().GetAll(gomock.Any(), gomock.Any(), gomock.Any()).
+				DoAndReturn(func(ctx context.Context, userID string, _ models.URLFilter) ([]models.URL, error) {
+					_, err := workspaces.LinkOwner(ctx, userID, models.ScopeRead)
+					return nil, err
+				})
+			_, err := c.List(context.Background())
+			var apiErr *client.Error
+			require.ErrorAs(t, err, &apiErr)
+			assert.Equal(t, http.StatusForbidden, apiErr.StatusCode)
+		})
+	}
+}
+
+func TestRetries(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int
+		retries  int
+		wantErr  bool
+		attempts int32
+	}{
+		{name: "server errors are retried", statuses: []int{http.StatusServiceUnavailable, http.StatusInternalServerError, http.StatusOK}, retries: 3, attempts: 3},
+		{name: "retries run out", statuses: []int{http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway}, retries: 2, wantErr: true, attempts: 3},
+		{name: "client errors are not retried", statuses: []int{http.StatusBadRequest, http.StatusOK}, retries: 3, wantErr: true, attempts: 1},
+		{name: "unsupported features are not retried", statuses: []int{http.StatusNotImplemented, http.StatusOK}, retries: 3, wantErr: true, attempts: 1},
+		{name: "retrying can be disabled", statuses: []int{http.StatusServiceUnavailable, http.StatusOK}, retries: 0, wantErr: true, attempts: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts atomic.Int32
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				status := tt.statuses[attempts.Add(1)-1]
+				if status != http.StatusOK {
+					w.WriteHeader(status)
+					return
+				}
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = w.Write([]byte(`{"urls":1,"users":2}`))
+			}))
+			defer ts.Close()
+
+			c, err := client.NewHTTP(ts.URL, client.WithRetries(tt.retries, time.Millisecond))
+			require.NoError(t, err)
+			stats, err := c.Stats(context.Background())
+
+			assert.Equal(t, tt.attempts, attempts.Load())
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, &client.Stats{URLs: 1, Users: 2}, stats)
+		})
+	}
+}
+
+func TestRetriesStopWithContext(t *testing.T) {
+	var attempts atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		attempts.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	c, err := client.NewHTTP(ts.URL, client.WithRetries(5, time.Hour))
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = c.Stats(ctx)
+
+	var apiErr *client.Error
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusServiceUnavailable, apiErr.StatusCode)
+	assert.Equal(t, int32(1), attempts.Load())
+}
+
+func TestNewHTTP(t *testing.T) {
+	for _, baseURL := range []string{"", "localhost:8080", "ftp://example.com", "http://"} {
+		_, err := client.NewHTTP(baseURL)
+		assert.Error(t, err, baseURL)
+	}
+}
+
+func TestTokenStores(t *testing.T) {
+	stores := map[string]client.TokenStore{
+		"memory": client.NewMemoryTokenStore(),
+		"file":   client.FileTokenStore{Path: filepath.Join(t.TempDir(), "nested", "token")},
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			token, err := store.Load()
+			require.NoError(t, err)
+			assert.Empty(t, token)
+
+			require.NoError(t, store.Save("abc"))
+			token, err = store.Load()
+			require.NoError(t, err)
+			assert.Equal(t, "abc", token)
+		})
+	}
+
+	info, err := os.Stat(stores["file"].(client.FileTokenStore).Path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+}
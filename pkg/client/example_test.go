@@ -0,0 +1,46 @@
+package client_test
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/grnsv/shortener/pkg/client"
+)
+
+func ExampleNewHTTP() {
+	c, err := client.NewHTTP("http://localhost:8080",
+		client.WithTokenStore(client.FileTokenStore{Path: filepath.Join(os.TempDir(), "shortener-token")}),
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer func() { _ = c.Close() }()
+
+	link, _, err := c.Shorten(context.Background(), client.ShortenRequest{
+		URL:      "https://example.com/a/very/long/path",
+		Metadata: client.Metadata{Title: "Example", Tags: []string{"docs"}},
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(link.ShortURL)
+}
+
+func ExampleNewGRPC() {
+	c, err := client.NewGRPC("localhost:3200", client.WithAPIKey(os.Getenv("SHORTENER_API_KEY")))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer func() { _ = c.Close() }()
+
+	links, err := c.List(context.Background(), "docs")
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, link := range links {
+		fmt.Println(link.ID, link.OriginalURL)
+	}
+}
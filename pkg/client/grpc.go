@@ -0,0 +1,259 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+
+	"github.com/google/uuid"
+	"github.com/grnsv/shortener/internal/api/pb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// GRPCClient calls the gRPC API.
+type GRPCClient struct {
+	conn   *grpc.ClientConn
+	client pb.ShortenerClient
+	opts   options
+}
+
+var _ Client = (*GRPCClient)(nil)
+
+// NewGRPC returns a Client calling the gRPC API at addr, e.g. localhost:3200.
+// It connects lazily, on the first call.
+func NewGRPC(addr string, opts ...Option) (*GRPCClient, error) {
+	o := newOptions(opts)
+	dialOptions := o.dialOptions
+	if len(dialOptions) == 0 {
+		dialOptions = []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}
+	}
+	conn, err := grpc.NewClient(addr, dialOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("invalid gRPC address %q: %w", addr, err)
+	}
+	return &GRPCClient{conn: conn, client: pb.NewShortenerClient(conn), opts: o}, nil
+}
+
+// Shorten shortens a URL with ShortenURL. The method reports an already shortened URL without its short URL,
+// which is then looked up with ShortenBatch, as the short URL of a plain link is the same for every user.
+func (c *GRPCClient) Shorten(ctx context.Context, req ShortenRequest) (*Link, bool, error) {
+	if req.Domain != "" {
+		return nil, false, ErrDomainUnsupported
+	}
+	var resp *pb.ShortenResponse
+	err := c.call(ctx, func(ctx context.Context, opts ...grpc.CallOption) (err error) {
+		resp, err = c.client.ShortenURL(ctx, &pb.ShortenRequest{
+			Url:          req.URL,
+			Password:     req.Password,
+			Interstitial: req.Interstitial,
+			RedirectCode: int32(req.RedirectCode),
+			Title:        req.Metadata.Title,
+			Description:  req.Metadata.Description,
+			Notes:        req.Metadata.Notes,
+			Tags:         req.Metadata.Tags,
+		}, opts...)
+		return err
+	})
+	var apiErr *Error
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusConflict {
+		results, batchErr := c.ShortenBatch(ctx, []BatchItem{{CorrelationID: "1", URL: req.URL}})
+		if batchErr != nil {
+			return nil, false, batchErr
+		}
+		return &Link{ID: results[0].ID, ShortURL: results[0].ShortURL, OriginalURL: req.URL}, true, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return &Link{
+		ID:           linkID(resp.Result),
+		ShortURL:     resp.Result,
+		OriginalURL:  req.URL,
+		RedirectCode: req.RedirectCode,
+		Interstitial: req.Interstitial,
+		Metadata:     req.Metadata,
+	}, false, nil
+}
+
+// ShortenBatch shortens URLs with ShortenBatch.
+func (c *GRPCClient) ShortenBatch(ctx context.Context, items []BatchItem) ([]BatchResult, error) {
+	in := &pb.BatchRequest{Items: make([]*pb.BatchRequestItem, len(items))}
+	for i, item := range items {
+		in.Items[i] = &pb.BatchRequestItem{CorrelationId: item.CorrelationID, OriginalUrl: item.URL}
+	}
+	ctx = metadata.AppendToOutgoingContext(ctx, "idempotency-key", uuid.NewString())
+	var resp *pb.BatchResponse
+	err := c.call(ctx, func(ctx context.Context, opts ...grpc.CallOption) (err error) {
+		resp, err = c.client.ShortenBatch(ctx, in, opts...)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]BatchResult, len(resp.Items))
+	for i, item := range resp.Items {
+		results[i] = BatchResult{
+			CorrelationID: item.CorrelationId,
+			ShortURL:      item.ShortUrl,
+			Status:        item.Status,
+			Error:         item.Error,
+		}
+		if item.ShortUrl != "" {
+			results[i].ID = linkID(item.ShortUrl)
+		}
+	}
+	return results, nil
+}
+
+// Expand returns where a link leads with ExpandURL, which also expands links with a preview page.
+func (c *GRPCClient) Expand(ctx context.Context, id string, password string) (*Expansion, error) {
+	var resp *pb.ExpandResponse
+	err := c.call(ctx, func(ctx context.Context, opts ...grpc.CallOption) (err error) {
+		resp, err = c.client.ExpandURL(ctx, &pb.ExpandRequest{Id: id, Password: password}, opts...)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Expansion{URL: resp.Url, RedirectCode: int(resp.RedirectCode), Interstitial: resp.Interstitial}, nil
+}
+
+// List lists the links of the user with GetURLs.
+func (c *GRPCClient) List(ctx context.Context, tags ...string) ([]Link, error) {
+	var resp *pb.GetURLsResponse
+	err := c.call(ctx, func(ctx context.Context, opts ...grpc.CallOption) (err error) {
+		resp, err = c.client.GetURLs(ctx, &pb.GetURLsRequest{Tags: tags}, opts...)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	links := make([]Link, len(resp.Urls))
+	for i, item := range resp.Urls {
+		links[i] = Link{
+			ID:           linkID(item.ShortUrl),
+			ShortURL:     item.ShortUrl,
+			OriginalURL:  item.OriginalUrl,
+			RedirectCode: int(item.RedirectCode),
+			Metadata: Metadata{
+				Title:       item.Title,
+				Description: item.Description,
+				Notes:       item.Notes,
+				Tags:        item.Tags,
+			},
+		}
+	}
+	return links, nil
+}
+
+// Delete deletes links with DeleteURLs.
+func (c *GRPCClient) Delete(ctx context.Context, ids ...string) error {
+	return c.call(ctx, func(ctx context.Context, opts ...grpc.CallOption) error {
+		_, err := c.client.DeleteURLs(ctx, &pb.DeleteURLsRequest{ShortUrls: ids}, opts...)
+		return err
+	})
+}
+
+// Stats returns statistics of the service with GetStats.
+func (c *GRPCClient) Stats(ctx context.Context) (*Stats, error) {
+	var resp *pb.StatsResponse
+	err := c.call(ctx, func(ctx context.Context, opts ...grpc.CallOption) (err error) {
+		resp, err = c.client.GetStats(ctx, &pb.Empty{}, opts...)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Stats{URLs: int(resp.Urls), Users: int(resp.Users)}, nil
+}
+
+// Close closes the connection.
+func (c *GRPCClient) Close() error {
+	return c.conn.Close()
+}
+
+// call authenticates and retries the call made by fn, saving the token the server issues in the response header.
+// Failed calls are reported as *Error.
+func (c *GRPCClient) call(ctx context.Context, fn func(ctx context.Context, opts ...grpc.CallOption) error) error {
+	if c.opts.workspace != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "workspace-id", c.opts.workspace)
+	}
+	return c.opts.retry(ctx, func() error {
+		if c.opts.apiKey != "" {
+			return fromStatus(fn(metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+c.opts.apiKey)))
+		}
+
+		token, err := c.opts.tokens.Load()
+		if err != nil {
+			return fmt.Errorf("failed to load token: %w", err)
+		}
+		callCtx := ctx
+		if token != "" {
+			callCtx = metadata.AppendToOutgoingContext(ctx, tokenCookieName, token)
+		}
+		var header metadata.MD
+		if err = fn(callCtx, grpc.Header(&header)); err != nil {
+			return fromStatus(err)
+		}
+		if values := header.Get(tokenCookieName); len(values) > 0 && values[0] != "" && values[0] != token {
+			if err = c.opts.tokens.Save(values[0]); err != nil {
+				return fmt.Errorf("failed to save token: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// grpcErrors maps gRPC status codes to the HTTP statuses and problem codes the HTTP API reports.
+var grpcErrors = map[codes.Code]struct {
+	status int
+	code   string
+}{
+	codes.InvalidArgument:    {http.StatusBadRequest, "bad_request"},
+	codes.Unauthenticated:    {http.StatusUnauthorized, "unauthorized"},
+	codes.PermissionDenied:   {http.StatusForbidden, "forbidden"},
+	codes.NotFound:           {http.StatusNotFound, "not_found"},
+	codes.AlreadyExists:      {http.StatusConflict, "conflict"},
+	codes.FailedPrecondition: {http.StatusUnprocessableEntity, "idempotency_key_reused"},
+	codes.ResourceExhausted:  {http.StatusTooManyRequests, "too_many_requests"},
+	codes.Unimplemented:      {http.StatusNotImplemented, "not_implemented"},
+	codes.Unavailable:        {http.StatusServiceUnavailable, "unavailable"},
+}
+
+// fromStatus converts a gRPC status error to *Error. Context errors are returned as they are.
+func fromStatus(err error) error {
+	if err == nil {
+		return nil
+	}
+	s, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+	switch s.Code() {
+	case codes.Canceled:
+		return context.Canceled
+	case codes.DeadlineExceeded:
+		return context.DeadlineExceeded
+	}
+	if e, known := grpcErrors[s.Code()]; known {
+		return &Error{StatusCode: e.status, Code: e.code, Detail: s.Message()}
+	}
+	return &Error{StatusCode: http.StatusInternalServerError, Code: "internal_error", Detail: s.Message()}
+}
+
+// linkID returns the ID of a link, the last path segment of its short URL.
+func linkID(shortURL string) string {
+	if u, err := url.Parse(shortURL); err == nil {
+		return path.Base(u.Path)
+	}
+	return path.Base(shortURL)
+}
@@ -0,0 +1,241 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// tokenCookieName is the cookie the HTTP API issues and reads the authentication token from.
+const tokenCookieName = "token"
+
+// HTTPClient calls version 2 of the HTTP API.
+type HTTPClient struct {
+	baseURL string
+	client  *http.Client
+	opts    options
+}
+
+var _ Client = (*HTTPClient)(nil)
+
+// NewHTTP returns a Client calling the HTTP API at baseURL, e.g. http://localhost:8080.
+func NewHTTP(baseURL string, opts ...Option) (*HTTPClient, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid server URL %q", baseURL)
+	}
+
+	o := newOptions(opts)
+	c := *o.httpClient
+	// Short links are expanded by reading their redirects, not by following them.
+	c.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	return &HTTPClient{baseURL: strings.TrimSuffix(baseURL, "/"), client: &c, opts: o}, nil
+}
+
+// Shorten shortens a URL with POST /api/v2/links.
+func (c *HTTPClient) Shorten(ctx context.Context, req ShortenRequest) (*Link, bool, error) {
+	var link Link
+	status, err := c.do(ctx, http.MethodPost, "/api/v2/links", nil, req, &link)
+	if err != nil {
+		return nil, false, err
+	}
+	return &link, status == http.StatusOK, nil
+}
+
+// ShortenBatch shortens URLs with POST /api/v2/links/batch.
+func (c *HTTPClient) ShortenBatch(ctx context.Context, items []BatchItem) ([]BatchResult, error) {
+	var resp struct {
+		Items []BatchResult `json:"items"`
+	}
+	header := http.Header{"Idempotency-Key": {uuid.NewString()}}
+	if _, err := c.do(ctx, http.MethodPost, "/api/v2/links/batch", header, items, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Items, nil
+}
+
+// Expand reads the redirect of GET /{id}, or of the unlock form POST /{id} if a password is given.
+// Links with a preview page cannot be expanded over HTTP and yield ErrPageServed.
+func (c *HTTPClient) Expand(ctx context.Context, id string, password string) (*Expansion, error) {
+	var exp *Expansion
+	err := c.opts.retry(ctx, func() error {
+		method, body := http.MethodGet, io.Reader(nil)
+		if password != "" {
+			method, body = http.MethodPost, strings.NewReader(url.Values{"password": {password}}.Encode())
+		}
+		req, err := http.NewRequestWithContext(ctx, method, c.baseURL+"/"+url.PathEscape(id), body)
+		if err != nil {
+			return err
+		}
+		if body != nil {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+		resp, err := c.send(req)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = resp.Body.Close() }()
+
+		switch {
+		case resp.StatusCode >= 300 && resp.StatusCode < 400:
+			exp = &Expansion{URL: resp.Header.Get("Location")}
+			if password == "" {
+				exp.RedirectCode = resp.StatusCode
+			}
+			return nil
+		case resp.StatusCode < 300:
+			return ErrPageServed
+		default:
+			return readError(resp)
+		}
+	})
+	return exp, err
+}
+
+// List lists the links of the user with GET /api/v2/links.
+func (c *HTTPClient) List(ctx context.Context, tags ...string) ([]Link, error) {
+	path := "/api/v2/links"
+	if len(tags) > 0 {
+		path += "?" + url.Values{"tag": tags}.Encode()
+	}
+	var list struct {
+		Links []Link `json:"links"`
+	}
+	if _, err := c.do(ctx, http.MethodGet, path, nil, nil, &list); err != nil {
+		return nil, err
+	}
+	return list.Links, nil
+}
+
+// Delete deletes links with DELETE /api/v2/links.
+func (c *HTTPClient) Delete(ctx context.Context, ids ...string) error {
+	_, err := c.do(ctx, http.MethodDelete, "/api/v2/links", nil, struct {
+		IDs []string `json:"ids"`
+	}{IDs: ids}, nil)
+	return err
+}
+
+// Stats returns statistics of the service with GET /api/internal/stats.
+func (c *HTTPClient) Stats(ctx context.Context) (*Stats, error) {
+	var stats Stats
+	if _, err := c.do(ctx, http.MethodGet, "/api/internal/stats", nil, nil, &stats); err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+// Close closes idle connections.
+func (c *HTTPClient) Close() error {
+	c.client.CloseIdleConnections()
+	return nil
+}
+
+// do sends in as JSON, unless nil, with the header and decodes a successful JSON response into out, unless nil.
+// It returns the status code of the successful response.
+func (c *HTTPClient) do(ctx context.Context, method, path string, header http.Header, in any, out any) (int, error) {
+	var data []byte
+	if in != nil {
+		var err error
+		if data, err = json.Marshal(in); err != nil {
+			return 0, err
+		}
+	}
+
+	var status int
+	err := c.opts.retry(ctx, func() error {
+		var body io.Reader
+		if in != nil {
+			body = bytes.NewReader(data)
+		}
+		req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+		if err != nil {
+			return err
+		}
+		for key, values := range header {
+			req.Header[key] = values
+		}
+		if in != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		req.Header.Set("Accept", "application/json")
+
+		resp, err := c.send(req)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = resp.Body.Close() }()
+
+		if resp.StatusCode >= 300 {
+			return readError(resp)
+		}
+		status = resp.StatusCode
+		if out != nil && resp.StatusCode != http.StatusNoContent {
+			if err = json.NewDecoder(resp.Body).Decode(out); err != nil {
+				return fmt.Errorf("failed to decode response: %w", err)
+			}
+		}
+		return nil
+	})
+	return status, err
+}
+
+// send authenticates and sends req, saving the token the server issues.
+func (c *HTTPClient) send(req *http.Request) (*http.Response, error) {
+	if c.opts.workspace != "" {
+		req.Header.Set("X-Workspace-ID", c.opts.workspace)
+	}
+	if c.opts.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.opts.apiKey)
+		return c.client.Do(req)
+	}
+
+	token, err := c.opts.tokens.Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load token: %w", err)
+	}
+	if token != "" {
+		req.AddCookie(&http.Cookie{Name: tokenCookieName, Value: token})
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	for _, cookie := range resp.Cookies() {
+		if cookie.Name == tokenCookieName && cookie.Value != "" && cookie.Value != token {
+			if err = c.opts.tokens.Save(cookie.Value); err != nil {
+				_ = resp.Body.Close()
+				return nil, fmt.Errorf("failed to save token: %w", err)
+			}
+		}
+	}
+	return resp, nil
+}
+
+// readError reads the problem details of a failed response.
+func readError(resp *http.Response) error {
+	apiErr := &Error{StatusCode: resp.StatusCode}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != "application/problem+json" {
+		return apiErr
+	}
+	var p struct {
+		Code   string `json:"code"`
+		Detail string `json:"detail"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&p); err != nil {
+		return errors.Join(apiErr, fmt.Errorf("failed to decode problem: %w", err))
+	}
+	apiErr.Code, apiErr.Detail = p.Code, p.Detail
+	return apiErr
+}
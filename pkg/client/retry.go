@@ -0,0 +1,47 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"time"
+)
+
+// retry calls fn until it succeeds, fails with an error that is not worth retrying, or the retries run out.
+// Retries are delayed by exponential backoff with full jitter and stop early when ctx is done.
+func (o *options) retry(ctx context.Context, fn func() error) error {
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= o.retries || !retryable(err) {
+			return err
+		}
+
+		delay := min(o.backoff<<attempt, o.maxBackoff)
+		if delay > 0 {
+			delay = rand.N(delay) + 1
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// retryable reports whether a call failing with err may succeed when retried:
+// the server failed, except for unsupported features, or the network did.
+func retryable(err error) bool {
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode >= http.StatusInternalServerError && apiErr.StatusCode != http.StatusNotImplemented
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
@@ -0,0 +1,67 @@
+package client
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// TokenStore keeps the authentication token the server issues. Load returns an empty token if none is stored.
+type TokenStore interface {
+	Load() (string, error)
+	Save(token string) error
+}
+
+// MemoryTokenStore keeps the token in memory for the lifetime of the client.
+type MemoryTokenStore struct {
+	mu    sync.Mutex
+	token string
+}
+
+// NewMemoryTokenStore returns an empty MemoryTokenStore.
+func NewMemoryTokenStore() *MemoryTokenStore {
+	return &MemoryTokenStore{}
+}
+
+// Load returns the token.
+func (s *MemoryTokenStore) Load() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.token, nil
+}
+
+// Save replaces the token.
+func (s *MemoryTokenStore) Save(token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.token = token
+	return nil
+}
+
+// FileTokenStore keeps the token in a file, so it outlives the process.
+type FileTokenStore struct {
+	Path string
+}
+
+// Load returns the token stored in the file, or an empty token if there is no file yet.
+func (s FileTokenStore) Load() (string, error) {
+	data, err := os.ReadFile(s.Path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// Save stores the token in a file only the user can read, creating its directory if needed.
+func (s FileTokenStore) Save(token string) error {
+	if err := os.MkdirAll(filepath.Dir(s.Path), 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(s.Path, []byte(token+"\n"), 0o600)
+}